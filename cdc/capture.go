@@ -15,6 +15,7 @@ package cdc
 
 import (
 	"context"
+	"os"
 	"sync"
 	"time"
 
@@ -40,11 +41,19 @@ import (
 
 const (
 	captureSessionTTL = 3
+	// drainCheckInterval is the interval to check whether the tables of a
+	// draining capture are moved away.
+	drainCheckInterval = time.Second
+	// etcdEndpointRefreshInterval is the interval to refresh the endpoints of
+	// the etcd client from the members of PD.
+	etcdEndpointRefreshInterval = 30 * time.Second
 )
 
 // processorOpts records options for processor
 type processorOpts struct {
 	flushCheckpointInterval time.Duration
+	// authToken is the token the processors call the APIs of the owner with.
+	authToken string
 }
 
 // Capture represents a Capture server, it monitors the changefeed information in etcd and schedules Task on it.
@@ -144,6 +153,10 @@ func (c *Capture) Run(ctx context.Context) (err error) {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	// the refresher exits once the capture exits
+	go func() {
+		_ = c.etcdClient.NewEndpointRefresher(ctx, etcdEndpointRefreshInterval).Run(ctx)
+	}()
 
 	taskWatcher := NewTaskWatcher(c, &TaskWatcherConfig{
 		Prefix:      kv.TaskStatusKeyPrefix + "/" + c.info.ID,
@@ -223,6 +236,91 @@ func (c *Capture) Close(ctx context.Context) error {
 	return errors.Trace(c.etcdClient.DeleteCaptureInfo(ctx, c.info.ID))
 }
 
+// Drain marks the capture draining, so the owner moves its tables to the other
+// captures, and waits until all the tables are moved away or the context is
+// done. The processors keep running until the tables are removed, so the
+// replication is not interrupted by the shutdown of the capture.
+func (c *Capture) Drain(ctx context.Context) error {
+	info := *c.info
+	info.Draining = true
+	if err := c.etcdClient.PutCaptureInfo(ctx, &info, c.session.Lease()); err != nil {
+		return cerror.WrapError(cerror.ErrCaptureRegister, err)
+	}
+	log.Info("capture is draining", zap.String("capture-id", c.info.ID))
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for {
+		tables, err := c.countTables(ctx)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if tables == 0 {
+			log.Info("capture is drained", zap.String("capture-id", c.info.ID))
+			return nil
+		}
+		log.Info("waiting for the tables to be moved away",
+			zap.String("capture-id", c.info.ID), zap.Int("tables", tables))
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// countTables returns the number of the tables and the unapplied table
+// operations of the capture in all the changefeeds.
+func (c *Capture) countTables(ctx context.Context) (int, error) {
+	_, changefeeds, err := c.etcdClient.GetChangeFeeds(ctx)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	count := 0
+	for changefeedID := range changefeeds {
+		_, status, err := c.etcdClient.GetTaskStatus(ctx, changefeedID, c.info.ID)
+		if err != nil {
+			if cerror.ErrTaskStatusNotExists.Equal(err) {
+				continue
+			}
+			return 0, errors.Trace(err)
+		}
+		count += len(status.Tables) + len(status.Operation)
+	}
+	return count, nil
+}
+
+// changefeedStats returns the statistics of the processor of the changefeed,
+// it returns false if the changefeed has no processor on the capture.
+func (c *Capture) changefeedStats(changefeedID model.ChangeFeedID) (*model.ProcessorStats, bool) {
+	c.procLock.Lock()
+	defer c.procLock.Unlock()
+	p, ok := c.processors[changefeedID]
+	if !ok {
+		return nil, false
+	}
+	return p.stats(), true
+}
+
+// checkReady returns an error if the capture is not ready to replicate, it
+// checks the tables of the processors are initialized and the sort dirs are
+// writable.
+func (c *Capture) checkReady() error {
+	c.procLock.Lock()
+	defer c.procLock.Unlock()
+	for changefeedID, p := range c.processors {
+		if !p.tablesInitialized() {
+			return errors.Errorf("the tables of changefeed %s are initializing", changefeedID)
+		}
+		if dir, ok := p.sortDir(); ok {
+			// the dir is created once a table is started
+			if err := util.IsDirAndWritable(dir); err != nil && !os.IsNotExist(errors.Cause(err)) {
+				return errors.Annotatef(err, "the sort dir of changefeed %s is not writable", changefeedID)
+			}
+		}
+	}
+	return nil
+}
+
 func (c *Capture) handleTaskEvent(ctx context.Context, ev *TaskEvent) error {
 	task := ev.Task
 	if ev.Op == TaskOpCreate {
@@ -231,14 +329,18 @@ func (c *Capture) handleTaskEvent(ctx context.Context, ev *TaskEvent) error {
 			if err != nil {
 				return err
 			}
+			c.procLock.Lock()
 			c.processors[task.ChangeFeedID] = p
+			c.procLock.Unlock()
 		}
 	} else if ev.Op == TaskOpDelete {
 		if p, ok := c.processors[task.ChangeFeedID]; ok {
 			if err := p.stop(ctx); err != nil {
 				return errors.Trace(err)
 			}
+			c.procLock.Lock()
 			delete(c.processors, task.ChangeFeedID)
+			c.procLock.Unlock()
 		}
 	}
 	return nil
@@ -263,7 +365,7 @@ func (c *Capture) assignTask(ctx context.Context, task *Task) (*processor, error
 		zap.String("changefeed", task.ChangeFeedID))
 
 	p, err := runProcessorImpl(
-		ctx, c.pdCli, c.credential, c.session, *cf, task.ChangeFeedID, *c.info, task.CheckpointTS, c.opts.flushCheckpointInterval)
+		ctx, c.pdCli, c.credential, c.session, *cf, task.ChangeFeedID, *c.info, task.CheckpointTS, c.opts.flushCheckpointInterval, c.opts.authToken)
 	if err != nil {
 		log.Error("run processor failed",
 			zap.String("changefeed", task.ChangeFeedID),
@@ -125,6 +125,7 @@ func (s *captureSuite) TestCaptureSessionDoneDuringHandleTask(c *check.C) {
 		ctx context.Context, _ pd.Client, _ *security.Credential,
 		session *concurrency.Session, info model.ChangeFeedInfo, changefeedID string,
 		captureInfo model.CaptureInfo, checkpointTs uint64, flushCheckpointInterval time.Duration,
+		authToken string,
 	) (*processor, error) {
 		runProcessorCount++
 		etcdCli := kv.NewCDCEtcdClient(ctx, session.Client())
@@ -0,0 +1,188 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+	"go.uber.org/zap"
+)
+
+// The tables far behind, such as the new tables added from an old checkpoint,
+// catch up by the incremental scans, which take much more CPU, memory and
+// sorter disk than the tables in the steady state. The owner bounds the tables
+// catching up on each capture to protect the other tables, and dispatches them
+// to the captures with more free sorter disk.
+
+// catchUpTable is a table dispatched to a capture to catch up.
+type catchUpTable struct {
+	captureID    model.CaptureID
+	dispatchTime time.Time
+}
+
+// scheduleCatchUpTables picks the tables catching up from the orphan tables
+// and dispatches them to the captures. The tables are left orphan if all the
+// captures are running too many tables catching up. It returns the other
+// orphan tables and the operations of the tables catching up.
+func (c *changeFeed) scheduleCatchUpTables(
+	orphanTables map[model.TableID]model.Ts, captures map[model.CaptureID]*model.CaptureInfo,
+) (map[model.TableID]model.Ts, map[model.CaptureID]map[model.TableID]*model.TableOperation) {
+	cfg := c.info.Config.Scheduler
+	if cfg == nil || cfg.MaxCatchUpTables <= 0 || len(orphanTables) == 0 {
+		return orphanTables, nil
+	}
+	lag := cfg.GetCatchUpLag()
+	now := time.Now()
+	steadyTables := make(map[model.TableID]model.Ts, len(orphanTables))
+	var catchUp []model.TableID
+	for tableID, startTs := range orphanTables {
+		if now.Sub(oracle.GetTimeFromTS(startTs)) > lag {
+			catchUp = append(catchUp, tableID)
+		} else {
+			steadyTables[tableID] = startTs
+		}
+	}
+	if len(catchUp) == 0 {
+		return orphanTables, nil
+	}
+	sort.Slice(catchUp, func(i, j int) bool { return catchUp[i] < catchUp[j] })
+
+	counts := c.catchUpCounts(captures)
+	operations := make(map[model.CaptureID]map[model.TableID]*model.TableOperation)
+	for i, tableID := range catchUp {
+		captureID, ok := c.selectCatchUpCapture(counts, cfg.MaxCatchUpTables)
+		if !ok {
+			log.Info("too many tables catching up, delay dispatching the tables",
+				zap.String("changefeed", c.id), zap.Int("delayed", len(catchUp)-i),
+				zap.Int("max-catch-up-tables", cfg.MaxCatchUpTables))
+			break
+		}
+		counts[captureID]++
+		if operations[captureID] == nil {
+			operations[captureID] = make(map[model.TableID]*model.TableOperation)
+		}
+		operations[captureID][tableID] = &model.TableOperation{BoundaryTs: orphanTables[tableID]}
+	}
+	return steadyTables, operations
+}
+
+// catchUpCounts returns the number of the tables catching up on each capture,
+// including the tables dispatched to catch up but not reported yet.
+func (c *changeFeed) catchUpCounts(captures map[model.CaptureID]*model.CaptureInfo) map[model.CaptureID]int {
+	type report struct {
+		workload   model.TaskWorkload
+		reportTime time.Time
+	}
+	counts := make(map[model.CaptureID]int, len(captures))
+	reports := make(map[model.CaptureID]report, len(captures))
+	for captureID := range captures {
+		counts[captureID] = 0
+		if c.runtimeStates == nil {
+			continue
+		}
+		workload, reportTime, ok := c.runtimeStates.workloadReport(c.id, captureID)
+		if !ok {
+			continue
+		}
+		reports[captureID] = report{workload: workload, reportTime: reportTime}
+		for _, info := range workload {
+			if info.CatchingUp {
+				counts[captureID]++
+			}
+		}
+	}
+	for tableID, table := range c.catchUpTables {
+		status, ok := c.taskStatus[table.captureID]
+		if ok {
+			_, ok = status.Tables[tableID]
+		}
+		if _, exist := captures[table.captureID]; !exist || !ok {
+			// the table is moved or removed
+			delete(c.catchUpTables, tableID)
+			continue
+		}
+		r, ok := reports[table.captureID]
+		if !ok {
+			if time.Since(table.dispatchTime) > runtimeStateTTL {
+				// the processor doesn't report to the owner, the table is
+				// not counted since whether it's catching up is unknown
+				delete(c.catchUpTables, tableID)
+				continue
+			}
+			counts[table.captureID]++
+			continue
+		}
+		info, added := r.workload[tableID]
+		if added && info.CatchingUp {
+			// counted by the report
+			continue
+		}
+		if added && r.reportTime.After(table.dispatchTime) {
+			delete(c.catchUpTables, tableID)
+			log.Info("the table caught up", zap.String("changefeed", c.id),
+				zap.String("capture-id", table.captureID), zap.Int64("table-id", tableID),
+				zap.Duration("duration", time.Since(table.dispatchTime)))
+			continue
+		}
+		counts[table.captureID]++
+	}
+	return counts
+}
+
+// selectCatchUpCapture returns the capture with the most free sorter disk
+// among the captures running less than limit tables catching up, it returns
+// false if there is no such capture.
+func (c *changeFeed) selectCatchUpCapture(counts map[model.CaptureID]int, limit int) (model.CaptureID, bool) {
+	var target model.CaptureID
+	var targetFree int64
+	for captureID, count := range counts {
+		if count >= limit {
+			continue
+		}
+		var free int64
+		if c.runtimeStates != nil {
+			free = c.runtimeStates.sorterFreeBytes(captureID)
+		}
+		if target == "" || free > targetFree ||
+			(free == targetFree && (count < counts[target] || (count == counts[target] && captureID < target))) {
+			target = captureID
+			targetFree = free
+		}
+	}
+	return target, target != ""
+}
+
+// recordCatchUpTables records the tables dispatched to catch up.
+func (c *changeFeed) recordCatchUpTables(
+	operations map[model.CaptureID]map[model.TableID]*model.TableOperation, addedTables map[model.TableID]struct{},
+) {
+	now := time.Now()
+	for captureID, ops := range operations {
+		for tableID := range ops {
+			if _, ok := addedTables[tableID]; !ok {
+				continue
+			}
+			if c.catchUpTables == nil {
+				c.catchUpTables = make(map[model.TableID]catchUpTable)
+			}
+			c.catchUpTables[tableID] = catchUpTable{captureID: captureID, dispatchTime: now}
+			log.Info("dispatch the table to catch up", zap.String("changefeed", c.id),
+				zap.String("capture-id", captureID), zap.Int64("table-id", tableID))
+		}
+	}
+}
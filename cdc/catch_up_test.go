@@ -0,0 +1,95 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+)
+
+type catchUpSuite struct{}
+
+var _ = check.Suite(&catchUpSuite{})
+
+func (s *catchUpSuite) TestScheduleCatchUpTables(c *check.C) {
+	defer testleak.AfterTest(c)()
+	cfg := config.GetDefaultReplicaConfig()
+	cfg.Scheduler.MaxCatchUpTables = 1
+	captures := map[model.CaptureID]*model.CaptureInfo{
+		"capture-1": {ID: "capture-1"},
+		"capture-2": {ID: "capture-2"},
+	}
+	store := newRuntimeStateStore()
+	cf := &changeFeed{
+		id:   "test",
+		info: &model.ChangeFeedInfo{Config: cfg},
+		taskStatus: model.ProcessorsInfos{
+			"capture-1": {Tables: map[model.TableID]*model.TableReplicaInfo{1: {}}},
+			"capture-2": {Tables: map[model.TableID]*model.TableReplicaInfo{}},
+		},
+		runtimeStates: store,
+	}
+	// capture-1 is running a table catching up, capture-2 has more free disk
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID:    "test",
+		CaptureID:       "capture-1",
+		Workload:        model.TaskWorkload{1: {Workload: 1, CatchingUp: true}},
+		SorterFreeBytes: 200,
+	})
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID:    "test",
+		CaptureID:       "capture-2",
+		Workload:        model.TaskWorkload{},
+		SorterFreeBytes: 100,
+	})
+	c.Assert(store.sorterFreeBytes("capture-1"), check.Equals, int64(200))
+
+	now := oracle.EncodeTSO(time.Now().UnixNano() / int64(time.Millisecond))
+	old := oracle.EncodeTSO(time.Now().Add(-time.Hour).UnixNano() / int64(time.Millisecond))
+	orphanTables := map[model.TableID]model.Ts{2: old, 3: old, 4: now}
+	steadyTables, operations := cf.scheduleCatchUpTables(orphanTables, captures)
+	// the steady tables are distributed as usual
+	c.Assert(steadyTables, check.DeepEquals, map[model.TableID]model.Ts{4: now})
+	// only one table catching up is dispatched to the capture not full
+	c.Assert(operations, check.HasLen, 1)
+	c.Assert(operations["capture-2"], check.DeepEquals, map[model.TableID]*model.TableOperation{2: {BoundaryTs: old}})
+
+	// the dispatched table is counted before it's reported
+	cf.taskStatus["capture-2"].Tables[2] = &model.TableReplicaInfo{StartTs: old}
+	cf.recordCatchUpTables(operations, map[model.TableID]struct{}{2: {}})
+	c.Assert(cf.catchUpCounts(captures), check.DeepEquals, map[model.CaptureID]int{"capture-1": 1, "capture-2": 1})
+	_, operations = cf.scheduleCatchUpTables(map[model.TableID]model.Ts{3: old}, captures)
+	c.Assert(operations, check.HasLen, 0)
+
+	// the table is no longer counted once it's reported as caught up
+	time.Sleep(10 * time.Millisecond)
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID: "test",
+		CaptureID:    "capture-2",
+		Workload:     model.TaskWorkload{2: {Workload: 1}},
+	})
+	c.Assert(cf.catchUpCounts(captures), check.DeepEquals, map[model.CaptureID]int{"capture-1": 1, "capture-2": 0})
+	c.Assert(cf.catchUpTables, check.HasLen, 0)
+
+	// no limit by default
+	cfg.Scheduler.MaxCatchUpTables = 0
+	steadyTables, operations = cf.scheduleCatchUpTables(orphanTables, captures)
+	c.Assert(steadyTables, check.DeepEquals, orphanTables)
+	c.Assert(operations, check.IsNil)
+}
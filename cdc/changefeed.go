@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"time"
 
@@ -33,12 +34,19 @@ import (
 	"github.com/pingcap/ticdc/pkg/filter"
 	"github.com/pingcap/ticdc/pkg/scheduler"
 	"github.com/pingcap/tidb/sessionctx/binloginfo"
+	"github.com/pingcap/tidb/store/tikv/oracle"
 	"go.etcd.io/etcd/mvcc/mvccpb"
 	"go.uber.org/zap"
 )
 
 type tableIDMap = map[model.TableID]struct{}
 
+// maxDispatchTablesPerRound is the max number of orphan tables dispatched in a
+// round of the owner, a changefeed with a huge number of tables is dispatched
+// in batches to bound the size of the etcd transactions and the number of
+// operations a processor applies at a time.
+const maxDispatchTablesPerRound = 4096
+
 // OwnerDDLHandler defines the ddl handler for Owner
 // which can pull ddl jobs and execute ddl jobs
 type OwnerDDLHandler interface {
@@ -75,6 +83,25 @@ type ChangeFeedRWriter interface {
 	PutAllChangeFeedStatus(ctx context.Context, infos map[model.ChangeFeedID]*model.ChangeFeedStatus) error
 }
 
+// cachedChangeFeedRWriter is a ChangeFeedRWriter that reads the task statuses
+// incrementally, only the changed ones are read from etcd.
+type cachedChangeFeedRWriter struct {
+	kv.CDCEtcdClient
+	taskStatusCache *kv.TaskStatusCache
+}
+
+func newCachedChangeFeedRWriter(cli kv.CDCEtcdClient) cachedChangeFeedRWriter {
+	return cachedChangeFeedRWriter{
+		CDCEtcdClient:   cli,
+		taskStatusCache: kv.NewTaskStatusCache(),
+	}
+}
+
+// GetAllTaskStatus implements ChangeFeedRWriter interface.
+func (w cachedChangeFeedRWriter) GetAllTaskStatus(ctx context.Context, changefeedID string) (model.ProcessorsInfos, error) {
+	return w.GetAllTaskStatusCached(ctx, changefeedID, w.taskStatusCache)
+}
+
 type changeFeed struct {
 	id     string
 	info   *model.ChangeFeedInfo
@@ -92,7 +119,6 @@ type changeFeed struct {
 	updateResolvedTs bool
 	startTimer       chan bool
 	syncpointStore   sink.SyncpointStore
-	syncCancel       context.CancelFunc
 	taskStatus       model.ProcessorsInfos
 	taskPositions    map[model.CaptureID]*model.TaskPosition
 	filter           *filter.Filter
@@ -115,10 +141,22 @@ type changeFeed struct {
 	moveTableJobs      map[model.TableID]*model.MoveTableJob
 	manualMoveCommands []*model.MoveTableJob
 	rebalanceNextTick  bool
+	// catchUpTables are the tables dispatched to catch up, they're counted
+	// until the processors report they caught up
+	catchUpTables map[model.TableID]catchUpTable
 
 	lastRebalanceTime time.Time
 
 	etcdCli kv.CDCEtcdClient
+	// runtimeStates keeps the runtime states reported by the processors, the
+	// states in etcd are used if it's nil or the states are not reported
+	runtimeStates *runtimeStateStore
+	// decisions records the decisions made for the changefeed, it may be nil
+	decisions *decisionLog
+	// cleanTicks is the number of the consecutive owner ticks without any
+	// warnings, the changefeed in the warning state returns to normal once
+	// it reaches warningClearTicks
+	cleanTicks int
 
 	// context cancel function for all internal goroutines
 	cancel context.CancelFunc
@@ -219,6 +257,96 @@ func (c *changeFeed) removeTable(sid model.SchemaID, tid model.TableID, targetTs
 	}
 }
 
+// renameTable updates the table after it's renamed, the table is started or
+// stopped at targetTs if it's moved into or out of the filter scope. It
+// returns whether the DDL should be skipped, the DDL of a table moved into the
+// scope is skipped, since its old name doesn't exist in the downstream.
+func (c *changeFeed) renameTable(tblInfo *model.TableInfo, targetTs model.Ts) (skip bool) {
+	oldName, replicating := c.tables[tblInfo.ID]
+	ignored := c.filter.ShouldIgnoreTable(tblInfo.TableName.Schema, tblInfo.TableName.Table)
+	switch {
+	case replicating && ignored:
+		log.Info("table is renamed out of the filter scope, stop replicating it",
+			zap.String("changefeed", c.id), zap.Int64("tableID", tblInfo.ID),
+			zap.Stringer("oldName", oldName), zap.Stringer("newName", tblInfo.TableName),
+			zap.Uint64("targetTs", targetTs))
+		c.removeTable(c.schemaIDOfTable(tblInfo.ID), tblInfo.ID, targetTs)
+	case !replicating && !ignored:
+		log.Info("table is renamed into the filter scope, start replicating it, "+
+			"the table must be created in the downstream manually",
+			zap.String("changefeed", c.id), zap.Int64("tableID", tblInfo.ID),
+			zap.Stringer("newName", tblInfo.TableName), zap.Uint64("targetTs", targetTs))
+		c.addTable(tblInfo, targetTs)
+		return true
+	case replicating:
+		// the table may be moved to another schema
+		if sid := c.schemaIDOfTable(tblInfo.ID); sid != tblInfo.SchemaID {
+			delete(c.schemas[sid], tblInfo.ID)
+			if _, ok := c.schemas[tblInfo.SchemaID]; !ok {
+				c.schemas[tblInfo.SchemaID] = make(tableIDMap)
+			}
+			c.schemas[tblInfo.SchemaID][tblInfo.ID] = struct{}{}
+		}
+		// no id change just update name
+		c.tables[tblInfo.ID] = tblInfo.TableName
+	}
+	return false
+}
+
+// exchangePartition handles EXCHANGE PARTITION. The partition and the table
+// swap their IDs while the data stays, so the physical tables are kept
+// replicating if both sides of the exchange are replicated, otherwise the
+// physical table moved into the scope is started and the one moved out of the
+// scope is stopped at the commit ts of the DDL. It returns whether the DDL
+// should be skipped, it's only executed if both sides exist in the downstream.
+func (c *changeFeed) exchangePartition(job *timodel.Job) (skip bool, err error) {
+	defID, _, ptID, err := entry.ExchangePartitionArgs(job)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	ntTable, exist := c.schema.TableByID(defID)
+	if !exist {
+		return false, cerror.ErrSnapshotTableNotFound.GenWithStackByArgs(defID)
+	}
+	ntOldID := job.TableID
+	targetTs := job.BinlogInfo.FinishedTS
+	_, ntReplicated := c.tables[ntOldID]
+	_, ptReplicated := c.tables[ptID]
+	switch {
+	case ntReplicated && ptReplicated:
+		delete(c.schemas[ntTable.SchemaID], ntOldID)
+		c.schemas[ntTable.SchemaID][defID] = struct{}{}
+		delete(c.tables, ntOldID)
+		c.tables[defID] = ntTable.TableName
+		for i, pid := range c.partitions[ptID] {
+			if pid == defID {
+				c.partitions[ptID][i] = ntOldID
+			}
+		}
+		// the physical tables keep replicating
+		return false, nil
+	case ntReplicated:
+		c.removeTable(ntTable.SchemaID, ntOldID, targetTs)
+		c.addTable(ntTable, targetTs)
+	case ptReplicated:
+		c.updatePartition(job.BinlogInfo.TableInfo, targetTs)
+	}
+	log.Info("partition is exchanged with a table out of the replication scope, skip the DDL",
+		zap.String("changefeed", c.id), zap.String("query", job.Query),
+		zap.Bool("tableReplicated", ntReplicated), zap.Bool("partitionedTableReplicated", ptReplicated),
+		zap.Uint64("targetTs", targetTs))
+	return true, nil
+}
+
+func (c *changeFeed) schemaIDOfTable(tid model.TableID) model.SchemaID {
+	for sid, tables := range c.schemas {
+		if _, ok := tables[tid]; ok {
+			return sid
+		}
+	}
+	return 0
+}
+
 func (c *changeFeed) updatePartition(tblInfo *timodel.TableInfo, startTs uint64) {
 	tid := tblInfo.ID
 	partitionsID, ok := c.partitions[tid]
@@ -237,8 +365,7 @@ func (c *changeFeed) updatePartition(tblInfo *timodel.TableInfo, startTs uint64)
 	newPartitionIDs := make([]int64, 0, len(pi.Definitions))
 	for _, partition := range pi.Definitions {
 		pid := partition.ID
-		_, ok := c.orphanTables[pid]
-		if !ok {
+		if _, ok := oldIDs[pid]; !ok {
 			// new partition.
 			c.orphanTables[pid] = startTs
 		}
@@ -260,7 +387,10 @@ func (c *changeFeed) updatePartition(tblInfo *timodel.TableInfo, startTs uint64)
 
 func (c *changeFeed) tryBalance(ctx context.Context, captures map[string]*model.CaptureInfo, rebalanceNow bool,
 	manualMoveCommands []*model.MoveTableJob) error {
-	err := c.balanceOrphanTables(ctx, captures)
+	// the draining captures keep their tables until the tables are moved
+	// away, but no table is scheduled to them
+	schedulable := schedulableCaptures(captures)
+	err := c.balanceOrphanTables(ctx, schedulable)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -268,11 +398,12 @@ func (c *changeFeed) tryBalance(ctx context.Context, captures map[string]*model.
 	if rebalanceNow {
 		c.rebalanceNextTick = true
 	}
-	err = c.handleManualMoveTableJobs(ctx, captures)
+	c.drainCaptures(captures, schedulable)
+	err = c.handleManualMoveTableJobs(ctx, schedulable)
 	if err != nil {
 		return errors.Trace(err)
 	}
-	err = c.rebalanceTables(ctx, captures)
+	err = c.rebalanceTables(ctx, schedulable)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -280,12 +411,65 @@ func (c *changeFeed) tryBalance(ctx context.Context, captures map[string]*model.
 	return errors.Trace(err)
 }
 
+// schedulableCaptures returns the captures which are not draining.
+func schedulableCaptures(captures map[model.CaptureID]*model.CaptureInfo) map[model.CaptureID]*model.CaptureInfo {
+	schedulable := make(map[model.CaptureID]*model.CaptureInfo, len(captures))
+	for id, info := range captures {
+		if !info.Draining {
+			schedulable[id] = info
+		}
+	}
+	return schedulable
+}
+
+// drainCaptures moves the tables of the draining captures to the capture with
+// the fewest tables, the moves are added once the previous moves are done.
+func (c *changeFeed) drainCaptures(captures, schedulable map[model.CaptureID]*model.CaptureInfo) {
+	if len(schedulable) == 0 || len(schedulable) == len(captures) {
+		return
+	}
+	if len(c.manualMoveCommands) > 0 || len(c.moveTableJobs) > 0 {
+		return
+	}
+	tableCounts := make(map[model.CaptureID]int, len(schedulable))
+	for id := range schedulable {
+		if status, ok := c.taskStatus[id]; ok {
+			tableCounts[id] = len(status.Tables)
+		} else {
+			tableCounts[id] = 0
+		}
+	}
+	for id, info := range captures {
+		if !info.Draining {
+			continue
+		}
+		status, ok := c.taskStatus[id]
+		if !ok {
+			continue
+		}
+		for tableID := range status.Tables {
+			var target model.CaptureID
+			for cid, count := range tableCounts {
+				if target == "" || count < tableCounts[target] || (count == tableCounts[target] && cid < target) {
+					target = cid
+				}
+			}
+			tableCounts[target]++
+			c.manualMoveCommands = append(c.manualMoveCommands, &model.MoveTableJob{
+				To:      target,
+				TableID: tableID,
+				Reason:  fmt.Sprintf("the capture %s is draining", id),
+			})
+			log.Info("move the table of the draining capture", zap.String("changefeed", c.id),
+				zap.String("capture-id", id), zap.Int64("table-id", tableID), zap.String("target", target))
+		}
+	}
+}
+
 func findTaskStatusWithTable(infos model.ProcessorsInfos, tableID model.TableID) (captureID model.CaptureID, info *model.TaskStatus, ok bool) {
 	for cid, info := range infos {
-		for tid := range info.Tables {
-			if tid == tableID {
-				return cid, info, true
-			}
+		if _, exist := info.Tables[tableID]; exist {
+			return cid, info, true
 		}
 	}
 	return "", nil, false
@@ -327,7 +511,30 @@ func (c *changeFeed) balanceOrphanTables(ctx context.Context, captures map[model
 		cleanedTables[id] = struct{}{}
 	}
 
-	operations := c.scheduler.DistributeTables(c.orphanTables)
+	orphanTables := c.orphanTables
+	if len(orphanTables) > maxDispatchTablesPerRound {
+		orphanTables = make(map[model.TableID]model.Ts, maxDispatchTablesPerRound)
+		for tableID, startTs := range c.orphanTables {
+			if len(orphanTables) >= maxDispatchTablesPerRound {
+				break
+			}
+			orphanTables[tableID] = startTs
+		}
+		log.Info("too many orphan tables, dispatch them in batches",
+			zap.String("changefeed", c.id),
+			zap.Int("orphanTables", len(c.orphanTables)),
+			zap.Int("batchSize", len(orphanTables)))
+	}
+	orphanTables, catchUpOperations := c.scheduleCatchUpTables(orphanTables, captures)
+	operations := c.scheduler.DistributeTables(orphanTables)
+	for captureID, ops := range catchUpOperations {
+		if operations[captureID] == nil {
+			operations[captureID] = make(map[model.TableID]*model.TableOperation, len(ops))
+		}
+		for tableID, op := range ops {
+			operations[captureID][tableID] = op
+		}
+	}
 	for captureID, operation := range operations {
 		schemaSnapshot := c.schema
 		for tableID, op := range operation {
@@ -371,7 +578,8 @@ func (c *changeFeed) balanceOrphanTables(ctx context.Context, captures map[model
 			return errors.Trace(err)
 		}
 		c.taskStatus[captureID] = newStatus.Clone()
-		log.Info("dispatch table success", zap.String("capture-id", captureID), zap.Stringer("status", newStatus))
+		log.Info("dispatch table success", zap.String("capture-id", captureID),
+			zap.Int("tables", len(newStatus.Tables)), zap.Int("operations", len(funcs)))
 		failpoint.Inject("OwnerRemoveTableError", func() {
 			if len(cleanedTables) > 0 {
 				failpoint.Return(errors.New("failpoint injected error"))
@@ -385,6 +593,7 @@ func (c *changeFeed) balanceOrphanTables(ctx context.Context, captures map[model
 	for tableID := range addedTables {
 		delete(c.orphanTables, tableID)
 	}
+	c.recordCatchUpTables(catchUpOperations, addedTables)
 
 	return nil
 }
@@ -404,7 +613,8 @@ func (c *changeFeed) updateTaskStatus(ctx context.Context, taskStatus map[model.
 			return errors.Trace(err)
 		}
 		c.taskStatus[captureID] = newStatus.Clone()
-		log.Info("dispatch table success", zap.String("capture-id", captureID), zap.Stringer("status", status))
+		log.Info("dispatch table success", zap.String("capture-id", captureID),
+			zap.Int("tables", len(status.Tables)), zap.Int("operations", len(status.Operation)))
 	}
 	return nil
 }
@@ -434,6 +644,13 @@ func (c *changeFeed) handleManualMoveTableJobs(ctx context.Context, captures map
 			continue
 		}
 		if moveJob.To == moveJob.From {
+			if c.taskStatus[moveJob.From].Tables[moveJob.TableID].Pinned != moveJob.Pin {
+				// pin or unpin the table in place
+				if err := c.pinTable(ctx, moveJob.From, moveJob.TableID, moveJob.Pin); err != nil {
+					return errors.Trace(err)
+				}
+				continue
+			}
 			log.Warn("invalid manual move job, the table is already exists in the target capture", zap.Reflect("job", moveJob))
 			continue
 		}
@@ -445,11 +662,78 @@ func (c *changeFeed) handleManualMoveTableJobs(ctx context.Context, captures map
 			c.moveTableJobs = make(map[model.TableID]*model.MoveTableJob)
 		}
 		c.moveTableJobs[moveJob.TableID] = moveJob
+		c.recordMoveTable(moveJob)
 		log.Info("received the manual move table job", zap.Reflect("job", moveJob))
 	}
 	return nil
 }
 
+// pinTable pins or unpins the table on the capture replicating it.
+func (c *changeFeed) pinTable(ctx context.Context, captureID model.CaptureID, tableID model.TableID, pin bool) error {
+	newStatus, _, err := c.etcdCli.AtomicPutTaskStatus(ctx, c.id, captureID, func(_ int64, status *model.TaskStatus) (bool, error) {
+		replicaInfo, exist := status.Tables[tableID]
+		if !exist || replicaInfo.Pinned == pin {
+			return false, nil
+		}
+		replicaInfo.Pinned = pin
+		return true, nil
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	c.taskStatus[captureID] = newStatus.Clone()
+	log.Info("update the pin of the table", zap.String("changefeed", c.id),
+		zap.String("capture-id", captureID), zap.Int64("table-id", tableID), zap.Bool("pin", pin))
+	return nil
+}
+
+// pinnedTables returns the tables pinned to the captures.
+func (c *changeFeed) pinnedTables() map[model.TableID]model.CaptureID {
+	pinned := make(map[model.TableID]model.CaptureID)
+	for captureID, status := range c.taskStatus {
+		for tableID, replicaInfo := range status.Tables {
+			if replicaInfo != nil && replicaInfo.Pinned {
+				pinned[tableID] = captureID
+			}
+		}
+	}
+	return pinned
+}
+
+// startTsOfTable returns the larger one of startTs and the start ts specified
+// for the table or the table of the partition.
+func (c *changeFeed) startTsOfTable(tableID model.TableID, startTs model.Ts) model.Ts {
+	if len(c.info.TableStartTs) == 0 {
+		return startTs
+	}
+	name, ok := c.tables[tableID]
+	if !ok {
+		for tid, pids := range c.partitions {
+			for _, pid := range pids {
+				if pid == tableID {
+					name, ok = c.tables[tid]
+				}
+			}
+		}
+	}
+	if !ok {
+		return startTs
+	}
+	return c.info.StartTsOfTable(name, startTs)
+}
+
+// recordMoveTable records the decision of moving a table.
+func (c *changeFeed) recordMoveTable(job *model.MoveTableJob) {
+	c.decisions.record(&OwnerDecision{
+		Kind:         DecisionMoveTable,
+		ChangefeedID: c.id,
+		TableID:      job.TableID,
+		From:         job.From,
+		To:           job.To,
+		Reason:       job.Reason,
+	})
+}
+
 func (c *changeFeed) rebalanceTables(ctx context.Context, captures map[model.CaptureID]*model.CaptureInfo) error {
 	if len(captures) == 0 {
 		return nil
@@ -468,12 +752,22 @@ func (c *changeFeed) rebalanceTables(ctx context.Context, captures map[model.Cap
 	if !c.rebalanceNextTick && !timeToRebalance {
 		return nil
 	}
+	reason := "rebalance the tables periodically"
+	if c.rebalanceNextTick {
+		reason = "rebalance the tables on demand"
+	}
 	c.lastRebalanceTime = time.Now()
 	c.rebalanceNextTick = false
 
 	captureIDs := make(map[model.CaptureID]struct{}, len(captures))
 	for cid := range captures {
 		captureIDs[cid] = struct{}{}
+		if c.runtimeStates != nil {
+			if workloads, ok := c.runtimeStates.workload(c.id, cid); ok {
+				c.scheduler.ResetWorkloads(cid, workloads)
+				continue
+			}
+		}
 		workloads, err := c.etcdCli.GetTaskWorkload(ctx, c.id, cid)
 		if err != nil {
 			return errors.Trace(err)
@@ -483,7 +777,20 @@ func (c *changeFeed) rebalanceTables(ctx context.Context, captures map[model.Cap
 	c.scheduler.AlignCapture(captureIDs)
 
 	_, moveTableJobs := c.scheduler.CalRebalanceOperates(0)
+	// the pinned tables stay on their captures until they are moved manually
+	pinned := c.pinnedTables()
+	for tableID := range moveTableJobs {
+		if captureID, ok := pinned[tableID]; ok {
+			log.Info("skip moving the pinned table", zap.String("changefeed", c.id),
+				zap.String("capture-id", captureID), zap.Int64("table-id", tableID))
+			delete(moveTableJobs, tableID)
+		}
+	}
 	log.Info("rebalance operations", zap.Reflect("moveTableJobs", moveTableJobs))
+	for _, job := range moveTableJobs {
+		job.Reason = reason
+		c.recordMoveTable(job)
+	}
 	c.moveTableJobs = moveTableJobs
 	return nil
 }
@@ -536,7 +843,8 @@ func (c *changeFeed) handleMoveTableJobs(ctx context.Context, captures map[model
 				log.Warn("ignored the move job, the table is not exist in the source capture", zap.Reflect("job", job))
 				continue
 			}
-			replicaInfo.StartTs = c.status.ResolvedTs
+			replicaInfo.StartTs = c.startTsOfTable(tableID, c.status.ResolvedTs)
+			replicaInfo.Pinned = job.Pin
 			job.TableReplicaInfo = replicaInfo
 			job.Status = model.MoveTableStatusDeleted
 			log.Info("handle the move job, remove table from the source capture", zap.Reflect("job", job))
@@ -558,6 +866,14 @@ func (c *changeFeed) handleMoveTableJobs(ctx context.Context, captures map[model
 			if !exist {
 				// the target capture is not exist, add table to orphanTables.
 				c.orphanTables[tableID] = replicaInfo.StartTs
+				c.decisions.record(&OwnerDecision{
+					Kind:         DecisionOrphanTable,
+					ChangefeedID: c.id,
+					TableID:      tableID,
+					From:         job.From,
+					To:           job.To,
+					Reason:       fmt.Sprintf("the target capture %s is not found", job.To),
+				})
 				log.Warn("the target capture is not exist, sent the table to orphanTables", zap.Reflect("job", job))
 				continue
 			}
@@ -599,12 +915,11 @@ func (c *changeFeed) applyJob(ctx context.Context, job *timodel.Job) (skip bool,
 			dropID := job.TableID
 			c.removeTable(schemaID, dropID, job.BinlogInfo.FinishedTS)
 		case timodel.ActionRenameTable:
-			tableName, exist := c.schema.GetTableNameByID(job.TableID)
+			table, exist := c.schema.TableByID(job.TableID)
 			if !exist {
 				return cerror.ErrSnapshotTableNotFound.GenWithStackByArgs(job.TableID)
 			}
-			// no id change just update name
-			c.tables[job.TableID] = tableName
+			skip = c.renameTable(table, job.BinlogInfo.FinishedTS)
 		case timodel.ActionTruncateTable:
 			dropID := job.TableID
 			c.removeTable(schemaID, dropID, job.BinlogInfo.FinishedTS)
@@ -617,6 +932,16 @@ func (c *changeFeed) applyJob(ctx context.Context, job *timodel.Job) (skip bool,
 			c.addTable(table, job.BinlogInfo.FinishedTS)
 		case timodel.ActionTruncateTablePartition, timodel.ActionAddTablePartition, timodel.ActionDropTablePartition:
 			c.updatePartition(job.BinlogInfo.TableInfo, job.BinlogInfo.FinishedTS)
+		case timodel.ActionExchangeTablePartition:
+			var err error
+			skip, err = c.exchangePartition(job)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		case timodel.ActionAlterTableAlterPartition:
+			// the placement rules are bound to the topology of the upstream
+			// cluster, they are not replicated
+			skip = true
 		}
 		return nil
 	}()
@@ -624,7 +949,7 @@ func (c *changeFeed) applyJob(ctx context.Context, job *timodel.Job) (skip bool,
 		log.Error("failed to applyJob, start to print debug info", zap.Error(err))
 		c.schema.PrintStatus(log.Error)
 	}
-	return false, err
+	return skip, err
 }
 
 // handleDDL check if we can change the status to be `ChangeFeedExecDDL` and execute the DDL asynchronously
@@ -742,7 +1067,7 @@ func (c *changeFeed) handleSyncPoint(ctx context.Context) error {
 		// ticker and ddl can trigger syncpoint record at the same time, only record once
 		syncpointRecorded := false
 		// ResolvedTs == CheckpointTs means a syncpoint reached;
-		// !c.updateResolvedTs means the syncpoint is setted by ticker;
+		// !c.updateResolvedTs means the syncpoint is setted by the interval;
 		// c.ddlTs == 0 means no DDL wait to exec and we can sink the syncpoint record securely ( c.ddlTs != 0 means some DDL should be sink to downstream and this syncpoint is fake ).
 		if c.status.ResolvedTs == c.status.CheckpointTs && !c.updateResolvedTs {
 			log.Info("sync point reached by ticker", zap.Uint64("ResolvedTs", c.status.ResolvedTs), zap.Uint64("CheckpointTs", c.status.CheckpointTs), zap.Bool("updateResolvedTs", c.updateResolvedTs), zap.Uint64("ddlResolvedTs", c.ddlResolvedTs), zap.Uint64("ddlTs", c.ddlTs), zap.Uint64("ddlExecutedTs", c.ddlExecutedTs))
@@ -811,13 +1136,16 @@ func (c *changeFeed) calcResolvedTs(ctx context.Context) error {
 			zap.Int("taskStatus", len(c.taskStatus)))
 		return nil
 	}
+	// barrier explains which one of the following limits minResolvedTs
+	barrier := &BarrierExplanation{Source: BarrierTargetTs}
 	if len(c.taskPositions) == 0 {
 		minCheckpointTs = c.status.ResolvedTs
 	} else {
 		// calc the min of all resolvedTs in captures
-		for _, position := range c.taskPositions {
+		for captureID, position := range c.taskPositions {
 			if minResolvedTs > position.ResolvedTs {
 				minResolvedTs = position.ResolvedTs
+				barrier = &BarrierExplanation{Source: BarrierProcessor, Capture: captureID}
 			}
 
 			if minCheckpointTs > position.CheckPointTs {
@@ -835,6 +1163,7 @@ func (c *changeFeed) calcResolvedTs(ctx context.Context) error {
 		}
 		if minResolvedTs > appliedTs {
 			minResolvedTs = appliedTs
+			barrier = &BarrierExplanation{Source: BarrierUnappliedOperation, Capture: captureID}
 		}
 		if appliedTs != math.MaxUint64 {
 			log.Debug("some operation is still unapplied",
@@ -845,22 +1174,24 @@ func (c *changeFeed) calcResolvedTs(ctx context.Context) error {
 	}
 	checkUpdateTs()
 
-	for _, startTs := range c.orphanTables {
+	for tableID, startTs := range c.orphanTables {
 		if minCheckpointTs > startTs {
 			minCheckpointTs = startTs
 		}
 		if minResolvedTs > startTs {
 			minResolvedTs = startTs
+			barrier = &BarrierExplanation{Source: BarrierOrphanTable, TableID: tableID}
 		}
 	}
 	checkUpdateTs()
 
-	for _, targetTs := range c.toCleanTables {
+	for tableID, targetTs := range c.toCleanTables {
 		if minCheckpointTs > targetTs {
 			minCheckpointTs = targetTs
 		}
 		if minResolvedTs > targetTs {
 			minResolvedTs = targetTs
+			barrier = &BarrierExplanation{Source: BarrierCleaningTable, TableID: tableID}
 		}
 	}
 	checkUpdateTs()
@@ -876,7 +1207,31 @@ func (c *changeFeed) calcResolvedTs(ctx context.Context) error {
 
 		if minResolvedTs > c.ddlResolvedTs {
 			minResolvedTs = c.ddlResolvedTs
+			barrier = &BarrierExplanation{Source: BarrierDDLPuller}
+		}
+	}
+	checkUpdateTs()
+
+	// the resolved ts stops at the next syncpoint until it's recorded
+	var syncpointTs uint64
+	if c.info.SyncPointEnabled && c.status.ResolvedTs != 0 {
+		c.syncpointMutex.Lock()
+		if c.updateResolvedTs {
+			syncpointTs = nextSyncpointTs(c.status.ResolvedTs, time.Now(), c.info.SyncPointInterval)
+			if minResolvedTs > syncpointTs {
+				minResolvedTs = syncpointTs
+				barrier = &BarrierExplanation{Source: BarrierSyncpoint}
+			}
 		}
+		c.syncpointMutex.Unlock()
+	}
+	checkUpdateTs()
+
+	// the resolved ts stops at the ts of the pending sink switch, the owner
+	// switches the sink once the checkpoint reaches it
+	if c.info.SinkSwitch != nil && minResolvedTs > c.info.SinkSwitch.Ts {
+		minResolvedTs = c.info.SinkSwitch.Ts
+		barrier = &BarrierExplanation{Source: BarrierSinkSwitch}
 	}
 	checkUpdateTs()
 
@@ -887,6 +1242,7 @@ func (c *changeFeed) calcResolvedTs(ctx context.Context) error {
 	}
 	if len(c.ddlJobHistory) > 0 && minResolvedTs >= c.ddlJobHistory[0].BinlogInfo.FinishedTS {
 		minResolvedTs = c.ddlJobHistory[0].BinlogInfo.FinishedTS
+		barrier = &BarrierExplanation{Source: BarrierDDLJob, DDLJobID: c.ddlJobHistory[0].ID}
 		c.ddlState = model.ChangeFeedWaitToExecDDL
 		c.ddlTs = minResolvedTs
 	}
@@ -903,18 +1259,26 @@ func (c *changeFeed) calcResolvedTs(ctx context.Context) error {
 
 	var tsUpdated bool
 
+	barrier.Ts = minResolvedTs
 	// syncpoint on
 	if c.info.SyncPointEnabled {
 		c.syncpointMutex.Lock()
+		if !c.updateResolvedTs {
+			barrier = &BarrierExplanation{Ts: c.status.ResolvedTs, Source: BarrierSyncpoint, Holding: true}
+		}
 		if c.updateResolvedTs && minResolvedTs > c.status.ResolvedTs {
 			c.status.ResolvedTs = minResolvedTs
 			tsUpdated = true
+			if minResolvedTs == syncpointTs {
+				c.updateResolvedTs = false
+			}
 		}
 		c.syncpointMutex.Unlock()
 	} else if minResolvedTs > c.status.ResolvedTs {
 		c.status.ResolvedTs = minResolvedTs
 		tsUpdated = true
 	}
+	c.decisions.setBarrier(c.id, barrier)
 
 	if minCheckpointTs > c.status.CheckpointTs {
 		c.status.CheckpointTs = minCheckpointTs
@@ -955,35 +1319,21 @@ func (c *changeFeed) pullDDLJob() error {
 	return nil
 }
 
-// startSyncPeriod start a timer for every changefeed to create sync point by time
-func (c *changeFeed) startSyncPeriod(ctx context.Context, interval time.Duration) {
-	log.Debug("sync ticker start", zap.Duration("sync-interval", interval))
-	go func(ctx context.Context) {
-		ticker := time.NewTicker(interval)
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				c.syncpointMutex.Lock()
-				c.updateResolvedTs = false
-				c.syncpointMutex.Unlock()
-			}
-		}
-	}(ctx)
-}
-
-func (c *changeFeed) stopSyncPointTicker() {
-	if c.syncCancel != nil {
-		c.syncCancel()
-		c.syncCancel = nil
-	}
-}
-
-func (c *changeFeed) startSyncPointTicker(ctx context.Context, interval time.Duration) {
-	var syncCtx context.Context
-	syncCtx, c.syncCancel = context.WithCancel(ctx)
-	c.startSyncPeriod(syncCtx, interval)
+// nextSyncpointTs returns the ts of the next syncpoint after the resolved ts.
+// The syncpoints are aligned to the multiples of the interval, so the
+// changefeeds with the same interval have the syncpoints at the same ts, and
+// their downstreams can be compared. The syncpoints older than an interval ago
+// are skipped, so a changefeed catching up doesn't stop at every one of them.
+func nextSyncpointTs(resolvedTs uint64, now time.Time, interval time.Duration) uint64 {
+	step := int64(interval / time.Millisecond)
+	if step <= 0 {
+		step = 1
+	}
+	from := oracle.ExtractPhysical(resolvedTs)
+	if recent := oracle.GetPhysical(now.Add(-interval)); recent > from {
+		from = recent
+	}
+	return oracle.ComposeTS((from/step+1)*step, 0)
 }
 
 func (c *changeFeed) Close() {
@@ -1011,5 +1361,22 @@ func (c *changeFeed) Close() {
 	if c.cancel != nil {
 		c.cancel()
 	}
+	c.decisions.removeChangefeed(c.id)
 	log.Info("changefeed closed", zap.String("id", c.id))
 }
+
+// collectWarnings returns the warnings reported by the processors, ordered by
+// the captures.
+func collectWarnings(positions map[model.CaptureID]*model.TaskPosition) []*model.RunningError {
+	var warnings []*model.RunningError
+	for _, position := range positions {
+		warnings = append(warnings, position.Warnings...)
+	}
+	sort.SliceStable(warnings, func(i, j int) bool {
+		if warnings[i].Addr != warnings[j].Addr {
+			return warnings[i].Addr < warnings[j].Addr
+		}
+		return warnings[i].Message < warnings[j].Message
+	})
+	return warnings
+}
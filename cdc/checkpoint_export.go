@@ -0,0 +1,176 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/quotes"
+	"github.com/pingcap/ticdc/pkg/secret"
+	"github.com/pingcap/ticdc/pkg/verify"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+	"go.uber.org/zap"
+)
+
+const (
+	checkpointExportSchema = "tidb_cdc"
+	checkpointExportTable  = "changefeed_checkpoint"
+)
+
+// checkpointExporter writes the checkpoint ts and the resolved ts of the
+// changefeeds into a table of the upstream TiDB, so the progress can be
+// consumed by SQL without the CDC API. The connection is opened lazily, so
+// the capture doesn't depend on the availability of the TiDB.
+type checkpointExporter struct {
+	uri          string
+	db           *sql.DB
+	tableCreated bool
+}
+
+func newCheckpointExporter(uri string) *checkpointExporter {
+	return &checkpointExporter{uri: uri}
+}
+
+func (e *checkpointExporter) close() {
+	if e.db != nil {
+		if err := e.db.Close(); err != nil {
+			log.Warn("close the checkpoint export db failed", zap.Error(err))
+		}
+		e.db = nil
+	}
+}
+
+func (e *checkpointExporter) quotedTable() string {
+	return quotes.QuoteSchema(checkpointExportSchema, checkpointExportTable)
+}
+
+func (e *checkpointExporter) createTable(ctx context.Context) error {
+	if _, err := e.db.ExecContext(ctx, "CREATE DATABASE IF NOT EXISTS "+quotes.QuoteName(checkpointExportSchema)); err != nil {
+		return cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	query := "CREATE TABLE IF NOT EXISTS " + e.quotedTable() + ` (
+	changefeed_id VARCHAR(128) NOT NULL PRIMARY KEY,
+	checkpoint_ts BIGINT UNSIGNED NOT NULL,
+	resolved_ts BIGINT UNSIGNED NOT NULL,
+	checkpoint_time DATETIME(3) NOT NULL COMMENT 'the physical time of checkpoint_ts in UTC',
+	update_time TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3)
+)`
+	if _, err := e.db.ExecContext(ctx, query); err != nil {
+		return cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	return nil
+}
+
+// export replaces the rows of the table by the statuses of the changefeeds,
+// the rows of the removed changefeeds are deleted.
+func (e *checkpointExporter) export(ctx context.Context, statuses map[model.ChangeFeedID]*model.ChangeFeedStatus) error {
+	if e.db == nil {
+		uri, err := secret.ResolveURI(ctx, e.uri)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		db, err := verify.OpenDB(ctx, uri)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		e.db = db
+	}
+	if !e.tableCreated {
+		if err := e.createTable(ctx); err != nil {
+			return errors.Trace(err)
+		}
+		e.tableCreated = true
+	}
+	ids := make([]string, 0, len(statuses))
+	for id := range statuses {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrMySQLTxnError, err)
+	}
+	if len(ids) == 0 {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+e.quotedTable()); err != nil {
+			_ = tx.Rollback()
+			return cerror.WrapError(cerror.ErrMySQLTxnError, err)
+		}
+		return cerror.WrapError(cerror.ErrMySQLTxnError, tx.Commit())
+	}
+
+	placeholders := make([]string, 0, len(ids))
+	args := make([]interface{}, 0, len(ids)*4)
+	for _, id := range ids {
+		status := statuses[id]
+		placeholders = append(placeholders, "(?,?,?,?)")
+		args = append(args, id, status.CheckpointTs, status.ResolvedTs,
+			oracle.GetTimeFromTS(status.CheckpointTs).UTC().Format("2006-01-02 15:04:05.000"))
+	}
+	query := "REPLACE INTO " + e.quotedTable() +
+		" (changefeed_id, checkpoint_ts, resolved_ts, checkpoint_time) VALUES " + strings.Join(placeholders, ",")
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		_ = tx.Rollback()
+		return cerror.WrapError(cerror.ErrMySQLTxnError, err)
+	}
+	query = "DELETE FROM " + e.quotedTable() + " WHERE changefeed_id NOT IN (" +
+		strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + ")"
+	args = args[:0]
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		_ = tx.Rollback()
+		return cerror.WrapError(cerror.ErrMySQLTxnError, err)
+	}
+	return cerror.WrapError(cerror.ErrMySQLTxnError, tx.Commit())
+}
+
+// exportCheckpointLoop exports the progress of the changefeeds periodically
+// while the capture is the owner, the failures are logged and retried in the
+// next round since the replication doesn't depend on them.
+func (s *Server) exportCheckpointLoop(ctx context.Context) error {
+	exporter := newCheckpointExporter(s.opts.checkpointExportURI)
+	defer exporter.close()
+	ticker := time.NewTicker(s.opts.checkpointExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		s.ownerLock.RLock()
+		isOwner := s.owner != nil
+		s.ownerLock.RUnlock()
+		if !isOwner {
+			continue
+		}
+		statuses, err := s.capture.etcdClient.GetAllChangeFeedStatus(ctx)
+		if err == nil {
+			err = exporter.export(ctx, statuses)
+		}
+		if err != nil && errors.Cause(err) != context.Canceled {
+			log.Warn("export the checkpoints of the changefeeds failed", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type checkpointExportSuite struct{}
+
+var _ = check.Suite(&checkpointExportSuite{})
+
+func (s *checkpointExportSuite) TestExport(c *check.C) {
+	defer testleak.AfterTest(c)()
+	db, mock, err := sqlmock.New()
+	c.Assert(err, check.IsNil)
+	exporter := newCheckpointExporter("")
+	exporter.db = db
+	defer exporter.close()
+	ctx := context.Background()
+
+	mock.ExpectExec("CREATE DATABASE IF NOT EXISTS `tidb_cdc`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `tidb_cdc`.`changefeed_checkpoint`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("REPLACE INTO `tidb_cdc`.`changefeed_checkpoint` "+
+		"(changefeed_id, checkpoint_ts, resolved_ts, checkpoint_time) VALUES (?,?,?,?),(?,?,?,?)")).
+		WithArgs("cf-1", uint64(417318403368288260), uint64(417318403368288261), "2020-06-12 06:29:32.224",
+			"cf-2", uint64(0), uint64(0), "1970-01-01 00:00:00.000").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `tidb_cdc`.`changefeed_checkpoint` WHERE changefeed_id NOT IN (?,?)")).
+		WithArgs("cf-1", "cf-2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err = exporter.export(ctx, map[model.ChangeFeedID]*model.ChangeFeedStatus{
+		"cf-2": {},
+		"cf-1": {CheckpointTs: 417318403368288260, ResolvedTs: 417318403368288261},
+	})
+	c.Assert(err, check.IsNil)
+
+	// the table is created only once
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `tidb_cdc`.`changefeed_checkpoint`")).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	c.Assert(exporter.export(ctx, nil), check.IsNil)
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+	mock.ExpectClose()
+}
@@ -183,18 +183,22 @@ func decodeMetaKey(ek []byte) (meta, error) {
 	return nil, cerror.ErrUnknownMetaType.GenWithStackByArgs(rawTp)
 }
 
-// decodeRow decodes a byte slice into datums with a existing row map.
-func decodeRow(b []byte, recordID kv.Handle, tableInfo *model.TableInfo, tz *time.Location) (map[int64]types.Datum, error) {
+// decodeRow decodes the row value to datums. The decoder and the datums are
+// reused if they are not nil, the decoder must be created for the table info.
+func decodeRow(b []byte, recordID kv.Handle, tableInfo *model.TableInfo, tz *time.Location,
+	decoder *rowcodec.DatumMapDecoder, datums map[int64]types.Datum) (map[int64]types.Datum, error) {
 	if len(b) == 0 {
 		return map[int64]types.Datum{}, nil
 	}
 	handleColIDs, handleColFt, reqCols := tableInfo.GetRowColInfos()
-	var datums map[int64]types.Datum
 	var err error
 	if rowcodec.IsNewFormat(b) {
-		datums, err = decodeRowV2(b, reqCols, tz)
+		if decoder == nil {
+			decoder = rowcodec.NewDatumMapDecoder(reqCols, tz)
+		}
+		datums, err = decodeRowV2(b, decoder, datums)
 	} else {
-		datums, err = decodeRowV1(b, tableInfo, tz)
+		datums, err = decodeRowV1(b, tableInfo, tz, datums)
 	}
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -204,8 +208,10 @@ func decodeRow(b []byte, recordID kv.Handle, tableInfo *model.TableInfo, tz *tim
 
 // decodeRowV1 decodes value data using old encoding format.
 // Row layout: colID1, value1, colID2, value2, .....
-func decodeRowV1(b []byte, tableInfo *model.TableInfo, tz *time.Location) (map[int64]types.Datum, error) {
-	row := make(map[int64]types.Datum)
+func decodeRowV1(b []byte, tableInfo *model.TableInfo, tz *time.Location, row map[int64]types.Datum) (map[int64]types.Datum, error) {
+	if row == nil {
+		row = make(map[int64]types.Datum)
+	}
 	if len(b) == 1 && b[0] == codec.NilFlag {
 		b = b[1:]
 	}
@@ -252,9 +258,8 @@ func decodeRowV1(b []byte, tableInfo *model.TableInfo, tz *time.Location) (map[i
 // decodeRowV2 decodes value data using new encoding format.
 // Ref: https://github.com/pingcap/tidb/pull/12634
 //      https://github.com/pingcap/tidb/blob/master/docs/design/2018-07-19-row-format.md
-func decodeRowV2(data []byte, columns []rowcodec.ColInfo, tz *time.Location) (map[int64]types.Datum, error) {
-	decoder := rowcodec.NewDatumMapDecoder(columns, tz)
-	datums, err := decoder.DecodeToDatumMap(data, nil)
+func decodeRowV2(data []byte, decoder *rowcodec.DatumMapDecoder, row map[int64]types.Datum) (map[int64]types.Datum, error) {
+	datums, err := decoder.DecodeToDatumMap(data, row)
 	if err != nil {
 		return datums, cerror.WrapError(cerror.ErrDecodeRowToDatum, err)
 	}
@@ -33,10 +33,35 @@ var (
 			Help:      "Bucketed histogram of processing time (s) of unmarshal and mount in mounter.",
 			Buckets:   prometheus.ExponentialBuckets(0.000001, 10, 10),
 		}, []string{"capture", "changefeed"})
+	rowSizeHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ticdc",
+			Subsystem: "mounter",
+			Name:      "row_size_bytes",
+			Help:      "Bucketed histogram of the size (bytes) of the mounted rows.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 10),
+		}, []string{"capture", "changefeed"})
+	largeRowCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ticdc",
+			Subsystem: "mounter",
+			Name:      "large_row_count",
+			Help:      "counter for the rows larger than the large row threshold",
+		}, []string{"capture", "changefeed", "schema", "table"})
+	skippedEventCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ticdc",
+			Subsystem: "mounter",
+			Name:      "skipped_event_count",
+			Help:      "counter for the row events skipped by the skip policy",
+		}, []string{"capture", "changefeed"})
 )
 
 // InitMetrics registers all metrics in this file
 func InitMetrics(registry *prometheus.Registry) {
 	registry.MustRegister(mounterInputChanSizeGauge)
 	registry.MustRegister(mountDuration)
+	registry.MustRegister(rowSizeHistogram)
+	registry.MustRegister(largeRowCounter)
+	registry.MustRegister(skippedEventCounter)
 }
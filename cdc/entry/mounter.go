@@ -19,6 +19,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"hash/crc32"
 	"math"
 	"math/rand"
 	"time"
@@ -29,17 +30,31 @@ import (
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/ticdc/cdc/model"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/usage"
 	"github.com/pingcap/ticdc/pkg/util"
 	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/table"
 	"github.com/pingcap/tidb/tablecodec"
 	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/rowcodec"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 )
 
 const (
 	defaultOutputChanSize = 128000
+	// defaultMounterBatchSize is the max number of events mounted by a worker
+	// at once
+	defaultMounterBatchSize = 128
+	// defaultColumnChunkSize is the number of columns allocated at once
+	defaultColumnChunkSize = 4096
+	// maxCachedRowDecoders is the max number of row decoders cached by a worker
+	maxCachedRowDecoders = 64
+	// defaultLargeRowThreshold is the default size of the large rows
+	defaultLargeRowThreshold = 1024 * 1024 // 1MB
+	// largeRowLogInterval is the min interval of logging the large rows of a
+	// table by a worker
+	largeRowLogInterval = 10 * time.Second
 )
 
 type baseKVEntry struct {
@@ -136,13 +151,23 @@ type mounterImpl struct {
 	tz               *time.Location
 	workerNum        int
 	enableOldValue   bool
+	// largeRowThreshold is the size of the rows reported as large rows
+	largeRowThreshold int64
+	// skipper skips the events can't be mounted, nil means no event is skipped
+	skipper *EventSkipper
 }
 
-// NewMounter creates a mounter
-func NewMounter(schemaStorage *SchemaStorage, workerNum int, enableOldValue bool) Mounter {
+// NewMounter creates a mounter, the skipper is nil if the changefeed fails on
+// the events can't be mounted.
+func NewMounter(
+	schemaStorage *SchemaStorage, workerNum int, largeRowThreshold int64, enableOldValue bool, skipper *EventSkipper,
+) Mounter {
 	if workerNum <= 0 {
 		workerNum = defaultMounterWorkerNum
 	}
+	if largeRowThreshold <= 0 {
+		largeRowThreshold = defaultLargeRowThreshold
+	}
 	chs := make([]chan *model.PolymorphicEvent, workerNum)
 	for i := 0; i < workerNum; i++ {
 		chs[i] = make(chan *model.PolymorphicEvent, defaultOutputChanSize)
@@ -152,6 +177,9 @@ func NewMounter(schemaStorage *SchemaStorage, workerNum int, enableOldValue bool
 		rawRowChangedChs: chs,
 		workerNum:        workerNum,
 		enableOldValue:   enableOldValue,
+
+		largeRowThreshold: largeRowThreshold,
+		skipper:           skipper,
 	}
 }
 
@@ -166,9 +194,9 @@ func (m *mounterImpl) Run(ctx context.Context) error {
 	})
 	for i := 0; i < m.workerNum; i++ {
 		index := i
-		errg.Go(func() error {
+		errg.Go(util.GuardPanic(ctx, "mounter-worker", func() error {
 			return m.codecWorker(ctx, index)
-		})
+		}))
 	}
 	return errg.Wait()
 }
@@ -177,29 +205,190 @@ func (m *mounterImpl) codecWorker(ctx context.Context, index int) error {
 	captureAddr := util.CaptureAddrFromCtx(ctx)
 	changefeedID := util.ChangefeedIDFromCtx(ctx)
 	metricMountDuration := mountDuration.WithLabelValues(captureAddr, changefeedID)
+	metricRowSize := rowSizeHistogram.WithLabelValues(captureAddr, changefeedID)
+	cfUsage := usage.Of(changefeedID)
 
+	mctx := newMountContext()
+	batch := make([]*model.PolymorphicEvent, 0, defaultMounterBatchSize)
 	for {
-		var pEvent *model.PolymorphicEvent
+		batch = batch[:0]
+		// the events are left in the channel if the changefeed uses more
+		// than its CPU shares
+		if err := cfUsage.WaitCPU(ctx); err != nil {
+			return errors.Trace(err)
+		}
 		select {
 		case <-ctx.Done():
 			return errors.Trace(ctx.Err())
-		case pEvent = <-m.rawRowChangedChs[index]:
+		case pEvent := <-m.rawRowChangedChs[index]:
+			batch = append(batch, pEvent)
 		}
-		if pEvent.RawKV.OpType == model.OpTypeResolved {
-			pEvent.PrepareFinished()
-			continue
+		// take the events already in the channel without waiting for more
+	collect:
+		for len(batch) < cap(batch) {
+			select {
+			case pEvent := <-m.rawRowChangedChs[index]:
+				batch = append(batch, pEvent)
+			default:
+				break collect
+			}
 		}
+
 		startTime := time.Now()
-		rowEvent, err := m.unmarshalAndMountRowChanged(ctx, pEvent.RawKV)
-		if err != nil {
-			return errors.Trace(err)
+		mounted := 0
+		for _, pEvent := range batch {
+			if pEvent.RawKV.OpType == model.OpTypeResolved {
+				pEvent.PrepareFinished()
+				continue
+			}
+			rowEvent, err := m.unmarshalAndMountRowChanged(ctx, mctx, pEvent.RawKV)
+			if err != nil {
+				rowEvent, err = m.skipper.retry(ctx, pEvent.RawKV, err, func() (*model.RowChangedEvent, error) {
+					return m.unmarshalAndMountRowChanged(ctx, mctx, pEvent.RawKV)
+				})
+			}
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if rowEvent != nil {
+				metricRowSize.Observe(float64(rowEvent.ApproximateSize))
+				if rowEvent.ApproximateSize >= m.largeRowThreshold {
+					m.reportLargeRow(ctx, mctx, rowEvent, pEvent.RawKV.Key)
+				}
+			}
+			pEvent.Row = rowEvent
+			pEvent.RawKV.Key = nil
+			pEvent.RawKV.Value = nil
+			pEvent.PrepareFinished()
+			mounted++
+		}
+		// the worker is CPU bound, the busy time is accounted as the CPU time
+		cfUsage.AddCPUTime(time.Since(startTime))
+		if mounted > 0 {
+			duration := time.Since(startTime).Seconds() / float64(mounted)
+			for i := 0; i < mounted; i++ {
+				metricMountDuration.Observe(duration)
+			}
+		}
+	}
+}
+
+// mountContext holds the states reused by a codec worker across the events,
+// it must not be shared by goroutines.
+type mountContext struct {
+	// decoders caches the row decoders of the recently used table infos
+	decoders map[*model.TableInfo]*rowcodec.DatumMapDecoder
+	// row and preRow are the datums of the row being mounted, they are
+	// cleared before decoding the next row
+	row     map[int64]types.Datum
+	preRow  map[int64]types.Datum
+	columns columnAllocator
+	// largeRowLogged is the last time the large rows of a table are logged
+	largeRowLogged map[model.TableID]time.Time
+}
+
+func newMountContext() *mountContext {
+	return &mountContext{
+		decoders: make(map[*model.TableInfo]*rowcodec.DatumMapDecoder),
+		row:      make(map[int64]types.Datum),
+		preRow:   make(map[int64]types.Datum),
+
+		largeRowLogged: make(map[model.TableID]time.Time),
+	}
+}
+
+// reportLargeRow counts the large row by its table and logs it with the hash
+// of its key, the large rows often exceed the message size limit of the MQ
+// sinks. The large rows of a table are logged at most once an interval.
+func (m *mounterImpl) reportLargeRow(ctx context.Context, mctx *mountContext, row *model.RowChangedEvent, key []byte) {
+	largeRowCounter.WithLabelValues(util.CaptureAddrFromCtx(ctx), util.ChangefeedIDFromCtx(ctx),
+		row.Table.Schema, row.Table.Table).Inc()
+	now := time.Now()
+	if now.Sub(mctx.largeRowLogged[row.Table.TableID]) < largeRowLogInterval {
+		return
+	}
+	mctx.largeRowLogged[row.Table.TableID] = now
+	columns := len(row.Columns)
+	if len(row.PreColumns) > columns {
+		columns = len(row.PreColumns)
+	}
+	log.Warn("large row is found",
+		util.ZapFieldChangefeed(ctx),
+		zap.String("table", row.Table.String()),
+		zap.Uint32("key-hash", crc32.ChecksumIEEE(key)),
+		zap.Int64("size", row.ApproximateSize),
+		zap.Int64("threshold", m.largeRowThreshold),
+		zap.Int("columns", columns),
+		zap.Uint64("commit-ts", row.CommitTs))
+}
+
+// rowDecoder returns the cached row decoder of the table info.
+func (c *mountContext) rowDecoder(tableInfo *model.TableInfo, tz *time.Location) *rowcodec.DatumMapDecoder {
+	if c == nil {
+		return nil
+	}
+	decoder, ok := c.decoders[tableInfo]
+	if !ok {
+		if len(c.decoders) >= maxCachedRowDecoders {
+			c.decoders = make(map[*model.TableInfo]*rowcodec.DatumMapDecoder)
 		}
-		pEvent.Row = rowEvent
-		pEvent.RawKV.Key = nil
-		pEvent.RawKV.Value = nil
-		pEvent.PrepareFinished()
-		metricMountDuration.Observe(time.Since(startTime).Seconds())
+		_, _, reqCols := tableInfo.GetRowColInfos()
+		decoder = rowcodec.NewDatumMapDecoder(reqCols, tz)
+		c.decoders[tableInfo] = decoder
+	}
+	return decoder
+}
+
+// datums returns the cleared datums map of the row or the previous row.
+func (c *mountContext) datums(pre bool) map[int64]types.Datum {
+	if c == nil {
+		return nil
+	}
+	datums := c.row
+	if pre {
+		datums = c.preRow
+	}
+	for id := range datums {
+		delete(datums, id)
+	}
+	return datums
+}
+
+func (c *mountContext) allocator() *columnAllocator {
+	if c == nil {
+		return nil
+	}
+	return &c.columns
+}
+
+// columnAllocator allocates the columns of rows from pre-allocated chunks
+// instead of allocating them one by one. The allocated columns are owned by
+// the rows and never reused.
+type columnAllocator struct {
+	columns  []model.Column
+	pointers []*model.Column
+}
+
+// alloc returns n nil column pointers and the storage of n columns.
+func (a *columnAllocator) alloc(n int) ([]*model.Column, []model.Column) {
+	if a == nil {
+		return make([]*model.Column, n), make([]model.Column, n)
 	}
+	if len(a.columns) < n {
+		size := maxInt(n, defaultColumnChunkSize)
+		a.columns = make([]model.Column, size)
+		a.pointers = make([]*model.Column, size)
+	}
+	pointers, columns := a.pointers[:n:n], a.columns[:n:n]
+	a.pointers, a.columns = a.pointers[n:], a.columns[n:]
+	return pointers, columns
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
 }
 
 func (m *mounterImpl) Input() chan<- *model.PolymorphicEvent {
@@ -225,7 +414,7 @@ func (m *mounterImpl) collectMetrics(ctx context.Context) {
 	}
 }
 
-func (m *mounterImpl) unmarshalAndMountRowChanged(ctx context.Context, raw *model.RawKVEntry) (*model.RowChangedEvent, error) {
+func (m *mounterImpl) unmarshalAndMountRowChanged(ctx context.Context, mctx *mountContext, raw *model.RawKVEntry) (*model.RowChangedEvent, error) {
 	if !bytes.HasPrefix(raw.Key, tablePrefix) {
 		return nil, nil
 	}
@@ -258,14 +447,14 @@ func (m *mounterImpl) unmarshalAndMountRowChanged(ctx context.Context, raw *mode
 		}
 		switch {
 		case bytes.HasPrefix(key, recordPrefix):
-			rowKV, err := m.unmarshalRowKVEntry(tableInfo, raw.Key, raw.Value, raw.OldValue, baseInfo)
+			rowKV, err := m.unmarshalRowKVEntry(mctx, tableInfo, raw.Key, raw.Value, raw.OldValue, baseInfo)
 			if err != nil {
 				return nil, errors.Trace(err)
 			}
 			if rowKV == nil {
 				return nil, nil
 			}
-			return m.mountRowKVEntry(tableInfo, rowKV, raw.ApproximateSize())
+			return m.mountRowKVEntry(mctx, tableInfo, rowKV, raw.ApproximateSize())
 		case bytes.HasPrefix(key, indexPrefix):
 			indexKV, err := m.unmarshalIndexKVEntry(key, raw.Value, raw.OldValue, baseInfo)
 			if err != nil {
@@ -274,7 +463,7 @@ func (m *mounterImpl) unmarshalAndMountRowChanged(ctx context.Context, raw *mode
 			if indexKV == nil {
 				return nil, nil
 			}
-			return m.mountIndexKVEntry(tableInfo, indexKV, raw.ApproximateSize())
+			return m.mountIndexKVEntry(mctx, tableInfo, indexKV, raw.ApproximateSize())
 		}
 		return nil, nil
 	}()
@@ -285,34 +474,35 @@ func (m *mounterImpl) unmarshalAndMountRowChanged(ctx context.Context, raw *mode
 	return row, err
 }
 
-func (m *mounterImpl) unmarshalRowKVEntry(tableInfo *model.TableInfo, rawKey []byte, rawValue []byte, rawOldValue []byte, base baseKVEntry) (*rowKVEntry, error) {
+func (m *mounterImpl) unmarshalRowKVEntry(mctx *mountContext, tableInfo *model.TableInfo, rawKey []byte, rawValue []byte, rawOldValue []byte, base baseKVEntry) (*rowKVEntry, error) {
 	recordID, err := tablecodec.DecodeRowKey(rawKey)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	decodeRow := func(rawColValue []byte) (map[int64]types.Datum, bool, error) {
+	decoder := mctx.rowDecoder(tableInfo, m.tz)
+	decodeRow := func(rawColValue []byte, pre bool) (map[int64]types.Datum, bool, error) {
 		if len(rawColValue) == 0 {
 			return nil, false, nil
 		}
-		row, err := decodeRow(rawColValue, recordID, tableInfo, m.tz)
+		row, err := decodeRow(rawColValue, recordID, tableInfo, m.tz, decoder, mctx.datums(pre))
 		if err != nil {
 			return nil, false, errors.Trace(err)
 		}
 		return row, true, nil
 	}
 
-	row, rowExist, err := decodeRow(rawValue)
+	row, rowExist, err := decodeRow(rawValue, false)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	preRow, preRowExist, err := decodeRow(rawOldValue)
+	preRow, preRowExist, err := decodeRow(rawOldValue, true)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
 	if base.Delete && !m.enableOldValue && (tableInfo.PKIsHandle || tableInfo.IsCommonHandle) {
 		handleColIDs, fieldTps, _ := tableInfo.GetRowColInfos()
-		preRow, err = tablecodec.DecodeHandleToDatumMap(recordID, handleColIDs, fieldTps, m.tz, nil)
+		preRow, err = tablecodec.DecodeHandleToDatumMap(recordID, handleColIDs, fieldTps, m.tz, mctx.datums(true))
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -405,8 +595,8 @@ func UnmarshalDDL(raw *model.RawKVEntry) (*timodel.Job, error) {
 	return job, nil
 }
 
-func datum2Column(tableInfo *model.TableInfo, datums map[int64]types.Datum, fillWithDefaultValue bool) ([]*model.Column, error) {
-	cols := make([]*model.Column, len(tableInfo.RowColumnsOffset))
+func datum2Column(tableInfo *model.TableInfo, datums map[int64]types.Datum, fillWithDefaultValue bool, alloc *columnAllocator) ([]*model.Column, error) {
+	cols, storage := alloc.alloc(len(tableInfo.RowColumnsOffset))
 	for _, colInfo := range tableInfo.Columns {
 		if !model.IsColCDCVisible(colInfo) {
 			continue
@@ -429,17 +619,21 @@ func datum2Column(tableInfo *model.TableInfo, datums map[int64]types.Datum, fill
 		} else {
 			continue
 		}
-		cols[tableInfo.RowColumnsOffset[colInfo.ID]] = &model.Column{
+		offset := tableInfo.RowColumnsOffset[colInfo.ID]
+		col := &storage[offset]
+		*col = model.Column{
 			Name:  colName,
 			Type:  colInfo.Tp,
 			Value: colValue,
 			Flag:  tableInfo.ColumnsFlag[colInfo.ID],
 		}
+		setColumnCharset(col, colInfo)
+		cols[offset] = col
 	}
 	return cols, nil
 }
 
-func (m *mounterImpl) mountRowKVEntry(tableInfo *model.TableInfo, row *rowKVEntry, dataSize int64) (*model.RowChangedEvent, error) {
+func (m *mounterImpl) mountRowKVEntry(mctx *mountContext, tableInfo *model.TableInfo, row *rowKVEntry, dataSize int64) (*model.RowChangedEvent, error) {
 	// if m.enableOldValue == true, go into this function
 	// if m.enableNewValue == false and row.Delete == false, go into this function
 	// if m.enableNewValue == false and row.Delete == true and use explict row id, go into this function
@@ -455,7 +649,7 @@ func (m *mounterImpl) mountRowKVEntry(tableInfo *model.TableInfo, row *rowKVEntr
 	if row.PreRowExist {
 		// FIXME(leoppro): using pre table info to mounter pre column datum
 		// the pre column and current column in one event may using different table info
-		preCols, err = datum2Column(tableInfo, row.PreRow, m.enableOldValue)
+		preCols, err = datum2Column(tableInfo, row.PreRow, m.enableOldValue, mctx.allocator())
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -463,7 +657,7 @@ func (m *mounterImpl) mountRowKVEntry(tableInfo *model.TableInfo, row *rowKVEntr
 
 	var cols []*model.Column
 	if row.RowExist {
-		cols, err = datum2Column(tableInfo, row.Row, true)
+		cols, err = datum2Column(tableInfo, row.Row, true, mctx.allocator())
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -493,7 +687,7 @@ func (m *mounterImpl) mountRowKVEntry(tableInfo *model.TableInfo, row *rowKVEntr
 	}, nil
 }
 
-func (m *mounterImpl) mountIndexKVEntry(tableInfo *model.TableInfo, idx *indexKVEntry, dataSize int64) (*model.RowChangedEvent, error) {
+func (m *mounterImpl) mountIndexKVEntry(mctx *mountContext, tableInfo *model.TableInfo, idx *indexKVEntry, dataSize int64) (*model.RowChangedEvent, error) {
 	// skip set index KV
 	if !idx.Delete || m.enableOldValue {
 		return nil, nil
@@ -518,7 +712,7 @@ func (m *mounterImpl) mountIndexKVEntry(tableInfo *model.TableInfo, idx *indexKV
 		return nil, errors.Trace(err)
 	}
 
-	preCols := make([]*model.Column, len(tableInfo.RowColumnsOffset))
+	preCols, storage := mctx.allocator().alloc(len(tableInfo.RowColumnsOffset))
 	for i, idxCol := range indexInfo.Columns {
 		colInfo := tableInfo.Columns[idxCol.Offset]
 		value, warn, err := formatColVal(idx.IndexValue[i], colInfo.Tp)
@@ -528,12 +722,15 @@ func (m *mounterImpl) mountIndexKVEntry(tableInfo *model.TableInfo, idx *indexKV
 		if warn != "" {
 			log.Warn(warn, zap.String("table", tableInfo.TableName.String()), zap.String("column", colInfo.Name.String()))
 		}
-		preCols[tableInfo.RowColumnsOffset[colInfo.ID]] = &model.Column{
+		offset := tableInfo.RowColumnsOffset[colInfo.ID]
+		storage[offset] = model.Column{
 			Name:  colInfo.Name.O,
 			Type:  colInfo.Tp,
 			Value: value,
 			Flag:  tableInfo.ColumnsFlag[colInfo.ID],
 		}
+		setColumnCharset(&storage[offset], colInfo)
+		preCols[offset] = &storage[offset]
 	}
 	var intRowID int64
 	if idx.RecordID != nil && idx.RecordID.IsInt() {
@@ -557,6 +754,16 @@ func (m *mounterImpl) mountIndexKVEntry(tableInfo *model.TableInfo, idx *indexKV
 
 var emptyBytes = make([]byte, 0)
 
+// setColumnCharset sets the charset and collation of a string column, so
+// the binary values can be told from the text ones by the sinks.
+func setColumnCharset(col *model.Column, colInfo *timodel.ColumnInfo) {
+	if !types.IsString(colInfo.Tp) {
+		return
+	}
+	col.Charset = colInfo.Charset
+	col.Collation = colInfo.Collate
+}
+
 func formatColVal(datum types.Datum, tp byte) (value interface{}, warn string, err error) {
 	if datum.IsNull() {
 		return nil, "", nil
@@ -0,0 +1,132 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entry
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	tidbkv "github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/rowcodec"
+)
+
+// newWideTable returns a table with an int handle and the given number of
+// int, varchar and double columns.
+func newWideTable(columns int) *model.TableInfo {
+	tableInfo := &timodel.TableInfo{
+		ID:         1,
+		Name:       timodel.NewCIStr("t"),
+		PKIsHandle: true,
+		State:      timodel.StatePublic,
+	}
+	pk := &timodel.ColumnInfo{
+		ID:        1,
+		Name:      timodel.NewCIStr("id"),
+		FieldType: *types.NewFieldType(mysql.TypeLonglong),
+		State:     timodel.StatePublic,
+	}
+	pk.Flag = mysql.PriKeyFlag | mysql.NotNullFlag
+	tableInfo.Columns = append(tableInfo.Columns, pk)
+	tps := []byte{mysql.TypeLonglong, mysql.TypeVarchar, mysql.TypeDouble}
+	for i := 0; i < columns; i++ {
+		tableInfo.Columns = append(tableInfo.Columns, &timodel.ColumnInfo{
+			ID:        int64(i + 2),
+			Name:      timodel.NewCIStr(fmt.Sprintf("c%d", i)),
+			Offset:    i + 1,
+			FieldType: *types.NewFieldType(tps[i%len(tps)]),
+			State:     timodel.StatePublic,
+		})
+	}
+	return model.WrapTableInfo(1, "test", 1, tableInfo)
+}
+
+// newWideRow encodes a row of the table in the new row format.
+func newWideRow(b *testing.B, tableInfo *model.TableInfo, handle int64) *model.RawKVEntry {
+	var colIDs []int64
+	var datums []types.Datum
+	for _, col := range tableInfo.Columns[1:] {
+		colIDs = append(colIDs, col.ID)
+		switch col.Tp {
+		case mysql.TypeLonglong:
+			datums = append(datums, types.NewIntDatum(handle*col.ID))
+		case mysql.TypeVarchar:
+			datums = append(datums, types.NewStringDatum(fmt.Sprintf("value-%d-%d", handle, col.ID)))
+		case mysql.TypeDouble:
+			datums = append(datums, types.NewFloat64Datum(float64(handle)/float64(col.ID)))
+		}
+	}
+	encoder := &rowcodec.Encoder{}
+	value, err := encoder.Encode(&stmtctx.StatementContext{TimeZone: time.UTC}, colIDs, datums, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return &model.RawKVEntry{
+		OpType:  model.OpTypePut,
+		Key:     tablecodec.EncodeRowKeyWithHandle(tableInfo.ID, tidbkv.IntHandle(handle)),
+		Value:   value,
+		StartTs: uint64(handle),
+		CRTs:    uint64(handle) + 1,
+	}
+}
+
+func benchmarkMountRow(b *testing.B, columns int, mctx *mountContext) {
+	tableInfo := newWideTable(columns)
+	rows := make([]*model.RawKVEntry, 1024)
+	for i := range rows {
+		rows[i] = newWideRow(b, tableInfo, int64(i+1))
+	}
+	m := &mounterImpl{tz: time.UTC, enableOldValue: true}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		raw := rows[i%len(rows)]
+		base := baseKVEntry{
+			StartTs:         raw.StartTs,
+			CRTs:            raw.CRTs,
+			PhysicalTableID: tableInfo.ID,
+		}
+		rowKV, err := m.unmarshalRowKVEntry(mctx, tableInfo, raw.Key, raw.Value, raw.OldValue, base)
+		if err != nil {
+			b.Fatal(err)
+		}
+		row, err := m.mountRowKVEntry(mctx, tableInfo, rowKV, raw.ApproximateSize())
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(row.Columns) != columns+1 {
+			b.Fatalf("unexpected columns %d", len(row.Columns))
+		}
+	}
+}
+
+func BenchmarkMountRow(b *testing.B) {
+	for _, columns := range []int{8, 64, 256} {
+		columns := columns
+		b.Run(fmt.Sprintf("%d-columns", columns), func(b *testing.B) {
+			benchmarkMountRow(b, columns, newMountContext())
+		})
+		// mount without reusing the decoder, datums and columns
+		b.Run(fmt.Sprintf("%d-columns-no-reuse", columns), func(b *testing.B) {
+			benchmarkMountRow(b, columns, nil)
+		})
+	}
+}
@@ -23,12 +23,14 @@ import (
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/pkg/regionspan"
+	"github.com/pingcap/ticdc/pkg/util"
 	"github.com/pingcap/ticdc/pkg/util/testleak"
 	ticonfig "github.com/pingcap/tidb/config"
 	tidbkv "github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/session"
 	"github.com/pingcap/tidb/store/mockstore"
 	"github.com/pingcap/tidb/util/testkit"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"go.uber.org/zap"
 )
 
@@ -257,15 +259,16 @@ func testMounterDisableOldValue(c *check.C, tc struct {
 	ver, err := store.CurrentVersion()
 	c.Assert(err, check.IsNil)
 	scheamStorage.AdvanceResolvedTs(ver.Ver)
-	mounter := NewMounter(scheamStorage, 1, false).(*mounterImpl)
+	mounter := NewMounter(scheamStorage, 1, 0, false, nil).(*mounterImpl)
 	mounter.tz = time.Local
 	ctx := context.Background()
+	mctx := newMountContext()
 
 	mountAndCheckRowInTable := func(tableID int64, f func(key []byte, value []byte) *model.RawKVEntry) int {
 		var rows int
 		walkTableSpanInStore(c, store, tableID, func(key []byte, value []byte) {
 			rawKV := f(key, value)
-			row, err := mounter.unmarshalAndMountRowChanged(ctx, rawKV)
+			row, err := mounter.unmarshalAndMountRowChanged(ctx, mctx, rawKV)
 			c.Assert(err, check.IsNil)
 			if row == nil {
 				return
@@ -394,3 +397,24 @@ func walkTableSpanInStore(c *check.C, store tidbkv.Storage, tableID int64, f fun
 		c.Assert(err, check.IsNil)
 	}
 }
+
+func (s *mountTxnsSuite) TestReportLargeRow(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := util.PutChangefeedIDInCtx(context.Background(), "test-large-row")
+	mounter := NewMounter(nil, 1, 0, false, nil).(*mounterImpl)
+	c.Assert(mounter.largeRowThreshold, check.Equals, int64(defaultLargeRowThreshold))
+	mctx := newMountContext()
+	row := &model.RowChangedEvent{
+		CommitTs:        1,
+		Table:           &model.TableName{Schema: "test", Table: "t", TableID: 1},
+		Columns:         []*model.Column{{Name: "a"}},
+		ApproximateSize: 2 * defaultLargeRowThreshold,
+	}
+	mounter.reportLargeRow(ctx, mctx, row, []byte("key"))
+	logged := mctx.largeRowLogged[1]
+	c.Assert(logged.IsZero(), check.IsFalse)
+	// the large rows of the table are counted but not logged again in the interval
+	mounter.reportLargeRow(ctx, mctx, row, []byte("key"))
+	c.Assert(mctx.largeRowLogged[1], check.Equals, logged)
+	c.Assert(testutil.ToFloat64(largeRowCounter.WithLabelValues("", "test-large-row", "test", "t")), check.Equals, float64(2))
+}
@@ -20,7 +20,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/cenkalti/backoff"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	timodel "github.com/pingcap/parser/model"
@@ -478,6 +477,54 @@ func (s *schemaSnapshot) updatePartition(tbl *model.TableInfo) error {
 	return nil
 }
 
+// exchangePartition swaps the ID of a partition and the ID of a
+// non-partitioned table, the data is not moved by EXCHANGE PARTITION.
+func (s *schemaSnapshot) exchangePartition(job *timodel.Job) error {
+	defID, ptSchemaID, _, err := ExchangePartitionArgs(job)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	nt, ok := s.tables[job.TableID]
+	if !ok {
+		return cerror.ErrSnapshotTableNotFound.GenWithStackByArgs(job.TableID)
+	}
+	ptSchema, ok := s.schemas[ptSchemaID]
+	if !ok {
+		return cerror.ErrSnapshotSchemaNotFound.GenWithStackByArgs(ptSchemaID)
+	}
+	version := job.BinlogInfo.FinishedTS
+	pt := model.WrapTableInfo(ptSchemaID, ptSchema.Name.O, version, job.BinlogInfo.TableInfo)
+	ntInfo := nt.TableInfo.Clone()
+	ntInfo.ID = defID
+	newNt := model.WrapTableInfo(nt.SchemaID, nt.TableName.Schema, version, ntInfo)
+
+	if err := s.dropTable(nt.ID); err != nil {
+		return errors.Trace(err)
+	}
+	if err := s.updatePartition(pt); err != nil {
+		return errors.Trace(err)
+	}
+	// the exchanged partition is not truncated, it becomes the table
+	delete(s.truncateTableID, defID)
+	if err := s.createTable(newNt); err != nil {
+		return errors.Trace(err)
+	}
+	log.Debug("exchange partition success", zap.Stringer("partitionedTable", pt.TableName),
+		zap.Stringer("table", newNt.TableName), zap.Int64("partitionID", nt.ID), zap.Int64("tableID", defID))
+	return nil
+}
+
+// ExchangePartitionArgs returns the old ID of the exchanged partition, the
+// schema ID and the ID of the partitioned table of an EXCHANGE PARTITION job.
+// The old ID of the partition is the new ID of the non-partitioned table, and
+// vice versa.
+func ExchangePartitionArgs(job *timodel.Job) (defID, ptSchemaID, ptID int64, err error) {
+	if err := job.DecodeArgs(&defID, &ptSchemaID, &ptID); err != nil {
+		return 0, 0, 0, errors.Trace(err)
+	}
+	return defID, ptSchemaID, ptID, nil
+}
+
 func (s *schemaSnapshot) createTable(table *model.TableInfo) error {
 	schema, ok := s.schemas[table.SchemaID]
 	if !ok {
@@ -603,6 +650,11 @@ func (s *schemaSnapshot) handleDDL(job *timodel.Job) error {
 		if err != nil {
 			return errors.Trace(err)
 		}
+	case timodel.ActionExchangeTablePartition:
+		err := s.exchangePartition(job)
+		if err != nil {
+			return errors.Trace(err)
+		}
 	default:
 		binlogInfo := job.BinlogInfo
 		if binlogInfo == nil {
@@ -692,31 +744,20 @@ func (s *SchemaStorage) GetSnapshot(ctx context.Context, ts uint64) (*schemaSnap
 
 	// The infinite retry here is a temporary solution to the `ErrSchemaStorageUnresolved` caused by
 	// DDL puller lagging too much.
-	err := retry.RunWithInfiniteRetry(10*time.Millisecond, func() error {
-		select {
-		case <-ctx.Done():
-			return errors.Trace(ctx.Err())
-		default:
-		}
+	startTime := time.Now()
+	err := retry.Do(ctx, func() error {
 		var err error
 		snap, err = s.getSnapshot(ts)
-		if cerror.ErrSchemaStorageUnresolved.NotEqual(err) {
-			return backoff.Permanent(err)
-		}
-
-		return err
-	}, func(elapsed time.Duration) {
-		if elapsed >= 5*time.Minute {
+		if cerror.ErrSchemaStorageUnresolved.Equal(err) && time.Since(startTime) >= 5*time.Minute {
 			log.Warn("GetSnapshot is taking too long, DDL puller stuck?", zap.Uint64("ts", ts))
 		}
-	})
-
-	switch e := err.(type) {
-	case *backoff.PermanentError:
-		return nil, e.Err
-	default:
-		return snap, err
+		return err
+	}, retry.WithBackoffBaseDelay(10*time.Millisecond), retry.WithBackoffMaxDelay(time.Minute), retry.WithInfiniteTries(),
+		retry.WithIsRetryableErr(cerror.ErrSchemaStorageUnresolved.Equal))
+	if err != nil {
+		return nil, err
 	}
+	return snap, nil
 }
 
 // GetLastSnapshot returns the last snapshot
@@ -726,17 +767,22 @@ func (s *SchemaStorage) GetLastSnapshot() *schemaSnapshot {
 	return s.snaps[len(s.snaps)-1]
 }
 
-// HandleDDLJob creates a new snapshot in storage and handles the ddl job
+// HandleDDLJob creates a new snapshot in storage and handles the ddl job.
+// The snapshot is built without blocking the readers of the storage, so it
+// must not be called concurrently.
 func (s *SchemaStorage) HandleDDLJob(job *timodel.Job) error {
 	if s.skipJob(job) {
 		s.AdvanceResolvedTs(job.BinlogInfo.FinishedTS)
 		return nil
 	}
-	s.snapsMu.Lock()
-	defer s.snapsMu.Unlock()
-	var snap *schemaSnapshot
+	s.snapsMu.RLock()
+	var lastSnap *schemaSnapshot
 	if len(s.snaps) > 0 {
-		lastSnap := s.snaps[len(s.snaps)-1]
+		lastSnap = s.snaps[len(s.snaps)-1]
+	}
+	s.snapsMu.RUnlock()
+	var snap *schemaSnapshot
+	if lastSnap != nil {
 		if job.BinlogInfo.FinishedTS <= lastSnap.currentTs {
 			log.Debug("ignore foregone DDL job", zap.Reflect("job", job))
 			return nil
@@ -748,7 +794,9 @@ func (s *SchemaStorage) HandleDDLJob(job *timodel.Job) error {
 	if err := snap.handleDDL(job); err != nil {
 		return errors.Trace(err)
 	}
+	s.snapsMu.Lock()
 	s.snaps = append(s.snaps, snap)
+	s.snapsMu.Unlock()
 	s.AdvanceResolvedTs(job.BinlogInfo.FinishedTS)
 	return nil
 }
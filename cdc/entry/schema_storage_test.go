@@ -15,6 +15,7 @@ package entry
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 
@@ -782,6 +783,62 @@ func (t *schemaSuite) TestExplicitTables(c *check.C) {
 	c.Assert(snap3.ineligibleTableID, check.HasLen, 0)
 }
 
+func (t *schemaSuite) TestExchangePartition(c *check.C) {
+	defer testleak.AfterTest(c)()
+	newTableInfo := func(id int64, name string, partitionIDs ...int64) *timodel.TableInfo {
+		info := &timodel.TableInfo{
+			ID:         id,
+			Name:       timodel.NewCIStr(name),
+			PKIsHandle: true,
+			Columns: []*timodel.ColumnInfo{
+				{ID: 1, Name: timodel.NewCIStr("id"), FieldType: types.FieldType{Flag: mysql.PriKeyFlag}, State: timodel.StatePublic},
+			},
+		}
+		if len(partitionIDs) > 0 {
+			info.Partition = &timodel.PartitionInfo{Enable: true}
+			for i, pid := range partitionIDs {
+				info.Partition.Definitions = append(info.Partition.Definitions,
+					timodel.PartitionDefinition{ID: pid, Name: timodel.NewCIStr(fmt.Sprintf("p%d", i))})
+			}
+		}
+		return info
+	}
+	snap := newEmptySchemaSnapshot(false)
+	c.Assert(snap.createSchema(&timodel.DBInfo{ID: 1, Name: timodel.NewCIStr("test")}), check.IsNil)
+	c.Assert(snap.createSchema(&timodel.DBInfo{ID: 2, Name: timodel.NewCIStr("other")}), check.IsNil)
+	c.Assert(snap.createTable(model.WrapTableInfo(1, "test", 100, newTableInfo(10, "pt", 11, 12))), check.IsNil)
+	c.Assert(snap.createTable(model.WrapTableInfo(2, "other", 100, newTableInfo(20, "nt"))), check.IsNil)
+
+	// ALTER TABLE test.pt EXCHANGE PARTITION p0 WITH TABLE other.nt
+	args, err := json.Marshal([]interface{}{11, 1, 10, "p0", true})
+	c.Assert(err, check.IsNil)
+	job := &timodel.Job{
+		SchemaID: 2,
+		TableID:  20,
+		Type:     timodel.ActionExchangeTablePartition,
+		RawArgs:  args,
+		BinlogInfo: &timodel.HistoryInfo{
+			FinishedTS: 110,
+			TableInfo:  newTableInfo(10, "pt", 20, 12),
+		},
+	}
+	c.Assert(snap.handleDDL(job), check.IsNil)
+
+	table, ok := snap.PhysicalTableByID(20)
+	c.Assert(ok, check.IsTrue)
+	c.Assert(table.TableName, check.Equals, model.TableName{Schema: "test", Table: "pt"})
+	_, ok = snap.TableByID(20)
+	c.Assert(ok, check.IsFalse)
+	table, ok = snap.TableByID(11)
+	c.Assert(ok, check.IsTrue)
+	c.Assert(table.TableName, check.Equals, model.TableName{Schema: "other", Table: "nt"})
+	id, ok := snap.GetTableIDByName("other", "nt")
+	c.Assert(ok, check.IsTrue)
+	c.Assert(id, check.Equals, int64(11))
+	c.Assert(snap.IsTruncateTableID(11), check.IsFalse)
+	c.Assert(snap.tableInSchema[2], check.DeepEquals, []int64{11})
+}
+
 /*
 TODO: Untested Action:
 
@@ -799,7 +856,6 @@ ActionAlterSequence                 ActionType = 35
 ActionDropSequence                  ActionType = 36
 ActionModifyTableAutoIdCache        ActionType = 39
 ActionRebaseAutoRandomBase          ActionType = 40
-ActionExchangeTablePartition        ActionType = 42
 ActionAddCheckConstraint            ActionType = 43
 ActionDropCheckConstraint           ActionType = 44
 ActionAlterCheckConstraint          ActionType = 45
@@ -0,0 +1,229 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entry
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/retry"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	// skipRetryBaseDelay and skipRetryMaxDelay bound the delays between the
+	// retries of an event before it's skipped
+	skipRetryBaseDelay = 100 * time.Millisecond
+	skipRetryMaxDelay  = 2 * time.Second
+)
+
+// EventSkipper skips the row events failed to be mounted by the errors listed
+// in the skip policy of a changefeed, such as the decode failures of the
+// corrupted upstream data, so one bad row doesn't halt the replication. An
+// event is retried before it's skipped, and the skipped events are dumped to
+// the quarantine file of the changefeed and reported as the warnings of the
+// processor until it's restarted.
+//
+// It's safe for concurrent use.
+type EventSkipper struct {
+	changefeedID string
+	codes        map[errors.RFCErrorCode]struct{}
+	maxRetries   int
+	path         string
+
+	metricSkipped prometheus.Counter
+
+	mu      sync.Mutex
+	skipped int
+	lastErr string
+}
+
+// NewEventSkipper creates an EventSkipper dumping the skipped events of the
+// changefeed to a file in dir, nil is returned if the policy skips nothing.
+func NewEventSkipper(
+	cfg *config.SkipEventConfig, dir string, captureAddr string, changefeedID model.ChangeFeedID,
+) (*EventSkipper, error) {
+	if cfg == nil || len(cfg.ErrorCodes) == 0 {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, cerror.WrapError(cerror.ErrQuarantineEvent, err)
+	}
+	codes := make(map[errors.RFCErrorCode]struct{}, len(cfg.ErrorCodes))
+	for _, code := range cfg.ErrorCodes {
+		codes[errors.RFCErrorCode(code)] = struct{}{}
+	}
+	return &EventSkipper{
+		changefeedID: changefeedID,
+		codes:        codes,
+		maxRetries:   cfg.MaxRetries,
+		path:         filepath.Join(dir, changefeedID+".log"),
+
+		metricSkipped: skippedEventCounter.WithLabelValues(captureAddr, changefeedID),
+	}, nil
+}
+
+// match returns whether the error is listed in the policy.
+func (s *EventSkipper) match(err error) bool {
+	_, ok := s.matchedCode(err)
+	return ok
+}
+
+func (s *EventSkipper) matchedCode(err error) (errors.RFCErrorCode, bool) {
+	for _, code := range cerror.RFCCodes(err) {
+		if _, ok := s.codes[code]; ok {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// retry mounts the event failed by err again, and skips it if it still fails
+// by a listed error after the retries, a nil row is returned for the skipped
+// event. The other errors are returned as is, so does a nil EventSkipper.
+func (s *EventSkipper) retry(
+	ctx context.Context, raw *model.RawKVEntry, err error, mount func() (*model.RowChangedEvent, error),
+) (*model.RowChangedEvent, error) {
+	if s == nil || !s.match(err) {
+		return nil, err
+	}
+	if s.maxRetries > 0 {
+		var row *model.RowChangedEvent
+		err = retry.Do(ctx, func() error {
+			var err error
+			row, err = mount()
+			return err
+		}, retry.WithBackoffBaseDelay(skipRetryBaseDelay),
+			retry.WithBackoffMaxDelay(skipRetryMaxDelay),
+			retry.WithMaxTries(uint64(s.maxRetries)),
+			retry.WithIsRetryableErr(s.match))
+		if err == nil {
+			return row, nil
+		}
+		if !s.match(err) {
+			return nil, err
+		}
+	}
+	if err := s.skip(raw, err); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// quarantineRecord is a line of the quarantine file, it keeps the full raw
+// event to be investigated or replayed manually.
+type quarantineRecord struct {
+	Time       time.Time    `json:"time"`
+	Changefeed string       `json:"changefeed"`
+	Code       string       `json:"code"`
+	Error      string       `json:"error"`
+	TableID    int64        `json:"table-id,omitempty"`
+	OpType     model.OpType `json:"op-type"`
+	Key        []byte       `json:"key"`
+	Value      []byte       `json:"value"`
+	OldValue   []byte       `json:"old-value"`
+	StartTs    uint64       `json:"start-ts"`
+	CommitTs   uint64       `json:"commit-ts"`
+	RegionID   uint64       `json:"region-id"`
+}
+
+// skip dumps the event to the quarantine file, the event can't be skipped if
+// it fails to be dumped, since it would be lost without any record.
+func (s *EventSkipper) skip(raw *model.RawKVEntry, cause error) error {
+	code, _ := s.matchedCode(cause)
+	record := &quarantineRecord{
+		Time:       time.Now(),
+		Changefeed: s.changefeedID,
+		Code:       string(code),
+		Error:      cause.Error(),
+		OpType:     raw.OpType,
+		Key:        raw.Key,
+		Value:      raw.Value,
+		OldValue:   raw.OldValue,
+		StartTs:    raw.StartTs,
+		CommitTs:   raw.CRTs,
+		RegionID:   raw.RegionID,
+	}
+	// the table id is unavailable if the key is corrupted
+	if _, tableID, err := decodeTableID(raw.Key); err == nil {
+		record.TableID = tableID
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrQuarantineEvent, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := appendLine(s.path, data); err != nil {
+		return errors.Annotate(cerror.WrapError(cerror.ErrQuarantineEvent, err), cause.Error())
+	}
+	s.skipped++
+	s.lastErr = cause.Error()
+	s.metricSkipped.Inc()
+	log.Warn("the row event is skipped by the skip policy",
+		zap.String("changefeed", s.changefeedID),
+		zap.Int64("tableID", record.TableID),
+		zap.Uint64("commitTs", raw.CRTs),
+		zap.String("quarantine", s.path),
+		zap.Error(cause))
+	return nil
+}
+
+func appendLine(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Warnings returns the warning of the skipped events reported by the capture
+// of addr, it's nil if no event is skipped.
+func (s *EventSkipper) Warnings(addr string) []*model.RunningError {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.skipped == 0 {
+		return nil
+	}
+	err := cerror.ErrEventSkipped.GenWithStackByArgs(s.skipped, s.path, s.lastErr)
+	return []*model.RunningError{{
+		Addr:    addr,
+		Code:    string(cerror.ErrEventSkipped.RFCCode()),
+		Message: err.Error(),
+	}}
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entry
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type skipperSuite struct{}
+
+var _ = check.Suite(&skipperSuite{})
+
+func (s *skipperSuite) TestSkipEvent(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	skipper, err := NewEventSkipper(nil, c.MkDir(), "", "cf")
+	c.Assert(err, check.IsNil)
+	c.Assert(skipper, check.IsNil)
+	_, err = NewEventSkipper(&config.SkipEventConfig{
+		ErrorCodes: []string{string(cerror.ErrSnapshotTableNotFound.RFCCode())},
+	}, c.MkDir(), "", "cf")
+	c.Assert(err, check.ErrorMatches, ".*can't be skipped.*")
+
+	dir := c.MkDir()
+	skipper, err = NewEventSkipper(&config.SkipEventConfig{
+		ErrorCodes: []string{string(cerror.ErrDecodeRowToDatum.RFCCode())},
+		MaxRetries: 2,
+	}, dir, "", "cf")
+	c.Assert(err, check.IsNil)
+	raw := &model.RawKVEntry{OpType: model.OpTypePut, Key: []byte("key"), Value: []byte("value"), CRTs: 100}
+	decodeErr := cerror.WrapError(cerror.ErrDecodeRowToDatum, errors.New("corrupted"))
+
+	// the other errors are never retried
+	tries := 0
+	otherErr := cerror.ErrSnapshotTableNotFound.GenWithStackByArgs(1)
+	_, err = skipper.retry(ctx, raw, otherErr, func() (*model.RowChangedEvent, error) {
+		tries++
+		return nil, nil
+	})
+	c.Assert(err, check.Equals, otherErr)
+	c.Assert(tries, check.Equals, 0)
+
+	// the event is mounted by a retry
+	row, err := skipper.retry(ctx, raw, decodeErr, func() (*model.RowChangedEvent, error) {
+		tries++
+		if tries < 2 {
+			return nil, decodeErr
+		}
+		return &model.RowChangedEvent{CommitTs: 100}, nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(row.CommitTs, check.Equals, uint64(100))
+	c.Assert(skipper.Warnings("addr"), check.HasLen, 0)
+
+	// the event is skipped after the retries
+	tries = 0
+	row, err = skipper.retry(ctx, raw, decodeErr, func() (*model.RowChangedEvent, error) {
+		tries++
+		return nil, decodeErr
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(row, check.IsNil)
+	c.Assert(tries, check.Equals, 2)
+	warnings := skipper.Warnings("addr")
+	c.Assert(warnings, check.HasLen, 1)
+	c.Assert(warnings[0].Addr, check.Equals, "addr")
+	c.Assert(warnings[0].Code, check.Equals, string(cerror.ErrEventSkipped.RFCCode()))
+	c.Assert(warnings[0].Message, check.Matches, ".*1 row events.*corrupted.*")
+
+	data, err := ioutil.ReadFile(skipper.path)
+	c.Assert(err, check.IsNil)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	c.Assert(lines, check.HasLen, 1)
+	record := new(quarantineRecord)
+	c.Assert(json.Unmarshal([]byte(lines[0]), record), check.IsNil)
+	c.Assert(record.Changefeed, check.Equals, "cf")
+	c.Assert(record.Code, check.Equals, string(cerror.ErrDecodeRowToDatum.RFCCode()))
+	c.Assert(record.Key, check.DeepEquals, raw.Key)
+	c.Assert(record.Value, check.DeepEquals, raw.Value)
+	c.Assert(record.CommitTs, check.Equals, uint64(100))
+
+	// a nil skipper returns the errors as is
+	skipper = nil
+	_, err = skipper.retry(ctx, raw, decodeErr, nil)
+	c.Assert(err, check.Equals, decodeErr)
+	c.Assert(skipper.Warnings("addr"), check.HasLen, 0)
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+)
+
+// flowQuota is the memory quota of the events between the pullers and the
+// sink shared by the tables of a processor.
+type flowQuota struct {
+	// max is the quota in bytes, zero means unlimited
+	max  uint64
+	used uint64
+}
+
+func newFlowQuota(max uint64) *flowQuota {
+	return &flowQuota{max: max}
+}
+
+func (q *flowQuota) exceeded() bool {
+	return q.max != 0 && atomic.LoadUint64(&q.used) >= q.max
+}
+
+func (q *flowQuota) consume(size uint64) {
+	atomic.AddUint64(&q.used, size)
+}
+
+func (q *flowQuota) release(size uint64) {
+	atomic.AddUint64(&q.used, ^(size - 1))
+}
+
+// pendingBytes is the bytes of the events before a resolved ts sent to the
+// sorter, they are released once the sink checkpoint reaches the resolved ts.
+type pendingBytes struct {
+	resolvedTs uint64
+	size       uint64
+}
+
+// tableFlowController bounds how far the puller of a table may run ahead of
+// the table sink checkpoint, both in commit ts and in bytes. The events between
+// the sorter and the sink are limited to a commit ts window and to the memory
+// quota of the processor, and the pullers are blocked once either is full, so
+// the memory is stable under bursty workloads and the pressure is propagated
+// to TiKV instead of being buffered.
+//
+// A table is never blocked by the quota if it has sent nothing after its
+// checkpoint, so the tables holding back the global resolved ts can always
+// move forward, and the quota may be exceeded by their events.
+type tableFlowController struct {
+	// window is the span of the commit ts window in tso, zero means unlimited
+	window        uint64
+	quota         *flowQuota
+	startTs       uint64
+	pCheckpointTs *uint64
+	// resolvedTs is the max resolved ts sent to the sorter, all the events
+	// before it are in the sorter or the sink, it's read by the statistics
+	// concurrently
+	resolvedTs uint64
+
+	// pending and unresolved are only accessed by the goroutine consuming
+	// the puller
+	pending    []pendingBytes
+	unresolved uint64
+}
+
+func newTableFlowController(
+	window time.Duration, quota *flowQuota, startTs uint64, pCheckpointTs *uint64,
+) *tableFlowController {
+	return &tableFlowController{
+		window:        oracle.ComposeTS(int64(window/time.Millisecond), 0),
+		quota:         quota,
+		startTs:       startTs,
+		pCheckpointTs: pCheckpointTs,
+		resolvedTs:    startTs,
+	}
+}
+
+func (c *tableFlowController) checkpointTs() uint64 {
+	checkpointTs := atomic.LoadUint64(c.pCheckpointTs)
+	// the checkpoint of the table is zero before the first flush
+	if checkpointTs < c.startTs {
+		return c.startTs
+	}
+	return checkpointTs
+}
+
+// blocked returns whether the events sent to the sorter run ahead of the sink
+// checkpoint by more than the window, or the memory quota is exceeded. The
+// bytes of the events flushed by the sink are released first.
+func (c *tableFlowController) blocked() bool {
+	checkpointTs := c.checkpointTs()
+	c.release(checkpointTs)
+	resolvedTs := c.sentResolvedTs()
+	if c.window != 0 && resolvedTs > checkpointTs+c.window {
+		return true
+	}
+	return resolvedTs > checkpointTs && c.quota.exceeded()
+}
+
+// release releases the bytes of the events before the checkpoint.
+func (c *tableFlowController) release(checkpointTs uint64) {
+	i := 0
+	for ; i < len(c.pending) && c.pending[i].resolvedTs <= checkpointTs; i++ {
+		c.quota.release(c.pending[i].size)
+	}
+	c.pending = c.pending[i:]
+}
+
+// close releases all the bytes of the table, it's called once the puller of
+// the table is stopped.
+func (c *tableFlowController) close() {
+	c.release(math.MaxUint64)
+	if c.unresolved != 0 {
+		c.quota.release(c.unresolved)
+		c.unresolved = 0
+	}
+}
+
+// sentResolvedTs returns the max resolved ts sent to the sorter.
+func (c *tableFlowController) sentResolvedTs() uint64 {
+	return atomic.LoadUint64(&c.resolvedTs)
+}
+
+// usage returns the used ratio of the window, the puller is blocked once it's
+// larger than 1.
+func (c *tableFlowController) usage() float64 {
+	resolvedTs, checkpointTs := c.sentResolvedTs(), c.checkpointTs()
+	if resolvedTs <= checkpointTs || c.window == 0 {
+		return 0
+	}
+	used := oracle.ExtractPhysical(resolvedTs) - oracle.ExtractPhysical(checkpointTs)
+	return float64(used) / float64(oracle.ExtractPhysical(c.window))
+}
+
+// consume records the event sent to the sorter.
+func (c *tableFlowController) consume(entry *model.RawKVEntry) {
+	if entry.OpType != model.OpTypeResolved {
+		size := uint64(entry.ApproximateSize())
+		c.quota.consume(size)
+		c.unresolved += size
+		return
+	}
+	if entry.CRTs <= c.sentResolvedTs() {
+		return
+	}
+	atomic.StoreUint64(&c.resolvedTs, entry.CRTs)
+	if c.unresolved != 0 {
+		c.pending = append(c.pending, pendingBytes{resolvedTs: entry.CRTs, size: c.unresolved})
+		c.unresolved = 0
+	}
+}
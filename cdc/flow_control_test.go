@@ -0,0 +1,100 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+)
+
+type flowControlSuite struct{}
+
+var _ = check.Suite(&flowControlSuite{})
+
+func (s *flowControlSuite) TestTableFlowController(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ts := func(physical int64) uint64 {
+		return oracle.ComposeTS(physical, 0)
+	}
+	var checkpointTs uint64
+	controller := newTableFlowController(time.Second, newFlowQuota(0), ts(1000), &checkpointTs)
+	c.Assert(controller.blocked(), check.IsFalse)
+	c.Assert(controller.usage(), check.Equals, float64(0))
+
+	// the rows don't move the window
+	controller.consume(&model.RawKVEntry{OpType: model.OpTypePut, CRTs: ts(5000)})
+	c.Assert(controller.blocked(), check.IsFalse)
+	controller.consume(&model.RawKVEntry{OpType: model.OpTypeResolved, CRTs: ts(2000)})
+	c.Assert(controller.blocked(), check.IsFalse)
+	c.Assert(controller.usage(), check.Equals, float64(1))
+	// the start ts is used before the first checkpoint
+	controller.consume(&model.RawKVEntry{OpType: model.OpTypeResolved, CRTs: ts(2001)})
+	c.Assert(controller.blocked(), check.IsTrue)
+
+	atomic.StoreUint64(&checkpointTs, ts(1500))
+	c.Assert(controller.blocked(), check.IsFalse)
+	c.Assert(controller.usage(), check.Equals, 0.501)
+	controller.consume(&model.RawKVEntry{OpType: model.OpTypeResolved, CRTs: ts(3000)})
+	c.Assert(controller.blocked(), check.IsTrue)
+	// the resolved ts never goes back
+	controller.consume(&model.RawKVEntry{OpType: model.OpTypeResolved, CRTs: ts(2400)})
+	c.Assert(controller.blocked(), check.IsTrue)
+	atomic.StoreUint64(&checkpointTs, ts(2000))
+	c.Assert(controller.blocked(), check.IsFalse)
+}
+
+func (s *flowControlSuite) TestTableFlowControllerQuota(c *check.C) {
+	defer testleak.AfterTest(c)()
+	quota := newFlowQuota(10)
+	var checkpointTs1, checkpointTs2 uint64
+	// the window is unlimited
+	controller1 := newTableFlowController(0, quota, 100, &checkpointTs1)
+	controller2 := newTableFlowController(0, quota, 100, &checkpointTs2)
+	put := func(controller *tableFlowController, value string) {
+		controller.consume(&model.RawKVEntry{OpType: model.OpTypePut, Value: []byte(value), CRTs: 150})
+	}
+	resolve := func(controller *tableFlowController, ts uint64) {
+		controller.consume(&model.RawKVEntry{OpType: model.OpTypeResolved, CRTs: ts})
+	}
+
+	put(controller1, "12345678")
+	resolve(controller1, 200)
+	c.Assert(controller1.blocked(), check.IsFalse)
+	put(controller1, "12345678")
+	resolve(controller1, 300)
+	c.Assert(controller1.blocked(), check.IsTrue)
+	// the table holding back the global resolved ts is never blocked
+	c.Assert(controller2.blocked(), check.IsFalse)
+	put(controller2, "1234")
+	resolve(controller2, 200)
+	c.Assert(controller2.blocked(), check.IsTrue)
+
+	// the bytes before the checkpoint are released
+	atomic.StoreUint64(&checkpointTs1, 200)
+	atomic.StoreUint64(&checkpointTs2, 200)
+	c.Assert(controller2.blocked(), check.IsFalse)
+	c.Assert(controller1.blocked(), check.IsFalse)
+	c.Assert(atomic.LoadUint64(&quota.used), check.Equals, uint64(8))
+	put(controller1, "12")
+	c.Assert(controller1.blocked(), check.IsTrue)
+
+	controller1.close()
+	controller2.close()
+	c.Assert(atomic.LoadUint64(&quota.used), check.Equals, uint64(0))
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"net/http"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/auth"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// adminAPIs are the APIs changing the state of the cluster, the other APIs
+// only read the state and can be called by viewers.
+var adminAPIs = map[string]struct{}{
+	"/capture/owner/resign":            {},
+	"/capture/owner/admin":             {},
+	"/capture/owner/rebalance_trigger": {},
+	"/capture/owner/move_table":        {},
+	SinkSwitchAPI:                      {},
+	"/admin/log":                       {},
+	changefeedLogLevelAPI:              {},
+	"/admin/config":                    {},
+}
+
+// internalAPIs are the APIs only called by the captures with the internal
+// token, the failed calls of them are logged instead of audited.
+var internalAPIs = map[string]struct{}{
+	runtimeStateAPI: {},
+}
+
+// publicAPIs are the APIs called without authentication, the probes of the
+// orchestrators can't carry the credentials, and they expose nothing.
+var publicAPIs = map[string]struct{}{
+	liveAPI:  {},
+	readyAPI: {},
+}
+
+// changefeedAPIs are the APIs about the changefeed specified by cf-id, the
+// callers scoped to a namespace only call them for the changefeeds in it.
+var changefeedAPIs = map[string]struct{}{
+	"/capture/owner/admin":             {},
+	"/capture/owner/rebalance_trigger": {},
+	"/capture/owner/move_table":        {},
+	SinkSwitchAPI:                      {},
+	"/capture/owner/changefeed/query":  {},
+	ChangefeedStatsAPI:                 {},
+	ChangefeedUsageAPI:                 {},
+	OwnerDecisionsAPI:                  {},
+}
+
+// namespaceListAPIs are the list APIs of the changefeeds and the processors,
+// which only list the ones in the namespace of the scoped callers.
+var namespaceListAPIs = map[string]struct{}{
+	ChangefeedListAPI: {},
+	ProcessorListAPI:  {},
+}
+
+// clusterViewAPIs are the APIs about the whole cluster the callers scoped to a
+// namespace can call, all the others are denied.
+var clusterViewAPIs = map[string]struct{}{
+	"/status":      {},
+	CaptureListAPI: {},
+}
+
+// checkNamespace checks whether the caller scoped to the namespace can call
+// the API, and limits the list APIs to the namespace.
+func checkNamespace(req *http.Request, namespace string) error {
+	path := req.URL.Path
+	if _, ok := clusterViewAPIs[path]; ok {
+		return nil
+	}
+	if _, ok := namespaceListAPIs[path]; ok {
+		query := req.URL.Query()
+		if ns := query.Get(APIOpVarNamespace); ns == "" || ns == namespace {
+			query.Set(APIOpVarNamespace, namespace)
+			req.URL.RawQuery = query.Encode()
+			return nil
+		}
+	}
+	if _, ok := changefeedAPIs[path]; ok && req.ParseForm() == nil {
+		id := req.Form.Get(APIOpVarChangefeedID)
+		if ns, _ := model.SplitChangefeedID(id); id != "" && ns == namespace {
+			return nil
+		}
+	}
+	return cerror.ErrAPINamespaceDenied.GenWithStackByArgs(namespace, req.Method, path)
+}
+
+// requiredRole returns the minimal role to call the API of the path.
+func requiredRole(path string) auth.Role {
+	if _, ok := adminAPIs[path]; ok || isFailpointAPI(path) {
+		return auth.RoleAdmin
+	}
+	return auth.RoleViewer
+}
+
+// authMiddleware rejects the unauthenticated callers and the callers without
+// the role required by the API.
+func authMiddleware(cfg *auth.Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := publicAPIs[req.URL.Path]; ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if _, ok := internalAPIs[req.URL.Path]; ok {
+			if err := cfg.AuthenticateInternal(req); err != nil {
+				log.Warn("internal api caller is not authenticated",
+					zap.String("path", req.URL.Path),
+					zap.String("remote-addr", req.RemoteAddr),
+					zap.Error(err))
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+			next.ServeHTTP(w, req)
+			return
+		}
+		required := requiredRole(req.URL.Path)
+		role, namespace, err := cfg.AuthenticateScope(req)
+		if err != nil {
+			if required == auth.RoleAdmin {
+				auditAPI(req, "call "+req.URL.Path, "", nil, err)
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		if role < required {
+			log.Warn("api caller is not allowed",
+				zap.String("path", req.URL.Path),
+				zap.String("remote-addr", req.RemoteAddr),
+				zap.Stringer("role", role))
+			err := cerror.ErrAPIPermissionDenied.GenWithStackByArgs(role, req.URL.Path)
+			auditAPI(req, "call "+req.URL.Path, "", nil, err)
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+		if namespace != "" {
+			if err := checkNamespace(req, namespace); err != nil {
+				log.Warn("api caller is not allowed out of its namespace",
+					zap.String("path", req.URL.Path),
+					zap.String("remote-addr", req.RemoteAddr),
+					zap.String("namespace", namespace))
+				auditAPI(req, "call "+req.URL.Path, "", nil, err)
+				writeError(w, http.StatusForbidden, err)
+				return
+			}
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/auth"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type httpAuthSuite struct{}
+
+var _ = check.Suite(&httpAuthSuite{})
+
+func (s *httpAuthSuite) TestAuthMiddleware(c *check.C) {
+	defer testleak.AfterTest(c)()
+	cfg := &auth.Config{
+		Tokens:         map[string]auth.Role{"view-token": auth.RoleViewer, "admin-token": auth.RoleAdmin},
+		InternalTokens: map[string]struct{}{"internal-token": {}},
+	}
+	handler := authMiddleware(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(path, token string) int {
+		req := httptest.NewRequest("POST", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	testCases := []struct {
+		path  string
+		token string
+		code  int
+	}{
+		{"/status", "", http.StatusUnauthorized},
+		{"/status", "unknown", http.StatusUnauthorized},
+		{"/status", "view-token", http.StatusOK},
+		{"/capture/owner/changefeed/query", "view-token", http.StatusOK},
+		{"/capture/owner/admin", "view-token", http.StatusForbidden},
+		{"/capture/owner/resign", "view-token", http.StatusForbidden},
+		{"/capture/owner/move_table", "view-token", http.StatusForbidden},
+		{SinkSwitchAPI, "view-token", http.StatusForbidden},
+		{"/admin/log", "view-token", http.StatusForbidden},
+		{"/admin/log/changefeed", "view-token", http.StatusForbidden},
+		{"/debug/fail/github.com/pingcap/ticdc/cdc/sink/SinkFlushError", "view-token", http.StatusForbidden},
+		{"/debug/fail/github.com/pingcap/ticdc/cdc/sink/SinkFlushError", "admin-token", http.StatusOK},
+		{"/capture/owner/admin", "admin-token", http.StatusOK},
+		{"/status", "admin-token", http.StatusOK},
+		{"/status/live", "", http.StatusOK},
+		{"/status/ready", "unknown", http.StatusOK},
+		{runtimeStateAPI, "admin-token", http.StatusUnauthorized},
+		{runtimeStateAPI, "internal-token", http.StatusOK},
+		{"/status", "internal-token", http.StatusUnauthorized},
+	}
+	for _, tc := range testCases {
+		c.Assert(serve(tc.path, tc.token), check.Equals, tc.code, check.Commentf("%v", tc))
+	}
+}
+
+func (s *httpAuthSuite) TestAuthNamespace(c *check.C) {
+	defer testleak.AfterTest(c)()
+	cfg := &auth.Config{
+		Tokens:          map[string]auth.Role{"team-a-token": auth.RoleAdmin},
+		TokenNamespaces: map[string]string{"team-a-token": "team-a"},
+	}
+	var query string
+	handler := authMiddleware(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(target string) int {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Authorization", "Bearer team-a-token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	testCases := []struct {
+		target string
+		code   int
+	}{
+		{"/status", http.StatusOK},
+		{CaptureListAPI, http.StatusOK},
+		{"/capture/owner/admin?cf-id=team-a.test", http.StatusOK},
+		{"/capture/owner/changefeed/query?cf-id=team-a.test", http.StatusOK},
+		{"/capture/owner/admin?cf-id=team-b.test", http.StatusForbidden},
+		{"/capture/owner/admin?cf-id=test", http.StatusForbidden},
+		{"/capture/owner/admin", http.StatusForbidden},
+		{ChangefeedUsageAPI, http.StatusForbidden},
+		{"/capture/owner/resign", http.StatusForbidden},
+		{"/admin/log", http.StatusForbidden},
+		{"/debug/info", http.StatusForbidden},
+		{ChangefeedListAPI + "?namespace=team-b", http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		c.Assert(serve(tc.target), check.Equals, tc.code, check.Commentf("%v", tc))
+	}
+
+	// the list APIs only list the changefeeds in the namespace
+	c.Assert(serve(ChangefeedListAPI+"?prefix=cf"), check.Equals, http.StatusOK)
+	c.Assert(query, check.Equals, "namespace=team-a&prefix=cf")
+	c.Assert(serve(ProcessorListAPI+"?namespace=team-a"), check.Equals, http.StatusOK)
+	c.Assert(query, check.Equals, "namespace=team-a")
+}
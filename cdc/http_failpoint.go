@@ -0,0 +1,57 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pingcap/failpoint"
+)
+
+// failpointAPIPrefix is the path prefix of the failpoint API, the failpoint is
+// named by the rest of the path, such as
+// /debug/fail/github.com/pingcap/ticdc/cdc/sink/SinkFlushError.
+//   - PUT with the term as the body enables the failpoint
+//   - GET returns the term of the failpoint
+//   - DELETE disables the failpoint
+//   - GET /debug/fail/ lists all the enabled failpoints
+const failpointAPIPrefix = "/debug/fail/"
+
+var failpointAPIEnabled int32
+
+// EnableFailpointAPI serves the failpoint API in the status servers created
+// afterwards. It's only called by the test binary built for the integration
+// tests, where the failpoints are compiled in, so the tests can trigger the
+// failures at the exact time instead of relying on GO_FAILPOINTS and sleeps.
+func EnableFailpointAPI() {
+	atomic.StoreInt32(&failpointAPIEnabled, 1)
+}
+
+func isFailpointAPIEnabled() bool {
+	return atomic.LoadInt32(&failpointAPIEnabled) == 1
+}
+
+func isFailpointAPI(path string) bool {
+	return strings.HasPrefix(path, failpointAPIPrefix)
+}
+
+func registerFailpointAPI(serverMux *http.ServeMux) {
+	if !isFailpointAPIEnabled() {
+		return
+	}
+	serverMux.Handle(failpointAPIPrefix,
+		http.StripPrefix(strings.TrimSuffix(failpointAPIPrefix, "/"), &failpoint.HttpHandler{}))
+}
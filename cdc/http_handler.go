@@ -19,12 +19,18 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink"
+	"github.com/pingcap/ticdc/pkg/audit"
+	"github.com/pingcap/ticdc/pkg/auth"
+	"github.com/pingcap/ticdc/pkg/config"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/ticdc/pkg/logutil"
+	"github.com/pingcap/ticdc/pkg/secret"
 	"github.com/pingcap/tidb/store/tikv/oracle"
 	"go.etcd.io/etcd/clientv3/concurrency"
 	"go.uber.org/zap"
@@ -39,6 +45,12 @@ const (
 	APIOpVarTargetCaptureID = "target-cp-id"
 	// APIOpVarTableID is the key of table ID in HTTP API
 	APIOpVarTableID = "table-id"
+	// APIOpVarPinTable is the key of whether to pin the moved table in HTTP API
+	APIOpVarPinTable = "pin"
+	// APIOpVarSinkURI is the key of the sink uri to switch to in HTTP API
+	APIOpVarSinkURI = "sink-uri"
+	// APIOpVarSwitchTs is the key of the ts to switch the sink at in HTTP API
+	APIOpVarSwitchTs = "switch-ts"
 	// APIOpForceRemoveChangefeed is used when remove a changefeed
 	APIOpForceRemoveChangefeed = "force-remove"
 )
@@ -54,6 +66,14 @@ type ChangefeedResp struct {
 	TSO          uint64              `json:"tso"`
 	Checkpoint   string              `json:"checkpoint"`
 	RunningError *model.RunningError `json:"error"`
+	Creator      string              `json:"creator,omitempty"`
+	// Warnings are the risks found by the processors, which don't fail the
+	// changefeed yet.
+	Warnings []*model.RunningError `json:"warnings,omitempty"`
+	// StateHistory are the latest transitions of the state with the reasons.
+	StateHistory []*model.StateTransition `json:"state-history,omitempty"`
+	// SinkSwitch is the pending switch of the sink.
+	SinkSwitch *model.SinkSwitch `json:"sink-switch,omitempty"`
 }
 
 func handleOwnerResp(w http.ResponseWriter, err error) {
@@ -68,6 +88,22 @@ func handleOwnerResp(w http.ResponseWriter, err error) {
 	writeData(w, commonResp{Status: true})
 }
 
+// auditAPI records an admin operation called by the API in the audit log.
+func auditAPI(req *http.Request, operation, target string, params map[string]string, err error) {
+	ev := audit.Event{
+		Source:    audit.SourceAPI,
+		Caller:    auth.Identity(req),
+		Remote:    req.RemoteAddr,
+		Operation: operation,
+		Target:    target,
+		Params:    params,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	audit.Log(ev)
+}
+
 func (s *Server) handleResignOwner(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
 		writeError(w, http.StatusBadRequest, cerror.ErrSupportPostOnly.GenWithStackByArgs())
@@ -95,6 +131,7 @@ func (s *Server) handleResignOwner(w http.ResponseWriter, req *http.Request) {
 	})
 	s.ownerLock.RUnlock()
 	s.setOwner(nil)
+	auditAPI(req, "resign owner", s.capture.info.ID, nil, nil)
 	handleOwnerResp(w, nil)
 }
 
@@ -138,6 +175,8 @@ func (s *Server) handleChangefeedAdmin(w http.ResponseWriter, req *http.Request)
 		Opts: opts,
 	}
 	err = s.owner.EnqueueJob(job)
+	auditAPI(req, job.Type.String(), job.CfID,
+		map[string]string{APIOpForceRemoveChangefeed: strconv.FormatBool(opts.ForceRemove)}, err)
 	handleOwnerResp(w, err)
 }
 
@@ -166,6 +205,7 @@ func (s *Server) handleRebalanceTrigger(w http.ResponseWriter, req *http.Request
 		return
 	}
 	s.owner.TriggerRebalance(changefeedID)
+	auditAPI(req, "rebalance tables", changefeedID, nil, nil)
 	handleOwnerResp(w, nil)
 }
 
@@ -206,7 +246,114 @@ func (s *Server) handleMoveTable(w http.ResponseWriter, req *http.Request) {
 			cerror.ErrAPIInvalidParam.GenWithStack("invalid tableID: %s", tableIDStr))
 		return
 	}
-	s.owner.ManualSchedule(changefeedID, to, tableID)
+	pin := false
+	if pinStr := req.Form.Get(APIOpVarPinTable); pinStr != "" {
+		pin, err = strconv.ParseBool(pinStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest,
+				cerror.ErrAPIInvalidParam.GenWithStack("invalid pin: %s", pinStr))
+			return
+		}
+	}
+	s.owner.ManualSchedule(changefeedID, to, tableID, pin)
+	auditAPI(req, "move table", changefeedID, map[string]string{
+		APIOpVarTableID:         tableIDStr,
+		APIOpVarTargetCaptureID: to,
+		APIOpVarPinTable:        strconv.FormatBool(pin),
+	}, nil)
+	handleOwnerResp(w, nil)
+}
+
+// handleSwitchSink requests the owner to switch the sink of a running
+// changefeed, the switch is accepted or rejected by the owner asynchronously.
+func (s *Server) handleSwitchSink(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusBadRequest, cerror.ErrSupportPostOnly.GenWithStackByArgs())
+		return
+	}
+
+	s.ownerLock.RLock()
+	defer s.ownerLock.RUnlock()
+	if s.owner == nil {
+		handleOwnerResp(w, concurrency.ErrElectionNotLeader)
+		return
+	}
+
+	err := req.ParseForm()
+	if err != nil {
+		writeInternalServerError(w, cerror.WrapError(cerror.ErrInternalServerError, err))
+		return
+	}
+	changefeedID := req.Form.Get(APIOpVarChangefeedID)
+	if err := model.ValidateChangefeedID(changefeedID); err != nil {
+		writeError(w, http.StatusBadRequest,
+			cerror.ErrAPIInvalidParam.GenWithStack("invalid changefeed id: %s", changefeedID))
+		return
+	}
+	sinkURI := req.Form.Get(APIOpVarSinkURI)
+	if sinkURI == "" {
+		writeError(w, http.StatusBadRequest,
+			cerror.ErrAPIInvalidParam.GenWithStack("%s is empty", APIOpVarSinkURI))
+		return
+	}
+	if err := sink.ValidateSinkURI(sinkURI); err != nil {
+		writeError(w, http.StatusBadRequest,
+			cerror.ErrAPIInvalidParam.GenWithStack("invalid %s: %s", APIOpVarSinkURI, err.Error()))
+		return
+	}
+	var switchTs uint64
+	if tsStr := req.Form.Get(APIOpVarSwitchTs); tsStr != "" {
+		switchTs, err = strconv.ParseUint(tsStr, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest,
+				cerror.ErrAPIInvalidParam.GenWithStack("invalid switch ts: %s", tsStr))
+			return
+		}
+	}
+	s.owner.SwitchSink(changefeedID, &model.SinkSwitch{
+		SinkURI:    sinkURI,
+		Ts:         switchTs,
+		CreateTime: time.Now(),
+		Creator:    auth.Identity(req),
+	})
+	auditAPI(req, "switch sink", changefeedID, map[string]string{
+		APIOpVarSinkURI:  secret.RedactURI(sinkURI),
+		APIOpVarSwitchTs: strconv.FormatUint(switchTs, 10),
+	}, nil)
+	handleOwnerResp(w, nil)
+}
+
+// handleRuntimeState receives the runtime state reported by a processor, it's
+// called frequently and not audited.
+func (s *Server) handleRuntimeState(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusBadRequest, cerror.ErrSupportPostOnly.GenWithStackByArgs())
+		return
+	}
+
+	s.ownerLock.RLock()
+	defer s.ownerLock.RUnlock()
+	if s.owner == nil {
+		handleOwnerResp(w, concurrency.ErrElectionNotLeader)
+		return
+	}
+
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeInternalServerError(w, cerror.WrapError(cerror.ErrInternalServerError, err))
+		return
+	}
+	state := new(model.ProcessorRuntimeState)
+	if err := state.Unmarshal(data); err != nil {
+		writeError(w, http.StatusBadRequest, cerror.WrapError(cerror.ErrAPIInvalidParam, err))
+		return
+	}
+	if err := model.ValidateChangefeedID(state.ChangefeedID); err != nil {
+		writeError(w, http.StatusBadRequest,
+			cerror.ErrAPIInvalidParam.GenWithStack("invalid changefeed id: %s", state.ChangefeedID))
+		return
+	}
+	s.owner.runtimeStates.update(state)
 	handleOwnerResp(w, nil)
 }
 
@@ -244,20 +391,64 @@ func (s *Server) handleChangefeedQuery(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	writeData(w, newChangefeedResp(cf, feedInfo, status, feedState))
+}
+
+// newChangefeedResp summarizes the changefeed, cf is nil if the changefeed is
+// not running on the owner.
+func newChangefeedResp(
+	cf *changeFeed, feedInfo *model.ChangeFeedInfo, status *model.ChangeFeedStatus, feedState model.FeedState,
+) *ChangefeedResp {
 	resp := &ChangefeedResp{
 		FeedState: string(feedState),
 	}
 	if cf != nil {
 		resp.RunningError = cf.info.Error
+		resp.Creator = cf.info.Creator
+		resp.Warnings = collectWarnings(cf.taskPositions)
+		resp.StateHistory = cf.info.StateHistory
+		feedInfo = cf.info
 	} else if feedInfo != nil {
 		resp.RunningError = feedInfo.Error
+		resp.Creator = feedInfo.Creator
+		resp.StateHistory = feedInfo.StateHistory
+	}
+	if feedInfo != nil && feedInfo.SinkSwitch != nil {
+		sinkSwitch := *feedInfo.SinkSwitch
+		sinkSwitch.SinkURI = secret.RedactURI(sinkSwitch.SinkURI)
+		resp.SinkSwitch = &sinkSwitch
 	}
 	if status != nil {
 		resp.TSO = status.CheckpointTs
 		tm := oracle.GetTimeFromTS(status.CheckpointTs)
 		resp.Checkpoint = tm.Format("2006-01-02 15:04:05.000")
 	}
-	writeData(w, resp)
+	return resp
+}
+
+// handleOwnerDecisions explains the recent decisions of the owner, and where
+// the barrier ts of the changefeeds comes from.
+func (s *Server) handleOwnerDecisions(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusBadRequest, cerror.ErrAPIInvalidParam.GenWithStack("only GET is supported"))
+		return
+	}
+	changefeedID := req.URL.Query().Get(APIOpVarChangefeedID)
+	if changefeedID != "" {
+		if err := model.ValidateChangefeedID(changefeedID); err != nil {
+			writeError(w, http.StatusBadRequest,
+				cerror.ErrAPIInvalidParam.GenWithStack("invalid changefeed id: %s", changefeedID))
+			return
+		}
+	}
+
+	s.ownerLock.RLock()
+	defer s.ownerLock.RUnlock()
+	if s.owner == nil {
+		handleOwnerResp(w, concurrency.ErrElectionNotLeader)
+		return
+	}
+	writeData(w, s.owner.decisions.explain(changefeedID))
 }
 
 func handleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
@@ -276,6 +467,7 @@ func handleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = logutil.SetLogLevel(level)
+	auditAPI(r, "change log level", level, nil, err)
 	if err != nil {
 		writeError(w, http.StatusBadRequest,
 			cerror.ErrAPIInvalidParam.GenWithStack("fail to change log level: %s", err))
@@ -285,3 +477,189 @@ func handleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
 
 	writeData(w, struct{}{})
 }
+
+const (
+	// changefeedLogLevelAPI changes the log level of a changefeed on the
+	// capture serving the request.
+	changefeedLogLevelAPI = "/admin/log/changefeed"
+	// defaultChangefeedLogLevelDuration is how long a changefeed log level
+	// takes effect if the duration isn't specified.
+	defaultChangefeedLogLevelDuration = 10 * time.Minute
+)
+
+// changefeedLogLevelReq is the request body of changefeedLogLevelAPI.
+type changefeedLogLevelReq struct {
+	ChangefeedID string `json:"changefeed-id"`
+	Level        string `json:"level"`
+	// Duration is how long the level takes effect, such as "30m".
+	Duration string `json:"duration"`
+}
+
+// handleChangefeedLogLevel returns the log levels of the changefeeds on GET,
+// sets the log level of a changefeed on POST, and resets the log level of
+// the changefeed specified by cf-id on DELETE.
+func handleChangefeedLogLevel(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		writeData(w, logutil.ChangefeedLogLevels())
+		return
+	case http.MethodDelete:
+		changefeedID := req.URL.Query().Get(APIOpVarChangefeedID)
+		if changefeedID == "" {
+			writeError(w, http.StatusBadRequest, cerror.ErrAPIInvalidParam.GenWithStack("%s is empty", APIOpVarChangefeedID))
+			return
+		}
+		logutil.ResetChangefeedLogLevel(changefeedID)
+		auditAPI(req, "reset changefeed log level", changefeedID, nil, nil)
+		log.Warn("changefeed log level reset", zap.String("changefeed", changefeedID))
+		writeData(w, struct{}{})
+		return
+	case http.MethodPost:
+	default:
+		writeError(w, http.StatusBadRequest, cerror.ErrSupportPostOnly.GenWithStackByArgs())
+		return
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	var body changefeedLogLevelReq
+	if err := json.Unmarshal(data, &body); err != nil {
+		writeError(w, http.StatusBadRequest,
+			cerror.ErrAPIInvalidParam.GenWithStack("invalid changefeed log level: %s", err))
+		return
+	}
+	duration := defaultChangefeedLogLevelDuration
+	if body.Duration != "" {
+		duration, err = time.ParseDuration(body.Duration)
+		if err != nil {
+			writeError(w, http.StatusBadRequest,
+				cerror.ErrAPIInvalidParam.GenWithStack("invalid duration: %s", err))
+			return
+		}
+	}
+
+	err = logutil.SetChangefeedLogLevel(body.ChangefeedID, body.Level, duration)
+	auditAPI(req, "change changefeed log level", body.ChangefeedID,
+		map[string]string{"level": body.Level, "duration": duration.String()}, err)
+	if err != nil {
+		writeError(w, http.StatusBadRequest,
+			cerror.ErrAPIInvalidParam.GenWithStack("fail to change changefeed log level: %s", err))
+		return
+	}
+	log.Warn("changefeed log level changed", zap.String("changefeed", body.ChangefeedID),
+		zap.String("level", body.Level), zap.Duration("duration", duration))
+
+	writeData(w, struct{}{})
+}
+
+// ChangefeedStatsAPI returns the statistics of the processor of a changefeed
+// on the capture serving the request.
+const ChangefeedStatsAPI = "/capture/changefeed/stats"
+
+// handleChangefeedStats returns the statistics of the processor of the
+// changefeed specified by cf-id, it responds 404 if the changefeed has no
+// processor on the capture.
+func (s *Server) handleChangefeedStats(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusBadRequest, cerror.ErrAPIInvalidParam.GenWithStack("only GET is supported"))
+		return
+	}
+	changefeedID := req.URL.Query().Get(APIOpVarChangefeedID)
+	if err := model.ValidateChangefeedID(changefeedID); err != nil {
+		writeError(w, http.StatusBadRequest,
+			cerror.ErrAPIInvalidParam.GenWithStack("invalid changefeed id: %s", changefeedID))
+		return
+	}
+	if s.capture == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("the capture is not running"))
+		return
+	}
+	stats, ok := s.capture.changefeedStats(changefeedID)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.Errorf("changefeed %s has no processor on the capture", changefeedID))
+		return
+	}
+	writeData(w, stats)
+}
+
+// ChangefeedUsageAPI returns the resources used by the changefeeds, which are
+// reported by the processors to the owner. Only the usage of the changefeed
+// specified by cf-id is returned if it's specified.
+const ChangefeedUsageAPI = "/capture/owner/changefeed/usage"
+
+// ChangefeedUsage is the resources used by a changefeed on all the captures
+// and on each of them.
+type ChangefeedUsage struct {
+	Total    model.ResourceUsage                     `json:"total"`
+	Captures map[model.CaptureID]model.ResourceUsage `json:"captures"`
+}
+
+// handleChangefeedUsage returns the resource usages of the changefeeds, the
+// processors not reporting to the owner recently are not counted.
+func (s *Server) handleChangefeedUsage(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusBadRequest, cerror.ErrAPIInvalidParam.GenWithStack("only GET is supported"))
+		return
+	}
+	changefeedID := req.URL.Query().Get(APIOpVarChangefeedID)
+	if changefeedID != "" {
+		if err := model.ValidateChangefeedID(changefeedID); err != nil {
+			writeError(w, http.StatusBadRequest,
+				cerror.ErrAPIInvalidParam.GenWithStack("invalid changefeed id: %s", changefeedID))
+			return
+		}
+	}
+
+	s.ownerLock.RLock()
+	defer s.ownerLock.RUnlock()
+	if s.owner == nil {
+		handleOwnerResp(w, concurrency.ErrElectionNotLeader)
+		return
+	}
+	resp := make(map[model.ChangeFeedID]*ChangefeedUsage)
+	for id, captures := range s.owner.runtimeStates.usages(changefeedID) {
+		cfUsage := &ChangefeedUsage{Captures: captures}
+		for _, u := range captures {
+			cfUsage.Total.Add(u)
+		}
+		resp[id] = cfUsage
+	}
+	writeData(w, resp)
+}
+
+// handleAdminConfig returns the reloadable server config on GET, and reloads
+// the settings in the request body on POST.
+func (s *Server) handleAdminConfig(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		writeData(w, s.currentConfig())
+		return
+	case http.MethodPost:
+	default:
+		writeError(w, http.StatusBadRequest, cerror.ErrSupportPostOnly.GenWithStackByArgs())
+		return
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	cfg := &config.ServerConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		writeError(w, http.StatusBadRequest,
+			cerror.ErrAPIInvalidParam.GenWithStack("invalid server config: %s", err))
+		return
+	}
+
+	err = s.ReloadConfig(cfg)
+	auditAPI(req, "reload server config", "", map[string]string{"config": string(data)}, err)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeData(w, s.currentConfig())
+}
@@ -0,0 +1,460 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/ticdc/cdc/model"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/secret"
+	"go.etcd.io/etcd/clientv3/concurrency"
+	"go.etcd.io/etcd/mvcc/mvccpb"
+)
+
+// The list APIs return a page of the items matching the filters in the query,
+// see ParseListOptions for the parameters.
+const (
+	ChangefeedListAPI = "/capture/owner/changefeeds"
+	CaptureListAPI    = "/capture/owner/captures"
+	ProcessorListAPI  = "/capture/owner/processors"
+)
+
+const (
+	// APIOpVarState is the key of the comma separated states in list APIs
+	APIOpVarState = "state"
+	// APIOpVarNamespace is the key of the namespace of the changefeeds in
+	// list APIs
+	APIOpVarNamespace = "namespace"
+	// APIOpVarPrefix is the key of the id prefix in list APIs
+	APIOpVarPrefix = "prefix"
+	// APIOpVarKeyword is the key of the searched keyword in list APIs
+	APIOpVarKeyword = "keyword"
+	// APIOpVarSortBy is the key of the sort key in list APIs
+	APIOpVarSortBy = "sort-by"
+	// APIOpVarDesc is the key of whether to sort descendingly in list APIs
+	APIOpVarDesc = "desc"
+	// APIOpVarOffset is the key of the offset of the page in list APIs
+	APIOpVarOffset = "offset"
+	// APIOpVarLimit is the key of the size of the page in list APIs
+	APIOpVarLimit = "limit"
+	// APIOpVarListAll is the key of whether to list the removed and finished
+	// changefeeds in the changefeed list API
+	APIOpVarListAll = "all"
+)
+
+const (
+	// DefaultListLimit is the page size of the list APIs if it's not specified.
+	DefaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// The sort keys of the list APIs, the first one of each API is the default.
+var (
+	ChangefeedSortKeys = []string{"id", "checkpoint", "state"}
+	CaptureSortKeys    = []string{"id", "address"}
+	ProcessorSortKeys  = []string{"changefeed", "capture"}
+)
+
+// ListOptions are the filters, the order and the page of a list API.
+type ListOptions struct {
+	// States are the states of the listed items, all states if it's empty.
+	States []string
+	// Namespace is the namespace of the listed changefeeds, the changefeeds
+	// in all namespaces are listed if it's empty.
+	Namespace string
+	// Prefix is the prefix of the ids of the listed items.
+	Prefix string
+	// Keyword is searched case-insensitively in the ids and some other fields
+	// of the items, such as the sink uri of the changefeeds.
+	Keyword string
+	SortBy  string
+	Desc    bool
+	Offset  int
+	Limit   int
+}
+
+// ParseListOptions parses the list options from the query, the sort key must
+// be one of sortKeys, and it's the first one of them if not specified.
+func ParseListOptions(query url.Values, sortKeys ...string) (*ListOptions, error) {
+	opts := &ListOptions{
+		Namespace: query.Get(APIOpVarNamespace),
+		Prefix:    query.Get(APIOpVarPrefix),
+		Keyword:   query.Get(APIOpVarKeyword),
+		SortBy:    query.Get(APIOpVarSortBy),
+		Limit:     DefaultListLimit,
+	}
+	if opts.Namespace != "" {
+		if err := model.ValidateNamespace(opts.Namespace); err != nil {
+			return nil, cerror.WrapError(cerror.ErrAPIInvalidParam, err)
+		}
+	}
+	if states := query.Get(APIOpVarState); states != "" {
+		opts.States = strings.Split(states, ",")
+	}
+	if opts.SortBy == "" {
+		opts.SortBy = sortKeys[0]
+	} else if !containsString(sortKeys, opts.SortBy) {
+		return nil, cerror.ErrAPIInvalidParam.GenWithStack(
+			"invalid %s: %s, must be one of %s", APIOpVarSortBy, opts.SortBy, strings.Join(sortKeys, ", "))
+	}
+	var err error
+	if v := query.Get(APIOpVarDesc); v != "" {
+		if opts.Desc, err = strconv.ParseBool(v); err != nil {
+			return nil, cerror.ErrAPIInvalidParam.GenWithStack("invalid %s: %s", APIOpVarDesc, v)
+		}
+	}
+	if v := query.Get(APIOpVarOffset); v != "" {
+		if opts.Offset, err = strconv.Atoi(v); err != nil || opts.Offset < 0 {
+			return nil, cerror.ErrAPIInvalidParam.GenWithStack("invalid %s: %s", APIOpVarOffset, v)
+		}
+	}
+	if v := query.Get(APIOpVarLimit); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil || opts.Limit <= 0 || opts.Limit > maxListLimit {
+			return nil, cerror.ErrAPIInvalidParam.GenWithStack(
+				"invalid %s: %s, must be in [1, %d]", APIOpVarLimit, v, maxListLimit)
+		}
+	}
+	return opts, nil
+}
+
+// Values encodes the options into the query of the list APIs.
+func (opts *ListOptions) Values() url.Values {
+	query := url.Values{}
+	if len(opts.States) > 0 {
+		query.Set(APIOpVarState, strings.Join(opts.States, ","))
+	}
+	if opts.Namespace != "" {
+		query.Set(APIOpVarNamespace, opts.Namespace)
+	}
+	if opts.Prefix != "" {
+		query.Set(APIOpVarPrefix, opts.Prefix)
+	}
+	if opts.Keyword != "" {
+		query.Set(APIOpVarKeyword, opts.Keyword)
+	}
+	if opts.SortBy != "" {
+		query.Set(APIOpVarSortBy, opts.SortBy)
+	}
+	if opts.Desc {
+		query.Set(APIOpVarDesc, "true")
+	}
+	if opts.Offset > 0 {
+		query.Set(APIOpVarOffset, strconv.Itoa(opts.Offset))
+	}
+	if opts.Limit > 0 {
+		query.Set(APIOpVarLimit, strconv.Itoa(opts.Limit))
+	}
+	return query
+}
+
+// Match returns whether the item passes the filters, fields are searched for
+// the keyword besides the id. The namespace is matched against the id, which
+// is the changefeed id of the item.
+func (opts *ListOptions) Match(id, state string, fields ...string) bool {
+	if !strings.HasPrefix(id, opts.Prefix) {
+		return false
+	}
+	if opts.Namespace != "" {
+		if namespace, _ := model.SplitChangefeedID(id); namespace != opts.Namespace {
+			return false
+		}
+	}
+	if len(opts.States) > 0 && !containsString(opts.States, state) {
+		return false
+	}
+	if opts.Keyword == "" {
+		return true
+	}
+	keyword := strings.ToLower(opts.Keyword)
+	if strings.Contains(strings.ToLower(id), keyword) {
+		return true
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Less applies the order of the options to the ascending less function.
+func (opts *ListOptions) Less(less func(i, j int) bool) func(i, j int) bool {
+	if !opts.Desc {
+		return less
+	}
+	return func(i, j int) bool { return less(j, i) }
+}
+
+// Page returns the range [start, end) of the page in n sorted items.
+func (opts *ListOptions) Page(n int) (start, end int) {
+	start = opts.Offset
+	if start > n {
+		start = n
+	}
+	end = n
+	if opts.Limit > 0 && start+opts.Limit < n {
+		end = start + opts.Limit
+	}
+	return start, end
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangefeedListItem is a changefeed listed by ChangefeedListAPI.
+type ChangefeedListItem struct {
+	ID      model.ChangeFeedID `json:"id"`
+	Summary *ChangefeedResp    `json:"summary"`
+}
+
+// ChangefeedListResp is the response of ChangefeedListAPI, Total is the number
+// of the changefeeds matching the filters.
+type ChangefeedListResp struct {
+	Total       int                   `json:"total"`
+	Changefeeds []*ChangefeedListItem `json:"changefeeds"`
+}
+
+// CaptureListResp is the response of CaptureListAPI, Total is the number of
+// the captures matching the filters.
+type CaptureListResp struct {
+	Total    int                  `json:"total"`
+	Owner    model.CaptureID      `json:"owner"`
+	Captures []*model.CaptureInfo `json:"captures"`
+}
+
+// ProcessorListResp is the response of ProcessorListAPI, Total is the number
+// of the processors matching the filters.
+type ProcessorListResp struct {
+	Total      int                   `json:"total"`
+	Processors []*model.ProcInfoSnap `json:"processors"`
+}
+
+// parseListRequest parses the list options of the GET request, and writes the
+// error if it's invalid or has the unsupported parameters.
+func parseListRequest(w http.ResponseWriter, req *http.Request, sortKeys []string, unsupported ...string) (*ListOptions, bool) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusBadRequest, cerror.ErrAPIInvalidParam.GenWithStack("only GET is supported"))
+		return nil, false
+	}
+	opts, err := ParseListOptions(req.URL.Query(), sortKeys...)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	for _, key := range unsupported {
+		if req.URL.Query().Get(key) != "" {
+			writeError(w, http.StatusBadRequest,
+				cerror.ErrAPIInvalidParam.GenWithStack("%s is not supported by %s", key, req.URL.Path))
+			return nil, false
+		}
+	}
+	return opts, true
+}
+
+func (s *Server) handleChangefeedList(w http.ResponseWriter, req *http.Request) {
+	opts, ok := parseListRequest(w, req, ChangefeedSortKeys)
+	if !ok {
+		return
+	}
+	var all bool
+	if v := req.URL.Query().Get(APIOpVarListAll); v != "" {
+		var err error
+		if all, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, cerror.ErrAPIInvalidParam.GenWithStack("invalid %s: %s", APIOpVarListAll, v))
+			return
+		}
+	}
+	s.ownerLock.RLock()
+	defer s.ownerLock.RUnlock()
+	if s.owner == nil {
+		handleOwnerResp(w, concurrency.ErrElectionNotLeader)
+		return
+	}
+
+	ctx := req.Context()
+	var raw map[string]*mvccpb.KeyValue
+	var err error
+	if opts.Namespace != "" {
+		_, raw, err = s.owner.etcdClient.GetNamespaceChangeFeeds(ctx, opts.Namespace)
+	} else {
+		_, raw, err = s.owner.etcdClient.GetChangeFeeds(ctx)
+	}
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	infos := make(map[model.ChangeFeedID]*model.ChangeFeedInfo, len(raw))
+	for id, kv := range raw {
+		info := &model.ChangeFeedInfo{}
+		if err := info.Unmarshal(kv.Value); err != nil {
+			writeInternalServerError(w, err)
+			return
+		}
+		infos[id] = info
+	}
+	statuses, err := s.owner.etcdClient.GetAllChangeFeedStatus(ctx)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	ids := make([]model.ChangeFeedID, 0, len(infos))
+	for id := range infos {
+		ids = append(ids, id)
+	}
+	if all {
+		for id := range statuses {
+			if _, ok := infos[id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	items := make([]*ChangefeedListItem, 0, len(ids))
+	for _, id := range ids {
+		info, status := infos[id], statuses[id]
+		var state model.FeedState
+		cf, running := s.owner.changeFeeds[id]
+		if running {
+			status, state = cf.status, cf.info.GetState()
+		} else {
+			state = deriveFeedState(info, status)
+		}
+		var fields []string
+		if info != nil {
+			fields = append(fields, info.Creator, secret.RedactURI(info.SinkURI))
+			if info.Error != nil {
+				fields = append(fields, info.Error.Message)
+			}
+		}
+		if !opts.Match(id, string(state), fields...) {
+			continue
+		}
+		items = append(items, &ChangefeedListItem{ID: id, Summary: newChangefeedResp(cf, info, status, state)})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	sort.SliceStable(items, opts.Less(func(i, j int) bool {
+		switch opts.SortBy {
+		case "checkpoint":
+			return items[i].Summary.TSO < items[j].Summary.TSO
+		case "state":
+			return items[i].Summary.FeedState < items[j].Summary.FeedState
+		default:
+			return items[i].ID < items[j].ID
+		}
+	}))
+	start, end := opts.Page(len(items))
+	writeData(w, &ChangefeedListResp{Total: len(items), Changefeeds: items[start:end]})
+}
+
+func (s *Server) handleCaptureList(w http.ResponseWriter, req *http.Request) {
+	opts, ok := parseListRequest(w, req, CaptureSortKeys, APIOpVarState, APIOpVarNamespace)
+	if !ok {
+		return
+	}
+	s.ownerLock.RLock()
+	defer s.ownerLock.RUnlock()
+	if s.owner == nil {
+		handleOwnerResp(w, concurrency.ErrElectionNotLeader)
+		return
+	}
+	_, captures, err := s.owner.etcdClient.GetCaptures(req.Context())
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	captures = FilterCaptures(captures, opts)
+	start, end := opts.Page(len(captures))
+	resp := &CaptureListResp{Total: len(captures), Captures: captures[start:end]}
+	if s.capture != nil {
+		resp.Owner = s.capture.info.ID
+	}
+	writeData(w, resp)
+}
+
+// FilterCaptures returns the captures matching the options in their order,
+// the page of the options is not applied.
+func FilterCaptures(captures []*model.CaptureInfo, opts *ListOptions) []*model.CaptureInfo {
+	matched := make([]*model.CaptureInfo, 0, len(captures))
+	for _, c := range captures {
+		if opts.Match(c.ID, "", c.AdvertiseAddr) {
+			matched = append(matched, c)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+	sort.SliceStable(matched, opts.Less(func(i, j int) bool {
+		if opts.SortBy == "address" {
+			return matched[i].AdvertiseAddr < matched[j].AdvertiseAddr
+		}
+		return matched[i].ID < matched[j].ID
+	}))
+	return matched
+}
+
+func (s *Server) handleProcessorList(w http.ResponseWriter, req *http.Request) {
+	opts, ok := parseListRequest(w, req, ProcessorSortKeys, APIOpVarState)
+	if !ok {
+		return
+	}
+	s.ownerLock.RLock()
+	defer s.ownerLock.RUnlock()
+	if s.owner == nil {
+		handleOwnerResp(w, concurrency.ErrElectionNotLeader)
+		return
+	}
+	processors, err := s.owner.etcdClient.GetProcessors(req.Context())
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	processors = FilterProcessors(processors, opts)
+	start, end := opts.Page(len(processors))
+	writeData(w, &ProcessorListResp{Total: len(processors), Processors: processors[start:end]})
+}
+
+// FilterProcessors returns the processors matching the options in their order,
+// the page of the options is not applied. The prefix matches the changefeed id
+// of the processors.
+func FilterProcessors(processors []*model.ProcInfoSnap, opts *ListOptions) []*model.ProcInfoSnap {
+	matched := make([]*model.ProcInfoSnap, 0, len(processors))
+	for _, p := range processors {
+		if opts.Match(p.CfID, "", p.CaptureID) {
+			matched = append(matched, p)
+		}
+	}
+	less := func(a, b *model.ProcInfoSnap) bool {
+		if a.CfID != b.CfID {
+			return a.CfID < b.CfID
+		}
+		return a.CaptureID < b.CaptureID
+	}
+	sort.Slice(matched, func(i, j int) bool { return less(matched[i], matched[j]) })
+	sort.SliceStable(matched, opts.Less(func(i, j int) bool {
+		if opts.SortBy == "capture" {
+			return matched[i].CaptureID < matched[j].CaptureID
+		}
+		return matched[i].CfID < matched[j].CfID
+	}))
+	return matched
+}
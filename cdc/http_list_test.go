@@ -0,0 +1,117 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"net/url"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type httpListSuite struct{}
+
+var _ = check.Suite(&httpListSuite{})
+
+func (s *httpListSuite) TestParseListOptions(c *check.C) {
+	defer testleak.AfterTest(c)()
+	opts, err := ParseListOptions(url.Values{}, ChangefeedSortKeys...)
+	c.Assert(err, check.IsNil)
+	c.Assert(opts, check.DeepEquals, &ListOptions{SortBy: "id", Limit: DefaultListLimit})
+
+	query := url.Values{
+		APIOpVarState:     {"normal,failed"},
+		APIOpVarNamespace: {"team-a"},
+		APIOpVarPrefix:    {"cf-"},
+		APIOpVarKeyword:   {"kafka"},
+		APIOpVarSortBy:    {"checkpoint"},
+		APIOpVarDesc:      {"true"},
+		APIOpVarOffset:    {"20"},
+		APIOpVarLimit:     {"10"},
+	}
+	opts, err = ParseListOptions(query, ChangefeedSortKeys...)
+	c.Assert(err, check.IsNil)
+	c.Assert(opts, check.DeepEquals, &ListOptions{
+		States:    []string{"normal", "failed"},
+		Namespace: "team-a",
+		Prefix:    "cf-",
+		Keyword:   "kafka",
+		SortBy:    "checkpoint",
+		Desc:      true,
+		Offset:    20,
+		Limit:     10,
+	})
+	// the options are encoded back into the same query
+	c.Assert(opts.Values(), check.DeepEquals, query)
+
+	for _, invalid := range []url.Values{
+		{APIOpVarSortBy: {"address"}},
+		{APIOpVarNamespace: {"team_a"}},
+		{APIOpVarDesc: {"yes please"}},
+		{APIOpVarOffset: {"-1"}},
+		{APIOpVarLimit: {"0"}},
+		{APIOpVarLimit: {"100000"}},
+	} {
+		_, err := ParseListOptions(invalid, ChangefeedSortKeys...)
+		c.Assert(err, check.ErrorMatches, ".*ErrAPIInvalidParam.*", check.Commentf("%v", invalid))
+	}
+}
+
+func (s *httpListSuite) TestListOptions(c *check.C) {
+	defer testleak.AfterTest(c)()
+	opts := &ListOptions{Prefix: "cf-", States: []string{"normal"}, Keyword: "KAFKA"}
+	c.Assert(opts.Match("cf-1", "normal", "kafka://127.0.0.1:9092/topic"), check.IsTrue)
+	c.Assert(opts.Match("cf-1", "normal", "mysql://127.0.0.1:3306/"), check.IsFalse)
+	c.Assert(opts.Match("cf-1", "failed", "kafka://127.0.0.1:9092/topic"), check.IsFalse)
+	c.Assert(opts.Match("test-1", "normal", "kafka://127.0.0.1:9092/topic"), check.IsFalse)
+	opts.Keyword = "CF"
+	c.Assert(opts.Match("cf-1", "normal"), check.IsTrue)
+
+	opts = &ListOptions{Namespace: "team-a"}
+	c.Assert(opts.Match("team-a.cf-1", "normal"), check.IsTrue)
+	c.Assert(opts.Match("team-b.cf-1", "normal"), check.IsFalse)
+	c.Assert(opts.Match("cf-1", "normal"), check.IsFalse)
+
+	opts = &ListOptions{Offset: 3, Limit: 2}
+	start, end := opts.Page(10)
+	c.Assert([]int{start, end}, check.DeepEquals, []int{3, 5})
+	start, end = opts.Page(4)
+	c.Assert([]int{start, end}, check.DeepEquals, []int{3, 4})
+	start, end = opts.Page(2)
+	c.Assert([]int{start, end}, check.DeepEquals, []int{2, 2})
+}
+
+func (s *httpListSuite) TestFilter(c *check.C) {
+	defer testleak.AfterTest(c)()
+	processors := []*model.ProcInfoSnap{
+		{CfID: "cf-2", CaptureID: "capture-1"},
+		{CfID: "cf-1", CaptureID: "capture-2"},
+		{CfID: "cf-1", CaptureID: "capture-1"},
+		{CfID: "test", CaptureID: "capture-1"},
+	}
+	matched := FilterProcessors(processors, &ListOptions{Prefix: "cf-", SortBy: "changefeed"})
+	c.Assert(matched, check.DeepEquals, []*model.ProcInfoSnap{processors[2], processors[1], processors[0]})
+	matched = FilterProcessors(processors, &ListOptions{Keyword: "capture-1", SortBy: "capture", Desc: true})
+	c.Assert(matched, check.DeepEquals, []*model.ProcInfoSnap{processors[2], processors[0], processors[3]})
+
+	captures := []*model.CaptureInfo{
+		{ID: "a", AdvertiseAddr: "127.0.0.1:8302"},
+		{ID: "b", AdvertiseAddr: "127.0.0.1:8301"},
+	}
+	matched2 := FilterCaptures(captures, &ListOptions{SortBy: "address"})
+	c.Assert(matched2, check.DeepEquals, []*model.CaptureInfo{captures[1], captures[0]})
+	matched2 = FilterCaptures(captures, &ListOptions{SortBy: "id", Desc: true})
+	c.Assert(matched2, check.DeepEquals, []*model.CaptureInfo{captures[1], captures[0]})
+}
@@ -22,10 +22,13 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/kv"
+	"github.com/pingcap/ticdc/cdc/model"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/ticdc/pkg/security"
 	"github.com/pingcap/ticdc/pkg/version"
@@ -45,14 +48,27 @@ func (s *Server) startStatusHTTP() error {
 	serverMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 
 	serverMux.HandleFunc("/status", s.handleStatus)
+	serverMux.HandleFunc(liveAPI, s.handleLive)
+	serverMux.HandleFunc(readyAPI, s.handleReady)
 	serverMux.HandleFunc("/debug/info", s.handleDebugInfo)
 	serverMux.HandleFunc("/capture/owner/resign", s.handleResignOwner)
 	serverMux.HandleFunc("/capture/owner/admin", s.handleChangefeedAdmin)
 	serverMux.HandleFunc("/capture/owner/rebalance_trigger", s.handleRebalanceTrigger)
 	serverMux.HandleFunc("/capture/owner/move_table", s.handleMoveTable)
+	serverMux.HandleFunc(SinkSwitchAPI, s.handleSwitchSink)
 	serverMux.HandleFunc("/capture/owner/changefeed/query", s.handleChangefeedQuery)
+	serverMux.HandleFunc(runtimeStateAPI, s.handleRuntimeState)
+	serverMux.HandleFunc(OwnerDecisionsAPI, s.handleOwnerDecisions)
+	serverMux.HandleFunc(ChangefeedStatsAPI, s.handleChangefeedStats)
+	serverMux.HandleFunc(ChangefeedUsageAPI, s.handleChangefeedUsage)
+	serverMux.HandleFunc(ChangefeedListAPI, s.handleChangefeedList)
+	serverMux.HandleFunc(CaptureListAPI, s.handleCaptureList)
+	serverMux.HandleFunc(ProcessorListAPI, s.handleProcessorList)
 
 	serverMux.HandleFunc("/admin/log", handleAdminLogLevel)
+	serverMux.HandleFunc(changefeedLogLevelAPI, handleChangefeedLogLevel)
+	serverMux.HandleFunc("/admin/config", s.handleAdminConfig)
+	registerFailpointAPI(serverMux)
 
 	prometheus.DefaultGatherer = registry
 	serverMux.Handle("/metrics", promhttp.Handler())
@@ -61,13 +77,19 @@ func (s *Server) startStatusHTTP() error {
 	if s.opts.credential != nil {
 		credential = s.opts.credential
 	}
-	tlsConfig, err := credential.ToTLSConfigWithVerify()
+	// the owner APIs are served by the status server, so the clients are verified with
+	// the allowed common names, and the certificates are reloaded on rotation
+	tlsConfig, err := credential.ToServerTLSConfig()
 	if err != nil {
 		log.Error("status server get tls config failed", zap.Error(err))
 		return errors.Trace(err)
 	}
+	var handler http.Handler = serverMux
+	if s.opts.auth.IsEnabled() {
+		handler = authMiddleware(s.opts.auth, serverMux)
+	}
 	addr := s.opts.addr
-	s.statusServer = &http.Server{Addr: addr, Handler: serverMux, TLSConfig: tlsConfig}
+	s.statusServer = &http.Server{Addr: addr, Handler: handler, TLSConfig: tlsConfig}
 
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -76,7 +98,8 @@ func (s *Server) startStatusHTTP() error {
 	go func() {
 		log.Info("status http server is running", zap.String("addr", addr))
 		if tlsConfig != nil {
-			err = s.statusServer.ServeTLS(ln, credential.CertPath, credential.KeyPath)
+			// the certificates are provided by tlsConfig
+			err = s.statusServer.ServeTLS(ln, "", "")
 		} else {
 			err = s.statusServer.Serve(ln)
 		}
@@ -103,8 +126,17 @@ func (s *Server) writeEtcdInfo(ctx context.Context, cli kv.CDCEtcdClient, w io.W
 		return
 	}
 
+	changefeedInfoPrefix := kv.GetEtcdKeyChangeFeedList()
 	for _, kv := range resp.Kvs {
-		fmt.Fprintf(w, "%s\n\t%s\n\n", string(kv.Key), string(kv.Value))
+		value := string(kv.Value)
+		// the sink uri in the changefeed info may contain credentials
+		if strings.HasPrefix(string(kv.Key), changefeedInfoPrefix) {
+			info := new(model.ChangeFeedInfo)
+			if err := info.Unmarshal(kv.Value); err == nil {
+				value = info.String()
+			}
+		}
+		fmt.Fprintf(w, "%s\n\t%s\n\n", string(kv.Key), value)
 	}
 }
 
@@ -141,6 +173,67 @@ func (s *Server) handleStatus(w http.ResponseWriter, req *http.Request) {
 	writeData(w, st)
 }
 
+// The probes of the orchestrators, such as Kubernetes. The server is restarted
+// if it's not live, and no client is routed to it if it's not ready.
+const (
+	liveAPI  = "/status/live"
+	readyAPI = "/status/ready"
+	// probeTimeout is the timeout of the requests to etcd in the probes.
+	probeTimeout = 3 * time.Second
+)
+
+// checkLive returns an error if the capture has lost its etcd session, the
+// capture restarts itself in this case, and the server should be restarted if
+// it can't recover in time.
+func (s *Server) checkLive() error {
+	if s.capture == nil {
+		return nil
+	}
+	select {
+	case <-s.capture.session.Done():
+		return errors.New("the etcd session of the capture is done")
+	default:
+	}
+	return nil
+}
+
+// checkReady returns an error if the capture is not ready to replicate: it's
+// draining, no owner is elected, the tables are initializing, or the sort dirs
+// are not writable.
+func (s *Server) checkReady(ctx context.Context) error {
+	if s.isDraining() {
+		return errors.New("the capture is draining")
+	}
+	if s.capture == nil {
+		return errors.New("the capture is not started")
+	}
+	if err := s.checkLive(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	if _, err := s.capture.etcdClient.GetOwnerID(ctx, kv.CaptureOwnerKey); err != nil {
+		return errors.Annotate(err, "no owner is elected")
+	}
+	return s.capture.checkReady()
+}
+
+func (s *Server) handleLive(w http.ResponseWriter, req *http.Request) {
+	if err := s.checkLive(); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeData(w, commonResp{Status: true})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, req *http.Request) {
+	if err := s.checkReady(req.Context()); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeData(w, commonResp{Status: true})
+}
+
 func writeInternalServerError(w http.ResponseWriter, err error) {
 	writeError(w, http.StatusInternalServerError, err)
 }
@@ -71,6 +71,7 @@ func (s *httpStatusSuite) TestHTTPStatus(c *check.C) {
 	testHandleChangefeedAdmin(c)
 	testHandleRebalance(c)
 	testHandleMoveTable(c)
+	testHandleSwitchSink(c)
 	testHandleChangefeedQuery(c)
 }
 
@@ -107,6 +108,12 @@ func testHandleMoveTable(c *check.C) {
 	testRequestNonOwnerFailed(c, uri)
 }
 
+func testHandleSwitchSink(c *check.C) {
+	uri := fmt.Sprintf("http://%s%s", testingServerOptions.advertiseAddr, SinkSwitchAPI)
+	testHTTPPostOnly(c, uri)
+	testRequestNonOwnerFailed(c, uri)
+}
+
 func testHandleChangefeedQuery(c *check.C) {
 	uri := fmt.Sprintf("http://%s/capture/owner/changefeed/query", testingServerOptions.advertiseAddr)
 	testHTTPPostOnly(c, uri)
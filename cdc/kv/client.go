@@ -30,6 +30,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/ticdc/pkg/regionspan"
 	"github.com/pingcap/ticdc/pkg/retry"
@@ -71,6 +72,9 @@ type singleRegionInfo struct {
 	ts           uint64
 	failStoreIDs map[uint64]struct{}
 	rpcCtx       *tikv.RPCContext
+	// followerRead is true if the request is sent to a follower in the zone
+	// of the capture instead of the leader
+	followerRead bool
 }
 
 var (
@@ -199,6 +203,16 @@ func (a *connArray) Init(ctx context.Context) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	windowSize, connWindowSize := int32(grpcInitialWindowSize), int32(grpcInitialConnWindowSize)
+	callOptions := []grpc.CallOption{grpc.MaxCallRecvMsgSize(grpcMaxCallRecvMsgSize)}
+	if cfg := config.GetKVClientConfig(); cfg != nil {
+		windowSize, connWindowSize = cfg.GRPCWindowSize, cfg.GRPCConnWindowSize
+		if cfg.GRPCCompression != config.GRPCCompressionNone {
+			// TiKV compresses the events by the algorithm accepted by the
+			// client, and the algorithm is accepted only if it's used.
+			callOptions = append(callOptions, grpc.UseCompressor(cfg.GRPCCompression))
+		}
+	}
 	for i := range a.v {
 		ctx, cancel := context.WithTimeout(ctx, dialTimeout)
 
@@ -206,9 +220,9 @@ func (a *connArray) Init(ctx context.Context) error {
 			ctx,
 			a.target,
 			grpcTLSOption,
-			grpc.WithInitialWindowSize(grpcInitialWindowSize),
-			grpc.WithInitialConnWindowSize(grpcInitialConnWindowSize),
-			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(grpcMaxCallRecvMsgSize)),
+			grpc.WithInitialWindowSize(windowSize),
+			grpc.WithInitialConnWindowSize(connWindowSize),
+			grpc.WithDefaultCallOptions(callOptions...),
 			grpc.WithConnectParams(grpc.ConnectParams{
 				Backoff: gbackoff.Config{
 					BaseDelay:  time.Second,
@@ -316,6 +330,8 @@ type CDCClient struct {
 	kvStorage   tikv.Storage
 
 	regionLimiters *regionEventFeedLimiters
+	// zoneRouter is nil if the requests are always sent to the leaders
+	zoneRouter *zoneRouter
 }
 
 // NewCDCClient creates a CDCClient instance
@@ -337,6 +353,9 @@ func NewCDCClient(ctx context.Context, pd pd.Client, kvStorage tikv.Storage, cre
 		},
 		regionLimiters: defaultRegionEventFeedLimiters,
 	}
+	if cfg := config.GetKVClientConfig(); cfg != nil && cfg.Zone != "" {
+		c.(*CDCClient).zoneRouter = newZoneRouter(pd, cfg.ZoneLabel, cfg.Zone)
+	}
 	return
 }
 
@@ -372,7 +391,7 @@ func (c *CDCClient) getRegionLimiter(regionID uint64) *rate.Limiter {
 }
 
 func (c *CDCClient) newStream(ctx context.Context, addr string, storeID uint64) (stream cdcpb.ChangeData_EventFeedClient, err error) {
-	err = retry.Run(50*time.Millisecond, 3, func() error {
+	err = retry.Do(ctx, func() error {
 		conn, err := c.getConn(ctx, addr)
 		if err != nil {
 			log.Info("get connection to store failed, retry later", zap.String("addr", addr), zap.Error(err))
@@ -400,7 +419,7 @@ func (c *CDCClient) newStream(ctx context.Context, addr string, storeID uint64)
 		}
 		log.Debug("created stream to store", zap.String("addr", addr))
 		return nil
-	})
+	}, retry.WithBackoffBaseDelay(50*time.Millisecond), retry.WithMaxTries(4))
 	return
 }
 
@@ -678,6 +697,13 @@ MainLoop:
 				}
 				continue MainLoop
 			}
+			sri.followerRead = false
+			if s.client.zoneRouter != nil {
+				if followerCtx := s.client.zoneRouter.route(ctx, s.regionCache, sri.verID, rpcCtx); followerCtx != nil {
+					rpcCtx = followerCtx
+					sri.followerRead = true
+				}
+			}
 			sri.rpcCtx = rpcCtx
 
 			requestID := allocID()
@@ -919,7 +945,7 @@ func (s *eventFeedSession) divideAndSendEventFeedToRegions(
 			regions []*tikv.Region
 			err     error
 		)
-		retryErr := retry.Run(50*time.Millisecond, maxRetry,
+		retryErr := retry.Do(ctx,
 			func() error {
 				select {
 				case <-ctx.Done():
@@ -949,7 +975,8 @@ func (s *eventFeedSession) divideAndSendEventFeedToRegions(
 				}
 				log.Debug("ScanRegions", zap.Stringer("span", nextSpan), zap.Reflect("regions", metas))
 				return nil
-			})
+			}, retry.WithBackoffBaseDelay(50*time.Millisecond), retry.WithBackoffMaxDelay(time.Minute),
+			retry.WithMaxTries(maxRetry+1), retry.WithTotalRetryDuration(15*time.Minute))
 
 		if retryErr != nil {
 			return retryErr
@@ -988,6 +1015,10 @@ func (s *eventFeedSession) handleError(ctx context.Context, errInfo regionErrorI
 		innerErr := eerr.err
 		if notLeader := innerErr.GetNotLeader(); notLeader != nil {
 			metricFeedNotLeaderCounter.Inc()
+			if errInfo.followerRead && s.client.zoneRouter != nil {
+				// the CDC component of the store can't read from followers
+				s.client.zoneRouter.markUnsupported(getStoreID(errInfo.rpcCtx))
+			}
 			// TODO: Handle the case that notleader.GetLeader() is nil.
 			s.regionCache.UpdateLeader(errInfo.verID, notLeader.GetLeader().GetStoreId(), errInfo.rpcCtx.AccessIdx)
 		} else if innerErr.GetEpochNotMatch() != nil {
@@ -1068,6 +1099,7 @@ func (s *eventFeedSession) receiveFromStream(
 	captureAddr := util.CaptureAddrFromCtx(ctx)
 	changefeedID := util.ChangefeedIDFromCtx(ctx)
 	metricSendEventBatchResolvedSize := batchResolvedEventSize.WithLabelValues(captureAddr, changefeedID)
+	metricFollowerReadBytes := followerReadBytesCounter.WithLabelValues(captureAddr, changefeedID)
 
 	// Each region has it's own goroutine to handle its messages. `regionStates` stores states of these regions.
 	regionStates := make(map[uint64]*regionFeedState)
@@ -1141,6 +1173,10 @@ func (s *eventFeedSession) receiveFromStream(
 			if err != nil {
 				return err
 			}
+			if state, ok := regionStates[event.RegionId]; ok && state.sri.followerRead {
+				// the bytes would cross the zones if they were sent by the leader
+				metricFollowerReadBytes.Add(float64(event.Size()))
+			}
 		}
 		if cevent.ResolvedTs != nil {
 			metricSendEventBatchResolvedSize.Observe(float64(len(cevent.ResolvedTs.Regions)))
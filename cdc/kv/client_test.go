@@ -319,12 +319,12 @@ var defaultVersionGen = func() string {
 
 // waitRequestID waits request ID larger than the given allocated ID
 func waitRequestID(c *check.C, allocatedID uint64) {
-	err := retry.Run(time.Millisecond*20, 10, func() error {
+	err := retry.Do(context.Background(), func() error {
 		if currentRequestID() > allocatedID {
 			return nil
 		}
 		return errors.Errorf("request id %d is not larger than %d", currentRequestID(), allocatedID)
-	})
+	}, retry.WithBackoffBaseDelay(time.Millisecond*20), retry.WithMaxTries(11))
 	c.Assert(err, check.IsNil)
 }
 
@@ -1185,14 +1185,14 @@ func (s *etcdSuite) TestStreamSendWithError(c *check.C) {
 	// The expected request ids are agnostic because the kv client could retry
 	// for more than one time, so we wait until the newly started server receives
 	// requests for both two regions.
-	err = retry.Run(time.Millisecond*200, 10, func() error {
+	err = retry.Do(context.Background(), func() error {
 		_, ok1 := requestIds.Load(regionID3)
 		_, ok2 := requestIds.Load(regionID4)
 		if ok1 && ok2 {
 			return nil
 		}
 		return errors.New("waiting for kv client requests received by server")
-	})
+	}, retry.WithBackoffBaseDelay(time.Millisecond*200), retry.WithMaxTries(11))
 	c.Assert(err, check.IsNil)
 	reqID1, _ := requestIds.Load(regionID3)
 	reqID2, _ := requestIds.Load(regionID4)
@@ -1270,12 +1270,12 @@ func (s *etcdSuite) TestStreamRecvWithError(c *check.C) {
 	waitRequestID(c, baseAllocatedID+1)
 	initialized1 := mockInitializedEvent(regionID, currentRequestID())
 	ch1 <- initialized1
-	err = retry.Run(time.Millisecond*200, 10, func() error {
+	err = retry.Do(context.Background(), func() error {
 		if len(ch1) == 0 {
 			return nil
 		}
 		return errors.New("message is not sent")
-	})
+	}, retry.WithBackoffBaseDelay(time.Millisecond*200), retry.WithMaxTries(11))
 	c.Assert(err, check.IsNil)
 
 	// another stream will be established, so we notify and wait the first
@@ -1417,20 +1417,20 @@ func (s *etcdSuite) TestIncompatibleTiKV(c *check.C) {
 		wg.Done()
 	}()
 
-	err = retry.Run(time.Millisecond*500, 20, func() error {
+	err = retry.Do(context.Background(), func() error {
 		if atomic.LoadInt32(&call) >= versionGenCallBoundary {
 			return nil
 		}
 		return errors.Errorf("version generator is not updated in time, call time %d", atomic.LoadInt32(&call))
-	})
+	}, retry.WithBackoffBaseDelay(time.Millisecond*500), retry.WithMaxTries(21))
 	c.Assert(err, check.IsNil)
-	err = retry.Run(time.Millisecond*200, 10, func() error {
+	err = retry.Do(context.Background(), func() error {
 		_, ok := requestIds.Load(regionID)
 		if ok {
 			return nil
 		}
 		return errors.New("waiting for kv client requests received by server")
-	})
+	}, retry.WithBackoffBaseDelay(time.Millisecond*200), retry.WithMaxTries(11))
 	c.Assert(err, check.IsNil)
 	reqID, _ := requestIds.Load(regionID)
 	initialized := mockInitializedEvent(regionID, reqID.(uint64))
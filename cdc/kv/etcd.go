@@ -16,9 +16,11 @@ package kv
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/pingcap/errors"
+	"github.com/pingcap/failpoint"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/model"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
@@ -55,6 +57,12 @@ const (
 
 	// JobKeyPrefix is the prefix of job keys
 	JobKeyPrefix = EtcdKeyBase + "/job"
+
+	// MaintenanceKey is the key of the cluster-wide maintenance mode
+	MaintenanceKey = EtcdKeyBase + "/maintenance"
+
+	// QuotaKey is the key of the quota of the cluster
+	QuotaKey = EtcdKeyBase + "/quota"
 )
 
 // GetEtcdKeyChangeFeedList returns the prefix key of all changefeed config
@@ -62,6 +70,12 @@ func GetEtcdKeyChangeFeedList() string {
 	return fmt.Sprintf("%s/changefeed/info", EtcdKeyBase)
 }
 
+// GetEtcdKeyChangeFeedNamespace returns the prefix of the keys of the configs
+// of the changefeeds in the namespace
+func GetEtcdKeyChangeFeedNamespace(namespace string) string {
+	return fmt.Sprintf("%s/%s%s", GetEtcdKeyChangeFeedList(), namespace, model.NamespaceSeparator)
+}
+
 // GetEtcdKeyChangeFeedInfo returns the key of a changefeed config
 func GetEtcdKeyChangeFeedInfo(changefeedID string) string {
 	return fmt.Sprintf("%s/%s", GetEtcdKeyChangeFeedList(), changefeedID)
@@ -123,7 +137,18 @@ func NewCDCEtcdClient(ctx context.Context, cli *clientv3.Client) CDCEtcdClient {
 		etcd.EtcdGrant:  etcdRequestCounter.WithLabelValues(etcd.EtcdGrant, captureAddr),
 		etcd.EtcdRevoke: etcdRequestCounter.WithLabelValues(etcd.EtcdRevoke, captureAddr),
 	}
-	return CDCEtcdClient{Client: etcd.Wrap(cli, metrics)}
+	latencies := make(map[string]prometheus.Observer, len(metrics))
+	for op := range metrics {
+		latencies[op] = etcdRequestDuration.WithLabelValues(op, captureAddr)
+	}
+	return CDCEtcdClient{Client: etcd.WrapWithLatencies(cli, metrics, latencies)}
+}
+
+// NewEndpointRefresher returns an EndpointRefresher of the etcd client, which
+// avoids the leader of PD.
+func (c CDCEtcdClient) NewEndpointRefresher(ctx context.Context, interval time.Duration) *etcd.EndpointRefresher {
+	latency := etcdStatusDuration.MustCurryWith(prometheus.Labels{"capture": util.CaptureAddrFromCtx(ctx)})
+	return etcd.NewEndpointRefresher(c.Client.Unwrap(), interval, true, latency)
 }
 
 // Close releases resources in CDCEtcdClient
@@ -159,8 +184,16 @@ func (c CDCEtcdClient) RevokeAllLeases(ctx context.Context, leases map[string]in
 
 // GetChangeFeeds returns kv revision and a map mapping from changefeedID to changefeed detail mvccpb.KeyValue
 func (c CDCEtcdClient) GetChangeFeeds(ctx context.Context) (int64, map[string]*mvccpb.KeyValue, error) {
-	key := GetEtcdKeyChangeFeedList()
+	return c.getChangeFeeds(ctx, GetEtcdKeyChangeFeedList())
+}
 
+// GetNamespaceChangeFeeds returns kv revision and a map mapping from changefeedID
+// to changefeed detail mvccpb.KeyValue of the changefeeds in the namespace
+func (c CDCEtcdClient) GetNamespaceChangeFeeds(ctx context.Context, namespace string) (int64, map[string]*mvccpb.KeyValue, error) {
+	return c.getChangeFeeds(ctx, GetEtcdKeyChangeFeedNamespace(namespace))
+}
+
+func (c CDCEtcdClient) getChangeFeeds(ctx context.Context, key string) (int64, map[string]*mvccpb.KeyValue, error) {
 	resp, err := c.Client.Get(ctx, key, clientv3.WithPrefix())
 	if err != nil {
 		return 0, nil, cerror.WrapError(cerror.ErrPDEtcdAPIError, err)
@@ -277,7 +310,8 @@ func (c CDCEtcdClient) GetCaptureLeases(ctx context.Context) (map[string]int64,
 	return leases, nil
 }
 
-// CreateChangefeedInfo creates a change feed info into etcd and fails if it is already exists.
+// CreateChangefeedInfo creates a change feed info into etcd and fails if it is already exists,
+// or the number of the changefeeds reaches the limit of the cluster quota.
 func (c CDCEtcdClient) CreateChangefeedInfo(ctx context.Context, info *model.ChangeFeedInfo, changeFeedID string) error {
 	if err := model.ValidateChangefeedID(changeFeedID); err != nil {
 		return err
@@ -288,21 +322,47 @@ func (c CDCEtcdClient) CreateChangefeedInfo(ctx context.Context, info *model.Cha
 	if err != nil {
 		return errors.Trace(err)
 	}
-	resp, err := c.Client.Txn(ctx).If(
-		clientv3.Compare(clientv3.ModRevision(infoKey), "=", 0),
-		clientv3.Compare(clientv3.ModRevision(jobKey), "=", 0),
-	).Then(
-		clientv3.OpPut(infoKey, value),
-	).Commit()
+	quota, err := c.GetClusterQuota(ctx)
 	if err != nil {
-		return cerror.WrapError(cerror.ErrPDEtcdAPIError, err)
+		return errors.Trace(err)
 	}
-	if !resp.Succeeded {
+	for {
+		cmps := []clientv3.Cmp{
+			clientv3.Compare(clientv3.ModRevision(infoKey), "=", 0),
+			clientv3.Compare(clientv3.ModRevision(jobKey), "=", 0),
+		}
+		if quota.MaxChangefeeds > 0 {
+			rev, details, err := c.GetChangeFeeds(ctx)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if _, ok := details[changeFeedID]; !ok && len(details) >= quota.MaxChangefeeds {
+				return cerror.ErrChangefeedQuotaExceeded.GenWithStackByArgs(quota.MaxChangefeeds)
+			}
+			// no changefeed is created after the changefeeds are counted
+			cmps = append(cmps, clientv3.Compare(
+				clientv3.CreateRevision(GetEtcdKeyChangeFeedList()), "<", rev+1).WithPrefix())
+		}
+		resp, err := c.Client.Txn(ctx).If(cmps...).Then(
+			clientv3.OpPut(infoKey, value),
+		).Else(
+			clientv3.OpGet(infoKey, clientv3.WithCountOnly()),
+			clientv3.OpGet(jobKey, clientv3.WithCountOnly()),
+		).Commit()
+		if err != nil {
+			return cerror.WrapError(cerror.ErrPDEtcdAPIError, err)
+		}
+		if resp.Succeeded {
+			return nil
+		}
+		if resp.Responses[0].GetResponseRange().Count == 0 && resp.Responses[1].GetResponseRange().Count == 0 {
+			// another changefeed is created concurrently, count again
+			continue
+		}
 		log.Warn("changefeed already exists, ignore create changefeed",
 			zap.String("changefeed", changeFeedID))
 		return cerror.ErrChangeFeedAlreadyExists.GenWithStackByArgs(changeFeedID)
 	}
-	return errors.Trace(err)
 }
 
 // SaveChangeFeedInfo stores change feed info into etcd
@@ -435,6 +495,102 @@ func (c CDCEtcdClient) GetAllTaskStatus(ctx context.Context, changefeedID string
 	return pinfo, nil
 }
 
+// GetTaskStatusCount returns the number of the captures the changefeed is
+// assigned to, it only reads the keys of the task statuses.
+func (c CDCEtcdClient) GetTaskStatusCount(ctx context.Context, changefeedID string) (int, error) {
+	resp, err := c.Client.Get(ctx, TaskStatusKeyPrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return 0, cerror.WrapError(cerror.ErrPDEtcdAPIError, err)
+	}
+	count := 0
+	for _, rawKv := range resp.Kvs {
+		changeFeed, err := model.ExtractKeySuffix(string(rawKv.Key))
+		if err != nil {
+			return 0, err
+		}
+		if changeFeed == changefeedID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// TaskStatusCache caches the task statuses read by GetAllTaskStatusCached. It's
+// safe for concurrent use.
+type TaskStatusCache struct {
+	mu       sync.Mutex
+	statuses map[model.ChangeFeedID]model.ProcessorsInfos
+}
+
+// NewTaskStatusCache creates a TaskStatusCache.
+func NewTaskStatusCache() *TaskStatusCache {
+	return &TaskStatusCache{
+		statuses: make(map[model.ChangeFeedID]model.ProcessorsInfos),
+	}
+}
+
+// GetAllTaskStatusCached is the same as GetAllTaskStatus, but it only reads the
+// revisions of the task statuses, and reads and decodes the values of the
+// task statuses changed since they were cached. The task status of a capture
+// replicating a large number of tables is large, reading all of them in every
+// round of the owner is costly.
+//
+// The returned task statuses are shared with the cache, they must not be
+// modified.
+func (c CDCEtcdClient) GetAllTaskStatusCached(
+	ctx context.Context,
+	changefeedID string,
+	cache *TaskStatusCache,
+) (model.ProcessorsInfos, error) {
+	resp, err := c.Client.Get(ctx, TaskStatusKeyPrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrPDEtcdAPIError, err)
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cached := cache.statuses[changefeedID]
+	pinfo := make(map[string]*model.TaskStatus)
+	for _, rawKv := range resp.Kvs {
+		changeFeed, err := model.ExtractKeySuffix(string(rawKv.Key))
+		if err != nil {
+			return nil, err
+		}
+		if changeFeed != changefeedID {
+			continue
+		}
+		endIndex := len(rawKv.Key) - len(changeFeed) - 1
+		captureID, err := model.ExtractKeySuffix(string(rawKv.Key[0:endIndex]))
+		if err != nil {
+			return nil, err
+		}
+		if info, ok := cached[captureID]; ok && info.ModRevision == rawKv.ModRevision {
+			pinfo[captureID] = info
+			continue
+		}
+		modRevision, info, err := c.GetTaskStatus(ctx, changefeedID, captureID)
+		if err != nil {
+			if cerror.ErrTaskStatusNotExists.Equal(err) {
+				// removed after the revisions are read
+				continue
+			}
+			return nil, errors.Trace(err)
+		}
+		info.ModRevision = modRevision
+		pinfo[captureID] = info
+	}
+	if len(pinfo) == 0 {
+		delete(cache.statuses, changefeedID)
+	} else {
+		cache.statuses[changefeedID] = pinfo
+	}
+	// return a copy of the map, the caller may modify it
+	infos := make(model.ProcessorsInfos, len(pinfo))
+	for captureID, info := range pinfo {
+		infos[captureID] = info
+	}
+	return infos, nil
+}
+
 // RemoveAllTaskStatus removes all task status of a changefeed
 func (c CDCEtcdClient) RemoveAllTaskStatus(ctx context.Context, changefeedID string) error {
 	resp, err := c.Client.Get(ctx, TaskStatusKeyPrefix, clientv3.WithPrefix())
@@ -492,7 +648,7 @@ func (c CDCEtcdClient) PutTaskStatus(
 	captureID string,
 	info *model.TaskStatus,
 ) error {
-	data, err := info.Marshal()
+	data, err := info.MarshalCompact()
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -604,7 +760,9 @@ func (c CDCEtcdClient) AtomicPutTaskStatus(
 ) (*model.TaskStatus, int64, error) {
 	var status *model.TaskStatus
 	var newModRevision int64
-	err := retry.Run(100*time.Millisecond, 3, func() error {
+	// the errors of the update functions are not retried
+	var updateErr error
+	err := retry.Do(ctx, func() error {
 		select {
 		case <-ctx.Done():
 			return errors.Trace(ctx.Err())
@@ -628,6 +786,7 @@ func (c CDCEtcdClient) AtomicPutTaskStatus(
 		for _, updateFunc := range updateFuncs {
 			u, err := updateFunc(modRevision, status)
 			if err != nil {
+				updateErr = err
 				return err
 			}
 			updated = updated || u
@@ -635,11 +794,15 @@ func (c CDCEtcdClient) AtomicPutTaskStatus(
 		if !updated {
 			return nil
 		}
-		value, err := status.Marshal()
+		value, err := status.MarshalCompact()
 		if err != nil {
 			return errors.Trace(err)
 		}
 
+		failpoint.Inject("EtcdTxnConflict", func() {
+			log.Info("inject etcd txn conflict", zap.String("key", key))
+			failpoint.Return(cerror.ErrWriteTsConflict.GenWithStackByArgs(key))
+		})
 		resp, err := c.Client.Txn(ctx).If(writeCmp).Then(
 			clientv3.OpPut(key, value),
 		).Commit()
@@ -653,7 +816,8 @@ func (c CDCEtcdClient) AtomicPutTaskStatus(
 		}
 		newModRevision = resp.Header.GetRevision()
 		return nil
-	})
+	}, retry.WithBackoffBaseDelay(100*time.Millisecond), retry.WithMaxTries(4),
+		retry.WithIsRetryableErr(func(error) bool { return updateErr == nil }))
 	if err != nil {
 		return nil, newModRevision, errors.Trace(err)
 	}
@@ -857,3 +1021,79 @@ func (c CDCEtcdClient) GetOwnerID(ctx context.Context, key string) (string, erro
 	}
 	return string(resp.Kvs[0].Value), nil
 }
+
+// GetMaintenanceInfo returns the maintenance mode of the cluster, it returns
+// ErrClusterNotInMaintenance if the cluster is not in the mode.
+func (c CDCEtcdClient) GetMaintenanceInfo(ctx context.Context) (*model.MaintenanceInfo, error) {
+	resp, err := c.Client.Get(ctx, MaintenanceKey)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrPDEtcdAPIError, err)
+	}
+	if resp.Count == 0 {
+		return nil, cerror.ErrClusterNotInMaintenance.GenWithStackByArgs()
+	}
+	info := &model.MaintenanceInfo{}
+	err = info.Unmarshal(resp.Kvs[0].Value)
+	return info, errors.Trace(err)
+}
+
+// CreateMaintenanceInfo enters the maintenance mode, it fails with
+// ErrClusterInMaintenance if the cluster is already in the mode.
+func (c CDCEtcdClient) CreateMaintenanceInfo(ctx context.Context, info *model.MaintenanceInfo) error {
+	value, err := info.Marshal()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := c.Client.Txn(ctx).If(
+		clientv3.Compare(clientv3.ModRevision(MaintenanceKey), "=", 0),
+	).Then(
+		clientv3.OpPut(MaintenanceKey, value),
+	).Else(
+		clientv3.OpGet(MaintenanceKey),
+	).Commit()
+	if err != nil {
+		return cerror.WrapError(cerror.ErrPDEtcdAPIError, err)
+	}
+	if !resp.Succeeded {
+		startTime := "unknown"
+		if kvs := resp.Responses[0].GetResponseRange().Kvs; len(kvs) > 0 {
+			existing := &model.MaintenanceInfo{}
+			if err := existing.Unmarshal(kvs[0].Value); err == nil {
+				startTime = existing.StartTime.String()
+			}
+		}
+		return cerror.ErrClusterInMaintenance.GenWithStackByArgs(startTime)
+	}
+	return nil
+}
+
+// DeleteMaintenanceInfo leaves the maintenance mode
+func (c CDCEtcdClient) DeleteMaintenanceInfo(ctx context.Context) error {
+	_, err := c.Client.Delete(ctx, MaintenanceKey)
+	return cerror.WrapError(cerror.ErrPDEtcdAPIError, err)
+}
+
+// GetClusterQuota returns the quota of the cluster, the quota without any
+// limit is returned if it's not set.
+func (c CDCEtcdClient) GetClusterQuota(ctx context.Context) (*model.ClusterQuota, error) {
+	resp, err := c.Client.Get(ctx, QuotaKey)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrPDEtcdAPIError, err)
+	}
+	quota := &model.ClusterQuota{}
+	if resp.Count == 0 {
+		return quota, nil
+	}
+	err = quota.Unmarshal(resp.Kvs[0].Value)
+	return quota, errors.Trace(err)
+}
+
+// PutClusterQuota stores the quota of the cluster
+func (c CDCEtcdClient) PutClusterQuota(ctx context.Context, quota *model.ClusterQuota) error {
+	value, err := quota.Marshal()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = c.Client.Put(ctx, QuotaKey, value)
+	return cerror.WrapError(cerror.ErrPDEtcdAPIError, err)
+}
@@ -105,6 +105,17 @@ func (s *etcdSuite) TestGetChangeFeeds(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(len(result), check.Equals, 3)
 
+	// the changefeeds in a namespace share the prefix of the keys
+	_, err = s.client.Client.Put(context.Background(), GetEtcdKeyChangeFeedInfo("team-a.id"), "detail3")
+	c.Assert(err, check.IsNil)
+	_, result, err = s.client.GetNamespaceChangeFeeds(context.Background(), "team-a")
+	c.Assert(err, check.IsNil)
+	c.Assert(len(result), check.Equals, 1)
+	c.Assert(string(result["team-a.id"].Value), check.Equals, "detail3")
+	_, result, err = s.client.GetNamespaceChangeFeeds(context.Background(), "team")
+	c.Assert(err, check.IsNil)
+	c.Assert(len(result), check.Equals, 0)
+
 	err = s.client.ClearAllCDCInfo(context.Background())
 	c.Assert(err, check.IsNil)
 
@@ -160,6 +171,52 @@ func (s *etcdSuite) TestDeleteTaskStatus(c *check.C) {
 	c.Assert(cerror.ErrTaskStatusNotExists.Equal(err), check.IsTrue)
 }
 
+func (s *etcdSuite) TestGetAllTaskStatusCached(c *check.C) {
+	defer testleak.AfterTest(c)()
+	defer s.TearDownTest(c)
+	ctx := context.Background()
+	feedID := "feedid"
+	cache := NewTaskStatusCache()
+
+	for _, captureID := range []string{"capture1", "capture2"} {
+		err := s.client.PutTaskStatus(ctx, feedID, captureID, &model.TaskStatus{
+			Tables: map[model.TableID]*model.TableReplicaInfo{1: {StartTs: 100}},
+		})
+		c.Assert(err, check.IsNil)
+	}
+	// the task status of other changefeeds is ignored
+	err := s.client.PutTaskStatus(ctx, "feedid2", "capture1", &model.TaskStatus{})
+	c.Assert(err, check.IsNil)
+
+	infos, err := s.client.GetAllTaskStatusCached(ctx, feedID, cache)
+	c.Assert(err, check.IsNil)
+	expected, err := s.client.GetAllTaskStatus(ctx, feedID)
+	c.Assert(err, check.IsNil)
+	c.Assert(infos, check.DeepEquals, expected)
+
+	// the unchanged task status is returned from the cache
+	capture1 := infos["capture1"]
+	infos, err = s.client.GetAllTaskStatusCached(ctx, feedID, cache)
+	c.Assert(err, check.IsNil)
+	c.Assert(infos["capture1"] == capture1, check.IsTrue)
+
+	// the changed and removed task statuses are read again
+	_, _, err = s.client.AtomicPutTaskStatus(ctx, feedID, "capture1", func(_ int64, status *model.TaskStatus) (bool, error) {
+		status.Tables[2] = &model.TableReplicaInfo{StartTs: 200}
+		return true, nil
+	})
+	c.Assert(err, check.IsNil)
+	err = s.client.DeleteTaskStatus(ctx, feedID, "capture2")
+	c.Assert(err, check.IsNil)
+	infos, err = s.client.GetAllTaskStatusCached(ctx, feedID, cache)
+	c.Assert(err, check.IsNil)
+	expected, err = s.client.GetAllTaskStatus(ctx, feedID)
+	c.Assert(err, check.IsNil)
+	c.Assert(infos, check.DeepEquals, expected)
+	c.Assert(infos, check.HasLen, 1)
+	c.Assert(infos["capture1"].Tables, check.HasLen, 2)
+}
+
 func (s *etcdSuite) TestGetPutTaskPosition(c *check.C) {
 	defer testleak.AfterTest(c)()
 	defer s.TearDownTest(c)
@@ -432,6 +489,37 @@ func (s *etcdSuite) TestCreateChangefeed(c *check.C) {
 	c.Assert(cerror.ErrChangeFeedAlreadyExists.Equal(err), check.IsTrue)
 }
 
+func (s *etcdSuite) TestCreateChangefeedInfoWithQuota(c *check.C) {
+	defer testleak.AfterTest(c)()
+	defer s.TearDownTest(c)
+	ctx := context.Background()
+	detail := &model.ChangeFeedInfo{
+		SinkURI: "blackhole://",
+		Creator: "token:0123abcd",
+	}
+
+	quota, err := s.client.GetClusterQuota(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(quota.MaxChangefeeds, check.Equals, 0)
+	err = s.client.PutClusterQuota(ctx, &model.ClusterQuota{MaxChangefeeds: 2})
+	c.Assert(err, check.IsNil)
+
+	c.Assert(s.client.CreateChangefeedInfo(ctx, detail, "test-1"), check.IsNil)
+	c.Assert(s.client.CreateChangefeedInfo(ctx, detail, "test-2"), check.IsNil)
+	err = s.client.CreateChangefeedInfo(ctx, detail, "test-2")
+	c.Assert(cerror.ErrChangeFeedAlreadyExists.Equal(err), check.IsTrue)
+	err = s.client.CreateChangefeedInfo(ctx, detail, "test-3")
+	c.Assert(cerror.ErrChangefeedQuotaExceeded.Equal(err), check.IsTrue)
+
+	info, err := s.client.GetChangeFeedInfo(ctx, "test-1")
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Creator, check.Equals, detail.Creator)
+
+	// the quota is released by removing the changefeeds
+	c.Assert(s.client.DeleteChangeFeedInfo(ctx, "test-1"), check.IsNil)
+	c.Assert(s.client.CreateChangefeedInfo(ctx, detail, "test-3"), check.IsNil)
+}
+
 type Captures []*model.CaptureInfo
 
 func (c Captures) Len() int           { return len(c) }
@@ -517,3 +605,31 @@ func (s *etcdSuite) TestGetAllCDCInfo(c *check.C) {
 		c.Assert(string(kv.Value), check.Equals, expected[i].value)
 	}
 }
+
+func (s *etcdSuite) TestMaintenanceInfo(c *check.C) {
+	defer testleak.AfterTest(c)()
+	defer s.TearDownTest(c)
+	ctx := context.Background()
+
+	_, err := s.client.GetMaintenanceInfo(ctx)
+	c.Assert(cerror.ErrClusterNotInMaintenance.Equal(err), check.IsTrue)
+
+	info := &model.MaintenanceInfo{
+		StartTime:         time.Now().Round(time.Second),
+		PausedChangefeeds: []model.ChangeFeedID{"cf-1", "cf-2"},
+	}
+	err = s.client.CreateMaintenanceInfo(ctx, info)
+	c.Assert(err, check.IsNil)
+	err = s.client.CreateMaintenanceInfo(ctx, &model.MaintenanceInfo{StartTime: time.Now()})
+	c.Assert(cerror.ErrClusterInMaintenance.Equal(err), check.IsTrue)
+
+	got, err := s.client.GetMaintenanceInfo(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.StartTime.Equal(info.StartTime), check.IsTrue)
+	c.Assert(got.PausedChangefeeds, check.DeepEquals, info.PausedChangefeeds)
+
+	err = s.client.DeleteMaintenanceInfo(ctx)
+	c.Assert(err, check.IsNil)
+	_, err = s.client.GetMaintenanceInfo(ctx)
+	c.Assert(cerror.ErrClusterNotInMaintenance.Equal(err), check.IsTrue)
+}
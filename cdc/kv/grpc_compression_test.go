@@ -0,0 +1,51 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+	"google.golang.org/grpc/encoding"
+)
+
+type grpcCompressionSuite struct{}
+
+var _ = check.Suite(&grpcCompressionSuite{})
+
+func (s *grpcCompressionSuite) TestCompressors(c *check.C) {
+	defer testleak.AfterTest(c)()
+	data := bytes.Repeat([]byte("ticdc event stream "), 1024)
+	for _, name := range []string{config.GRPCCompressionGzip, config.GRPCCompressionSnappy, config.GRPCCompressionZstd} {
+		compressor := encoding.GetCompressor(name)
+		c.Assert(compressor, check.NotNil, check.Commentf("%s", name))
+
+		var buf bytes.Buffer
+		w, err := compressor.Compress(&buf)
+		c.Assert(err, check.IsNil)
+		_, err = w.Write(data)
+		c.Assert(err, check.IsNil)
+		c.Assert(w.Close(), check.IsNil)
+		c.Assert(buf.Len(), check.Less, len(data), check.Commentf("%s", name))
+
+		r, err := compressor.Decompress(&buf)
+		c.Assert(err, check.IsNil)
+		decompressed, err := ioutil.ReadAll(r)
+		c.Assert(err, check.IsNil)
+		c.Assert(decompressed, check.DeepEquals, data, check.Commentf("%s", name))
+	}
+}
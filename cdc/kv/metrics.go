@@ -75,6 +75,13 @@ var (
 			Help:      "The number of region in one batch resolved ts event",
 			Buckets:   prometheus.ExponentialBuckets(2, 2, 16),
 		}, []string{"capture", "changefeed"})
+	followerReadBytesCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ticdc",
+			Subsystem: "kvclient",
+			Name:      "same_zone_follower_read_bytes",
+			Help:      "The bytes of the events received from the followers in the zone of the capture instead of the leaders out of it",
+		}, []string{"capture", "changefeed"})
 	etcdRequestCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "ticdc",
@@ -82,6 +89,22 @@ var (
 			Name:      "request_count",
 			Help:      "request counter of etcd operation",
 		}, []string{"type", "capture"})
+	etcdRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ticdc",
+			Subsystem: "etcd",
+			Name:      "request_duration_seconds",
+			Help:      "The latency of the etcd operations, each retry is observed.",
+			Buckets:   prometheus.ExponentialBuckets(0.001 /* 1 ms */, 2, 16),
+		}, []string{"type", "capture"})
+	etcdStatusDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ticdc",
+			Subsystem: "etcd",
+			Name:      "endpoint_status_duration_seconds",
+			Help:      "The latency of checking the status of the etcd endpoints.",
+			Buckets:   prometheus.ExponentialBuckets(0.001 /* 1 ms */, 2, 16),
+		}, []string{"capture", "endpoint"})
 )
 
 // InitMetrics registers all metrics in the kv package
@@ -94,5 +117,8 @@ func InitMetrics(registry *prometheus.Registry) {
 	registry.MustRegister(sendEventCounter)
 	registry.MustRegister(clientChannelSize)
 	registry.MustRegister(batchResolvedEventSize)
+	registry.MustRegister(followerReadBytesCounter)
 	registry.MustRegister(etcdRequestCounter)
+	registry.MustRegister(etcdRequestDuration)
+	registry.MustRegister(etcdStatusDuration)
 }
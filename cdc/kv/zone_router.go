@@ -0,0 +1,120 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pingcap/log"
+	tidbkv "github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/store/tikv"
+	pd "github.com/tikv/pd/client"
+	"go.uber.org/zap"
+)
+
+// zoneRouter routes the event feed requests of the regions whose leaders are
+// out of the zone of the capture to the followers in the zone, so the events
+// don't cross the zones. The stores which reject the follower requests, i.e.
+// whose CDC components can't read from followers, are not routed to again.
+type zoneRouter struct {
+	pd        pd.Client
+	zoneLabel string
+	zone      string
+
+	mu                sync.Mutex
+	storeZones        map[uint64]string
+	unsupportedStores map[uint64]struct{}
+}
+
+func newZoneRouter(pd pd.Client, zoneLabel, zone string) *zoneRouter {
+	return &zoneRouter{
+		pd:                pd,
+		zoneLabel:         zoneLabel,
+		zone:              zone,
+		storeZones:        make(map[uint64]string),
+		unsupportedStores: make(map[uint64]struct{}),
+	}
+}
+
+// inZone returns whether the store is in the zone of the capture, the stores
+// whose labels can't be loaded are treated as out of the zone.
+func (r *zoneRouter) inZone(ctx context.Context, storeID uint64) bool {
+	r.mu.Lock()
+	zone, ok := r.storeZones[storeID]
+	r.mu.Unlock()
+	if ok {
+		return zone == r.zone
+	}
+	store, err := r.pd.GetStore(ctx, storeID)
+	if err != nil {
+		log.Warn("get the labels of the store failed", zap.Uint64("storeID", storeID), zap.Error(err))
+		return false
+	}
+	for _, label := range store.GetLabels() {
+		if label.GetKey() == r.zoneLabel {
+			zone = label.GetValue()
+		}
+	}
+	r.mu.Lock()
+	r.storeZones[storeID] = zone
+	r.mu.Unlock()
+	return zone == r.zone
+}
+
+func (r *zoneRouter) supported(storeID uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.unsupportedStores[storeID]
+	return !ok
+}
+
+// markUnsupported stops routing the requests to the followers on the store.
+func (r *zoneRouter) markUnsupported(storeID uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.unsupportedStores[storeID]; !ok {
+		log.Info("the store doesn't support the follower event feed, the requests are sent to the leaders",
+			zap.Uint64("storeID", storeID))
+		r.unsupportedStores[storeID] = struct{}{}
+	}
+}
+
+// route returns the rpc context of a follower of the region in the zone of the
+// capture, it's nil if the leader is in the zone or no such follower exists.
+func (r *zoneRouter) route(
+	ctx context.Context, regionCache *tikv.RegionCache, id tikv.RegionVerID, leaderCtx *tikv.RPCContext,
+) *tikv.RPCContext {
+	leaderStoreID := getStoreID(leaderCtx)
+	if r.inZone(ctx, leaderStoreID) {
+		return nil
+	}
+	bo := tikv.NewBackoffer(ctx, tikvRequestMaxBackoff)
+	// the seeds select each follower in turn
+	for seed := range leaderCtx.Meta.GetPeers() {
+		rpcCtx, err := regionCache.GetTiKVRPCContext(bo, id, tidbkv.ReplicaReadFollower, uint32(seed))
+		if err != nil || rpcCtx == nil {
+			return nil
+		}
+		storeID := getStoreID(rpcCtx)
+		if storeID == leaderStoreID {
+			// the region has no reachable follower
+			return nil
+		}
+		if r.supported(storeID) && r.inZone(ctx, storeID) {
+			return rpcCtx
+		}
+	}
+	return nil
+}
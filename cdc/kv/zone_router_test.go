@@ -0,0 +1,83 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+	tidbkv "github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/store/mockstore/mocktikv"
+	"github.com/pingcap/tidb/store/tikv"
+	pd "github.com/tikv/pd/client"
+)
+
+type zoneRouterSuite struct{}
+
+var _ = check.Suite(&zoneRouterSuite{})
+
+// zoneMockPDClient labels the stores by their zones.
+type zoneMockPDClient struct {
+	pd.Client
+	zones map[uint64]string
+}
+
+func (m *zoneMockPDClient) GetStore(ctx context.Context, storeID uint64) (*metapb.Store, error) {
+	s, err := m.Client.GetStore(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	s.Labels = append(s.Labels, &metapb.StoreLabel{Key: "zone", Value: m.zones[storeID]})
+	return s, nil
+}
+
+func (s *zoneRouterSuite) TestRoute(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	_, cluster, pdClient, err := mocktikv.NewTiKVAndPDClient("")
+	c.Assert(err, check.IsNil)
+	pdClient = &zoneMockPDClient{Client: pdClient, zones: map[uint64]string{1: "az1", 2: "az2"}}
+	cluster.AddStore(1, "localhost:1")
+	cluster.AddStore(2, "localhost:2")
+	// the leader of the region is on store 1
+	cluster.Bootstrap(3, []uint64{1, 2}, []uint64{4, 5}, 4)
+
+	regionCache := tikv.NewRegionCache(pdClient)
+	defer regionCache.Close()
+	bo := tikv.NewBackoffer(ctx, tikvRequestMaxBackoff)
+	loc, err := regionCache.LocateKey(bo, []byte("a"))
+	c.Assert(err, check.IsNil)
+	leaderCtx, err := regionCache.GetTiKVRPCContext(bo, loc.Region, tidbkv.ReplicaReadLeader, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(getStoreID(leaderCtx), check.Equals, uint64(1))
+
+	// the leader is in the zone
+	router := newZoneRouter(pdClient, "zone", "az1")
+	c.Assert(router.route(ctx, regionCache, loc.Region, leaderCtx), check.IsNil)
+
+	router = newZoneRouter(pdClient, "zone", "az2")
+	rpcCtx := router.route(ctx, regionCache, loc.Region, leaderCtx)
+	c.Assert(rpcCtx, check.NotNil)
+	c.Assert(getStoreID(rpcCtx), check.Equals, uint64(2))
+
+	// the store rejected a follower request
+	router.markUnsupported(2)
+	c.Assert(router.route(ctx, regionCache, loc.Region, leaderCtx), check.IsNil)
+
+	// no store is in the zone
+	router = newZoneRouter(pdClient, "zone", "az3")
+	c.Assert(router.route(ctx, regionCache, loc.Region, leaderCtx), check.IsNil)
+}
@@ -19,6 +19,10 @@ import (
 	"github.com/pingcap/ticdc/cdc/puller"
 	"github.com/pingcap/ticdc/cdc/puller/sorter"
 	"github.com/pingcap/ticdc/cdc/sink"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/ticdc/cdc/sink/producer/kafka"
+	"github.com/pingcap/ticdc/pkg/diskmanager"
+	"github.com/pingcap/ticdc/pkg/util"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -31,8 +35,12 @@ func init() {
 	kv.InitMetrics(registry)
 	puller.InitMetrics(registry)
 	sink.InitMetrics(registry)
+	codec.InitMetrics(registry)
+	kafka.InitMetrics(registry)
 	entry.InitMetrics(registry)
 	sorter.InitMetrics(registry)
+	diskmanager.InitMetrics(registry)
+	util.InitMetrics(registry)
 	initProcessorMetrics(registry)
 	initOwnerMetrics(registry)
 	initServerMetrics(registry)
@@ -75,6 +75,13 @@ var (
 			Help:      "The time it took to update sub changefeed info.",
 			Buckets:   prometheus.ExponentialBuckets(0.001 /* 1 ms */, 2, 18),
 		}, []string{"capture"})
+	ddlQueueSizeGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "ticdc",
+			Subsystem: "processor",
+			Name:      "ddl_queue_size",
+			Help:      "The number of DDL events waiting for building schema snapshots",
+		}, []string{"changefeed", "capture"})
 	processorErrorCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "ticdc",
@@ -82,6 +89,13 @@ var (
 			Name:      "exit_with_error_count",
 			Help:      "counter for processor exits with error",
 		}, []string{"changefeed", "capture"})
+	resourceUsageGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "ticdc",
+			Subsystem: "processor",
+			Name:      "resource_usage",
+			Help:      "resources used by the changefeed on the capture",
+		}, []string{"changefeed", "capture", "resource"})
 )
 
 // initProcessorMetrics registers all metrics used in processor
@@ -94,5 +108,7 @@ func initProcessorMetrics(registry *prometheus.Registry) {
 	registry.MustRegister(syncTableNumGauge)
 	registry.MustRegister(txnCounter)
 	registry.MustRegister(updateInfoDuration)
+	registry.MustRegister(ddlQueueSizeGauge)
 	registry.MustRegister(processorErrorCounter)
+	registry.MustRegister(resourceUsageGauge)
 }
@@ -17,6 +17,7 @@ import (
 	"encoding/json"
 
 	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/pkg/diskmanager"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 )
 
@@ -24,6 +25,11 @@ import (
 type CaptureInfo struct {
 	ID            CaptureID `json:"id"`
 	AdvertiseAddr string    `json:"address"`
+	// Draining is set when the capture is shutting down gracefully, the owner
+	// moves its tables to the other captures and schedules no table to it.
+	Draining bool `json:"draining,omitempty"`
+	// DataDir is the result of the health checks of the data dir at startup.
+	DataDir *diskmanager.DirHealth `json:"data-dir,omitempty"`
 }
 
 // Marshal using json.Marshal.
@@ -18,6 +18,7 @@ import (
 	"math"
 	"regexp"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/pingcap/errors"
@@ -25,6 +26,7 @@ import (
 	"github.com/pingcap/ticdc/pkg/config"
 	"github.com/pingcap/ticdc/pkg/cyclic/mark"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/secret"
 	"github.com/pingcap/tidb/store/tikv/oracle"
 	"go.uber.org/zap"
 )
@@ -39,13 +41,29 @@ const (
 	SortUnified  SortEngine = "unified"
 )
 
-// FeedState represents the running state of a changefeed
+// DefaultSortDir is the directory of the sorter files if it's not specified,
+// the files of the sink spill and the quarantine are put in it as well.
+const DefaultSortDir = "/tmp/cdc_sort"
+
+// FeedState represents the running state of a changefeed, the legal
+// transitions between the states are defined in changefeed_state.go.
 type FeedState string
 
 // All FeedStates
 const (
-	StateNormal   FeedState = "normal"
-	StateFailed   FeedState = "failed"
+	// StateNormal means the changefeed is replicating.
+	StateNormal FeedState = "normal"
+	// StateWarning means the changefeed is replicating, but its processors
+	// report warnings, or it just recovered from an error.
+	StateWarning FeedState = "warning"
+	// StateError means the changefeed is not replicating because of an
+	// error, and the owner retries it automatically.
+	StateError FeedState = "error"
+	// StateFailed means the changefeed is stopped by an error the owner
+	// doesn't retry, it's resumed only by the user after the cause is fixed.
+	StateFailed FeedState = "failed"
+	// StateStopped means the changefeed is paused by the user without error,
+	// it's resumed only by the user.
 	StateStopped  FeedState = "stopped"
 	StateRemoved  FeedState = "removed"
 	StateFinished FeedState = "finished"
@@ -85,12 +103,61 @@ type ChangeFeedInfo struct {
 
 	SyncPointEnabled  bool          `json:"sync-point-enabled"`
 	SyncPointInterval time.Duration `json:"sync-point-interval"`
+
+	// TTL is the lifetime of the changefeed since it's created, the owner
+	// removes the changefeed after it expires. Zero means never expire.
+	TTL time.Duration `json:"ttl,omitempty"`
+	// Creator is the identity of the user creating the changefeed.
+	Creator string `json:"creator,omitempty"`
+	// ShadowOf is the ID of the primary changefeed if it's a shadow changefeed,
+	// which replicates the same tables to the shadow schemas for comparison.
+	ShadowOf ChangeFeedID `json:"shadow-of,omitempty"`
+	// ClusterID is the id of the upstream cluster the changefeed replicates
+	// from, the changefeed fails if the upstream cluster id changes.
+	ClusterID uint64 `json:"cluster-id,omitempty"`
+	// TableStartTs is the start ts of the tables added to the changefeed by
+	// widening the filter, keyed by "schema.table". The tables start from it
+	// instead of the checkpoint of the changefeed to avoid a large incremental
+	// scan.
+	TableStartTs map[string]uint64 `json:"table-start-ts,omitempty"`
+	// StateHistory are the latest transitions of the state, the oldest ones
+	// are dropped first.
+	StateHistory []*StateTransition `json:"state-history,omitempty"`
+	// SinkSwitch is the pending switch of the sink, nil if there is none.
+	SinkSwitch *SinkSwitch `json:"sink-switch,omitempty"`
+	// IgnoreSinkConflicts is set if the changefeed is created even though the
+	// other changefeeds write the same tables to the same downstream.
+	IgnoreSinkConflicts bool `json:"ignore-sink-conflicts,omitempty"`
+}
+
+// SinkSwitch is a switch of the sink of a running changefeed at a resolved ts
+// boundary. The resolved ts of the changefeed stops at Ts, and the changefeed
+// is restarted with the new sink once the current sink flushes the events
+// before Ts, so the new sink receives the events committed after Ts. The MQ
+// sinks send the events before they are resolved, so the current sink may
+// receive some events after Ts as well.
+type SinkSwitch struct {
+	SinkURI    string    `json:"sink-uri"`
+	Ts         uint64    `json:"ts"`
+	CreateTime time.Time `json:"create-time"`
+	// Creator is the identity of the user requesting the switch.
+	Creator string `json:"creator,omitempty"`
 }
 
-var changeFeedIDRe *regexp.Regexp = regexp.MustCompile(`^[a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*$`)
+// NamespaceSeparator separates the namespace and the name in the id of a
+// changefeed in a namespace, such as "team-a.simple-changefeed-task". The
+// namespace is a prefix of the keys of the changefeed in etcd, so the
+// changefeeds in different namespaces never collide.
+const NamespaceSeparator = "."
+
+var (
+	namespaceRe    *regexp.Regexp = regexp.MustCompile(`^[a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*$`)
+	changeFeedIDRe *regexp.Regexp = regexp.MustCompile(`^([a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*\.)?[a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*$`)
+)
 
 // ValidateChangefeedID returns true if the changefeed ID matches
-// the pattern "^[a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*$", eg, "simple-changefeed-task".
+// the pattern "^([a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*\.)?[a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*$",
+// eg, "simple-changefeed-task" or "team-a.simple-changefeed-task".
 func ValidateChangefeedID(changefeedID string) error {
 	if !changeFeedIDRe.MatchString(changefeedID) {
 		return cerror.ErrInvalidChangefeedID.GenWithStackByArgs()
@@ -98,6 +165,45 @@ func ValidateChangefeedID(changefeedID string) error {
 	return nil
 }
 
+// ValidateNamespace returns an error if the namespace doesn't match the
+// pattern "^[a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*$", eg, "team-a".
+func ValidateNamespace(namespace string) error {
+	if !namespaceRe.MatchString(namespace) {
+		return cerror.ErrInvalidNamespace.GenWithStackByArgs(namespace)
+	}
+	return nil
+}
+
+// SplitChangefeedID splits the id of the changefeed into the namespace and
+// the name, the namespace is empty if the changefeed isn't in a namespace.
+func SplitChangefeedID(id ChangeFeedID) (namespace, name string) {
+	if idx := strings.Index(id, NamespaceSeparator); idx >= 0 {
+		return id[:idx], id[idx+len(NamespaceSeparator):]
+	}
+	return "", id
+}
+
+// QualifyChangefeedID returns the id of the changefeed in the namespace, the
+// id is returned as is if the namespace is empty or the id is already in the
+// namespace.
+func QualifyChangefeedID(namespace string, id ChangeFeedID) (ChangeFeedID, error) {
+	if namespace == "" {
+		return id, nil
+	}
+	if err := ValidateNamespace(namespace); err != nil {
+		return "", err
+	}
+	ns, name := SplitChangefeedID(id)
+	if ns == "" {
+		return namespace + NamespaceSeparator + name, nil
+	}
+	if ns != namespace {
+		return "", cerror.ErrInvalidNamespace.GenWithStack(
+			"changefeed %s is not in namespace %s", id, namespace)
+	}
+	return id, nil
+}
+
 // String implements fmt.Stringer interface, but hide some sensitive information
 func (info *ChangeFeedInfo) String() (str string) {
 	var err error
@@ -112,7 +218,10 @@ func (info *ChangeFeedInfo) String() (str string) {
 		log.Error("failed to unmarshal changefeed info", zap.Error(err))
 		return
 	}
-	clone.SinkURI = "***"
+	clone.SinkURI = secret.RedactURI(clone.SinkURI)
+	if clone.SinkSwitch != nil {
+		clone.SinkSwitch.SinkURI = secret.RedactURI(clone.SinkSwitch.SinkURI)
+	}
 	str, err = clone.Marshal()
 	if err != nil {
 		log.Error("failed to marshal changefeed info", zap.Error(err))
@@ -145,6 +254,41 @@ func (info *ChangeFeedInfo) GetTargetTs() uint64 {
 	return uint64(math.MaxUint64)
 }
 
+// ApplySinkSwitch replaces the sink by the pending switch once the checkpoint
+// reaches the switch ts, it returns the replaced sink uri, or false if the
+// sink is not switched. The old value is enabled if the new sink needs it.
+func (info *ChangeFeedInfo) ApplySinkSwitch(checkpointTs uint64) (string, bool) {
+	if info.SinkSwitch == nil || checkpointTs < info.SinkSwitch.Ts {
+		return "", false
+	}
+	old := info.SinkURI
+	info.SinkURI = info.SinkSwitch.SinkURI
+	info.SinkSwitch = nil
+	// an invalid sink uri is reported when the sink is created
+	if info.Config != nil && !info.Config.EnableOldValue {
+		if needOldValue, err := SinkNeedsOldValue(info.SinkURI, info.Config); err == nil && needOldValue {
+			log.Warn("the new sink needs the old value, enable the old value of the changefeed",
+				zap.String("sink-uri", secret.RedactURI(info.SinkURI)))
+			info.Config.EnableOldValue = true
+		}
+	}
+	return old, true
+}
+
+// Expired returns whether the TTL of the changefeed is expired at the time.
+func (info *ChangeFeedInfo) Expired(now time.Time) bool {
+	return info.TTL > 0 && now.Sub(info.CreateTime) >= info.TTL
+}
+
+// StartTsOfTable returns the larger one of startTs and the start ts specified
+// for the table.
+func (info *ChangeFeedInfo) StartTsOfTable(table TableName, startTs uint64) uint64 {
+	if ts := info.TableStartTs[table.String()]; ts > startTs {
+		return ts
+	}
+	return startTs
+}
+
 // Marshal returns the json marshal format of a ChangeFeedInfo
 func (info *ChangeFeedInfo) Marshal() (string, error) {
 	data, err := json.Marshal(info)
@@ -170,6 +314,32 @@ func (info *ChangeFeedInfo) Unmarshal(data []byte) error {
 	return nil
 }
 
+// oldValueProtocols are the protocols of the MQ sinks sending the before-images
+// of the rows.
+var oldValueProtocols = map[string]struct{}{
+	"canal":      {},
+	"canal-json": {},
+	"maxwell":    {},
+	"avro":       {},
+}
+
+// SinkNeedsOldValue returns whether the sink of a changefeed needs the old
+// values of the rows, which are pulled from TiKV and mounted only for the
+// changefeeds enabling the old value. The sink uri is invalid if it can't be
+// parsed.
+func SinkNeedsOldValue(sinkURI string, cfg *config.ReplicaConfig) (bool, error) {
+	if cfg.Sink != nil && cfg.Sink.LogCompaction {
+		return true, nil
+	}
+	// the secrets referenced by the uri are not resolved
+	u, err := secret.ParseURI(sinkURI)
+	if err != nil {
+		return false, err
+	}
+	_, ok := oldValueProtocols[u.Query().Get("protocol")]
+	return ok, nil
+}
+
 // VerifyAndFix verifies changefeed info and may fillin some fields.
 // If a must field is not provided, return an error.
 // If some necessary filed is missing but can use a default value, fillin it.
@@ -178,6 +348,9 @@ func (info *ChangeFeedInfo) VerifyAndFix() error {
 	if info.Engine == "" {
 		info.Engine = SortUnified
 	}
+	if info.SortDir == "" {
+		info.SortDir = DefaultSortDir
+	}
 	if info.Config.Filter == nil {
 		info.Config.Filter = defaultConfig.Filter
 	}
@@ -193,6 +366,18 @@ func (info *ChangeFeedInfo) VerifyAndFix() error {
 	if info.Config.Scheduler == nil {
 		info.Config.Scheduler = defaultConfig.Scheduler
 	}
+	if info.Config.RateLimit == nil {
+		info.Config.RateLimit = defaultConfig.RateLimit
+	}
+	if info.Config.WorkerPool == nil {
+		info.Config.WorkerPool = defaultConfig.WorkerPool
+	}
+	if info.Config.TableSkew == nil {
+		info.Config.TableSkew = defaultConfig.TableSkew
+	}
+	if info.Config.FlowControl == nil {
+		info.Config.FlowControl = defaultConfig.FlowControl
+	}
 	return nil
 }
 
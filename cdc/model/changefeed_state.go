@@ -0,0 +1,111 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+// maxStateHistory is the max number of the state transitions kept in the
+// changefeed info.
+const maxStateHistory = 16
+
+// StateTransition records a transition of the state of a changefeed.
+type StateTransition struct {
+	From   FeedState `json:"from"`
+	To     FeedState `json:"to"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// legalTransitions are the states each state can transit to.
+var legalTransitions = map[FeedState][]FeedState{
+	StateNormal:   {StateWarning, StateError, StateFailed, StateStopped, StateFinished, StateRemoved},
+	StateWarning:  {StateNormal, StateError, StateFailed, StateStopped, StateFinished, StateRemoved},
+	StateError:    {StateNormal, StateWarning, StateFailed, StateStopped, StateRemoved},
+	StateFailed:   {StateNormal, StateStopped, StateRemoved},
+	StateStopped:  {StateNormal, StateRemoved},
+	StateFinished: {StateRemoved},
+	StateRemoved:  {},
+}
+
+// CanTransit returns whether the state can transit to the given state. The
+// empty state written by the old versions is treated as normal.
+func (s FeedState) CanTransit(to FeedState) bool {
+	if s == "" {
+		s = StateNormal
+	}
+	for _, state := range legalTransitions[s] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRunning returns whether the changefeed in the state is replicating.
+func (s FeedState) IsRunning() bool {
+	return s == "" || s == StateNormal || s == StateWarning
+}
+
+// GetState returns the state of the changefeed, the empty state written by
+// the old versions is treated as normal.
+func (info *ChangeFeedInfo) GetState() FeedState {
+	if info.State == "" {
+		return StateNormal
+	}
+	return info.State
+}
+
+// Transit changes the state of the changefeed and records the transition
+// with the reason. Transiting to the current state is a no-op, and an illegal
+// transition leaves the changefeed untouched.
+func (info *ChangeFeedInfo) Transit(to FeedState, reason string) error {
+	from := info.GetState()
+	if from == to {
+		return nil
+	}
+	if !from.CanTransit(to) {
+		return cerror.ErrChangefeedIllegalTransition.GenWithStackByArgs(from, to)
+	}
+	info.State = to
+	info.StateHistory = append(info.StateHistory, &StateTransition{
+		From:   from,
+		To:     to,
+		Time:   time.Now(),
+		Reason: reason,
+	})
+	if len(info.StateHistory) > maxStateHistory {
+		info.StateHistory = info.StateHistory[len(info.StateHistory)-maxStateHistory:]
+	}
+	return nil
+}
+
+// CollapseWarningHistory keeps only the latest round trip between the normal
+// and the warning states at the end of the state history, so the flapping
+// warnings don't push the other transitions out of the history.
+func (info *ChangeFeedInfo) CollapseWarningHistory() {
+	isWarningFlip := func(t *StateTransition) bool {
+		return (t.From == StateNormal && t.To == StateWarning) ||
+			(t.From == StateWarning && t.To == StateNormal)
+	}
+	n := len(info.StateHistory)
+	for n >= 3 && isWarningFlip(info.StateHistory[n-1]) &&
+		isWarningFlip(info.StateHistory[n-2]) && isWarningFlip(info.StateHistory[n-3]) {
+		info.StateHistory = append(info.StateHistory[:n-3], info.StateHistory[n-1])
+		n = len(info.StateHistory)
+	}
+}
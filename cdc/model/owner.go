@@ -14,9 +14,14 @@
 package model
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math"
+	"sync/atomic"
+	"time"
 
 	"github.com/pingcap/errors"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
@@ -83,6 +88,12 @@ type TaskPosition struct {
 	Count uint64 `json:"count"`
 	// Error code when error happens
 	Error *RunningError `json:"error"`
+	// Warnings are the risks found by the processor, which don't fail the
+	// changefeed, such as the divergences of the downstream tables.
+	Warnings []*RunningError `json:"warnings,omitempty"`
+	// Epoch identifies the processor writing the position, a processor
+	// started later has a larger epoch.
+	Epoch uint64 `json:"epoch,omitempty"`
 }
 
 // Marshal returns the json marshal format of a TaskStatus
@@ -104,6 +115,113 @@ func (tp *TaskPosition) String() string {
 	return data
 }
 
+// ProcessorRuntimeState is the runtime state a processor reports to the owner
+// directly, the fields not reported are left empty.
+type ProcessorRuntimeState struct {
+	ChangefeedID ChangeFeedID  `json:"changefeed-id"`
+	CaptureID    CaptureID     `json:"capture-id"`
+	Position     *TaskPosition `json:"position,omitempty"`
+	Workload     TaskWorkload  `json:"workload,omitempty"`
+	// SorterFreeBytes is the space the sorter of the capture can still write,
+	// it's reported with the workload and zero if it's unknown or used up.
+	SorterFreeBytes int64 `json:"sorter-free-bytes,omitempty"`
+	// Usage is the resources used by the changefeed on the capture, it's
+	// reported with the workload.
+	Usage *ResourceUsage `json:"usage,omitempty"`
+}
+
+// Marshal returns the json marshal format of a ProcessorRuntimeState
+func (s *ProcessorRuntimeState) Marshal() ([]byte, error) {
+	data, err := json.Marshal(s)
+	return data, cerror.WrapError(cerror.ErrMarshalFailed, err)
+}
+
+// Unmarshal unmarshals into *ProcessorRuntimeState from json marshal byte slice
+func (s *ProcessorRuntimeState) Unmarshal(data []byte) error {
+	err := json.Unmarshal(data, s)
+	return errors.Annotatef(
+		cerror.WrapError(cerror.ErrUnmarshalFailed, err), "Unmarshal data: %v", data)
+}
+
+// ResourceUsage is the resources used by a changefeed. The CPU time and the
+// sink bytes are accumulated since the processor is started, the memory and
+// the disk bytes are the current usage of the sorter.
+type ResourceUsage struct {
+	CPUSeconds      float64 `json:"cpu-seconds"`
+	MemoryBytes     int64   `json:"memory-bytes"`
+	SorterDiskBytes int64   `json:"sorter-disk-bytes"`
+	SinkBytes       int64   `json:"sink-bytes"`
+	// ThrottledSeconds is how long the CPU bound workers of the changefeed
+	// are paused since the changefeed uses more than its CPU shares.
+	ThrottledSeconds float64 `json:"throttled-seconds"`
+}
+
+// Add adds the usage of another processor to the usage.
+func (u *ResourceUsage) Add(other ResourceUsage) {
+	u.CPUSeconds += other.CPUSeconds
+	u.MemoryBytes += other.MemoryBytes
+	u.SorterDiskBytes += other.SorterDiskBytes
+	u.SinkBytes += other.SinkBytes
+	u.ThrottledSeconds += other.ThrottledSeconds
+}
+
+// TableTraffic is the traffic of a table replicated by a processor, the
+// counters are accumulated since the table is added.
+type TableTraffic struct {
+	TableID TableID `json:"table-id"`
+	Table   string  `json:"table"`
+	Rows    uint64  `json:"rows"`
+	Bytes   uint64  `json:"bytes"`
+	// Pipeline is the progress of the stages of the table, it's absent in the
+	// statistics of the earlier versions
+	Pipeline *TablePipeline `json:"pipeline,omitempty"`
+}
+
+// TablePipeline is the progress of a table through the puller, the sorter and
+// the sink of a processor. The times are zero until the stage handles the
+// first event.
+type TablePipeline struct {
+	// SorterBacklog is the number of the rows in the sorter
+	SorterBacklog uint64 `json:"sorter-backlog"`
+	// PulledResolvedTs is the max resolved ts sent to the sorter
+	PulledResolvedTs uint64 `json:"pulled-resolved-ts"`
+	// SortedResolvedTs is the max resolved ts output by the sorter
+	SortedResolvedTs uint64 `json:"sorted-resolved-ts"`
+	CheckpointTs     uint64 `json:"checkpoint-ts"`
+	// FlowControlUsage is the used ratio of the flow control window, the
+	// puller is blocked once it's larger than 1
+	FlowControlUsage float64   `json:"flow-control-usage"`
+	LastPulled       time.Time `json:"last-pulled"`
+	LastSorted       time.Time `json:"last-sorted"`
+	LastFlushed      time.Time `json:"last-flushed"`
+}
+
+// SinkError is the last error returned by the sink of a processor.
+type SinkError struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// ProcessorStats is the statistics of a processor served by its capture, the
+// rows and bytes are the sums of the counters of the current tables.
+type ProcessorStats struct {
+	ChangefeedID ChangeFeedID   `json:"changefeed-id"`
+	CaptureID    CaptureID      `json:"capture-id"`
+	Rows         uint64         `json:"rows"`
+	Bytes        uint64         `json:"bytes"`
+	Tables       []TableTraffic `json:"tables"`
+	// FlushLatencies are the latest durations of flushing the table sinks in seconds
+	FlushLatencies []float64 `json:"flush-latencies"`
+	// BarrierTs is the global resolved ts, the tables can't be replicated beyond it
+	BarrierTs    uint64 `json:"barrier-ts"`
+	CheckpointTs uint64 `json:"checkpoint-ts"`
+	ResolvedTs   uint64 `json:"resolved-ts"`
+	// Usage is the resources used by the processor
+	Usage ResourceUsage `json:"usage"`
+	// LastSinkError is nil if the sink never fails
+	LastSinkError *SinkError `json:"last-sink-error,omitempty"`
+}
+
 // MoveTableStatus represents for the status of a MoveTableJob
 type MoveTableStatus int
 
@@ -121,6 +239,10 @@ type MoveTableJob struct {
 	TableID          TableID
 	TableReplicaInfo *TableReplicaInfo
 	Status           MoveTableStatus
+	// Reason explains why the table is moved.
+	Reason string
+	// Pin pins the table to the target capture.
+	Pin bool
 }
 
 // All TableOperation status
@@ -169,6 +291,9 @@ type TaskWorkload map[TableID]WorkloadInfo
 // WorkloadInfo records the workload info of a table
 type WorkloadInfo struct {
 	Workload uint64 `json:"workload"`
+	// CatchingUp is set if the resolved ts of the table lags far behind, such
+	// as the table is in the incremental scan.
+	CatchingUp bool `json:"catching-up,omitempty"`
 }
 
 // Unmarshal unmarshals into *TaskWorkload from json marshal byte slice
@@ -191,6 +316,9 @@ func (w *TaskWorkload) Marshal() (string, error) {
 type TableReplicaInfo struct {
 	StartTs     Ts      `json:"start-ts"`
 	MarkTableID TableID `json:"mark-table-id"`
+	// Pinned tables are placed on the capture manually and not moved by the
+	// rebalance.
+	Pinned bool `json:"pinned,omitempty"`
 }
 
 // Clone clones a TableReplicaInfo
@@ -308,8 +436,67 @@ func (ts *TaskStatus) Marshal() (string, error) {
 	return string(data), cerror.WrapError(cerror.ErrMarshalFailed, err)
 }
 
+// taskStatusCompressThreshold is the size above which a marshaled task status
+// is compressed before it's written to etcd, so the task status of a capture
+// replicating a large number of tables fits in the request size limit of etcd.
+const taskStatusCompressThreshold = 256 * 1024
+
+// taskStatusCompression is whether the large task statuses are compressed,
+// it's off by default since the captures and the cli of the older versions
+// can't read the compressed task statuses.
+var taskStatusCompression int32
+
+// EnableTaskStatusCompression sets whether the task statuses larger than
+// 256KB are compressed before they're written to etcd. It should be enabled
+// only after all the captures and the cli of the cluster are upgraded to a
+// version reading the compressed task statuses, and disabled before any of
+// them are downgraded.
+func EnableTaskStatusCompression(enable bool) {
+	var v int32
+	if enable {
+		v = 1
+	}
+	atomic.StoreInt32(&taskStatusCompression, v)
+}
+
+// gzipMagic is the header of the gzip format, a JSON value never starts with it.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// MarshalCompact returns the json marshal format of a TaskStatus, which is
+// compressed by gzip if it's larger than taskStatusCompressThreshold and the
+// compression is enabled. It's the format stored in etcd, Unmarshal accepts
+// both the formats.
+func (ts *TaskStatus) MarshalCompact() (string, error) {
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return "", cerror.WrapError(cerror.ErrMarshalFailed, err)
+	}
+	if len(data) <= taskStatusCompressThreshold || atomic.LoadInt32(&taskStatusCompression) == 0 {
+		return string(data), nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return "", cerror.WrapError(cerror.ErrMarshalFailed, err)
+	}
+	if err := w.Close(); err != nil {
+		return "", cerror.WrapError(cerror.ErrMarshalFailed, err)
+	}
+	return buf.String(), nil
+}
+
 // Unmarshal unmarshals into *TaskStatus from json marshal byte slice
 func (ts *TaskStatus) Unmarshal(data []byte) error {
+	if bytes.HasPrefix(data, gzipMagic) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return cerror.WrapError(cerror.ErrUnmarshalFailed, err)
+		}
+		data, err = ioutil.ReadAll(r)
+		if err != nil {
+			return cerror.WrapError(cerror.ErrUnmarshalFailed, err)
+		}
+	}
 	err := json.Unmarshal(data, ts)
 	return errors.Annotatef(
 		cerror.WrapError(cerror.ErrUnmarshalFailed, err), "Unmarshal data: %v", data)
@@ -412,6 +599,46 @@ func (status *ChangeFeedStatus) Unmarshal(data []byte) error {
 		cerror.WrapError(cerror.ErrUnmarshalFailed, err), "Unmarshal data: %v", data)
 }
 
+// MaintenanceInfo records the cluster-wide maintenance mode, the changefeeds
+// paused by entering the mode are resumed by leaving it, the ones paused before
+// are kept paused.
+type MaintenanceInfo struct {
+	StartTime         time.Time      `json:"start-time"`
+	PausedChangefeeds []ChangeFeedID `json:"paused-changefeeds"`
+}
+
+// Marshal returns json encoded string of MaintenanceInfo
+func (info *MaintenanceInfo) Marshal() (string, error) {
+	data, err := json.Marshal(info)
+	return string(data), cerror.WrapError(cerror.ErrMarshalFailed, err)
+}
+
+// Unmarshal unmarshals into *MaintenanceInfo from json marshal byte slice
+func (info *MaintenanceInfo) Unmarshal(data []byte) error {
+	err := json.Unmarshal(data, info)
+	return errors.Annotatef(
+		cerror.WrapError(cerror.ErrUnmarshalFailed, err), "Unmarshal data: %v", data)
+}
+
+// ClusterQuota is the limits of the resources of the cluster, zero means no
+// limit.
+type ClusterQuota struct {
+	MaxChangefeeds int `json:"max-changefeeds"`
+}
+
+// Marshal returns json encoded string of ClusterQuota
+func (q *ClusterQuota) Marshal() (string, error) {
+	data, err := json.Marshal(q)
+	return string(data), cerror.WrapError(cerror.ErrMarshalFailed, err)
+}
+
+// Unmarshal unmarshals into *ClusterQuota from json marshal byte slice
+func (q *ClusterQuota) Unmarshal(data []byte) error {
+	err := json.Unmarshal(data, q)
+	return errors.Annotatef(
+		cerror.WrapError(cerror.ErrUnmarshalFailed, err), "Unmarshal data: %v", data)
+}
+
 // ProcInfoSnap holds most important replication information of a processor
 type ProcInfoSnap struct {
 	CfID      string                        `json:"changefeed-id"`
@@ -246,6 +246,39 @@ func (s *taskStatusSuite) TestTaskStatusMarshal(c *check.C) {
 	c.Assert(newStatus, check.DeepEquals, status)
 }
 
+func (s *taskStatusSuite) TestTaskStatusMarshalCompact(c *check.C) {
+	defer testleak.AfterTest(c)()
+	status := &TaskStatus{
+		Tables: map[TableID]*TableReplicaInfo{
+			1: {StartTs: 420875942036766723},
+		},
+	}
+	// the small status is not compressed
+	data, err := status.MarshalCompact()
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.Equals, status.String())
+
+	for i := TableID(2); i <= 100000; i++ {
+		status.AddTable(i, &TableReplicaInfo{StartTs: 420875942036766723}, 420875942036766723)
+	}
+	// the compression is disabled by default
+	data, err = status.MarshalCompact()
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.Equals, status.String())
+
+	EnableTaskStatusCompression(true)
+	defer EnableTaskStatusCompression(false)
+	data, err = status.MarshalCompact()
+	c.Assert(err, check.IsNil)
+	c.Assert(len(data), check.Less, taskStatusCompressThreshold*4)
+	c.Assert(len(data), check.Less, len(status.String())/4)
+
+	newStatus := &TaskStatus{}
+	err = newStatus.Unmarshal([]byte(data))
+	c.Assert(err, check.IsNil)
+	c.Assert(newStatus, check.DeepEquals, status)
+}
+
 func (s *taskStatusSuite) TestAddTable(c *check.C) {
 	defer testleak.AfterTest(c)()
 	ts := uint64(420875942036766723)
@@ -58,6 +58,9 @@ type TableInfo struct {
 	// HandleIndexTableIneligible(-2) : the table is not eligible
 	HandleIndexID int64
 
+	// IndexColumnsOffset is the offsets of the visible columns of each unique
+	// index, it's empty for a unique index without visible columns, such as a
+	// unique expression index.
 	IndexColumnsOffset [][]int
 	rowColInfos        []rowcodec.ColInfo
 	rowColFieldTps     map[int64]*types.FieldType
@@ -131,9 +134,7 @@ func WrapTableInfo(schemaID int64, schemaName string, version uint64, info *mode
 					indexColOffset = append(indexColOffset, ti.RowColumnsOffset[colInfo.ID])
 				}
 			}
-			if len(indexColOffset) > 0 {
-				ti.IndexColumnsOffset = append(ti.IndexColumnsOffset, indexColOffset)
-			}
+			ti.IndexColumnsOffset = append(ti.IndexColumnsOffset, indexColOffset)
 		}
 	}
 
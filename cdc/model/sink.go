@@ -16,9 +16,11 @@ package model
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/pingcap/log"
 	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/ticdc/pkg/quotes"
 	"github.com/pingcap/ticdc/pkg/util"
 	"go.uber.org/zap"
@@ -36,6 +38,8 @@ const (
 	MqMessageTypeDDL
 	// MqMessageTypeResolved is resolved type of message key
 	MqMessageTypeResolved
+	// MqMessageTypeHeartbeat is heartbeat type of message key, it's the resolved ts of a table
+	MqMessageTypeHeartbeat
 )
 
 // ColumnFlagType is for encapsulating the flag operations for different flags.
@@ -289,6 +293,10 @@ type Column struct {
 	Type  byte           `json:"type"`
 	Flag  ColumnFlagType `json:"flag"`
 	Value interface{}    `json:"value"`
+	// Charset and Collation are only set for the string columns, the values
+	// of the non-binary charsets are always encoded in UTF-8.
+	Charset   string `json:"charset,omitempty"`
+	Collation string `json:"collation,omitempty"`
 }
 
 // ColumnValueString returns the string representation of the column value
@@ -339,12 +347,18 @@ func ColumnValueString(c interface{}) string {
 type ColumnInfo struct {
 	Name string
 	Type byte
+	// Flag is the mysql column flag, such as PriKeyFlag and UnsignedFlag
+	Flag uint
+	// FieldType is the full type definition, such as `varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin`
+	FieldType string
 }
 
 // FromTiColumnInfo populates cdc's ColumnInfo from TiDB's model.ColumnInfo
 func (c *ColumnInfo) FromTiColumnInfo(tiColumnInfo *model.ColumnInfo) {
 	c.Type = tiColumnInfo.Tp
 	c.Name = tiColumnInfo.Name.O
+	c.Flag = tiColumnInfo.Flag
+	c.FieldType = tiColumnInfo.FieldType.String()
 }
 
 // SimpleTableInfo is the simplified table info passed to the sink
@@ -356,6 +370,9 @@ type SimpleTableInfo struct {
 	// table ID
 	TableID    int64
 	ColumnInfo []*ColumnInfo
+	// TableInfoVersion is the ts when the table schema is changed by the latest DDL,
+	// or the snapshot ts if the table schema is loaded from a snapshot.
+	TableInfoVersion uint64
 }
 
 // DDLEvent represents a DDL event
@@ -389,6 +406,7 @@ func (d *DDLEvent) FromJob(job *model.Job, preTableInfo *TableInfo) {
 
 		d.TableInfo.Table = tableName
 		d.TableInfo.TableID = job.TableID
+		d.TableInfo.TableInfoVersion = job.BinlogInfo.FinishedTS
 	}
 	d.fillPreTableInfo(preTableInfo)
 }
@@ -401,6 +419,7 @@ func (d *DDLEvent) fillPreTableInfo(preTableInfo *TableInfo) {
 	d.PreTableInfo.Schema = preTableInfo.TableName.Schema
 	d.PreTableInfo.Table = preTableInfo.TableName.Table
 	d.PreTableInfo.TableID = preTableInfo.ID
+	d.PreTableInfo.TableInfoVersion = preTableInfo.TableInfoVersion
 
 	d.PreTableInfo.ColumnInfo = make([]*ColumnInfo, len(preTableInfo.Columns))
 	for i, colInfo := range preTableInfo.Columns {
@@ -409,6 +428,61 @@ func (d *DDLEvent) fillPreTableInfo(preTableInfo *TableInfo) {
 	}
 }
 
+// NewBootstrapDDLEvent creates the bootstrap DDL event of a table, which tells
+// the consumers the schema of the table at ts before any rows of the table. Its
+// query is a CREATE TABLE IF NOT EXISTS statement with the visible columns and
+// the primary key of the table.
+func NewBootstrapDDLEvent(tableInfo *TableInfo, ts uint64) *DDLEvent {
+	d := &DDLEvent{
+		StartTs:  ts,
+		CommitTs: ts,
+		Type:     model.ActionCreateTable,
+		TableInfo: &SimpleTableInfo{
+			Schema:           tableInfo.TableName.Schema,
+			Table:            tableInfo.TableName.Table,
+			TableID:          tableInfo.ID,
+			TableInfoVersion: tableInfo.TableInfoVersion,
+		},
+	}
+	defs := make([]string, 0, len(tableInfo.Columns)+1)
+	for _, col := range tableInfo.Columns {
+		if !IsColCDCVisible(col) {
+			continue
+		}
+		colInfo := new(ColumnInfo)
+		colInfo.FromTiColumnInfo(col)
+		d.TableInfo.ColumnInfo = append(d.TableInfo.ColumnInfo, colInfo)
+		def := quotes.QuoteName(colInfo.Name) + " " + colInfo.FieldType
+		if mysql.HasNotNullFlag(col.Flag) {
+			def += " NOT NULL"
+		}
+		defs = append(defs, def)
+	}
+	var pkCols []string
+	if tableInfo.PKIsHandle {
+		for _, col := range tableInfo.Columns {
+			if mysql.HasPriKeyFlag(col.Flag) {
+				pkCols = append(pkCols, quotes.QuoteName(col.Name.O))
+			}
+		}
+	} else {
+		for _, idx := range tableInfo.Indices {
+			if !idx.Primary {
+				continue
+			}
+			for _, col := range idx.Columns {
+				pkCols = append(pkCols, quotes.QuoteName(col.Name.O))
+			}
+		}
+	}
+	if len(pkCols) > 0 {
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(pkCols, ",")+")")
+	}
+	d.Query = "CREATE TABLE IF NOT EXISTS " + quotes.QuoteSchema(d.TableInfo.Schema, d.TableInfo.Table) +
+		" (" + strings.Join(defs, ",") + ")"
+	return d
+}
+
 // SingleTableTxn represents a transaction which includes many row events in a single table
 type SingleTableTxn struct {
 	Table     *TableName
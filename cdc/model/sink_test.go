@@ -182,10 +182,12 @@ func (s *commonDataStructureSuite) TestFromTiColumnInfo(c *check.C) {
 	col := &ColumnInfo{}
 	col.FromTiColumnInfo(&timodel.ColumnInfo{
 		Name:      timodel.CIStr{O: "col1"},
-		FieldType: types.FieldType{Tp: 3},
+		FieldType: types.FieldType{Tp: 3, Flag: mysql.UnsignedFlag},
 	})
 	c.Assert(col.Name, check.Equals, "col1")
 	c.Assert(col.Type, check.Equals, uint8(3))
+	c.Assert(col.Flag, check.Equals, uint(mysql.UnsignedFlag))
+	c.Assert(col.FieldType, check.Matches, "int.*UNSIGNED")
 }
 
 func (s *commonDataStructureSuite) TestDDLEventFromJob(c *check.C) {
@@ -227,9 +229,50 @@ func (s *commonDataStructureSuite) TestDDLEventFromJob(c *check.C) {
 	event.FromJob(job, preTableInfo)
 	c.Assert(event.StartTs, check.Equals, uint64(420536581131337731))
 	c.Assert(event.TableInfo.TableID, check.Equals, int64(49))
+	c.Assert(event.TableInfo.TableInfoVersion, check.Equals, uint64(420536581196873729))
 	c.Assert(event.PreTableInfo.ColumnInfo, check.HasLen, 1)
 
 	event = &DDLEvent{}
 	event.FromJob(job, nil)
 	c.Assert(event.PreTableInfo, check.IsNil)
 }
+
+func (s *commonDataStructureSuite) TestNewBootstrapDDLEvent(c *check.C) {
+	defer testleak.AfterTest(c)()
+	tableInfo := WrapTableInfo(1, "test", 100, &timodel.TableInfo{
+		ID:         49,
+		Name:       timodel.CIStr{O: "t1"},
+		PKIsHandle: true,
+		Columns: []*timodel.ColumnInfo{
+			{ID: 1, Name: timodel.CIStr{O: "id"}, FieldType: types.FieldType{
+				Tp: mysql.TypeLong, Flen: 11, Flag: mysql.PriKeyFlag | mysql.NotNullFlag,
+			}, State: timodel.StatePublic},
+			{ID: 2, Name: timodel.CIStr{O: "a"}, FieldType: types.FieldType{
+				Tp: mysql.TypeVarchar, Flen: 20,
+			}, State: timodel.StatePublic},
+			{ID: 3, Name: timodel.CIStr{O: "b"}, FieldType: types.FieldType{
+				Tp: mysql.TypeLong, Flen: 11,
+			}, State: timodel.StatePublic, GeneratedExprString: "`id` + 1"},
+		},
+	})
+	event := NewBootstrapDDLEvent(tableInfo, 200)
+	c.Assert(event.StartTs, check.Equals, uint64(200))
+	c.Assert(event.CommitTs, check.Equals, uint64(200))
+	c.Assert(event.Type, check.Equals, timodel.ActionCreateTable)
+	c.Assert(event.TableInfo.Schema, check.Equals, "test")
+	c.Assert(event.TableInfo.Table, check.Equals, "t1")
+	c.Assert(event.TableInfo.TableID, check.Equals, int64(49))
+	// the virtual generated column is not visible
+	c.Assert(event.TableInfo.ColumnInfo, check.HasLen, 2)
+	c.Assert(event.Query, check.Matches,
+		"CREATE TABLE IF NOT EXISTS `test`.`t1` \\(`id` int\\(11\\) NOT NULL,`a` varchar\\(20\\).*,PRIMARY KEY \\(`id`\\)\\)")
+
+	tableInfo.PKIsHandle = false
+	tableInfo.Indices = []*timodel.IndexInfo{{
+		Name:    timodel.CIStr{O: "PRIMARY"},
+		Primary: true,
+		Columns: []*timodel.IndexColumn{{Name: timodel.CIStr{O: "a"}}, {Name: timodel.CIStr{O: "id"}}},
+	}}
+	event = NewBootstrapDDLEvent(tableInfo, 200)
+	c.Assert(event.Query, check.Matches, ".*,PRIMARY KEY \\(`a`,`id`\\)\\)")
+}
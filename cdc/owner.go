@@ -42,6 +42,7 @@ import (
 	"go.etcd.io/etcd/clientv3"
 	"go.etcd.io/etcd/clientv3/concurrency"
 	"go.etcd.io/etcd/mvcc"
+	"go.etcd.io/etcd/mvcc/mvccpb"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
 )
@@ -99,10 +100,13 @@ type Owner struct {
 
 	adminJobs     []model.AdminJob
 	adminJobsLock sync.Mutex
+	// sinkSwitchJobs are the requested sink switches, guarded by adminJobsLock
+	sinkSwitchJobs []*sinkSwitchJob
 
 	stepDown func(ctx context.Context) error
 
-	// gcTTL is the ttl of cdc gc safepoint ttl.
+	// gcTTL is the ttl of cdc gc safepoint ttl, it can be changed by reloading
+	// the server config, so it must be accessed atomically.
 	gcTTL int64
 	// last update gc safepoint time. zero time means has not updated or cleared
 	gcSafepointLastUpdate time.Time
@@ -110,6 +114,10 @@ type Owner struct {
 	lastFlushChangefeeds    time.Time
 	flushChangefeedInterval time.Duration
 	feedChangeNotifier      *notify.Notifier
+	// runtimeStates keeps the runtime states reported by the processors
+	runtimeStates *runtimeStateStore
+	// decisions keeps the recent decisions of the owner for explaining them
+	decisions *decisionLog
 }
 
 const (
@@ -117,6 +125,8 @@ const (
 	CDCServiceSafePointID = "ticdc"
 	// GCSafepointUpdateInterval is the minimual interval that CDC can update gc safepoint
 	GCSafepointUpdateInterval = time.Duration(2 * time.Second)
+	// minOwnerRunInterval is the minimal interval between two runs of the owner
+	minOwnerRunInterval = 100 * time.Millisecond
 )
 
 // NewOwner creates a new Owner instance
@@ -147,16 +157,24 @@ func NewOwner(
 		rebalanceTigger:         make(map[model.ChangeFeedID]bool),
 		manualScheduleCommand:   make(map[model.ChangeFeedID][]*model.MoveTableJob),
 		pdEndpoints:             endpoints,
-		cfRWriter:               cli,
+		cfRWriter:               newCachedChangeFeedRWriter(cli),
 		etcdClient:              cli,
 		gcTTL:                   gcTTL,
 		flushChangefeedInterval: flushChangefeedInterval,
 		feedChangeNotifier:      new(notify.Notifier),
+		runtimeStates:           newRuntimeStateStore(),
+		decisions:               newDecisionLog(maxOwnerDecisions),
 	}
 
 	return owner, nil
 }
 
+// setGcTTL changes the ttl of cdc gc safepoint, which takes effect in the
+// next update of the service gc safepoint.
+func (o *Owner) setGcTTL(gcTTL int64) {
+	atomic.StoreInt64(&o.gcTTL, gcTTL)
+}
+
 func (o *Owner) addCapture(info *model.CaptureInfo) {
 	o.l.Lock()
 	o.captures[info.ID] = info
@@ -166,11 +184,21 @@ func (o *Owner) addCapture(info *model.CaptureInfo) {
 	o.rebalanceMu.Unlock()
 }
 
+// updateCapture updates the info of an existing capture.
+func (o *Owner) updateCapture(info *model.CaptureInfo) {
+	o.l.Lock()
+	defer o.l.Unlock()
+	if _, ok := o.captures[info.ID]; ok {
+		o.captures[info.ID] = info
+	}
+}
+
 func (o *Owner) removeCapture(info *model.CaptureInfo) {
 	o.l.Lock()
 	defer o.l.Unlock()
 
 	delete(o.captures, info.ID)
+	o.runtimeStates.removeCapture(info.ID)
 
 	for _, feed := range o.changeFeeds {
 		task, ok := feed.taskStatus[info.ID]
@@ -225,13 +253,17 @@ func (o *Owner) addOrphanTable(cid model.CaptureID, tableID model.TableID, start
 	}
 }
 
+// newChangeFeed creates the changefeed found by the owner. The changefeeds are
+// checked for the sink conflicts with it if they are not nil.
 func (o *Owner) newChangeFeed(
 	ctx context.Context,
 	id model.ChangeFeedID,
 	processorsInfos model.ProcessorsInfos,
 	taskPositions map[string]*model.TaskPosition,
 	info *model.ChangeFeedInfo,
-	checkpointTs uint64) (cf *changeFeed, resultErr error) {
+	checkpointTs uint64,
+	changefeeds map[model.ChangeFeedID]*mvccpb.KeyValue,
+) (cf *changeFeed, resultErr error) {
 	log.Info("Find new changefeed", zap.Stringer("info", info),
 		zap.String("changefeed", id), zap.Uint64("checkpoint ts", checkpointTs))
 	if info.Config.CheckGCSafePoint {
@@ -357,14 +389,17 @@ func (o *Owner) newChangeFeed(
 					log.Info("ignore known table partition", zap.Int64("tid", tid), zap.Int64("partitionID", id), zap.Stringer("table", table), zap.Uint64("ts", ts))
 					continue
 				}
-				orphanTables[id] = checkpointTs
+				orphanTables[id] = info.StartTsOfTable(table, checkpointTs)
 			}
 		} else {
-			orphanTables[tid] = checkpointTs
+			orphanTables[tid] = info.StartTsOfTable(table, checkpointTs)
 		}
 
 		sinkTableInfo[j-1] = new(model.SimpleTableInfo)
 		sinkTableInfo[j-1].TableID = tid
+		sinkTableInfo[j-1].Schema = table.Schema
+		sinkTableInfo[j-1].Table = table.Table
+		sinkTableInfo[j-1].TableInfoVersion = tblInfo.TableInfoVersion
 		sinkTableInfo[j-1].ColumnInfo = make([]*model.ColumnInfo, len(tblInfo.Cols()))
 
 		for i, colInfo := range tblInfo.Cols() {
@@ -373,9 +408,26 @@ func (o *Owner) newChangeFeed(
 		}
 
 	}
+	// the changefeeds may be created without the cli, which checks the sink
+	// conflicts before
+	if changefeeds != nil && !info.IgnoreSinkConflicts {
+		names := make([]model.TableName, 0, len(tables))
+		for _, table := range tables {
+			names = append(names, table)
+		}
+		conflicts, err := FindSinkConflicts(changefeeds, id, info.SinkURI, names)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := SinkConflictError(conflicts); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
 	errCh := make(chan error, 1)
 
-	primarySink, err := sink.NewSink(ctx, id, info.SinkURI, filter, info.Config, info.Opts, errCh)
+	// the sink of the owner runs the monitors which are needed once per changefeed
+	sinkCtx := util.SetOwnerInCtx(util.PutChangefeedIDInCtx(ctx, id))
+	primarySink, err := sink.NewSink(sinkCtx, id, info.SinkURI, filter, info.Config, info.Opts, errCh)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -434,10 +486,11 @@ func (o *Owner) newChangeFeed(
 		updateResolvedTs:    true,
 		startTimer:          make(chan bool),
 		syncpointStore:      syncpointStore,
-		syncCancel:          nil,
 		taskStatus:          processorsInfos,
 		taskPositions:       taskPositions,
 		etcdCli:             o.etcdClient,
+		runtimeStates:       o.runtimeStates,
+		decisions:           o.decisions,
 		filter:              filter,
 		sink:                primarySink,
 		cyclicEnabled:       info.Config.Cyclic.IsEnabled(),
@@ -490,6 +543,7 @@ func (o *Owner) loadChangeFeeds(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
+		taskPositions = o.runtimeStates.mergePositions(changeFeedID, taskStatus, taskPositions)
 		if cf, exist := o.changeFeeds[changeFeedID]; exist {
 			cf.updateProcessorInfos(taskStatus, taskPositions)
 			for _, pos := range taskPositions {
@@ -509,7 +563,16 @@ func (o *Owner) loadChangeFeeds(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		if cfInfo.State == model.StateFailed {
+		// the running changefeeds are checked by checkClusterHealth
+		if cfInfo.Expired(time.Now()) {
+			if _, ok := o.stoppedFeeds[changeFeedID]; ok || cfInfo.GetState() == model.StateFailed {
+				if err := o.removeExpiredChangefeed(changeFeedID, cfInfo); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if cfInfo.GetState() == model.StateFailed {
 			if _, ok := o.failInitFeeds[changeFeedID]; ok {
 				continue
 			}
@@ -521,6 +584,13 @@ func (o *Owner) loadChangeFeeds(ctx context.Context) error {
 			log.Info("changefeed recovered from failure", zap.String("changefeed", changeFeedID))
 			delete(o.failInitFeeds, changeFeedID)
 		}
+		failed, err := o.verifyUpstreamClusterID(ctx, changeFeedID, cfInfo)
+		if err != nil {
+			return err
+		}
+		if failed {
+			continue
+		}
 		needSave, canInit := cfInfo.CheckErrorHistory()
 		if needSave {
 			err := o.etcdClient.SaveChangeFeedInfo(ctx, cfInfo, changeFeedID)
@@ -574,8 +644,13 @@ func (o *Owner) loadChangeFeeds(ctx context.Context) error {
 		}
 
 		checkpointTs := cfInfo.GetCheckpointTs(status)
+		// the sink conflicts are only checked before the changefeed is started
+		var changefeeds map[model.ChangeFeedID]*mvccpb.KeyValue
+		if status == nil {
+			changefeeds = details
+		}
 
-		newCf, err := o.newChangeFeed(ctx, changeFeedID, taskStatus, taskPositions, cfInfo, checkpointTs)
+		newCf, err := o.newChangeFeed(ctx, changeFeedID, taskStatus, taskPositions, cfInfo, checkpointTs, changefeeds)
 		if err != nil {
 			cfInfo.Error = &model.RunningError{
 				Addr:    util.CaptureAddrFromCtx(ctx),
@@ -587,7 +662,14 @@ func (o *Owner) loadChangeFeeds(ctx context.Context) error {
 			if filter.ChangefeedFastFailError(err) {
 				log.Error("create changefeed with fast fail error, mark changefeed as failed",
 					zap.Error(err), zap.String("changefeed", changeFeedID))
-				cfInfo.State = model.StateFailed
+				if err := cfInfo.Transit(model.StateFailed, "failed to create the changefeed with a fast fail error"); err != nil {
+					return err
+				}
+				o.decisions.record(&OwnerDecision{
+					Kind:         DecisionFailChangefeed,
+					ChangefeedID: changeFeedID,
+					Reason:       fmt.Sprintf("failed to create the changefeed with a fast fail error: %s", err),
+				})
 				err := o.etcdClient.SaveChangeFeedInfo(ctx, cfInfo, changeFeedID)
 				if err != nil {
 					return err
@@ -595,6 +677,9 @@ func (o *Owner) loadChangeFeeds(ctx context.Context) error {
 				continue
 			}
 
+			if err2 := cfInfo.Transit(model.StateError, "failed to create the changefeed"); err2 != nil {
+				return err2
+			}
 			err2 := o.etcdClient.SaveChangeFeedInfo(ctx, cfInfo, changeFeedID)
 			if err2 != nil {
 				return err2
@@ -613,11 +698,20 @@ func (o *Owner) loadChangeFeeds(ctx context.Context) error {
 			if err != nil {
 				return err
 			}
-			newCf.startSyncPointTicker(ctx, newCf.info.SyncPointInterval)
 		} else {
 			log.Info("syncpoint is off")
 		}
 
+		if cfInfo.GetState() == model.StateError {
+			// the error is kept until the changefeed runs without warnings
+			if err := cfInfo.Transit(model.StateWarning, "recovered from the error"); err != nil {
+				return err
+			}
+			if err := o.etcdClient.SaveChangeFeedInfo(ctx, cfInfo, changeFeedID); err != nil {
+				return err
+			}
+		}
+
 		o.changeFeeds[changeFeedID] = newCf
 		delete(o.stoppedFeeds, changeFeedID)
 	}
@@ -634,6 +728,42 @@ func (o *Owner) loadChangeFeeds(ctx context.Context) error {
 	return nil
 }
 
+// verifyUpstreamClusterID fails the changefeed if its upstream cluster id is
+// changed, such as PD is rebuilt or the data is restored into a new cluster,
+// since the checkpoint ts of the changefeed is meaningless in the new cluster.
+// The changefeeds not recording the cluster id adopt the current one.
+func (o *Owner) verifyUpstreamClusterID(
+	ctx context.Context, changeFeedID model.ChangeFeedID, cfInfo *model.ChangeFeedInfo,
+) (failed bool, err error) {
+	clusterID := o.pdClient.GetClusterID(ctx)
+	if cfInfo.ClusterID == clusterID {
+		return false, nil
+	}
+	if cfInfo.ClusterID == 0 {
+		cfInfo.ClusterID = clusterID
+		return false, errors.Trace(o.etcdClient.SaveChangeFeedInfo(ctx, cfInfo, changeFeedID))
+	}
+	changedErr := cerror.ErrUpstreamClusterIDChanged.GenWithStackByArgs(cfInfo.ClusterID, clusterID)
+	log.Error("the upstream cluster id is changed, mark changefeed as failed",
+		zap.String("changefeed", changeFeedID), zap.Uint64("cluster-id", cfInfo.ClusterID),
+		zap.Uint64("current-cluster-id", clusterID))
+	cfInfo.Error = &model.RunningError{
+		Addr:    util.CaptureAddrFromCtx(ctx),
+		Code:    string(cerror.ErrUpstreamClusterIDChanged.RFCCode()),
+		Message: changedErr.Error(),
+	}
+	cfInfo.ErrorHis = append(cfInfo.ErrorHis, time.Now().UnixNano()/1e6)
+	if err := cfInfo.Transit(model.StateFailed, "the upstream cluster id is changed"); err != nil {
+		return false, errors.Trace(err)
+	}
+	o.decisions.record(&OwnerDecision{
+		Kind:         DecisionFailChangefeed,
+		ChangefeedID: changeFeedID,
+		Reason:       changedErr.Error(),
+	})
+	return true, errors.Trace(o.etcdClient.SaveChangeFeedInfo(ctx, cfInfo, changeFeedID))
+}
+
 func (o *Owner) balanceTables(ctx context.Context) error {
 	rebalanceForAllChangefeed := false
 	o.rebalanceMu.Lock()
@@ -713,13 +843,14 @@ func (o *Owner) flushChangeFeedInfos(ctx context.Context) error {
 		}
 	}
 	if time.Since(o.gcSafepointLastUpdate) > GCSafepointUpdateInterval {
-		actual, err := o.pdClient.UpdateServiceGCSafePoint(ctx, CDCServiceSafePointID, o.gcTTL, minCheckpointTs)
+		gcTTL := atomic.LoadInt64(&o.gcTTL)
+		actual, err := o.pdClient.UpdateServiceGCSafePoint(ctx, CDCServiceSafePointID, gcTTL, minCheckpointTs)
 		if err != nil {
 			sinceLastUpdate := time.Since(o.gcSafepointLastUpdate)
 			log.Warn("failed to update service safe point", zap.Error(err),
 				zap.Duration("since-last-update", sinceLastUpdate))
 			// We do not throw an error unless updating GC safepoint has been failing for more than gcTTL.
-			if sinceLastUpdate >= time.Second*time.Duration(o.gcTTL) {
+			if sinceLastUpdate >= time.Second*time.Duration(gcTTL) {
 				return cerror.ErrUpdateServiceSafepointFailed.Wrap(err)
 			}
 		} else {
@@ -836,6 +967,7 @@ func (o *Owner) dispatchJob(ctx context.Context, job model.AdminJob) error {
 		o.stoppedFeeds[job.CfID] = cf.status
 	}
 	delete(o.changeFeeds, job.CfID)
+	o.runtimeStates.removeChangefeed(job.CfID)
 	return nil
 }
 
@@ -848,45 +980,58 @@ func (o *Owner) collectChangefeedInfo(ctx context.Context, cid model.ChangeFeedI
 	var ok bool
 	cf, ok = o.changeFeeds[cid]
 	if ok {
-		return cf, cf.status, cf.info.State, nil
+		return cf, cf.status, cf.info.GetState(), nil
 	}
-	feedState = model.StateNormal
 
 	var cfInfo *model.ChangeFeedInfo
 	cfInfo, err = o.etcdClient.GetChangeFeedInfo(ctx, cid)
 	if err != nil && cerror.ErrChangeFeedNotExists.NotEqual(err) {
 		return
 	}
-
 	status, _, err = o.etcdClient.GetChangeFeedStatus(ctx, cid)
-	if err != nil {
-		if cerror.ErrChangeFeedNotExists.Equal(err) {
-			// Only changefeed info exists and error field is not nil means
-			// the changefeed has met error, mark it as failed.
-			if cfInfo != nil && cfInfo.Error != nil {
+	feedState = deriveFeedState(cfInfo, status)
+	return
+}
+
+// deriveFeedState returns the state of the changefeed not running on the
+// owner by its info and status, either of them may be nil.
+func deriveFeedState(cfInfo *model.ChangeFeedInfo, status *model.ChangeFeedStatus) model.FeedState {
+	feedState := model.StateNormal
+	if cfInfo != nil {
+		feedState = cfInfo.GetState()
+		// the changefeeds met errors in the old versions don't persist the
+		// failed or error states
+		if len(cfInfo.StateHistory) == 0 && cfInfo.Error != nil && feedState.IsRunning() {
+			if cfInfo.AdminJobType == model.AdminStop {
 				feedState = model.StateFailed
+			} else {
+				feedState = model.StateError
 			}
 		}
-		return
+	}
+	if status == nil {
+		return feedState
 	}
 	switch status.AdminJobType {
-	case model.AdminNone, model.AdminResume:
-		if cfInfo != nil && cfInfo.Error != nil {
-			feedState = model.StateFailed
-		}
 	case model.AdminStop:
-		feedState = model.StateStopped
+		if feedState.IsRunning() {
+			feedState = model.StateStopped
+		}
 	case model.AdminRemove:
 		feedState = model.StateRemoved
 	case model.AdminFinish:
 		feedState = model.StateFinished
 	}
-	return
+	return feedState
 }
 
-func (o *Owner) checkClusterHealth(_ context.Context) error {
+func (o *Owner) checkClusterHealth(ctx context.Context) error {
+	now := time.Now()
 	// check whether a changefeed has finished by comparing checkpoint-ts and target-ts
 	for _, cf := range o.changeFeeds {
+		if err := o.updateWarningState(ctx, cf); err != nil {
+			return err
+		}
 		if cf.status.CheckpointTs == cf.info.GetTargetTs() {
 			log.Info("changefeed replication finished", zap.String("changefeed", cf.id), zap.Uint64("checkpointTs", cf.status.CheckpointTs))
 			err := o.EnqueueJob(model.AdminJob{
@@ -896,12 +1041,61 @@ func (o *Owner) checkClusterHealth(_ context.Context) error {
 			if err != nil {
 				return err
 			}
+		} else if cf.info.Expired(now) {
+			if err := o.removeExpiredChangefeed(cf.id, cf.info); err != nil {
+				return err
+			}
 		}
 	}
 	// TODO: check processor normal exited
 	return nil
 }
 
+// warningClearTicks is the number of the consecutive owner ticks without any
+// warnings before a changefeed in the warning state returns to normal, so a
+// flapping warning doesn't change the state on every tick.
+const warningClearTicks = 300
+
+// updateWarningState moves the running changefeed between the normal and the
+// warning states by the warnings reported by its processors. The changefeed
+// enters the warning state at once, and returns to normal only after no
+// warnings are reported for warningClearTicks ticks.
+func (o *Owner) updateWarningState(ctx context.Context, cf *changeFeed) error {
+	warnings := collectWarnings(cf.taskPositions)
+	if len(warnings) > 0 {
+		cf.cleanTicks = 0
+	} else {
+		cf.cleanTicks++
+	}
+	state := cf.info.GetState()
+	switch {
+	case state == model.StateNormal && len(warnings) > 0:
+		if err := cf.info.Transit(model.StateWarning, warnings[0].Message); err != nil {
+			return err
+		}
+	case state == model.StateWarning && cf.cleanTicks >= warningClearTicks:
+		if err := cf.info.Transit(model.StateNormal, "no warnings are reported"); err != nil {
+			return err
+		}
+		cf.info.Error = nil
+	default:
+		return nil
+	}
+	cf.info.CollapseWarningHistory()
+	return errors.Trace(o.etcdClient.SaveChangeFeedInfo(ctx, cf.info, cf.id))
+}
+
+// removeExpiredChangefeed removes the changefeed whose TTL is expired, so the
+// GC safepoint is not held by it anymore.
+func (o *Owner) removeExpiredChangefeed(id model.ChangeFeedID, info *model.ChangeFeedInfo) error {
+	log.Info("changefeed expired, remove it", zap.String("changefeed", id),
+		zap.Time("createTime", info.CreateTime), zap.Duration("ttl", info.TTL))
+	return o.EnqueueJob(model.AdminJob{
+		CfID: id,
+		Type: model.AdminRemove,
+	})
+}
+
 func (o *Owner) handleAdminJob(ctx context.Context) error {
 	removeIdx := 0
 	o.adminJobsLock.Lock()
@@ -918,9 +1112,9 @@ func (o *Owner) handleAdminJob(ctx context.Context) error {
 			if cerror.ErrChangeFeedNotExists.NotEqual(err) {
 				return err
 			}
-			if feedState == model.StateFailed && job.Type == model.AdminRemove {
-				// changefeed in failed state, but changefeed status has not
-				// been created yet. Try to remove changefeed info only.
+			if (feedState == model.StateFailed || feedState == model.StateError) && job.Type == model.AdminRemove {
+				// changefeed in failed or error state, but changefeed status
+				// has not been created yet. Try to remove changefeed info only.
 				err := o.etcdClient.DeleteChangeFeedInfo(ctx, job.CfID)
 				if err != nil {
 					return errors.Trace(err)
@@ -948,10 +1142,26 @@ func (o *Owner) handleAdminJob(ctx context.Context) error {
 				continue
 			}
 
+			to, reason := model.StateStopped, "paused by the user"
+			if job.Error != nil {
+				to = model.StateFailed
+				reason = fmt.Sprintf("stopped by an error reported by %s, code: %s", job.Error.Addr, job.Error.Code)
+			}
+			if err := cf.info.Transit(to, reason); err != nil {
+				log.Warn("reject the admin job, the state of the changefeed can't be changed",
+					zap.String("changefeed", job.CfID), zap.Stringer("type", job.Type), zap.Error(err))
+				continue
+			}
 			cf.info.AdminJobType = model.AdminStop
 			cf.info.Error = job.Error
 			if job.Error != nil {
 				cf.info.ErrorHis = append(cf.info.ErrorHis, time.Now().UnixNano()/1e6)
+				o.decisions.record(&OwnerDecision{
+					Kind:         DecisionFailChangefeed,
+					ChangefeedID: job.CfID,
+					Reason: fmt.Sprintf("stopped by an error reported by %s, code: %s, message: %s",
+						job.Error.Addr, job.Error.Code, job.Error.Message),
+				})
 			}
 
 			err := o.etcdClient.SaveChangeFeedInfo(ctx, cf.info, job.CfID)
@@ -963,10 +1173,8 @@ func (o *Owner) handleAdminJob(ctx context.Context) error {
 			if err != nil {
 				return errors.Trace(err)
 			}
-			cf.stopSyncPointTicker()
 		case model.AdminRemove, model.AdminFinish:
 			if cf != nil {
-				cf.stopSyncPointTicker()
 				err := o.dispatchJob(ctx, job)
 				if err != nil {
 					return errors.Trace(err)
@@ -984,8 +1192,8 @@ func (o *Owner) handleAdminJob(ctx context.Context) error {
 						log.Info("changefeed has been removed or finished, remove command will do nothing")
 					}
 					continue
-				case model.StateStopped, model.StateFailed:
-					// remove a paused or failed changefeed
+				case model.StateStopped, model.StateFailed, model.StateError:
+					// remove a paused, failed or retrying changefeed
 					status.AdminJobType = model.AdminRemove
 					err = o.etcdClient.PutChangeFeedStatus(ctx, job.CfID, status)
 					if err != nil {
@@ -1028,20 +1236,12 @@ func (o *Owner) handleAdminJob(ctx context.Context) error {
 			if err != nil {
 				return errors.Trace(err)
 			}
-
-			// set admin job in changefeed status to tell owner resume changefeed
-			status.AdminJobType = model.AdminResume
-			err = o.etcdClient.PutChangeFeedStatus(ctx, job.CfID, status)
-			if err != nil {
-				return errors.Trace(err)
+			err = o.resumeChangefeed(ctx, job.CfID, status, cfInfo, "resumed by the user")
+			if cerror.ErrChangefeedIllegalTransition.Equal(err) {
+				log.Warn("reject the admin job, the state of the changefeed can't be changed",
+					zap.String("changefeed", job.CfID), zap.Stringer("type", job.Type), zap.Error(err))
+				continue
 			}
-
-			// set admin job in changefeed cfInfo to trigger each capture's changefeed list watch event
-			cfInfo.AdminJobType = model.AdminResume
-			// clear last running error
-			cfInfo.State = model.StateNormal
-			cfInfo.Error = nil
-			err = o.etcdClient.SaveChangeFeedInfo(ctx, cfInfo, job.CfID)
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -1059,6 +1259,29 @@ func (o *Owner) handleAdminJob(ctx context.Context) error {
 	return nil
 }
 
+// resumeChangefeed resumes a stopped changefeed, the changefeed is started
+// again by loadChangeFeeds. It returns ErrChangefeedIllegalTransition without
+// changing anything if the changefeed can't be resumed from its state.
+func (o *Owner) resumeChangefeed(
+	ctx context.Context, id model.ChangeFeedID, status *model.ChangeFeedStatus, cfInfo *model.ChangeFeedInfo, reason string,
+) error {
+	if err := cfInfo.Transit(model.StateNormal, reason); err != nil {
+		return errors.Trace(err)
+	}
+	// set admin job in changefeed status to tell owner resume changefeed
+	status.AdminJobType = model.AdminResume
+	err := o.etcdClient.PutChangeFeedStatus(ctx, id, status)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	// set admin job in changefeed cfInfo to trigger each capture's changefeed list watch event
+	cfInfo.AdminJobType = model.AdminResume
+	// clear last running error
+	cfInfo.Error = nil
+	return errors.Trace(o.etcdClient.SaveChangeFeedInfo(ctx, cfInfo, id))
+}
+
 func (o *Owner) throne(ctx context.Context) error {
 	// Start a routine to keep watching on the liveness of
 	// captures.
@@ -1118,6 +1341,7 @@ func (o *Owner) Run(ctx context.Context, tickTime time.Duration) error {
 	o.watchFeedChange(ctx1)
 
 	ownership := newOwnersip(tickTime)
+	var lastRunTime time.Time
 loop:
 	for {
 		select {
@@ -1133,6 +1357,17 @@ loop:
 			ownership.inc()
 		}
 
+		// coalesce the position changes of the processors, which wake up the
+		// owner much more frequently than needed in a large cluster
+		if wait := minOwnerRunInterval - time.Since(lastRunTime); wait > 0 {
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+				break loop
+			case <-time.After(wait):
+			}
+		}
+		lastRunTime = time.Now()
 		err = o.run(ctx)
 		if err != nil {
 			if errors.Cause(err) != context.Canceled {
@@ -1254,6 +1489,11 @@ func (o *Owner) run(ctx context.Context) error {
 		return errors.Trace(err)
 	}
 
+	err = o.handleSinkSwitch(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
 	err = o.calcResolvedTs(ctx)
 	if err != nil {
 		return errors.Trace(err)
@@ -1295,13 +1535,16 @@ func (o *Owner) TriggerRebalance(changefeedID model.ChangeFeedID) {
 	// TODO(leoppro) throw an error if the changefeed is not exist
 }
 
-// ManualSchedule moves the table from a capture to another capture
-func (o *Owner) ManualSchedule(changefeedID model.ChangeFeedID, to model.CaptureID, tableID model.TableID) {
+// ManualSchedule moves the table from a capture to another capture, the table
+// is pinned to the target capture if pin is true, or unpinned otherwise.
+func (o *Owner) ManualSchedule(changefeedID model.ChangeFeedID, to model.CaptureID, tableID model.TableID, pin bool) {
 	o.rebalanceMu.Lock()
 	defer o.rebalanceMu.Unlock()
 	o.manualScheduleCommand[changefeedID] = append(o.manualScheduleCommand[changefeedID], &model.MoveTableJob{
 		To:      to,
 		TableID: tableID,
+		Reason:  "moved manually",
+		Pin:     pin,
 	})
 }
 
@@ -1463,12 +1706,17 @@ func (o *Owner) watchCapture(ctx context.Context) error {
 					zap.String("capture", c.AdvertiseAddr))
 				o.removeCapture(c)
 			case clientv3.EventTypePut:
-				if !ev.IsCreate() {
-					continue
-				}
 				if err := c.Unmarshal(ev.Kv.Value); err != nil {
 					return errors.Trace(err)
 				}
+				if !ev.IsCreate() {
+					log.Info("update capture",
+						zap.String("capture-id", c.ID),
+						zap.String("capture", c.AdvertiseAddr),
+						zap.Bool("draining", c.Draining))
+					o.updateCapture(c)
+					continue
+				}
 				log.Info("add capture",
 					zap.String("capture-id", c.ID),
 					zap.String("capture", c.AdvertiseAddr))
@@ -0,0 +1,176 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/ticdc/cdc/model"
+)
+
+// OwnerDecisionsAPI returns the recent decisions of the owner, the decisions
+// of a changefeed are returned if cf-id is specified.
+const OwnerDecisionsAPI = "/capture/owner/decisions"
+
+// maxOwnerDecisions is the number of the decisions kept by the owner, the
+// oldest ones are dropped first.
+const maxOwnerDecisions = 256
+
+// The kinds of the owner decisions.
+const (
+	// DecisionMoveTable moves a table from a capture to another one.
+	DecisionMoveTable = "move-table"
+	// DecisionOrphanTable re-dispatches a table since the target capture of
+	// its move job is gone.
+	DecisionOrphanTable = "orphan-table"
+	// DecisionFailChangefeed stops a changefeed with an error.
+	DecisionFailChangefeed = "fail-changefeed"
+	// DecisionSwitchSink accepts or applies a switch of the sink.
+	DecisionSwitchSink = "switch-sink"
+	// DecisionRejectSinkSwitch rejects a switch of the sink.
+	DecisionRejectSinkSwitch = "reject-sink-switch"
+)
+
+// The sources of the barrier ts of a changefeed, which is the ts the global
+// resolved ts can't exceed.
+const (
+	BarrierTargetTs           = "target-ts"
+	BarrierProcessor          = "processor-resolved-ts"
+	BarrierUnappliedOperation = "unapplied-operation"
+	BarrierOrphanTable        = "orphan-table"
+	BarrierCleaningTable      = "cleaning-table"
+	BarrierDDLPuller          = "ddl-resolved-ts"
+	BarrierSyncpoint          = "syncpoint"
+	BarrierDDLJob             = "ddl-job"
+	BarrierSinkSwitch         = "sink-switch"
+)
+
+// OwnerDecision is a decision made by the owner and the reason of it.
+type OwnerDecision struct {
+	Time         time.Time          `json:"time"`
+	Kind         string             `json:"kind"`
+	ChangefeedID model.ChangeFeedID `json:"changefeed-id"`
+	TableID      model.TableID      `json:"table-id,omitempty"`
+	From         model.CaptureID    `json:"from,omitempty"`
+	To           model.CaptureID    `json:"to,omitempty"`
+	Reason       string             `json:"reason"`
+}
+
+// BarrierExplanation explains where the barrier ts of a changefeed comes from.
+type BarrierExplanation struct {
+	Time    time.Time `json:"time"`
+	Ts      uint64    `json:"ts"`
+	Source  string    `json:"source"`
+	Capture string    `json:"capture,omitempty"`
+	TableID int64     `json:"table-id,omitempty"`
+	// DDLJobID is the id of the DDL job blocking the changefeed.
+	DDLJobID int64 `json:"ddl-job-id,omitempty"`
+	// Holding is true if the resolved ts is held at a syncpoint until the
+	// syncpoint is recorded.
+	Holding bool `json:"holding,omitempty"`
+}
+
+// OwnerDecisionsResp is the response of OwnerDecisionsAPI.
+type OwnerDecisionsResp struct {
+	Decisions []*OwnerDecision                           `json:"decisions"`
+	Barriers  map[model.ChangeFeedID]*BarrierExplanation `json:"barriers"`
+}
+
+// decisionLog keeps the recent decisions of the owner in memory, they are
+// lost when the owner changes.
+type decisionLog struct {
+	mu        sync.Mutex
+	decisions []*OwnerDecision
+	// next is the position of the next decision once the log is full
+	next     int
+	capacity int
+	barriers map[model.ChangeFeedID]*BarrierExplanation
+}
+
+func newDecisionLog(capacity int) *decisionLog {
+	return &decisionLog{
+		capacity: capacity,
+		barriers: make(map[model.ChangeFeedID]*BarrierExplanation),
+	}
+}
+
+// record appends a decision to the log, the time is filled if it's zero.
+func (l *decisionLog) record(d *OwnerDecision) {
+	if l == nil {
+		return
+	}
+	if d.Time.IsZero() {
+		d.Time = time.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.decisions) < l.capacity {
+		l.decisions = append(l.decisions, d)
+		return
+	}
+	l.decisions[l.next] = d
+	l.next = (l.next + 1) % l.capacity
+}
+
+// setBarrier updates the barrier explanation of the changefeed.
+func (l *decisionLog) setBarrier(changefeedID model.ChangeFeedID, b *BarrierExplanation) {
+	if l == nil {
+		return
+	}
+	if b.Time.IsZero() {
+		b.Time = time.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.barriers[changefeedID] = b
+}
+
+// removeChangefeed drops the barrier explanation of a closed changefeed, its
+// decisions are kept.
+func (l *decisionLog) removeChangefeed(changefeedID model.ChangeFeedID) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.barriers, changefeedID)
+}
+
+// explain returns the decisions from the oldest to the latest and the barrier
+// explanations, of all the changefeeds if changefeedID is empty.
+func (l *decisionLog) explain(changefeedID model.ChangeFeedID) *OwnerDecisionsResp {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	resp := &OwnerDecisionsResp{
+		Decisions: make([]*OwnerDecision, 0, len(l.decisions)),
+		Barriers:  make(map[model.ChangeFeedID]*BarrierExplanation),
+	}
+	for i := range l.decisions {
+		d := l.decisions[(l.next+i)%len(l.decisions)]
+		if changefeedID != "" && d.ChangefeedID != changefeedID {
+			continue
+		}
+		clone := *d
+		resp.Decisions = append(resp.Decisions, &clone)
+	}
+	for id, b := range l.barriers {
+		if changefeedID != "" && id != changefeedID {
+			continue
+		}
+		clone := *b
+		resp.Barriers[id] = &clone
+	}
+	return resp
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"fmt"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type decisionLogSuite struct{}
+
+var _ = check.Suite(&decisionLogSuite{})
+
+func (s *decisionLogSuite) TestDecisionLog(c *check.C) {
+	defer testleak.AfterTest(c)()
+	l := newDecisionLog(3)
+	for i := 0; i < 5; i++ {
+		l.record(&OwnerDecision{
+			Kind:         DecisionMoveTable,
+			ChangefeedID: fmt.Sprintf("cf-%d", i%2),
+			TableID:      int64(i),
+			Reason:       "moved manually",
+		})
+	}
+	l.setBarrier("cf-0", &BarrierExplanation{Ts: 100, Source: BarrierProcessor, Capture: "capture-1"})
+	l.setBarrier("cf-1", &BarrierExplanation{Ts: 200, Source: BarrierDDLJob, DDLJobID: 10})
+
+	// the oldest decisions are dropped
+	resp := l.explain("")
+	c.Assert(resp.Decisions, check.HasLen, 3)
+	for i, d := range resp.Decisions {
+		c.Assert(d.TableID, check.Equals, int64(i+2))
+		c.Assert(d.Time.IsZero(), check.IsFalse)
+	}
+	c.Assert(resp.Barriers, check.HasLen, 2)
+
+	resp = l.explain("cf-0")
+	c.Assert(resp.Decisions, check.HasLen, 2)
+	c.Assert(resp.Decisions[0].TableID, check.Equals, int64(2))
+	c.Assert(resp.Decisions[1].TableID, check.Equals, int64(4))
+	c.Assert(resp.Barriers, check.HasLen, 1)
+	c.Assert(resp.Barriers["cf-0"].Source, check.Equals, BarrierProcessor)
+	c.Assert(resp.Barriers["cf-0"].Capture, check.Equals, "capture-1")
+
+	l.removeChangefeed("cf-0")
+	resp = l.explain("cf-0")
+	c.Assert(resp.Decisions, check.HasLen, 2)
+	c.Assert(resp.Barriers, check.HasLen, 0)
+
+	// the changefeeds created without the owner don't record decisions
+	var nilLog *decisionLog
+	nilLog.record(&OwnerDecision{Kind: DecisionFailChangefeed})
+	nilLog.setBarrier("cf-0", &BarrierExplanation{})
+}
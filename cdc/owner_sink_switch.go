@@ -0,0 +1,145 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/secret"
+	"go.uber.org/zap"
+)
+
+// SinkSwitchAPI switches the sink of a running changefeed at a resolved ts
+// boundary.
+const SinkSwitchAPI = "/capture/owner/changefeed/switch_sink"
+
+// sinkSwitchJob requests to switch the sink of a changefeed.
+type sinkSwitchJob struct {
+	id         model.ChangeFeedID
+	sinkSwitch *model.SinkSwitch
+}
+
+// SwitchSink requests to switch the sink of the running changefeed, the zero
+// ts of the switch means the current resolved ts of the changefeed. The
+// request is checked and accepted by the owner asynchronously, the accepted
+// switch is saved in the changefeed info.
+func (o *Owner) SwitchSink(id model.ChangeFeedID, sinkSwitch *model.SinkSwitch) {
+	o.adminJobsLock.Lock()
+	defer o.adminJobsLock.Unlock()
+	o.sinkSwitchJobs = append(o.sinkSwitchJobs, &sinkSwitchJob{id: id, sinkSwitch: sinkSwitch})
+}
+
+// handleSinkSwitch accepts the requested sink switches, and switches the sinks
+// of the changefeeds whose checkpoints reach the switch ts.
+func (o *Owner) handleSinkSwitch(ctx context.Context) error {
+	o.adminJobsLock.Lock()
+	jobs := o.sinkSwitchJobs
+	o.sinkSwitchJobs = nil
+	o.adminJobsLock.Unlock()
+	for _, job := range jobs {
+		if err := o.acceptSinkSwitch(ctx, job); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for _, cf := range o.changeFeeds {
+		if cf.info.SinkSwitch == nil || cf.status.CheckpointTs < cf.info.SinkSwitch.Ts {
+			continue
+		}
+		if err := o.switchSink(ctx, cf); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func (o *Owner) acceptSinkSwitch(ctx context.Context, job *sinkSwitchJob) error {
+	reject := func(reason string) {
+		log.Warn("sink switch is rejected", zap.String("changefeed", job.id), zap.String("reason", reason))
+		o.decisions.record(&OwnerDecision{
+			Kind:         DecisionRejectSinkSwitch,
+			ChangefeedID: job.id,
+			Reason:       reason,
+		})
+	}
+	cf, ok := o.changeFeeds[job.id]
+	if !ok {
+		reject("the changefeed is not running")
+		return nil
+	}
+	if cf.info.SinkSwitch != nil {
+		reject(fmt.Sprintf("the sink is being switched at %d", cf.info.SinkSwitch.Ts))
+		return nil
+	}
+	sinkSwitch := job.sinkSwitch
+	if sinkSwitch.Ts == 0 {
+		sinkSwitch.Ts = cf.status.ResolvedTs
+	}
+	// the events before the resolved ts may be sent to the current sink
+	if sinkSwitch.Ts < cf.status.ResolvedTs {
+		reject(fmt.Sprintf("the switch ts %d is less than the resolved ts %d", sinkSwitch.Ts, cf.status.ResolvedTs))
+		return nil
+	}
+	if sinkSwitch.Ts >= cf.info.GetTargetTs() {
+		reject(fmt.Sprintf("the switch ts %d is not less than the target ts %d", sinkSwitch.Ts, cf.info.TargetTs))
+		return nil
+	}
+	cf.info.SinkSwitch = sinkSwitch
+	if err := o.etcdClient.SaveChangeFeedInfo(ctx, cf.info, job.id); err != nil {
+		cf.info.SinkSwitch = nil
+		return errors.Trace(err)
+	}
+	log.Info("sink switch is accepted", zap.String("changefeed", job.id),
+		zap.String("sinkURI", secret.RedactURI(sinkSwitch.SinkURI)), zap.Uint64("ts", sinkSwitch.Ts))
+	o.decisions.record(&OwnerDecision{
+		Kind:         DecisionSwitchSink,
+		ChangefeedID: job.id,
+		Reason: fmt.Sprintf("the sink will be switched to %s at %d",
+			secret.RedactURI(sinkSwitch.SinkURI), sinkSwitch.Ts),
+	})
+	return nil
+}
+
+// switchSink stops the changefeed to flush and close the current sink, and
+// resumes it with the new sink from the switch ts. The resumed changefeed is
+// not started until the processors of the stopped one are cleaned, which
+// close their sinks before.
+func (o *Owner) switchSink(ctx context.Context, cf *changeFeed) error {
+	id, sinkSwitch := cf.id, cf.info.SinkSwitch
+	if err := cf.info.Transit(model.StateStopped, fmt.Sprintf("stopped to switch the sink at %d", sinkSwitch.Ts)); err != nil {
+		log.Warn("failed to change the state of the changefeed", zap.String("changefeed", id), zap.Error(err))
+	}
+	cf.info.AdminJobType = model.AdminStop
+	if err := o.etcdClient.SaveChangeFeedInfo(ctx, cf.info, id); err != nil {
+		return errors.Trace(err)
+	}
+	if err := o.dispatchJob(ctx, model.AdminJob{CfID: id, Type: model.AdminStop}); err != nil {
+		return errors.Trace(err)
+	}
+	old, _ := cf.info.ApplySinkSwitch(cf.status.CheckpointTs)
+	log.Info("sink is switched", zap.String("changefeed", id),
+		zap.String("from", secret.RedactURI(old)), zap.String("to", secret.RedactURI(cf.info.SinkURI)),
+		zap.Uint64("checkpointTs", cf.status.CheckpointTs))
+	o.decisions.record(&OwnerDecision{
+		Kind:         DecisionSwitchSink,
+		ChangefeedID: id,
+		Reason: fmt.Sprintf("the sink is switched from %s to %s at %d",
+			secret.RedactURI(old), secret.RedactURI(cf.info.SinkURI), sinkSwitch.Ts),
+	})
+	return o.resumeChangefeed(ctx, id, cf.status, cf.info,
+		fmt.Sprintf("resumed with the sink switched at %d", sinkSwitch.Ts))
+}
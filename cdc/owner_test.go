@@ -16,6 +16,7 @@ package cdc
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"sync"
@@ -41,6 +42,7 @@ import (
 	"github.com/pingcap/ticdc/pkg/util/testleak"
 	"github.com/pingcap/tidb/meta"
 	"github.com/pingcap/tidb/store/mockstore"
+	"github.com/pingcap/tidb/store/tikv/oracle"
 	pd "github.com/tikv/pd/client"
 	"go.etcd.io/etcd/clientv3"
 	"go.etcd.io/etcd/embed"
@@ -88,6 +90,11 @@ type mockPDClient struct {
 	invokeCounter     int
 	mockSafePointLost bool
 	mockPDFailure     bool
+	clusterID         uint64
+}
+
+func (m *mockPDClient) GetClusterID(ctx context.Context) uint64 {
+	return m.clusterID
 }
 
 func (m *mockPDClient) UpdateServiceGCSafePoint(ctx context.Context, serviceID string, ttl int64, safePoint uint64) (uint64, error) {
@@ -226,6 +233,8 @@ func (s *ownerSuite) TestOwnerUploadGCSafePointOutdated(c *check.C) {
 	c.Assert(err, check.IsNil)
 
 	c.Assert(mockOwner.stoppedFeeds["test_change_feed_1"], check.NotNil)
+	// the changefeed losing the GC safepoint fails instead of being paused
+	c.Assert(changeFeeds["test_change_feed_1"].info.State, check.Equals, model.StateFailed)
 	c.Assert(changeFeeds["test_change_feed_2"].info.State, check.Equals, model.StateNormal)
 	s.TearDownTest(c)
 }
@@ -925,6 +934,204 @@ func (s *ownerSuite) TestChangefeedApplyDDLJob(c *check.C) {
 	s.TearDownTest(c)
 }
 
+func (s *ownerSuite) TestChangefeedApplyRenameJob(c *check.C) {
+	defer testleak.AfterTest(c)()
+	newSchemaJob := func(schemaID int64, name string) *timodel.Job {
+		return &timodel.Job{
+			SchemaID: schemaID,
+			Type:     timodel.ActionCreateSchema,
+			State:    timodel.JobStateSynced,
+			BinlogInfo: &timodel.HistoryInfo{
+				DBInfo: &timodel.DBInfo{ID: schemaID, Name: timodel.NewCIStr(name)},
+			},
+		}
+	}
+	newTableJob := func(tp timodel.ActionType, schemaID int64, schema, table string, finishedTs uint64) *timodel.Job {
+		return &timodel.Job{
+			SchemaID: schemaID,
+			TableID:  47,
+			Type:     tp,
+			State:    timodel.JobStateSynced,
+			BinlogInfo: &timodel.HistoryInfo{
+				FinishedTS: finishedTs,
+				DBInfo:     &timodel.DBInfo{ID: schemaID, Name: timodel.NewCIStr(schema)},
+				TableInfo: &timodel.TableInfo{
+					ID:         47,
+					Name:       timodel.NewCIStr(table),
+					PKIsHandle: true,
+					Columns: []*timodel.ColumnInfo{
+						{ID: 1, FieldType: types.FieldType{Flag: mysql.PriKeyFlag}, State: timodel.StatePublic},
+					},
+				},
+			},
+		}
+	}
+	cfg := config.GetDefaultReplicaConfig()
+	cfg.Filter.Rules = []string{"test.*"}
+	f, err := filter.NewFilter(cfg)
+	c.Assert(err, check.IsNil)
+
+	store, err := mockstore.NewMockStore()
+	c.Assert(err, check.IsNil)
+	defer func() {
+		_ = store.Close()
+	}()
+	txn, err := store.Begin()
+	c.Assert(err, check.IsNil)
+	defer func() {
+		_ = txn.Rollback()
+	}()
+	schemaSnap, err := entry.NewSingleSchemaSnapshotFromMeta(meta.NewMeta(txn), 0, false)
+	c.Assert(err, check.IsNil)
+
+	cf := &changeFeed{
+		schema:        schemaSnap,
+		schemas:       make(map[model.SchemaID]tableIDMap),
+		tables:        make(map[model.TableID]model.TableName),
+		partitions:    make(map[model.TableID][]int64),
+		orphanTables:  make(map[model.TableID]model.Ts),
+		toCleanTables: make(map[model.TableID]model.Ts),
+		filter:        f,
+		info:          &model.ChangeFeedInfo{Config: cfg},
+	}
+	applyJob := func(job *timodel.Job) bool {
+		c.Assert(cf.schema.HandleDDL(job), check.IsNil)
+		c.Assert(cf.schema.FillSchemaName(job), check.IsNil)
+		skip, err := cf.applyJob(context.TODO(), job)
+		c.Assert(err, check.IsNil)
+		return skip
+	}
+	applyJob(newSchemaJob(1, "test"))
+	applyJob(newSchemaJob(2, "other"))
+	applyJob(newTableJob(timodel.ActionCreateTable, 2, "other", "t1", 100))
+	c.Assert(cf.tables, check.HasLen, 0)
+
+	// the table is renamed into the filter scope, its DDL is skipped
+	c.Assert(applyJob(newTableJob(timodel.ActionRenameTable, 1, "test", "t1", 110)), check.IsTrue)
+	c.Assert(cf.tables, check.DeepEquals, map[model.TableID]model.TableName{47: {Schema: "test", Table: "t1"}})
+	c.Assert(cf.schemas[1], check.DeepEquals, tableIDMap{47: struct{}{}})
+	c.Assert(cf.orphanTables, check.DeepEquals, map[model.TableID]model.Ts{47: 110})
+
+	// the table is renamed in the filter scope
+	delete(cf.orphanTables, 47)
+	c.Assert(applyJob(newTableJob(timodel.ActionRenameTable, 1, "test", "t2", 120)), check.IsFalse)
+	c.Assert(cf.tables, check.DeepEquals, map[model.TableID]model.TableName{47: {Schema: "test", Table: "t2"}})
+
+	// the table is renamed out of the filter scope
+	c.Assert(applyJob(newTableJob(timodel.ActionRenameTable, 2, "other", "t2", 130)), check.IsFalse)
+	c.Assert(cf.tables, check.HasLen, 0)
+	c.Assert(cf.schemas[1], check.HasLen, 0)
+	c.Assert(cf.toCleanTables, check.DeepEquals, map[model.TableID]model.Ts{47: 130})
+	s.TearDownTest(c)
+}
+
+func (s *ownerSuite) TestChangefeedApplyExchangePartitionJob(c *check.C) {
+	defer testleak.AfterTest(c)()
+	newTableInfo := func(id int64, name string, partitionIDs ...int64) *timodel.TableInfo {
+		info := &timodel.TableInfo{
+			ID:         id,
+			Name:       timodel.NewCIStr(name),
+			PKIsHandle: true,
+			Columns: []*timodel.ColumnInfo{
+				{ID: 1, FieldType: types.FieldType{Flag: mysql.PriKeyFlag}, State: timodel.StatePublic},
+			},
+		}
+		if len(partitionIDs) > 0 {
+			info.Partition = &timodel.PartitionInfo{Enable: true}
+			for _, pid := range partitionIDs {
+				info.Partition.Definitions = append(info.Partition.Definitions, timodel.PartitionDefinition{ID: pid})
+			}
+		}
+		return info
+	}
+	args, err := json.Marshal([]interface{}{11, 1, 10, "p0", true})
+	c.Assert(err, check.IsNil)
+	jobs := []*timodel.Job{
+		{
+			SchemaID:   1,
+			Type:       timodel.ActionCreateSchema,
+			BinlogInfo: &timodel.HistoryInfo{DBInfo: &timodel.DBInfo{ID: 1, Name: timodel.NewCIStr("test")}},
+		},
+		{
+			SchemaID:   2,
+			Type:       timodel.ActionCreateSchema,
+			BinlogInfo: &timodel.HistoryInfo{DBInfo: &timodel.DBInfo{ID: 2, Name: timodel.NewCIStr("other")}},
+		},
+		{
+			SchemaID:   1,
+			TableID:    10,
+			Type:       timodel.ActionCreateTable,
+			BinlogInfo: &timodel.HistoryInfo{FinishedTS: 100, TableInfo: newTableInfo(10, "pt", 11, 12)},
+		},
+		{
+			SchemaID:   2,
+			TableID:    20,
+			Type:       timodel.ActionCreateTable,
+			BinlogInfo: &timodel.HistoryInfo{FinishedTS: 100, TableInfo: newTableInfo(20, "nt")},
+		},
+	}
+	// only the partitioned table test.pt is replicated
+	cfg := config.GetDefaultReplicaConfig()
+	cfg.Filter.Rules = []string{"test.*"}
+	f, err := filter.NewFilter(cfg)
+	c.Assert(err, check.IsNil)
+
+	store, err := mockstore.NewMockStore()
+	c.Assert(err, check.IsNil)
+	defer func() {
+		_ = store.Close()
+	}()
+	txn, err := store.Begin()
+	c.Assert(err, check.IsNil)
+	defer func() {
+		_ = txn.Rollback()
+	}()
+	schemaSnap, err := entry.NewSingleSchemaSnapshotFromMeta(meta.NewMeta(txn), 0, false)
+	c.Assert(err, check.IsNil)
+
+	cf := &changeFeed{
+		schema:        schemaSnap,
+		schemas:       make(map[model.SchemaID]tableIDMap),
+		tables:        make(map[model.TableID]model.TableName),
+		partitions:    make(map[model.TableID][]int64),
+		orphanTables:  make(map[model.TableID]model.Ts),
+		toCleanTables: make(map[model.TableID]model.Ts),
+		filter:        f,
+		info:          &model.ChangeFeedInfo{Config: cfg},
+	}
+	applyJob := func(job *timodel.Job) bool {
+		c.Assert(cf.schema.HandleDDL(job), check.IsNil)
+		c.Assert(cf.schema.FillSchemaName(job), check.IsNil)
+		skip, err := cf.applyJob(context.TODO(), job)
+		c.Assert(err, check.IsNil)
+		return skip
+	}
+	for _, job := range jobs {
+		applyJob(job)
+	}
+	c.Assert(cf.orphanTables, check.DeepEquals, map[model.TableID]model.Ts{11: 100, 12: 100})
+	// the partitions are dispatched
+	cf.orphanTables = make(map[model.TableID]model.Ts)
+
+	// ALTER TABLE test.pt EXCHANGE PARTITION p0 WITH TABLE other.nt, the
+	// partition 11 becomes other.nt, and the table 20 becomes the partition
+	skip := applyJob(&timodel.Job{
+		SchemaID: 2,
+		TableID:  20,
+		Type:     timodel.ActionExchangeTablePartition,
+		RawArgs:  args,
+		BinlogInfo: &timodel.HistoryInfo{
+			FinishedTS: 110,
+			TableInfo:  newTableInfo(10, "pt", 20, 12),
+		},
+	})
+	c.Assert(skip, check.IsTrue)
+	c.Assert(cf.partitions[10], check.DeepEquals, []int64{20, 12})
+	c.Assert(cf.orphanTables, check.DeepEquals, map[model.TableID]model.Ts{20: 110})
+	c.Assert(cf.toCleanTables, check.DeepEquals, map[model.TableID]model.Ts{11: 110})
+	s.TearDownTest(c)
+}
+
 func (s *ownerSuite) TestWatchCampaignKey(c *check.C) {
 	defer testleak.AfterTest(c)()
 	defer s.TearDownTest(c)
@@ -1188,3 +1395,125 @@ func (s *ownerSuite) TestWriteDebugInfo(c *check.C) {
 	owner.writeDebugInfo(&buf)
 	c.Assert(buf.String(), check.Matches, `[\s\S]*active changefeeds[\s\S]*stopped changefeeds[\s\S]*captures[\s\S]*`)
 }
+
+func (s *ownerSuite) TestDrainCaptures(c *check.C) {
+	defer testleak.AfterTest(c)()
+	defer s.TearDownTest(c)
+	captures := map[model.CaptureID]*model.CaptureInfo{
+		"capture-1": {ID: "capture-1", Draining: true},
+		"capture-2": {ID: "capture-2"},
+		"capture-3": {ID: "capture-3"},
+	}
+	cf := &changeFeed{
+		id: "test",
+		taskStatus: model.ProcessorsInfos{
+			"capture-1": {Tables: map[model.TableID]*model.TableReplicaInfo{1: {}, 2: {}, 3: {}}},
+			"capture-2": {Tables: map[model.TableID]*model.TableReplicaInfo{4: {}, 5: {}}},
+		},
+	}
+	schedulable := schedulableCaptures(captures)
+	c.Assert(schedulable, check.HasLen, 2)
+	c.Assert(schedulable["capture-1"], check.IsNil)
+
+	cf.drainCaptures(captures, schedulable)
+	c.Assert(cf.manualMoveCommands, check.HasLen, 3)
+	targets := make(map[model.CaptureID]int)
+	for _, job := range cf.manualMoveCommands {
+		targets[job.To]++
+	}
+	// the tables are moved to the capture with the fewest tables
+	c.Assert(targets, check.DeepEquals, map[model.CaptureID]int{"capture-2": 1, "capture-3": 2})
+
+	// no more moves are added before the previous moves are done
+	cf.drainCaptures(captures, schedulable)
+	c.Assert(cf.manualMoveCommands, check.HasLen, 3)
+
+	// the tables can't be moved if all the captures are draining
+	cf.manualMoveCommands = nil
+	for _, info := range captures {
+		info.Draining = true
+	}
+	cf.drainCaptures(captures, schedulableCaptures(captures))
+	c.Assert(cf.manualMoveCommands, check.HasLen, 0)
+}
+
+func (s *ownerSuite) TestPinTables(c *check.C) {
+	defer testleak.AfterTest(c)()
+	defer s.TearDownTest(c)
+	ctx := context.Background()
+	captures := map[model.CaptureID]*model.CaptureInfo{
+		"capture-1": {ID: "capture-1"},
+		"capture-2": {ID: "capture-2"},
+	}
+	cf := &changeFeed{
+		id: "test",
+		taskStatus: model.ProcessorsInfos{
+			"capture-1": {Tables: map[model.TableID]*model.TableReplicaInfo{1: {Pinned: true}, 2: {}}},
+			"capture-2": {Tables: map[model.TableID]*model.TableReplicaInfo{3: {}}},
+		},
+	}
+	c.Assert(cf.pinnedTables(), check.DeepEquals, map[model.TableID]model.CaptureID{1: "capture-1"})
+
+	// the pin is carried by the move job
+	cf.manualMoveCommands = []*model.MoveTableJob{{To: "capture-1", TableID: 3, Pin: true}}
+	err := cf.handleManualMoveTableJobs(ctx, captures)
+	c.Assert(err, check.IsNil)
+	c.Assert(cf.moveTableJobs, check.HasLen, 1)
+	c.Assert(cf.moveTableJobs[3].From, check.Equals, "capture-2")
+	c.Assert(cf.moveTableJobs[3].Pin, check.IsTrue)
+}
+
+func (s *ownerSuite) TestNextSyncpointTs(c *check.C) {
+	defer testleak.AfterTest(c)()
+	interval := 10 * time.Second
+	now := time.Unix(1000, 0)
+	ts := func(sec int64) uint64 { return oracle.ComposeTS(sec*1000, 0) }
+
+	// aligned to the multiples of the interval
+	c.Assert(nextSyncpointTs(ts(995)+1, now, interval), check.Equals, ts(1000))
+	c.Assert(nextSyncpointTs(ts(1000), now, interval), check.Equals, ts(1010))
+	c.Assert(nextSyncpointTs(ts(1003), now, interval), check.Equals, ts(1010))
+	// the syncpoints older than an interval ago are skipped
+	c.Assert(nextSyncpointTs(ts(900), now, interval), check.Equals, ts(1000))
+	c.Assert(nextSyncpointTs(ts(900), now.Add(time.Second), interval), check.Equals, ts(1000))
+	c.Assert(nextSyncpointTs(ts(900), now.Add(interval), interval), check.Equals, ts(1010))
+}
+
+func (s *ownerSuite) TestVerifyUpstreamClusterID(c *check.C) {
+	defer testleak.AfterTest(c)()
+	owner := &Owner{
+		pdClient:   &mockPDClient{clusterID: 1},
+		etcdClient: s.client,
+		decisions:  newDecisionLog(maxOwnerDecisions),
+	}
+	info := &model.ChangeFeedInfo{SinkURI: "blackhole://", Config: config.GetDefaultReplicaConfig()}
+	err := s.client.SaveChangeFeedInfo(s.ctx, info, "cf")
+	c.Assert(err, check.IsNil)
+
+	// the changefeed not recording the cluster id adopts the current one
+	failed, err := owner.verifyUpstreamClusterID(s.ctx, "cf", info)
+	c.Assert(err, check.IsNil)
+	c.Assert(failed, check.IsFalse)
+	saved, err := s.client.GetChangeFeedInfo(s.ctx, "cf")
+	c.Assert(err, check.IsNil)
+	c.Assert(saved.ClusterID, check.Equals, uint64(1))
+
+	failed, err = owner.verifyUpstreamClusterID(s.ctx, "cf", saved)
+	c.Assert(err, check.IsNil)
+	c.Assert(failed, check.IsFalse)
+
+	// the upstream cluster is rebuilt
+	owner.pdClient = &mockPDClient{clusterID: 2}
+	failed, err = owner.verifyUpstreamClusterID(s.ctx, "cf", saved)
+	c.Assert(err, check.IsNil)
+	c.Assert(failed, check.IsTrue)
+	saved, err = s.client.GetChangeFeedInfo(s.ctx, "cf")
+	c.Assert(err, check.IsNil)
+	c.Assert(saved.State, check.Equals, model.StateFailed)
+	c.Assert(saved.StateHistory, check.HasLen, 1)
+	c.Assert(saved.ClusterID, check.Equals, uint64(1))
+	c.Assert(saved.Error.Code, check.Equals, string(cerror.ErrUpstreamClusterIDChanged.RFCCode()))
+	decisions := owner.decisions.explain("cf").Decisions
+	c.Assert(decisions, check.HasLen, 1)
+	c.Assert(decisions[0].Kind, check.Equals, DecisionFailChangefeed)
+}
@@ -17,29 +17,36 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/cenkalti/backoff"
 	"github.com/google/uuid"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
-	"github.com/pingcap/log"
+	timodel "github.com/pingcap/parser/model"
 	"github.com/pingcap/ticdc/cdc/entry"
 	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/cdc/puller"
 	psorter "github.com/pingcap/ticdc/cdc/puller/sorter"
 	"github.com/pingcap/ticdc/cdc/sink"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/diskmanager"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/logutil"
 	"github.com/pingcap/ticdc/pkg/notify"
 	"github.com/pingcap/ticdc/pkg/regionspan"
 	"github.com/pingcap/ticdc/pkg/retry"
 	"github.com/pingcap/ticdc/pkg/security"
+	"github.com/pingcap/ticdc/pkg/usage"
 	"github.com/pingcap/ticdc/pkg/util"
 	tidbkv "github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/store/tikv/oracle"
@@ -52,11 +59,29 @@ import (
 )
 
 const (
-	// defaultMemBufferCapacity is the default memory buffer per change feed.
-	defaultMemBufferCapacity int64 = 10 * 1024 * 1024 * 1024 // 10G
-
 	defaultSyncResolvedBatch = 1024
 
+	// defaultDDLQueueSize is the capacity of the queue between the DDL puller
+	// and the schema builder.
+	defaultDDLQueueSize = 1024
+
+	// syncTaskStatusInterval is the max interval of syncing the task status
+	// from etcd, in case the changes are missed by the watcher.
+	syncTaskStatusInterval = 10 * time.Second
+	// forceFlushPositionInterval is the max interval of writing the task
+	// position to etcd even if it's unchanged.
+	forceFlushPositionInterval = 10 * time.Second
+	// forceFlushWorkloadInterval is the max interval of writing the task
+	// workload to etcd if it's reported to the owner.
+	forceFlushWorkloadInterval = time.Minute
+
+	// defaultTableInitConcurrency is the max number of tables initializing at
+	// the same time in a processor. A table is initializing until its puller
+	// finishes the initial scan and the first resolved ts is received,
+	// starting thousands of tables at once after a capture restarts overloads
+	// both TiKV and the processor, and delays all the tables.
+	defaultTableInitConcurrency = 64
+
 	schemaStorageGCLag = time.Minute * 20
 )
 
@@ -65,8 +90,15 @@ type processor struct {
 	captureInfo  model.CaptureInfo
 	changefeedID string
 	changefeed   model.ChangeFeedInfo
-	limitter     *puller.BlurResourceLimitter
-	stopped      int32
+	rateLimiter  *puller.RateLimiter
+	// flowWindow and flowQuota bound the events between the pullers and the
+	// sink, see tableFlowController
+	flowWindow time.Duration
+	flowQuota  *flowQuota
+	stopped    int32
+	// logger tags the logs with the capture and the changefeed, its level can
+	// be changed for the changefeed alone.
+	logger *zap.Logger
 
 	pdCli      pd.Client
 	credential *security.Credential
@@ -87,6 +119,11 @@ type processor struct {
 	schemaStorage   *entry.SchemaStorage
 
 	mounter entry.Mounter
+	// eventSkipper is nil if the changefeed doesn't skip any event
+	eventSkipper *entry.EventSkipper
+	// sorterPools are the worker pools of the Unified Sorters of the
+	// changefeed, nil means the shared ones are used.
+	sorterPools *psorter.WorkerPools
 
 	stateMu           sync.Mutex
 	status            *model.TaskStatus
@@ -95,12 +132,41 @@ type processor struct {
 	markTableIDs      map[int64]struct{}
 	statusModRevision int64
 
+	// pendingTables are the tables waiting to be started by tableInitWorker,
+	// the tables with smaller start ts are started first, so the tables
+	// lagging behind the most catch up first.
+	pendingTables      map[int64]*model.TableReplicaInfo
+	initializingTables int32
+	tableInitCh        chan struct{}
+
+	// taskStatusChangedCh is notified by the task status watcher, the task
+	// status is synced from etcd only if it's changed, it has unapplied
+	// operations, or it's not synced for syncTaskStatusInterval.
+	taskStatusChangedCh   chan struct{}
+	taskStatusChanged     int32
+	statusOpsUnapplied    bool
+	lastStatusSyncTime    time.Time
+	lastFlushedPosition   *model.TaskPosition
+	lastPositionFlushTime time.Time
+
+	// stateReporter reports the task position and workload to the owner, they
+	// are written to etcd only periodically if the reports succeed.
+	stateReporter *runtimeStateReporter
+
 	globalResolvedTsNotifier  *notify.Notifier
 	localResolvedNotifier     *notify.Notifier
 	localResolvedReceiver     *notify.Receiver
 	localCheckpointTsNotifier *notify.Notifier
 	localCheckpointTsReceiver *notify.Receiver
 
+	// flushLatencies are the latest durations of flushing the table sinks
+	flushLatencies *latencyWindow
+	// lastSinkError is the last error returned by the table sinks
+	lastSinkError lastSinkError
+	// usage is the resources used by the changefeed on the capture, the
+	// sorter and the mounter account into it as well.
+	usage *usage.Changefeed
+
 	wg       *errgroup.Group
 	errCh    chan<- error
 	opDoneCh chan int64
@@ -117,6 +183,10 @@ type tableInfo struct {
 	mCheckpointTs uint64
 	workload      model.WorkloadInfo
 	cancel        context.CancelFunc
+	// discardResumeLogs removes the sorter resume logs of the table
+	discardResumeLogs []func()
+	traffic           tableTraffic
+	pipeline          tablePipeline
 }
 
 func (t *tableInfo) loadResolvedTs() uint64 {
@@ -141,6 +211,22 @@ func (t *tableInfo) loadCheckpointTs() uint64 {
 	return tableCkpt
 }
 
+// newSorterPools creates the worker pools of the Unified Sorters of the
+// changefeed if it's isolated from the other changefeeds, otherwise nil is
+// returned and the shared worker pools are used.
+func newSorterPools(changefeed model.ChangeFeedInfo) *psorter.WorkerPools {
+	cfg := changefeed.Config.WorkerPool
+	if cfg == nil || !cfg.Isolated ||
+		changefeed.Engine != model.SortUnified || diskmanager.MemoryFallback() {
+		return nil
+	}
+	workerNum := cfg.SorterWorkerNum
+	if workerNum <= 0 {
+		workerNum = config.GetSorterConfig().NumWorkerPoolGoroutine
+	}
+	return psorter.NewWorkerPools(workerNum)
+}
+
 // newProcessor creates and returns a processor for the specified change feed
 func newProcessor(
 	ctx context.Context,
@@ -154,19 +240,19 @@ func newProcessor(
 	checkpointTs uint64,
 	errCh chan error,
 	flushCheckpointInterval time.Duration,
+	authToken string,
 ) (*processor, error) {
 	etcdCli := session.Client()
 	cdcEtcdCli := kv.NewCDCEtcdClient(ctx, etcdCli)
-	limitter := puller.NewBlurResourceLimmter(defaultMemBufferCapacity)
 
-	log.Info("start processor with startts",
-		zap.Uint64("startts", checkpointTs), util.ZapFieldChangefeed(ctx))
+	logger := logutil.ChangefeedLogger(captureInfo.AdvertiseAddr, changefeedID)
+	logger.Info("start processor with startts", zap.Uint64("startts", checkpointTs))
 	kvStorage, err := util.KVStorageFromCtx(ctx)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 	ddlspans := []regionspan.Span{regionspan.GetDDLSpan(), regionspan.GetAddIndexDDLSpan()}
-	ddlPuller := puller.NewPuller(ctx, pdCli, credential, kvStorage, checkpointTs, ddlspans, limitter, false)
+	ddlPuller := puller.NewPuller(ctx, pdCli, credential, kvStorage, checkpointTs, ddlspans, false)
 	filter, err := filter.NewFilter(changefeed.Config)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -187,10 +273,37 @@ func newProcessor(
 	if err != nil {
 		return nil, err
 	}
+	stateReporter, err := newRuntimeStateReporter(cdcEtcdCli, credential, authToken)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	quarantineDir := filepath.Join(changefeed.SortDir, diskmanager.ComponentQuarantine)
+	if m := diskmanager.GetGlobal(); m != nil {
+		quarantineDir = m.Dir(diskmanager.ComponentQuarantine)
+	}
+	eventSkipper, err := entry.NewEventSkipper(changefeed.Config.Mounter.SkipEvent, quarantineDir,
+		captureInfo.AdvertiseAddr, changefeedID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	cfUsage := usage.Of(changefeedID)
+	if changefeed.Config.RateLimit != nil {
+		cfUsage.SetCPUShares(changefeed.Config.RateLimit.CPUShares)
+	}
+
+	flowControl := changefeed.Config.FlowControl
+	if flowControl == nil {
+		flowControl = config.GetDefaultReplicaConfig().FlowControl
+	}
 
 	p := &processor{
 		id:            uuid.New().String(),
-		limitter:      limitter,
+		logger:        logger,
+		rateLimiter:   puller.NewRateLimiter(changefeed.Config.RateLimit),
+		flowWindow:    time.Duration(flowControl.Window) * time.Minute,
+		flowQuota:     newFlowQuota(flowControl.MemoryQuota),
 		captureInfo:   captureInfo,
 		changefeedID:  changefeedID,
 		changefeed:    changefeed,
@@ -200,13 +313,15 @@ func newProcessor(
 		session:       session,
 		sinkManager:   sinkManager,
 		ddlPuller:     ddlPuller,
-		mounter:       entry.NewMounter(schemaStorage, changefeed.Config.Mounter.WorkerNum, changefeed.Config.EnableOldValue),
+		mounter:       entry.NewMounter(schemaStorage, changefeed.Config.Mounter.WorkerNum, changefeed.Config.Mounter.LargeRowThreshold, changefeed.Config.EnableOldValue, eventSkipper),
+		eventSkipper:  eventSkipper,
+		sorterPools:   newSorterPools(changefeed),
 		schemaStorage: schemaStorage,
 		errCh:         errCh,
 
 		flushCheckpointInterval: flushCheckpointInterval,
 
-		position: &model.TaskPosition{CheckPointTs: checkpointTs},
+		position: &model.TaskPosition{CheckPointTs: checkpointTs, Epoch: uint64(time.Now().UnixNano())},
 
 		globalResolvedTsNotifier: globalResolvedTsNotifier,
 		localResolvedNotifier:    localResolvedNotifier,
@@ -216,10 +331,20 @@ func newProcessor(
 		localCheckpointTsNotifier: localCheckpointTsNotifier,
 		localCheckpointTsReceiver: localCheckpointTsReceiver,
 
-		tables:       make(map[int64]*tableInfo),
-		markTableIDs: make(map[int64]struct{}),
+		tables:        make(map[int64]*tableInfo),
+		markTableIDs:  make(map[int64]struct{}),
+		pendingTables: make(map[int64]*model.TableReplicaInfo),
+		tableInitCh:   make(chan struct{}, 1),
 
 		opDoneCh: make(chan int64, 256),
+
+		flushLatencies: newLatencyWindow(defaultLatencyWindowSize),
+		usage:          cfUsage,
+
+		stateReporter:       stateReporter,
+		taskStatusChangedCh: make(chan struct{}, 1),
+		// sync the task status in the first flush
+		taskStatusChanged: 1,
 	}
 	modRevision, status, err := p.etcdCli.GetTaskStatus(ctx, p.changefeedID, p.captureInfo.ID)
 	if err != nil {
@@ -237,9 +362,11 @@ func newProcessor(
 		p.globalcheckpointTs = info.CheckpointTs
 	}
 
+	p.stateMu.Lock()
 	for tableID, replicaInfo := range p.status.Tables {
-		p.addTable(ctx, tableID, replicaInfo)
+		p.addPendingTableLocked(tableID, replicaInfo)
 	}
+	p.stateMu.Unlock()
 	return p, nil
 }
 
@@ -250,29 +377,54 @@ func (p *processor) Run(ctx context.Context) {
 		context.WithCancel(util.PutTableInfoInCtx(cctx, 0, "ticdc-processor-ddl"))
 	p.ddlPullerCancel = ddlPullerCancel
 
-	wg.Go(func() error {
+	wg.Go(util.GuardPanic(cctx, "position-worker", func() error {
 		return p.positionWorker(cctx)
-	})
+	}))
 
-	wg.Go(func() error {
+	wg.Go(util.GuardPanic(cctx, "global-status-worker", func() error {
 		return p.globalStatusWorker(cctx)
-	})
+	}))
 
-	wg.Go(func() error {
+	wg.Go(util.GuardPanic(cctx, "task-status-worker", func() error {
+		return p.taskStatusWatchWorker(cctx)
+	}))
+
+	wg.Go(util.GuardPanic(cctx, "ddl-puller", func() error {
 		return p.ddlPuller.Run(ddlPullerCtx)
-	})
+	}))
 
-	wg.Go(func() error {
-		return p.ddlPullWorker(cctx)
-	})
+	ddlCh := make(chan ddlEvent, defaultDDLQueueSize)
+	wg.Go(util.GuardPanic(cctx, "ddl-pull-worker", func() error {
+		return p.ddlPullWorker(cctx, ddlCh)
+	}))
+
+	wg.Go(util.GuardPanic(cctx, "schema-build-worker", func() error {
+		return p.schemaBuildWorker(cctx, ddlCh)
+	}))
 
-	wg.Go(func() error {
+	wg.Go(util.GuardPanic(cctx, "mounter", func() error {
 		return p.mounter.Run(cctx)
-	})
+	}))
+
+	if p.sorterPools != nil {
+		wg.Go(util.GuardPanic(cctx, "sorter-pools", func() error {
+			return p.sorterPools.Run(cctx)
+		}))
+	}
 
-	wg.Go(func() error {
+	wg.Go(util.GuardPanic(cctx, "workload-worker", func() error {
 		return p.workloadWorker(cctx)
-	})
+	}))
+
+	wg.Go(util.GuardPanic(cctx, "table-init-worker", func() error {
+		return p.tableInitWorker(cctx)
+	}))
+
+	if interval := p.sinkManager.HeartbeatInterval(); interval > 0 {
+		wg.Go(util.GuardPanic(cctx, "heartbeat-worker", func() error {
+			return p.heartbeatWorker(cctx, interval)
+		}))
+	}
 
 	go func() {
 		if err := wg.Wait(); err != nil {
@@ -285,10 +437,8 @@ func (p *processor) Run(ctx context.Context) {
 func (p *processor) wait() {
 	err := p.wg.Wait()
 	if err != nil && errors.Cause(err) != context.Canceled {
-		log.Error("processor wait error",
+		p.logger.Error("processor wait error",
 			zap.String("capture-id", p.captureInfo.ID),
-			zap.String("capture", p.captureInfo.AdvertiseAddr),
-			zap.String("changefeed", p.changefeedID),
 			zap.Error(err),
 		)
 	}
@@ -303,9 +453,48 @@ func (p *processor) writeDebugInfo(w io.Writer) {
 	for _, table := range p.tables {
 		fmt.Fprintf(w, "\ttable id: %d, resolveTS: %d\n", table.id, table.loadResolvedTs())
 	}
+	fmt.Fprintf(w, "pending tables: %d, initializing tables: %d\n",
+		len(p.pendingTables), atomic.LoadInt32(&p.initializingTables))
 	p.stateMu.Unlock()
 }
 
+// taskStatusWatchWorker watches the task status of the processor and notifies
+// the position worker to sync it, so the task status isn't read from etcd in
+// every flush.
+func (p *processor) taskStatusWatchWorker(ctx context.Context) error {
+	key := kv.GetEtcdKeyTaskStatus(p.changefeedID, p.captureInfo.ID)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		ch := p.etcdCli.Client.Watch(ctx, key)
+		// the changes may be missed before the watcher is created
+		p.notifyTaskStatusChanged()
+		for resp := range ch {
+			if resp.Err() == mvcc.ErrCompacted {
+				break
+			}
+			if resp.Err() != nil {
+				return cerror.WrapError(cerror.ErrProcessorEtcdWatch, resp.Err())
+			}
+			if len(resp.Events) > 0 {
+				p.notifyTaskStatusChanged()
+			}
+		}
+	}
+}
+
+// notifyTaskStatusChanged notifies the position worker to sync the task status.
+func (p *processor) notifyTaskStatusChanged() {
+	atomic.StoreInt32(&p.taskStatusChanged, 1)
+	select {
+	case p.taskStatusChangedCh <- struct{}{}:
+	default:
+	}
+}
+
 // localResolvedWorker do the flowing works.
 // 1, update resolve ts by scanning all table's resolve ts.
 // 2, update checkpoint ts by consuming entry from p.executedTxns.
@@ -313,26 +502,28 @@ func (p *processor) writeDebugInfo(w io.Writer) {
 // 4, check admin command in TaskStatus and apply corresponding command
 func (p *processor) positionWorker(ctx context.Context) error {
 	lastFlushTime := time.Now()
+	var lastResolvedFlushTime time.Time
 	retryFlushTaskStatusAndPosition := func() error {
 		t0Update := time.Now()
-		err := retry.Run(500*time.Millisecond, 3, func() error {
+		err := retry.Do(ctx, func() error {
 			inErr := p.flushTaskStatusAndPosition(ctx)
 			if inErr != nil {
 				if errors.Cause(inErr) != context.Canceled {
-					logError := log.Error
+					logError := p.logger.Error
 					errField := zap.Error(inErr)
 					if cerror.ErrAdminStopProcessor.Equal(inErr) {
-						logError = log.Warn
+						logError = p.logger.Warn
 						errField = zap.String("error", inErr.Error())
 					}
-					logError("update info failed", util.ZapFieldChangefeed(ctx), errField)
+					logError("update info failed", errField)
 				}
 				if p.isStopped() || cerror.ErrAdminStopProcessor.Equal(inErr) {
-					return backoff.Permanent(cerror.ErrAdminStopProcessor.FastGenByArgs())
+					return cerror.ErrAdminStopProcessor.FastGenByArgs()
 				}
 			}
 			return inErr
-		})
+		}, retry.WithBackoffBaseDelay(500*time.Millisecond), retry.WithMaxTries(4),
+			retry.WithIsRetryableErr(cerror.ErrAdminStopProcessor.NotEqual))
 		updateInfoDuration.
 			WithLabelValues(p.captureInfo.AdvertiseAddr).
 			Observe(time.Since(t0Update).Seconds())
@@ -349,11 +540,11 @@ func (p *processor) positionWorker(ctx context.Context) error {
 		if !p.isStopped() {
 			err := retryFlushTaskStatusAndPosition()
 			if err != nil && errors.Cause(err) != context.Canceled {
-				log.Warn("failed to update info before exit", util.ZapFieldChangefeed(ctx), zap.Error(err))
+				p.logger.Warn("failed to update info before exit", zap.Error(err))
 			}
 		}
 
-		log.Info("Local resolved worker exited", util.ZapFieldChangefeed(ctx))
+		p.logger.Info("Local resolved worker exited")
 	}()
 
 	resolvedTsGauge := resolvedTsGauge.WithLabelValues(p.changefeedID, p.captureInfo.AdvertiseAddr)
@@ -374,6 +565,9 @@ func (p *processor) positionWorker(ctx context.Context) error {
 					minResolvedTs = ts
 				}
 			}
+			if ts := p.pendingTablesMinStartTsLocked(); ts < minResolvedTs {
+				minResolvedTs = ts
+			}
 			p.stateMu.Unlock()
 			atomic.StoreUint64(&p.localResolvedTs, minResolvedTs)
 
@@ -383,11 +577,18 @@ func (p *processor) positionWorker(ctx context.Context) error {
 			metricResolvedTsLagGauge.Set(float64(oracle.GetPhysical(time.Now())-phyTs) / 1e3)
 			resolvedTsGauge.Set(float64(phyTs))
 
-			if p.position.ResolvedTs < minResolvedTs {
+			// the resolved ts is flushed at most once per flush interval, the
+			// following ticks catch up with the skipped one
+			if p.position.ResolvedTs < minResolvedTs && time.Since(lastResolvedFlushTime) >= p.flushCheckpointInterval {
 				p.position.ResolvedTs = minResolvedTs
 				if err := retryFlushTaskStatusAndPosition(); err != nil {
 					return errors.Trace(err)
 				}
+				lastResolvedFlushTime = time.Now()
+			}
+		case <-p.taskStatusChangedCh:
+			if err := retryFlushTaskStatusAndPosition(); err != nil {
+				return errors.Trace(err)
 			}
 		case <-p.localCheckpointTsReceiver.C:
 			checkpointTs := atomic.LoadUint64(&p.globalResolvedTs)
@@ -398,9 +599,12 @@ func (p *processor) positionWorker(ctx context.Context) error {
 					checkpointTs = ts
 				}
 			}
+			if ts := p.pendingTablesMinStartTsLocked(); ts < checkpointTs {
+				checkpointTs = ts
+			}
 			p.stateMu.Unlock()
 			if checkpointTs == 0 {
-				log.Debug("0 is not a valid checkpointTs", util.ZapFieldChangefeed(ctx))
+				p.logger.Debug("0 is not a valid checkpointTs")
 				continue
 			}
 			atomic.StoreUint64(&p.checkpointTs, checkpointTs)
@@ -414,6 +618,12 @@ func (p *processor) positionWorker(ctx context.Context) error {
 			}
 
 			p.position.CheckPointTs = checkpointTs
+			skewWarnings, err := p.checkTableSkew()
+			if err != nil {
+				return errors.Trace(err)
+			}
+			p.position.Warnings = append(p.sinkWarnings(), skewWarnings...)
+			p.position.Warnings = append(p.position.Warnings, p.eventSkipper.Warnings(p.captureInfo.AdvertiseAddr)...)
 			checkpointTsGauge.Set(float64(phyTs))
 			if err := retryFlushTaskStatusAndPosition(); err != nil {
 				return errors.Trace(err)
@@ -424,7 +634,15 @@ func (p *processor) positionWorker(ctx context.Context) error {
 	}
 }
 
-func (p *processor) ddlPullWorker(ctx context.Context) error {
+// ddlEvent is a DDL job or a resolved ts pulled by the DDL puller.
+type ddlEvent struct {
+	job        *timodel.Job
+	resolvedTs uint64
+}
+
+// ddlPullWorker decodes the DDL jobs pulled by the DDL puller and sends them
+// to the schema builder, it blocks only if the queue is full.
+func (p *processor) ddlPullWorker(ctx context.Context, ddlCh chan<- ddlEvent) error {
 	ddlRawKVCh := puller.SortOutput(ctx, p.ddlPuller.Output())
 	var ddlRawKV *model.RawKVEntry
 	for {
@@ -437,18 +655,46 @@ func (p *processor) ddlPullWorker(ctx context.Context) error {
 			continue
 		}
 		failpoint.Inject("processorDDLResolved", func() {})
+		var event ddlEvent
 		if ddlRawKV.OpType == model.OpTypeResolved {
-			p.schemaStorage.AdvanceResolvedTs(ddlRawKV.CRTs)
-			p.localResolvedNotifier.Notify()
+			event.resolvedTs = ddlRawKV.CRTs
+		} else {
+			job, err := entry.UnmarshalDDL(ddlRawKV)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if job == nil {
+				continue
+			}
+			event.job = job
 		}
-		job, err := entry.UnmarshalDDL(ddlRawKV)
-		if err != nil {
-			return errors.Trace(err)
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case ddlCh <- event:
 		}
-		if job == nil {
+	}
+}
+
+// schemaBuildWorker builds the schema snapshots of the DDL jobs in order, so
+// the main loop of the processor is never blocked by a burst of DDL jobs.
+func (p *processor) schemaBuildWorker(ctx context.Context, ddlCh <-chan ddlEvent) error {
+	metricDDLQueueSize := ddlQueueSizeGauge.WithLabelValues(p.changefeedID, p.captureInfo.AdvertiseAddr)
+	defer ddlQueueSizeGauge.DeleteLabelValues(p.changefeedID, p.captureInfo.AdvertiseAddr)
+	for {
+		var event ddlEvent
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case event = <-ddlCh:
+		}
+		metricDDLQueueSize.Set(float64(len(ddlCh)))
+		if event.job == nil {
+			p.schemaStorage.AdvanceResolvedTs(event.resolvedTs)
+			p.localResolvedNotifier.Notify()
 			continue
 		}
-		if err := p.schemaStorage.HandleDDLJob(job); err != nil {
+		if err := p.schemaStorage.HandleDDLJob(event.job); err != nil {
 			return errors.Trace(err)
 		}
 	}
@@ -460,6 +706,7 @@ func (p *processor) workloadWorker(ctx context.Context) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	lastFlushTime := time.Now()
 	for {
 		select {
 		case <-ctx.Done():
@@ -469,16 +716,110 @@ func (p *processor) workloadWorker(ctx context.Context) error {
 		if p.isStopped() {
 			continue
 		}
+		catchUpLag := p.changefeed.Config.Scheduler.GetCatchUpLag()
+		now := time.Now()
 		p.stateMu.Lock()
 		workload := make(model.TaskWorkload, len(p.tables))
 		for _, table := range p.tables {
-			workload[table.id] = table.workload
+			info := table.workload
+			info.CatchingUp = now.Sub(oracle.GetTimeFromTS(table.loadResolvedTs())) > catchUpLag
+			workload[table.id] = info
 		}
 		p.stateMu.Unlock()
+		resourceUsage := p.usage.Snapshot()
+		p.updateResourceUsageMetrics(resourceUsage)
+		if p.rateLimiter != nil {
+			count, err := p.etcdCli.GetTaskStatusCount(ctx, p.changefeedID)
+			if err != nil {
+				p.logger.Warn("failed to get the number of the captures of the changefeed", zap.Error(err))
+			} else {
+				p.rateLimiter.SetCaptureCount(count)
+			}
+		}
+		if p.stateReporter != nil && p.stateReporter.available() {
+			var sorterFreeBytes int64
+			if m := diskmanager.GetGlobal(); m != nil {
+				if available := m.Available(diskmanager.ComponentSorter); available > 0 {
+					sorterFreeBytes = available
+				}
+			}
+			err := p.stateReporter.report(ctx, &model.ProcessorRuntimeState{
+				ChangefeedID:    p.changefeedID,
+				CaptureID:       p.captureInfo.ID,
+				Workload:        workload,
+				SorterFreeBytes: sorterFreeBytes,
+				Usage:           &resourceUsage,
+			})
+			if err == nil && time.Since(lastFlushTime) < forceFlushWorkloadInterval {
+				continue
+			}
+			if err != nil {
+				p.logger.Warn("failed to report task workload to owner, write it to etcd", zap.Error(err))
+			}
+		}
 		err := p.etcdCli.PutTaskWorkload(ctx, p.changefeedID, p.captureInfo.ID, &workload)
 		if err != nil {
 			return errors.Trace(err)
 		}
+		lastFlushTime = time.Now()
+	}
+}
+
+// resourceUsageTypes are the values of the resource label of resourceUsageGauge
+var resourceUsageTypes = []string{"cpu", "memory", "sorter-disk", "sink", "throttled"}
+
+func (p *processor) updateResourceUsageMetrics(u model.ResourceUsage) {
+	addr := p.captureInfo.AdvertiseAddr
+	resourceUsageGauge.WithLabelValues(p.changefeedID, addr, "cpu").Set(u.CPUSeconds)
+	resourceUsageGauge.WithLabelValues(p.changefeedID, addr, "memory").Set(float64(u.MemoryBytes))
+	resourceUsageGauge.WithLabelValues(p.changefeedID, addr, "sorter-disk").Set(float64(u.SorterDiskBytes))
+	resourceUsageGauge.WithLabelValues(p.changefeedID, addr, "sink").Set(float64(u.SinkBytes))
+	resourceUsageGauge.WithLabelValues(p.changefeedID, addr, "throttled").Set(u.ThrottledSeconds)
+}
+
+// heartbeatWorker sends the heartbeats of the tables with their checkpoint ts
+// periodically, so the consumers of the sink know the tables are replicated
+// even if they have no changes.
+func (p *processor) heartbeatWorker(ctx context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-t.C:
+		}
+		if p.isStopped() {
+			continue
+		}
+		p.stateMu.Lock()
+		checkpoints := make(map[model.TableID]uint64, len(p.tables))
+		for _, table := range p.tables {
+			checkpoints[table.id] = table.loadCheckpointTs()
+		}
+		p.stateMu.Unlock()
+		// the partitions of a table share the heartbeat with the min checkpoint ts
+		heartbeats := make(map[model.TableName]uint64, len(checkpoints))
+		snap := p.schemaStorage.GetLastSnapshot()
+		for tableID, checkpointTs := range checkpoints {
+			name, ok := snap.GetTableNameByID(tableID)
+			if !ok {
+				continue
+			}
+			if ts, exist := heartbeats[name]; !exist || checkpointTs < ts {
+				heartbeats[name] = checkpointTs
+			}
+		}
+		for name, checkpointTs := range heartbeats {
+			if checkpointTs == 0 {
+				// the table is not initialized
+				continue
+			}
+			name := name
+			if err := p.sinkManager.EmitHeartbeat(ctx, &name, checkpointTs); err != nil {
+				return errors.Trace(err)
+			}
+		}
 	}
 }
 
@@ -489,20 +830,142 @@ func (p *processor) flushTaskPosition(ctx context.Context) error {
 	if p.isStopped() {
 		return cerror.ErrAdminStopProcessor.GenWithStackByArgs()
 	}
+	// skip the unchanged position, it's still written periodically in case
+	// the key is removed by others
+	unchanged := p.lastFlushedPosition != nil && reflect.DeepEqual(p.lastFlushedPosition, p.position)
+	if unchanged && time.Since(p.lastPositionFlushTime) < forceFlushPositionInterval {
+		return nil
+	}
+	// the position reported to the owner is written to etcd periodically, so
+	// a new owner can load it
+	if !unchanged && p.reportPosition(ctx) &&
+		time.Since(p.lastPositionFlushTime) < forceFlushPositionInterval {
+		position := *p.position
+		p.lastFlushedPosition = &position
+		return nil
+	}
 	// p.position.Count = p.sink.Count()
 	updated, err := p.etcdCli.PutTaskPositionOnChange(ctx, p.changefeedID, p.captureInfo.ID, p.position)
 	if err != nil {
 		if errors.Cause(err) != context.Canceled {
-			log.Error("failed to flush task position", util.ZapFieldChangefeed(ctx), zap.Error(err))
+			p.logger.Error("failed to flush task position", zap.Error(err))
 			return errors.Trace(err)
 		}
+		return nil
 	}
+	position := *p.position
+	p.lastFlushedPosition = &position
+	p.lastPositionFlushTime = time.Now()
 	if updated {
-		log.Debug("flushed task position", util.ZapFieldChangefeed(ctx), zap.Stringer("position", p.position))
+		p.logger.Debug("flushed task position", zap.Stringer("position", p.position))
 	}
 	return nil
 }
 
+// sinkWarnings returns the warnings reported by the sink.
+func (p *processor) sinkWarnings() []*model.RunningError {
+	warnings := p.sinkManager.Warnings()
+	for _, warning := range warnings {
+		warning.Addr = p.captureInfo.AdvertiseAddr
+	}
+	return warnings
+}
+
+// checkTableSkew returns the warnings of the tables whose checkpoints lag
+// behind the most advanced table of the processor by more than the max lag of
+// the table skew guard, which indicates the tables are stuck while the others
+// advance. An error is returned instead if the guard pauses the changefeed.
+func (p *processor) checkTableSkew() ([]*model.RunningError, error) {
+	cfg := p.changefeed.Config.TableSkew
+	if cfg == nil || cfg.MaxLag <= 0 {
+		return nil, nil
+	}
+	p.stateMu.Lock()
+	tables := make([]tableSkew, 0, len(p.tables))
+	for _, table := range p.tables {
+		tables = append(tables, tableSkew{id: table.id, name: table.name, checkpointTs: table.loadCheckpointTs()})
+	}
+	p.stateMu.Unlock()
+	skews := findSkewedTables(tables, time.Duration(cfg.MaxLag)*time.Minute)
+	if len(skews) == 0 {
+		return nil, nil
+	}
+	warnings := make([]*model.RunningError, 0, len(skews))
+	for _, skew := range skews {
+		// the partitions of a table have the same name
+		table := fmt.Sprintf("%s(id %d)", skew.name, skew.id)
+		err := cerror.ErrTableCheckpointSkewed.GenWithStackByArgs(table, skew.lag)
+		p.logger.Warn("the checkpoint of the table lags behind the other tables",
+			zap.String("table", skew.name), zap.Int64("tableID", skew.id), zap.Duration("lag", skew.lag))
+		if cfg.Pause {
+			return nil, err
+		}
+		warnings = append(warnings, &model.RunningError{
+			Addr:    p.captureInfo.AdvertiseAddr,
+			Code:    string(cerror.ErrTableCheckpointSkewed.RFCCode()),
+			Message: err.Error(),
+		})
+	}
+	return warnings, nil
+}
+
+type tableSkew struct {
+	id           int64
+	name         string
+	checkpointTs uint64
+	// lag is the duration the checkpoint lags behind the most advanced table
+	lag time.Duration
+}
+
+// findSkewedTables returns the tables whose checkpoints lag behind the most
+// advanced one by more than maxLag, ordered by the lags descending.
+func findSkewedTables(tables []tableSkew, maxLag time.Duration) []tableSkew {
+	var maxTs uint64
+	for _, table := range tables {
+		if table.checkpointTs > maxTs {
+			maxTs = table.checkpointTs
+		}
+	}
+	var skews []tableSkew
+	for _, table := range tables {
+		// the tables just added have no checkpoints yet
+		if table.checkpointTs == 0 {
+			continue
+		}
+		table.lag = time.Duration(oracle.ExtractPhysical(maxTs)-oracle.ExtractPhysical(table.checkpointTs)) * time.Millisecond
+		if table.lag > maxLag {
+			skews = append(skews, table)
+		}
+	}
+	sort.Slice(skews, func(i, j int) bool {
+		if skews[i].lag != skews[j].lag {
+			return skews[i].lag > skews[j].lag
+		}
+		return skews[i].id < skews[j].id
+	})
+	return skews
+}
+
+// reportPosition reports the task position to the owner, it returns false if
+// the position is not reported and should be written to etcd.
+func (p *processor) reportPosition(ctx context.Context) bool {
+	// the errors are always written to etcd
+	if p.stateReporter == nil || p.position.Error != nil || !p.stateReporter.available() {
+		return false
+	}
+	position := *p.position
+	err := p.stateReporter.report(ctx, &model.ProcessorRuntimeState{
+		ChangefeedID: p.changefeedID,
+		CaptureID:    p.captureInfo.ID,
+		Position:     &position,
+	})
+	if err != nil {
+		p.logger.Warn("failed to report task position to owner, write it to etcd", zap.Error(err))
+		return false
+	}
+	return true
+}
+
 // First try to synchronize task status from etcd.
 // If local cached task status is outdated (caused by new table scheduling),
 // update it to latest value, and force update task position, since add new
@@ -511,6 +974,10 @@ func (p *processor) flushTaskStatusAndPosition(ctx context.Context) error {
 	if p.isStopped() {
 		return cerror.ErrAdminStopProcessor.GenWithStackByArgs()
 	}
+	if atomic.SwapInt32(&p.taskStatusChanged, 0) == 0 && !p.statusOpsUnapplied &&
+		time.Since(p.lastStatusSyncTime) < syncTaskStatusInterval {
+		return p.flushTaskPosition(ctx)
+	}
 	var tablesToRemove []model.TableID
 	newTaskStatus, newModRevision, err := p.etcdCli.AtomicPutTaskStatus(ctx, p.changefeedID, p.captureInfo.ID,
 		func(modRevision int64, taskStatus *model.TaskStatus) (bool, error) {
@@ -521,12 +988,12 @@ func (p *processor) flushTaskStatusAndPosition(ctx context.Context) error {
 			}
 			// task will be stopped in capture task handler, do nothing
 			if taskStatus.AdminJobType.IsStopState() {
-				return false, backoff.Permanent(cerror.ErrAdminStopProcessor.GenWithStackByArgs())
+				return false, cerror.ErrAdminStopProcessor.GenWithStackByArgs()
 			}
 			toRemove, err := p.handleTables(ctx, taskStatus)
 			tablesToRemove = append(tablesToRemove, toRemove...)
 			if err != nil {
-				return false, backoff.Permanent(errors.Trace(err))
+				return false, errors.Trace(err)
 			}
 			// processor reads latest task status from etcd, analyzes operation
 			// field and processes table add or delete. If operation is unapplied
@@ -539,11 +1006,15 @@ func (p *processor) flushTaskStatusAndPosition(ctx context.Context) error {
 			return true, err
 		})
 	if err != nil {
+		// sync the task status again in the next flush
+		atomic.StoreInt32(&p.taskStatusChanged, 1)
 		// not need to check error
 		//nolint:errcheck
 		p.flushTaskPosition(ctx)
 		return errors.Trace(err)
 	}
+	p.lastStatusSyncTime = time.Now()
+	p.statusOpsUnapplied = newTaskStatus != nil && newTaskStatus.SomeOperationsUnapplied()
 	for _, tableID := range tablesToRemove {
 		p.removeTable(tableID)
 	}
@@ -563,14 +1034,18 @@ func (p *processor) removeTable(tableID int64) {
 	p.stateMu.Lock()
 	defer p.stateMu.Unlock()
 
-	log.Debug("remove table", zap.String("changefeed", p.changefeedID), zap.Int64("id", tableID))
+	p.logger.Debug("remove table", zap.Int64("id", tableID))
 
 	table, ok := p.tables[tableID]
 	if !ok {
-		log.Warn("table not found", zap.String("changefeed", p.changefeedID), zap.Int64("tableID", tableID))
+		p.logger.Warn("table not found", zap.Int64("tableID", tableID))
 		return
 	}
 
+	// the table is replicated by another capture afterwards
+	for _, discard := range table.discardResumeLogs {
+		discard()
+	}
 	table.cancel()
 	delete(p.tables, tableID)
 	if table.markTableID != 0 {
@@ -589,13 +1064,16 @@ func (p *processor) handleTables(ctx context.Context, status *model.TaskStatus)
 		if opt.Delete {
 			if opt.BoundaryTs <= p.position.CheckPointTs {
 				if opt.BoundaryTs != p.position.CheckPointTs {
-					log.Warn("the replication progresses beyond the BoundaryTs and duplicate data may be received by downstream",
+					p.logger.Warn("the replication progresses beyond the BoundaryTs and duplicate data may be received by downstream",
 						zap.Uint64("local resolved TS", p.position.ResolvedTs), zap.Any("opt", opt))
 				}
+				p.stateMu.Lock()
+				// the table may be removed before it's started
+				delete(p.pendingTables, tableID)
+				p.stateMu.Unlock()
 				table, exist := p.tables[tableID]
 				if !exist {
-					log.Warn("table which will be deleted is not found",
-						util.ZapFieldChangefeed(ctx), zap.Int64("tableID", tableID))
+					p.logger.Warn("table which will be deleted is not found", zap.Int64("tableID", tableID))
 					opt.Done = true
 					opt.Status = model.OperFinished
 					status.Dirty = true
@@ -603,8 +1081,7 @@ func (p *processor) handleTables(ctx context.Context, status *model.TaskStatus)
 				}
 				table.cancel()
 				checkpointTs := table.loadCheckpointTs()
-				log.Debug("stop table", zap.Int64("tableID", tableID),
-					util.ZapFieldChangefeed(ctx),
+				p.logger.Debug("stop table", zap.Int64("tableID", tableID),
 					zap.Any("opt", opt),
 					zap.Uint64("checkpointTs", checkpointTs))
 				opt.BoundaryTs = checkpointTs
@@ -622,9 +1099,18 @@ func (p *processor) handleTables(ctx context.Context, status *model.TaskStatus)
 			if p.changefeed.Config.Cyclic.IsEnabled() && replicaInfo.MarkTableID == 0 {
 				return tablesToRemove, cerror.ErrProcessorTableNotFound.GenWithStack("normal table(%d) and mark table not match ", tableID)
 			}
-			p.addTable(ctx, tableID, replicaInfo)
-			opt.Status = model.OperProcessed
-			status.Dirty = true
+			// the table is started by tableInitWorker, the operation is
+			// processed in the next sync of the task status after that
+			p.stateMu.Lock()
+			_, started := p.tables[tableID]
+			if !started {
+				p.addPendingTableLocked(tableID, replicaInfo)
+			}
+			p.stateMu.Unlock()
+			if started {
+				opt.Status = model.OperProcessed
+				status.Dirty = true
+			}
 		}
 	}
 
@@ -633,13 +1119,11 @@ func (p *processor) handleTables(ctx context.Context, status *model.TaskStatus)
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		case tableID := <-p.opDoneCh:
-			log.Debug("Operation done signal received",
-				util.ZapFieldChangefeed(ctx),
+			p.logger.Debug("Operation done signal received",
 				zap.Int64("tableID", tableID),
 				zap.Reflect("operation", status.Operation[tableID]))
 			if status.Operation[tableID] == nil {
-				log.Debug("TableID does not exist, probably a mark table, ignore",
-					util.ZapFieldChangefeed(ctx), zap.Int64("tableID", tableID))
+				p.logger.Debug("TableID does not exist, probably a mark table, ignore", zap.Int64("tableID", tableID))
 				continue
 			}
 			status.Operation[tableID].Done = true
@@ -661,7 +1145,7 @@ done:
 
 // globalStatusWorker read global resolve ts from changefeed level info and forward `tableInputChans` regularly.
 func (p *processor) globalStatusWorker(ctx context.Context) error {
-	log.Info("Global status worker started", util.ZapFieldChangefeed(ctx))
+	p.logger.Info("Global status worker started")
 
 	var (
 		changefeedStatus *model.ChangeFeedStatus
@@ -688,37 +1172,28 @@ func (p *processor) globalStatusWorker(ctx context.Context) error {
 		if lastResolvedTs < changefeedStatus.ResolvedTs {
 			lastResolvedTs = changefeedStatus.ResolvedTs
 			atomic.StoreUint64(&p.globalResolvedTs, lastResolvedTs)
-			log.Debug("Update globalResolvedTs",
-				zap.Uint64("globalResolvedTs", lastResolvedTs), util.ZapFieldChangefeed(ctx))
+			p.logger.Debug("Update globalResolvedTs",
+				zap.Uint64("globalResolvedTs", lastResolvedTs))
 			p.globalResolvedTsNotifier.Notify()
 		}
 	}
 
-	retryCfg := backoff.WithMaxRetries(
-		backoff.WithContext(
-			backoff.NewExponentialBackOff(), ctx),
-		5,
-	)
 	for {
 		select {
 		case <-ctx.Done():
-			log.Info("Global resolved worker exited", util.ZapFieldChangefeed(ctx))
+			p.logger.Info("Global resolved worker exited")
 			return ctx.Err()
 		default:
 		}
 
-		err := backoff.Retry(func() error {
+		err := retry.Do(ctx, func() error {
 			var err error
 			changefeedStatus, statusRev, err = p.etcdCli.GetChangeFeedStatus(ctx, p.changefeedID)
-			if err != nil {
-				if errors.Cause(err) == context.Canceled {
-					return backoff.Permanent(err)
-				}
-				log.Error("Global resolved worker: read global resolved ts failed",
-					util.ZapFieldChangefeed(ctx), zap.Error(err))
+			if err != nil && errors.Cause(err) != context.Canceled {
+				p.logger.Error("Global resolved worker: read global resolved ts failed", zap.Error(err))
 			}
 			return err
-		}, retryCfg)
+		}, retry.WithBackoffBaseDelay(500*time.Millisecond), retry.WithBackoffMaxDelay(time.Minute), retry.WithMaxTries(6))
 		if err != nil {
 			return errors.Trace(err)
 		}
@@ -757,25 +1232,191 @@ func createSchemaStorage(
 	return entry.NewSchemaStorage(meta, checkpointTs, filter, forceReplicate)
 }
 
-func (p *processor) addTable(ctx context.Context, tableID int64, replicaInfo *model.TableReplicaInfo) {
+// addPendingTableLocked queues a table to be started by tableInitWorker, it
+// must be called with stateMu held.
+func (p *processor) addPendingTableLocked(tableID int64, replicaInfo *model.TableReplicaInfo) {
+	if _, ok := p.tables[tableID]; ok {
+		return
+	}
+	if _, ok := p.pendingTables[tableID]; !ok {
+		p.logger.Debug("add pending table",
+			zap.Int64("tableID", tableID), zap.Uint64("startTs", replicaInfo.StartTs))
+	}
+	p.pendingTables[tableID] = replicaInfo
+	// the position must not pass the start ts of a pending table
+	if p.position.CheckPointTs > replicaInfo.StartTs {
+		p.position.CheckPointTs = replicaInfo.StartTs
+	}
+	if p.position.ResolvedTs > replicaInfo.StartTs {
+		p.position.ResolvedTs = replicaInfo.StartTs
+	}
+	select {
+	case p.tableInitCh <- struct{}{}:
+	default:
+	}
+}
+
+// pendingTablesMinStartTsLocked returns the min start ts of the pending
+// tables, it must be called with stateMu held.
+func (p *processor) pendingTablesMinStartTsLocked() uint64 {
+	minStartTs := uint64(math.MaxUint64)
+	for _, replicaInfo := range p.pendingTables {
+		if replicaInfo.StartTs < minStartTs {
+			minStartTs = replicaInfo.StartTs
+		}
+	}
+	return minStartTs
+}
+
+// oldestPendingTablesLocked returns at most n pending tables with the
+// smallest start ts, it must be called with stateMu held.
+func (p *processor) oldestPendingTablesLocked(n int) []model.TableID {
+	if n <= 0 || len(p.pendingTables) == 0 {
+		return nil
+	}
+	tableIDs := make([]model.TableID, 0, len(p.pendingTables))
+	for tableID := range p.pendingTables {
+		tableIDs = append(tableIDs, tableID)
+	}
+	sort.Slice(tableIDs, func(i, j int) bool {
+		ti, tj := p.pendingTables[tableIDs[i]].StartTs, p.pendingTables[tableIDs[j]].StartTs
+		if ti != tj {
+			return ti < tj
+		}
+		return tableIDs[i] < tableIDs[j]
+	})
+	if len(tableIDs) > n {
+		tableIDs = tableIDs[:n]
+	}
+	return tableIDs
+}
+
+// tableInitWorker starts the pending tables, at most
+// defaultTableInitConcurrency tables are initializing at the same time.
+func (p *processor) tableInitWorker(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-p.tableInitCh:
+		case <-ticker.C:
+		}
+		if p.isStopped() {
+			continue
+		}
+		n := defaultTableInitConcurrency - int(atomic.LoadInt32(&p.initializingTables))
+		p.stateMu.Lock()
+		tableIDs := p.oldestPendingTablesLocked(n)
+		// start the tables with stateMu held, so the pending tables are
+		// always counted in the position
+		for _, tableID := range tableIDs {
+			replicaInfo := p.pendingTables[tableID]
+			delete(p.pendingTables, tableID)
+			p.addTableLocked(ctx, tableID, replicaInfo)
+		}
+		pending := len(p.pendingTables)
+		p.stateMu.Unlock()
+		if len(tableIDs) > 0 {
+			p.logger.Info("start pending tables",
+				zap.Int("started", len(tableIDs)), zap.Int("pending", pending))
+			// mark the add table operations of the started tables processed
+			p.notifyTaskStatusChanged()
+		}
+	}
+}
+
+// sortDir returns the directory of the sorter files, it returns false if the
+// sorter engine doesn't write files.
+func (p *processor) sortDir() (string, bool) {
+	switch p.changefeed.Engine {
+	case model.SortInFile:
+	case model.SortUnified:
+		if diskmanager.MemoryFallback() {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	// the files of the unified sorter are accounted by the disk manager
+	if m := diskmanager.GetGlobal(); m != nil && p.changefeed.Engine == model.SortUnified {
+		return m.Dir(diskmanager.ComponentSorter), true
+	}
+	return p.changefeed.SortDir, true
+}
+
+// emitBootstrapMessage sends the bootstrap DDL event of the table with the
+// schema at startTs, it's skipped if the table is not found in the snapshot.
+func (p *processor) emitBootstrapMessage(ctx context.Context, tableID model.TableID, startTs uint64) error {
+	snap, err := p.schemaStorage.GetSnapshot(ctx, startTs)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	tableInfo, ok := snap.PhysicalTableByID(tableID)
+	if !ok {
+		p.logger.Warn("table not found in the schema snapshot, skip the bootstrap message",
+			zap.Int64("tableID", tableID), zap.Uint64("startTs", startTs))
+		return nil
+	}
+	ddl := model.NewBootstrapDDLEvent(tableInfo, startTs)
+	return errors.Trace(p.sinkManager.EmitBootstrapMessage(ctx, ddl))
+}
+
+// openResumeLog opens the sorter resume log of the table, it returns nil if
+// the table doesn't use the unified sorter with the resume enabled.
+func (p *processor) openResumeLog(tableID model.TableID) *psorter.ResumeLog {
+	m := diskmanager.GetGlobal()
+	if m == nil || diskmanager.MemoryFallback() || p.changefeed.Engine != model.SortUnified ||
+		!config.GetSorterConfig().EnableResume {
+		return nil
+	}
+	dir := filepath.Join(m.Dir(diskmanager.ComponentSorterResume), p.changefeedID, strconv.FormatInt(tableID, 10))
+	l, err := psorter.OpenResumeLog(dir)
+	if err != nil {
+		// the table is pulled from the start ts
+		p.logger.Warn("open the sorter resume log failed", zap.Int64("tableID", tableID), zap.Error(err))
+		return nil
+	}
+	return l
+}
+
+// tablesInitialized returns whether all the tables of the processor are
+// started and their pullers are initialized.
+func (p *processor) tablesInitialized() bool {
 	p.stateMu.Lock()
-	defer p.stateMu.Unlock()
+	pending := len(p.pendingTables)
+	p.stateMu.Unlock()
+	return pending == 0 && atomic.LoadInt32(&p.initializingTables) == 0
+}
+
+// tableInitialized is called once the puller of a table is initialized or
+// exits, so another pending table can be started.
+func (p *processor) tableInitialized() {
+	atomic.AddInt32(&p.initializingTables, -1)
+	select {
+	case p.tableInitCh <- struct{}{}:
+	default:
+	}
+}
 
+// addTableLocked starts a table, it must be called with stateMu held.
+func (p *processor) addTableLocked(ctx context.Context, tableID int64, replicaInfo *model.TableReplicaInfo) {
 	var tableName string
-	err := retry.Run(time.Millisecond*5, 3, func() error {
+	err := retry.Do(ctx, func() error {
 		if name, ok := p.schemaStorage.GetLastSnapshot().GetTableNameByID(tableID); ok {
 			tableName = name.QuoteString()
 			return nil
 		}
 		return errors.Errorf("failed to get table name, fallback to use table id: %d", tableID)
-	})
+	}, retry.WithBackoffBaseDelay(5*time.Millisecond), retry.WithMaxTries(4))
 	if err != nil {
-		log.Warn("get table name for metric", util.ZapFieldChangefeed(ctx), zap.String("error", err.Error()))
+		p.logger.Warn("get table name for metric", zap.String("error", err.Error()))
 		tableName = strconv.Itoa(int(tableID))
 	}
 
 	if _, ok := p.tables[tableID]; ok {
-		log.Warn("Ignore existing table", util.ZapFieldChangefeed(ctx), zap.Int64("ID", tableID))
+		p.logger.Warn("Ignore existing table", zap.Int64("ID", tableID))
 		return
 	}
 
@@ -784,16 +1425,14 @@ func (p *processor) addTable(ctx context.Context, tableID int64, replicaInfo *mo
 	if replicaInfo.StartTs < globalcheckpointTs {
 		// use Warn instead of Panic in case that p.globalcheckpointTs has not been initialized.
 		// The cdc_state_checker will catch a real inconsistency in integration tests.
-		log.Warn("addTable: startTs < checkpoint",
-			util.ZapFieldChangefeed(ctx),
+		p.logger.Warn("addTable: startTs < checkpoint",
 			zap.Int64("tableID", tableID),
 			zap.Uint64("checkpoint", globalcheckpointTs),
 			zap.Uint64("startTs", replicaInfo.StartTs))
 	}
 
 	globalResolvedTs := atomic.LoadUint64(&p.globalResolvedTs)
-	log.Debug("Add table", zap.Int64("tableID", tableID),
-		util.ZapFieldChangefeed(ctx),
+	p.logger.Debug("Add table", zap.Int64("tableID", tableID),
 		zap.String("name", tableName),
 		zap.Any("replicaInfo", replicaInfo),
 		zap.Uint64("globalResolvedTs", globalResolvedTs))
@@ -809,7 +1448,7 @@ func (p *processor) addTable(ctx context.Context, tableID int64, replicaInfo *mo
 	// We temporarily set the value to constant 1
 	table.workload = model.WorkloadInfo{Workload: 1}
 
-	startPuller := func(tableID model.TableID, pResolvedTs *uint64, pCheckpointTs *uint64) sink.Sink {
+	startPuller := func(tableID model.TableID, pResolvedTs *uint64, pCheckpointTs *uint64, traffic *tableTraffic, pipeline *tablePipeline) sink.Sink {
 		// start table puller
 		enableOldValue := p.changefeed.Config.EnableOldValue
 		span := regionspan.GetTableSpan(tableID, enableOldValue)
@@ -818,23 +1457,44 @@ func (p *processor) addTable(ctx context.Context, tableID int64, replicaInfo *mo
 			p.sendError(err)
 			return nil
 		}
-		plr := puller.NewPuller(ctx, p.pdCli, p.credential, kvStorage, replicaInfo.StartTs, []regionspan.Span{span}, p.limitter, enableOldValue)
+		pullerStartTs := replicaInfo.StartTs
+		resumeLog := p.openResumeLog(tableID)
+		if resumeLog != nil {
+			if resolvedTs, ok := resumeLog.Covers(replicaInfo.StartTs); ok {
+				// the events before the resolved ts are replayed by the sorter
+				p.logger.Info("resume the table from the sorter resume log",
+					zap.Int64("tableID", tableID),
+					zap.Uint64("startTs", replicaInfo.StartTs),
+					zap.Uint64("resolvedTs", resolvedTs))
+				pullerStartTs = resolvedTs
+			}
+		}
+		plr := puller.NewPuller(ctx, p.pdCli, p.credential, kvStorage, pullerStartTs, []regionspan.Span{span}, enableOldValue)
 		go func() {
-			err := plr.Run(ctx)
+			err := util.GuardPanic(ctx, "puller", func() error {
+				return plr.Run(ctx)
+			})()
 			if errors.Cause(err) != context.Canceled {
 				p.sendError(err)
 			}
 		}()
 
 		var sorter puller.EventSorter
-		switch p.changefeed.Engine {
+		engine := p.changefeed.Engine
+		if engine == model.SortUnified && diskmanager.MemoryFallback() {
+			p.logger.Warn("data dir is unhealthy, use the memory sorter instead of the unified sorter",
+				zap.Int64("table-id", tableID))
+			engine = model.SortInMemory
+		}
+		switch engine {
 		case model.SortInMemory:
 			sorter = puller.NewEntrySorter()
 		case model.SortInFile, model.SortUnified:
-			err := util.IsDirAndWritable(p.changefeed.SortDir)
+			sortDir, _ := p.sortDir()
+			err := util.IsDirAndWritable(sortDir)
 			if err != nil {
 				if os.IsNotExist(errors.Cause(err)) {
-					err = os.MkdirAll(p.changefeed.SortDir, 0o755)
+					err = os.MkdirAll(sortDir, 0o755)
 					if err != nil {
 						p.sendError(errors.Annotate(cerror.WrapError(cerror.ErrProcessorSortDir, err), "create dir"))
 						return nil
@@ -846,29 +1506,45 @@ func (p *processor) addTable(ctx context.Context, tableID int64, replicaInfo *mo
 			}
 
 			if p.changefeed.Engine == model.SortInFile {
-				sorter = puller.NewFileSorter(p.changefeed.SortDir)
+				sorter = puller.NewFileSorter(sortDir)
 			} else {
 				// Unified Sorter
-				sorter = psorter.NewUnifiedSorter(p.changefeed.SortDir, tableName, util.CaptureAddrFromCtx(ctx))
+				unifiedSorter := psorter.NewUnifiedSorter(sortDir, tableName, util.CaptureAddrFromCtx(ctx), p.sorterPools)
+				if resumeLog != nil {
+					unifiedSorter.SetResumeLog(resumeLog, replicaInfo.StartTs, func() uint64 {
+						return atomic.LoadUint64(&p.globalcheckpointTs)
+					})
+					table.discardResumeLogs = append(table.discardResumeLogs, unifiedSorter.DiscardResumeLog)
+				}
+				sorter = unifiedSorter
 			}
 		default:
 			p.sendError(cerror.ErrUnknownSortEngine.GenWithStackByArgs(p.changefeed.Engine))
 			return nil
 		}
 		go func() {
-			err := sorter.Run(ctx)
+			err := util.GuardPanic(ctx, "sorter", func() error {
+				return sorter.Run(ctx)
+			})()
 			if errors.Cause(err) != context.Canceled {
 				p.sendError(err)
 			}
 		}()
 
+		flowController := newTableFlowController(p.flowWindow, p.flowQuota, replicaInfo.StartTs, pCheckpointTs)
+		if pipeline != nil {
+			pipeline.flowController = flowController
+		}
 		go func() {
-			p.pullerConsume(ctx, plr, sorter)
+			defer p.recoverPanic(ctx, "puller-consumer")
+			p.pullerConsume(ctx, plr, sorter, flowController, pipeline)
 		}()
 
 		tableSink := p.sinkManager.CreateTableSink(tableID, replicaInfo.StartTs)
+		atomic.AddInt32(&p.initializingTables, 1)
 		go func() {
-			p.sorterConsume(ctx, tableID, tableName, sorter, pResolvedTs, pCheckpointTs, replicaInfo, tableSink)
+			defer p.recoverPanic(ctx, "sorter-consumer")
+			p.sorterConsume(ctx, tableID, tableName, sorter, pResolvedTs, pCheckpointTs, replicaInfo, tableSink, traffic, pipeline)
 		}()
 		return tableSink
 	}
@@ -881,7 +1557,7 @@ func (p *processor) addTable(ctx context.Context, tableID int64, replicaInfo *mo
 			table.markTableID = mTableID
 			table.mResolvedTs = replicaInfo.StartTs
 
-			mTableSink = startPuller(mTableID, &table.mResolvedTs, &table.mCheckpointTs)
+			mTableSink = startPuller(mTableID, &table.mResolvedTs, &table.mCheckpointTs, nil, nil)
 		}
 	}
 
@@ -894,7 +1570,7 @@ func (p *processor) addTable(ctx context.Context, tableID int64, replicaInfo *mo
 	}
 
 	atomic.StoreUint64(&p.localResolvedTs, p.position.ResolvedTs)
-	tableSink = startPuller(tableID, &table.resolvedTs, &table.checkpointTs)
+	tableSink = startPuller(tableID, &table.resolvedTs, &table.checkpointTs, &table.traffic, &table.pipeline)
 	table.cancel = func() {
 		cancel()
 		if tableSink != nil {
@@ -918,9 +1594,26 @@ func (p *processor) sorterConsume(
 	pCheckpointTs *uint64,
 	replicaInfo *model.TableReplicaInfo,
 	sink sink.Sink,
+	traffic *tableTraffic,
+	pipeline *tablePipeline,
 ) {
 	var lastResolvedTs uint64
 	opDone := false
+	initialized := false
+	defer func() {
+		if !initialized {
+			p.tableInitialized()
+		}
+	}()
+	if p.changefeed.Config.Sink.BootstrapMessage && tableID != replicaInfo.MarkTableID {
+		// the consumers must see the bootstrap message before the rows of the table
+		if err := p.emitBootstrapMessage(ctx, tableID, replicaInfo.StartTs); err != nil {
+			if errors.Cause(err) != context.Canceled {
+				p.sendError(err)
+			}
+			return
+		}
+	}
 	resolvedTsGauge := tableResolvedTsGauge.WithLabelValues(p.changefeedID, p.captureInfo.AdvertiseAddr, tableName)
 	checkDoneTicker := time.NewTicker(1 * time.Second)
 	checkDone := func() {
@@ -932,9 +1625,9 @@ func (p *processor) sorterConsume(
 		if !opDone && lastResolvedTs >= localResolvedTs && localResolvedTs >= globalResolvedTs &&
 			tableCheckPointTs >= localCheckpoint {
 
-			log.Debug("localResolvedTs >= globalResolvedTs, sending operation done signal",
+			p.logger.Debug("localResolvedTs >= globalResolvedTs, sending operation done signal",
 				zap.Uint64("localResolvedTs", localResolvedTs), zap.Uint64("globalResolvedTs", globalResolvedTs),
-				zap.Int64("tableID", tableID), util.ZapFieldChangefeed(ctx))
+				zap.Int64("tableID", tableID))
 
 			opDone = true
 			checkDoneTicker.Stop()
@@ -948,8 +1641,7 @@ func (p *processor) sorterConsume(
 			}
 		}
 		if !opDone {
-			log.Debug("addTable not done",
-				util.ZapFieldChangefeed(ctx),
+			p.logger.Debug("addTable not done",
 				zap.Uint64("tableResolvedTs", lastResolvedTs),
 				zap.Uint64("localResolvedTs", localResolvedTs),
 				zap.Uint64("globalResolvedTs", globalResolvedTs),
@@ -972,14 +1664,17 @@ func (p *processor) sorterConsume(
 				continue
 			}
 			rows = append(rows, ev.Row)
+			traffic.add(ev.Row)
+			p.usage.AddSinkBytes(ev.Row.ApproximateSize)
 		}
 		failpoint.Inject("ProcessorSyncResolvedPreEmit", func() {
-			log.Info("Prepare to panic for ProcessorSyncResolvedPreEmit")
+			p.logger.Info("Prepare to panic for ProcessorSyncResolvedPreEmit")
 			time.Sleep(10 * time.Second)
 			panic("ProcessorSyncResolvedPreEmit")
 		})
 		err := sink.EmitRowChangedEvents(ctx, rows...)
 		if err != nil {
+			p.lastSinkError.record(err)
 			return errors.Trace(err)
 		}
 		events = events[:0]
@@ -999,6 +1694,11 @@ func (p *processor) sorterConsume(
 		return nil
 	}
 
+	// sinkFull stops reading the sorter until the sink flushes its pending rows,
+	// it's only set after a resolved event, when all the emitted rows are resolved
+	// by the local resolved ts, so the flush can always drain the sink.
+	sinkFull := false
+
 	globalResolvedTsReceiver, err := p.globalResolvedTsNotifier.NewReceiver(1 * time.Second)
 	if err != nil {
 		if errors.Cause(err) != context.Canceled {
@@ -1009,16 +1709,21 @@ func (p *processor) sorterConsume(
 	defer globalResolvedTsReceiver.Stop()
 
 	for {
+		sorterOutput := sorter.Output()
+		if sinkFull {
+			sorterOutput = nil
+		}
 		select {
 		case <-ctx.Done():
 			if errors.Cause(ctx.Err()) != context.Canceled {
 				p.sendError(ctx.Err())
 			}
 			return
-		case pEvent := <-sorter.Output():
+		case pEvent := <-sorterOutput:
 			if pEvent == nil {
 				continue
 			}
+			pipeline.sortedOut(pEvent)
 
 			pEvent.SetUpFinishedChan()
 			select {
@@ -1043,6 +1748,11 @@ func (p *processor) sorterConsume(
 				}
 				atomic.StoreUint64(pResolvedTs, pEvent.CRTs)
 				lastResolvedTs = pEvent.CRTs
+				sinkFull = isSinkFull(sink)
+				if !initialized {
+					initialized = true
+					p.tableInitialized()
+				}
 				p.localResolvedNotifier.Notify()
 				resolvedTsGauge.Set(float64(oracle.ExtractPhysical(pEvent.CRTs)))
 				if !opDone {
@@ -1051,8 +1761,7 @@ func (p *processor) sorterConsume(
 				continue
 			}
 			if pEvent.CRTs <= lastResolvedTs || pEvent.CRTs < replicaInfo.StartTs {
-				log.Panic("The CRTs of event is not expected, please report a bug",
-					util.ZapFieldChangefeed(ctx),
+				p.logger.Panic("The CRTs of event is not expected, please report a bug",
 					zap.String("model", "sorter"),
 					zap.Uint64("resolvedTs", lastResolvedTs),
 					zap.Int64("tableID", tableID),
@@ -1076,7 +1785,7 @@ func (p *processor) sorterConsume(
 			var minTs uint64
 			if localResolvedTs < globalResolvedTs {
 				minTs = localResolvedTs
-				log.Warn("the local resolved ts is less than the global resolved ts",
+				p.logger.Warn("the local resolved ts is less than the global resolved ts",
 					zap.Uint64("localResolvedTs", localResolvedTs), zap.Uint64("globalResolvedTs", globalResolvedTs))
 			} else {
 				minTs = globalResolvedTs
@@ -1085,14 +1794,19 @@ func (p *processor) sorterConsume(
 				continue
 			}
 
+			flushStart := time.Now()
 			checkpointTs, err := sink.FlushRowChangedEvents(ctx, minTs)
+			p.flushLatencies.observe(time.Since(flushStart))
 			if err != nil {
+				p.lastSinkError.record(err)
 				if errors.Cause(err) != context.Canceled {
 					p.errCh <- errors.Trace(err)
 				}
 				return
 			}
 
+			pipeline.flushed()
+			sinkFull = sinkFull && isSinkFull(sink)
 			if checkpointTs < replicaInfo.StartTs {
 				checkpointTs = replicaInfo.StartTs
 			}
@@ -1109,14 +1823,46 @@ func (p *processor) sorterConsume(
 	}
 }
 
+// isSinkFull returns true if the table sink can't take more rows until its
+// pending rows are flushed.
+func isSinkFull(s sink.Sink) bool {
+	reporter, ok := s.(sink.FullReporter)
+	return ok && reporter.Full()
+}
+
 // pullerConsume receives RawKVEntry from a given puller and sends to sorter
-// for data sorting and mounter for data encode
+// for data sorting and mounter for data encode, the row changes are throttled
+// by the rate limit of the changefeed, and the puller is paused once it runs
+// too far ahead of the table sink checkpoint or the memory quota is exceeded
 func (p *processor) pullerConsume(
 	ctx context.Context,
 	plr puller.Puller,
 	sorter puller.EventSorter,
+	flowController *tableFlowController,
+	pipeline *tablePipeline,
 ) {
+	checkpointTsReceiver, err := p.localCheckpointTsNotifier.NewReceiver(time.Second)
+	if err != nil {
+		if errors.Cause(err) != context.Canceled {
+			p.sendError(err)
+		}
+		return
+	}
+	defer checkpointTsReceiver.Stop()
+	defer flowController.close()
 	for {
+		// the sink checkpoint always catches up the resolved ts sent to the sorter,
+		// so the puller can't be blocked forever
+		for flowController.blocked() {
+			select {
+			case <-ctx.Done():
+				if errors.Cause(ctx.Err()) != context.Canceled {
+					p.sendError(ctx.Err())
+				}
+				return
+			case <-checkpointTsReceiver.C:
+			}
+		}
 		select {
 		case <-ctx.Done():
 			if errors.Cause(ctx.Err()) != context.Canceled {
@@ -1127,6 +1873,14 @@ func (p *processor) pullerConsume(
 			if rawKV == nil {
 				continue
 			}
+			if err := p.rateLimiter.Wait(ctx, rawKV); err != nil {
+				if errors.Cause(err) != context.Canceled {
+					p.sendError(err)
+				}
+				return
+			}
+			flowController.consume(rawKV)
+			pipeline.pulled(rawKV)
 			pEvent := model.NewPolymorphicEvent(rawKV)
 			sorter.AddEntry(ctx, pEvent)
 		}
@@ -1134,7 +1888,7 @@ func (p *processor) pullerConsume(
 }
 
 func (p *processor) stop(ctx context.Context) error {
-	log.Info("stop processor", zap.String("id", p.id), zap.String("capture", p.captureInfo.AdvertiseAddr), zap.String("changefeed", p.changefeedID))
+	p.logger.Info("stop processor", zap.String("id", p.id))
 	p.stateMu.Lock()
 	for _, tbl := range p.tables {
 		tbl.cancel()
@@ -1149,6 +1903,14 @@ func (p *processor) stop(ctx context.Context) error {
 	failpoint.Inject("processorStopDelay", nil)
 	atomic.StoreInt32(&p.stopped, 1)
 	syncTableNumGauge.WithLabelValues(p.changefeedID, p.captureInfo.AdvertiseAddr).Set(0)
+	for _, resource := range resourceUsageTypes {
+		resourceUsageGauge.DeleteLabelValues(p.changefeedID, p.captureInfo.AdvertiseAddr, resource)
+	}
+	usage.Remove(p.changefeedID)
+	// the owner restarts the changefeed after the tasks are deleted, close the
+	// sink before, so the sink is never shared with the restarted one, such as
+	// a switched sink.
+	closeErr := p.sinkManager.Close()
 	if err := p.etcdCli.DeleteTaskPosition(ctx, p.changefeedID, p.captureInfo.ID); err != nil {
 		return err
 	}
@@ -1158,7 +1920,7 @@ func (p *processor) stop(ctx context.Context) error {
 	if err := p.etcdCli.DeleteTaskWorkload(ctx, p.changefeedID, p.captureInfo.ID); err != nil {
 		return err
 	}
-	return p.sinkManager.Close()
+	return closeErr
 }
 
 func (p *processor) isStopped() bool {
@@ -1178,6 +1940,7 @@ func runProcessor(
 	captureInfo model.CaptureInfo,
 	checkpointTs uint64,
 	flushCheckpointInterval time.Duration,
+	authToken string,
 ) (*processor, error) {
 	opts := make(map[string]string, len(info.Opts)+2)
 	for k, v := range info.Opts {
@@ -1200,13 +1963,23 @@ func runProcessor(
 		return nil, errors.Trace(err)
 	}
 	sinkManager := sink.NewManager(ctx, s, errCh, checkpointTs)
+	if spillCfg := info.Config.Sink.Spill; spillCfg != nil && spillCfg.Enable {
+		spillDir := filepath.Join(info.SortDir, "sink-spill", changefeedID)
+		if m := diskmanager.GetGlobal(); m != nil {
+			spillDir = filepath.Join(m.Dir(diskmanager.ComponentSinkSpill), changefeedID)
+		}
+		if err := sinkManager.EnableSpill(ctx, spillDir, spillCfg); err != nil {
+			cancel()
+			return nil, errors.Trace(err)
+		}
+	}
 	processor, err := newProcessor(ctx, pdCli, credential, session, info, sinkManager,
-		changefeedID, captureInfo, checkpointTs, errCh, flushCheckpointInterval)
+		changefeedID, captureInfo, checkpointTs, errCh, flushCheckpointInterval, authToken)
 	if err != nil {
 		cancel()
 		return nil, err
 	}
-	log.Info("start to run processor", zap.String("changefeed", changefeedID), zap.String("processor", processor.id))
+	processor.logger.Info("start to run processor", zap.String("processor", processor.id))
 
 	processorErrorCounter.WithLabelValues(changefeedID, captureInfo.AdvertiseAddr).Add(0)
 	processor.Run(ctx)
@@ -1218,9 +1991,7 @@ func runProcessor(
 		cause := errors.Cause(err)
 		if cause != nil && cause != context.Canceled && cerror.ErrAdminStopProcessor.NotEqual(cause) {
 			processorErrorCounter.WithLabelValues(changefeedID, captureInfo.AdvertiseAddr).Inc()
-			log.Error("error on running processor",
-				util.ZapFieldCapture(ctx),
-				zap.String("changefeed", changefeedID),
+			processor.logger.Error("error on running processor",
 				zap.String("processor", processor.id),
 				zap.Error(err))
 			// record error information in etcd
@@ -1238,13 +2009,11 @@ func runProcessor(
 			timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), 5*time.Second)
 			_, err = processor.etcdCli.PutTaskPositionOnChange(timeoutCtx, processor.changefeedID, processor.captureInfo.ID, processor.position)
 			if err != nil {
-				log.Warn("upload processor error failed", util.ZapFieldChangefeed(ctx), zap.Error(err))
+				processor.logger.Warn("upload processor error failed", zap.Error(err))
 			}
 			timeoutCancel()
 		} else {
-			log.Info("processor exited",
-				util.ZapFieldCapture(ctx),
-				zap.String("changefeed", changefeedID),
+			processor.logger.Info("processor exited",
 				zap.String("processor", processor.id))
 		}
 	}()
@@ -1252,10 +2021,18 @@ func runProcessor(
 	return processor, nil
 }
 
+// recoverPanic sends the panic of a routine of the table pipelines as an error
+// of the processor, it must be deferred directly by the routine.
+func (p *processor) recoverPanic(ctx context.Context, component string) {
+	if r := recover(); r != nil {
+		p.sendError(util.PanicError(ctx, component, r))
+	}
+}
+
 func (p *processor) sendError(err error) {
 	select {
 	case p.errCh <- err:
 	default:
-		log.Error("processor receives redundant error", zap.Error(err))
+		p.logger.Error("processor receives redundant error", zap.Error(err))
 	}
 }
@@ -0,0 +1,223 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc/model"
+)
+
+// defaultLatencyWindowSize is the number of the latest latencies kept by a
+// processor, which are enough to estimate the percentiles.
+const defaultLatencyWindowSize = 1024
+
+// tableTraffic counts the rows and the bytes emitted to the sink of a table.
+type tableTraffic struct {
+	rows  uint64
+	bytes uint64
+}
+
+// add counts a row, it's a no-op on a nil tableTraffic, such as the one of a
+// mark table.
+func (t *tableTraffic) add(row *model.RowChangedEvent) {
+	if t == nil {
+		return
+	}
+	atomic.AddUint64(&t.rows, 1)
+	atomic.AddUint64(&t.bytes, uint64(row.ApproximateSize))
+}
+
+// tablePipeline tracks the progress of a table through the puller, the sorter
+// and the sink, the times are in unix nanoseconds.
+type tablePipeline struct {
+	// added and sorted are the numbers of the rows sent to and output by
+	// the sorter
+	added       uint64
+	sorted      uint64
+	lastPulled  int64
+	lastSorted  int64
+	lastFlushed int64
+	// flowController is set before the puller is started, with stateMu held
+	flowController *tableFlowController
+}
+
+// pulled records an event sent to the sorter, it's a no-op on a nil
+// tablePipeline, such as the one of a mark table.
+func (t *tablePipeline) pulled(entry *model.RawKVEntry) {
+	if t == nil {
+		return
+	}
+	if entry.OpType != model.OpTypeResolved {
+		atomic.AddUint64(&t.added, 1)
+	}
+	atomic.StoreInt64(&t.lastPulled, time.Now().UnixNano())
+}
+
+// sortedOut records an event output by the sorter.
+func (t *tablePipeline) sortedOut(ev *model.PolymorphicEvent) {
+	if t == nil {
+		return
+	}
+	if ev.RawKV == nil || ev.RawKV.OpType != model.OpTypeResolved {
+		atomic.AddUint64(&t.sorted, 1)
+	}
+	atomic.StoreInt64(&t.lastSorted, time.Now().UnixNano())
+}
+
+// flushed records a successful flush of the table sink.
+func (t *tablePipeline) flushed() {
+	if t == nil {
+		return
+	}
+	atomic.StoreInt64(&t.lastFlushed, time.Now().UnixNano())
+}
+
+func (t *tablePipeline) snapshot(table *tableInfo) *model.TablePipeline {
+	ret := &model.TablePipeline{
+		SortedResolvedTs: atomic.LoadUint64(&table.resolvedTs),
+		CheckpointTs:     atomic.LoadUint64(&table.checkpointTs),
+		LastPulled:       unixNanoTime(atomic.LoadInt64(&t.lastPulled)),
+		LastSorted:       unixNanoTime(atomic.LoadInt64(&t.lastSorted)),
+		LastFlushed:      unixNanoTime(atomic.LoadInt64(&t.lastFlushed)),
+	}
+	// the rows are counted by the sorter consumer after the puller consumer,
+	// so load the sorted one first
+	sorted := atomic.LoadUint64(&t.sorted)
+	if added := atomic.LoadUint64(&t.added); added > sorted {
+		ret.SorterBacklog = added - sorted
+	}
+	if t.flowController != nil {
+		ret.PulledResolvedTs = t.flowController.sentResolvedTs()
+		ret.FlowControlUsage = t.flowController.usage()
+	}
+	return ret
+}
+
+func unixNanoTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// lastSinkError keeps the last error returned by the sinks of a processor.
+type lastSinkError struct {
+	mu  sync.Mutex
+	err *model.SinkError
+}
+
+// record keeps the error, the canceled errors are ignored.
+func (e *lastSinkError) record(err error) {
+	if errors.Cause(err) == context.Canceled {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.err = &model.SinkError{Time: time.Now(), Message: err.Error()}
+}
+
+func (e *lastSinkError) load() *model.SinkError {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		return nil
+	}
+	ret := *e.err
+	return &ret
+}
+
+// latencyWindow keeps the latest latencies in a ring buffer.
+type latencyWindow struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+	full      bool
+}
+
+func newLatencyWindow(size int) *latencyWindow {
+	return &latencyWindow{latencies: make([]time.Duration, size)}
+}
+
+// observe records a latency, it's a no-op on a nil latencyWindow.
+func (w *latencyWindow) observe(latency time.Duration) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.latencies[w.next] = latency
+	w.next++
+	if w.next == len(w.latencies) {
+		w.next = 0
+		w.full = true
+	}
+}
+
+// seconds returns the latencies in the window in seconds, in ascending order.
+func (w *latencyWindow) seconds() []float64 {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := w.next
+	if w.full {
+		n = len(w.latencies)
+	}
+	ret := make([]float64, 0, n)
+	for _, latency := range w.latencies[:n] {
+		ret = append(ret, latency.Seconds())
+	}
+	sort.Float64s(ret)
+	return ret
+}
+
+// stats returns the statistics of the processor.
+func (p *processor) stats() *model.ProcessorStats {
+	stats := &model.ProcessorStats{
+		ChangefeedID:   p.changefeedID,
+		CaptureID:      p.captureInfo.ID,
+		FlushLatencies: p.flushLatencies.seconds(),
+		BarrierTs:      atomic.LoadUint64(&p.globalResolvedTs),
+		CheckpointTs:   atomic.LoadUint64(&p.checkpointTs),
+		ResolvedTs:     atomic.LoadUint64(&p.localResolvedTs),
+		Usage:          p.usage.Snapshot(),
+		LastSinkError:  p.lastSinkError.load(),
+	}
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	stats.Tables = make([]model.TableTraffic, 0, len(p.tables))
+	for _, table := range p.tables {
+		traffic := model.TableTraffic{
+			TableID: table.id,
+			Table:   table.name,
+			Rows:    atomic.LoadUint64(&table.traffic.rows),
+			Bytes:   atomic.LoadUint64(&table.traffic.bytes),
+		}
+		traffic.Pipeline = table.pipeline.snapshot(table)
+		stats.Rows += traffic.Rows
+		stats.Bytes += traffic.Bytes
+		stats.Tables = append(stats.Tables, traffic)
+	}
+	sort.Slice(stats.Tables, func(i, j int) bool {
+		return stats.Tables[i].TableID < stats.Tables[j].TableID
+	})
+	return stats
+}
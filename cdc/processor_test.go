@@ -15,11 +15,21 @@ package cdc
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"time"
 
 	"github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/cdc/entry"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/notify"
 	"github.com/pingcap/ticdc/pkg/util/testleak"
+	"github.com/pingcap/tidb/store/tikv/oracle"
 )
 
 type processorSuite struct{}
@@ -30,6 +40,7 @@ func (s *processorSuite) TestWriteDebugInfo(c *check.C) {
 	defer testleak.AfterTest(c)()
 	p := &processor{
 		changefeedID: "test",
+		logger:       log.L(),
 		changefeed: model.ChangeFeedInfo{
 			SinkURI: "blackhole://",
 			Config:  config.GetDefaultReplicaConfig(),
@@ -47,6 +58,129 @@ func (s *processorSuite) TestWriteDebugInfo(c *check.C) {
 	c.Assert(buf.String(), check.Matches, `changefeedID[\s\S]*info[\s\S]*tables[\s\S]*`)
 }
 
+func (s *processorSuite) TestSchemaBuildWorker(c *check.C) {
+	defer testleak.AfterTest(c)()
+	schemaStorage, err := entry.NewSchemaStorage(nil, 0, nil, false)
+	c.Assert(err, check.IsNil)
+	p := &processor{
+		changefeedID:          "test",
+		logger:                log.L(),
+		schemaStorage:         schemaStorage,
+		localResolvedNotifier: new(notify.Notifier),
+	}
+	defer p.localResolvedNotifier.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ddlCh := make(chan ddlEvent, defaultDDLQueueSize)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.schemaBuildWorker(ctx, ddlCh)
+	}()
+	for i := int64(1); i <= 100; i++ {
+		ddlCh <- ddlEvent{job: &timodel.Job{
+			ID:       i,
+			State:    timodel.JobStateSynced,
+			SchemaID: i,
+			Type:     timodel.ActionCreateSchema,
+			BinlogInfo: &timodel.HistoryInfo{
+				SchemaVersion: i,
+				DBInfo:        &timodel.DBInfo{ID: i, Name: timodel.NewCIStr(fmt.Sprintf("test%d", i))},
+				FinishedTS:    uint64(i * 10),
+			},
+			Query: fmt.Sprintf("create database test%d", i),
+		}}
+	}
+	ddlCh <- ddlEvent{resolvedTs: 2000}
+
+	getCtx, getCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer getCancel()
+	snap, err := schemaStorage.GetSnapshot(getCtx, 2000)
+	c.Assert(err, check.IsNil)
+	for i := int64(1); i <= 100; i++ {
+		_, ok := snap.SchemaByID(i)
+		c.Assert(ok, check.IsTrue)
+	}
+	snap, err = schemaStorage.GetSnapshot(getCtx, 505)
+	c.Assert(err, check.IsNil)
+	_, ok := snap.SchemaByID(50)
+	c.Assert(ok, check.IsTrue)
+	_, ok = snap.SchemaByID(51)
+	c.Assert(ok, check.IsFalse)
+
+	cancel()
+	c.Assert(errors.Cause(<-errCh), check.Equals, context.Canceled)
+}
+
+func (s *processorSuite) TestSkipUnchangedPosition(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	// the etcd client is not set, the flushes must not touch etcd
+	p := &processor{
+		changefeedID:          "test",
+		logger:                log.L(),
+		position:              &model.TaskPosition{CheckPointTs: 100, ResolvedTs: 200},
+		lastFlushedPosition:   &model.TaskPosition{CheckPointTs: 100, ResolvedTs: 200},
+		lastPositionFlushTime: time.Now(),
+		lastStatusSyncTime:    time.Now(),
+	}
+	c.Assert(p.flushTaskPosition(ctx), check.IsNil)
+	// the task status is not synced if it's not changed
+	c.Assert(p.flushTaskStatusAndPosition(ctx), check.IsNil)
+	c.Assert(p.statusOpsUnapplied, check.IsFalse)
+}
+
+func (s *processorSuite) TestPendingTables(c *check.C) {
+	defer testleak.AfterTest(c)()
+	p := &processor{
+		changefeedID:  "test",
+		logger:        log.L(),
+		position:      &model.TaskPosition{CheckPointTs: 300, ResolvedTs: 400},
+		tables:        map[int64]*tableInfo{1: {id: 1}},
+		pendingTables: make(map[int64]*model.TableReplicaInfo),
+		tableInitCh:   make(chan struct{}, 1),
+	}
+	c.Assert(p.oldestPendingTablesLocked(10), check.HasLen, 0)
+	c.Assert(p.pendingTablesMinStartTsLocked(), check.Equals, uint64(math.MaxUint64))
+
+	// the started table is not pending
+	p.addPendingTableLocked(1, &model.TableReplicaInfo{StartTs: 100})
+	for tableID, startTs := range map[int64]uint64{2: 500, 3: 200, 4: 350, 5: 200} {
+		p.addPendingTableLocked(tableID, &model.TableReplicaInfo{StartTs: startTs})
+	}
+	c.Assert(p.pendingTables, check.HasLen, 4)
+	c.Assert(p.pendingTablesMinStartTsLocked(), check.Equals, uint64(200))
+	c.Assert(p.position, check.DeepEquals, &model.TaskPosition{CheckPointTs: 200, ResolvedTs: 200})
+	c.Assert(len(p.tableInitCh), check.Equals, 1)
+
+	// the tables with the smallest start ts are popped first
+	c.Assert(p.oldestPendingTablesLocked(0), check.HasLen, 0)
+	c.Assert(p.oldestPendingTablesLocked(3), check.DeepEquals, []model.TableID{3, 5, 4})
+	c.Assert(p.oldestPendingTablesLocked(10), check.DeepEquals, []model.TableID{3, 5, 4, 2})
+}
+
+func (s *processorSuite) TestFindSkewedTables(c *check.C) {
+	defer testleak.AfterTest(c)()
+	now := time.Now()
+	ts := func(lag time.Duration) uint64 {
+		return oracle.ComposeTS(oracle.GetPhysical(now.Add(-lag)), 0)
+	}
+	tables := []tableSkew{
+		{id: 1, name: "`test`.`t1`", checkpointTs: ts(0)},
+		{id: 2, name: "`test`.`t2`", checkpointTs: ts(5 * time.Minute)},
+		{id: 3, name: "`test`.`t3`", checkpointTs: ts(20 * time.Minute)},
+		{id: 4, name: "`test`.`t3`", checkpointTs: ts(15 * time.Minute)},
+		// the table without checkpoint is skipped
+		{id: 5, name: "`test`.`t4`"},
+	}
+	skews := findSkewedTables(tables, 10*time.Minute)
+	c.Assert(skews, check.DeepEquals, []tableSkew{
+		{id: 3, name: "`test`.`t3`", checkpointTs: ts(20 * time.Minute), lag: 20 * time.Minute},
+		{id: 4, name: "`test`.`t3`", checkpointTs: ts(15 * time.Minute), lag: 15 * time.Minute},
+	})
+	c.Assert(findSkewedTables(tables, 30*time.Minute), check.HasLen, 0)
+	c.Assert(findSkewedTables(nil, 0), check.HasLen, 0)
+}
+
 /*
 import (
 	"context"
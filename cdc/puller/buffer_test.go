@@ -20,7 +20,6 @@ import (
 
 	"github.com/pingcap/check"
 	"github.com/pingcap/ticdc/cdc/model"
-	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/ticdc/pkg/regionspan"
 	"github.com/pingcap/ticdc/pkg/util/testleak"
 )
@@ -92,44 +91,3 @@ func (bs *bufferSuite) TestWaitsCanBeCanceled(c *check.C) {
 		c.Fatal("AddEntry doesn't stop in time.")
 	}
 }
-
-type memBufferSuite struct{}
-
-var _ = check.Suite(&memBufferSuite{})
-
-func (bs *memBufferSuite) TestMemBuffer(c *check.C) {
-	defer testleak.AfterTest(c)()
-	limitter := NewBlurResourceLimmter(1024 * 1024)
-	bf := makeMemBuffer(limitter)
-
-	var err error
-	var entries []model.RegionFeedEvent
-	for {
-		entry := model.RegionFeedEvent{
-			Val: &model.RawKVEntry{
-				Value: make([]byte, 1024),
-			},
-		}
-		err = bf.AddEntry(context.Background(), entry)
-		if err != nil {
-			break
-		}
-
-		entries = append(entries, entry)
-	}
-
-	c.Assert(cerror.ErrBufferReachLimit.Equal(err), check.IsTrue)
-	num := float64(bf.mu.entries.Len())
-	nearNum := 1024.0
-	c.Assert(num >= nearNum*0.9, check.IsTrue)
-	c.Assert(num <= nearNum*1.1, check.IsTrue)
-
-	// Check can get back the entries.
-	var getEntries []model.RegionFeedEvent
-	for len(getEntries) < len(entries) {
-		entry, err := bf.Get(context.Background())
-		c.Assert(err, check.IsNil)
-		getEntries = append(getEntries, entry)
-	}
-	c.Assert(getEntries, check.DeepEquals, entries)
-}
@@ -0,0 +1,189 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puller
+
+import (
+	"bytes"
+	"container/heap"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"go.uber.org/zap"
+)
+
+// defaultDedupMaxKeys is the max number of the keys the deduplicator of a
+// puller records if it's not configured. The keys are recorded only until the
+// resolved ts is forwarded, so the limit is reached only by the large
+// transactions or a stuck resolved ts.
+const defaultDedupMaxKeys = 16 * 1024
+
+// tsHeap is a min-heap of the commit ts.
+type tsHeap []uint64
+
+func (h tsHeap) Len() int           { return len(h) }
+func (h tsHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h tsHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *tsHeap) Push(x interface{}) {
+	*h = append(*h, x.(uint64))
+}
+
+func (h *tsHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+// keySpan is the position of a key in keySet.data.
+type keySpan struct {
+	start, end uint32
+	// next is the index of the previous key with the same hash, or -1
+	next int32
+}
+
+// keySet is a set of the keys committed at a ts. The keys are copied into a
+// shared buffer and indexed by their hashes, so recording a key doesn't
+// allocate a string for it.
+type keySet struct {
+	data  []byte
+	spans []keySpan
+	heads map[uint64]int32
+}
+
+func newKeySet() *keySet {
+	return &keySet{heads: make(map[uint64]int32)}
+}
+
+func (s *keySet) len() int {
+	return len(s.spans)
+}
+
+// add records the key, it returns false if the key has been recorded.
+func (s *keySet) add(key []byte) bool {
+	h := hashKey(key)
+	head, ok := s.heads[h]
+	if ok {
+		for i := head; i >= 0; i = s.spans[i].next {
+			span := s.spans[i]
+			if bytes.Equal(s.data[span.start:span.end], key) {
+				return false
+			}
+		}
+	} else {
+		head = -1
+	}
+	start := uint32(len(s.data))
+	s.data = append(s.data, key...)
+	s.spans = append(s.spans, keySpan{start: start, end: uint32(len(s.data)), next: head})
+	s.heads[h] = int32(len(s.spans) - 1)
+	return true
+}
+
+// hashKey returns the 64-bit FNV-1a hash of the key.
+func hashKey(key []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, b := range key {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	return h
+}
+
+// deduplicator drops the kv events delivered more than once. After a region
+// error, the kv client reconnects the region and scans it again from the
+// resolved ts of the region, so the events committed after that ts may be
+// received twice, which leads to duplicated writes in the sinks without the
+// safe mode. A key is written at most once at a commit ts, so the events are
+// identified by their commit ts and keys.
+//
+// At most maxKeys keys are recorded, the keys of the smallest commit ts are
+// forgotten first once the limit is reached, and the events committed at or
+// before the forgotten ones are passed through without deduplication. The
+// deduplication is disabled if maxKeys is 0.
+type deduplicator struct {
+	// seen records the keys of the output events by their commit ts, the
+	// events committed before the resolved ts can't be received again, so
+	// they are forgotten once the resolved ts is forwarded.
+	seen map[uint64]*keySet
+	// tsHeap keeps the commit ts in seen, so the smallest ones are forgotten
+	// without scanning seen.
+	tsHeap  tsHeap
+	keys    int
+	maxKeys int
+	// evictedTs is the max commit ts whose keys are forgotten before the
+	// resolved ts is forwarded.
+	evictedTs uint64
+	// limited is true from the time the keys are first forgotten until the
+	// resolved ts passes the forgotten ones, it's logged once in the period.
+	limited bool
+}
+
+func newDeduplicator(maxKeys int) *deduplicator {
+	return &deduplicator{
+		seen:    make(map[uint64]*keySet),
+		maxKeys: maxKeys,
+	}
+}
+
+// duplicated returns whether the event has been output before, the event is
+// recorded if it's not a duplicate.
+func (d *deduplicator) duplicated(raw *model.RawKVEntry) bool {
+	if d.maxKeys <= 0 || raw.CRTs <= d.evictedTs {
+		return false
+	}
+	keys, ok := d.seen[raw.CRTs]
+	if !ok {
+		keys = newKeySet()
+		d.seen[raw.CRTs] = keys
+		heap.Push(&d.tsHeap, raw.CRTs)
+	}
+	if !keys.add(raw.Key) {
+		return true
+	}
+	d.keys++
+	if d.keys > d.maxKeys {
+		d.evict()
+	}
+	return false
+}
+
+// evict forgets the keys of the smallest commit ts until the number of the
+// keys is within the limit.
+func (d *deduplicator) evict() {
+	for d.keys > d.maxKeys && d.tsHeap.Len() > 0 {
+		ts := heap.Pop(&d.tsHeap).(uint64)
+		d.keys -= d.seen[ts].len()
+		delete(d.seen, ts)
+		d.evictedTs = ts
+	}
+	if !d.limited {
+		d.limited = true
+		log.Warn("too many keys to deduplicate, the events committed before are not deduplicated",
+			zap.Int("max-keys", d.maxKeys), zap.Uint64("evicted-ts", d.evictedTs))
+	}
+}
+
+// resolve forgets the events committed at or before the resolved ts.
+func (d *deduplicator) resolve(resolvedTs uint64) {
+	for d.tsHeap.Len() > 0 && d.tsHeap[0] <= resolvedTs {
+		ts := heap.Pop(&d.tsHeap).(uint64)
+		d.keys -= d.seen[ts].len()
+		delete(d.seen, ts)
+	}
+	if d.limited && d.evictedTs <= resolvedTs {
+		d.limited = false
+	}
+}
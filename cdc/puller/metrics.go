@@ -38,6 +38,13 @@ var (
 			Name:      "txn_collect_event_count",
 			Help:      "The number of events received from txn collector",
 		}, []string{"capture", "changefeed", "table", "type"})
+	duplicatedEventCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ticdc",
+			Subsystem: "puller",
+			Name:      "duplicated_event_count",
+			Help:      "The number of duplicated events dropped by puller, which are re-delivered after region reconnects",
+		}, []string{"capture", "changefeed", "table"})
 	pullerResolvedTsGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "ticdc",
@@ -57,7 +64,7 @@ var (
 			Namespace: "ticdc",
 			Subsystem: "puller",
 			Name:      "mem_buffer_size",
-			Help:      "Puller in memory buffer size",
+			Help:      "The number of events in the puller buffer",
 		}, []string{"capture", "changefeed", "table"})
 	eventChanSizeGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -109,6 +116,7 @@ var (
 func InitMetrics(registry *prometheus.Registry) {
 	registry.MustRegister(kvEventCounter)
 	registry.MustRegister(txnCollectCounter)
+	registry.MustRegister(duplicatedEventCounter)
 	registry.MustRegister(pullerResolvedTsGauge)
 	registry.MustRegister(memBufferSizeGauge)
 	registry.MustRegister(outputChanSizeGauge)
@@ -23,6 +23,7 @@ import (
 	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/cdc/puller/frontier"
+	"github.com/pingcap/ticdc/pkg/config"
 	"github.com/pingcap/ticdc/pkg/regionspan"
 	"github.com/pingcap/ticdc/pkg/security"
 	"github.com/pingcap/ticdc/pkg/txnutil"
@@ -56,7 +57,7 @@ type pullerImpl struct {
 	kvStorage      tikv.Storage
 	checkpointTs   uint64
 	spans          []regionspan.ComparableSpan
-	buffer         *memBuffer
+	buffer         ChanBuffer
 	outputCh       chan *model.RawKVEntry
 	tsTracker      frontier.Frontier
 	resolvedTs     uint64
@@ -73,7 +74,6 @@ func NewPuller(
 	kvStorage tidbkv.Storage,
 	checkpointTs uint64,
 	spans []regionspan.Span,
-	limitter *BlurResourceLimitter,
 	enableOldValue bool,
 ) Puller {
 	tikvStorage, ok := kvStorage.(tikv.Storage)
@@ -96,7 +96,7 @@ func NewPuller(
 		kvStorage:      tikvStorage,
 		checkpointTs:   checkpointTs,
 		spans:          comparableSpans,
-		buffer:         makeMemBuffer(limitter),
+		buffer:         makeChanBuffer(),
 		outputCh:       make(chan *model.RawKVEntry, defaultPullerOutputChanSize),
 		tsTracker:      tsTracker,
 		resolvedTs:     checkpointTs,
@@ -139,6 +139,7 @@ func (p *pullerImpl) Run(ctx context.Context) error {
 	metricEventCounterResolved := kvEventCounter.WithLabelValues(captureAddr, changefeedID, "resolved")
 	metricTxnCollectCounterKv := txnCollectCounter.WithLabelValues(captureAddr, changefeedID, tableName, "kv")
 	metricTxnCollectCounterResolved := txnCollectCounter.WithLabelValues(captureAddr, changefeedID, tableName, "resolved")
+	metricDuplicatedEventCounter := duplicatedEventCounter.WithLabelValues(captureAddr, changefeedID, tableName)
 	defer func() {
 		outputChanSizeGauge.DeleteLabelValues(captureAddr, changefeedID, tableName)
 		eventChanSizeGauge.DeleteLabelValues(captureAddr, changefeedID, tableName)
@@ -148,6 +149,7 @@ func (p *pullerImpl) Run(ctx context.Context) error {
 		kvEventCounter.DeleteLabelValues(captureAddr, changefeedID, "resolved")
 		txnCollectCounter.DeleteLabelValues(captureAddr, changefeedID, tableName, "kv")
 		txnCollectCounter.DeleteLabelValues(captureAddr, changefeedID, tableName, "resolved")
+		duplicatedEventCounter.DeleteLabelValues(captureAddr, changefeedID, tableName)
 	}()
 	g.Go(func() error {
 		for {
@@ -156,7 +158,7 @@ func (p *pullerImpl) Run(ctx context.Context) error {
 				return nil
 			case <-time.After(15 * time.Second):
 				metricEventChanSize.Set(float64(len(eventCh)))
-				metricMemBufferSize.Set(float64(p.buffer.Size()))
+				metricMemBufferSize.Set(float64(len(p.buffer)))
 				metricOutputChanSize.Set(float64(len(p.outputCh)))
 				metricPullerResolvedTs.Set(float64(oracle.ExtractPhysical(atomic.LoadUint64(&p.resolvedTs))))
 			}
@@ -196,6 +198,11 @@ func (p *pullerImpl) Run(ctx context.Context) error {
 	})
 
 	lastResolvedTs := p.checkpointTs
+	dedupMaxKeys := defaultDedupMaxKeys
+	if cfg := config.GetKVClientConfig(); cfg != nil {
+		dedupMaxKeys = cfg.DedupMaxKeys
+	}
+	dedup := newDeduplicator(dedupMaxKeys)
 	g.Go(func() error {
 		output := func(raw *model.RawKVEntry) error {
 			if raw.CRTs < p.resolvedTs || (raw.CRTs == p.resolvedTs && raw.OpType != model.OpTypeResolved) {
@@ -222,6 +229,14 @@ func (p *pullerImpl) Run(ctx context.Context) error {
 			}
 			if e.Val != nil {
 				metricTxnCollectCounterKv.Inc()
+				// the events of the initial scan are too many to record, and
+				// the scan is retried from the checkpoint ts anyway
+				if initialized && dedup.duplicated(e.Val) {
+					metricDuplicatedEventCounter.Inc()
+					log.Debug("drop the duplicated event",
+						zap.Reflect("row", e.Val), zap.Int64("tableID", tableID))
+					continue
+				}
 				if err := output(e.Val); err != nil {
 					return errors.Trace(err)
 				}
@@ -263,6 +278,7 @@ func (p *pullerImpl) Run(ctx context.Context) error {
 					return errors.Trace(err)
 				}
 				atomic.StoreUint64(&p.resolvedTs, resolvedTs)
+				dedup.resolve(resolvedTs)
 			}
 		}
 	})
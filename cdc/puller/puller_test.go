@@ -123,7 +123,7 @@ func (s *pullerSuite) newPullerForTest(
 		kv.NewCDCKVClient = backupNewCDCKVClient
 	}()
 	pdCli := &mockPdClientForPullerTest{clusterID: uint64(1)}
-	plr := NewPuller(ctx, pdCli, nil /* credential */, store, checkpointTs, spans, nil /* limitter */, enableOldValue)
+	plr := NewPuller(ctx, pdCli, nil /* credential */, store, checkpointTs, spans, enableOldValue)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -170,13 +170,13 @@ func (s *pullerSuite) TestPullerResolvedForward(c *check.C) {
 	c.Assert(ev.OpType, check.Equals, model.OpTypeResolved)
 	c.Assert(ev.CRTs, check.Equals, uint64(1000))
 	c.Assert(plr.IsInitialized(), check.IsTrue)
-	err := retry.Run(time.Millisecond*10, 10, func() error {
+	err := retry.Do(context.Background(), func() error {
 		ts := plr.GetResolvedTs()
 		if ts != uint64(1000) {
 			return errors.Errorf("resolved ts %d of puller does not forward to 1000", ts)
 		}
 		return nil
-	})
+	}, retry.WithBackoffBaseDelay(time.Millisecond*10), retry.WithMaxTries(11))
 	c.Assert(err, check.IsNil)
 
 	store.Close()
@@ -217,3 +217,113 @@ func (s *pullerSuite) TestPullerRawKV(c *check.C) {
 	cancel()
 	wg.Wait()
 }
+
+func (s *pullerSuite) TestPullerDeduplicate(c *check.C) {
+	defer testleak.AfterTest(c)()
+	spans := []regionspan.Span{
+		{Start: []byte("c"), End: []byte("e")},
+	}
+	checkpointTs := uint64(996)
+	plr, cancel, wg, store := s.newPullerForTest(c, spans, checkpointTs)
+
+	put := func(key string, ts uint64) {
+		plr.cli.Returns(&model.RegionFeedEvent{
+			Val: &model.RawKVEntry{
+				OpType: model.OpTypePut,
+				Key:    []byte(key),
+				Value:  []byte("test-value"),
+				CRTs:   ts,
+			},
+		})
+	}
+	// the events of the initial scan are not deduplicated
+	put("c", 1000)
+	put("c", 1000)
+	plr.cli.Returns(&model.RegionFeedEvent{
+		Resolved: &model.ResolvedSpan{
+			Span:       regionspan.ToComparableSpan(spans[0]),
+			ResolvedTs: uint64(1001),
+		},
+	})
+	put("c", 1002)
+	put("d", 1002)
+	// the events are re-delivered after the region is reconnected
+	put("c", 1002)
+	put("d", 1002)
+	put("d", 1003)
+	plr.cli.Returns(&model.RegionFeedEvent{
+		Resolved: &model.ResolvedSpan{
+			Span:       regionspan.ToComparableSpan(spans[0]),
+			ResolvedTs: uint64(1003),
+		},
+	})
+	for i := 0; i < 2; i++ {
+		ev := <-plr.Output()
+		c.Assert(ev.Key, check.DeepEquals, []byte("c"))
+		c.Assert(ev.CRTs, check.Equals, uint64(1000))
+	}
+	ev := <-plr.Output()
+	c.Assert(ev.OpType, check.Equals, model.OpTypeResolved)
+	c.Assert(ev.CRTs, check.Equals, uint64(1001))
+	expected := []struct {
+		key string
+		ts  uint64
+	}{{"c", 1002}, {"d", 1002}, {"d", 1003}}
+	for _, exp := range expected {
+		ev := <-plr.Output()
+		c.Assert(ev.OpType, check.Equals, model.OpTypePut)
+		c.Assert(ev.Key, check.DeepEquals, []byte(exp.key))
+		c.Assert(ev.CRTs, check.Equals, exp.ts)
+	}
+	ev = <-plr.Output()
+	c.Assert(ev.OpType, check.Equals, model.OpTypeResolved)
+	c.Assert(ev.CRTs, check.Equals, uint64(1003))
+
+	store.Close()
+	cancel()
+	wg.Wait()
+}
+
+func (s *pullerSuite) TestDeduplicator(c *check.C) {
+	defer testleak.AfterTest(c)()
+	d := newDeduplicator(3)
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("a"), CRTs: 10}), check.IsFalse)
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("a"), CRTs: 10}), check.IsTrue)
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("a"), CRTs: 11}), check.IsFalse)
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("b"), CRTs: 11}), check.IsFalse)
+	d.resolve(10)
+	c.Assert(d.seen, check.HasLen, 1)
+	c.Assert(d.seen[11].len(), check.Equals, 2)
+	d.resolve(11)
+	c.Assert(d.seen, check.HasLen, 0)
+	c.Assert(d.keys, check.Equals, 0)
+
+	// the keys of the smallest commit ts are forgotten once the limit is reached
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("a"), CRTs: 13}), check.IsFalse)
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("a"), CRTs: 12}), check.IsFalse)
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("b"), CRTs: 12}), check.IsFalse)
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("b"), CRTs: 13}), check.IsFalse)
+	c.Assert(d.seen, check.HasLen, 1)
+	c.Assert(d.keys, check.Equals, 2)
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("a"), CRTs: 12}), check.IsFalse)
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("a"), CRTs: 13}), check.IsTrue)
+	c.Assert(d.limited, check.IsTrue)
+	d.resolve(13)
+	c.Assert(d.seen, check.HasLen, 0)
+	c.Assert(d.tsHeap, check.HasLen, 0)
+	c.Assert(d.limited, check.IsFalse)
+
+	// the keys with the same hash are told apart
+	keys := newKeySet()
+	c.Assert(keys.add([]byte("a")), check.IsTrue)
+	keys.heads[hashKey([]byte("b"))] = keys.heads[hashKey([]byte("a"))]
+	c.Assert(keys.add([]byte("b")), check.IsTrue)
+	c.Assert(keys.add([]byte("a")), check.IsFalse)
+	c.Assert(keys.add([]byte("b")), check.IsFalse)
+	c.Assert(keys.len(), check.Equals, 2)
+
+	// the deduplication is disabled
+	d = newDeduplicator(0)
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("a"), CRTs: 10}), check.IsFalse)
+	c.Assert(d.duplicated(&model.RawKVEntry{Key: []byte("a"), CRTs: 10}), check.IsFalse)
+}
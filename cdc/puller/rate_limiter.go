@@ -0,0 +1,112 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puller
+
+import (
+	"context"
+	"math"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter limits the rows and bytes per second of the row changes sent
+// from the pullers to the sorters. The resolved events are never limited,
+// otherwise the progress of the changefeed would be blocked.
+//
+// The limits of the config are the limits of the whole changefeed, each
+// capture replicating the changefeed gets an even share of them, see
+// SetCaptureCount.
+type RateLimiter struct {
+	cfg   config.RateLimitConfig
+	rows  *rate.Limiter
+	bytes *rate.Limiter
+}
+
+// NewRateLimiter creates a RateLimiter, it returns nil if the throughput
+// is unlimited.
+func NewRateLimiter(cfg *config.RateLimitConfig) *RateLimiter {
+	if cfg == nil || (cfg.RowsPerSecond == 0 && cfg.BytesPerSecond == 0) {
+		return nil
+	}
+	l := &RateLimiter{cfg: *cfg}
+	if cfg.RowsPerSecond != 0 {
+		l.rows = rate.NewLimiter(rate.Limit(cfg.RowsPerSecond), burst(cfg.RowsPerSecond))
+	}
+	if cfg.BytesPerSecond != 0 {
+		l.bytes = rate.NewLimiter(rate.Limit(cfg.BytesPerSecond), burst(cfg.BytesPerSecond))
+	}
+	return l
+}
+
+// SetCaptureCount shares the limits among the captures the changefeed is
+// assigned to. The limits are not changed if count is not positive.
+func (l *RateLimiter) SetCaptureCount(count int) {
+	if l == nil || count <= 0 {
+		return
+	}
+	if l.rows != nil {
+		limit := share(l.cfg.RowsPerSecond, count)
+		l.rows.SetLimit(rate.Limit(limit))
+		l.rows.SetBurst(burst(limit))
+	}
+	if l.bytes != nil {
+		limit := share(l.cfg.BytesPerSecond, count)
+		l.bytes.SetLimit(rate.Limit(limit))
+		l.bytes.SetBurst(burst(limit))
+	}
+}
+
+// share returns the share of the limit of one of count captures, it's at
+// least 1 so that the capture is never blocked forever.
+func share(limit uint64, count int) uint64 {
+	limit /= uint64(count)
+	if limit == 0 {
+		return 1
+	}
+	return limit
+}
+
+// burst allows the limiter to send the events of one second at once.
+func burst(limit uint64) int {
+	if limit > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(limit)
+}
+
+// Wait blocks until the entry is allowed to be sent or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, entry *model.RawKVEntry) error {
+	if l == nil || entry.OpType == model.OpTypeResolved {
+		return nil
+	}
+	if l.rows != nil {
+		if err := l.rows.Wait(ctx); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if l.bytes != nil {
+		// an entry larger than the burst takes the tokens of the whole burst
+		n := entry.ApproximateSize()
+		if n > int64(l.bytes.Burst()) {
+			n = int64(l.bytes.Burst())
+		}
+		if err := l.bytes.WaitN(ctx, int(n)); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package puller
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+	"golang.org/x/time/rate"
+)
+
+type rateLimiterSuite struct{}
+
+var _ = check.Suite(&rateLimiterSuite{})
+
+func (s *rateLimiterSuite) TestRateLimiter(c *check.C) {
+	defer testleak.AfterTest(c)()
+	c.Assert(NewRateLimiter(nil), check.IsNil)
+	c.Assert(NewRateLimiter(&config.RateLimitConfig{}), check.IsNil)
+	ctx := context.Background()
+	row := &model.RawKVEntry{OpType: model.OpTypePut, Key: []byte("k"), Value: make([]byte, 99)}
+	resolved := &model.RawKVEntry{OpType: model.OpTypeResolved}
+
+	// a nil limiter never blocks
+	var limiter *RateLimiter
+	c.Assert(limiter.Wait(ctx, row), check.IsNil)
+
+	limiter = NewRateLimiter(&config.RateLimitConfig{RowsPerSecond: 10})
+	start := time.Now()
+	for i := 0; i < 15; i++ {
+		c.Assert(limiter.Wait(ctx, row), check.IsNil)
+	}
+	c.Assert(time.Since(start), check.Greater, 400*time.Millisecond)
+	// the resolved events are not limited
+	start = time.Now()
+	for i := 0; i < 100; i++ {
+		c.Assert(limiter.Wait(ctx, resolved), check.IsNil)
+	}
+	c.Assert(time.Since(start), check.Less, 100*time.Millisecond)
+
+	// 100 bytes per row, the entries larger than the burst are not rejected
+	limiter = NewRateLimiter(&config.RateLimitConfig{BytesPerSecond: 50})
+	c.Assert(limiter.Wait(ctx, row), check.IsNil)
+	cctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	c.Assert(limiter.Wait(cctx, row), check.NotNil)
+
+	// the limits are shared by the captures of the changefeed
+	limiter = NewRateLimiter(&config.RateLimitConfig{RowsPerSecond: 100, BytesPerSecond: 1})
+	limiter.SetCaptureCount(0)
+	c.Assert(limiter.rows.Limit(), check.Equals, rate.Limit(100))
+	limiter.SetCaptureCount(4)
+	c.Assert(limiter.rows.Limit(), check.Equals, rate.Limit(25))
+	c.Assert(limiter.rows.Burst(), check.Equals, 25)
+	c.Assert(limiter.bytes.Limit(), check.Equals, rate.Limit(1))
+	limiter.SetCaptureCount(1)
+	c.Assert(limiter.rows.Limit(), check.Equals, rate.Limit(100))
+}
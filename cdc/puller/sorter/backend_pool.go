@@ -31,12 +31,18 @@ import (
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/diskmanager"
 	cerrors "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/usage"
+	"github.com/pingcap/ticdc/pkg/util"
 	"go.uber.org/zap"
 )
 
 const (
 	backgroundJobInterval = time.Second * 5
+	// diskQuotaWaitInterval is how often a blocked alloc checks if the disk
+	// quota of the sorter is available again.
+	diskQuotaWaitInterval = time.Millisecond * 100
 )
 
 var (
@@ -142,12 +148,38 @@ func newBackEndPool(dir string, captureAddr string) *backEndPool {
 }
 
 func (p *backEndPool) alloc(ctx context.Context) (backEnd, error) {
+	// the data is accounted to the changefeed of the sorter
+	cfUsage := usage.Of(util.ChangefeedIDFromCtx(ctx))
+
 	sorterConfig := config.GetSorterConfig()
-	if p.sorterMemoryUsage() < int64(sorterConfig.MaxMemoryConsumption) &&
-		p.memoryPressure() < int32(sorterConfig.MaxMemoryPressure) {
+	var ticker *time.Ticker
+	for {
+		if p.sorterMemoryUsage() < int64(sorterConfig.MaxMemoryConsumption) &&
+			p.memoryPressure() < int32(sorterConfig.MaxMemoryPressure) {
+
+			ret := newMemoryBackEnd()
+			ret.usage = cfUsage
+			return ret, nil
+		}
 
-		ret := newMemoryBackEnd()
-		return ret, nil
+		m := diskmanager.GetGlobal()
+		if m == nil || !m.Full(diskmanager.ComponentSorter) {
+			break
+		}
+		// Neither the memory nor the disk is available, the flush is blocked
+		// until the files of the merged data are removed.
+		if ticker == nil {
+			log.Warn("Unified Sorter: disk quota is used up, waiting for the disk to be freed",
+				zap.Int64("used", m.Used(diskmanager.ComponentSorter)),
+				zap.String("table", tableNameFromCtx(ctx)))
+			ticker = time.NewTicker(diskQuotaWaitInterval)
+			defer ticker.Stop()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Trace(ctx.Err())
+		case <-ticker.C:
+		}
 	}
 
 	p.cancelRWLock.RLock()
@@ -161,7 +193,9 @@ func (p *backEndPool) alloc(ctx context.Context) (backEnd, error) {
 		ptr := &p.cache[i]
 		ret := atomic.SwapPointer(ptr, nil)
 		if ret != nil {
-			return (*fileBackEnd)(ret), nil
+			f := (*fileBackEnd)(ret)
+			f.usage = cfUsage
+			return f, nil
 		}
 	}
 
@@ -174,6 +208,7 @@ func (p *backEndPool) alloc(ctx context.Context) (backEnd, error) {
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	ret.usage = cfUsage
 
 	return ret, nil
 }
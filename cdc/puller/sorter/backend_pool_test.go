@@ -21,8 +21,10 @@ import (
 	"time"
 
 	"github.com/pingcap/check"
+	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/diskmanager"
 	"github.com/pingcap/ticdc/pkg/util/testleak"
 )
 
@@ -98,6 +100,47 @@ func (s *backendPoolSuite) TestBasicFunction(c *check.C) {
 	c.Assert(os.IsNotExist(err), check.IsTrue)
 }
 
+func (s *backendPoolSuite) TestDiskQuotaUsedUp(c *check.C) {
+	defer testleak.AfterTest(c)()
+
+	m, err := diskmanager.NewManager(&diskmanager.Config{DataDir: c.MkDir(), Quota: 100}, "")
+	c.Assert(err, check.IsNil)
+	diskmanager.SetGlobal(m)
+	defer diskmanager.SetGlobal(nil)
+	m.ForceAllocate(diskmanager.ComponentSorter, 100)
+
+	config.SetSorterConfig(&config.SorterConfig{
+		MaxMemoryPressure:    90,                      // 90%
+		MaxMemoryConsumption: 16 * 1024 * 1024 * 1024, // 16G
+	})
+	err = failpoint.Enable("github.com/pingcap/ticdc/cdc/puller/sorter/memoryPressureInjectPoint", "return(100)")
+	c.Assert(err, check.IsNil)
+	defer func() {
+		_ = failpoint.Disable("github.com/pingcap/ticdc/cdc/puller/sorter/memoryPressureInjectPoint")
+	}()
+
+	backEndPool := newBackEndPool(m.Dir(diskmanager.ComponentSorter), "")
+	defer backEndPool.terminate()
+
+	// the alloc is blocked instead of sorting in memory
+	ctx, cancel := context.WithTimeout(context.Background(), diskQuotaWaitInterval*3)
+	defer cancel()
+	_, err = backEndPool.alloc(ctx)
+	c.Assert(errors.Cause(err), check.Equals, context.DeadlineExceeded)
+
+	// the alloc goes on once the disk is freed
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	go func() {
+		time.Sleep(diskQuotaWaitInterval * 2)
+		m.Free(diskmanager.ComponentSorter, 100)
+	}()
+	backEnd, err := backEndPool.alloc(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(backEnd, check.FitsTypeOf, &fileBackEnd{})
+	c.Assert(backEndPool.dealloc(backEnd), check.IsNil)
+}
+
 func (s *backendPoolSuite) TestCleanUp(c *check.C) {
 	defer testleak.AfterTest(c)()
 
@@ -24,6 +24,8 @@ import (
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/diskmanager"
+	"github.com/pingcap/ticdc/pkg/usage"
 	"go.uber.org/zap"
 )
 
@@ -39,6 +41,8 @@ type fileBackEnd struct {
 	serde    serializerDeserializer
 	borrowed int32
 	size     int64
+	// usage is the usage of the changefeed the data belongs to
+	usage *usage.Changefeed
 }
 
 func newFileBackEnd(fileName string, serde serializerDeserializer) (*fileBackEnd, error) {
@@ -139,6 +143,10 @@ func (f *fileBackEnd) cleanStats() {
 	if pool != nil {
 		atomic.AddInt64(&pool.onDiskDataSize, -f.size)
 	}
+	if m := diskmanager.GetGlobal(); m != nil {
+		m.Free(diskmanager.ComponentSorter, f.size)
+	}
+	f.usage.AddSorterDisk(-f.size)
 	f.size = 0
 }
 
@@ -274,6 +282,9 @@ type fileBackEndWriter struct {
 }
 
 func (w *fileBackEndWriter) writeNext(event *model.PolymorphicEvent) error {
+	failpoint.Inject("SorterIOError", func() {
+		failpoint.Return(errors.New("injected sorter IO error"))
+	})
 	var err error
 	w.rawBytesBuf, err = w.backEnd.serde.marshal(event, w.rawBytesBuf)
 	if err != nil {
@@ -344,6 +355,10 @@ func (w *fileBackEndWriter) flushAndClose() error {
 	atomic.AddInt64(&openFDCount, -1)
 	w.backEnd.size = w.bytesWritten
 	atomic.AddInt64(&pool.onDiskDataSize, w.bytesWritten)
+	if m := diskmanager.GetGlobal(); m != nil {
+		m.ForceAllocate(diskmanager.ComponentSorter, w.bytesWritten)
+	}
+	w.backEnd.usage.AddSorterDisk(w.bytesWritten)
 
 	failpoint.Inject("sorterDebug", func() {
 		atomic.StoreInt32(&w.backEnd.borrowed, 0)
@@ -16,7 +16,6 @@ package sorter
 import (
 	"container/heap"
 	"context"
-	"sync"
 	"sync/atomic"
 	"time"
 
@@ -53,17 +52,19 @@ type heapSorter struct {
 	inputCh     chan *model.PolymorphicEvent
 	outputCh    chan *flushTask
 	heap        sortHeap
+	pools       *WorkerPools
 
 	poolHandle    workerpool.EventHandle
 	internalState *heapSorterInternalState
 }
 
-func newHeapSorter(id int, out chan *flushTask) *heapSorter {
+func newHeapSorter(id int, pools *WorkerPools, out chan *flushTask) *heapSorter {
 	return &heapSorter{
 		id:       id,
 		inputCh:  make(chan *model.PolymorphicEvent, 1024*1024),
 		outputCh: out,
 		heap:     make(sortHeap, 0, 65536),
+		pools:    pools,
 	}
 }
 
@@ -143,7 +144,7 @@ func (h *heapSorter) flush(ctx context.Context, maxResolvedTs uint64) error {
 
 	if !isEmptyFlush {
 		backEndFinal := backEnd
-		err := heapSorterIOPool.Go(ctx, func() {
+		err := h.pools.heapSorterIOPool.Go(ctx, func() {
 			writer, err := backEnd.writer()
 			if err != nil {
 				if backEndFinal != nil {
@@ -212,12 +213,6 @@ func (h *heapSorter) flush(ctx context.Context, maxResolvedTs uint64) error {
 	return nil
 }
 
-var (
-	heapSorterPool   workerpool.WorkerPool
-	heapSorterIOPool workerpool.AsyncPool
-	poolOnce         sync.Once
-)
-
 type heapSorterInternalState struct {
 	maxResolved           uint64
 	heapSizeBytesEstimate int64
@@ -231,7 +226,7 @@ func (h *heapSorter) init(ctx context.Context, onError func(err error)) {
 		sorterConfig: config.GetSorterConfig(),
 	}
 
-	poolHandle := heapSorterPool.RegisterEvent(func(ctx context.Context, eventI interface{}) error {
+	poolHandle := h.pools.heapSorterPool.RegisterEvent(func(ctx context.Context, eventI interface{}) error {
 		event := eventI.(*model.PolymorphicEvent)
 		heap.Push(&h.heap, &sortItem{entry: event})
 		isResolvedEvent := event.RawKV != nil && event.RawKV.OpType == model.OpTypeResolved
@@ -279,11 +274,3 @@ func (h *heapSorter) init(ctx context.Context, onError func(err error)) {
 	h.poolHandle = poolHandle
 	h.internalState = state
 }
-
-func lazyInitWorkerPool() {
-	poolOnce.Do(func() {
-		sorterConfig := config.GetSorterConfig()
-		heapSorterPool = workerpool.NewDefaultWorkerPool(sorterConfig.NumWorkerPoolGoroutine)
-		heapSorterIOPool = workerpool.NewDefaultAsyncPool(sorterConfig.NumWorkerPoolGoroutine * 2)
-	})
-}
@@ -19,6 +19,7 @@ import (
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/usage"
 	"go.uber.org/zap"
 )
 
@@ -26,6 +27,8 @@ type memoryBackEnd struct {
 	events        []*model.PolymorphicEvent
 	estimatedSize int64
 	borrowed      int32
+	// usage is the usage of the changefeed the data belongs to
+	usage *usage.Changefeed
 }
 
 func newMemoryBackEnd() *memoryBackEnd {
@@ -65,6 +68,7 @@ func (m *memoryBackEnd) free() error {
 	if pool != nil {
 		atomic.AddInt64(&pool.memoryUseEstimate, -m.estimatedSize)
 	}
+	m.usage.AddSorterMemory(-m.estimatedSize)
 
 	return nil
 }
@@ -91,6 +95,7 @@ func (r *memoryBackEndReader) resetAndClose() error {
 	})
 
 	atomic.AddInt64(&pool.memoryUseEstimate, -r.backEnd.estimatedSize)
+	r.backEnd.usage.AddSorterMemory(-r.backEnd.estimatedSize)
 	r.backEnd.estimatedSize = 0
 
 	return nil
@@ -135,6 +140,7 @@ func (w *memoryBackEndWriter) flushAndClose() error {
 
 	w.backEnd.estimatedSize = w.bytesWritten
 	atomic.AddInt64(&pool.memoryUseEstimate, w.bytesWritten)
+	w.backEnd.usage.AddSorterMemory(w.bytesWritten)
 
 	return nil
 }
@@ -0,0 +1,361 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sorter
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/diskmanager"
+	"go.uber.org/zap"
+)
+
+const (
+	resumeManifestFile = "manifest.json"
+	// resumeSegmentSize is the size of a segment to be closed at the next
+	// resolved event
+	resumeSegmentSize = 64 * 1024 * 1024
+)
+
+// resumeSegment is a file of the sorted events of a table, it has all the
+// events in (StartTs, ResolvedTs]. The events after ResolvedTs in the file
+// are ignored.
+type resumeSegment struct {
+	File       string `json:"file"`
+	StartTs    uint64 `json:"start-ts"`
+	ResolvedTs uint64 `json:"resolved-ts"`
+	Size       int64  `json:"size"`
+}
+
+// ResumeLog keeps the output of the unified sorter of a table on disk, so the
+// table can be resumed after the capture is restarted by replaying the sorted
+// events after its checkpoint, instead of pulling and sorting them again.
+//
+// The log is made of the contiguous segments listed in the manifest. The
+// segment being written is listed when it's closed, which happens when it's
+// large enough or the sorter exits, so the log is consistent even if the
+// capture crashes. A ResumeLog is used by a single goroutine.
+type ResumeLog struct {
+	dir      string
+	segments []resumeSegment
+	// resolvedTs is the ts up to which the events are in the listed segments
+	resolvedTs uint64
+	serde      msgPackGenSerde
+
+	// the segment being written, f is nil if no segment is open
+	f       *os.File
+	w       *bufio.Writer
+	current resumeSegment
+	buf     []byte
+}
+
+// OpenResumeLog opens the resume log in dir, the files not listed in the
+// manifest are removed. The log is discarded if a listed segment is missing,
+// the table is pulled from its checkpoint then.
+func OpenResumeLog(dir string) (*ResumeLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Trace(err)
+	}
+	l := &ResumeLog{dir: dir}
+	data, err := ioutil.ReadFile(filepath.Join(dir, resumeManifestFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Trace(err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &l.segments); err != nil {
+			log.Warn("the manifest of the sorter resume log is broken, the log is discarded",
+				zap.String("dir", dir), zap.Error(err))
+			l.segments = nil
+		}
+	}
+	for _, seg := range l.segments {
+		if _, err := os.Stat(filepath.Join(dir, seg.File)); err != nil {
+			log.Warn("a segment of the sorter resume log is missing, the log is discarded",
+				zap.String("dir", dir), zap.String("segment", seg.File), zap.Error(err))
+			l.segments = nil
+			if err := l.writeManifest(); err != nil {
+				return nil, errors.Trace(err)
+			}
+			break
+		}
+	}
+	listed := make(map[string]struct{}, len(l.segments))
+	for _, seg := range l.segments {
+		listed[seg.File] = struct{}{}
+		l.resolvedTs = seg.ResolvedTs
+		allocResumeLog(seg.Size)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, f := range files {
+		if _, ok := listed[f.Name()]; ok || f.Name() == resumeManifestFile {
+			continue
+		}
+		// the segment being written when the capture exited
+		if err := os.Remove(filepath.Join(dir, f.Name())); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return l, nil
+}
+
+// Covers returns the resolved ts of the log if the log has all the events
+// after startTs, the events in (startTs, resolvedTs] can be replayed then.
+func (l *ResumeLog) Covers(startTs uint64) (uint64, bool) {
+	if len(l.segments) == 0 || l.segments[0].StartTs > startTs || l.resolvedTs <= startTs {
+		return 0, false
+	}
+	return l.resolvedTs, true
+}
+
+// reset removes all the segments, the log starts at startTs afterwards.
+func (l *ResumeLog) reset(startTs uint64) error {
+	if l.f != nil {
+		if err := l.closeFile(); err != nil {
+			return errors.Trace(err)
+		}
+		if err := os.Remove(filepath.Join(l.dir, l.current.File)); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	segments := l.segments
+	l.segments = nil
+	l.resolvedTs = startTs
+	if err := l.writeManifest(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(l.removeSegments(segments))
+}
+
+// replay sends the events in (startTs, resolvedTs of the log] to out.
+func (l *ResumeLog) replay(ctx context.Context, startTs uint64, out chan<- *model.PolymorphicEvent) error {
+	for _, seg := range l.segments {
+		if seg.ResolvedTs <= startTs {
+			continue
+		}
+		if err := l.replaySegment(ctx, seg, startTs, out); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func (l *ResumeLog) replaySegment(
+	ctx context.Context, seg resumeSegment, startTs uint64, out chan<- *model.PolymorphicEvent,
+) error {
+	f, err := os.Open(filepath.Join(l.dir, seg.File))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, fileBufferSize)
+	var buf []byte
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errors.Trace(err)
+		}
+		if binary.LittleEndian.Uint32(header[:4]) != magic {
+			return errors.Errorf("wrong magic of the sorter resume log %s", seg.File)
+		}
+		size := binary.LittleEndian.Uint32(header[4:])
+		if cap(buf) < int(size) {
+			buf = make([]byte, size)
+		}
+		buf = buf[:size]
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return errors.Trace(err)
+		}
+		event := new(model.PolymorphicEvent)
+		if _, err := l.serde.unmarshal(event, buf); err != nil {
+			return errors.Trace(err)
+		}
+		if event.CRTs > seg.ResolvedTs {
+			// the events are sorted
+			return nil
+		}
+		if event.CRTs <= startTs {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case out <- event:
+		}
+	}
+}
+
+// append writes an output event of the sorter to the log. The segment being
+// written is closed at a resolved event once it's large enough, and the
+// segments whose events are all at or before checkpointTs are removed then.
+func (l *ResumeLog) append(event *model.PolymorphicEvent, checkpointTs uint64) error {
+	isResolved := event.RawKV.OpType == model.OpTypeResolved
+	if isResolved && event.CRTs <= l.resolvedTs {
+		return nil
+	}
+	if l.f == nil {
+		name := fmt.Sprintf("%020d.seg", l.resolvedTs)
+		f, err := os.Create(filepath.Join(l.dir, name))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		l.f = f
+		l.w = bufio.NewWriterSize(f, fileBufferSize)
+		l.current = resumeSegment{File: name, StartTs: l.resolvedTs, ResolvedTs: l.resolvedTs}
+	}
+	var err error
+	l.buf, err = l.serde.marshal(event, l.buf)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	var header [8]byte
+	binary.LittleEndian.PutUint32(header[:4], magic)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(l.buf)))
+	if _, err := l.w.Write(header[:]); err != nil {
+		return errors.Trace(err)
+	}
+	if _, err := l.w.Write(l.buf); err != nil {
+		return errors.Trace(err)
+	}
+	l.current.Size += int64(len(header) + len(l.buf))
+	if !isResolved {
+		return nil
+	}
+	l.current.ResolvedTs = event.CRTs
+	if l.current.Size < resumeSegmentSize {
+		return nil
+	}
+	if err := l.closeSegment(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(l.truncate(checkpointTs))
+}
+
+// closeSegment closes the segment being written and lists it in the manifest.
+func (l *ResumeLog) closeSegment() error {
+	if l.f == nil {
+		return nil
+	}
+	if err := l.w.Flush(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := l.closeFile(); err != nil {
+		return errors.Trace(err)
+	}
+	if l.current.ResolvedTs == l.current.StartTs {
+		// no event is resolved by the segment
+		return errors.Trace(os.Remove(filepath.Join(l.dir, l.current.File)))
+	}
+	l.segments = append(l.segments, l.current)
+	l.resolvedTs = l.current.ResolvedTs
+	allocResumeLog(l.current.Size)
+	return errors.Trace(l.writeManifest())
+}
+
+func (l *ResumeLog) closeFile() error {
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	l.w = nil
+	return errors.Trace(err)
+}
+
+// truncate removes the segments whose events are all at or before the
+// checkpoint ts, which are never replayed.
+func (l *ResumeLog) truncate(checkpointTs uint64) error {
+	i := 0
+	for i < len(l.segments) && l.segments[i].ResolvedTs <= checkpointTs {
+		i++
+	}
+	if i == 0 {
+		return nil
+	}
+	removed := l.segments[:i]
+	l.segments = append([]resumeSegment(nil), l.segments[i:]...)
+	if err := l.writeManifest(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(l.removeSegments(removed))
+}
+
+func (l *ResumeLog) removeSegments(segments []resumeSegment) error {
+	for _, seg := range segments {
+		if err := os.Remove(filepath.Join(l.dir, seg.File)); err != nil && !os.IsNotExist(err) {
+			return errors.Trace(err)
+		}
+		allocResumeLog(-seg.Size)
+	}
+	return nil
+}
+
+// writeManifest replaces the manifest atomically.
+func (l *ResumeLog) writeManifest() error {
+	data, err := json.Marshal(l.segments)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	tmp := filepath.Join(l.dir, resumeManifestFile+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0o644); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(tmp, filepath.Join(l.dir, resumeManifestFile)))
+}
+
+// Close closes the segment being written, the log can be opened again by
+// OpenResumeLog afterwards.
+func (l *ResumeLog) Close() error {
+	return errors.Trace(l.closeSegment())
+}
+
+// Remove removes all the files of the log.
+func (l *ResumeLog) Remove() error {
+	if err := l.closeFile(); err != nil {
+		log.Warn("close the sorter resume log failed", zap.String("dir", l.dir), zap.Error(err))
+	}
+	for _, seg := range l.segments {
+		allocResumeLog(-seg.Size)
+	}
+	l.segments = nil
+	return errors.Trace(os.RemoveAll(l.dir))
+}
+
+func allocResumeLog(size int64) {
+	if m := diskmanager.GetGlobal(); m != nil {
+		if size >= 0 {
+			m.ForceAllocate(diskmanager.ComponentSorterResume, size)
+		} else {
+			m.Free(diskmanager.ComponentSorterResume, -size)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sorter
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type resumeLogSuite struct{}
+
+var _ = check.Suite(&resumeLogSuite{})
+
+func newRowEvent(ts uint64) *model.PolymorphicEvent {
+	return model.NewPolymorphicEvent(&model.RawKVEntry{
+		OpType:  model.OpTypePut,
+		Key:     []byte("key"),
+		Value:   []byte("value"),
+		StartTs: ts - 1,
+		CRTs:    ts,
+	})
+}
+
+func replayedTs(c *check.C, l *ResumeLog, startTs uint64) []uint64 {
+	ch := make(chan *model.PolymorphicEvent, 128)
+	c.Assert(l.replay(context.Background(), startTs, ch), check.IsNil)
+	close(ch)
+	var ts []uint64
+	for event := range ch {
+		ts = append(ts, event.CRTs)
+	}
+	return ts
+}
+
+func (s *resumeLogSuite) TestResumeLog(c *check.C) {
+	defer testleak.AfterTest(c)()
+	dir := filepath.Join(c.MkDir(), "resume")
+	l, err := OpenResumeLog(dir)
+	c.Assert(err, check.IsNil)
+	c.Assert(l.reset(100), check.IsNil)
+	events := []*model.PolymorphicEvent{
+		newRowEvent(101), newRowEvent(102), model.NewResolvedPolymorphicEvent(0, 102),
+		newRowEvent(103), model.NewResolvedPolymorphicEvent(0, 105),
+		// not resolved when the log is closed
+		newRowEvent(106),
+	}
+	for _, event := range events {
+		c.Assert(l.append(event, 0), check.IsNil)
+	}
+	c.Assert(l.Close(), check.IsNil)
+
+	// the file left by a crash is removed
+	orphan := filepath.Join(dir, "orphan.seg")
+	c.Assert(ioutil.WriteFile(orphan, []byte("data"), 0o644), check.IsNil)
+	l, err = OpenResumeLog(dir)
+	c.Assert(err, check.IsNil)
+	_, err = os.Stat(orphan)
+	c.Assert(os.IsNotExist(err), check.IsTrue)
+
+	_, ok := l.Covers(99)
+	c.Assert(ok, check.IsFalse)
+	_, ok = l.Covers(105)
+	c.Assert(ok, check.IsFalse)
+	resolvedTs, ok := l.Covers(102)
+	c.Assert(ok, check.IsTrue)
+	c.Assert(resolvedTs, check.Equals, uint64(105))
+	c.Assert(replayedTs(c, l, 102), check.DeepEquals, []uint64{103, 105})
+
+	// the events after the resolved ts of the log are appended to a new segment
+	c.Assert(l.append(newRowEvent(108), 0), check.IsNil)
+	c.Assert(l.append(model.NewResolvedPolymorphicEvent(0, 110), 0), check.IsNil)
+	c.Assert(l.Close(), check.IsNil)
+	l, err = OpenResumeLog(dir)
+	c.Assert(err, check.IsNil)
+	resolvedTs, ok = l.Covers(100)
+	c.Assert(ok, check.IsTrue)
+	c.Assert(resolvedTs, check.Equals, uint64(110))
+	c.Assert(replayedTs(c, l, 100), check.DeepEquals, []uint64{101, 102, 102, 103, 105, 108, 110})
+
+	// the segments before the checkpoint are removed
+	c.Assert(l.truncate(105), check.IsNil)
+	_, ok = l.Covers(100)
+	c.Assert(ok, check.IsFalse)
+	c.Assert(replayedTs(c, l, 105), check.DeepEquals, []uint64{108, 110})
+
+	// the log is discarded if a listed segment is missing
+	c.Assert(l.Close(), check.IsNil)
+	c.Assert(l.segments, check.HasLen, 1)
+	c.Assert(os.Remove(filepath.Join(dir, l.segments[0].File)), check.IsNil)
+	l, err = OpenResumeLog(dir)
+	c.Assert(err, check.IsNil)
+	_, ok = l.Covers(105)
+	c.Assert(ok, check.IsFalse)
+	c.Assert(replayedTs(c, l, 105), check.HasLen, 0)
+
+	// the log is restarted if it doesn't cover the start ts
+	c.Assert(l.reset(200), check.IsNil)
+	_, ok = l.Covers(105)
+	c.Assert(ok, check.IsFalse)
+	files, err := ioutil.ReadDir(dir)
+	c.Assert(err, check.IsNil)
+	c.Assert(files, check.HasLen, 1)
+
+	c.Assert(l.Remove(), check.IsNil)
+	_, err = os.Stat(dir)
+	c.Assert(os.IsNotExist(err), check.IsTrue)
+}
@@ -16,6 +16,7 @@ package sorter
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
@@ -23,6 +24,7 @@ import (
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/pkg/config"
 	"github.com/pingcap/ticdc/pkg/util"
+	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -32,14 +34,22 @@ type UnifiedSorter struct {
 	outputCh  chan *model.PolymorphicEvent
 	dir       string
 	pool      *backEndPool
+	pools     *WorkerPools
 	tableName string // used only for debugging and tracing
+
+	resumeLog     *ResumeLog
+	resumeStartTs uint64
+	checkpointTs  func() uint64
+	// discardResumeLog is set if the resume log is removed when the sorter exits
+	discardResumeLog int32
 }
 
 type ctxKey struct {
 }
 
-// NewUnifiedSorter creates a new UnifiedSorter
-func NewUnifiedSorter(dir string, tableName string, captureAddr string) *UnifiedSorter {
+// NewUnifiedSorter creates a new UnifiedSorter, the events are sorted by the
+// given WorkerPools, or by the shared ones if pools is nil.
+func NewUnifiedSorter(dir string, tableName string, captureAddr string, pools *WorkerPools) *UnifiedSorter {
 	poolMu.Lock()
 	defer poolMu.Unlock()
 
@@ -47,12 +57,16 @@ func NewUnifiedSorter(dir string, tableName string, captureAddr string) *Unified
 		pool = newBackEndPool(dir, captureAddr)
 	}
 
-	lazyInitWorkerPool()
+	if pools == nil {
+		lazyInitWorkerPool()
+		pools = sharedWorkerPools
+	}
 	return &UnifiedSorter{
 		inputCh:   make(chan *model.PolymorphicEvent, 128000),
 		outputCh:  make(chan *model.PolymorphicEvent, 128000),
 		dir:       dir,
 		pool:      pool,
+		pools:     pools,
 		tableName: tableName,
 	}
 }
@@ -69,6 +83,65 @@ func UnifiedSorterCleanUp() {
 	}
 }
 
+// SetResumeLog makes the sorter replay the events after startTs from the log
+// before its output if the log covers startTs, and write its output to the
+// log. The puller of the table must start from the resolved ts of the log
+// returned by ResumeLog.Covers if it covers startTs, or from startTs
+// otherwise. The log is truncated by checkpointTs, which is the ts no table
+// can be resumed before, and it's closed when the sorter exits.
+func (s *UnifiedSorter) SetResumeLog(l *ResumeLog, startTs uint64, checkpointTs func() uint64) {
+	s.resumeLog = l
+	s.resumeStartTs = startTs
+	s.checkpointTs = checkpointTs
+}
+
+// DiscardResumeLog makes the sorter remove the resume log when it exits, it's
+// called when the table is removed from the capture.
+func (s *UnifiedSorter) DiscardResumeLog() {
+	atomic.StoreInt32(&s.discardResumeLog, 1)
+}
+
+// runResumeLog replays the resume log, then forwards the output of the merger
+// to the output of the sorter and writes it to the resume log.
+func (s *UnifiedSorter) runResumeLog(ctx context.Context, in <-chan *model.PolymorphicEvent) error {
+	l := s.resumeLog
+	defer func() {
+		var err error
+		if atomic.LoadInt32(&s.discardResumeLog) != 0 {
+			err = l.Remove()
+		} else {
+			err = l.Close()
+		}
+		if err != nil {
+			log.Warn("close the sorter resume log failed", zap.String("table", s.tableName), zap.Error(err))
+		}
+	}()
+	if resolvedTs, ok := l.Covers(s.resumeStartTs); ok {
+		log.Info("Unified Sorter: replaying the resume log", zap.String("table", s.tableName),
+			zap.Uint64("startTs", s.resumeStartTs), zap.Uint64("resolvedTs", resolvedTs))
+		if err := l.replay(ctx, s.resumeStartTs, s.outputCh); err != nil {
+			return errors.Trace(err)
+		}
+	} else if err := l.reset(s.resumeStartTs); err != nil {
+		return errors.Trace(err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event := <-in:
+			if err := l.append(event, s.checkpointTs()); err != nil {
+				return errors.Trace(err)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case s.outputCh <- event:
+			}
+		}
+	}
+}
+
 // Run implements the EventSorter interface
 func (s *UnifiedSorter) Run(ctx context.Context) error {
 	failpoint.Inject("sorterDebug", func() {
@@ -94,7 +167,7 @@ func (s *UnifiedSorter) Run(ctx context.Context) error {
 	heapSorters := make([]*heapSorter, sorterConfig.NumConcurrentWorker)
 	for i := range heapSorters {
 		finalI := i
-		heapSorters[finalI] = newHeapSorter(finalI, heapSorterCollectCh)
+		heapSorters[finalI] = newHeapSorter(finalI, s.pools, heapSorterCollectCh)
 		heapSorters[finalI].init(subctx, func(err error) {
 			heapSorterErrOnce.Do(func() {
 				heapSorterErrCh <- err
@@ -122,8 +195,16 @@ func (s *UnifiedSorter) Run(ctx context.Context) error {
 		}
 	})
 
+	mergerOutputCh := s.outputCh
+	if s.resumeLog != nil {
+		mergerOutputCh = make(chan *model.PolymorphicEvent, 1024)
+		errg.Go(func() error {
+			return printError(s.runResumeLog(subctx, mergerOutputCh))
+		})
+	}
+
 	errg.Go(func() error {
-		return printError(runMerger(subctx, numConcurrentHeaps, heapSorterCollectCh, s.outputCh))
+		return printError(runMerger(subctx, numConcurrentHeaps, heapSorterCollectCh, mergerOutputCh))
 	})
 
 	errg.Go(func() error {
@@ -194,22 +275,6 @@ func (s *UnifiedSorter) Output() <-chan *model.PolymorphicEvent {
 	return s.outputCh
 }
 
-// RunWorkerPool runs the worker pool used by the heapSorters
-// It **must** be running for Unified Sorter to work.
-func RunWorkerPool(ctx context.Context) error {
-	lazyInitWorkerPool()
-	errg, ctx := errgroup.WithContext(ctx)
-	errg.Go(func() error {
-		return errors.Trace(heapSorterPool.Run(ctx))
-	})
-
-	errg.Go(func() error {
-		return errors.Trace(heapSorterIOPool.Run(ctx))
-	})
-
-	return errors.Trace(errg.Wait())
-}
-
 // tableNameFromCtx is used for retrieving the table's name from a context within the Unified Sorter
 func tableNameFromCtx(ctx context.Context) string {
 	if sorter, ok := ctx.Value(ctxKey{}).(*UnifiedSorter); ok {
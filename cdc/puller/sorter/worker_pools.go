@@ -0,0 +1,77 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sorter
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/workerpool"
+	"golang.org/x/sync/errgroup"
+)
+
+// WorkerPools are the worker pools used by the heapSorters. A changefeed can
+// have its own WorkerPools, so the slow sorting of its events can't delay the
+// tables of the other changefeeds on the same capture.
+type WorkerPools struct {
+	heapSorterPool   workerpool.WorkerPool
+	heapSorterIOPool workerpool.AsyncPool
+}
+
+// NewWorkerPools creates WorkerPools running workerNum Goroutines to sort
+// the events and twice as many to write the sorted events to the backends.
+func NewWorkerPools(workerNum int) *WorkerPools {
+	return &WorkerPools{
+		heapSorterPool:   workerpool.NewDefaultWorkerPool(workerNum),
+		heapSorterIOPool: workerpool.NewDefaultAsyncPool(workerNum * 2),
+	}
+}
+
+// Run runs the worker pools, it **must** be running for the Unified Sorters
+// using them to work.
+func (p *WorkerPools) Run(ctx context.Context) error {
+	errg, ctx := errgroup.WithContext(ctx)
+	errg.Go(func() error {
+		return errors.Trace(p.heapSorterPool.Run(ctx))
+	})
+
+	errg.Go(func() error {
+		return errors.Trace(p.heapSorterIOPool.Run(ctx))
+	})
+
+	return errors.Trace(errg.Wait())
+}
+
+var (
+	// sharedWorkerPools are used by the Unified Sorters of the changefeeds
+	// without their own WorkerPools.
+	sharedWorkerPools *WorkerPools
+	poolOnce          sync.Once
+)
+
+func lazyInitWorkerPool() {
+	poolOnce.Do(func() {
+		sorterConfig := config.GetSorterConfig()
+		sharedWorkerPools = NewWorkerPools(sorterConfig.NumWorkerPoolGoroutine)
+	})
+}
+
+// RunWorkerPool runs the shared worker pools used by the heapSorters.
+// It **must** be running for Unified Sorter to work.
+func RunWorkerPool(ctx context.Context) error {
+	lazyInitWorkerPool()
+	return errors.Trace(sharedWorkerPools.Run(ctx))
+}
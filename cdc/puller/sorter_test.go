@@ -67,13 +67,42 @@ func (s *sorterSuite) TestSorterBasic(c *check.C) {
 
 	err := os.MkdirAll("/tmp/sorter", 0o755)
 	c.Assert(err, check.IsNil)
-	sorter := sorter2.NewUnifiedSorter("/tmp/sorter", "test", "0.0.0.0:0")
+	sorter := sorter2.NewUnifiedSorter("/tmp/sorter", "test", "0.0.0.0:0", nil)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
 	testSorter(ctx, c, sorter, 10000)
 }
 
+func (s *sorterSuite) TestSorterIsolatedWorkerPools(c *check.C) {
+	defer testleak.AfterTest(c)()
+	defer sorter2.UnifiedSorterCleanUp()
+
+	config.SetSorterConfig(&config.SorterConfig{
+		NumConcurrentWorker:    8,
+		ChunkSizeLimit:         1 * 1024 * 1024 * 1024,
+		MaxMemoryPressure:      60,
+		MaxMemoryConsumption:   16 * 1024 * 1024 * 1024,
+		NumWorkerPoolGoroutine: 4,
+	})
+
+	err := os.MkdirAll("/tmp/sorter", 0o755)
+	c.Assert(err, check.IsNil)
+	pools := sorter2.NewWorkerPools(2)
+	sorter := sorter2.NewUnifiedSorter("/tmp/sorter", "test", "0.0.0.0:0", pools)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	poolsCtx, poolsCancel := context.WithCancel(ctx)
+	poolsErrCh := make(chan error, 1)
+	go func() {
+		poolsErrCh <- pools.Run(poolsCtx)
+	}()
+	testSorter(ctx, c, sorter, 10000)
+	poolsCancel()
+	<-poolsErrCh
+}
+
 func (s *sorterSuite) TestSorterCancel(c *check.C) {
 	defer testleak.AfterTest(c)()
 	defer sorter2.UnifiedSorterCleanUp()
@@ -88,7 +117,7 @@ func (s *sorterSuite) TestSorterCancel(c *check.C) {
 
 	err := os.MkdirAll("/tmp/sorter", 0o755)
 	c.Assert(err, check.IsNil)
-	sorter := sorter2.NewUnifiedSorter("/tmp/sorter", "test", "0.0.0.0:0")
+	sorter := sorter2.NewUnifiedSorter("/tmp/sorter", "test", "0.0.0.0:0", nil)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
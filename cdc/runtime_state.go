@@ -0,0 +1,349 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/kv"
+	"github.com/pingcap/ticdc/cdc/model"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/httputil"
+	"github.com/pingcap/ticdc/pkg/security"
+	"go.uber.org/zap"
+)
+
+// The task positions and workloads change frequently, writing them to etcd
+// limits the scale of the cluster. The processors report them to the owner
+// through the runtime state API of the owner, and write them to etcd only
+// periodically, so a new owner can still load them from etcd.
+const (
+	// runtimeStateTTL is how long a reported runtime state is used by the
+	// owner, the state in etcd is used after that.
+	runtimeStateTTL = 30 * time.Second
+	// runtimeStateRetryInterval is how long a processor writes its runtime
+	// state to etcd after it fails to report to the owner.
+	runtimeStateRetryInterval = 10 * time.Second
+	// runtimeStateReportTimeout is the timeout of a report.
+	runtimeStateReportTimeout = 3 * time.Second
+	// runtimeStateAPI is the path of the runtime state API of the owner.
+	runtimeStateAPI = "/capture/owner/runtime_state"
+)
+
+type reportedPosition struct {
+	position   *model.TaskPosition
+	reportTime time.Time
+}
+
+type reportedWorkload struct {
+	workload        model.TaskWorkload
+	sorterFreeBytes int64
+	usage           *model.ResourceUsage
+	reportTime      time.Time
+}
+
+// runtimeStateStore keeps the runtime states reported by the processors in the
+// memory of the owner.
+type runtimeStateStore struct {
+	mu        sync.Mutex
+	positions map[model.ChangeFeedID]map[model.CaptureID]reportedPosition
+	workloads map[model.ChangeFeedID]map[model.CaptureID]reportedWorkload
+}
+
+func newRuntimeStateStore() *runtimeStateStore {
+	return &runtimeStateStore{
+		positions: make(map[model.ChangeFeedID]map[model.CaptureID]reportedPosition),
+		workloads: make(map[model.ChangeFeedID]map[model.CaptureID]reportedWorkload),
+	}
+}
+
+func (s *runtimeStateStore) update(state *model.ProcessorRuntimeState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if state.Position != nil {
+		positions, ok := s.positions[state.ChangefeedID]
+		if !ok {
+			positions = make(map[model.CaptureID]reportedPosition)
+			s.positions[state.ChangefeedID] = positions
+		}
+		positions[state.CaptureID] = reportedPosition{position: state.Position, reportTime: now}
+	}
+	if state.Workload != nil {
+		workloads, ok := s.workloads[state.ChangefeedID]
+		if !ok {
+			workloads = make(map[model.CaptureID]reportedWorkload)
+			s.workloads[state.ChangefeedID] = workloads
+		}
+		workloads[state.CaptureID] = reportedWorkload{
+			workload:        state.Workload,
+			sorterFreeBytes: state.SorterFreeBytes,
+			usage:           state.Usage,
+			reportTime:      now,
+		}
+	}
+}
+
+// mergePositions overrides the positions loaded from etcd with the reported
+// ones, only the captures having a task status are merged, since the
+// processors of the other captures are stopped. A reported position is
+// ignored if the one in etcd is written by a processor started later, or by
+// the same processor after the report while the reporter is unavailable.
+func (s *runtimeStateStore) mergePositions(
+	changefeedID model.ChangeFeedID,
+	taskStatus model.ProcessorsInfos,
+	positions map[model.CaptureID]*model.TaskPosition,
+) map[model.CaptureID]*model.TaskPosition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for captureID, reported := range s.positions[changefeedID] {
+		if time.Since(reported.reportTime) > runtimeStateTTL {
+			delete(s.positions[changefeedID], captureID)
+			continue
+		}
+		if _, ok := taskStatus[captureID]; !ok {
+			continue
+		}
+		if pos, ok := positions[captureID]; ok {
+			// an error is always written to etcd by the processor
+			if pos.Error != nil {
+				continue
+			}
+			if pos.Epoch > reported.position.Epoch {
+				continue
+			}
+			if pos.Epoch == reported.position.Epoch &&
+				(reported.position.CheckPointTs < pos.CheckPointTs ||
+					reported.position.ResolvedTs < pos.ResolvedTs) {
+				continue
+			}
+		}
+		if positions == nil {
+			positions = make(map[model.CaptureID]*model.TaskPosition)
+		}
+		positions[captureID] = reported.position
+	}
+	return positions
+}
+
+// workload returns the reported workload of the capture, it returns false if
+// the workload is not reported recently.
+func (s *runtimeStateStore) workload(
+	changefeedID model.ChangeFeedID, captureID model.CaptureID,
+) (model.TaskWorkload, bool) {
+	workload, _, ok := s.workloadReport(changefeedID, captureID)
+	return workload, ok
+}
+
+// workloadReport returns the reported workload of the capture and when it's
+// reported, it returns false if the workload is not reported recently.
+func (s *runtimeStateStore) workloadReport(
+	changefeedID model.ChangeFeedID, captureID model.CaptureID,
+) (model.TaskWorkload, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reported, ok := s.workloads[changefeedID][captureID]
+	if !ok || time.Since(reported.reportTime) > runtimeStateTTL {
+		return nil, time.Time{}, false
+	}
+	// the workload may be modified by the scheduler
+	workload := make(model.TaskWorkload, len(reported.workload))
+	for tableID, info := range reported.workload {
+		workload[tableID] = info
+	}
+	return workload, reported.reportTime, true
+}
+
+// sorterFreeBytes returns the free sorter space of the capture reported most
+// recently by the processors of all the changefeeds, it returns zero if it's
+// not reported recently.
+func (s *runtimeStateStore) sorterFreeBytes(captureID model.CaptureID) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var latest reportedWorkload
+	for _, workloads := range s.workloads {
+		if reported, ok := workloads[captureID]; ok && reported.reportTime.After(latest.reportTime) {
+			latest = reported
+		}
+	}
+	if time.Since(latest.reportTime) > runtimeStateTTL {
+		return 0
+	}
+	return latest.sorterFreeBytes
+}
+
+// usages returns the resource usages reported recently by the processors of
+// the changefeed, or of all the changefeeds if changefeedID is empty.
+func (s *runtimeStateStore) usages(
+	changefeedID model.ChangeFeedID,
+) map[model.ChangeFeedID]map[model.CaptureID]model.ResourceUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usages := make(map[model.ChangeFeedID]map[model.CaptureID]model.ResourceUsage)
+	for id, workloads := range s.workloads {
+		if changefeedID != "" && id != changefeedID {
+			continue
+		}
+		for captureID, reported := range workloads {
+			if reported.usage == nil || time.Since(reported.reportTime) > runtimeStateTTL {
+				continue
+			}
+			if _, ok := usages[id]; !ok {
+				usages[id] = make(map[model.CaptureID]model.ResourceUsage)
+			}
+			usages[id][captureID] = *reported.usage
+		}
+	}
+	return usages
+}
+
+// removeCapture removes the states reported by the capture.
+func (s *runtimeStateStore) removeCapture(captureID model.CaptureID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, positions := range s.positions {
+		delete(positions, captureID)
+	}
+	for _, workloads := range s.workloads {
+		delete(workloads, captureID)
+	}
+}
+
+// removeChangefeed removes the states reported by the processors of the
+// changefeed.
+func (s *runtimeStateStore) removeChangefeed(changefeedID model.ChangeFeedID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.positions, changefeedID)
+	delete(s.workloads, changefeedID)
+}
+
+// runtimeStateReporter reports the runtime states of a processor to the
+// owner. It stops reporting for a while after a failure, the processor writes
+// the states to etcd in the meantime.
+type runtimeStateReporter struct {
+	etcdCli kv.CDCEtcdClient
+	httpCli *httputil.Client
+	scheme  string
+
+	mu        sync.Mutex
+	failedAt  time.Time
+	ownerAddr string
+	// disabled is set if the owner rejects the internal token, the states
+	// are always written to etcd then.
+	disabled bool
+}
+
+func newRuntimeStateReporter(
+	etcdCli kv.CDCEtcdClient, credential *security.Credential, authToken string,
+) (*runtimeStateReporter, error) {
+	httpCli, err := httputil.NewClient(credential, httputil.WithTimeout(runtimeStateReportTimeout))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// the runtime state API is an internal API
+	httpCli.SetBearerToken(authToken)
+	scheme := "http"
+	if credential != nil && credential.IsTLSEnabled() {
+		scheme = "https"
+	}
+	return &runtimeStateReporter{
+		etcdCli: etcdCli,
+		httpCli: httpCli,
+		scheme:  scheme,
+	}, nil
+}
+
+// available returns whether the reporter can be used.
+func (r *runtimeStateReporter) available() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.disabled && time.Since(r.failedAt) >= runtimeStateRetryInterval
+}
+
+func (r *runtimeStateReporter) report(ctx context.Context, state *model.ProcessorRuntimeState) error {
+	err := r.doReport(ctx, state)
+	if err != nil {
+		r.mu.Lock()
+		r.failedAt = time.Now()
+		// resolve the owner again in the next report
+		r.ownerAddr = ""
+		r.mu.Unlock()
+	}
+	return err
+}
+
+func (r *runtimeStateReporter) doReport(ctx context.Context, state *model.ProcessorRuntimeState) error {
+	addr, err := r.resolveOwner(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	data, err := state.Marshal()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	url := fmt.Sprintf("%s://%s%s", r.scheme, addr, runtimeStateAPI)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return cerror.WrapError(cerror.ErrReportRuntimeState, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.httpCli.Do(req)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrReportRuntimeState, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		log.Warn("the owner rejects the internal token, the runtime states are written to etcd",
+			zap.String("owner", addr), zap.String("changefeed", state.ChangefeedID))
+		r.mu.Lock()
+		r.disabled = true
+		r.mu.Unlock()
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return cerror.ErrReportRuntimeState.GenWithStack(
+			"report runtime state to owner %s failed, status: %d, body: %s", addr, resp.StatusCode, body)
+	}
+	return nil
+}
+
+func (r *runtimeStateReporter) resolveOwner(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	addr := r.ownerAddr
+	r.mu.Unlock()
+	if addr != "" {
+		return addr, nil
+	}
+	ownerID, err := r.etcdCli.GetOwnerID(ctx, kv.CaptureOwnerKey)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	info, err := r.etcdCli.GetCaptureInfo(ctx, ownerID)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	r.mu.Lock()
+	r.ownerAddr = info.AdvertiseAddr
+	r.mu.Unlock()
+	return info.AdvertiseAddr, nil
+}
@@ -0,0 +1,238 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/kv"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type runtimeStateSuite struct{}
+
+var _ = check.Suite(&runtimeStateSuite{})
+
+func (s *runtimeStateSuite) TestRuntimeStateStore(c *check.C) {
+	defer testleak.AfterTest(c)()
+	store := newRuntimeStateStore()
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID: "cf",
+		CaptureID:    "capture-1",
+		Position:     &model.TaskPosition{CheckPointTs: 100, ResolvedTs: 200},
+	})
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID: "cf",
+		CaptureID:    "capture-2",
+		Position:     &model.TaskPosition{CheckPointTs: 110, ResolvedTs: 210},
+		Workload:     model.TaskWorkload{1: {Workload: 1}},
+	})
+	taskStatus := model.ProcessorsInfos{
+		"capture-1": {},
+		"capture-2": {},
+	}
+	positions := store.mergePositions("cf", taskStatus, map[model.CaptureID]*model.TaskPosition{
+		"capture-1": {CheckPointTs: 90, ResolvedTs: 90},
+		"capture-3": {CheckPointTs: 80, ResolvedTs: 80},
+	})
+	c.Assert(positions, check.DeepEquals, map[model.CaptureID]*model.TaskPosition{
+		"capture-1": {CheckPointTs: 100, ResolvedTs: 200},
+		"capture-2": {CheckPointTs: 110, ResolvedTs: 210},
+		"capture-3": {CheckPointTs: 80, ResolvedTs: 80},
+	})
+
+	// the positions of the captures without task status are not merged
+	positions = store.mergePositions("cf", model.ProcessorsInfos{"capture-1": {}}, nil)
+	c.Assert(positions, check.HasLen, 1)
+	// the errors in etcd are kept
+	runningErr := &model.RunningError{Message: "error"}
+	positions = store.mergePositions("cf", taskStatus, map[model.CaptureID]*model.TaskPosition{
+		"capture-1": {Error: runningErr},
+	})
+	c.Assert(positions["capture-1"].Error, check.Equals, runningErr)
+	// the newer positions in etcd are kept
+	positions = store.mergePositions("cf", taskStatus, map[model.CaptureID]*model.TaskPosition{
+		"capture-1": {CheckPointTs: 150, ResolvedTs: 150},
+		"capture-2": {CheckPointTs: 100, ResolvedTs: 220},
+	})
+	c.Assert(positions, check.DeepEquals, map[model.CaptureID]*model.TaskPosition{
+		"capture-1": {CheckPointTs: 150, ResolvedTs: 150},
+		"capture-2": {CheckPointTs: 100, ResolvedTs: 220},
+	})
+	// the positions in etcd written by a restarted processor are kept, even
+	// if they are older than the report of the stopped processor
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID: "cf",
+		CaptureID:    "capture-1",
+		Position:     &model.TaskPosition{CheckPointTs: 300, ResolvedTs: 300, Epoch: 1},
+	})
+	positions = store.mergePositions("cf", taskStatus, map[model.CaptureID]*model.TaskPosition{
+		"capture-1": {CheckPointTs: 120, ResolvedTs: 120, Epoch: 2},
+	})
+	c.Assert(positions["capture-1"].CheckPointTs, check.Equals, uint64(120))
+	// the report of the restarted processor is used before it writes etcd
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID: "cf",
+		CaptureID:    "capture-1",
+		Position:     &model.TaskPosition{CheckPointTs: 130, ResolvedTs: 130, Epoch: 2},
+	})
+	positions = store.mergePositions("cf", taskStatus, map[model.CaptureID]*model.TaskPosition{
+		"capture-1": {CheckPointTs: 300, ResolvedTs: 300, Epoch: 1},
+	})
+	c.Assert(positions["capture-1"].CheckPointTs, check.Equals, uint64(130))
+
+	workload, ok := store.workload("cf", "capture-2")
+	c.Assert(ok, check.IsTrue)
+	c.Assert(workload, check.DeepEquals, model.TaskWorkload{1: {Workload: 1}})
+	_, ok = store.workload("cf", "capture-1")
+	c.Assert(ok, check.IsFalse)
+
+	// the expired states are ignored
+	store.positions["cf"]["capture-1"] = reportedPosition{
+		position:   &model.TaskPosition{CheckPointTs: 100},
+		reportTime: time.Now().Add(-2 * runtimeStateTTL),
+	}
+	positions = store.mergePositions("cf", taskStatus, nil)
+	c.Assert(positions, check.HasLen, 1)
+	c.Assert(store.positions["cf"], check.HasLen, 1)
+
+	store.removeCapture("capture-2")
+	c.Assert(store.mergePositions("cf", taskStatus, nil), check.HasLen, 0)
+	_, ok = store.workload("cf", "capture-2")
+	c.Assert(ok, check.IsFalse)
+
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID: "cf-2",
+		CaptureID:    "capture-1",
+		Position:     &model.TaskPosition{CheckPointTs: 100},
+		Workload:     model.TaskWorkload{1: {Workload: 1}},
+	})
+	store.removeChangefeed("cf-2")
+	c.Assert(store.positions, check.Not(check.HasKey), "cf-2")
+	c.Assert(store.workloads, check.Not(check.HasKey), "cf-2")
+}
+
+func (s *runtimeStateSuite) TestResourceUsages(c *check.C) {
+	defer testleak.AfterTest(c)()
+	store := newRuntimeStateStore()
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID: "cf-1",
+		CaptureID:    "capture-1",
+		Workload:     model.TaskWorkload{1: {Workload: 1}},
+		Usage:        &model.ResourceUsage{CPUSeconds: 1.5, MemoryBytes: 100},
+	})
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID: "cf-1",
+		CaptureID:    "capture-2",
+		Workload:     model.TaskWorkload{2: {Workload: 1}},
+		Usage:        &model.ResourceUsage{CPUSeconds: 0.5, SinkBytes: 200},
+	})
+	// the usage isn't reported by an old processor
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID: "cf-2",
+		CaptureID:    "capture-1",
+		Workload:     model.TaskWorkload{3: {Workload: 1}},
+	})
+	store.update(&model.ProcessorRuntimeState{
+		ChangefeedID: "cf-3",
+		CaptureID:    "capture-1",
+		Workload:     model.TaskWorkload{4: {Workload: 1}},
+		Usage:        &model.ResourceUsage{SorterDiskBytes: 300},
+	})
+
+	usages := store.usages("")
+	c.Assert(usages, check.DeepEquals, map[model.ChangeFeedID]map[model.CaptureID]model.ResourceUsage{
+		"cf-1": {
+			"capture-1": {CPUSeconds: 1.5, MemoryBytes: 100},
+			"capture-2": {CPUSeconds: 0.5, SinkBytes: 200},
+		},
+		"cf-3": {
+			"capture-1": {SorterDiskBytes: 300},
+		},
+	})
+	var total model.ResourceUsage
+	for _, u := range usages["cf-1"] {
+		total.Add(u)
+	}
+	c.Assert(total, check.DeepEquals, model.ResourceUsage{CPUSeconds: 2, MemoryBytes: 100, SinkBytes: 200})
+
+	// the expired usages are ignored
+	reported := store.workloads["cf-1"]["capture-2"]
+	reported.reportTime = time.Now().Add(-2 * runtimeStateTTL)
+	store.workloads["cf-1"]["capture-2"] = reported
+	usages = store.usages("cf-1")
+	c.Assert(usages, check.HasLen, 1)
+	c.Assert(usages["cf-1"], check.HasLen, 1)
+	c.Assert(store.usages("cf-4"), check.HasLen, 0)
+}
+
+func (s *runtimeStateSuite) TestRuntimeStateReporter(c *check.C) {
+	defer testleak.AfterTest(c)()
+	store := newRuntimeStateStore()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.Assert(req.URL.Path, check.Equals, runtimeStateAPI)
+		c.Assert(req.Header.Get("Authorization"), check.Equals, "Bearer token")
+		data, err := ioutil.ReadAll(req.Body)
+		c.Assert(err, check.IsNil)
+		state := new(model.ProcessorRuntimeState)
+		c.Assert(state.Unmarshal(data), check.IsNil)
+		switch state.ChangefeedID {
+		case "bad":
+			http.Error(w, "bad changefeed", http.StatusBadRequest)
+			return
+		case "unauthenticated":
+			http.Error(w, "invalid internal token", http.StatusUnauthorized)
+			return
+		}
+		store.update(state)
+	}))
+	defer server.Close()
+
+	reporter, err := newRuntimeStateReporter(kv.CDCEtcdClient{}, nil, "token")
+	c.Assert(err, check.IsNil)
+	defer reporter.httpCli.CloseIdleConnections()
+	reporter.ownerAddr = strings.TrimPrefix(server.URL, "http://")
+
+	ctx := context.Background()
+	c.Assert(reporter.available(), check.IsTrue)
+	err = reporter.report(ctx, &model.ProcessorRuntimeState{
+		ChangefeedID: "cf",
+		CaptureID:    "capture-1",
+		Position:     &model.TaskPosition{CheckPointTs: 100, ResolvedTs: 200},
+	})
+	c.Assert(err, check.IsNil)
+	positions := store.mergePositions("cf", model.ProcessorsInfos{"capture-1": {}}, nil)
+	c.Assert(positions["capture-1"], check.DeepEquals, &model.TaskPosition{CheckPointTs: 100, ResolvedTs: 200})
+
+	// the reporter is unavailable for a while after a failure
+	err = reporter.report(ctx, &model.ProcessorRuntimeState{ChangefeedID: "bad"})
+	c.Assert(err, check.ErrorMatches, "(?s).*bad changefeed.*")
+	c.Assert(reporter.available(), check.IsFalse)
+	c.Assert(reporter.ownerAddr, check.Equals, "")
+
+	// the reporter is disabled if the internal token is rejected
+	reporter.failedAt = time.Time{}
+	reporter.ownerAddr = strings.TrimPrefix(server.URL, "http://")
+	err = reporter.report(ctx, &model.ProcessorRuntimeState{ChangefeedID: "unauthenticated"})
+	c.Assert(err, check.ErrorMatches, "(?s).*invalid internal token.*")
+	reporter.failedAt = time.Time{}
+	c.Assert(reporter.available(), check.IsFalse)
+}
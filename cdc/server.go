@@ -21,12 +21,15 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/pingcap/ticdc/cdc/puller/sorter"
+	"github.com/pingcap/ticdc/pkg/auth"
+	"github.com/pingcap/ticdc/pkg/diskmanager"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/ticdc/pkg/httputil"
 	"github.com/pingcap/ticdc/pkg/security"
@@ -58,6 +61,15 @@ type options struct {
 	timezone               *time.Location
 	ownerFlushInterval     time.Duration
 	processorFlushInterval time.Duration
+	auth                   *auth.Config
+	disk                   *diskmanager.Config
+	// checkpointExportURI is the upstream TiDB the progress of the
+	// changefeeds is exported to, it's not exported if it's empty
+	checkpointExportURI      string
+	checkpointExportInterval time.Duration
+	// ignoreIncompatibleVersions only warns the upstream versions refused by
+	// the compatibility matrix
+	ignoreIncompatibleVersions bool
 }
 
 func (o *options) validateAndAdjust() error {
@@ -95,6 +107,13 @@ func (o *options) validateAndAdjust() error {
 			return errors.Annotate(err, "invalidate TLS config")
 		}
 	}
+	if o.auth != nil && len(o.auth.CertRoles) != 0 && tlsConfig == nil {
+		return cerror.ErrInvalidServerOption.GenWithStack("certificate roles require TLS to be enabled")
+	}
+	if o.checkpointExportURI != "" && o.checkpointExportInterval <= 0 {
+		return cerror.ErrInvalidServerOption.GenWithStack(
+			"the checkpoint export interval %s must be positive", o.checkpointExportInterval)
+	}
 	for _, ep := range strings.Split(o.pdEndpoints, ",") {
 		if tlsConfig != nil {
 			if strings.Index(ep, "http://") == 0 {
@@ -164,6 +183,40 @@ func Credential(credential *security.Credential) ServerOption {
 	}
 }
 
+// Auth returns a ServerOption that sets the authentication of the HTTP APIs
+func Auth(cfg *auth.Config) ServerOption {
+	return func(o *options) {
+		o.auth = cfg
+	}
+}
+
+// DiskManager returns a ServerOption that sets the data dir and the disk
+// quotas of the capture, the data dir is not used if cfg is nil.
+func DiskManager(cfg *diskmanager.Config) ServerOption {
+	return func(o *options) {
+		o.disk = cfg
+	}
+}
+
+// CheckpointExport returns a ServerOption that makes the owner write the
+// checkpoint ts and the resolved ts of the changefeeds into the table
+// tidb_cdc.changefeed_checkpoint of a TiDB on the interval.
+func CheckpointExport(uri string, interval time.Duration) ServerOption {
+	return func(o *options) {
+		o.checkpointExportURI = uri
+		o.checkpointExportInterval = interval
+	}
+}
+
+// IgnoreIncompatibleVersions returns a ServerOption that starts the capture
+// even if the versions of the upstream components are refused by the
+// compatibility matrix.
+func IgnoreIncompatibleVersions(ignore bool) ServerOption {
+	return func(o *options) {
+		o.ignoreIncompatibleVersions = ignore
+	}
+}
+
 // A ServerOption sets options such as the addr of PD.
 type ServerOption func(*options)
 
@@ -176,6 +229,11 @@ type Server struct {
 	statusServer *http.Server
 	pdClient     pd.Client
 	pdEndpoints  []string
+	// draining is set once the server starts to shut down gracefully
+	draining int32
+	// dataDirHealth is the result of the health checks of the data dir at
+	// startup, it's nil if the data dir is not configured.
+	dataDirHealth *diskmanager.DirHealth
 }
 
 // NewServer creates a Server instance.
@@ -195,17 +253,43 @@ func NewServer(opt ...ServerOption) (*Server, error) {
 		zap.Any("timezone", opts.timezone),
 		zap.Duration("owner-flush-interval", opts.ownerFlushInterval),
 		zap.Duration("processor-flush-interval", opts.processorFlushInterval),
+		zap.Bool("auth-enabled", opts.auth.IsEnabled()),
 	)
 
 	s := &Server{
 		opts: opts,
 	}
+	if opts.disk != nil {
+		health := diskmanager.CheckDir(opts.disk.DataDir, opts.disk.MinFreeBytes)
+		for _, warning := range health.Warnings {
+			log.Warn("data dir is risky", zap.String("data-dir", health.Dir), zap.String("warning", warning))
+		}
+		switch {
+		case health.Healthy():
+			m, err := diskmanager.NewManager(opts.disk, opts.advertiseAddr)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			diskmanager.SetGlobal(m)
+		case opts.disk.MemoryFallback:
+			log.Warn("data dir is unhealthy, the unified sorter falls back to the memory sorter",
+				zap.String("data-dir", health.Dir), zap.String("error", health.Error))
+			health.MemoryFallback = true
+			diskmanager.SetMemoryFallback(true)
+		default:
+			return nil, cerror.ErrDiskManager.GenWithStack("data dir %s is unhealthy: %s", health.Dir, health.Error)
+		}
+		s.dataDirHealth = health
+	}
 	return s, nil
 }
 
 // Run runs the server.
 func (s *Server) Run(ctx context.Context) error {
 	s.pdEndpoints = strings.Split(s.opts.pdEndpoints, ",")
+	// the client certificates of the etcd client and the kv client are reloaded
+	// once they are rotated, while the PD client and the TiKV store load them
+	// by themselves when they connect to a new PD member or TiKV store
 	grpcTLSOption, err := s.opts.credential.ToGRPCDialOption()
 	if err != nil {
 		return errors.Trace(err)
@@ -230,10 +314,7 @@ func (s *Server) Run(ctx context.Context) error {
 	}
 	s.pdClient = pdClient
 
-	// To not block CDC server startup, we need to warn instead of error
-	// when TiKV is incompatible.
-	errorTiKVIncompatible := false
-	err = version.CheckClusterVersion(ctx, s.pdClient, s.pdEndpoints[0], s.opts.credential, errorTiKVIncompatible)
+	err = version.CheckClusterVersion(ctx, s.pdClient, s.pdEndpoints[0], s.opts.credential, s.opts.ignoreIncompatibleVersions)
 	if err != nil {
 		return err
 	}
@@ -265,6 +346,10 @@ func (s *Server) Run(ctx context.Context) error {
 func (s *Server) setOwner(owner *Owner) {
 	s.ownerLock.Lock()
 	defer s.ownerLock.Unlock()
+	if owner != nil {
+		// the gc ttl may be reloaded during the owner is created
+		owner.setGcTTL(atomic.LoadInt64(&s.opts.gcTTL))
+	}
 	s.owner = owner
 }
 
@@ -295,7 +380,7 @@ func (s *Server) campaignOwnerLoop(ctx context.Context) error {
 		}
 		captureID := s.capture.info.ID
 		log.Info("campaign owner successfully", zap.String("capture-id", captureID))
-		owner, err := NewOwner(ctx, s.pdClient, s.opts.credential, s.capture.session, s.opts.gcTTL, s.opts.ownerFlushInterval)
+		owner, err := NewOwner(ctx, s.pdClient, s.opts.credential, s.capture.session, atomic.LoadInt64(&s.opts.gcTTL), s.opts.ownerFlushInterval)
 		if err != nil {
 			log.Warn("create new owner failed", zap.Error(err))
 			continue
@@ -370,12 +455,23 @@ func (s *Server) run(ctx context.Context) (err error) {
 	ctx = util.PutCaptureAddrInCtx(ctx, s.opts.advertiseAddr)
 	ctx = util.PutTimezoneInCtx(ctx, s.opts.timezone)
 
-	procOpts := &processorOpts{flushCheckpointInterval: s.opts.processorFlushInterval}
+	procOpts := &processorOpts{
+		flushCheckpointInterval: s.opts.processorFlushInterval,
+		authToken:               s.opts.auth.InternalToken(),
+	}
+	if s.opts.auth.IsEnabled() && procOpts.authToken == "" {
+		log.Warn("no internal token is loaded, the processors write the runtime states to etcd instead of reporting them to the owner")
+	}
 	capture, err := NewCapture(ctx, s.pdEndpoints, s.pdClient, s.opts.credential, s.opts.advertiseAddr, procOpts)
 	if err != nil {
 		return err
 	}
+	capture.info.DataDir = s.dataDirHealth
 	s.capture = capture
+	err = version.CheckTiDBVersion(ctx, capture.etcdClient.Client.Unwrap(), s.opts.ignoreIncompatibleVersions)
+	if err != nil {
+		return err
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -397,9 +493,31 @@ func (s *Server) run(ctx context.Context) (err error) {
 		return s.capture.Run(cctx)
 	})
 
+	if s.opts.checkpointExportURI != "" {
+		wg.Go(func() error {
+			return s.exportCheckpointLoop(cctx)
+		})
+	}
+
 	return wg.Wait()
 }
 
+// Drain moves the tables of the capture to the other captures before the
+// server exits, so the shutdown doesn't interrupt the replication. It returns
+// once the tables are moved away or the context is done, and the server is
+// not ready since then.
+func (s *Server) Drain(ctx context.Context) error {
+	atomic.StoreInt32(&s.draining, 1)
+	if s.capture == nil {
+		return nil
+	}
+	return s.capture.Drain(ctx)
+}
+
+func (s *Server) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
 // Close closes the server.
 func (s *Server) Close() {
 	if s.capture != nil {
@@ -0,0 +1,107 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"sync/atomic"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/pkg/config"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/logutil"
+	"go.uber.org/zap"
+)
+
+// ReloadConfig applies the reloadable settings to the running server, the
+// tables replicated by the capture are not rescheduled. The settings with zero
+// values are left unchanged.
+func (s *Server) ReloadConfig(cfg *config.ServerConfig) error {
+	sorterCfg, err := mergeSorterConfig(config.GetSorterConfig(), cfg.Sorter)
+	if err != nil {
+		return err
+	}
+	if cfg.GcTTL < 0 {
+		return cerror.ErrInvalidServerConfig.GenWithStack("gc-ttl %d is negative", cfg.GcTTL)
+	}
+	// the log level is validated before it's changed
+	if cfg.LogLevel != "" {
+		if err := logutil.SetLogLevel(cfg.LogLevel); err != nil {
+			return cerror.WrapError(cerror.ErrInvalidServerConfig, err)
+		}
+	}
+	if cfg.GcTTL != 0 {
+		s.ownerLock.Lock()
+		atomic.StoreInt64(&s.opts.gcTTL, cfg.GcTTL)
+		if s.owner != nil {
+			s.owner.setGcTTL(cfg.GcTTL)
+		}
+		s.ownerLock.Unlock()
+	}
+	if sorterCfg != nil {
+		config.SetSorterConfig(sorterCfg)
+	}
+	log.Info("server config reloaded",
+		zap.String("log-level", cfg.LogLevel),
+		zap.Int64("gc-ttl", cfg.GcTTL),
+		zap.Any("sorter", sorterCfg))
+	return nil
+}
+
+// currentConfig returns the current values of the reloadable settings.
+func (s *Server) currentConfig() *config.ServerConfig {
+	cfg := &config.ServerConfig{
+		LogLevel: log.GetLevel().String(),
+		GcTTL:    atomic.LoadInt64(&s.opts.gcTTL),
+	}
+	if sorterCfg := config.GetSorterConfig(); sorterCfg != nil {
+		clone := *sorterCfg
+		cfg.Sorter = &clone
+	}
+	return cfg
+}
+
+// mergeSorterConfig returns a copy of the current sorter config updated by
+// the non-zero settings in update, or nil if there is nothing to update.
+func mergeSorterConfig(current, update *config.SorterConfig) (*config.SorterConfig, error) {
+	if update == nil {
+		return nil, nil
+	}
+	if current == nil {
+		return nil, cerror.ErrInvalidServerConfig.GenWithStack("the sorter is not configured")
+	}
+	if update.NumWorkerPoolGoroutine != 0 && update.NumWorkerPoolGoroutine != current.NumWorkerPoolGoroutine {
+		// the workerpool is started once in the process
+		return nil, cerror.ErrInvalidServerConfig.GenWithStack("num-workerpool-goroutine can't be reloaded")
+	}
+	if update.NumConcurrentWorker < 0 {
+		return nil, cerror.ErrInvalidServerConfig.GenWithStack("num-concurrent-workers %d is negative", update.NumConcurrentWorker)
+	}
+	if update.MaxMemoryPressure < 0 || update.MaxMemoryPressure > 100 {
+		return nil, cerror.ErrInvalidServerConfig.GenWithStack("max-memory-pressure %d is not in [0, 100]", update.MaxMemoryPressure)
+	}
+	merged := *current
+	if update.NumConcurrentWorker != 0 {
+		merged.NumConcurrentWorker = update.NumConcurrentWorker
+	}
+	if update.ChunkSizeLimit != 0 {
+		merged.ChunkSizeLimit = update.ChunkSizeLimit
+	}
+	if update.MaxMemoryPressure != 0 {
+		merged.MaxMemoryPressure = update.MaxMemoryPressure
+	}
+	if update.MaxMemoryConsumption != 0 {
+		merged.MaxMemoryConsumption = update.MaxMemoryConsumption
+	}
+	return &merged, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"github.com/pingcap/check"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+	"go.uber.org/zap/zapcore"
+)
+
+type serverConfigSuite struct{}
+
+var _ = check.Suite(&serverConfigSuite{})
+
+func (s *serverConfigSuite) TestReloadConfig(c *check.C) {
+	defer testleak.AfterTest(c)()
+	sorterCfg := config.GetSorterConfig()
+	defer config.SetSorterConfig(sorterCfg)
+	level := log.GetLevel()
+	defer log.SetLevel(level)
+
+	config.SetSorterConfig(&config.SorterConfig{
+		NumConcurrentWorker:    4,
+		ChunkSizeLimit:         1024,
+		MaxMemoryPressure:      80,
+		MaxMemoryConsumption:   8192,
+		NumWorkerPoolGoroutine: 16,
+	})
+	server := &Server{opts: testingServerOptions}
+	owner := &Owner{gcTTL: DefaultCDCGCSafePointTTL}
+	server.setOwner(owner)
+
+	err := server.ReloadConfig(&config.ServerConfig{
+		LogLevel: "warn",
+		GcTTL:    3600,
+		Sorter:   &config.SorterConfig{MaxMemoryConsumption: 4096, ChunkSizeLimit: 2048},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(log.GetLevel(), check.Equals, zapcore.WarnLevel)
+	c.Assert(owner.gcTTL, check.Equals, int64(3600))
+	c.Assert(server.currentConfig(), check.DeepEquals, &config.ServerConfig{
+		LogLevel: "warn",
+		GcTTL:    3600,
+		Sorter: &config.SorterConfig{
+			NumConcurrentWorker:    4,
+			ChunkSizeLimit:         2048,
+			MaxMemoryPressure:      80,
+			MaxMemoryConsumption:   4096,
+			NumWorkerPoolGoroutine: 16,
+		},
+	})
+
+	// nothing is changed if any setting is invalid
+	err = server.ReloadConfig(&config.ServerConfig{
+		LogLevel: "debug",
+		Sorter:   &config.SorterConfig{NumWorkerPoolGoroutine: 8},
+	})
+	c.Assert(err, check.ErrorMatches, ".*num-workerpool-goroutine can't be reloaded.*")
+	err = server.ReloadConfig(&config.ServerConfig{LogLevel: "debug", GcTTL: -1})
+	c.Assert(err, check.ErrorMatches, ".*gc-ttl -1 is negative.*")
+	err = server.ReloadConfig(&config.ServerConfig{GcTTL: 60, LogLevel: "unknown"})
+	c.Assert(err, check.NotNil)
+	c.Assert(log.GetLevel(), check.Equals, zapcore.WarnLevel)
+	c.Assert(owner.gcTTL, check.Equals, int64(3600))
+	c.Assert(config.GetSorterConfig().MaxMemoryConsumption, check.Equals, uint64(4096))
+
+	// the new owner uses the reloaded gc ttl
+	server.setOwner(nil)
+	owner = &Owner{gcTTL: DefaultCDCGCSafePointTTL}
+	server.setOwner(owner)
+	c.Assert(owner.gcTTL, check.Equals, int64(3600))
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"time"
+)
+
+// defaultTargetFlushLatency is the default flush latency the adaptive batch
+// size tries to keep.
+const defaultTargetFlushLatency = 200 * time.Millisecond
+
+// adaptiveBatchSize tunes the size of the batches flushed to the downstream
+// within [min, max] by the observed flush latency and errors. It grows the
+// size additively while the full batches are flushed much faster than the
+// target latency, and shrinks it multiplicatively when a flush is slower than
+// the target or fails, so the batches follow the capacity of the downstream.
+//
+// It's not safe for concurrent use, each sink worker owns one.
+type adaptiveBatchSize struct {
+	min           int
+	max           int
+	targetLatency time.Duration
+	size          int
+}
+
+// newAdaptiveBatchSize creates an adaptiveBatchSize, which starts from the max
+// size, that is the batch size used if the adaptive batching is disabled.
+func newAdaptiveBatchSize(min, max int, targetLatency time.Duration) *adaptiveBatchSize {
+	if min <= 0 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	if targetLatency <= 0 {
+		targetLatency = defaultTargetFlushLatency
+	}
+	return &adaptiveBatchSize{
+		min:           min,
+		max:           max,
+		targetLatency: targetLatency,
+		size:          max,
+	}
+}
+
+// get returns the current batch size.
+func (b *adaptiveBatchSize) get() int {
+	return b.size
+}
+
+// observe records a successful flush of a batch of the given size.
+func (b *adaptiveBatchSize) observe(size int, latency time.Duration) {
+	switch {
+	case latency > b.targetLatency:
+		b.set(b.size - b.size/4 - 1)
+	case latency < b.targetLatency/2 && size >= b.size:
+		// only the full batches tell that the downstream can take more
+		b.set(b.size + b.size/8 + 1)
+	}
+}
+
+// observeError records a failed flush.
+func (b *adaptiveBatchSize) observeError() {
+	b.set(b.size / 2)
+}
+
+func (b *adaptiveBatchSize) set(size int) {
+	if size < b.min {
+		size = b.min
+	}
+	if size > b.max {
+		size = b.max
+	}
+	b.size = size
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type adaptiveBatchSuite struct{}
+
+var _ = check.Suite(&adaptiveBatchSuite{})
+
+func (s *adaptiveBatchSuite) TestAdaptiveBatchSize(c *check.C) {
+	defer testleak.AfterTest(c)()
+	b := newAdaptiveBatchSize(16, 256, 100*time.Millisecond)
+	c.Assert(b.get(), check.Equals, 256)
+
+	// shrinks while the flushes are slow
+	for i := 0; i < 100; i++ {
+		b.observe(b.get(), time.Second)
+	}
+	c.Assert(b.get(), check.Equals, 16)
+
+	// the partial batches don't grow the size
+	b.observe(1, time.Millisecond)
+	c.Assert(b.get(), check.Equals, 16)
+	// neither do the flushes close to the target
+	b.observe(16, 80*time.Millisecond)
+	c.Assert(b.get(), check.Equals, 16)
+
+	// grows while the full batches are fast
+	last := b.get()
+	for i := 0; i < 100; i++ {
+		b.observe(b.get(), time.Millisecond)
+		c.Assert(b.get(), check.GreaterEqual, last)
+		last = b.get()
+	}
+	c.Assert(b.get(), check.Equals, 256)
+
+	b.observeError()
+	c.Assert(b.get(), check.Equals, 128)
+	for i := 0; i < 10; i++ {
+		b.observeError()
+	}
+	c.Assert(b.get(), check.Equals, 16)
+
+	// the bounds are adjusted
+	b = newAdaptiveBatchSize(0, -1, 0)
+	c.Assert(b.min, check.Equals, 1)
+	c.Assert(b.max, check.Equals, 1)
+	c.Assert(b.targetLatency, check.Equals, defaultTargetFlushLatency)
+}
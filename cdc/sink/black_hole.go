@@ -49,6 +49,7 @@ func (b *blackHoleSink) EmitRowChangedEvents(ctx context.Context, rows ...*model
 	rowsCount := len(rows)
 	atomic.AddUint64(&b.accumulated, uint64(rowsCount))
 	b.statistics.AddRowsCount(rowsCount)
+	b.statistics.AddCommitTs(rows)
 	return nil
 }
 
@@ -61,6 +62,7 @@ func (b *blackHoleSink) FlushRowChangedEvents(ctx context.Context, resolvedTs ui
 		b.lastAccumulated = accumulated
 		return int(batchSize), nil
 	})
+	b.statistics.ObserveFlushed(resolvedTs)
 	b.statistics.PrintStatus(ctx)
 	atomic.StoreUint64(&b.checkpointTs, resolvedTs)
 	return resolvedTs, err
@@ -15,8 +15,10 @@ package sink
 
 import (
 	"encoding/binary"
+	"strings"
 
 	"github.com/pingcap/log"
+	"github.com/pingcap/parser/charset"
 	"go.uber.org/zap"
 
 	"github.com/pingcap/ticdc/cdc/model"
@@ -90,6 +92,15 @@ func genTxnKeys(txn *model.SingleTableTxn) [][]byte {
 
 func genRowKeys(row *model.RowChangedEvent) [][]byte {
 	var keys [][]byte
+	// The conflicts on some unique indexes can't be detected by the values,
+	// they can only be detected by the table.
+	useTableKey := false
+	for _, idxCol := range row.IndexColumns {
+		if !isKeyComparable(row.Columns, idxCol) || !isKeyComparable(row.PreColumns, idxCol) {
+			useTableKey = true
+			break
+		}
+	}
 	if len(row.Columns) != 0 {
 		for iIdx, idxCol := range row.IndexColumns {
 			key := genKeyList(row.Columns, iIdx, idxCol, row.Table.TableID)
@@ -108,13 +119,13 @@ func genRowKeys(row *model.RowChangedEvent) [][]byte {
 			keys = append(keys, key)
 		}
 	}
-	if len(keys) == 0 {
+	if len(keys) == 0 || useTableKey {
 		// use table ID as key if no key generated (no PK/UK),
 		// no concurrence for rows in the same table.
 		log.Debug("use table id as the key", zap.Int64("tableID", row.Table.TableID))
 		tableKey := make([]byte, 8)
 		binary.BigEndian.PutUint64(tableKey, uint64(row.Table.TableID))
-		keys = [][]byte{tableKey}
+		keys = append(keys, tableKey)
 	}
 	return keys
 }
@@ -123,12 +134,12 @@ func genKeyList(columns []*model.Column, iIdx int, colIdx []int, tableID int64)
 	var key []byte
 	for _, i := range colIdx {
 		// if a column value is null, we can ignore this index
-		// If the index contain generated column, we can't use this key to detect conflict with other DML,
-		// Because such as insert can't specified the generated value.
-		if columns[i] == nil || columns[i].Value == nil || columns[i].Flag.IsGeneratedColumn() {
+		// the values of the stored generated columns are mounted, so they can
+		// be used to detect conflicts as well
+		if columns[i] == nil || columns[i].Value == nil {
 			return nil
 		}
-		key = append(key, []byte(model.ColumnValueString(columns[i].Value))...)
+		key = append(key, []byte(keyValueString(columns[i]))...)
 		key = append(key, 0)
 	}
 	if len(key) == 0 {
@@ -140,3 +151,35 @@ func genKeyList(columns []*model.Column, iIdx int, colIdx []int, tableID int64)
 	key = append(key, tableKey...)
 	return key
 }
+
+// isKeyComparable returns whether the conflicts on the unique index can be
+// detected by comparing the bytes of the column values.
+func isKeyComparable(columns []*model.Column, colIdx []int) bool {
+	if len(columns) == 0 {
+		return true
+	}
+	// the values of a unique index without visible columns, such as a unique
+	// expression index, are not available
+	if len(colIdx) == 0 {
+		return false
+	}
+	for _, i := range colIdx {
+		// the different values may be equal in a case insensitive collation
+		if columns[i] != nil && strings.HasSuffix(columns[i].Collation, "_ci") {
+			return false
+		}
+	}
+	return true
+}
+
+// keyValueString returns the value of a column used in the keys. The trailing
+// spaces of the text values are trimmed, since they are ignored by the PAD
+// SPACE collations. Trimming them in the NO PAD collations only causes more
+// conflicts.
+func keyValueString(col *model.Column) string {
+	value := model.ColumnValueString(col.Value)
+	if col.Charset != "" && col.Charset != charset.CharsetBin {
+		value = strings.TrimRight(value, " ")
+	}
+	return value
+}
@@ -209,6 +209,29 @@ func (s *testCausalitySuite) TestGenKeys(c *check.C) {
 			{'1', 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 47},
 			{0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 47},
 		},
+	}, {
+		// the second unique index is an expression index without visible
+		// columns, the table key is used as well
+		txn: &model.SingleTableTxn{
+			Rows: []*model.RowChangedEvent{
+				{
+					StartTs:  418658114257813514,
+					CommitTs: 418658114257813515,
+					Table:    &model.TableName{Schema: "common_1", Table: "uk_expr", TableID: 47},
+					Columns: []*model.Column{nil, {
+						Name:  "a1",
+						Type:  mysql.TypeLong,
+						Flag:  model.BinaryFlag | model.HandleKeyFlag,
+						Value: 1,
+					}},
+					IndexColumns: [][]int{{1}, {}},
+				},
+			},
+		},
+		expected: [][]byte{
+			{'1', 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 47},
+			{0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 47},
+		},
 	}}
 	for _, tc := range testCases {
 		keys := genTxnKeys(tc.txn)
@@ -218,3 +241,33 @@ func (s *testCausalitySuite) TestGenKeys(c *check.C) {
 		c.Assert(keys, check.DeepEquals, tc.expected)
 	}
 }
+
+func (s *testCausalitySuite) TestGenKeysCollation(c *check.C) {
+	defer testleak.AfterTest(c)()
+	newRow := func(value string, charset, collation string) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			Table: &model.TableName{Schema: "test", Table: "t", TableID: 47},
+			Columns: []*model.Column{{
+				Name:      "a",
+				Type:      mysql.TypeVarchar,
+				Flag:      model.HandleKeyFlag | model.PrimaryKeyFlag,
+				Value:     []byte(value),
+				Charset:   charset,
+				Collation: collation,
+			}},
+			IndexColumns: [][]int{{0}},
+		}
+	}
+	tableKey := []byte{0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 47}
+
+	// the trailing spaces are ignored by the PAD SPACE collations
+	c.Assert(genRowKeys(newRow("a  ", "utf8mb4", "utf8mb4_bin")), check.DeepEquals,
+		genRowKeys(newRow("a", "utf8mb4", "utf8mb4_bin")))
+	// but they are kept in the binary values
+	c.Assert(genRowKeys(newRow("a  ", "binary", "binary")), check.Not(check.DeepEquals),
+		genRowKeys(newRow("a", "binary", "binary")))
+	// the values of a case insensitive collation can only conflict by the table
+	keys := genRowKeys(newRow("a", "utf8mb4", "utf8mb4_general_ci"))
+	c.Assert(keys, check.HasLen, 2)
+	c.Assert(keys[1], check.DeepEquals, tableKey)
+}
@@ -0,0 +1,339 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type azblobSuite struct{}
+
+var _ = check.Suite(&azblobSuite{})
+
+// fakeAzblobServer is a minimal in-memory azure blob service of a single container.
+// It accepts the requests signed by the sas token `sig=secret`, or by the shared
+// key of the account `test` if key is set.
+type fakeAzblobServer struct {
+	key []byte
+
+	mu     sync.Mutex
+	blobs  map[string][]byte
+	blocks map[string][]byte
+}
+
+func newFakeAzblobServer() *fakeAzblobServer {
+	return &fakeAzblobServer{
+		blobs:  make(map[string][]byte),
+		blocks: make(map[string][]byte),
+	}
+}
+
+func (f *fakeAzblobServer) authorized(r *http.Request) bool {
+	if r.Header.Get("x-ms-version") != azblobAPIVersion || r.Header.Get("x-ms-date") == "" {
+		return false
+	}
+	if r.URL.Query().Get("sig") == "secret" {
+		return true
+	}
+	if f.key == nil {
+		return false
+	}
+	cred := &azblobSharedKeyCredential{account: "test", key: f.key}
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write([]byte(cred.stringToSign(r)))
+	expected := "SharedKey test:" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return r.Header.Get("Authorization") == expected
+}
+
+func (f *fakeAzblobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.authorized(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	// path is /container/blob
+	name := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	query := r.URL.Query()
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodGet && query.Get("comp") == "list":
+		keys := make([]string, 0, len(f.blobs))
+		for k := range f.blobs {
+			if strings.HasPrefix(k, query.Get("prefix")) && k > query.Get("marker") {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		nextMarker := ""
+		if maxResults, err := strconv.Atoi(query.Get("maxresults")); err == nil && len(keys) > maxResults {
+			keys = keys[:maxResults]
+			nextMarker = keys[len(keys)-1]
+		}
+		fmt.Fprint(w, "<EnumerationResults><Blobs>")
+		for _, k := range keys {
+			fmt.Fprintf(w, "<Blob><Name>%s</Name><Properties><Content-Length>%d</Content-Length></Properties></Blob>",
+				k, len(f.blobs[k]))
+		}
+		fmt.Fprintf(w, "</Blobs><NextMarker>%s</NextMarker></EnumerationResults>", nextMarker)
+	case r.Method == http.MethodGet:
+		data, ok := f.blobs[name[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case r.Method == http.MethodDelete:
+		if _, ok := f.blobs[name[1]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.blobs, name[1])
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		f.blocks[query.Get("blockid")] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		if err := xml.Unmarshal(body, &list); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var data []byte
+		for _, id := range list.Latest {
+			block, ok := f.blocks[id]
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data = append(data, block...)
+		}
+		f.blobs[name[1]] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && r.Header.Get("x-ms-blob-type") == "BlockBlob":
+		f.blobs[name[1]] = body
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newTestAzblobStorage(c *check.C, serverURL, params string) *azblobStorage {
+	sinkURI, err := url.Parse(fmt.Sprintf("azblob://container/prefix?account-name=test&%s&endpoint=%s",
+		params, url.QueryEscape(serverURL)))
+	c.Assert(err, check.IsNil)
+	st, err := newAzblobStorage(context.Background(), sinkURI)
+	c.Assert(err, check.IsNil)
+	c.Assert(st.prefix, check.Equals, "prefix")
+	st.client = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	return st
+}
+
+func (s *azblobSuite) TestAzblobStorage(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	server := httptest.NewServer(newFakeAzblobServer())
+	defer server.Close()
+	st := newTestAzblobStorage(c, server.URL, "sas-token=sig=secret")
+
+	c.Assert(st.Write(ctx, "t_1/cdclog.1", []byte("hello")), check.IsNil)
+	data, err := st.Read(ctx, "t_1/cdclog.1")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "hello")
+
+	uploader, err := st.CreateUploader(ctx, "t_2/cdclog.2")
+	c.Assert(err, check.IsNil)
+	c.Assert(uploader.UploadPart(ctx, []byte("foo")), check.IsNil)
+	c.Assert(uploader.UploadPart(ctx, []byte("bar")), check.IsNil)
+	c.Assert(uploader.CompleteUpload(ctx), check.IsNil)
+	data, err = st.Read(ctx, "t_2/cdclog.2")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "foobar")
+
+	var paths []string
+	err = st.WalkDir(ctx, &storage.WalkOption{SubDir: "t_2"}, func(path string, size int64) error {
+		paths = append(paths, path)
+		c.Assert(size, check.Equals, int64(6))
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(paths, check.DeepEquals, []string{"t_2/cdclog.2"})
+
+	c.Assert(st.Remove(ctx, "t_2/cdclog.2"), check.IsNil)
+	_, err = st.Read(ctx, "t_2/cdclog.2")
+	c.Assert(err, check.ErrorMatches, ".*404.*")
+	c.Assert(st.Remove(ctx, "t_2/cdclog.2"), check.ErrorMatches, ".*404.*")
+
+	// the requests without a valid signature are rejected
+	st = newTestAzblobStorage(c, server.URL, "sas-token=sig=wrong")
+	_, err = st.Read(ctx, "t_1/cdclog.1")
+	c.Assert(err, check.ErrorMatches, ".*403.*")
+}
+
+func (s *azblobSuite) TestAzblobWalkDirPages(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	server := httptest.NewServer(newFakeAzblobServer())
+	defer server.Close()
+	st := newTestAzblobStorage(c, server.URL, "sas-token=sig=secret")
+
+	var expected []string
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("manifests/manifest.%d", i)
+		c.Assert(st.Write(ctx, name, []byte("m")), check.IsNil)
+		expected = append(expected, name)
+	}
+	c.Assert(st.Write(ctx, "ddls/ddl.1", []byte("d")), check.IsNil)
+
+	// the objects are listed in pages of ListCount
+	var paths []string
+	err := st.WalkDir(ctx, &storage.WalkOption{SubDir: "manifests", ListCount: 2}, func(path string, size int64) error {
+		paths = append(paths, path)
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(paths, check.DeepEquals, expected)
+
+	// the error returned by fn stops the walk
+	paths = nil
+	err = st.WalkDir(ctx, &storage.WalkOption{SubDir: "manifests", ListCount: 2}, func(path string, size int64) error {
+		paths = append(paths, path)
+		return fmt.Errorf("stop")
+	})
+	c.Assert(err, check.ErrorMatches, "stop")
+	c.Assert(paths, check.HasLen, 1)
+}
+
+func (s *azblobSuite) TestAzblobSharedKey(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	fake := newFakeAzblobServer()
+	fake.key = []byte("account-key")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	key := url.QueryEscape(base64.StdEncoding.EncodeToString(fake.key))
+	st := newTestAzblobStorage(c, server.URL, "account-key="+key)
+
+	// the content length and the escaped path are signed
+	c.Assert(st.Write(ctx, "t_1/cdclog 1", []byte("hello")), check.IsNil)
+	data, err := st.Read(ctx, "t_1/cdclog 1")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "hello")
+	uploader, err := st.CreateUploader(ctx, "t_2/cdclog.2")
+	c.Assert(err, check.IsNil)
+	c.Assert(uploader.UploadPart(ctx, []byte("foo")), check.IsNil)
+	c.Assert(uploader.CompleteUpload(ctx), check.IsNil)
+	err = st.WalkDir(ctx, &storage.WalkOption{SubDir: "t_1"}, func(path string, size int64) error {
+		c.Assert(path, check.Equals, "t_1/cdclog 1")
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+
+	st = newTestAzblobStorage(c, server.URL, "account-key="+url.QueryEscape(base64.StdEncoding.EncodeToString([]byte("wrong"))))
+	_, err = st.Read(ctx, "t_1/cdclog 1")
+	c.Assert(err, check.ErrorMatches, ".*403.*")
+
+	sinkURI, err := url.Parse("azblob://container/prefix?account-name=test&account-key=not-base64!")
+	c.Assert(err, check.IsNil)
+	_, err = newAzblobStorage(ctx, sinkURI)
+	c.Assert(err, check.ErrorMatches, ".*invalid azure storage account key.*")
+}
+
+func (s *azblobSuite) TestSharedKeyStringToSign(c *check.C) {
+	defer testleak.AfterTest(c)()
+	req, err := http.NewRequest(http.MethodGet,
+		"https://test.blob.core.windows.net/container?restype=container&comp=list&prefix=a%2F", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("x-ms-version", azblobAPIVersion)
+	req.Header.Set("x-ms-date", "Mon, 02 Jan 2006 15:04:05 GMT")
+	cred := &azblobSharedKeyCredential{account: "test", key: []byte("key")}
+	stringToSign := "GET\n\n\n\n\n\n\n\n\n\n\n\n" +
+		"x-ms-date:Mon, 02 Jan 2006 15:04:05 GMT\nx-ms-version:2019-12-12\n" +
+		"/test/container\ncomp:list\nprefix:a/\nrestype:container"
+	c.Assert(cred.stringToSign(req), check.Equals, stringToSign)
+	c.Assert(cred.sign(context.Background(), req), check.IsNil)
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte(stringToSign))
+	c.Assert(req.Header.Get("Authorization"), check.Equals,
+		"SharedKey test:"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	// the content length of a put is signed, the blob type header is canonicalized
+	req, err = http.NewRequest(http.MethodPut, "https://test.blob.core.windows.net/container/a/b", strings.NewReader("hello"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("x-ms-version", azblobAPIVersion)
+	req.Header.Set("x-ms-date", "Mon, 02 Jan 2006 15:04:05 GMT")
+	c.Assert(cred.stringToSign(req), check.Equals, "PUT\n\n\n5\n\n\n\n\n\n\n\n\n"+
+		"x-ms-blob-type:BlockBlob\nx-ms-date:Mon, 02 Jan 2006 15:04:05 GMT\nx-ms-version:2019-12-12\n"+
+		"/test/container/a/b")
+}
+
+func (s *azblobSuite) TestManagedIdentityToken(c *check.C) {
+	defer testleak.AfterTest(c)()
+	var (
+		mu       sync.Mutex
+		requests int
+		expireAt = time.Now().Add(time.Hour)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.Header.Get("Metadata"), check.Equals, "true")
+		c.Assert(r.URL.Query().Get("resource"), check.Equals, azblobStorageScope)
+		c.Assert(r.URL.Query().Get("client_id"), check.Equals, "client")
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_on":"%d"}`, requests, expireAt.Unix())
+	}))
+	defer server.Close()
+	tokenURL := azblobIMDSTokenURL
+	azblobIMDSTokenURL = server.URL
+	defer func() {
+		azblobIMDSTokenURL = tokenURL
+	}()
+
+	transport := &http.Transport{DisableKeepAlives: true}
+	cred := &azblobManagedIdentityCredential{clientID: "client", client: &http.Client{Transport: transport}}
+	ctx := context.Background()
+	sign := func() string {
+		req, err := http.NewRequest(http.MethodGet, "https://test.blob.core.windows.net/container/a", nil)
+		c.Assert(err, check.IsNil)
+		c.Assert(cred.sign(ctx, req), check.IsNil)
+		return req.Header.Get("Authorization")
+	}
+	c.Assert(sign(), check.Equals, "Bearer token-1")
+	// the token is cached until it's about to expire
+	c.Assert(sign(), check.Equals, "Bearer token-1")
+	cred.expireAt = time.Now().Add(time.Minute)
+	c.Assert(sign(), check.Equals, "Bearer token-2")
+}
@@ -0,0 +1,468 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/quotes"
+	"go.uber.org/zap"
+)
+
+// DownstreamSink is the sink the consumer replicates the log sink output to,
+// it is implemented by sink.Sink.
+type DownstreamSink interface {
+	EmitRowChangedEvents(ctx context.Context, rows ...*model.RowChangedEvent) error
+	EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error
+	FlushRowChangedEvents(ctx context.Context, resolvedTs uint64) (uint64, error)
+}
+
+// Consumer tails the output of the log sink and applies it to a downstream sink
+// in commit ts order. Only the data files committed by manifests are read, so the
+// downstream always sees a consistent snapshot at the resolved ts of a manifest.
+type Consumer struct {
+	*logSink
+	downstream   DownstreamSink
+	pollInterval time.Duration
+	// appliedTs is the resolved ts of the latest manifest applied to the downstream
+	appliedTs uint64
+	// endTs is the max commit ts of the events to apply, zero means unlimited
+	endTs uint64
+	// ddls are the loaded DDL events not applied yet in commit ts order
+	ddls []*model.DDLEvent
+	// ddlFileSizes are the sizes of the ddl files when they are read
+	ddlFileSizes map[string]int64
+}
+
+// NewConsumer creates a consumer reading the output of the log sink at sinkURI.
+// The events whose commit ts is less than or equal to startTs are skipped.
+func NewConsumer(
+	ctx context.Context, sinkURI *url.URL, downstream DownstreamSink, startTs uint64, pollInterval time.Duration,
+) (*Consumer, error) {
+	var (
+		root       string
+		extStorage externalStorage
+		err        error
+	)
+	switch strings.ToLower(sinkURI.Scheme) {
+	case "local", "file":
+		root = sinkURI.Path + "/"
+	default:
+		extStorage, _, err = newExternalStorage(ctx, sinkURI)
+		if err != nil {
+			return nil, err
+		}
+	}
+	opts, err := parseOptions(sinkURI, maxRowFileSize)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := parseEncryption(sinkURI)
+	if err != nil {
+		return nil, err
+	}
+	c := &Consumer{
+		logSink:      newLogSink(root, extStorage, opts),
+		downstream:   downstream,
+		pollInterval: pollInterval,
+		appliedTs:    startTs,
+	}
+	c.encryptor = enc
+	return c, nil
+}
+
+// AppliedTs returns the resolved ts of the latest manifest applied to the downstream,
+// the consumer can be restarted from it.
+func (c *Consumer) AppliedTs() uint64 {
+	return c.appliedTs
+}
+
+// Run applies the committed manifests to the downstream until the context is canceled.
+func (c *Consumer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.consumeOnce(ctx); err != nil {
+			return errors.Trace(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Replay applies the events whose commit ts is in (startTs, endTs] to the
+// downstream and returns, it fails if the manifests are not resolved to endTs.
+func (c *Consumer) Replay(ctx context.Context, endTs uint64) error {
+	if endTs <= c.appliedTs {
+		return cerror.ErrReplayInvalidRange.GenWithStackByArgs(c.appliedTs, endTs)
+	}
+	c.endTs = endTs
+	if err := c.consumeOnce(ctx); err != nil {
+		return errors.Trace(err)
+	}
+	if c.appliedTs < endTs {
+		return cerror.ErrReplayIncomplete.GenWithStackByArgs(c.appliedTs, endTs)
+	}
+	return nil
+}
+
+func (c *Consumer) consumeOnce(ctx context.Context) error {
+	resolvedTsList, err := c.listManifests(ctx)
+	if err != nil {
+		return err
+	}
+	if len(resolvedTsList) == 0 {
+		return nil
+	}
+	ddls, err := c.loadDDLEvents(ctx)
+	if err != nil {
+		return err
+	}
+	for _, resolvedTs := range resolvedTsList {
+		if c.endTs != 0 && c.appliedTs >= c.endTs {
+			return nil
+		}
+		data, err := c.readObject(ctx, makeManifestFileObject(resolvedTs))
+		if err != nil {
+			// the manifest may be superseded and removed by the sink after it's
+			// listed, the manifest superseding it is applied instead
+			if superseded, listErr := c.manifestRemoved(ctx, resolvedTs); listErr == nil && superseded {
+				log.Info("[Consumer] skip the superseded manifest", zap.Uint64("resolved ts", resolvedTs))
+				continue
+			}
+			return err
+		}
+		m := new(manifest)
+		if err := m.Unmarshal(data); err != nil {
+			return err
+		}
+		if err := c.applyManifest(ctx, m, ddls); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyManifest applies the data files of a manifest and the DDLs between the previous
+// applied ts and the resolved ts of the manifest, which is capped by the end ts.
+// The files carried by the manifest are read again for their rows after the resolved
+// ts of the previous manifest.
+func (c *Consumer) applyManifest(ctx context.Context, m *manifest, ddls []*model.DDLEvent) error {
+	resolvedTs := m.ResolvedTs
+	if c.endTs != 0 && resolvedTs > c.endTs {
+		resolvedTs = c.endTs
+	}
+	var (
+		rows       []*model.RowChangedEvent
+		duplicated int
+	)
+	seen := make(map[string]struct{})
+	files := append(append([]*dataFile{}, m.Carried...), m.Files...)
+	for _, file := range files {
+		data, err := c.readFile(ctx, file.Path)
+		if err != nil {
+			return err
+		}
+		fileRows, _, err := decodeLogEvents(data)
+		if err != nil {
+			return errors.Annotatef(err, "decode data file %s", file.Path)
+		}
+		for _, row := range fileRows {
+			// a row may be replayed by the sink and appear in the files of several manifests
+			if row.CommitTs <= c.appliedTs || row.CommitTs > resolvedTs {
+				continue
+			}
+			key := rowKey(row)
+			if _, ok := seen[key]; ok {
+				duplicated++
+				continue
+			}
+			seen[key] = struct{}{}
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].CommitTs < rows[j].CommitTs
+	})
+
+	i := 0
+	for _, ddl := range ddls {
+		if ddl.CommitTs <= c.appliedTs || ddl.CommitTs > resolvedTs {
+			continue
+		}
+		// all rows before the DDL must be written to the downstream before the DDL is executed
+		j := i
+		for j < len(rows) && rows[j].CommitTs < ddl.CommitTs {
+			j++
+		}
+		if err := c.emitRows(ctx, rows[i:j], ddl.CommitTs-1); err != nil {
+			return err
+		}
+		i = j
+		log.Info("[Consumer] execute ddl", zap.Uint64("commit ts", ddl.CommitTs), zap.String("query", ddl.Query))
+		if err := c.downstream.EmitDDLEvent(ctx, ddl); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if err := c.emitRows(ctx, rows[i:], resolvedTs); err != nil {
+		return err
+	}
+	c.appliedTs = resolvedTs
+	log.Info("[Consumer] manifest applied",
+		zap.Uint64("resolved ts", resolvedTs),
+		zap.Int("file count", len(files)),
+		zap.Int("row count", len(rows)),
+		zap.Int("duplicated row count", duplicated))
+	return nil
+}
+
+// rowKey identifies a row changed event, the row written again by a restarted
+// sink has the same key. A transaction changes a row at most once, so the
+// different changes never share a key.
+func rowKey(row *model.RowChangedEvent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s", row.CommitTs, quotes.QuoteSchema(row.Table.Schema, row.Table.Table))
+	for _, cols := range [][]*model.Column{row.PreColumns, row.Columns} {
+		b.WriteString(" |")
+		for _, col := range cols {
+			if col != nil {
+				fmt.Fprintf(&b, " %s=%v", col.Name, col.Value)
+			}
+		}
+	}
+	return b.String()
+}
+
+func (c *Consumer) emitRows(ctx context.Context, rows []*model.RowChangedEvent, resolvedTs uint64) error {
+	if len(rows) > 0 {
+		if err := c.downstream.EmitRowChangedEvents(ctx, rows...); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	// wait until all rows are written to the downstream
+	for {
+		flushedTs, err := c.downstream.FlushRowChangedEvents(ctx, resolvedTs)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if flushedTs >= resolvedTs {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// listManifests returns the resolved ts of the manifests which are not applied in ascending order.
+func (c *Consumer) listManifests(ctx context.Context) ([]uint64, error) {
+	names, err := c.listDir(ctx, manifestDir)
+	if err != nil {
+		return nil, err
+	}
+	var resolvedTsList []uint64
+	for name := range names {
+		if !strings.HasPrefix(name, manifestPrefix+".") {
+			continue
+		}
+		reversed, err := strconv.ParseUint(strings.TrimPrefix(name, manifestPrefix+"."), 10, 64)
+		if err != nil {
+			// tmp files of local file sink
+			continue
+		}
+		if resolvedTs := maxUint64 - reversed; resolvedTs > c.appliedTs {
+			resolvedTsList = append(resolvedTsList, resolvedTs)
+		}
+	}
+	sort.Slice(resolvedTsList, func(i, j int) bool {
+		return resolvedTsList[i] < resolvedTsList[j]
+	})
+	return resolvedTsList, nil
+}
+
+// manifestRemoved returns whether the manifest of the resolved ts is removed.
+func (c *Consumer) manifestRemoved(ctx context.Context, resolvedTs uint64) (bool, error) {
+	resolvedTsList, err := c.listManifests(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, ts := range resolvedTsList {
+		if ts == resolvedTs {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// loadDDLEvents loads the DDL events not applied yet from the ddl event log in
+// commit ts order. Only the new ddl files and the ones growing since they are
+// read are read, the sink appends the DDLs to the latest file.
+func (c *Consumer) loadDDLEvents(ctx context.Context) ([]*model.DDLEvent, error) {
+	names, err := c.listDir(ctx, ddlEventsDir)
+	if err != nil {
+		return nil, err
+	}
+	if c.ddlFileSizes == nil {
+		c.ddlFileSizes = make(map[string]int64, len(names))
+	}
+	ddls := append([]*model.DDLEvent{}, c.ddls...)
+	readSizes := make(map[string]int64)
+	for name, size := range names {
+		if !strings.HasPrefix(name, ddlEventsPrefix+".") || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		if readSize, ok := c.ddlFileSizes[name]; ok && readSize == size {
+			continue
+		}
+		data, err := c.readFile(ctx, ddlEventsDir+"/"+name)
+		if err != nil {
+			return nil, err
+		}
+		_, fileDDLs, err := decodeLogEvents(data)
+		if err != nil {
+			return nil, errors.Annotatef(err, "decode ddl file %s", name)
+		}
+		ddls = append(ddls, fileDDLs...)
+		readSizes[name] = size
+	}
+	sort.SliceStable(ddls, func(i, j int) bool {
+		return ddls[i].CommitTs < ddls[j].CommitTs
+	})
+	// a DDL may be emitted more than once after the changefeed is restarted,
+	// and the DDLs in a growing file are loaded again
+	result := make([]*model.DDLEvent, 0, len(ddls))
+	for _, ddl := range ddls {
+		if ddl.CommitTs <= c.appliedTs ||
+			(len(result) > 0 && result[len(result)-1].CommitTs == ddl.CommitTs) {
+			continue
+		}
+		result = append(result, ddl)
+	}
+	c.ddls = result
+	for name, size := range readSizes {
+		c.ddlFileSizes[name] = size
+	}
+	return result, nil
+}
+
+// listDir returns the sizes of the objects in the given directory by their
+// base names.
+func (c *Consumer) listDir(ctx context.Context, dir string) (map[string]int64, error) {
+	names := make(map[string]int64)
+	if c.storage() != nil {
+		err := c.storage().WalkDir(ctx, &storage.WalkOption{SubDir: dir}, func(key string, size int64) error {
+			names[key[strings.LastIndex(key, "/")+1:]] = size
+			return nil
+		})
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrS3SinkStorageAPI, err)
+		}
+		return names, nil
+	}
+	infos, err := ioutil.ReadDir(filepath.Join(c.root(), dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, cerror.WrapError(cerror.ErrFileSinkFileOp, err)
+	}
+	for _, info := range infos {
+		if !info.IsDir() {
+			names[info.Name()] = info.Size()
+		}
+	}
+	return names, nil
+}
+
+func (l *logSink) readObject(ctx context.Context, name string) ([]byte, error) {
+	if l.storage() != nil {
+		data, err := l.storage().Read(ctx, name)
+		return data, cerror.WrapError(cerror.ErrS3SinkStorageAPI, err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(l.root(), filepath.FromSlash(name)))
+	return data, cerror.WrapError(cerror.ErrFileSinkFileOp, err)
+}
+
+// readFile reads a data file or a ddl file, the file is decrypted if it is encrypted.
+func (c *Consumer) readFile(ctx context.Context, name string) ([]byte, error) {
+	data, err := c.readObject(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return c.decrypt(ctx, data)
+}
+
+// decodeLogEvents decodes a file written by the log sink.
+func decodeLogEvents(data []byte) ([]*model.RowChangedEvent, []*model.DDLEvent, error) {
+	if len(data) == 0 {
+		return nil, nil, nil
+	}
+	decoder, err := codec.NewJSONEventBatchDecoder(data, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		rows []*model.RowChangedEvent
+		ddls []*model.DDLEvent
+	)
+	for {
+		tp, hasNext, err := decoder.HasNext()
+		if err != nil {
+			return nil, nil, err
+		}
+		if !hasNext {
+			return rows, ddls, nil
+		}
+		switch tp {
+		case model.MqMessageTypeRow:
+			row, err := decoder.NextRowChangedEvent()
+			if err != nil {
+				return nil, nil, err
+			}
+			rows = append(rows, row)
+		case model.MqMessageTypeDDL:
+			ddl, err := decoder.NextDDLEvent()
+			if err != nil {
+				return nil, nil, err
+			}
+			ddls = append(ddls, ddl)
+		case model.MqMessageTypeResolved:
+			if _, err := decoder.NextResolvedEvent(); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, cerror.ErrJSONCodecInvalidData.GenWithStack("unknown message type %v", tp)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type consumerSuite struct{}
+
+var _ = check.Suite(&consumerSuite{})
+
+// mockDownstream records the events in the order they are applied.
+type mockDownstream struct {
+	events []string
+}
+
+func (m *mockDownstream) EmitRowChangedEvents(ctx context.Context, rows ...*model.RowChangedEvent) error {
+	for _, row := range rows {
+		m.events = append(m.events, fmt.Sprintf("row %d", row.CommitTs))
+	}
+	return nil
+}
+
+func (m *mockDownstream) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error {
+	m.events = append(m.events, fmt.Sprintf("ddl %d", ddl.CommitTs))
+	return nil
+}
+
+func (m *mockDownstream) FlushRowChangedEvents(ctx context.Context, resolvedTs uint64) (uint64, error) {
+	m.events = append(m.events, fmt.Sprintf("flush %d", resolvedTs))
+	return resolvedTs, nil
+}
+
+// writeTestRows writes the rows of the commit ts to a data file of the log sink.
+func writeTestRows(c *check.C, sink *logSink, name string, commitTs ...uint64) {
+	encoder := sink.encoder()
+	for _, ts := range commitTs {
+		_, err := encoder.AppendRowChangedEvent(&model.RowChangedEvent{
+			CommitTs: ts,
+			Table:    &model.TableName{Schema: "test", Table: "t1", TableID: 1},
+			Columns:  []*model.Column{{Name: "id", Type: mysql.TypeLong, Value: int64(ts)}},
+		})
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(sink.writeAtomic(context.Background(), name, encoder.MixedBuild(true)), check.IsNil)
+	sink.commitDataFile(1, name, 0, commitTs[len(commitTs)-1], nil)
+}
+
+// writeConsumerTestData writes the rows of 101-130 committed by the manifests
+// of 110 and 150, and a DDL of 115 to the log sink at root.
+func writeConsumerTestData(c *check.C, root string) {
+	ctx := context.Background()
+	sink := newLogSink(root+"/", nil, newOptions(maxRowFileSize))
+	writeRows := func(name string, commitTs ...uint64) {
+		writeTestRows(c, sink, name, commitTs...)
+	}
+
+	writeRows("t_1/cdclog.102", 101, 102)
+	c.Assert(sink.commitManifest(ctx, 110), check.IsNil)
+	// the row of 102 is replayed in the next file
+	writeRows("t_1/cdclog.130", 102, 120, 130)
+	c.Assert(sink.commitManifest(ctx, 150), check.IsNil)
+	// not committed by any manifest
+	writeRows("t_1/cdclog.160", 160)
+
+	encoder := sink.encoder()
+	_, err := encoder.EncodeDDLEvent(&model.DDLEvent{
+		CommitTs:  115,
+		TableInfo: &model.SimpleTableInfo{Schema: "test", Table: "t1"},
+		Query:     "alter table t1 add column a int",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.writeAtomic(ctx, makeDDLFileObject(115), encoder.MixedBuild(true)), check.IsNil)
+}
+
+func (s *consumerSuite) TestConsumer(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	root := c.MkDir()
+	writeConsumerTestData(c, root)
+
+	sinkURI, err := url.Parse("local://" + root)
+	c.Assert(err, check.IsNil)
+	downstream := &mockDownstream{}
+	consumer, err := NewConsumer(ctx, sinkURI, downstream, 101, time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumer.consumeOnce(ctx), check.IsNil)
+	c.Assert(consumer.AppliedTs(), check.Equals, uint64(150))
+	c.Assert(downstream.events, check.DeepEquals, []string{
+		"row 102", "flush 110",
+		"flush 114", "ddl 115",
+		"row 120", "row 130", "flush 150",
+	})
+
+	// nothing new to apply
+	c.Assert(consumer.consumeOnce(ctx), check.IsNil)
+	c.Assert(downstream.events, check.HasLen, 7)
+}
+
+func (s *consumerSuite) TestLoadDDLEvents(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	root := c.MkDir()
+	writeConsumerTestData(c, root)
+	sinkURI, err := url.Parse("local://" + root)
+	c.Assert(err, check.IsNil)
+	consumer, err := NewConsumer(ctx, sinkURI, &mockDownstream{}, 101, time.Second)
+	c.Assert(err, check.IsNil)
+	ddls, err := consumer.loadDDLEvents(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(ddls, check.HasLen, 1)
+
+	// the file read before is not read again if it doesn't grow
+	path := filepath.Join(root, filepath.FromSlash(makeDDLFileObject(115)))
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(ioutil.WriteFile(path, bytes.Repeat([]byte{'x'}, len(data)), 0o644), check.IsNil)
+	encoder := consumer.encoder()
+	_, err = encoder.EncodeDDLEvent(&model.DDLEvent{
+		CommitTs:  140,
+		TableInfo: &model.SimpleTableInfo{Schema: "test", Table: "t1"},
+		Query:     "alter table t1 add column b int",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(consumer.writeAtomic(ctx, makeDDLFileObject(140), encoder.MixedBuild(true)), check.IsNil)
+	ddls, err = consumer.loadDDLEvents(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(ddls, check.HasLen, 2)
+	c.Assert(ddls[1].CommitTs, check.Equals, uint64(140))
+
+	// the applied DDLs are dropped
+	consumer.appliedTs = 120
+	ddls, err = consumer.loadDDLEvents(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(ddls, check.HasLen, 1)
+	c.Assert(ddls[0].CommitTs, check.Equals, uint64(140))
+}
+
+func (s *consumerSuite) TestConsumerCarriedRows(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	root := c.MkDir()
+	sink := newLogSink(root+"/", nil, newOptions(maxRowFileSize))
+	// the file holds the rows on both sides of the resolved ts 125
+	writeTestRows(c, sink, "t_1/cdclog.130", 120, 130)
+	c.Assert(sink.commitManifest(ctx, 125), check.IsNil)
+	// the row of 130 is written again by a restarted sink
+	writeTestRows(c, sink, "t_1/cdclog.140", 130, 140)
+	c.Assert(sink.commitManifest(ctx, 150), check.IsNil)
+
+	sinkURI, err := url.Parse("local://" + root)
+	c.Assert(err, check.IsNil)
+	downstream := &mockDownstream{}
+	consumer, err := NewConsumer(ctx, sinkURI, downstream, 100, time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumer.consumeOnce(ctx), check.IsNil)
+	c.Assert(consumer.AppliedTs(), check.Equals, uint64(150))
+	c.Assert(downstream.events, check.DeepEquals, []string{
+		"row 120", "flush 125",
+		"row 130", "row 140", "flush 150",
+	})
+
+	// a consumer started between the manifests reads the carried file
+	downstream = &mockDownstream{}
+	consumer, err = NewConsumer(ctx, sinkURI, downstream, 125, time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumer.consumeOnce(ctx), check.IsNil)
+	c.Assert(downstream.events, check.DeepEquals, []string{"row 130", "row 140", "flush 150"})
+}
+
+func (s *consumerSuite) TestReplay(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	root := c.MkDir()
+	writeConsumerTestData(c, root)
+	sinkURI, err := url.Parse("local://" + root)
+	c.Assert(err, check.IsNil)
+
+	// the window ends between the manifests
+	downstream := &mockDownstream{}
+	consumer, err := NewConsumer(ctx, sinkURI, downstream, 101, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumer.Replay(ctx, 120), check.IsNil)
+	c.Assert(consumer.AppliedTs(), check.Equals, uint64(120))
+	c.Assert(downstream.events, check.DeepEquals, []string{
+		"row 102", "flush 110",
+		"flush 114", "ddl 115",
+		"row 120", "flush 120",
+	})
+
+	// the window starts after the DDL
+	downstream = &mockDownstream{}
+	consumer, err = NewConsumer(ctx, sinkURI, downstream, 115, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumer.Replay(ctx, 150), check.IsNil)
+	c.Assert(downstream.events, check.DeepEquals, []string{"row 120", "row 130", "flush 150"})
+
+	// the row of 160 is not committed by any manifest
+	consumer, err = NewConsumer(ctx, sinkURI, &mockDownstream{}, 101, 0)
+	c.Assert(err, check.IsNil)
+	err = consumer.Replay(ctx, 160)
+	c.Assert(err, check.ErrorMatches, ".*only replayed to 150.*")
+
+	consumer, err = NewConsumer(ctx, sinkURI, &mockDownstream{}, 150, 0)
+	c.Assert(err, check.IsNil)
+	err = consumer.Replay(ctx, 150)
+	c.Assert(err, check.ErrorMatches, ".*must be larger than the start ts.*")
+}
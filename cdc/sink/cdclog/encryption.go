@@ -0,0 +1,435 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/pingcap/log"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Envelope encryption of the data files and the ddl files.
+//
+// The files are encrypted by AES-256-GCM with data keys, and the data keys are
+// encrypted by a master key managed by AWS KMS or provided in the sink uri:
+//
+//	s3://bucket/prefix?encryption=kms&kms-key-id=arn:aws:kms:...&kms-region=us-west-2
+//	s3://bucket/prefix?encryption=local&encryption-key=${secret:env:CDC_MASTER_KEY}
+//
+// The encrypted data keys are written to `keys/{key id}` before they are used,
+// and the manifests record the key ids of each data file. A data key is rotated
+// every `data-key-rotation` (1h by default).
+//
+// An encrypted file is a sequence of segments, each flush appends a segment:
+//
+//	magic (4 bytes) | key id length (1 byte) | key id | nonce (12 bytes) | sealed length (4 bytes) | sealed data
+//
+// The schema files, the log meta and the manifests are not encrypted, they
+// contain no row data.
+const (
+	encryptionNone  = "none"
+	encryptionKMS   = "kms"
+	encryptionLocal = "local"
+
+	keysDir = "keys"
+
+	segmentMagic = "CDE1"
+
+	defaultDataKeyRotation = time.Hour
+	dataKeySize            = 32
+)
+
+// masterKey encrypts and decrypts the data keys.
+type masterKey interface {
+	// ID identifies the master key, it is recorded with the encrypted data keys.
+	ID() string
+	// generateDataKey returns a new data key and the data key encrypted by the master key.
+	generateDataKey(ctx context.Context) (plaintext, ciphertext []byte, err error)
+	// decryptDataKey decrypts a data key encrypted by the master key.
+	decryptDataKey(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+type kmsMasterKey struct {
+	client *kms.KMS
+	keyID  string
+}
+
+func newKMSMasterKey(keyID, region string) (*kmsMasterKey, error) {
+	// the credential is loaded in the same way as the AWS CLI
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrLogSinkEncryption, err)
+	}
+	cfg := aws.NewConfig()
+	if region != "" {
+		cfg = cfg.WithRegion(region)
+	}
+	return &kmsMasterKey{client: kms.New(sess, cfg), keyID: keyID}, nil
+}
+
+func (k *kmsMasterKey) ID() string {
+	return k.keyID
+}
+
+func (k *kmsMasterKey) generateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	output, err := k.client.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(k.keyID),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+	if err != nil {
+		return nil, nil, cerror.WrapError(cerror.ErrLogSinkEncryption, err)
+	}
+	return output.Plaintext, output.CiphertextBlob, nil
+}
+
+func (k *kmsMasterKey) decryptDataKey(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	output, err := k.client.DecryptWithContext(ctx, &kms.DecryptInput{CiphertextBlob: ciphertext})
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrLogSinkEncryption, err)
+	}
+	return output.Plaintext, nil
+}
+
+// localMasterKey is a master key provided by the user, the data keys are
+// encrypted by AES-256-GCM with it.
+type localMasterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func newLocalMasterKey(key []byte) (*localMasterKey, error) {
+	if len(key) != dataKeySize {
+		return nil, cerror.ErrLogSinkEncryption.GenWithStack("the master key must be %d bytes", dataKeySize)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &localMasterKey{id: "local-" + hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+func (k *localMasterKey) ID() string {
+	return k.id
+}
+
+func (k *localMasterKey) generateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	plaintext := make([]byte, dataKeySize)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, cerror.WrapError(cerror.ErrLogSinkEncryption, err)
+	}
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, cerror.WrapError(cerror.ErrLogSinkEncryption, err)
+	}
+	return plaintext, k.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (k *localMasterKey) decryptDataKey(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	nonceSize := k.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, cerror.ErrLogSinkEncryption.GenWithStack("invalid encrypted data key")
+	}
+	plaintext, err := k.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+	if err != nil {
+		return nil, cerror.ErrLogSinkEncryption.GenWithStack("decrypt data key failed, the master key may be mismatched")
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrLogSinkEncryption, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, cerror.WrapError(cerror.ErrLogSinkEncryption, err)
+}
+
+// encryptedDataKey is the content of a key file.
+type encryptedDataKey struct {
+	ID          string    `json:"id"`
+	MasterKeyID string    `json:"master-key-id"`
+	Ciphertext  []byte    `json:"ciphertext"`
+	CreateTime  time.Time `json:"create-time"`
+}
+
+func makeKeyFileObject(keyID string) string {
+	return keysDir + "/" + keyID
+}
+
+type dataKey struct {
+	id      string
+	aead    cipher.AEAD
+	created time.Time
+}
+
+// encryptor encrypts the files written by the log sink and decrypts them for the consumer.
+type encryptor struct {
+	master   masterKey
+	rotation time.Duration
+
+	mu      sync.Mutex
+	current *dataKey
+	// keys caches the decrypted data keys by id
+	keys map[string]cipher.AEAD
+}
+
+// parseEncryption creates an encryptor by the sink uri, nil is returned if the
+// encryption is disabled.
+func parseEncryption(sinkURI *url.URL) (*encryptor, error) {
+	query := sinkURI.Query()
+	var (
+		master masterKey
+		err    error
+	)
+	switch s := query.Get("encryption"); s {
+	case "", encryptionNone:
+		return nil, nil
+	case encryptionKMS:
+		keyID := query.Get("kms-key-id")
+		if keyID == "" {
+			return nil, cerror.ErrSinkURIInvalid.GenWithStack("kms-key-id is required by kms encryption")
+		}
+		master, err = newKMSMasterKey(keyID, query.Get("kms-region"))
+	case encryptionLocal:
+		var key []byte
+		key, err = base64.StdEncoding.DecodeString(query.Get("encryption-key"))
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrSinkURIInvalid, err)
+		}
+		master, err = newLocalMasterKey(key)
+	default:
+		return nil, cerror.ErrSinkURIInvalid.GenWithStack("unknown log sink encryption: %s", s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rotation := defaultDataKeyRotation
+	if s := query.Get("data-key-rotation"); s != "" {
+		rotation, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrSinkURIInvalid, err)
+		}
+		if rotation <= 0 {
+			return nil, cerror.ErrSinkURIInvalid.GenWithStack("data-key-rotation must be positive: %s", s)
+		}
+	}
+	return newEncryptor(master, rotation), nil
+}
+
+func newEncryptor(master masterKey, rotation time.Duration) *encryptor {
+	return &encryptor{
+		master:   master,
+		rotation: rotation,
+		keys:     make(map[string]cipher.AEAD),
+	}
+}
+
+// currentKey returns the data key in use, a new data key is generated and
+// persisted by writeKey if the current one expires.
+func (e *encryptor) currentKey(ctx context.Context, writeKey func(name string, data []byte) error) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.current != nil && time.Since(e.current.created) < e.rotation {
+		return e.current, nil
+	}
+	plaintext, ciphertext, err := e.master.generateDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, cerror.WrapError(cerror.ErrLogSinkEncryption, err)
+	}
+	key := &dataKey{id: hex.EncodeToString(idBytes), aead: aead, created: time.Now()}
+	data, err := json.Marshal(&encryptedDataKey{
+		ID:          key.id,
+		MasterKeyID: e.master.ID(),
+		Ciphertext:  ciphertext,
+		CreateTime:  key.created,
+	})
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrMarshalFailed, err)
+	}
+	// the key must be persisted before any file is encrypted by it
+	if err := writeKey(makeKeyFileObject(key.id), data); err != nil {
+		return nil, err
+	}
+	log.Info("[encryptor] new data key generated",
+		zap.String("key id", key.id),
+		zap.String("master key id", e.master.ID()))
+	e.current = key
+	e.keys[key.id] = aead
+	return key, nil
+}
+
+// encrypt seals data into a segment, the id of the data key is returned.
+func (e *encryptor) encrypt(
+	ctx context.Context, data []byte, writeKey func(name string, data []byte) error,
+) ([]byte, string, error) {
+	key, err := e.currentKey(ctx, writeKey)
+	if err != nil {
+		return nil, "", err
+	}
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", cerror.WrapError(cerror.ErrLogSinkEncryption, err)
+	}
+	// the key id is authenticated, so a segment can't be decrypted by another key
+	sealed := key.aead.Seal(nil, nonce, data, []byte(key.id))
+	buf := bytes.NewBuffer(make([]byte, 0, len(segmentMagic)+1+len(key.id)+len(nonce)+4+len(sealed)))
+	buf.WriteString(segmentMagic)
+	buf.WriteByte(byte(len(key.id)))
+	buf.WriteString(key.id)
+	buf.Write(nonce)
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(sealed)))
+	buf.Write(length[:])
+	buf.Write(sealed)
+	return buf.Bytes(), key.id, nil
+}
+
+// decrypt opens all segments of an encrypted file, the data keys are loaded by
+// readKey. The data is returned as is if it is not encrypted.
+func (e *encryptor) decrypt(
+	ctx context.Context, data []byte, readKey func(name string) ([]byte, error),
+) ([]byte, error) {
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	var plaintext []byte
+	for len(data) > 0 {
+		if !isEncrypted(data) {
+			return nil, cerror.ErrLogSinkEncryption.GenWithStack("invalid segment magic")
+		}
+		data = data[len(segmentMagic):]
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return nil, cerror.ErrLogSinkEncryption.GenWithStack("truncated segment")
+		}
+		keyID := string(data[1 : 1+int(data[0])])
+		data = data[1+int(data[0]):]
+		aead, err := e.loadKey(ctx, keyID, readKey)
+		if err != nil {
+			return nil, err
+		}
+		nonceSize := aead.NonceSize()
+		if len(data) < nonceSize+4 {
+			return nil, cerror.ErrLogSinkEncryption.GenWithStack("truncated segment")
+		}
+		nonce := data[:nonceSize]
+		length := int(binary.BigEndian.Uint32(data[nonceSize : nonceSize+4]))
+		data = data[nonceSize+4:]
+		if len(data) < length {
+			return nil, cerror.ErrLogSinkEncryption.GenWithStack("truncated segment")
+		}
+		plaintext, err = aead.Open(plaintext, nonce, data[:length], []byte(keyID))
+		if err != nil {
+			return nil, cerror.ErrLogSinkEncryption.GenWithStack("decrypt segment failed, the data may be modified")
+		}
+		data = data[length:]
+	}
+	return plaintext, nil
+}
+
+func (e *encryptor) loadKey(
+	ctx context.Context, keyID string, readKey func(name string) ([]byte, error),
+) (cipher.AEAD, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if aead, ok := e.keys[keyID]; ok {
+		return aead, nil
+	}
+	data, err := readKey(makeKeyFileObject(keyID))
+	if err != nil {
+		return nil, err
+	}
+	key := new(encryptedDataKey)
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, cerror.WrapError(cerror.ErrUnmarshalFailed, err)
+	}
+	if key.MasterKeyID != e.master.ID() {
+		log.Warn("[encryptor] the data key is encrypted by another master key",
+			zap.String("key id", keyID),
+			zap.String("master key id", key.MasterKeyID),
+			zap.String("expected master key id", e.master.ID()))
+	}
+	plaintext, err := e.master.decryptDataKey(ctx, key.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	e.keys[keyID] = aead
+	return aead, nil
+}
+
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(segmentMagic))
+}
+
+// encrypt encrypts data if the encryption is enabled, the id of the data key is
+// returned, or an empty string if the data is not encrypted.
+func (l *logSink) encrypt(ctx context.Context, data []byte) ([]byte, string, error) {
+	if l.encryptor == nil || len(data) == 0 {
+		return data, "", nil
+	}
+	return l.encryptor.encrypt(ctx, data, func(name string, data []byte) error {
+		return l.writeAtomic(ctx, name, data)
+	})
+}
+
+// decrypt decrypts data if it is encrypted.
+func (l *logSink) decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	if l.encryptor == nil {
+		return nil, cerror.ErrLogSinkEncryption.GenWithStack("the file is encrypted, please specify the encryption in the sink uri")
+	}
+	return l.encryptor.decrypt(ctx, data, l.readObject)
+}
+
+// appendKeyID appends the key id if it isn't the last one.
+func appendKeyID(keyIDs []string, keyID string) []string {
+	if keyID == "" || (len(keyIDs) > 0 && keyIDs[len(keyIDs)-1] == keyID) {
+		return keyIDs
+	}
+	return append(keyIDs, keyID)
+}
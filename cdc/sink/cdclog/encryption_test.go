@@ -0,0 +1,157 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/url"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type encryptionSuite struct{}
+
+var _ = check.Suite(&encryptionSuite{})
+
+func (s *encryptionSuite) TestEncryptor(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	master, err := newLocalMasterKey(bytes.Repeat([]byte{1}, dataKeySize))
+	c.Assert(err, check.IsNil)
+	keyFiles := make(map[string][]byte)
+	writeKey := func(name string, data []byte) error {
+		keyFiles[name] = data
+		return nil
+	}
+	readKey := func(name string) ([]byte, error) {
+		return keyFiles[name], nil
+	}
+
+	enc := newEncryptor(master, time.Hour)
+	seg1, keyID1, err := enc.encrypt(ctx, []byte("hello "), writeKey)
+	c.Assert(err, check.IsNil)
+	seg2, keyID2, err := enc.encrypt(ctx, []byte("world"), writeKey)
+	c.Assert(err, check.IsNil)
+	c.Assert(keyID1, check.Equals, keyID2)
+	c.Assert(keyFiles, check.HasLen, 1)
+	c.Assert(bytes.Contains(seg1, []byte("hello")), check.IsFalse)
+
+	// the key is rotated
+	enc.rotation = 0
+	seg3, keyID3, err := enc.encrypt(ctx, []byte("!"), writeKey)
+	c.Assert(err, check.IsNil)
+	c.Assert(keyID3, check.Not(check.Equals), keyID1)
+	c.Assert(keyFiles, check.HasLen, 2)
+
+	// the data keys are loaded from the key files
+	file := append(append(append([]byte{}, seg1...), seg2...), seg3...)
+	data, err := newEncryptor(master, time.Hour).decrypt(ctx, file, readKey)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "hello world!")
+
+	// the plaintext is returned as is
+	data, err = enc.decrypt(ctx, []byte("plaintext"), readKey)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "plaintext")
+
+	modified := append([]byte{}, file...)
+	modified[len(seg1)-1] ^= 0xff
+	_, err = enc.decrypt(ctx, modified, readKey)
+	c.Assert(err, check.ErrorMatches, ".*decrypt segment failed.*")
+	_, err = enc.decrypt(ctx, file[:len(file)-1], readKey)
+	c.Assert(err, check.ErrorMatches, ".*truncated segment.*")
+
+	otherMaster, err := newLocalMasterKey(bytes.Repeat([]byte{2}, dataKeySize))
+	c.Assert(err, check.IsNil)
+	_, err = newEncryptor(otherMaster, time.Hour).decrypt(ctx, file, readKey)
+	c.Assert(err, check.ErrorMatches, ".*the master key may be mismatched.*")
+}
+
+func (s *encryptionSuite) TestParseEncryption(c *check.C) {
+	defer testleak.AfterTest(c)()
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, dataKeySize))
+	testCases := []struct {
+		uri     string
+		enabled bool
+		err     string
+	}{
+		{"s3://bucket/prefix", false, ""},
+		{"s3://bucket/prefix?encryption=none", false, ""},
+		{"s3://bucket/prefix?encryption=local&data-key-rotation=10m&encryption-key=" + url.QueryEscape(key), true, ""},
+		{"s3://bucket/prefix?encryption=local&encryption-key=YWJj", false, ".*the master key must be 32 bytes.*"},
+		{"s3://bucket/prefix?encryption=kms", false, ".*kms-key-id is required.*"},
+		{"s3://bucket/prefix?encryption=local&data-key-rotation=0s&encryption-key=" + url.QueryEscape(key), false, ".*data-key-rotation must be positive.*"},
+		{"s3://bucket/prefix?encryption=rot13", false, ".*unknown log sink encryption: rot13.*"},
+	}
+	for _, tc := range testCases {
+		sinkURI, err := url.Parse(tc.uri)
+		c.Assert(err, check.IsNil)
+		enc, err := parseEncryption(sinkURI)
+		if tc.err != "" {
+			c.Assert(err, check.ErrorMatches, tc.err, check.Commentf("uri: %s", tc.uri))
+			continue
+		}
+		c.Assert(err, check.IsNil, check.Commentf("uri: %s", tc.uri))
+		c.Assert(enc != nil, check.Equals, tc.enabled, check.Commentf("uri: %s", tc.uri))
+	}
+}
+
+func (s *encryptionSuite) TestConsumeEncryptedFiles(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	root := c.MkDir()
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, dataKeySize))
+	sinkURI, err := url.Parse("local://" + root + "?encryption=local&encryption-key=" + url.QueryEscape(key))
+	c.Assert(err, check.IsNil)
+	enc, err := parseEncryption(sinkURI)
+	c.Assert(err, check.IsNil)
+	sink := newLogSink(root+"/", nil, newOptions(maxRowFileSize))
+	sink.encryptor = enc
+
+	encoder := sink.encoder()
+	_, err = encoder.AppendRowChangedEvent(&model.RowChangedEvent{
+		CommitTs: 101,
+		Table:    &model.TableName{Schema: "test", Table: "t1", TableID: 1},
+		Columns:  []*model.Column{{Name: "id", Type: mysql.TypeLong, Value: int64(101)}},
+	})
+	c.Assert(err, check.IsNil)
+	data, keyID, err := sink.encrypt(ctx, encoder.MixedBuild(true))
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.writeAtomic(ctx, "t_1/cdclog.101", data), check.IsNil)
+	sink.commitDataFile(1, "t_1/cdclog.101", int64(len(data)), 101, appendKeyID(nil, keyID))
+	c.Assert(sink.commitManifest(ctx, 110), check.IsNil)
+
+	m, err := sink.loadLatestManifest(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Files[0].KeyIDs, check.DeepEquals, []string{keyID})
+
+	downstream := &mockDownstream{}
+	consumer, err := NewConsumer(ctx, sinkURI, downstream, 100, time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumer.consumeOnce(ctx), check.IsNil)
+	c.Assert(downstream.events, check.DeepEquals, []string{"row 101", "flush 110"})
+
+	// the encryption must be specified to consume the encrypted files
+	plainURI, err := url.Parse("local://" + root)
+	c.Assert(err, check.IsNil)
+	consumer, err = NewConsumer(ctx, plainURI, &mockDownstream{}, 100, time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(consumer.consumeOnce(ctx), check.ErrorMatches, ".*the file is encrypted.*")
+}
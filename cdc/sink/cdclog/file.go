@@ -36,7 +36,7 @@ const (
 
 	defaultFileName = "cdclog"
 
-	maxRowFileSize = 10 << 20 // TODO update
+	maxRowFileSize = 10 << 20 // rotate row changed event file if it is larger than 10Mb by default
 )
 
 type logPath struct {
@@ -48,6 +48,15 @@ type logPath struct {
 type tableStream struct {
 	dataCh  chan *model.RowChangedEvent
 	rowFile *os.File
+	// rowFileDir is the directory rowFile is created in. It's named by the old
+	// table name if the table is renamed before rowFile is rotated, while the
+	// rows after the rename carry the new table name.
+	rowFileDir string
+	// lastRow is the last row written to rowFile, used to name the rotated file,
+	// it is nil if rowFile has no data to be sealed
+	lastRow *model.RowChangedEvent
+	// keyIDs are the ids of the data keys encrypting rowFile
+	keyIDs []string
 
 	encoder codec.EventBatchEncoder
 
@@ -83,18 +92,17 @@ func (ts *tableStream) Size() *atomic.Int64 {
 }
 
 func (ts *tableStream) isEmpty() bool {
-	return ts.sendEvents.Load() == 0
+	return ts.sendEvents.Load() == 0 && ts.lastRow == nil
 }
 
 func (ts *tableStream) shouldFlush() bool {
 	return ts.sendSize.Load() > maxPartFlushSize
 }
 
-func (ts *tableStream) flush(ctx context.Context, sink *logSink) error {
-	var fileName string
+func (ts *tableStream) flush(ctx context.Context, sink *logSink, seal bool) error {
 	flushedEvents := ts.sendEvents.Load()
 	flushedSize := ts.sendSize.Load()
-	if flushedEvents == 0 {
+	if flushedEvents == 0 && !(seal && ts.rowFile != nil && ts.lastRow != nil) {
 		log.Info("[flushTableStreams] no events to flush")
 		return nil
 	}
@@ -107,8 +115,8 @@ func (ts *tableStream) flush(ctx context.Context, sink *logSink) error {
 	for event := int64(0); event < flushedEvents; event++ {
 		row := <-ts.dataCh
 		if event == flushedEvents-1 {
-			// the last event
-			fileName = makeTableFileName(row.CommitTs)
+			// the last event, we record it to generate the new rotate file name
+			ts.lastRow = row
 		}
 		_, err := ts.encoder.AppendRowChangedEvent(row)
 		if err != nil {
@@ -121,31 +129,40 @@ func (ts *tableStream) flush(ctx context.Context, sink *logSink) error {
 			ts.encoder.Reset()
 		}
 	}()
+	lastRow := ts.lastRow
 
 	log.Debug("[flushTableStreams] build cdc log data",
 		zap.Int64("table id", ts.tableID),
 		zap.Int64("flushed size", flushedSize),
 		zap.Int64("flushed event", flushedEvents),
 		zap.Int("encode size", len(rowDatas)),
-		zap.String("file name", fileName),
+		zap.Uint64("last commit ts", lastRow.CommitTs),
 	)
 
-	tableDir := filepath.Join(sink.root(), makeTableDirectoryName(ts.tableID))
+	tableDir := filepath.Join(sink.root(), filepath.FromSlash(
+		sink.options.tableDir(ts.tableID, lastRow.Table.Schema, lastRow.Table.Table)))
 
 	if ts.rowFile == nil {
-		// create new file to append data
+		// create new file to append data, the content left by a crashed run is
+		// not committed by any manifest, so it is truncated.
 		err := os.MkdirAll(tableDir, defaultDirMode)
 		if err != nil {
 			return err
 		}
-		file, err := os.OpenFile(filepath.Join(tableDir, defaultFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, defaultFileMode)
+		file, err := os.OpenFile(filepath.Join(tableDir, defaultFileName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, defaultFileMode)
 		if err != nil {
 			return err
 		}
 		ts.rowFile = file
+		ts.rowFileDir = tableDir
 	}
 
-	_, err := ts.rowFile.Write(rowDatas)
+	rowDatas, keyID, err := sink.encrypt(ctx, rowDatas)
+	if err != nil {
+		return err
+	}
+	ts.keyIDs = appendKeyID(ts.keyIDs, keyID)
+	_, err = ts.rowFile.Write(rowDatas)
 	if err != nil {
 		return err
 	}
@@ -155,23 +172,41 @@ func (ts *tableStream) flush(ctx context.Context, sink *logSink) error {
 		return err
 	}
 
-	if stat.Size() > maxRowFileSize {
+	if stat.Size() > sink.options.fileSize || (seal && stat.Size() > 0) {
 		// rotate file
-		err := ts.rowFile.Close()
+		err := ts.rowFile.Sync()
+		if err != nil {
+			return err
+		}
+		err = ts.rowFile.Close()
+		if err != nil {
+			return err
+		}
+		oldPath := filepath.Join(ts.rowFileDir, defaultFileName)
+		fileObject := sink.options.tableFileObject(
+			ts.tableID, lastRow.Table.Schema, lastRow.Table.Table, lastRow.CommitTs)
+		newPath := filepath.Join(sink.root(), filepath.FromSlash(fileObject))
+		err = os.MkdirAll(filepath.Dir(newPath), defaultDirMode)
 		if err != nil {
 			return err
 		}
-		oldPath := filepath.Join(tableDir, defaultFileName)
-		newPath := filepath.Join(tableDir, fileName)
 		err = os.Rename(oldPath, newPath)
 		if err != nil {
 			return err
 		}
+		sink.commitDataFile(ts.tableID, fileObject, stat.Size(), lastRow.CommitTs, ts.keyIDs)
+		err = os.MkdirAll(tableDir, defaultDirMode)
+		if err != nil {
+			return err
+		}
 		file, err := os.OpenFile(filepath.Join(tableDir, defaultFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, defaultFileMode)
 		if err != nil {
 			return err
 		}
 		ts.rowFile = file
+		ts.rowFileDir = tableDir
+		ts.lastRow = nil
+		ts.keyIDs = nil
 		ts.encoder = nil
 	}
 
@@ -246,6 +281,9 @@ func (f *fileSink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error
 			return err
 		}
 	}
+	if err := f.emitDDLSchemaFiles(ctx, ddl); err != nil {
+		return err
+	}
 	firstCreated := false
 	if f.ddlEncoder == nil {
 		// create ddl encoder once for each ddl log file
@@ -263,6 +301,10 @@ func (f *fileSink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error
 			f.ddlEncoder.Reset()
 		}
 	}()
+	data, _, err = f.encrypt(ctx, data)
+	if err != nil {
+		return err
+	}
 
 	if f.ddlFile == nil {
 		// create file stream
@@ -310,13 +352,16 @@ func (f *fileSink) Initialize(ctx context.Context, tableInfo []*model.SimpleTabl
 	if tableInfo != nil {
 		for _, table := range tableInfo {
 			if table != nil {
-				name := makeTableDirectoryName(table.TableID)
-				err := os.MkdirAll(filepath.Join(f.logPath.root, name), defaultDirMode)
+				name := f.options.tableDir(table.TableID, table.Schema, table.Table)
+				err := os.MkdirAll(filepath.Join(f.logPath.root, filepath.FromSlash(name)), defaultDirMode)
 				if err != nil {
 					return cerror.WrapError(cerror.ErrFileSinkCreateDir, err)
 				}
 			}
 		}
+		if err := f.initTableSchemas(ctx, tableInfo); err != nil {
+			return err
+		}
 		// update log meta to record the relationship about tableName and tableID
 		f.logMeta = makeLogMetaContent(tableInfo)
 		data, err := f.logMeta.Marshal()
@@ -349,13 +394,17 @@ func NewLocalFileSink(ctx context.Context, sinkURI *url.URL, errCh chan error) (
 		zap.String("host", sinkURI.Host),
 		zap.String("path", sinkURI.Path),
 	)
+	opts, err := parseOptions(sinkURI, maxRowFileSize)
+	if err != nil {
+		return nil, err
+	}
 	rootPath := sinkURI.Path + "/"
 	logPath := &logPath{
 		root: rootPath,
 		meta: rootPath + logMetaFile,
 		ddl:  rootPath + ddlEventsDir,
 	}
-	err := os.MkdirAll(logPath.ddl, defaultDirMode)
+	err = os.MkdirAll(logPath.ddl, defaultDirMode)
 	if err != nil {
 		log.Error("create ddl path failed",
 			zap.String("ddl path", logPath.ddl),
@@ -363,10 +412,20 @@ func NewLocalFileSink(ctx context.Context, sinkURI *url.URL, errCh chan error) (
 		return nil, cerror.WrapError(cerror.ErrFileSinkCreateDir, err)
 	}
 
+	enc, err := parseEncryption(sinkURI)
+	if err != nil {
+		return nil, err
+	}
+
 	f := &fileSink{
 		logMeta: newLogMeta(),
 		logPath: logPath,
-		logSink: newLogSink(logPath.root, nil),
+		logSink: newLogSink(logPath.root, nil, opts),
+	}
+	f.encryptor = enc
+
+	if err := f.recoverFromManifest(ctx); err != nil {
+		return nil, err
 	}
 
 	// important! we should flush asynchronously in another goroutine
@@ -0,0 +1,63 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type fileSuite struct{}
+
+var _ = check.Suite(&fileSuite{})
+
+func (s *fileSuite) TestRotateRenamedTable(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	root := c.MkDir()
+	opts := newOptions(maxRowFileSize)
+	opts.layout = layoutSchemaTable
+	sink := newLogSink(root, nil, opts)
+	ts := newTableStream(1).(*tableStream)
+	send := func(table string, commitTs uint64) {
+		ts.dataCh <- &model.RowChangedEvent{
+			CommitTs: commitTs,
+			Table:    &model.TableName{Schema: "test", Table: table, TableID: 1},
+			Columns:  []*model.Column{{Name: "id", Type: mysql.TypeLong, Value: int64(commitTs)}},
+		}
+		ts.sendEvents.Inc()
+	}
+
+	send("t1", 100)
+	c.Assert(ts.flush(ctx, sink, false), check.IsNil)
+	_, err := os.Stat(filepath.Join(root, "test", "t1", defaultFileName))
+	c.Assert(err, check.IsNil)
+
+	// the file created before the table is renamed to t2 is rotated
+	send("t2", 200)
+	c.Assert(ts.flush(ctx, sink, true), check.IsNil)
+	_, err = os.Stat(filepath.Join(root, "test", "t2", makeTableFileName(200)))
+	c.Assert(err, check.IsNil)
+	_, err = os.Stat(filepath.Join(root, "test", "t1", defaultFileName))
+	c.Assert(os.IsNotExist(err), check.IsTrue)
+	_, err = os.Stat(filepath.Join(root, "test", "t2", defaultFileName))
+	c.Assert(err, check.IsNil)
+	c.Assert(ts.rowFile.Close(), check.IsNil)
+}
@@ -0,0 +1,316 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/log"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	manifestDir    = "manifests"
+	manifestPrefix = "manifest"
+
+	// manifestIdleInterval is how long a manifest is not written if there is
+	// no new file, the consumers see the resolved ts advance only after it.
+	manifestIdleInterval = time.Minute
+)
+
+// dataFile is a complete row changed event file committed by a manifest.
+type dataFile struct {
+	TableID int64  `json:"table-id"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	// MaxCommitTs is the max commit ts of the rows in the file, the rows of
+	// a table are written in commit ts order, so it's the commit ts of the
+	// last row.
+	MaxCommitTs uint64 `json:"max-commit-ts,omitempty"`
+	// KeyIDs are the ids of the data keys encrypting the file, see encryption.go
+	KeyIDs []string `json:"key-ids,omitempty"`
+}
+
+// manifest is written atomically after every flush which advances the resolved
+// ts, it lists the data files committed since the previous manifest. All row
+// changed events whose commit ts is less than or equal to ResolvedTs are
+// contained in the files listed by this manifest and the manifests before it.
+// The files may contain the rows after ResolvedTs as well, such files are
+// listed again in Carried by the next manifest, so the rows in the window
+// (PrevResolvedTs, ResolvedTs] are all in Files and Carried.
+// Consumers should only read the files listed in manifests, a file which is not
+// listed is either being written or left by a crashed run. A row may appear in
+// several files after the sink is restarted, consumers should deduplicate it.
+type manifest struct {
+	ResolvedTs     uint64      `json:"resolved-ts"`
+	PrevResolvedTs uint64      `json:"prev-resolved-ts"`
+	Files          []*dataFile `json:"files"`
+	// Carried are the files committed by the previous manifests which contain
+	// the rows after PrevResolvedTs.
+	Carried []*dataFile `json:"carried,omitempty"`
+}
+
+// Marshal saves manifest
+func (m *manifest) Marshal() ([]byte, error) {
+	data, err := json.Marshal(m)
+	return data, cerror.WrapError(cerror.ErrMarshalFailed, err)
+}
+
+// Unmarshal loads manifest
+func (m *manifest) Unmarshal(data []byte) error {
+	return cerror.WrapError(cerror.ErrUnmarshalFailed, json.Unmarshal(data, m))
+}
+
+// makeManifestFileName makes the latest manifest the first one in lexicographical order,
+// so we can find it by listing only one object.
+func makeManifestFileName(resolvedTs uint64) string {
+	return fmt.Sprintf("%s.%d", manifestPrefix, maxUint64-resolvedTs)
+}
+
+func makeManifestFileObject(resolvedTs uint64) string {
+	return fmt.Sprintf("%s/%s", manifestDir, makeManifestFileName(resolvedTs))
+}
+
+// commitDataFile records a complete data file, which will be listed by the next manifest.
+func (l *logSink) commitDataFile(tableID int64, path string, size int64, maxCommitTs uint64, keyIDs []string) {
+	l.filesMu.Lock()
+	defer l.filesMu.Unlock()
+	l.pendingFiles = append(l.pendingFiles, &dataFile{
+		TableID:     tableID,
+		Path:        path,
+		Size:        size,
+		MaxCommitTs: maxCommitTs,
+		KeyIDs:      keyIDs,
+	})
+}
+
+// carryFiles returns the files containing the rows after the resolved ts.
+func carryFiles(files []*dataFile, resolvedTs uint64) []*dataFile {
+	var carried []*dataFile
+	for _, file := range files {
+		if file.MaxCommitTs > resolvedTs {
+			carried = append(carried, file)
+		}
+	}
+	return carried
+}
+
+// commitManifest writes a manifest for all data files completed since the last
+// manifest. If there is neither a new file nor a carried one, nothing but the
+// resolved ts changes, the manifest is only written once in manifestIdleInterval,
+// so the consumers still see the resolved ts of the idle tables advance.
+//
+// A manifest without new files is superseded by the next manifest, which starts
+// from the same previous resolved ts and carries the same files, so it's
+// removed after the next one is written.
+func (l *logSink) commitManifest(ctx context.Context, resolvedTs uint64) error {
+	l.filesMu.Lock()
+	files := l.pendingFiles
+	l.pendingFiles = nil
+	l.filesMu.Unlock()
+	if resolvedTs <= l.manifestTs {
+		// put the files back, they will be committed by the next manifest
+		l.filesMu.Lock()
+		l.pendingFiles = append(files, l.pendingFiles...)
+		l.filesMu.Unlock()
+		return nil
+	}
+	if len(files) == 0 && len(l.carriedFiles) == 0 &&
+		time.Since(l.manifestWriteTime) < manifestIdleInterval {
+		return nil
+	}
+	m := &manifest{
+		ResolvedTs:     resolvedTs,
+		PrevResolvedTs: l.manifestTs,
+		Files:          files,
+		Carried:        l.carriedFiles,
+	}
+	superseded := l.lastManifest
+	if superseded != nil && len(superseded.Files) == 0 {
+		m.PrevResolvedTs = superseded.PrevResolvedTs
+		m.Carried = superseded.Carried
+	} else {
+		superseded = nil
+	}
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := l.writeAtomic(ctx, makeManifestFileObject(resolvedTs), data); err != nil {
+		return err
+	}
+	log.Info("[commitManifest] manifest committed",
+		zap.Uint64("resolved ts", resolvedTs),
+		zap.Uint64("prev resolved ts", m.PrevResolvedTs),
+		zap.Int("file count", len(files)),
+		zap.Int("carried file count", len(m.Carried)))
+	l.manifestTs = resolvedTs
+	l.carriedFiles = carryFiles(append(l.carriedFiles, files...), resolvedTs)
+	l.lastManifest = m
+	l.manifestWriteTime = time.Now()
+	if superseded != nil {
+		// a superseded manifest left by a failed removal is harmless, the
+		// consumers skip the rows applied already
+		if err := l.removeObject(ctx, makeManifestFileObject(superseded.ResolvedTs)); err != nil {
+			log.Warn("[commitManifest] failed to remove the superseded manifest",
+				zap.Uint64("resolved ts", superseded.ResolvedTs), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// removeObject removes an object from the storage, it does nothing if the
+// storage can't remove objects.
+func (l *logSink) removeObject(ctx context.Context, name string) error {
+	if l.storage() != nil {
+		remover, ok := l.storage().(objectRemover)
+		if !ok {
+			return nil
+		}
+		return cerror.WrapError(cerror.ErrS3SinkStorageAPI, remover.Remove(ctx, name))
+	}
+	err := os.Remove(filepath.Join(l.root(), filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return cerror.WrapError(cerror.ErrFileSinkFileOp, err)
+}
+
+// writeAtomic writes a whole file to the storage, readers never see a partial file.
+func (l *logSink) writeAtomic(ctx context.Context, name string, data []byte) error {
+	if l.storage() != nil {
+		// a single put object is atomic in s3
+		return cerror.WrapError(cerror.ErrS3SinkStorageAPI, l.storage().Write(ctx, name, data))
+	}
+	target := filepath.Join(l.root(), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), defaultDirMode); err != nil {
+		return cerror.WrapError(cerror.ErrFileSinkCreateDir, err)
+	}
+	tmp := target + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultFileMode)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrFileSinkFileOp, err)
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return cerror.WrapError(cerror.ErrFileSinkFileOp, err)
+	}
+	return cerror.WrapError(cerror.ErrFileSinkFileOp, os.Rename(tmp, target))
+}
+
+// loadLatestManifest loads the latest committed manifest, nil is returned if there is no manifest.
+func (l *logSink) loadLatestManifest(ctx context.Context) (*manifest, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if l.storage() != nil {
+		var name string
+		opt := &storage.WalkOption{
+			SubDir:    manifestDir,
+			ListCount: 1,
+		}
+		err = l.storage().WalkDir(ctx, opt, func(key string, size int64) error {
+			// manifests are listed in lexicographical order, the first one is the latest.
+			if name == "" && strings.Contains(key, manifestPrefix+".") {
+				name = key
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrS3SinkStorageAPI, err)
+		}
+		if name == "" {
+			return nil, nil
+		}
+		name = manifestDir + "/" + name[strings.LastIndex(name, "/")+1:]
+		data, err = l.storage().Read(ctx, name)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrS3SinkStorageAPI, err)
+		}
+	} else {
+		dir := filepath.Join(l.root(), manifestDir)
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, cerror.WrapError(cerror.ErrFileSinkFileOp, err)
+		}
+		var name string
+		// ReadDir returns files sorted by name, the first one is the latest.
+		for _, info := range infos {
+			if !info.IsDir() && strings.HasPrefix(info.Name(), manifestPrefix+".") &&
+				!strings.HasSuffix(info.Name(), ".tmp") {
+				name = info.Name()
+				break
+			}
+		}
+		if name == "" {
+			return nil, nil
+		}
+		data, err = ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrFileSinkFileOp, err)
+		}
+	}
+	m := new(manifest)
+	if err := m.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// recoverFromManifest makes the sink skip the row changed events which are already
+// committed by a manifest of the previous run, so replay after crash doesn't duplicate data.
+// Besides the rows before the resolved ts of the manifest, the rows of a table before
+// the max commit ts of its committed files are skipped. The rows at the max commit ts
+// are written again, since a transaction may be split into several files.
+func (l *logSink) recoverFromManifest(ctx context.Context) error {
+	m, err := l.loadLatestManifest(ctx)
+	if err != nil {
+		return err
+	}
+	if m != nil {
+		l.manifestTs = m.ResolvedTs
+		l.lastManifest = m
+		l.committedTs.Store(m.ResolvedTs)
+		l.carriedFiles = carryFiles(append(m.Carried, m.Files...), m.ResolvedTs)
+		l.tableCommittedTs = make(map[int64]uint64, len(l.carriedFiles))
+		for _, file := range l.carriedFiles {
+			if file.MaxCommitTs > l.tableCommittedTs[file.TableID] {
+				l.tableCommittedTs[file.TableID] = file.MaxCommitTs
+			}
+		}
+		log.Info("[recoverFromManifest] recover from the latest manifest",
+			zap.Uint64("resolved ts", m.ResolvedTs),
+			zap.Any("table committed ts", l.tableCommittedTs))
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type manifestSuite struct{}
+
+var _ = check.Suite(&manifestSuite{})
+
+func (s *manifestSuite) TestCommitAndRecoverManifest(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	root := c.MkDir()
+
+	sink := newLogSink(root, nil, newOptions(maxRowFileSize))
+	m, err := sink.loadLatestManifest(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.IsNil)
+
+	// the manifest is written without any file, the resolved ts advances
+	c.Assert(sink.commitManifest(ctx, 50), check.IsNil)
+	m, err = sink.loadLatestManifest(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, &manifest{ResolvedTs: 50})
+
+	sink.commitDataFile(1, "t_1/cdclog.99", 10, 99, nil)
+	c.Assert(sink.commitManifest(ctx, 100), check.IsNil)
+	sink.commitDataFile(1, "t_1/cdclog.199", 20, 199, nil)
+	sink.commitDataFile(2, "t_2/cdclog.198", 30, 198, nil)
+	// resolved ts doesn't advance, files are kept for the next manifest
+	c.Assert(sink.commitManifest(ctx, 100), check.IsNil)
+	c.Assert(sink.commitManifest(ctx, 150), check.IsNil)
+
+	m, err = sink.loadLatestManifest(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, &manifest{
+		ResolvedTs:     150,
+		PrevResolvedTs: 100,
+		Files: []*dataFile{
+			{TableID: 1, Path: "t_1/cdclog.199", Size: 20, MaxCommitTs: 199},
+			{TableID: 2, Path: "t_2/cdclog.198", Size: 30, MaxCommitTs: 198},
+		},
+	})
+
+	// the files containing the rows after 150 are carried by the next manifest
+	c.Assert(sink.commitManifest(ctx, 198), check.IsNil)
+	m, err = sink.loadLatestManifest(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, &manifest{
+		ResolvedTs:     198,
+		PrevResolvedTs: 150,
+		Carried: []*dataFile{
+			{TableID: 1, Path: "t_1/cdclog.199", Size: 20, MaxCommitTs: 199},
+			{TableID: 2, Path: "t_2/cdclog.198", Size: 30, MaxCommitTs: 198},
+		},
+	})
+
+	// a new run skips the events committed by the previous run
+	sink = newLogSink(root, nil, newOptions(maxRowFileSize))
+	c.Assert(sink.recoverFromManifest(ctx), check.IsNil)
+	c.Assert(sink.manifestTs, check.Equals, uint64(198))
+	c.Assert(sink.carriedFiles, check.DeepEquals, []*dataFile{
+		{TableID: 1, Path: "t_1/cdclog.199", Size: 20, MaxCommitTs: 199},
+	})
+	rows := []*model.RowChangedEvent{
+		{CommitTs: 150, Table: &model.TableName{TableID: 1}},
+		{CommitTs: 198, Table: &model.TableName{TableID: 2}},
+		// the rows at the max commit ts of the committed files are written again
+		{CommitTs: 199, Table: &model.TableName{TableID: 1}},
+		{CommitTs: 201, Table: &model.TableName{TableID: 1}},
+	}
+	err = sink.emitRowChangedEvents(ctx, newTableStream, rows...)
+	c.Assert(err, check.IsNil)
+	c.Assert(sink.units, check.HasLen, 1)
+	c.Assert(sink.units[0].Events().Load(), check.Equals, int64(2))
+}
+
+func (s *manifestSuite) TestIdleAndSupersededManifests(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	root := c.MkDir()
+	listManifests := func() []string {
+		infos, err := ioutil.ReadDir(filepath.Join(root, manifestDir))
+		c.Assert(err, check.IsNil)
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		return names
+	}
+
+	sink := newLogSink(root, nil, newOptions(maxRowFileSize))
+	c.Assert(sink.commitManifest(ctx, 50), check.IsNil)
+	// nothing changes, the manifest is not written
+	c.Assert(sink.commitManifest(ctx, 60), check.IsNil)
+	c.Assert(listManifests(), check.DeepEquals, []string{makeManifestFileName(50)})
+
+	// the idle manifest is written after a while, and supersedes the previous one
+	sink.manifestWriteTime = time.Now().Add(-manifestIdleInterval)
+	c.Assert(sink.commitManifest(ctx, 70), check.IsNil)
+	c.Assert(listManifests(), check.DeepEquals, []string{makeManifestFileName(70)})
+	m, err := sink.loadLatestManifest(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, &manifest{ResolvedTs: 70})
+
+	sink.commitDataFile(1, "t_1/cdclog.99", 10, 99, nil)
+	c.Assert(sink.commitManifest(ctx, 80), check.IsNil)
+	c.Assert(listManifests(), check.DeepEquals, []string{makeManifestFileName(80)})
+	m, err = sink.loadLatestManifest(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, &manifest{
+		ResolvedTs: 80,
+		Files:      []*dataFile{{TableID: 1, Path: "t_1/cdclog.99", Size: 10, MaxCommitTs: 99}},
+	})
+
+	// the manifest listing new files is kept, and the carried rows are
+	// committed without new files
+	c.Assert(sink.commitManifest(ctx, 90), check.IsNil)
+	c.Assert(listManifests(), check.DeepEquals, []string{makeManifestFileName(90), makeManifestFileName(80)})
+}
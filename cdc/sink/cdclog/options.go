@@ -0,0 +1,159 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+)
+
+// file layouts, decide the directory of a table
+const (
+	// layoutTableID puts the files of a table under `t_{table id}`
+	layoutTableID = "table-id"
+	// layoutSchemaTable puts the files of a table under `{schema}/{table}`
+	layoutSchemaTable = "schema-table"
+)
+
+// partition policies, decide the sub directory under a table directory
+const (
+	partitionNone    = "none"
+	partitionDate    = "date"
+	partitionHour    = "hour"
+	partitionTsRange = "ts-range"
+
+	defaultPartitionInterval = time.Hour
+)
+
+// options controls how the log sink organizes and flushes row changed event files.
+type options struct {
+	layout    string
+	partition string
+	// partitionInterval is the commit-ts range covered by a partition, only used by `ts-range` partition
+	partitionInterval time.Duration
+	// fileSize is the target size of a complete file, a file is rotated once it exceeds this size
+	fileSize int64
+	// flushInterval is the max duration rows are buffered before they are flushed to storage
+	flushInterval time.Duration
+}
+
+func newOptions(defaultFileSize int64) *options {
+	return &options{
+		layout:            layoutTableID,
+		partition:         partitionNone,
+		partitionInterval: defaultPartitionInterval,
+		fileSize:          defaultFileSize,
+		flushInterval:     defaultFlushRowChangedEventDuration,
+	}
+}
+
+// parseOptions parses log sink options from the sink uri, for example:
+// s3://bucket/prefix?layout=schema-table&partition=hour&file-size=67108864&flush-interval=10s
+func parseOptions(sinkURI *url.URL, defaultFileSize int64) (*options, error) {
+	opts := newOptions(defaultFileSize)
+	query := sinkURI.Query()
+
+	s := query.Get("layout")
+	switch s {
+	case "":
+	case layoutTableID, layoutSchemaTable:
+		opts.layout = s
+	default:
+		return nil, cerror.ErrSinkURIInvalid.GenWithStack("unknown log sink layout: %s", s)
+	}
+
+	s = query.Get("partition")
+	switch s {
+	case "":
+	case partitionNone, partitionDate, partitionHour, partitionTsRange:
+		opts.partition = s
+	default:
+		return nil, cerror.ErrSinkURIInvalid.GenWithStack("unknown log sink partition: %s", s)
+	}
+
+	s = query.Get("partition-interval")
+	if s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrSinkURIInvalid, err)
+		}
+		if d <= 0 {
+			return nil, cerror.ErrSinkURIInvalid.GenWithStack("partition-interval must be positive: %s", s)
+		}
+		opts.partitionInterval = d
+	}
+
+	s = query.Get("file-size")
+	if s != "" {
+		size, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrSinkURIInvalid, err)
+		}
+		if size <= 0 {
+			return nil, cerror.ErrSinkURIInvalid.GenWithStack("file-size must be positive: %s", s)
+		}
+		opts.fileSize = size
+	}
+
+	s = query.Get("flush-interval")
+	if s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrSinkURIInvalid, err)
+		}
+		if d <= 0 {
+			return nil, cerror.ErrSinkURIInvalid.GenWithStack("flush-interval must be positive: %s", s)
+		}
+		opts.flushInterval = d
+	}
+	return opts, nil
+}
+
+// tableDir returns the directory of a table relative to the sink root.
+func (o *options) tableDir(tableID int64, schema, table string) string {
+	if o.layout == layoutSchemaTable {
+		return path.Join(url.PathEscape(schema), url.PathEscape(table))
+	}
+	return makeTableDirectoryName(tableID)
+}
+
+// partitionDir returns the partition directory for the given commit ts,
+// an empty string is returned if partition is disabled.
+func (o *options) partitionDir(commitTs uint64) string {
+	physical := oracle.GetTimeFromTS(commitTs).UTC()
+	switch o.partition {
+	case partitionDate:
+		return fmt.Sprintf("date=%s", physical.Format("2006-01-02"))
+	case partitionHour:
+		return fmt.Sprintf("date=%s/hour=%02d", physical.Format("2006-01-02"), physical.Hour())
+	case partitionTsRange:
+		start := physical.Truncate(o.partitionInterval)
+		return fmt.Sprintf("ts=%d", oracle.ComposeTS(oracle.GetPhysical(start), 0))
+	}
+	return ""
+}
+
+// tableFileObject returns the key of a complete row changed event file, it's
+// relative to the sink root, which is a local directory or an object storage.
+// Note that a file is placed in the partition of the largest commit ts it contains,
+// so the first rows of a file may belong to the previous partition.
+func (o *options) tableFileObject(tableID int64, schema, table string, commitTs uint64) string {
+	return path.Join(o.tableDir(tableID, schema, table), o.partitionDir(commitTs), makeTableFileName(commitTs))
+}
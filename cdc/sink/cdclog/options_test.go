@@ -0,0 +1,93 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type optionsSuite struct{}
+
+var _ = check.Suite(&optionsSuite{})
+
+func (s *optionsSuite) TestParseOptions(c *check.C) {
+	defer testleak.AfterTest(c)()
+	uri, err := url.Parse("s3://bucket/prefix")
+	c.Assert(err, check.IsNil)
+	opts, err := parseOptions(uri, maxCompletePartSize)
+	c.Assert(err, check.IsNil)
+	c.Assert(opts, check.DeepEquals, newOptions(maxCompletePartSize))
+
+	uri, err = url.Parse("s3://bucket/prefix?layout=schema-table&partition=ts-range" +
+		"&partition-interval=30m&file-size=1024&flush-interval=10s")
+	c.Assert(err, check.IsNil)
+	opts, err = parseOptions(uri, maxCompletePartSize)
+	c.Assert(err, check.IsNil)
+	c.Assert(opts, check.DeepEquals, &options{
+		layout:            layoutSchemaTable,
+		partition:         partitionTsRange,
+		partitionInterval: 30 * time.Minute,
+		fileSize:          1024,
+		flushInterval:     10 * time.Second,
+	})
+
+	for _, query := range []string{
+		"layout=unknown",
+		"partition=minute",
+		"partition-interval=abc",
+		"file-size=-1",
+		"flush-interval=0s",
+	} {
+		uri, err = url.Parse("local:///tmp/cdclog?" + query)
+		c.Assert(err, check.IsNil)
+		_, err = parseOptions(uri, maxRowFileSize)
+		c.Assert(err, check.ErrorMatches, ".*ErrSinkURIInvalid.*")
+	}
+}
+
+func (s *optionsSuite) TestTableFileObject(c *check.C) {
+	defer testleak.AfterTest(c)()
+	commitTs := oracle.ComposeTS(oracle.GetPhysical(time.Date(2020, 12, 1, 8, 45, 0, 0, time.UTC)), 1)
+	testCases := []struct {
+		layout    string
+		partition string
+		expected  string
+	}{
+		{layoutTableID, partitionNone, "t_45/cdclog.%d"},
+		{layoutSchemaTable, partitionNone, "test/t1/cdclog.%d"},
+		{layoutTableID, partitionDate, "t_45/date=2020-12-01/cdclog.%d"},
+		{layoutSchemaTable, partitionHour, "test/t1/date=2020-12-01/hour=08/cdclog.%d"},
+	}
+	for _, tc := range testCases {
+		opts := newOptions(maxRowFileSize)
+		opts.layout = tc.layout
+		opts.partition = tc.partition
+		c.Assert(opts.tableFileObject(45, "test", "t1", commitTs), check.Equals,
+			fmt.Sprintf(tc.expected, commitTs))
+	}
+
+	opts := newOptions(maxRowFileSize)
+	opts.partition = partitionTsRange
+	rangeStart := oracle.ComposeTS(oracle.GetPhysical(time.Date(2020, 12, 1, 8, 0, 0, 0, time.UTC)), 0)
+	c.Assert(opts.partitionDir(commitTs), check.Equals, fmt.Sprintf("ts=%d", rangeStart))
+}
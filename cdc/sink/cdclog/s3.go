@@ -21,7 +21,6 @@ import (
 
 	"github.com/pingcap/br/pkg/storage"
 	"github.com/pingcap/errors"
-	"github.com/pingcap/kvproto/pkg/backup"
 	"github.com/pingcap/log"
 	parsemodel "github.com/pingcap/parser/model"
 	"github.com/pingcap/ticdc/cdc/model"
@@ -34,11 +33,11 @@ import (
 
 const (
 	maxPartFlushSize    = 5 << 20   // The minimal multipart upload size is 5Mb.
-	maxCompletePartSize = 100 << 20 // rotate row changed event file if one complete file larger than 100Mb
+	maxCompletePartSize = 100 << 20 // rotate row changed event file if one complete file larger than 100Mb by default
 	maxDDLFlushSize     = 10 << 20  // rotate ddl event file if one complete file larger than 10Mb
 
 	defaultBufferChanSize               = 20480
-	defaultFlushRowChangedEventDuration = 5 * time.Second // TODO make it as a config
+	defaultFlushRowChangedEventDuration = 5 * time.Second
 )
 
 type tableBuffer struct {
@@ -54,6 +53,10 @@ type tableBuffer struct {
 		uploader  storage.Uploader
 		uploadNum int
 		byteSize  int64
+		fileName  string
+		keyIDs    []string
+		// maxCommitTs is the commit ts of the last row uploaded
+		maxCommitTs uint64
 	}
 }
 
@@ -81,7 +84,7 @@ func (tb *tableBuffer) shouldFlush() bool {
 	return tb.sendSize.Load() > maxPartFlushSize
 }
 
-func (tb *tableBuffer) flush(ctx context.Context, sink *logSink) error {
+func (tb *tableBuffer) flush(ctx context.Context, sink *logSink, seal bool) error {
 	hashPart := tb.uploadParts
 	sendEvents := tb.sendEvents.Load()
 	if sendEvents == 0 && hashPart.uploadNum == 0 {
@@ -96,14 +99,18 @@ func (tb *tableBuffer) flush(ctx context.Context, sink *logSink) error {
 		firstCreated = true
 	}
 
-	var newFileName string
+	var (
+		newFileName string
+		maxCommitTs uint64
+	)
 	flushedSize := int64(0)
 	for event := int64(0); event < sendEvents; event++ {
 		row := <-tb.dataCh
 		flushedSize += row.ApproximateSize
 		if event == sendEvents-1 {
 			// if last event, we record ts as new rotate file name
-			newFileName = makeTableFileObject(row.Table.TableID, row.CommitTs)
+			newFileName = sink.options.tableFileObject(row.Table.TableID, row.Table.Schema, row.Table.Table, row.CommitTs)
+			maxCommitTs = row.CommitTs
 		}
 		_, err := tb.encoder.AppendRowChangedEvent(row)
 		if err != nil {
@@ -127,7 +134,13 @@ func (tb *tableBuffer) flush(ctx context.Context, sink *logSink) error {
 		// zap.ByteString("rowDatas", rowDatas),
 	)
 
-	if len(rowDatas) > maxPartFlushSize || hashPart.uploadNum > 0 {
+	plainSize := len(rowDatas)
+	rowDatas, keyID, err := sink.encrypt(ctx, rowDatas)
+	if err != nil {
+		return err
+	}
+
+	if plainSize > maxPartFlushSize || hashPart.uploadNum > 0 {
 		// S3 multi-upload need every chunk(except the last one) is greater than 5Mb
 		// so, if this batch data size is greater than 5Mb or it has uploadPart already
 		// we will use multi-upload this batch data
@@ -138,6 +151,7 @@ func (tb *tableBuffer) flush(ctx context.Context, sink *logSink) error {
 					return cerror.WrapError(cerror.ErrS3SinkStorageAPI, err)
 				}
 				hashPart.uploader = uploader
+				hashPart.fileName = newFileName
 			}
 
 			err := hashPart.uploader.UploadPart(ctx, rowDatas)
@@ -147,19 +161,26 @@ func (tb *tableBuffer) flush(ctx context.Context, sink *logSink) error {
 
 			hashPart.byteSize += int64(len(rowDatas))
 			hashPart.uploadNum++
+			hashPart.keyIDs = appendKeyID(hashPart.keyIDs, keyID)
+			hashPart.maxCommitTs = maxCommitTs
 		}
 
-		if hashPart.byteSize > maxCompletePartSize || len(rowDatas) <= maxPartFlushSize {
-			// we need do complete when total upload size is greater than 100Mb
+		if hashPart.byteSize > sink.options.fileSize || plainSize <= maxPartFlushSize || seal {
+			// we need do complete when total upload size is greater than the target file size
 			// or this part data is less than 5Mb to avoid meet EntityTooSmall error
+			// or the flushed data must be sealed in a complete file
 			log.Info("[FlushRowChangedEvents] complete file", zap.Int64("tableID", tb.tableID))
 			err := hashPart.uploader.CompleteUpload(ctx)
 			if err != nil {
 				return cerror.WrapError(cerror.ErrS3SinkStorageAPI, err)
 			}
+			sink.commitDataFile(tb.tableID, hashPart.fileName, hashPart.byteSize, hashPart.maxCommitTs, hashPart.keyIDs)
 			hashPart.byteSize = 0
 			hashPart.uploadNum = 0
 			hashPart.uploader = nil
+			hashPart.fileName = ""
+			hashPart.keyIDs = nil
+			hashPart.maxCommitTs = 0
 			tb.encoder = nil
 		}
 	} else {
@@ -169,6 +190,7 @@ func (tb *tableBuffer) flush(ctx context.Context, sink *logSink) error {
 		if err != nil {
 			return cerror.WrapError(cerror.ErrS3SinkStorageAPI, err)
 		}
+		sink.commitDataFile(tb.tableID, newFileName, int64(len(rowDatas)), maxCommitTs, appendKeyID(nil, keyID))
 		tb.encoder = nil
 	}
 
@@ -185,9 +207,12 @@ func newTableBuffer(tableID int64) logUnit {
 		sendSize:   atomic.NewInt64(0),
 		sendEvents: atomic.NewInt64(0),
 		uploadParts: struct {
-			uploader  storage.Uploader
-			uploadNum int
-			byteSize  int64
+			uploader    storage.Uploader
+			uploadNum   int
+			byteSize    int64
+			fileName    string
+			keyIDs      []string
+			maxCommitTs uint64
 		}{
 			uploader:  nil,
 			uploadNum: 0,
@@ -201,7 +226,7 @@ type s3Sink struct {
 
 	prefix string
 
-	storage storage.ExternalStorage
+	storage externalStorage
 
 	logMeta *logMeta
 
@@ -255,6 +280,9 @@ func (s *s3Sink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error {
 			return err
 		}
 	}
+	if err := s.emitDDLSchemaFiles(ctx, ddl); err != nil {
+		return err
+	}
 	firstCreated := false
 	if s.ddlEncoder == nil {
 		s.ddlEncoder = s.encoder()
@@ -267,6 +295,10 @@ func (s *s3Sink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error {
 	data := s.ddlEncoder.MixedBuild(firstCreated)
 	// reset encoder buf for next round append
 	defer s.ddlEncoder.Reset()
+	data, _, err = s.encrypt(ctx, data)
+	if err != nil {
+		return err
+	}
 
 	var (
 		name     string
@@ -317,7 +349,7 @@ func (s *s3Sink) Initialize(ctx context.Context, tableInfo []*model.SimpleTableI
 	if tableInfo != nil {
 		for _, table := range tableInfo {
 			if table != nil {
-				err := s.storage.Write(ctx, makeTableDirectoryName(table.TableID), nil)
+				err := s.storage.Write(ctx, s.options.tableDir(table.TableID, table.Schema, table.Table), nil)
 				if err != nil {
 					return errors.Annotate(
 						cerror.WrapError(cerror.ErrS3SinkStorageAPI, err),
@@ -325,6 +357,9 @@ func (s *s3Sink) Initialize(ctx context.Context, tableInfo []*model.SimpleTableI
 				}
 			}
 		}
+		if err := s.initTableSchemas(ctx, tableInfo); err != nil {
+			return err
+		}
 		// update log meta to record the relationship about tableName and tableID
 		s.logMeta = makeLogMetaContent(tableInfo)
 
@@ -341,37 +376,32 @@ func (s *s3Sink) Close() error {
 	return nil
 }
 
-// NewS3Sink creates new sink support log data to s3 directly
+// NewS3Sink creates new sink support log data to s3 directly,
+// gcs and azure blob storage are supported as well.
 func NewS3Sink(ctx context.Context, sinkURI *url.URL, errCh chan error) (*s3Sink, error) {
-	if len(sinkURI.Host) == 0 {
-		return nil, errors.Errorf("please specify the bucket for s3 in %s", sinkURI)
-	}
-	prefix := strings.Trim(sinkURI.Path, "/")
-	s3 := &backup.S3{Bucket: sinkURI.Host, Prefix: prefix}
-	options := &storage.BackendOptions{}
-	storage.ExtractQueryParameters(sinkURI, &options.S3)
-	if err := options.S3.Apply(s3); err != nil {
-		return nil, cerror.WrapError(cerror.ErrS3SinkInitialzie, err)
+	s3storage, prefix, err := newExternalStorage(ctx, sinkURI)
+	if err != nil {
+		return nil, err
 	}
-	// we should set this to true, since br set it by default in parseBackend
-	s3.ForcePathStyle = true
-	backend := &backup.StorageBackend{
-		Backend: &backup.StorageBackend_S3{S3: s3},
+	opts, err := parseOptions(sinkURI, maxCompletePartSize)
+	if err != nil {
+		return nil, err
 	}
-	s3storage, err := storage.New(ctx, backend, &storage.ExternalStorageOptions{
-		SendCredentials: false,
-		SkipCheckPath:   false,
-		HTTPClient:      nil,
-	})
+	enc, err := parseEncryption(sinkURI)
 	if err != nil {
-		return nil, cerror.WrapError(cerror.ErrS3SinkInitialzie, err)
+		return nil, err
 	}
 
 	s := &s3Sink{
 		prefix:  prefix,
 		storage: s3storage,
 		logMeta: newLogMeta(),
-		logSink: newLogSink("", s3storage),
+		logSink: newLogSink("", s3storage, opts),
+	}
+	s.encryptor = enc
+
+	if err := s.recoverFromManifest(ctx); err != nil {
+		return nil, err
 	}
 
 	// important! we should flush asynchronously in another goroutine
@@ -0,0 +1,154 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// schemaDir is the sub directory of a table directory holding the schema files.
+	schemaDir      = "schema"
+	schemaPrefix   = "schema"
+	ddlEventPrefix = "ddl"
+)
+
+// schemaColumn is a column in the table schema file.
+type schemaColumn struct {
+	Name      string `json:"name"`
+	Type      byte   `json:"type"`
+	Flag      uint   `json:"flag"`
+	FieldType string `json:"field-type"`
+}
+
+// tableSchema is a snapshot of the table schema, it is valid for the row changed events
+// whose commit ts is greater than SchemaTs, until the schema with a greater SchemaTs.
+type tableSchema struct {
+	SchemaTs uint64          `json:"schema-ts"`
+	TableID  int64           `json:"table-id"`
+	Schema   string          `json:"schema"`
+	Table    string          `json:"table"`
+	Columns  []*schemaColumn `json:"columns"`
+}
+
+// ddlEvent is a DDL event changing the table schema, the schema after it is
+// recorded in the schema file with the same ts.
+type ddlEvent struct {
+	CommitTs uint64 `json:"commit-ts"`
+	StartTs  uint64 `json:"start-ts"`
+	Type     string `json:"type"`
+	Query    string `json:"query"`
+	Schema   string `json:"schema"`
+	Table    string `json:"table"`
+	// PreSchema and PreTable are the table name before the DDL, such as rename table
+	PreSchema string `json:"pre-schema,omitempty"`
+	PreTable  string `json:"pre-table,omitempty"`
+}
+
+func newTableSchema(schemaTs uint64, table *model.SimpleTableInfo) *tableSchema {
+	s := &tableSchema{
+		SchemaTs: schemaTs,
+		TableID:  table.TableID,
+		Schema:   table.Schema,
+		Table:    table.Table,
+		Columns:  make([]*schemaColumn, 0, len(table.ColumnInfo)),
+	}
+	for _, col := range table.ColumnInfo {
+		s.Columns = append(s.Columns, &schemaColumn{
+			Name:      col.Name,
+			Type:      col.Type,
+			Flag:      col.Flag,
+			FieldType: col.FieldType,
+		})
+	}
+	return s
+}
+
+func (o *options) schemaFileObject(tableID int64, schema, table string, schemaTs uint64) string {
+	return fmt.Sprintf("%s/%s/%s.%d.json", o.tableDir(tableID, schema, table), schemaDir, schemaPrefix, schemaTs)
+}
+
+func (o *options) ddlEventFileObject(tableID int64, schema, table string, commitTs uint64) string {
+	return fmt.Sprintf("%s/%s/%s.%d.json", o.tableDir(tableID, schema, table), schemaDir, ddlEventPrefix, commitTs)
+}
+
+// writeTableSchema writes the schema file of a table, the file is overwritten if it
+// exists, because the schema of a table at a given ts never changes.
+func (l *logSink) writeTableSchema(ctx context.Context, schemaTs uint64, table *model.SimpleTableInfo) error {
+	data, err := json.Marshal(newTableSchema(schemaTs, table))
+	if err != nil {
+		return cerror.WrapError(cerror.ErrMarshalFailed, err)
+	}
+	name := l.options.schemaFileObject(table.TableID, table.Schema, table.Table, schemaTs)
+	log.Debug("[writeTableSchema] write schema file", zap.String("name", name))
+	return l.writeAtomic(ctx, name, data)
+}
+
+// initTableSchemas writes the schema snapshot of all tables when the sink is initialized.
+func (l *logSink) initTableSchemas(ctx context.Context, tables []*model.SimpleTableInfo) error {
+	for _, table := range tables {
+		if table == nil {
+			continue
+		}
+		if err := l.writeTableSchema(ctx, table.TableInfoVersion, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emitDDLSchemaFiles writes the DDL event file and the schema file after the DDL
+// to the directory of the table changed by the DDL. DDLs without a table, such as
+// create database, are only recorded in the ddl event log.
+func (l *logSink) emitDDLSchemaFiles(ctx context.Context, ddl *model.DDLEvent) error {
+	table := ddl.TableInfo
+	if table == nil || table.Table == "" {
+		// the table is dropped, put the ddl event file in the directory of the dropped table
+		table = ddl.PreTableInfo
+	}
+	if table == nil || table.Table == "" {
+		return nil
+	}
+	event := &ddlEvent{
+		CommitTs: ddl.CommitTs,
+		StartTs:  ddl.StartTs,
+		Type:     ddl.Type.String(),
+		Query:    ddl.Query,
+		Schema:   table.Schema,
+		Table:    table.Table,
+	}
+	if pre := ddl.PreTableInfo; pre != nil && (pre.Schema != table.Schema || pre.Table != table.Table) {
+		event.PreSchema = pre.Schema
+		event.PreTable = pre.Table
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrMarshalFailed, err)
+	}
+	name := l.options.ddlEventFileObject(table.TableID, table.Schema, table.Table, ddl.CommitTs)
+	if err := l.writeAtomic(ctx, name, data); err != nil {
+		return err
+	}
+	if table != ddl.TableInfo {
+		return nil
+	}
+	return l.writeTableSchema(ctx, ddl.CommitTs, table)
+}
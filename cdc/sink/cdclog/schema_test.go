@@ -0,0 +1,109 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/check"
+	parsemodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type schemaSuite struct{}
+
+var _ = check.Suite(&schemaSuite{})
+
+func (s *schemaSuite) TestSchemaFiles(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	root := c.MkDir()
+	sink := newLogSink(root, nil, newOptions(maxRowFileSize))
+
+	table := &model.SimpleTableInfo{
+		Schema:           "test",
+		Table:            "t1",
+		TableID:          1,
+		TableInfoVersion: 100,
+		ColumnInfo:       []*model.ColumnInfo{{Name: "id", Type: 3, FieldType: "int(11)"}},
+	}
+	c.Assert(sink.initTableSchemas(ctx, []*model.SimpleTableInfo{table, nil}), check.IsNil)
+
+	readJSON := func(name string, v interface{}) {
+		data, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		c.Assert(err, check.IsNil)
+		c.Assert(json.Unmarshal(data, v), check.IsNil)
+	}
+	schema := new(tableSchema)
+	readJSON("t_1/schema/schema.100.json", schema)
+	c.Assert(schema, check.DeepEquals, &tableSchema{
+		SchemaTs: 100,
+		TableID:  1,
+		Schema:   "test",
+		Table:    "t1",
+		Columns:  []*schemaColumn{{Name: "id", Type: 3, FieldType: "int(11)"}},
+	})
+
+	newTable := *table
+	newTable.ColumnInfo = append(newTable.ColumnInfo, &model.ColumnInfo{Name: "a", Type: 3, FieldType: "int(11)"})
+	ddl := &model.DDLEvent{
+		StartTs:      190,
+		CommitTs:     200,
+		TableInfo:    &newTable,
+		PreTableInfo: table,
+		Query:        "alter table t1 add column a int",
+		Type:         parsemodel.ActionAddColumn,
+	}
+	c.Assert(sink.emitDDLSchemaFiles(ctx, ddl), check.IsNil)
+	event := new(ddlEvent)
+	readJSON("t_1/schema/ddl.200.json", event)
+	c.Assert(event, check.DeepEquals, &ddlEvent{
+		CommitTs: 200,
+		StartTs:  190,
+		Type:     "add column",
+		Query:    "alter table t1 add column a int",
+		Schema:   "test",
+		Table:    "t1",
+	})
+	schema = new(tableSchema)
+	readJSON("t_1/schema/schema.200.json", schema)
+	c.Assert(schema.Columns, check.HasLen, 2)
+
+	// drop table writes the ddl event file only
+	ddl = &model.DDLEvent{
+		CommitTs:     300,
+		TableInfo:    &model.SimpleTableInfo{Schema: "test"},
+		PreTableInfo: &newTable,
+		Query:        "drop table t1",
+		Type:         parsemodel.ActionDropTable,
+	}
+	c.Assert(sink.emitDDLSchemaFiles(ctx, ddl), check.IsNil)
+	readJSON("t_1/schema/ddl.300.json", new(ddlEvent))
+	_, err := os.Stat(filepath.Join(root, "t_1", "schema", "schema.300.json"))
+	c.Assert(os.IsNotExist(err), check.IsTrue)
+
+	// create database has no table
+	ddl = &model.DDLEvent{
+		CommitTs:  400,
+		TableInfo: &model.SimpleTableInfo{Schema: "test2"},
+		Query:     "create database test2",
+		Type:      parsemodel.ActionCreateSchema,
+	}
+	c.Assert(sink.emitDDLSchemaFiles(ctx, ddl), check.IsNil)
+}
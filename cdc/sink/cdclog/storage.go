@@ -0,0 +1,122 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdclog
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/backup"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+// externalStorage is the subset of storage.ExternalStorage used by the log sink,
+// it is implemented by the br storage (s3, gcs) and azure blob storage.
+type externalStorage interface {
+	// Write writes a complete object, the object is visible only after it returns.
+	Write(ctx context.Context, name string, data []byte) error
+	// Read reads a complete object.
+	Read(ctx context.Context, name string) ([]byte, error)
+	// WalkDir traverses the objects under opt.SubDir in lexicographical order.
+	WalkDir(ctx context.Context, opt *storage.WalkOption, fn func(path string, size int64) error) error
+	// CreateUploader creates a multipart uploader for a large object.
+	CreateUploader(ctx context.Context, name string) (storage.Uploader, error)
+}
+
+// objectRemover is implemented by the external storages which can remove the
+// objects, the superseded manifests are kept in the other ones.
+type objectRemover interface {
+	// Remove removes an object.
+	Remove(ctx context.Context, name string) error
+}
+
+// ObjectStorage is the storage of the objects written and read by names, which
+// are relative to the path of the storage uri.
+type ObjectStorage interface {
+	Write(ctx context.Context, name string, data []byte) error
+	Read(ctx context.Context, name string) ([]byte, error)
+}
+
+// NewObjectStorage creates the object storage of the uri, the schemes of the
+// log sink are supported.
+func NewObjectStorage(ctx context.Context, uri string) (ObjectStorage, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrSinkURIInvalid, err)
+	}
+	s, _, err := newExternalStorage(ctx, u)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return s, nil
+}
+
+// newExternalStorage creates the storage of the log sink by the scheme of sink uri,
+// the supported schemes are s3, gs (or gcs) and azblob (or azure).
+// Credentials can be specified in the sink uri, otherwise they are loaded from the
+// environment variables or the managed identity of the cloud provider.
+func newExternalStorage(ctx context.Context, sinkURI *url.URL) (externalStorage, string, error) {
+	if len(sinkURI.Host) == 0 {
+		return nil, "", errors.Errorf("please specify the bucket for %s in %s", sinkURI.Scheme, sinkURI)
+	}
+	prefix := strings.Trim(sinkURI.Path, "/")
+	var (
+		backend *backup.StorageBackend
+		initErr *errors.Error
+	)
+	switch strings.ToLower(sinkURI.Scheme) {
+	case "s3":
+		s3 := &backup.S3{Bucket: sinkURI.Host, Prefix: prefix}
+		options := &storage.BackendOptions{}
+		storage.ExtractQueryParameters(sinkURI, &options.S3)
+		if err := options.S3.Apply(s3); err != nil {
+			return nil, "", cerror.WrapError(cerror.ErrS3SinkInitialzie, err)
+		}
+		// we should set this to true, since br set it by default in parseBackend
+		s3.ForcePathStyle = true
+		backend = &backup.StorageBackend{
+			Backend: &backup.StorageBackend_S3{S3: s3},
+		}
+		initErr = cerror.ErrS3SinkInitialzie
+	case "gs", "gcs":
+		// credentials are loaded by `credentials-file` in the sink uri,
+		// or from GOOGLE_APPLICATION_CREDENTIALS and the GCE metadata server.
+		var err error
+		backend, err = storage.ParseBackend(sinkURI.String(), nil)
+		if err != nil {
+			return nil, "", cerror.WrapError(cerror.ErrGCSSinkInitialize, err)
+		}
+		initErr = cerror.ErrGCSSinkInitialize
+	case "azblob", "azure":
+		azStorage, err := newAzblobStorage(ctx, sinkURI)
+		if err != nil {
+			return nil, "", cerror.WrapError(cerror.ErrAzblobSinkInitialize, err)
+		}
+		return azStorage, prefix, nil
+	default:
+		return nil, "", cerror.ErrSinkURIInvalid.GenWithStack("the log sink scheme (%s) is not supported", sinkURI.Scheme)
+	}
+	extStorage, err := storage.New(ctx, backend, &storage.ExternalStorageOptions{
+		SendCredentials: false,
+		SkipCheckPath:   false,
+		HTTPClient:      nil,
+	})
+	if err != nil {
+		return nil, "", cerror.WrapError(initErr, err)
+	}
+	return extStorage, prefix, nil
+}
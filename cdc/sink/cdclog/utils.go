@@ -20,7 +20,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/pingcap/br/pkg/storage"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/cdc/sink/codec"
@@ -49,8 +48,9 @@ type logUnit interface {
 
 	isEmpty() bool
 	shouldFlush() bool
-	// flush data to storage.
-	flush(ctx context.Context, sink *logSink) error
+	// flush data to storage, if seal is true, all flushed data must be
+	// in complete files and committed to sink before it returns.
+	flush(ctx context.Context, sink *logSink, seal bool) error
 }
 
 type logSink struct {
@@ -59,16 +59,38 @@ type logSink struct {
 
 	encoder func() codec.EventBatchEncoder
 	units   []logUnit
+	options *options
 
 	// file sink use
 	rootPath string
 	// s3 sink use
-	storagePath storage.ExternalStorage
+	storagePath externalStorage
+	// encryptor encrypts the data files and the ddl files, nil if the encryption is disabled
+	encryptor *encryptor
 
 	hashMap sync.Map
+
+	filesMu      sync.Mutex
+	pendingFiles []*dataFile
+	// manifestTs is the resolved ts of the latest manifest
+	manifestTs uint64
+	// carriedFiles are the committed files containing the rows after manifestTs
+	carriedFiles []*dataFile
+	// lastManifest is the latest manifest, it's superseded by the next one if
+	// it doesn't list any new file.
+	lastManifest *manifest
+	// manifestWriteTime is when the latest manifest is written
+	manifestWriteTime time.Time
+	// row changed events whose commit ts is less than or equal to committedTs
+	// have been committed by the previous run, they are skipped when replaying.
+	committedTs *atomic.Uint64
+	// tableCommittedTs are the max commit ts of the files committed by the previous
+	// run of the tables, which are larger than committedTs. It's only set by the
+	// recovery.
+	tableCommittedTs map[int64]uint64
 }
 
-func newLogSink(root string, storage storage.ExternalStorage) *logSink {
+func newLogSink(root string, storage externalStorage, opts *options) *logSink {
 	return &logSink{
 		notifyChan:     make(chan []logUnit),
 		notifyWaitChan: make(chan struct{}),
@@ -78,13 +100,15 @@ func newLogSink(root string, storage storage.ExternalStorage) *logSink {
 			return ret
 		},
 		units:       make([]logUnit, 0),
+		options:     opts,
 		rootPath:    root,
 		storagePath: storage,
+		committedTs: atomic.NewUint64(0),
 	}
 }
 
 // s3Sink need this
-func (l *logSink) storage() storage.ExternalStorage {
+func (l *logSink) storage() externalStorage {
 	return l.storagePath
 }
 
@@ -112,7 +136,7 @@ func (l *logSink) startFlush(ctx context.Context) error {
 						zap.Int64("size", u.Size().Load()),
 						zap.Int64("event count", u.Events().Load()),
 					)
-					return uReplica.flush(ectx, l)
+					return uReplica.flush(ectx, l, true)
 				})
 			}
 			if err := eg.Wait(); err != nil {
@@ -133,7 +157,7 @@ func (l *logSink) startFlush(ctx context.Context) error {
 							zap.Int64("size", u.Size().Load()),
 							zap.Int64("event count", u.Events().Load()),
 						)
-						return uReplica.flush(ectx, l)
+						return uReplica.flush(ectx, l, false)
 					})
 				}
 			}
@@ -145,9 +169,14 @@ func (l *logSink) startFlush(ctx context.Context) error {
 }
 
 func (l *logSink) emitRowChangedEvents(ctx context.Context, newUnit func(int64) logUnit, rows ...*model.RowChangedEvent) error {
+	committedTs := l.committedTs.Load()
 	for _, row := range rows {
 		// dispatch row event by tableID
 		tableID := row.Table.GetTableID()
+		if row.CommitTs <= committedTs || row.CommitTs < l.tableCommittedTs[tableID] {
+			// already committed by the previous run
+			continue
+		}
 		var (
 			ok   bool
 			item interface{}
@@ -190,8 +219,8 @@ func (l *logSink) flushRowChangedEvents(ctx context.Context, resolvedTs uint64)
 			case <-ctx.Done():
 				return 0, ctx.Err()
 
-			case <-time.After(defaultFlushRowChangedEventDuration):
-				// cannot accumulate enough row events in 5 second
+			case <-time.After(l.options.flushInterval):
+				// cannot accumulate enough row events in flush interval
 				// call flushed worker to flush
 				l.notifyChan <- needFlushedUnits
 				// wait flush worker finished
@@ -199,6 +228,9 @@ func (l *logSink) flushRowChangedEvents(ctx context.Context, resolvedTs uint64)
 			}
 		}
 	}
+	if err := l.commitManifest(ctx, resolvedTs); err != nil {
+		return 0, err
+	}
 	return resolvedTs, nil
 }
 
@@ -222,10 +254,6 @@ func makeTableDirectoryName(tableID int64) string {
 	return fmt.Sprintf("%s%d", tablePrefix, tableID)
 }
 
-func makeTableFileObject(tableID int64, commitTS uint64) string {
-	return fmt.Sprintf("%s%d/%s", tablePrefix, tableID, makeTableFileName(commitTS))
-}
-
 func makeTableFileName(commitTS uint64) string {
 	return fmt.Sprintf("cdclog.%d", commitTS)
 }
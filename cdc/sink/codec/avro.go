@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"sort"
 	"strconv"
 	"time"
 
@@ -28,6 +29,7 @@ import (
 	"github.com/pingcap/log"
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/tidb/types"
 	tijson "github.com/pingcap/tidb/types/json"
@@ -39,6 +41,9 @@ type AvroEventBatchEncoder struct {
 	keySchemaManager   *AvroSchemaManager
 	valueSchemaManager *AvroSchemaManager
 	resultBuf          []*MQMessage
+	// keyBuilder selects the columns encoded in the keys, the handle key
+	// columns are encoded if it's nil.
+	keyBuilder *MessageKeyBuilder
 }
 
 type avroEncodeResult struct {
@@ -65,6 +70,23 @@ func (a *AvroEventBatchEncoder) GetValueSchemaManager() *AvroSchemaManager {
 	return a.valueSchemaManager
 }
 
+// SetMessageKeyBuilder implements the MessageKeySetter interface, only the
+// keys made of the columns can be encoded in Avro.
+func (a *AvroEventBatchEncoder) SetMessageKeyBuilder(builder *MessageKeyBuilder) error {
+	switch builder.Type() {
+	case config.MessageKeyHandleKey, config.MessageKeyUniqueKeys:
+	default:
+		return cerror.ErrKafkaInvalidConfig.GenWithStack(
+			"the message key type %s is not supported by the avro protocol", builder.Type())
+	}
+	a.keyBuilder = builder
+	return nil
+}
+
+// EnableLogCompaction implements the LogCompactionSetter interface, the
+// deleted rows are always sent as tombstones in Avro.
+func (a *AvroEventBatchEncoder) EnableLogCompaction() {}
+
 // SetKeySchemaManager sets the value schema manager for an Avro encoder
 func (a *AvroEventBatchEncoder) SetKeySchemaManager(manager *AvroSchemaManager) {
 	a.keySchemaManager = manager
@@ -98,7 +120,12 @@ func (a *AvroEventBatchEncoder) AppendRowChangedEvent(e *model.RowChangedEvent)
 		mqMessage.Value = nil
 	}
 
-	pkeyCols := e.HandleKeyColumns()
+	var pkeyCols []*model.Column
+	if a.keyBuilder != nil {
+		pkeyCols = a.keyBuilder.KeyColumns(e)
+	} else {
+		pkeyCols = e.HandleKeyColumns()
+	}
 
 	res, err := avroEncode(e.Table, a.keySchemaManager, e.TableInfoVersion, pkeyCols)
 	if err != nil {
@@ -171,7 +198,8 @@ func (a *AvroEventBatchEncoder) SetParams(params map[string]string) error {
 
 func avroEncode(table *model.TableName, manager *AvroSchemaManager, tableVersion uint64, cols []*model.Column) (*avroEncodeResult, error) {
 	schemaGen := func() (string, error) {
-		schema, err := ColumnInfoToAvroSchema(table.Table, cols)
+		namespace, name := manager.recordName(*table)
+		schema, err := columnInfoToAvroSchema(namespace, name, cols)
 		if err != nil {
 			return "", errors.Annotate(err, "AvroEventBatchEncoder: generating schema failed")
 		}
@@ -202,9 +230,10 @@ func avroEncode(table *model.TableName, manager *AvroSchemaManager, tableVersion
 }
 
 type avroSchemaTop struct {
-	Tp     string                   `json:"type"`
-	Name   string                   `json:"name"`
-	Fields []map[string]interface{} `json:"fields"`
+	Tp        string                   `json:"type"`
+	Name      string                   `json:"name"`
+	Namespace string                   `json:"namespace,omitempty"`
+	Fields    []map[string]interface{} `json:"fields"`
 }
 
 type logicalType string
@@ -224,10 +253,15 @@ const (
 
 // ColumnInfoToAvroSchema generates the Avro schema JSON for the corresponding columns
 func ColumnInfoToAvroSchema(name string, columnInfo []*model.Column) (string, error) {
+	return columnInfoToAvroSchema("", name, columnInfo)
+}
+
+func columnInfoToAvroSchema(namespace string, name string, columnInfo []*model.Column) (string, error) {
 	top := avroSchemaTop{
-		Tp:     "record",
-		Name:   name,
-		Fields: nil,
+		Tp:        "record",
+		Name:      name,
+		Namespace: namespace,
+		Fields:    nil,
 	}
 
 	for _, col := range columnInfo {
@@ -488,3 +522,130 @@ func (r *avroEncodeResult) toEnvelope() ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// AvroEventBatchDecoder decodes the Avro messages produced by AvroEventBatchEncoder,
+// a message contains exactly one row changed event.
+// The commit ts, the column types and DDL events are not contained in the Avro messages,
+// so the decoded row changed events can only be applied in the order they are received.
+type AvroEventBatchDecoder struct {
+	ctx                context.Context
+	key                []byte
+	value              []byte
+	keySchemaManager   *AvroSchemaManager
+	valueSchemaManager *AvroSchemaManager
+}
+
+// NewAvroEventBatchDecoder creates a new AvroEventBatchDecoder
+func NewAvroEventBatchDecoder(
+	ctx context.Context, key []byte, value []byte, keySchemaManager *AvroSchemaManager, valueSchemaManager *AvroSchemaManager,
+) EventBatchDecoder {
+	return &AvroEventBatchDecoder{
+		ctx:                ctx,
+		key:                key,
+		value:              value,
+		keySchemaManager:   keySchemaManager,
+		valueSchemaManager: valueSchemaManager,
+	}
+}
+
+// HasNext implements the EventBatchDecoder interface
+func (d *AvroEventBatchDecoder) HasNext() (model.MqMessageType, bool, error) {
+	if len(d.key) == 0 {
+		return model.MqMessageTypeUnknow, false, nil
+	}
+	return model.MqMessageTypeRow, true, nil
+}
+
+// NextResolvedEvent implements the EventBatchDecoder interface, resolved events are not supported by Avro
+func (d *AvroEventBatchDecoder) NextResolvedEvent() (uint64, error) {
+	return 0, cerror.ErrAvroDecodeFailed.GenWithStack("resolved events are not supported by Avro")
+}
+
+// NextDDLEvent implements the EventBatchDecoder interface, DDL events are not supported by Avro
+func (d *AvroEventBatchDecoder) NextDDLEvent() (*model.DDLEvent, error) {
+	return nil, cerror.ErrAvroDecodeFailed.GenWithStack("DDL events are not supported by Avro")
+}
+
+// NextRowChangedEvent implements the EventBatchDecoder interface
+func (d *AvroEventBatchDecoder) NextRowChangedEvent() (*model.RowChangedEvent, error) {
+	if len(d.key) == 0 {
+		return nil, cerror.ErrAvroDecodeFailed.GenWithStack("no row changed event left")
+	}
+	keyCols, table, err := avroDecode(d.ctx, d.keySchemaManager, d.key)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	d.key = nil
+	row := &model.RowChangedEvent{Table: table}
+	handleKeys := make(map[string]struct{}, len(keyCols))
+	for _, col := range keyCols {
+		col.Flag.SetIsHandleKey()
+		handleKeys[col.Name] = struct{}{}
+	}
+	if len(d.value) == 0 {
+		// the value of a deleted row is nil
+		row.PreColumns = keyCols
+		return row, nil
+	}
+	cols, _, err := avroDecode(d.ctx, d.valueSchemaManager, d.value)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	d.value = nil
+	for _, col := range cols {
+		if _, ok := handleKeys[col.Name]; ok {
+			col.Flag.SetIsHandleKey()
+		}
+	}
+	row.Columns = cols
+	return row, nil
+}
+
+// avroDecode decodes an Avro envelope to columns, the columns are sorted by name.
+func avroDecode(ctx context.Context, manager *AvroSchemaManager, data []byte) ([]*model.Column, *model.TableName, error) {
+	if len(data) < 5 || data[0] != magicByte {
+		return nil, nil, cerror.ErrAvroDecodeFailed.GenWithStack("invalid Avro envelope")
+	}
+	registryID := int(binary.BigEndian.Uint32(data[1:5]))
+	avroCodec, table, err := manager.LookupByID(ctx, registryID)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	native, _, err := avroCodec.NativeFromBinary(data[5:])
+	if err != nil {
+		return nil, nil, cerror.WrapError(cerror.ErrAvroDecodeFailed, err)
+	}
+	record, ok := native.(map[string]interface{})
+	if !ok {
+		return nil, nil, cerror.ErrAvroDecodeFailed.GenWithStack("unexpected Avro native data %v", native)
+	}
+	cols := make([]*model.Column, 0, len(record))
+	for name, value := range record {
+		if union, ok := value.(map[string]interface{}); ok {
+			// nullable columns are encoded as unions
+			value = nil
+			for _, v := range union {
+				value = v
+			}
+		}
+		cols = append(cols, &model.Column{Name: name, Value: avroNativeToColumnValue(value)})
+	}
+	sort.Slice(cols, func(i, j int) bool { return cols[i].Name < cols[j].Name })
+	return cols, &model.TableName{Schema: table.Schema, Table: table.Table}, nil
+}
+
+// avroNativeToColumnValue converts the Avro native data to the value accepted by the MySQL sink.
+func avroNativeToColumnValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case int32:
+		return int64(val)
+	case *big.Rat:
+		return val.RatString()
+	case time.Time:
+		return val.UTC().Format("2006-01-02 15:04:05.999")
+	case time.Duration:
+		return types.Duration{Duration: val, Fsp: 3}.String()
+	default:
+		return v
+	}
+}
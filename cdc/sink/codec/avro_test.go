@@ -194,3 +194,52 @@ func (s *avroBatchEncoderSuite) TestAvroEncode(c *check.C) {
 	_, err = s.encoder.AppendRowChangedEvent(testCaseUpdate)
 	c.Check(err, check.IsNil)
 }
+
+func (s *avroBatchEncoderSuite) TestAvroDecode(c *check.C) {
+	defer testleak.AfterTest(c)()
+	row := &model.RowChangedEvent{
+		CommitTs:         417318403368288260,
+		TableInfoVersion: 1,
+		Table: &model.TableName{
+			Schema: "test_db",
+			Table:  "decode_t",
+		},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag, Value: int64(1)},
+			{Name: "name", Type: mysql.TypeVarchar, Value: "Bob"},
+			{Name: "comment", Type: mysql.TypeVarchar, Value: nil},
+		},
+	}
+	_, err := s.encoder.AppendRowChangedEvent(row)
+	c.Assert(err, check.IsNil)
+	msgs := s.encoder.Build()
+	c.Assert(msgs, check.HasLen, 1)
+
+	ctx := context.Background()
+	decoder := NewAvroEventBatchDecoder(ctx, msgs[0].Key, msgs[0].Value, s.encoder.keySchemaManager, s.encoder.valueSchemaManager)
+	tp, hasNext, err := decoder.HasNext()
+	c.Assert(err, check.IsNil)
+	c.Assert(hasNext, check.IsTrue)
+	c.Assert(tp, check.Equals, model.MqMessageTypeRow)
+	decoded, err := decoder.NextRowChangedEvent()
+	c.Assert(err, check.IsNil)
+	c.Assert(decoded.Table, check.DeepEquals, row.Table)
+	c.Assert(decoded.PreColumns, check.HasLen, 0)
+	c.Assert(decoded.Columns, check.DeepEquals, []*model.Column{
+		{Name: "comment", Value: nil},
+		{Name: "id", Flag: model.HandleKeyFlag, Value: int64(1)},
+		{Name: "name", Value: "Bob"},
+	})
+	_, hasNext, err = decoder.HasNext()
+	c.Assert(err, check.IsNil)
+	c.Assert(hasNext, check.IsFalse)
+
+	// the value of a deleted row is nil
+	decoder = NewAvroEventBatchDecoder(ctx, msgs[0].Key, nil, s.encoder.keySchemaManager, s.encoder.valueSchemaManager)
+	decoded, err = decoder.NextRowChangedEvent()
+	c.Assert(err, check.IsNil)
+	c.Assert(decoded.Columns, check.HasLen, 0)
+	c.Assert(decoded.PreColumns, check.DeepEquals, []*model.Column{
+		{Name: "id", Flag: model.HandleKeyFlag, Value: int64(1)},
+	})
+}
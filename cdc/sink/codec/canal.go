@@ -77,7 +77,8 @@ func convertDdlEventType(e *model.DDLEvent) canal.EventType {
 	case mm.ActionAddColumn, mm.ActionDropColumn, mm.ActionModifyColumn, mm.ActionRebaseAutoID,
 		mm.ActionSetDefaultValue, mm.ActionModifyTableComment, mm.ActionRenameIndex, mm.ActionAddTablePartition,
 		mm.ActionDropTablePartition, mm.ActionModifyTableCharsetAndCollate, mm.ActionTruncateTablePartition,
-		mm.ActionAddColumns, mm.ActionDropColumns:
+		mm.ActionAddColumns, mm.ActionDropColumns, mm.ActionAlterIndexVisibility, mm.ActionExchangeTablePartition,
+		mm.ActionAlterTableAlterPartition:
 		return canal.EventType_ALTER
 	case mm.ActionDropTable:
 		return canal.EventType_ERASE
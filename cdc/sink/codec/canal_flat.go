@@ -15,13 +15,19 @@ package codec
 
 import (
 	"encoding/json"
+	"sort"
+	"strings"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
+	mm "github.com/pingcap/parser/model"
+	parser_types "github.com/pingcap/parser/types"
 	"github.com/pingcap/ticdc/cdc/model"
 	cerrors "github.com/pingcap/ticdc/pkg/errors"
 	canal "github.com/pingcap/ticdc/proto/canal"
+	"github.com/pingcap/tidb/store/tikv/oracle"
 	"go.uber.org/zap"
+	"golang.org/x/text/encoding/charmap"
 )
 
 // CanalFlatEventBatchEncoder encodes Canal flat messages in JSON format
@@ -29,6 +35,11 @@ type CanalFlatEventBatchEncoder struct {
 	builder       *canalEntryBuilder
 	unresolvedBuf []*canalFlatMessage
 	resolvedBuf   []*canalFlatMessage
+	// keyBuilder builds the keys of the row messages, the keys are empty if
+	// it's nil.
+	keyBuilder *MessageKeyBuilder
+	// tombstone sends the deleted rows as the messages with null values.
+	tombstone bool
 }
 
 // NewCanalFlatEventBatchEncoder creates a new CanalFlatEventBatchEncoder
@@ -59,7 +70,124 @@ type canalFlatMessage struct {
 	Data []map[string]interface{} `json:"data"`
 	Old  []map[string]interface{} `json:"old"`
 	// Used internally by CanalFlatEventBatchEncoder
-	tikvTs uint64
+	tikvTs    uint64
+	key       []byte
+	tombstone bool
+}
+
+// writeJSON writes the message in the same format as json.Marshal.
+func (c *canalFlatMessage) writeJSON(w *jsonWriter) error {
+	w.objectStart()
+	w.key("id")
+	w.int64(c.ID)
+	w.key("database")
+	w.string(c.Schema)
+	w.key("table")
+	w.string(c.Table)
+	w.key("pkNames")
+	if c.PKNames == nil {
+		w.null()
+	} else {
+		w.arrayStart()
+		for _, name := range c.PKNames {
+			w.string(name)
+		}
+		w.arrayEnd()
+	}
+	w.key("isDdl")
+	w.bool(c.IsDDL)
+	w.key("type")
+	w.string(c.EventType)
+	w.key("es")
+	w.int64(c.ExecutionTime)
+	w.key("ts")
+	w.uint64(c.BuildTime)
+	w.key("sql")
+	w.string(c.Query)
+	w.key("sqlType")
+	if c.SQLType == nil {
+		w.null()
+	} else {
+		w.objectStart()
+		for _, name := range sortedMapKeys(w, c.SQLType) {
+			w.key(name)
+			w.int64(int64(c.SQLType[name]))
+		}
+		w.objectEnd()
+	}
+	w.key("mysqlType")
+	if c.MySQLType == nil {
+		w.null()
+	} else {
+		w.objectStart()
+		for _, name := range sortedMapKeys(w, c.MySQLType) {
+			w.key(name)
+			w.string(c.MySQLType[name])
+		}
+		w.objectEnd()
+	}
+	w.key("data")
+	if err := writeCanalFlatData(w, c.Data); err != nil {
+		return err
+	}
+	w.key("old")
+	if err := writeCanalFlatData(w, c.Old); err != nil {
+		return err
+	}
+	w.objectEnd()
+	return nil
+}
+
+func writeCanalFlatData(w *jsonWriter, data []map[string]interface{}) error {
+	if data == nil {
+		w.null()
+		return nil
+	}
+	w.arrayStart()
+	for _, row := range data {
+		if row == nil {
+			w.null()
+			continue
+		}
+		w.objectStart()
+		for _, name := range w.sortedKeys(row) {
+			w.key(name)
+			if err := w.value(row[name]); err != nil {
+				return err
+			}
+		}
+		w.objectEnd()
+	}
+	w.arrayEnd()
+	return nil
+}
+
+// sortedMapKeys returns the sorted keys of the sqlType or mysqlType map, the
+// keys are valid until the next call.
+func sortedMapKeys(w *jsonWriter, m interface{}) []string {
+	w.keys = w.keys[:0]
+	switch m := m.(type) {
+	case map[string]int32:
+		for k := range m {
+			w.keys = append(w.keys, k)
+		}
+	case map[string]string:
+		for k := range m {
+			w.keys = append(w.keys, k)
+		}
+	}
+	sort.Strings(w.keys)
+	return w.keys
+}
+
+// encode encodes the message by a pooled jsonWriter.
+func (c *canalFlatMessage) encode() ([]byte, error) {
+	w := getJSONWriter()
+	defer putJSONWriter(w)
+	if err := c.writeJSON(w); err != nil {
+		return nil, err
+	}
+	return w.bytes(), nil
 }
 
 func (c *CanalFlatEventBatchEncoder) newFlatMessageForDML(e *model.RowChangedEvent) (*canalFlatMessage, error) {
@@ -174,6 +302,13 @@ func (c *CanalFlatEventBatchEncoder) AppendRowChangedEvent(e *model.RowChangedEv
 	if err != nil {
 		return EncoderNoOperation, errors.Trace(err)
 	}
+	if c.keyBuilder != nil {
+		msg.key, err = c.keyBuilder.Build(e)
+		if err != nil {
+			return EncoderNoOperation, errors.Trace(err)
+		}
+	}
+	msg.tombstone = c.tombstone && e.IsDelete()
 	c.unresolvedBuf = append(c.unresolvedBuf, msg)
 	return EncoderNoOperation, nil
 }
@@ -202,7 +337,7 @@ func (c *CanalFlatEventBatchEncoder) EncodeDDLEvent(e *model.DDLEvent) (*MQMessa
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	value, err := json.Marshal(msg)
+	value, err := msg.encode()
 	if err != nil {
 		return nil, cerrors.WrapError(cerrors.ErrCanalEncodeFailed, err)
 	}
@@ -216,12 +351,16 @@ func (c *CanalFlatEventBatchEncoder) Build() []*MQMessage {
 	}
 	ret := make([]*MQMessage, len(c.resolvedBuf))
 	for i := range c.resolvedBuf {
-		value, err := json.Marshal(c.resolvedBuf[i])
+		if c.resolvedBuf[i].tombstone {
+			ret[i] = NewMQMessage(c.resolvedBuf[i].key, nil, c.resolvedBuf[i].tikvTs)
+			continue
+		}
+		value, err := c.resolvedBuf[i].encode()
 		if err != nil {
 			log.Panic("CanalFlatEventBatchEncoder", zap.Error(err))
 			return nil
 		}
-		ret[i] = NewMQMessage(nil, value, c.resolvedBuf[i].tikvTs)
+		ret[i] = NewMQMessage(c.resolvedBuf[i].key, value, c.resolvedBuf[i].tikvTs)
 	}
 	c.resolvedBuf = c.resolvedBuf[0:0]
 	return ret
@@ -247,3 +386,175 @@ func (c *CanalFlatEventBatchEncoder) SetParams(params map[string]string) error {
 	// no op
 	return nil
 }
+
+// CanalFlatEventBatchDecoder decodes the Canal flat messages in JSON format,
+// a message contains exactly one row changed event or DDL event.
+// Only the physical part of the commit ts is kept in the Canal flat messages,
+// and resolved events are not supported.
+type CanalFlatEventBatchDecoder struct {
+	msg *canalFlatMessage
+}
+
+// NewCanalFlatEventBatchDecoder creates a new CanalFlatEventBatchDecoder
+func NewCanalFlatEventBatchDecoder(data []byte) (EventBatchDecoder, error) {
+	msg := new(canalFlatMessage)
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, cerrors.WrapError(cerrors.ErrCanalDecodeFailed, err)
+	}
+	return &CanalFlatEventBatchDecoder{msg: msg}, nil
+}
+
+// HasNext implements the EventBatchDecoder interface
+func (b *CanalFlatEventBatchDecoder) HasNext() (model.MqMessageType, bool, error) {
+	if b.msg == nil {
+		return model.MqMessageTypeUnknow, false, nil
+	}
+	if b.msg.IsDDL {
+		return model.MqMessageTypeDDL, true, nil
+	}
+	return model.MqMessageTypeRow, true, nil
+}
+
+// NextResolvedEvent implements the EventBatchDecoder interface, resolved events are not supported
+func (b *CanalFlatEventBatchDecoder) NextResolvedEvent() (uint64, error) {
+	return 0, cerrors.ErrCanalDecodeFailed.GenWithStack("resolved events are not supported by canal-json")
+}
+
+// NextRowChangedEvent implements the EventBatchDecoder interface
+func (b *CanalFlatEventBatchDecoder) NextRowChangedEvent() (*model.RowChangedEvent, error) {
+	if b.msg == nil || b.msg.IsDDL {
+		return nil, cerrors.ErrCanalDecodeFailed.GenWithStack("not found row changed event message")
+	}
+	msg := b.msg
+	b.msg = nil
+
+	pkNames := make(map[string]struct{}, len(msg.PKNames))
+	for _, name := range msg.PKNames {
+		pkNames[name] = struct{}{}
+	}
+	var data, old map[string]interface{}
+	if len(msg.Data) > 0 {
+		data = msg.Data[0]
+	}
+	if len(msg.Old) > 0 {
+		old = msg.Old[0]
+	}
+	row := &model.RowChangedEvent{
+		CommitTs: oracle.ComposeTS(msg.ExecutionTime, 0),
+		Table:    &model.TableName{Schema: msg.Schema, Table: msg.Table},
+	}
+	var err error
+	switch msg.EventType {
+	case canal.EventType_INSERT.String():
+		row.Columns, err = canalFlatDataToColumns(data, msg.MySQLType, pkNames)
+	case canal.EventType_UPDATE.String():
+		row.Columns, err = canalFlatDataToColumns(data, msg.MySQLType, pkNames)
+		if err == nil {
+			row.PreColumns, err = canalFlatDataToColumns(old, msg.MySQLType, pkNames)
+		}
+	case canal.EventType_DELETE.String():
+		// the deleted row is in the data, see newFlatMessageForDML
+		row.PreColumns, err = canalFlatDataToColumns(data, msg.MySQLType, pkNames)
+	default:
+		return nil, cerrors.ErrCanalDecodeFailed.GenWithStack("unknown event type %s", msg.EventType)
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return row, nil
+}
+
+// NextDDLEvent implements the EventBatchDecoder interface
+func (b *CanalFlatEventBatchDecoder) NextDDLEvent() (*model.DDLEvent, error) {
+	if b.msg == nil || !b.msg.IsDDL {
+		return nil, cerrors.ErrCanalDecodeFailed.GenWithStack("not found DDL event message")
+	}
+	msg := b.msg
+	b.msg = nil
+	ddl := &model.DDLEvent{
+		CommitTs:  oracle.ComposeTS(msg.ExecutionTime, 0),
+		TableInfo: &model.SimpleTableInfo{Schema: msg.Schema, Table: msg.Table},
+		Query:     msg.Query,
+		Type:      canalFlatEventTypeToAction(msg.EventType, msg.Table),
+	}
+	return ddl, nil
+}
+
+// canalFlatEventTypeToAction converts the canal event type back to an action type roughly,
+// it is the inverse of convertDdlEventType.
+func canalFlatEventTypeToAction(eventType string, table string) mm.ActionType {
+	switch eventType {
+	case canal.EventType_CREATE.String():
+		return mm.ActionCreateTable
+	case canal.EventType_RENAME.String():
+		return mm.ActionRenameTable
+	case canal.EventType_CINDEX.String():
+		return mm.ActionAddIndex
+	case canal.EventType_DINDEX.String():
+		return mm.ActionDropIndex
+	case canal.EventType_ALTER.String():
+		return mm.ActionModifyColumn
+	case canal.EventType_ERASE.String():
+		return mm.ActionDropTable
+	case canal.EventType_TRUNCATE.String():
+		return mm.ActionTruncateTable
+	}
+	if table == "" {
+		// schema level DDLs, such as create database, should not switch to the schema before executed
+		return mm.ActionCreateSchema
+	}
+	return mm.ActionNone
+}
+
+func canalFlatDataToColumns(
+	data map[string]interface{}, mysqlType map[string]string, pkNames map[string]struct{},
+) ([]*model.Column, error) {
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	encoder := charmap.ISO8859_1.NewEncoder()
+	cols := make([]*model.Column, 0, len(data))
+	for _, name := range names {
+		tpName := mysqlType[name]
+		col := &model.Column{
+			Name: name,
+			Type: parser_types.StrToType(tpName),
+		}
+		if _, ok := pkNames[name]; ok {
+			col.Flag.SetIsHandleKey()
+			col.Flag.SetIsPrimaryKey()
+		}
+		switch v := data[name].(type) {
+		case nil:
+		case string:
+			if strings.Contains(tpName, "blob") || strings.Contains(tpName, "binary") {
+				// binary values are encoded in ISO-8859-1, see buildColumn
+				col.Flag.SetIsBinary()
+				encoded, err := encoder.String(v)
+				if err != nil {
+					return nil, cerrors.WrapError(cerrors.ErrCanalDecodeFailed, err)
+				}
+				col.Value = []byte(encoded)
+			} else {
+				col.Value = v
+			}
+		default:
+			return nil, cerrors.ErrCanalDecodeFailed.GenWithStack("unexpected value %v of column %s", v, name)
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// SetMessageKeyBuilder implements the MessageKeySetter interface
+func (c *CanalFlatEventBatchEncoder) SetMessageKeyBuilder(builder *MessageKeyBuilder) error {
+	c.keyBuilder = builder
+	return nil
+}
+
+// EnableLogCompaction implements the LogCompactionSetter interface
+func (c *CanalFlatEventBatchEncoder) EnableLogCompaction() {
+	c.tombstone = true
+}
@@ -140,6 +140,60 @@ func (s *canalFlatSuite) TestBatching(c *check.C) {
 	c.Assert(encoder.resolvedBuf, check.HasLen, 0)
 }
 
+func (s *canalFlatSuite) TestDecodeCanalFlatMessage(c *check.C) {
+	defer testleak.AfterTest(c)()
+	encoder := &CanalFlatEventBatchEncoder{builder: NewCanalEntryBuilder()}
+	_, err := encoder.AppendRowChangedEvent(testCaseUpdate)
+	c.Assert(err, check.IsNil)
+	_, err = encoder.AppendResolvedEvent(testCaseUpdate.CommitTs)
+	c.Assert(err, check.IsNil)
+	msgs := encoder.Build()
+	c.Assert(msgs, check.HasLen, 1)
+
+	decoder, err := NewCanalFlatEventBatchDecoder(msgs[0].Value)
+	c.Assert(err, check.IsNil)
+	tp, hasNext, err := decoder.HasNext()
+	c.Assert(err, check.IsNil)
+	c.Assert(hasNext, check.IsTrue)
+	c.Assert(tp, check.Equals, model.MqMessageTypeRow)
+	row, err := decoder.NextRowChangedEvent()
+	c.Assert(err, check.IsNil)
+	c.Assert(row.CommitTs>>18, check.Equals, testCaseUpdate.CommitTs>>18)
+	c.Assert(row.Table, check.DeepEquals, testCaseUpdate.Table)
+	c.Assert(row.Columns, check.HasLen, 5)
+	c.Assert(row.PreColumns, check.HasLen, 5)
+	for _, col := range row.Columns {
+		switch col.Name {
+		case "id":
+			c.Assert(col.Flag.IsHandleKey(), check.IsTrue)
+			c.Assert(col.Value, check.Equals, "1")
+		case "name":
+			c.Assert(col.Type, check.Equals, mysql.TypeVarchar)
+			c.Assert(col.Value, check.Equals, "Bob")
+		case "blob":
+			c.Assert(col.Flag.IsBinary(), check.IsTrue)
+			c.Assert(col.Value, check.DeepEquals, []byte("测试blob"))
+		}
+	}
+	_, hasNext, err = decoder.HasNext()
+	c.Assert(err, check.IsNil)
+	c.Assert(hasNext, check.IsFalse)
+
+	msg, err := encoder.EncodeDDLEvent(testCaseDdl)
+	c.Assert(err, check.IsNil)
+	decoder, err = NewCanalFlatEventBatchDecoder(msg.Value)
+	c.Assert(err, check.IsNil)
+	tp, hasNext, err = decoder.HasNext()
+	c.Assert(err, check.IsNil)
+	c.Assert(hasNext, check.IsTrue)
+	c.Assert(tp, check.Equals, model.MqMessageTypeDDL)
+	ddl, err := decoder.NextDDLEvent()
+	c.Assert(err, check.IsNil)
+	c.Assert(ddl.Query, check.Equals, testCaseDdl.Query)
+	c.Assert(ddl.Type, check.Equals, mm.ActionCreateTable)
+	c.Assert(ddl.TableInfo, check.DeepEquals, testCaseDdl.TableInfo)
+}
+
 var testCaseUpdate = &model.RowChangedEvent{
 	CommitTs: 417318403368288260,
 	Table: &model.TableName{
@@ -0,0 +1,67 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+// claimCheckMagic prefixes the values of the claim check messages, it's never
+// the prefix of the values of the protocols, e.g. the avro values start with
+// 0x00 and the protobuf values never start with 0xff.
+var claimCheckMagic = []byte("\xffticdc-claim-check\xff")
+
+// NewClaimCheckMessage returns the claim check message of a message offloaded
+// to the external storage as the object of the name. It has the key of the
+// offloaded message, so it's in the same partition and log compaction works.
+func NewClaimCheckMessage(key []byte, name string, ts uint64) *MQMessage {
+	value := make([]byte, 0, len(claimCheckMagic)+len(name))
+	value = append(value, claimCheckMagic...)
+	value = append(value, name...)
+	return NewMQMessage(key, value, ts)
+}
+
+// ParseClaimCheck returns the object name of the offloaded message if the value
+// is of a claim check message.
+func ParseClaimCheck(value []byte) (string, bool) {
+	if !bytes.HasPrefix(value, claimCheckMagic) {
+		return "", false
+	}
+	return string(value[len(claimCheckMagic):]), true
+}
+
+// EncodeClaimCheckObject encodes the offloaded message as the object, which is
+// the length of the key in 8 bytes, the key and the value.
+func EncodeClaimCheckObject(msg *MQMessage) []byte {
+	data := make([]byte, 8, 8+len(msg.Key)+len(msg.Value))
+	binary.BigEndian.PutUint64(data, uint64(len(msg.Key)))
+	data = append(data, msg.Key...)
+	return append(data, msg.Value...)
+}
+
+// DecodeClaimCheckObject decodes the key and the value of the offloaded message
+// from the object.
+func DecodeClaimCheckObject(data []byte) (key []byte, value []byte, err error) {
+	if len(data) < 8 {
+		return nil, nil, cerror.ErrClaimCheckInvalidObject.GenWithStackByArgs(len(data))
+	}
+	keyLen := binary.BigEndian.Uint64(data)
+	if keyLen > uint64(len(data)-8) {
+		return nil, nil, cerror.ErrClaimCheckInvalidObject.GenWithStackByArgs(len(data))
+	}
+	return data[8 : 8+keyLen], data[8+keyLen:], nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"github.com/pingcap/check"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type claimCheckSuite struct{}
+
+var _ = check.Suite(&claimCheckSuite{})
+
+func (s *claimCheckSuite) TestClaimCheck(c *check.C) {
+	defer testleak.AfterTest(c)()
+	msg := NewClaimCheckMessage([]byte("key"), "cf/test.t/100-1", 100)
+	c.Assert(msg.Key, check.DeepEquals, []byte("key"))
+	name, ok := ParseClaimCheck(msg.Value)
+	c.Assert(ok, check.IsTrue)
+	c.Assert(name, check.Equals, "cf/test.t/100-1")
+	_, ok = ParseClaimCheck([]byte(`{"id":1}`))
+	c.Assert(ok, check.IsFalse)
+
+	data := EncodeClaimCheckObject(NewMQMessage([]byte("key"), []byte("value"), 100))
+	key, value, err := DecodeClaimCheckObject(data)
+	c.Assert(err, check.IsNil)
+	c.Assert(key, check.DeepEquals, []byte("key"))
+	c.Assert(value, check.DeepEquals, []byte("value"))
+
+	for _, data := range [][]byte{nil, data[:7], data[:10]} {
+		_, _, err = DecodeClaimCheckObject(data)
+		c.Assert(cerror.ErrClaimCheckInvalidObject.Equal(err), check.IsTrue)
+	}
+}
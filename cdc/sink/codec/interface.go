@@ -100,6 +100,23 @@ type EventBatchDecoder interface {
 	NextDDLEvent() (*model.DDLEvent, error)
 }
 
+// HeartbeatEncoder is implemented by the encoders which can encode the
+// heartbeat events of the tables.
+type HeartbeatEncoder interface {
+	// EncodeHeartbeatEvent encodes a heartbeat event of the table, which tells
+	// the consumers that all the rows of the table committed before or at ts
+	// have been sent, even if the table has no changes.
+	EncodeHeartbeatEvent(table *model.TableName, ts uint64) (*MQMessage, error)
+}
+
+// HeartbeatDecoder is implemented by the decoders which can decode the
+// heartbeat events of the tables.
+type HeartbeatDecoder interface {
+	// NextHeartbeatEvent returns the table and the ts of the next heartbeat
+	// event if exists
+	NextHeartbeatEvent() (*model.TableName, uint64, error)
+}
+
 // EncoderResult indicates an action request by the encoder to the mqSink
 type EncoderResult uint8
 
@@ -135,8 +135,36 @@ type mqMessageKey struct {
 }
 
 func (m *mqMessageKey) Encode() ([]byte, error) {
-	data, err := json.Marshal(m)
-	return data, cerror.WrapError(cerror.ErrMarshalFailed, err)
+	w := getJSONWriter()
+	defer putJSONWriter(w)
+	m.writeJSON(w)
+	return w.bytes(), nil
+}
+
+// writeJSON writes the key in the same format as json.Marshal.
+func (m *mqMessageKey) writeJSON(w *jsonWriter) {
+	w.objectStart()
+	w.key("ts")
+	w.uint64(m.Ts)
+	if m.Schema != "" {
+		w.key("scm")
+		w.string(m.Schema)
+	}
+	if m.Table != "" {
+		w.key("tbl")
+		w.string(m.Table)
+	}
+	if m.RowID != 0 {
+		w.key("rid")
+		w.int64(m.RowID)
+	}
+	if m.Partition != nil {
+		w.key("ptn")
+		w.int64(*m.Partition)
+	}
+	w.key("t")
+	w.int64(int64(m.Type))
+	w.objectEnd()
 }
 
 func (m *mqMessageKey) Decode(data []byte) error {
@@ -149,6 +177,8 @@ type mqMessageRow struct {
 	Delete     map[string]column `json:"d,omitempty"`
 }
 
+// Encode encodes the row message by encoding/json, the encoder writes the
+// row events by writeRowEventValue directly.
 func (m *mqMessageRow) Encode() ([]byte, error) {
 	data, err := json.Marshal(m)
 	return data, cerror.WrapError(cerror.ErrMarshalFailed, err)
@@ -179,8 +209,15 @@ type mqMessageDDL struct {
 }
 
 func (m *mqMessageDDL) Encode() ([]byte, error) {
-	data, err := json.Marshal(m)
-	return data, cerror.WrapError(cerror.ErrMarshalFailed, err)
+	w := getJSONWriter()
+	defer putJSONWriter(w)
+	w.objectStart()
+	w.key("q")
+	w.string(m.Query)
+	w.key("t")
+	w.uint64(uint64(m.Type))
+	w.objectEnd()
+	return w.bytes(), nil
 }
 
 func (m *mqMessageDDL) Decode(data []byte) error {
@@ -194,12 +231,12 @@ func newResolvedMessage(ts uint64) *mqMessageKey {
 	}
 }
 
-func rowEventToMqMessage(e *model.RowChangedEvent) (*mqMessageKey, *mqMessageRow) {
+func rowEventToMqMessageKey(e *model.RowChangedEvent) mqMessageKey {
 	var partition *int64
 	if e.Table.IsPartition {
 		partition = &e.Table.TableID
 	}
-	key := &mqMessageKey{
+	return mqMessageKey{
 		Ts:        e.CommitTs,
 		Schema:    e.Table.Schema,
 		Table:     e.Table.Table,
@@ -207,6 +244,10 @@ func rowEventToMqMessage(e *model.RowChangedEvent) (*mqMessageKey, *mqMessageRow
 		Partition: partition,
 		Type:      model.MqMessageTypeRow,
 	}
+}
+
+func rowEventToMqMessage(e *model.RowChangedEvent) (*mqMessageKey, *mqMessageRow) {
+	key := rowEventToMqMessageKey(e)
 	value := &mqMessageRow{}
 	if e.IsDelete() {
 		value.Delete = sinkColumns2JsonColumns(e.PreColumns)
@@ -214,7 +255,87 @@ func rowEventToMqMessage(e *model.RowChangedEvent) (*mqMessageKey, *mqMessageRow
 		value.Update = sinkColumns2JsonColumns(e.Columns)
 		value.PreColumns = sinkColumns2JsonColumns(e.PreColumns)
 	}
-	return key, value
+	return &key, value
+}
+
+// writeRowEventValue writes the value of the row event without building the
+// mqMessageRow, the output is the same as mqMessageRow.Encode.
+func writeRowEventValue(w *jsonWriter, e *model.RowChangedEvent) error {
+	w.objectStart()
+	if e.IsDelete() {
+		if err := writeJSONColumns(w, "d", e.PreColumns); err != nil {
+			return err
+		}
+	} else {
+		if err := writeJSONColumns(w, "u", e.Columns); err != nil {
+			return err
+		}
+		if err := writeJSONColumns(w, "p", e.PreColumns); err != nil {
+			return err
+		}
+	}
+	w.objectEnd()
+	return nil
+}
+
+// writeJSONColumns writes the columns as a map of column names to columns,
+// nothing is written if there are no columns.
+func writeJSONColumns(w *jsonWriter, key string, cols []*model.Column) error {
+	sorted := w.columns[:0]
+	for _, col := range cols {
+		if col != nil {
+			sorted = append(sorted, col)
+		}
+	}
+	w.columns = sorted
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.Sort(&w.columns)
+	w.key(key)
+	w.objectStart()
+	for _, col := range w.columns {
+		w.key(col.Name)
+		if err := writeJSONColumn(w, col); err != nil {
+			return err
+		}
+	}
+	w.objectEnd()
+	return nil
+}
+
+// writeJSONColumn writes the column in the same format as json.Marshal the
+// column built by column.FromSinkColumn.
+func writeJSONColumn(w *jsonWriter, col *model.Column) error {
+	w.objectStart()
+	w.key("t")
+	w.uint64(uint64(col.Type))
+	if col.Flag.IsHandleKey() {
+		w.key("h")
+		w.bool(true)
+	}
+	w.key("f")
+	w.uint64(uint64(col.Flag))
+	w.key("v")
+	switch col.Type {
+	case mysql.TypeString, mysql.TypeVarString, mysql.TypeVarchar:
+		if v, ok := col.Value.([]byte); ok {
+			if col.Flag.IsBinary() {
+				w.scratch = strconv.AppendQuote(w.scratch[:0], string(v))
+				w.stringBytes(w.scratch[1 : len(w.scratch)-1])
+			} else {
+				w.stringBytes(v)
+			}
+			break
+		}
+		fallthrough
+	default:
+		if err := w.value(col.Value); err != nil {
+			return err
+		}
+	}
+	w.objectEnd()
+	return nil
 }
 
 func sinkColumns2JsonColumns(cols []*model.Column) map[string]column {
@@ -335,7 +456,22 @@ func (d *JSONEventBatchEncoder) AppendResolvedEvent(ts uint64) (EncoderResult, e
 
 // EncodeCheckpointEvent implements the EventBatchEncoder interface
 func (d *JSONEventBatchEncoder) EncodeCheckpointEvent(ts uint64) (*MQMessage, error) {
-	keyMsg := newResolvedMessage(ts)
+	return d.encodeKeyOnlyMessage(newResolvedMessage(ts))
+}
+
+// EncodeHeartbeatEvent implements the HeartbeatEncoder interface
+func (d *JSONEventBatchEncoder) EncodeHeartbeatEvent(table *model.TableName, ts uint64) (*MQMessage, error) {
+	return d.encodeKeyOnlyMessage(&mqMessageKey{
+		Ts:     ts,
+		Schema: table.Schema,
+		Table:  table.Table,
+		Type:   model.MqMessageTypeHeartbeat,
+	})
+}
+
+// encodeKeyOnlyMessage encodes a message with an empty value, such as the
+// resolved and heartbeat messages.
+func (d *JSONEventBatchEncoder) encodeKeyOnlyMessage(keyMsg *mqMessageKey) (*MQMessage, error) {
 	key, err := keyMsg.Encode()
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -363,21 +499,21 @@ func (d *JSONEventBatchEncoder) EncodeCheckpointEvent(ts uint64) (*MQMessage, er
 	valueBuf := new(bytes.Buffer)
 	valueBuf.Write(valueLenByte[:])
 
-	ret := NewMQMessage(keyBuf.Bytes(), valueBuf.Bytes(), ts)
+	ret := NewMQMessage(keyBuf.Bytes(), valueBuf.Bytes(), keyMsg.Ts)
 	return ret, nil
 }
 
 // AppendRowChangedEvent implements the EventBatchEncoder interface
 func (d *JSONEventBatchEncoder) AppendRowChangedEvent(e *model.RowChangedEvent) (EncoderResult, error) {
-	keyMsg, valueMsg := rowEventToMqMessage(e)
-	key, err := keyMsg.Encode()
-	if err != nil {
-		return EncoderNoOperation, errors.Trace(err)
-	}
-	value, err := valueMsg.Encode()
-	if err != nil {
-		return EncoderNoOperation, errors.Trace(err)
+	keyWriter, valueWriter := getJSONWriter(), getJSONWriter()
+	defer putJSONWriter(keyWriter)
+	defer putJSONWriter(valueWriter)
+	keyMsg := rowEventToMqMessageKey(e)
+	keyMsg.writeJSON(keyWriter)
+	if err := writeRowEventValue(valueWriter, e); err != nil {
+		return EncoderNoOperation, cerror.WrapError(cerror.ErrMarshalFailed, err)
 	}
+	key, value := keyWriter.buf, valueWriter.buf
 
 	var keyLenByte [8]byte
 	binary.BigEndian.PutUint64(keyLenByte[:], uint64(len(key)))
@@ -604,6 +740,25 @@ func (b *JSONEventBatchMixedDecoder) NextResolvedEvent() (uint64, error) {
 	return resolvedTs, nil
 }
 
+// NextHeartbeatEvent implements the HeartbeatDecoder interface
+func (b *JSONEventBatchMixedDecoder) NextHeartbeatEvent() (*model.TableName, uint64, error) {
+	if b.nextKey == nil {
+		if err := b.decodeNextKey(); err != nil {
+			return nil, 0, err
+		}
+	}
+	b.mixedBytes = b.mixedBytes[b.nextKeyLen+8:]
+	if b.nextKey.Type != model.MqMessageTypeHeartbeat {
+		return nil, 0, cerror.ErrJSONCodecInvalidData.GenWithStack("not found heartbeat event message")
+	}
+	valueLen := binary.BigEndian.Uint64(b.mixedBytes[:8])
+	b.mixedBytes = b.mixedBytes[valueLen+8:]
+	table := &model.TableName{Schema: b.nextKey.Schema, Table: b.nextKey.Table}
+	ts := b.nextKey.Ts
+	b.nextKey = nil
+	return table, ts, nil
+}
+
 // NextRowChangedEvent implements the EventBatchDecoder interface
 func (b *JSONEventBatchMixedDecoder) NextRowChangedEvent() (*model.RowChangedEvent, error) {
 	if b.nextKey == nil {
@@ -705,6 +860,25 @@ func (b *JSONEventBatchDecoder) NextResolvedEvent() (uint64, error) {
 	return resolvedTs, nil
 }
 
+// NextHeartbeatEvent implements the HeartbeatDecoder interface
+func (b *JSONEventBatchDecoder) NextHeartbeatEvent() (*model.TableName, uint64, error) {
+	if b.nextKey == nil {
+		if err := b.decodeNextKey(); err != nil {
+			return nil, 0, err
+		}
+	}
+	b.keyBytes = b.keyBytes[b.nextKeyLen+8:]
+	if b.nextKey.Type != model.MqMessageTypeHeartbeat {
+		return nil, 0, cerror.ErrJSONCodecInvalidData.GenWithStack("not found heartbeat event message")
+	}
+	valueLen := binary.BigEndian.Uint64(b.valueBytes[:8])
+	b.valueBytes = b.valueBytes[valueLen+8:]
+	table := &model.TableName{Schema: b.nextKey.Schema, Table: b.nextKey.Table}
+	ts := b.nextKey.Ts
+	b.nextKey = nil
+	return table, ts, nil
+}
+
 // NextRowChangedEvent implements the EventBatchDecoder interface
 func (b *JSONEventBatchDecoder) NextRowChangedEvent() (*model.RowChangedEvent, error) {
 	if b.nextKey == nil {
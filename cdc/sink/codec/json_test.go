@@ -336,6 +336,34 @@ func (s *batchSuite) TestDefaultEventBatchCodec(c *check.C) {
 	}, NewJSONEventBatchDecoder)
 }
 
+func (s *batchSuite) TestHeartbeatEvent(c *check.C) {
+	defer testleak.AfterTest(c)()
+	table := &model.TableName{Schema: "test", Table: "t1"}
+	encoder := NewJSONEventBatchEncoder().(HeartbeatEncoder)
+	msg, err := encoder.EncodeHeartbeatEvent(table, 417318403368288260)
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.Ts, check.Equals, uint64(417318403368288260))
+	decoder, err := NewJSONEventBatchDecoder(msg.Key, msg.Value)
+	c.Assert(err, check.IsNil)
+	tp, hasNext, err := decoder.HasNext()
+	c.Assert(err, check.IsNil)
+	c.Assert(hasNext, check.IsTrue)
+	c.Assert(tp, check.Equals, model.MqMessageTypeHeartbeat)
+	decodedTable, ts, err := decoder.(HeartbeatDecoder).NextHeartbeatEvent()
+	c.Assert(err, check.IsNil)
+	c.Assert(decodedTable, check.DeepEquals, table)
+	c.Assert(ts, check.Equals, uint64(417318403368288260))
+	_, hasNext, err = decoder.HasNext()
+	c.Assert(err, check.IsNil)
+	c.Assert(hasNext, check.IsFalse)
+
+	// the heartbeat is not a resolved event
+	decoder, err = NewJSONEventBatchDecoder(msg.Key, msg.Value)
+	c.Assert(err, check.IsNil)
+	_, err = decoder.NextResolvedEvent()
+	c.Assert(err, check.ErrorMatches, ".*not found resolved event message.*")
+}
+
 var _ = check.Suite(&columnSuite{})
 
 type columnSuite struct{}
@@ -0,0 +1,370 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pingcap/ticdc/cdc/model"
+)
+
+// maxPooledJSONWriterSize is the max size of the buffer kept by a pooled
+// jsonWriter, the larger buffers are dropped to avoid pinning the memory.
+const maxPooledJSONWriterSize = 1024 * 1024
+
+var jsonWriterPool = sync.Pool{
+	New: func() interface{} {
+		return &jsonWriter{buf: make([]byte, 0, 1024)}
+	},
+}
+
+func getJSONWriter() *jsonWriter {
+	return jsonWriterPool.Get().(*jsonWriter)
+}
+
+func putJSONWriter(w *jsonWriter) {
+	if cap(w.buf) > maxPooledJSONWriterSize {
+		return
+	}
+	w.reset()
+	jsonWriterPool.Put(w)
+}
+
+// jsonWriter appends JSON to a reusable buffer without reflection. The output
+// is the same as the output of encoding/json, including the order of the map
+// keys and the escaping of the strings.
+type jsonWriter struct {
+	buf []byte
+	// needComma records whether a comma is needed before the next element,
+	// one for each nesting level
+	needComma []bool
+	// afterKey is set if a key is just written, the value needs no comma
+	afterKey bool
+	// scratch, keys and columns are reused to avoid allocations
+	scratch []byte
+	keys    []string
+	columns columnsByName
+}
+
+func (w *jsonWriter) reset() {
+	w.buf = w.buf[:0]
+	w.needComma = w.needComma[:0]
+	w.afterKey = false
+	w.scratch = w.scratch[:0]
+	// drop the references to the encoded values
+	for i := range w.keys {
+		w.keys[i] = ""
+	}
+	w.keys = w.keys[:0]
+	for i := range w.columns {
+		w.columns[i] = nil
+	}
+	w.columns = w.columns[:0]
+}
+
+// sortedKeys returns the keys of the map in the order of encoding/json, the
+// keys are valid until the next call.
+func (w *jsonWriter) sortedKeys(m map[string]interface{}) []string {
+	w.keys = w.keys[:0]
+	for k := range m {
+		w.keys = append(w.keys, k)
+	}
+	sort.Strings(w.keys)
+	return w.keys
+}
+
+// bytes returns a copy of the written JSON.
+func (w *jsonWriter) bytes() []byte {
+	data := make([]byte, len(w.buf))
+	copy(data, w.buf)
+	return data
+}
+
+func (w *jsonWriter) element() {
+	if w.afterKey {
+		w.afterKey = false
+		return
+	}
+	n := len(w.needComma)
+	if n == 0 {
+		return
+	}
+	if w.needComma[n-1] {
+		w.buf = append(w.buf, ',')
+	}
+	w.needComma[n-1] = true
+}
+
+func (w *jsonWriter) objectStart() {
+	w.element()
+	w.buf = append(w.buf, '{')
+	w.needComma = append(w.needComma, false)
+}
+
+func (w *jsonWriter) objectEnd() {
+	w.buf = append(w.buf, '}')
+	w.needComma = w.needComma[:len(w.needComma)-1]
+}
+
+func (w *jsonWriter) arrayStart() {
+	w.element()
+	w.buf = append(w.buf, '[')
+	w.needComma = append(w.needComma, false)
+}
+
+func (w *jsonWriter) arrayEnd() {
+	w.buf = append(w.buf, ']')
+	w.needComma = w.needComma[:len(w.needComma)-1]
+}
+
+// key writes the key of an object field, the value must be written next.
+func (w *jsonWriter) key(name string) {
+	w.element()
+	w.buf = appendJSONString(w.buf, name)
+	w.buf = append(w.buf, ':')
+	w.afterKey = true
+}
+
+func (w *jsonWriter) null() {
+	w.element()
+	w.buf = append(w.buf, "null"...)
+}
+
+func (w *jsonWriter) bool(v bool) {
+	w.element()
+	w.buf = strconv.AppendBool(w.buf, v)
+}
+
+func (w *jsonWriter) int64(v int64) {
+	w.element()
+	w.buf = strconv.AppendInt(w.buf, v, 10)
+}
+
+func (w *jsonWriter) uint64(v uint64) {
+	w.element()
+	w.buf = strconv.AppendUint(w.buf, v, 10)
+}
+
+func (w *jsonWriter) string(v string) {
+	w.element()
+	w.buf = appendJSONString(w.buf, v)
+}
+
+// stringBytes writes the bytes as a JSON string, it's the same as
+// w.string(string(v)) without converting the bytes.
+func (w *jsonWriter) stringBytes(v []byte) {
+	w.element()
+	w.buf = appendJSONBytesString(w.buf, v)
+}
+
+func (w *jsonWriter) float(v float64, bits int) error {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return &json.UnsupportedValueError{Str: strconv.FormatFloat(v, 'g', -1, bits)}
+	}
+	w.element()
+	w.buf = appendJSONFloat(w.buf, v, bits)
+	return nil
+}
+
+// base64 writes the bytes as a base64 encoded string, which is how
+// encoding/json encodes []byte.
+func (w *jsonWriter) base64(v []byte) {
+	w.element()
+	w.buf = append(w.buf, '"')
+	n := len(w.buf)
+	size := base64.StdEncoding.EncodedLen(len(v))
+	if cap(w.buf)-n < size {
+		grown := make([]byte, n, 2*cap(w.buf)+size)
+		copy(grown, w.buf)
+		w.buf = grown
+	}
+	w.buf = w.buf[:n+size]
+	base64.StdEncoding.Encode(w.buf[n:], v)
+	w.buf = append(w.buf, '"')
+}
+
+// value writes the value of an interface, the uncommon types are encoded by
+// encoding/json.
+func (w *jsonWriter) value(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		w.null()
+	case string:
+		w.string(v)
+	case []byte:
+		if v == nil {
+			w.null()
+		} else {
+			w.base64(v)
+		}
+	case int64:
+		w.int64(v)
+	case uint64:
+		w.uint64(v)
+	case int:
+		w.int64(int64(v))
+	case int32:
+		w.int64(int64(v))
+	case float64:
+		return w.float(v, 64)
+	case float32:
+		return w.float(float64(v), 32)
+	case bool:
+		w.bool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.element()
+		w.buf = append(w.buf, data...)
+	}
+	return nil
+}
+
+// appendJSONFloat is the same as the float encoder of encoding/json.
+func appendJSONFloat(b []byte, f float64, bits int) []byte {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+const hexDigits = "0123456789abcdef"
+
+// jsonSafe reports whether the ASCII byte can be written in a JSON string
+// without escaping, the HTML characters are escaped like encoding/json.
+func jsonSafe(b byte) bool {
+	return b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&'
+}
+
+// appendJSONString is the same as the string encoder of encoding/json.
+func appendJSONString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if jsonSafe(c) {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			b = appendJSONEscapedByte(b, c)
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are escaped for JSONP
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, `\u202`...)
+			b = append(b, hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
+
+// appendJSONBytesString is appendJSONString for bytes.
+func appendJSONBytesString(b []byte, s []byte) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if jsonSafe(c) {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			b = appendJSONEscapedByte(b, c)
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, `\u202`...)
+			b = append(b, hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
+
+func appendJSONEscapedByte(b []byte, c byte) []byte {
+	switch c {
+	case '\\', '"':
+		return append(b, '\\', c)
+	case '\n':
+		return append(b, '\\', 'n')
+	case '\r':
+		return append(b, '\\', 'r')
+	case '\t':
+		return append(b, '\\', 't')
+	default:
+		// the control characters and the HTML characters
+		return append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+	}
+}
+
+// columnsByName sorts the columns by name, which is the order of the keys of
+// a map encoded by encoding/json.
+type columnsByName []*model.Column
+
+func (c *columnsByName) Len() int           { return len(*c) }
+func (c *columnsByName) Less(i, j int) bool { return (*c)[i].Name < (*c)[j].Name }
+func (c *columnsByName) Swap(i, j int)      { (*c)[i], (*c)[j] = (*c)[j], (*c)[i] }
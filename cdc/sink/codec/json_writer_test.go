@@ -0,0 +1,202 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type jsonWriterSuite struct{}
+
+var _ = check.Suite(&jsonWriterSuite{})
+
+var jsonWriterTestRows = []*model.RowChangedEvent{{
+	CommitTs: 417318403368288260,
+	RowID:    100,
+	Table:    &model.TableName{Schema: "test", Table: "t<1>", TableID: 47, IsPartition: true},
+	Columns: []*model.Column{
+		{Name: "id", Type: mysql.TypeLonglong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: int64(-1)},
+		nil,
+		{Name: "name", Type: mysql.TypeVarchar, Value: []byte("a\"b\\c\n<&> 中文")},
+		{Name: "bin", Type: mysql.TypeString, Flag: model.BinaryFlag, Value: []byte{0, 1, 'a', 0xff, '"'}},
+		{Name: "empty", Type: mysql.TypeVarString, Value: []byte{}},
+		{Name: "blob", Type: mysql.TypeBlob, Value: []byte("blob\x00value")},
+		{Name: "unsigned", Type: mysql.TypeLonglong, Flag: model.UnsignedFlag, Value: uint64(math.MaxUint64)},
+		{Name: "double", Type: mysql.TypeDouble, Value: 3.1415926},
+		{Name: "tiny_double", Type: mysql.TypeDouble, Value: 1e-9},
+		{Name: "float", Type: mysql.TypeFloat, Value: float32(0.1)},
+		{Name: "decimal", Type: mysql.TypeNewDecimal, Value: "12345.6789"},
+		{Name: "null", Type: mysql.TypeVarchar, Value: nil},
+		{Name: "Upper", Type: mysql.TypeLong, Value: int64(1)},
+	},
+	PreColumns: []*model.Column{
+		{Name: "id", Type: mysql.TypeLonglong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: int64(-1)},
+		{Name: "name", Type: mysql.TypeVarchar, Value: []byte("old")},
+	},
+}, {
+	CommitTs: 1,
+	Table:    &model.TableName{Schema: "test", Table: "t"},
+	PreColumns: []*model.Column{
+		{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag, Value: int64(1)},
+		{Name: "json", Type: mysql.TypeJSON, Value: `{"key": "value"}`},
+	},
+}, {
+	CommitTs: 2,
+	Table:    &model.TableName{Schema: "test", Table: "t"},
+	Columns:  []*model.Column{nil},
+}}
+
+func (s *jsonWriterSuite) TestOpenProtocol(c *check.C) {
+	defer testleak.AfterTest(c)()
+	for _, row := range jsonWriterTestRows {
+		keyMsg, valueMsg := rowEventToMqMessage(row)
+		expectedKey, err := json.Marshal(keyMsg)
+		c.Assert(err, check.IsNil)
+		expectedValue, err := valueMsg.Encode()
+		c.Assert(err, check.IsNil)
+
+		key, err := keyMsg.Encode()
+		c.Assert(err, check.IsNil)
+		c.Assert(string(key), check.Equals, string(expectedKey))
+		w := getJSONWriter()
+		c.Assert(writeRowEventValue(w, row), check.IsNil)
+		c.Assert(string(w.buf), check.Equals, string(expectedValue))
+		putJSONWriter(w)
+	}
+
+	_, ddlMsg := ddlEventtoMqMessage(&model.DDLEvent{
+		CommitTs:  1,
+		TableInfo: &model.SimpleTableInfo{Schema: "test", Table: "t"},
+		Query:     "create table t (a varchar(10) default '<>')",
+		Type:      3,
+	})
+	expected, err := json.Marshal(ddlMsg)
+	c.Assert(err, check.IsNil)
+	value, err := ddlMsg.Encode()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(value), check.Equals, string(expected))
+
+	w := getJSONWriter()
+	defer putJSONWriter(w)
+	c.Assert(w.value(math.NaN()), check.NotNil)
+}
+
+func (s *jsonWriterSuite) TestCanalFlat(c *check.C) {
+	defer testleak.AfterTest(c)()
+	encoder := &CanalFlatEventBatchEncoder{builder: NewCanalEntryBuilder()}
+	deleteRow := *testCaseUpdate
+	deleteRow.Columns = nil
+	var msgs []*canalFlatMessage
+	for _, row := range []*model.RowChangedEvent{testCaseUpdate, &deleteRow} {
+		msg, err := encoder.newFlatMessageForDML(row)
+		c.Assert(err, check.IsNil)
+		msgs = append(msgs, msg)
+	}
+	msg, err := encoder.newFlatMessageForDDL(testCaseDdl)
+	c.Assert(err, check.IsNil)
+	msgs = append(msgs, msg)
+
+	for _, msg := range msgs {
+		expected, err := json.Marshal(msg)
+		c.Assert(err, check.IsNil)
+		value, err := msg.encode()
+		c.Assert(err, check.IsNil)
+		c.Assert(string(value), check.Equals, string(expected))
+	}
+}
+
+// newBenchmarkRow returns a row with the given number of columns.
+func newBenchmarkRow(columns int) *model.RowChangedEvent {
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+	}
+	for i := 0; i < columns; i++ {
+		col := &model.Column{Name: fmt.Sprintf("col%d", i)}
+		switch i % 3 {
+		case 0:
+			col.Type, col.Value = mysql.TypeLonglong, int64(i)
+		case 1:
+			col.Type, col.Value = mysql.TypeVarchar, []byte(fmt.Sprintf("value of column %d", i))
+		case 2:
+			col.Type, col.Value = mysql.TypeDouble, float64(i)/3
+		}
+		if i == 0 {
+			col.Flag = model.HandleKeyFlag | model.PrimaryKeyFlag
+		}
+		row.Columns = append(row.Columns, col)
+	}
+	return row
+}
+
+func BenchmarkOpenProtocolEncode(b *testing.B) {
+	row := newBenchmarkRow(32)
+	b.Run("encoding-json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			keyMsg, valueMsg := rowEventToMqMessage(row)
+			if _, err := json.Marshal(keyMsg); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := valueMsg.Encode(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("json-writer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			keyWriter, valueWriter := getJSONWriter(), getJSONWriter()
+			keyMsg := rowEventToMqMessageKey(row)
+			keyMsg.writeJSON(keyWriter)
+			if err := writeRowEventValue(valueWriter, row); err != nil {
+				b.Fatal(err)
+			}
+			putJSONWriter(keyWriter)
+			putJSONWriter(valueWriter)
+		}
+	})
+}
+
+func BenchmarkCanalFlatEncode(b *testing.B) {
+	encoder := &CanalFlatEventBatchEncoder{builder: NewCanalEntryBuilder()}
+	msg, err := encoder.newFlatMessageForDML(newBenchmarkRow(32))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("encoding-json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := json.Marshal(msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("json-writer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := msg.encode(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"strings"
+
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+// MessageKeySetter is implemented by the encoders whose message keys can be
+// built by a MessageKeyBuilder, they send a message per row.
+type MessageKeySetter interface {
+	// SetMessageKeyBuilder makes the encoder build the keys of the row
+	// messages by the builder, an error is returned if the encoder can't
+	// encode the keys of the type of the builder.
+	SetMessageKeyBuilder(builder *MessageKeyBuilder) error
+}
+
+// LogCompactionSetter is implemented by the encoders which can send the
+// deleted rows as tombstones, so their output can be kept in a log-compacted
+// topic.
+type LogCompactionSetter interface {
+	// EnableLogCompaction makes the encoder send a tombstone, i.e. a message
+	// with the key of the row and a null value, for each deleted row.
+	EnableLogCompaction()
+}
+
+// templatePart is a literal or a placeholder of a message key template.
+type templatePart struct {
+	literal string
+	// placeholder is the name in the braces, such as "table" or "column:id"
+	placeholder string
+}
+
+// MessageKeyBuilder builds the message keys of the rows by a
+// MessageKeyConfig, independently of the partition dispatcher.
+type MessageKeyBuilder struct {
+	tp       string
+	template []templatePart
+}
+
+// NewMessageKeyBuilder creates a MessageKeyBuilder.
+func NewMessageKeyBuilder(cfg *config.MessageKeyConfig) (*MessageKeyBuilder, error) {
+	b := &MessageKeyBuilder{tp: cfg.Type}
+	switch cfg.Type {
+	case config.MessageKeyHandleKey, config.MessageKeyUniqueKeys, config.MessageKeyTable:
+		if cfg.Template != "" {
+			return nil, cerror.ErrKafkaInvalidConfig.GenWithStack(
+				"the message key template is only supported by the type %s", config.MessageKeyTemplate)
+		}
+	case config.MessageKeyTemplate:
+		template, err := parseMessageKeyTemplate(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+		b.template = template
+	default:
+		return nil, cerror.ErrKafkaInvalidConfig.GenWithStack("unknown message key type %q", cfg.Type)
+	}
+	return b, nil
+}
+
+func parseMessageKeyTemplate(template string) ([]templatePart, error) {
+	if template == "" {
+		return nil, cerror.ErrKafkaInvalidConfig.GenWithStack("the message key template is empty")
+	}
+	var parts []templatePart
+	rest := template
+	for rest != "" {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			parts = append(parts, templatePart{literal: rest})
+			break
+		}
+		if start > 0 {
+			parts = append(parts, templatePart{literal: rest[:start]})
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, cerror.ErrKafkaInvalidConfig.GenWithStack("unclosed placeholder in the message key template %q", template)
+		}
+		placeholder := rest[start+1 : start+end]
+		switch {
+		case placeholder == "schema", placeholder == "table", placeholder == "handle-key":
+		case strings.HasPrefix(placeholder, "column:") && len(placeholder) > len("column:"):
+		default:
+			return nil, cerror.ErrKafkaInvalidConfig.GenWithStack(
+				"unknown placeholder {%s} in the message key template %q", placeholder, template)
+		}
+		parts = append(parts, templatePart{placeholder: placeholder})
+		rest = rest[start+end+1:]
+	}
+	return parts, nil
+}
+
+// Type returns the type of the message keys.
+func (b *MessageKeyBuilder) Type() string {
+	return b.tp
+}
+
+// rowColumns returns the columns identifying the row, the old values are used
+// for the deleted rows.
+func rowColumns(e *model.RowChangedEvent) []*model.Column {
+	if e.IsDelete() {
+		return e.PreColumns
+	}
+	return e.Columns
+}
+
+// KeyColumns returns the columns put in the key of the row, it's nil if the
+// keys aren't made of the columns.
+func (b *MessageKeyBuilder) KeyColumns(e *model.RowChangedEvent) []*model.Column {
+	switch b.tp {
+	case config.MessageKeyHandleKey:
+		return e.HandleKeyColumns()
+	case config.MessageKeyUniqueKeys:
+		var keyCols []*model.Column
+		for _, col := range rowColumns(e) {
+			if col != nil && (col.Flag.IsPrimaryKey() || col.Flag.IsUniqueKey()) {
+				keyCols = append(keyCols, col)
+			}
+		}
+		if len(keyCols) == 0 {
+			// the table without any primary key or unique key is identified
+			// by its handle
+			return e.HandleKeyColumns()
+		}
+		return keyCols
+	}
+	return nil
+}
+
+// Build builds the key of the row.
+func (b *MessageKeyBuilder) Build(e *model.RowChangedEvent) ([]byte, error) {
+	switch b.tp {
+	case config.MessageKeyHandleKey, config.MessageKeyUniqueKeys:
+		w := getJSONWriter()
+		defer putJSONWriter(w)
+		w.objectStart()
+		for _, col := range b.KeyColumns(e) {
+			w.key(col.Name)
+			if err := w.value(col.Value); err != nil {
+				return nil, cerror.WrapError(cerror.ErrMessageKeyBuildFailed, err)
+			}
+		}
+		w.objectEnd()
+		return w.bytes(), nil
+	case config.MessageKeyTable:
+		return []byte(e.Table.Schema + "." + e.Table.Table), nil
+	}
+
+	var key strings.Builder
+	for _, part := range b.template {
+		switch {
+		case part.placeholder == "":
+			key.WriteString(part.literal)
+		case part.placeholder == "schema":
+			key.WriteString(e.Table.Schema)
+		case part.placeholder == "table":
+			key.WriteString(e.Table.Table)
+		case part.placeholder == "handle-key":
+			for i, col := range e.HandleKeyColumns() {
+				if i > 0 {
+					key.WriteByte(',')
+				}
+				key.WriteString(model.ColumnValueString(col.Value))
+			}
+		default:
+			name := strings.TrimPrefix(part.placeholder, "column:")
+			col := findColumn(rowColumns(e), name)
+			if col == nil {
+				return nil, cerror.ErrMessageKeyBuildFailed.GenWithStack(
+					"column %s is not found in the row of table %s", name, e.Table)
+			}
+			key.WriteString(model.ColumnValueString(col.Value))
+		}
+	}
+	return []byte(key.String()), nil
+}
+
+func findColumn(cols []*model.Column, name string) *model.Column {
+	for _, col := range cols {
+		if col != nil && col.Name == name {
+			return col
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type messageKeySuite struct{}
+
+var _ = check.Suite(&messageKeySuite{})
+
+var testCaseKeyedRow = &model.RowChangedEvent{
+	CommitTs: 417318403368288260,
+	Table: &model.TableName{
+		Schema: "test",
+		Table:  "t",
+	},
+	Columns: []*model.Column{
+		{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 1},
+		{Name: "code", Type: mysql.TypeVarchar, Flag: model.UniqueKeyFlag, Value: "a1"},
+		{Name: "name", Type: mysql.TypeVarchar, Value: "Bob"},
+	},
+}
+
+func (s *messageKeySuite) TestBuildMessageKey(c *check.C) {
+	defer testleak.AfterTest(c)()
+	deleteRow := *testCaseKeyedRow
+	deleteRow.PreColumns, deleteRow.Columns = deleteRow.Columns, nil
+
+	testCases := []struct {
+		cfg config.MessageKeyConfig
+		key string
+	}{
+		{config.MessageKeyConfig{Type: config.MessageKeyHandleKey}, `{"id":1}`},
+		{config.MessageKeyConfig{Type: config.MessageKeyUniqueKeys}, `{"id":1,"code":"a1"}`},
+		{config.MessageKeyConfig{Type: config.MessageKeyTable}, "test.t"},
+		{config.MessageKeyConfig{
+			Type:     config.MessageKeyTemplate,
+			Template: "{schema}.{table}:{handle-key}/{column:name}",
+		}, "test.t:1/Bob"},
+		{config.MessageKeyConfig{Type: config.MessageKeyTemplate, Template: "static"}, "static"},
+	}
+	for _, tc := range testCases {
+		builder, err := NewMessageKeyBuilder(&tc.cfg)
+		c.Assert(err, check.IsNil)
+		for _, row := range []*model.RowChangedEvent{testCaseKeyedRow, &deleteRow} {
+			key, err := builder.Build(row)
+			c.Assert(err, check.IsNil)
+			c.Assert(string(key), check.Equals, tc.key, check.Commentf("%v", tc.cfg))
+		}
+	}
+
+	builder, err := NewMessageKeyBuilder(&config.MessageKeyConfig{
+		Type:     config.MessageKeyTemplate,
+		Template: "{column:unknown}",
+	})
+	c.Assert(err, check.IsNil)
+	_, err = builder.Build(testCaseKeyedRow)
+	c.Assert(err, check.ErrorMatches, ".*column unknown is not found.*")
+}
+
+func (s *messageKeySuite) TestInvalidMessageKeyConfig(c *check.C) {
+	defer testleak.AfterTest(c)()
+	testCases := []struct {
+		cfg config.MessageKeyConfig
+		err string
+	}{
+		{config.MessageKeyConfig{Type: "unknown"}, ".*unknown message key type.*"},
+		{config.MessageKeyConfig{Type: config.MessageKeyTable, Template: "{table}"}, ".*only supported by the type template.*"},
+		{config.MessageKeyConfig{Type: config.MessageKeyTemplate}, ".*template is empty.*"},
+		{config.MessageKeyConfig{Type: config.MessageKeyTemplate, Template: "{table"}, ".*unclosed placeholder.*"},
+		{config.MessageKeyConfig{Type: config.MessageKeyTemplate, Template: "{ts}"}, ".*unknown placeholder.*"},
+		{config.MessageKeyConfig{Type: config.MessageKeyTemplate, Template: "{column:}"}, ".*unknown placeholder.*"},
+	}
+	for _, tc := range testCases {
+		_, err := NewMessageKeyBuilder(&tc.cfg)
+		c.Assert(err, check.ErrorMatches, tc.err, check.Commentf("%v", tc.cfg))
+	}
+
+	// the avro keys must be made of the columns
+	builder, err := NewMessageKeyBuilder(&config.MessageKeyConfig{Type: config.MessageKeyTable})
+	c.Assert(err, check.IsNil)
+	encoder := NewAvroEventBatchEncoder().(MessageKeySetter)
+	c.Assert(encoder.SetMessageKeyBuilder(builder), check.ErrorMatches, ".*not supported by the avro protocol.*")
+	_, ok := NewJSONEventBatchEncoder().(MessageKeySetter)
+	c.Assert(ok, check.IsFalse)
+}
+
+func (s *messageKeySuite) TestCanalFlatMessageKey(c *check.C) {
+	defer testleak.AfterTest(c)()
+	builder, err := NewMessageKeyBuilder(&config.MessageKeyConfig{Type: config.MessageKeyHandleKey})
+	c.Assert(err, check.IsNil)
+	encoder := NewCanalFlatEventBatchEncoder()
+	c.Assert(encoder.(MessageKeySetter).SetMessageKeyBuilder(builder), check.IsNil)
+
+	_, err = encoder.AppendRowChangedEvent(testCaseKeyedRow)
+	c.Assert(err, check.IsNil)
+	_, err = encoder.AppendResolvedEvent(testCaseKeyedRow.CommitTs)
+	c.Assert(err, check.IsNil)
+	msgs := encoder.Build()
+	c.Assert(msgs, check.HasLen, 1)
+	c.Assert(string(msgs[0].Key), check.Equals, `{"id":1}`)
+}
+
+func (s *messageKeySuite) TestCanalFlatTombstone(c *check.C) {
+	defer testleak.AfterTest(c)()
+	builder, err := NewMessageKeyBuilder(&config.MessageKeyConfig{Type: config.MessageKeyHandleKey})
+	c.Assert(err, check.IsNil)
+	encoder := NewCanalFlatEventBatchEncoder()
+	c.Assert(encoder.(MessageKeySetter).SetMessageKeyBuilder(builder), check.IsNil)
+	encoder.(LogCompactionSetter).EnableLogCompaction()
+
+	deleteRow := *testCaseKeyedRow
+	deleteRow.PreColumns, deleteRow.Columns = deleteRow.Columns, nil
+	for _, row := range []*model.RowChangedEvent{testCaseKeyedRow, &deleteRow} {
+		_, err = encoder.AppendRowChangedEvent(row)
+		c.Assert(err, check.IsNil)
+	}
+	_, err = encoder.AppendResolvedEvent(testCaseKeyedRow.CommitTs)
+	c.Assert(err, check.IsNil)
+	msgs := encoder.Build()
+	c.Assert(msgs, check.HasLen, 2)
+	c.Assert(msgs[0].Value, check.NotNil)
+	c.Assert(string(msgs[1].Key), check.Equals, `{"id":1}`)
+	c.Assert(msgs[1].Value, check.IsNil)
+
+	_, ok := NewJSONEventBatchEncoder().(LogCompactionSetter)
+	c.Assert(ok, check.IsFalse)
+}
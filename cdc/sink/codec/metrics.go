@@ -0,0 +1,32 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var registryRequestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "ticdc",
+		Subsystem: "sink",
+		Name:      "avro_registry_request_duration_seconds",
+		Help:      "Bucketed histogram of the duration of the requests to the Avro schema registry, including the retries.",
+		Buckets:   prometheus.ExponentialBuckets(0.001 /* 1 ms */, 2, 20),
+	}, []string{"method", "result"})
+
+// InitMetrics registers all metrics in this file
+func InitMetrics(registry *prometheus.Registry) {
+	registry.MustRegister(registryRequestDuration)
+}
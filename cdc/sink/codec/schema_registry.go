@@ -21,16 +21,18 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/cenkalti/backoff"
 	"github.com/linkedin/goavro/v2"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/model"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/ticdc/pkg/httputil"
+	"github.com/pingcap/ticdc/pkg/retry"
 	"github.com/pingcap/ticdc/pkg/security"
 	"go.uber.org/zap"
 )
@@ -39,11 +41,59 @@ import (
 // look up local cache according to the table's name, and fetch from the Registry
 // in cache the local cache entry is missing.
 type AvroSchemaManager struct {
-	registryURL   string
+	registryURL string
+	// cache caches the schemas of the tables, and registeredIDs caches the IDs
+	// of the registered schemas, so a schema isn't registered again if it's
+	// not changed by a DDL. They are shared by the encoders of the sink.
 	cache         map[string]*schemaCacheEntry
+	registeredIDs map[registeredSchema]int
+	cacheMu       sync.Mutex
 	subjectSuffix string
+	strategy      SubjectNameStrategy
+	// topic is the topic of the messages, it's used by the topic based strategies
+	topic string
 
-	credential *security.Credential
+	httpCli *httputil.Client
+
+	// idCache caches the schemas looked up by the registry designated ID, used by consumers
+	idCache   map[int]*idCacheEntry
+	idCacheMu sync.Mutex
+}
+
+// SubjectNameStrategy decides the subjects the schemas are registered under,
+// the strategies of the Confluent Schema Registry are supported besides the
+// default one.
+type SubjectNameStrategy string
+
+const (
+	// TableNameStrategy registers the schemas under `{schema}_{table}-key` and
+	// `{schema}_{table}-value`, and names the records by the tables.
+	TableNameStrategy SubjectNameStrategy = "TableName"
+	// TopicNameStrategy registers the schemas under `{topic}-key` and `{topic}-value`.
+	TopicNameStrategy SubjectNameStrategy = "TopicName"
+	// RecordNameStrategy registers the schemas under the full names of the records.
+	RecordNameStrategy SubjectNameStrategy = "RecordName"
+	// TopicRecordNameStrategy registers the schemas under `{topic}-{full name of the record}`.
+	TopicRecordNameStrategy SubjectNameStrategy = "TopicRecordName"
+)
+
+// ParseSubjectNameStrategy parses a subject name strategy, the Java class names
+// of the Confluent strategies are accepted as well.
+func ParseSubjectNameStrategy(s string) (SubjectNameStrategy, error) {
+	if i := strings.LastIndexByte(s, '.'); i >= 0 {
+		// such as io.confluent.kafka.serializers.subject.TopicNameStrategy
+		s = strings.TrimSuffix(s[i+1:], "Strategy")
+	}
+	switch strategy := SubjectNameStrategy(s); strategy {
+	case TableNameStrategy, TopicNameStrategy, RecordNameStrategy, TopicRecordNameStrategy:
+		return strategy, nil
+	}
+	return "", cerror.ErrPrepareAvroFailed.GenWithStack("unknown subject name strategy %s", s)
+}
+
+type registeredSchema struct {
+	subject string
+	schema  string
 }
 
 type schemaCacheEntry struct {
@@ -52,6 +102,11 @@ type schemaCacheEntry struct {
 	codec      *goavro.Codec
 }
 
+type idCacheEntry struct {
+	tableName model.TableName
+	codec     *goavro.Codec
+}
+
 type registerRequest struct {
 	Schema string `json:"schema"`
 	// Commented out for compatibility with Confluent 5.4.x
@@ -68,13 +123,21 @@ type lookupResponse struct {
 	Schema     string `json:"schema"`
 }
 
-// NewAvroSchemaManager creates a new AvroSchemaManager
+type lookupByIDResponse struct {
+	Schema string `json:"schema"`
+}
+
+type subjectVersion struct {
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// NewAvroSchemaManager creates a new AvroSchemaManager, the username and the
+// password in the registry URL are sent by the basic authentication.
 func NewAvroSchemaManager(
 	ctx context.Context, credential *security.Credential, registryURL string, subjectSuffix string,
 ) (*AvroSchemaManager, error) {
-	registryURL = strings.TrimRight(registryURL, "/")
-	// Test connectivity to the Schema Registry
-	req, err := http.NewRequestWithContext(ctx, "GET", registryURL, nil)
+	u, err := url.Parse(strings.TrimRight(registryURL, "/"))
 	if err != nil {
 		return nil, cerror.WrapError(cerror.ErrAvroSchemaAPIError, err)
 	}
@@ -82,10 +145,22 @@ func NewAvroSchemaManager(
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	resp, err := httpCli.Do(req)
+	if u.User != nil {
+		password, _ := u.User.Password()
+		httpCli.SetBasicAuth(u.User.Username(), password)
+		// keep the password out of the logs
+		u.User = nil
+	}
+	registryURL = u.String()
+
+	// Test connectivity to the Schema Registry
+	req, err := http.NewRequestWithContext(ctx, "GET", registryURL, nil)
 	if err != nil {
-		return nil, errors.Annotate(
-			cerror.WrapError(cerror.ErrAvroSchemaAPIError, err), "Test connection to Schema Registry failed")
+		return nil, cerror.WrapError(cerror.ErrAvroSchemaAPIError, err)
+	}
+	resp, err := httpRetry(ctx, httpCli, req, false)
+	if err != nil {
+		return nil, errors.Annotate(err, "Test connection to Schema Registry failed")
 	}
 	defer resp.Body.Close()
 
@@ -95,7 +170,7 @@ func NewAvroSchemaManager(
 			cerror.WrapError(cerror.ErrAvroSchemaAPIError, err), "Reading response from Schema Registry failed")
 	}
 
-	if string(text[:]) != "{}" {
+	if resp.StatusCode != 200 || string(text[:]) != "{}" {
 		return nil, cerror.ErrAvroSchemaAPIError.GenWithStack("Unexpected response from Schema Registry")
 	}
 
@@ -104,11 +179,25 @@ func NewAvroSchemaManager(
 	return &AvroSchemaManager{
 		registryURL:   registryURL,
 		cache:         make(map[string]*schemaCacheEntry, 1),
+		registeredIDs: make(map[registeredSchema]int),
 		subjectSuffix: subjectSuffix,
-		credential:    credential,
+		strategy:      TableNameStrategy,
+		httpCli:       httpCli,
+		idCache:       make(map[int]*idCacheEntry),
 	}, nil
 }
 
+// SetSubjectNameStrategy sets the subject name strategy, the topic is required
+// by the topic based strategies.
+func (m *AvroSchemaManager) SetSubjectNameStrategy(strategy SubjectNameStrategy, topic string) error {
+	if topic == "" && (strategy == TopicNameStrategy || strategy == TopicRecordNameStrategy) {
+		return cerror.ErrPrepareAvroFailed.GenWithStack("the subject name strategy %s requires a topic", strategy)
+	}
+	m.strategy = strategy
+	m.topic = topic
+	return nil
+}
+
 var regexRemoveSpaces = regexp.MustCompile(`\s`)
 
 // Register the latest schema for a table to the Registry, by passing in a Codec
@@ -133,7 +222,7 @@ func (m *AvroSchemaManager) Register(ctx context.Context, tableName model.TableN
 		return 0, cerror.ErrAvroSchemaAPIError.GenWithStackByArgs()
 	}
 	req.Header.Add("Accept", "application/vnd.schemaregistry.v1+json")
-	resp, err := httpRetry(ctx, m.credential, req, false)
+	resp, err := httpRetry(ctx, m.httpCli, req, false)
 	if err != nil {
 		return 0, err
 	}
@@ -178,7 +267,10 @@ func (m *AvroSchemaManager) Register(ctx context.Context, tableName model.TableN
 // Calling this method with a tiSchemaID other than that used last time will invariably trigger a RESTful request to the Registry.
 // Returns (codec, registry schema ID, error)
 func (m *AvroSchemaManager) Lookup(ctx context.Context, tableName model.TableName, tiSchemaID uint64) (*goavro.Codec, int, error) {
-	key := m.tableNameToSchemaSubject(tableName)
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	// the schemas of the tables may share a subject, so they are cached by the tables
+	key := tableName.String()
 	if entry, exists := m.cache[key]; exists && entry.tiSchemaID == tiSchemaID {
 		log.Info("Avro schema lookup cache hit",
 			zap.String("key", key),
@@ -201,7 +293,7 @@ func (m *AvroSchemaManager) Lookup(ctx context.Context, tableName model.TableNam
 	}
 	req.Header.Add("Accept", "application/vnd.schemaregistry.v1+json, application/vnd.schemaregistry+json, application/json")
 
-	resp, err := httpRetry(ctx, m.credential, req, true)
+	resp, err := httpRetry(ctx, m.httpCli, req, true)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -245,7 +337,7 @@ func (m *AvroSchemaManager) Lookup(ctx context.Context, tableName model.TableNam
 	}
 	cacheEntry.registryID = jsonResp.RegistryID
 	cacheEntry.tiSchemaID = tiSchemaID
-	m.cache[m.tableNameToSchemaSubject(tableName)] = cacheEntry
+	m.cache[key] = cacheEntry
 
 	log.Info("Avro schema lookup successful with cache miss",
 		zap.Uint64("tiSchemaID", cacheEntry.tiSchemaID),
@@ -262,7 +354,10 @@ type SchemaGenerator func() (string, error)
 // GetCachedOrRegister checks if the suitable Avro schema has been cached.
 // If not, a new schema is generated, registered and cached.
 func (m *AvroSchemaManager) GetCachedOrRegister(ctx context.Context, tableName model.TableName, tiSchemaID uint64, schemaGen SchemaGenerator) (*goavro.Codec, int, error) {
-	key := m.tableNameToSchemaSubject(tableName)
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	// the schemas of the tables may share a subject, so they are cached by the tables
+	key := tableName.String()
 	if entry, exists := m.cache[key]; exists && entry.tiSchemaID == tiSchemaID {
 		log.Info("Avro schema GetCachedOrRegister cache hit",
 			zap.String("key", key),
@@ -286,17 +381,22 @@ func (m *AvroSchemaManager) GetCachedOrRegister(ctx context.Context, tableName m
 			cerror.WrapError(cerror.ErrAvroSchemaAPIError, err), "GetCachedOrRegister: Could not make goavro codec")
 	}
 
-	id, err := m.Register(ctx, tableName, codec)
-	if err != nil {
-		return nil, 0, errors.Annotate(
-			cerror.WrapError(cerror.ErrAvroSchemaAPIError, err), "GetCachedOrRegister: Could not register schema")
+	registered := registeredSchema{subject: m.tableNameToSchemaSubject(tableName), schema: codec.CanonicalSchema()}
+	id, ok := m.registeredIDs[registered]
+	if !ok {
+		id, err = m.Register(ctx, tableName, codec)
+		if err != nil {
+			return nil, 0, errors.Annotate(
+				cerror.WrapError(cerror.ErrAvroSchemaAPIError, err), "GetCachedOrRegister: Could not register schema")
+		}
+		m.registeredIDs[registered] = id
 	}
 
 	cacheEntry := new(schemaCacheEntry)
 	cacheEntry.codec = codec
 	cacheEntry.registryID = id
 	cacheEntry.tiSchemaID = tiSchemaID
-	m.cache[m.tableNameToSchemaSubject(tableName)] = cacheEntry
+	m.cache[key] = cacheEntry
 
 	log.Info("Avro schema GetCachedOrRegister successful with cache miss",
 		zap.Uint64("tiSchemaID", cacheEntry.tiSchemaID),
@@ -306,17 +406,114 @@ func (m *AvroSchemaManager) GetCachedOrRegister(ctx context.Context, tableName m
 	return codec, id, nil
 }
 
+// LookupByID looks up the schema by the Registry designated ID and the table the schema belongs to.
+// It is used by consumers to decode the messages, the subject of the schema must be registered
+// by a TiCDC Avro sink, and the Registry should support `GET /schemas/ids/{id}/versions`.
+func (m *AvroSchemaManager) LookupByID(ctx context.Context, registryID int) (*goavro.Codec, *model.TableName, error) {
+	m.idCacheMu.Lock()
+	defer m.idCacheMu.Unlock()
+	if entry, exists := m.idCache[registryID]; exists {
+		return entry.codec, &entry.tableName, nil
+	}
+
+	var schemaResp lookupByIDResponse
+	uri := m.registryURL + "/schemas/ids/" + strconv.Itoa(registryID)
+	if err := m.getJSON(ctx, uri, &schemaResp); err != nil {
+		return nil, nil, err
+	}
+	codec, err := goavro.NewCodec(schemaResp.Schema)
+	if err != nil {
+		return nil, nil, errors.Annotate(
+			cerror.WrapError(cerror.ErrAvroSchemaAPIError, err), "Creating Avro codec failed")
+	}
+	var top avroSchemaTop
+	if err := json.Unmarshal([]byte(schemaResp.Schema), &top); err != nil {
+		return nil, nil, cerror.WrapError(cerror.ErrAvroSchemaAPIError, err)
+	}
+
+	entry := &idCacheEntry{codec: codec}
+	if top.Namespace != "" {
+		// the namespace is `{schema}.{table}` unless the default strategy is used
+		parts := strings.SplitN(top.Namespace, ".", 2)
+		if len(parts) != 2 {
+			return nil, nil, cerror.ErrAvroSchemaAPIError.GenWithStack(
+				"unexpected namespace %s of schema %d", top.Namespace, registryID)
+		}
+		entry.tableName = model.TableName{Schema: parts[0], Table: parts[1]}
+	} else {
+		var versions []subjectVersion
+		if err := m.getJSON(ctx, uri+"/versions", &versions); err != nil {
+			return nil, nil, err
+		}
+		// the subject is `{schema}_{table}{suffix}`, and the name of the record is the table name
+		tableSuffix := "_" + top.Name + m.subjectSuffix
+		found := false
+		for _, v := range versions {
+			if strings.HasSuffix(v.Subject, tableSuffix) {
+				entry.tableName = model.TableName{
+					Schema: strings.TrimSuffix(v.Subject, tableSuffix),
+					Table:  top.Name,
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, nil, cerror.ErrAvroSchemaAPIError.GenWithStack(
+				"the table of schema %d is not found in subjects %v", registryID, versions)
+		}
+	}
+	m.idCache[registryID] = entry
+
+	log.Info("Avro schema lookup by ID successful",
+		zap.Int("registryID", registryID),
+		zap.String("schema", entry.tableName.Schema),
+		zap.String("table", entry.tableName.Table))
+	return entry.codec, &entry.tableName, nil
+}
+
+func (m *AvroSchemaManager) getJSON(ctx context.Context, uri string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrAvroSchemaAPIError, err)
+	}
+	req.Header.Add("Accept", "application/vnd.schemaregistry.v1+json, application/vnd.schemaregistry+json, application/json")
+
+	resp, err := httpRetry(ctx, m.httpCli, req, false)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Annotate(
+			cerror.WrapError(cerror.ErrAvroSchemaAPIError, err), "Failed to read response from Registry")
+	}
+	return cerror.WrapError(cerror.ErrAvroSchemaAPIError, json.Unmarshal(body, v))
+}
+
 // ClearRegistry clears the Registry subject for the given table. Should be idempotent.
 // Exported for testing.
 func (m *AvroSchemaManager) ClearRegistry(ctx context.Context, tableName model.TableName) error {
-	uri := m.registryURL + "/subjects/" + url.QueryEscape(m.tableNameToSchemaSubject(tableName))
+	subject := m.tableNameToSchemaSubject(tableName)
+	m.cacheMu.Lock()
+	delete(m.cache, tableName.String())
+	for registered := range m.registeredIDs {
+		if registered.subject == subject {
+			delete(m.registeredIDs, registered)
+		}
+	}
+	m.cacheMu.Unlock()
+
+	uri := m.registryURL + "/subjects/" + url.QueryEscape(subject)
 	req, err := http.NewRequestWithContext(ctx, "DELETE", uri, nil)
 	if err != nil {
 		log.Error("Could not construct request for clearRegistry", zap.String("uri", uri))
 		return cerror.WrapError(cerror.ErrAvroSchemaAPIError, err)
 	}
 	req.Header.Add("Accept", "application/vnd.schemaregistry.v1+json, application/vnd.schemaregistry+json, application/json")
-	resp, err := httpRetry(ctx, m.credential, req, true)
+	resp, err := httpRetry(ctx, m.httpCli, req, true)
 	if err != nil {
 		return err
 	}
@@ -335,57 +532,98 @@ func (m *AvroSchemaManager) ClearRegistry(ctx context.Context, tableName model.T
 	return cerror.ErrAvroSchemaAPIError.GenWithStack("Error when clearing Registry, status = %d", resp.StatusCode)
 }
 
-func httpRetry(ctx context.Context, credential *security.Credential, r *http.Request, allow404 bool) (*http.Response, error) {
-	var (
-		err  error
-		resp *http.Response
-		data []byte
-	)
+// the delays between the retries of the requests to the schema registry.
+const (
+	httpRetryBaseDelay = 500 * time.Millisecond
+	httpRetryMaxDelay  = 30 * time.Second
+	httpRetryDuration  = 15 * time.Minute
+)
 
-	expBackoff := backoff.NewExponentialBackOff()
-	expBackoff.MaxInterval = time.Second * 30
-	httpCli, err := httputil.NewClient(credential)
+// httpRetry sends the request until it succeeds or fails with an error that
+// is not retryable, the last response is returned if it's not retryable.
+func httpRetry(ctx context.Context, httpCli *httputil.Client, r *http.Request, allow404 bool) (resp *http.Response, err error) {
+	start := time.Now()
+	defer func() {
+		result := "success"
+		if err != nil || resp.StatusCode >= 300 && !(resp.StatusCode == 404 && allow404) {
+			result = "fail"
+		}
+		registryRequestDuration.WithLabelValues(r.Method, result).Observe(time.Since(start).Seconds())
+	}()
 
+	var data []byte
 	if r.Body != nil {
 		data, err = ioutil.ReadAll(r.Body)
 		_ = r.Body.Close()
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrAvroSchemaAPIError, err)
+		}
 	}
 
-	if err != nil {
-		return nil, cerror.WrapError(cerror.ErrAvroSchemaAPIError, err)
-	}
-	for {
+	err = retry.Do(ctx, func() error {
 		if data != nil {
 			r.Body = ioutil.NopCloser(bytes.NewReader(data))
 		}
-		resp, err = httpCli.Do(r)
-
-		if err != nil {
-			log.Warn("HTTP request failed", zap.String("msg", err.Error()))
-			goto checkCtx
+		var reqErr error
+		resp, reqErr = httpCli.Do(r)
+		if reqErr != nil {
+			log.Warn("HTTP request failed", zap.String("msg", reqErr.Error()))
+			return reqErr
 		}
-
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 || (resp.StatusCode == 404 && allow404) {
-			break
+			return nil
+		}
+		if !isRetryableStatus(resp.StatusCode) {
+			return nil
 		}
 		log.Warn("HTTP server returned with error", zap.Int("status", resp.StatusCode))
 		_ = resp.Body.Close()
-
-	checkCtx:
-		select {
-		case <-ctx.Done():
+		return cerror.ErrAvroSchemaAPIError.GenWithStack("HTTP server returned with status %d", resp.StatusCode)
+	}, retry.WithBackoffBaseDelay(httpRetryBaseDelay), retry.WithBackoffMaxDelay(httpRetryMaxDelay),
+		retry.WithInfiniteTries(), retry.WithTotalRetryDuration(httpRetryDuration))
+	if err != nil {
+		if ctx.Err() != nil {
 			return nil, errors.New("HTTP retry cancelled")
-
-		default:
 		}
-
-		time.Sleep(expBackoff.NextBackOff())
+		return nil, cerror.ErrAvroSchemaAPIError.GenWithStack(
+			"the request to %s still fails after retrying for %s", r.URL.Path, httpRetryDuration)
 	}
-
 	return resp, nil
 }
 
+// isRetryableStatus returns whether the request may succeed if it's retried,
+// the other errors, such as an incompatible schema or a wrong password, are
+// returned to the callers at once.
+func isRetryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
 func (m *AvroSchemaManager) tableNameToSchemaSubject(tableName model.TableName) string {
+	switch m.strategy {
+	case TopicNameStrategy:
+		return m.topic + m.subjectSuffix
+	case RecordNameStrategy:
+		namespace, name := m.recordName(tableName)
+		return namespace + "." + name
+	case TopicRecordNameStrategy:
+		namespace, name := m.recordName(tableName)
+		return m.topic + "-" + namespace + "." + name
+	}
 	// We should guarantee unique names for subjects
 	return tableName.Schema + "_" + tableName.Table + m.subjectSuffix
 }
+
+// recordName returns the namespace and the name of the record of the table. The
+// records are named by the tables for the default strategy, otherwise they
+// are `{schema}.{table}.Key` and `{schema}.{table}.Value`, so the keys and the
+// values have different full names, and the consumers can tell the tables by
+// the namespaces.
+func (m *AvroSchemaManager) recordName(tableName model.TableName) (string, string) {
+	if m.strategy == TableNameStrategy {
+		return "", tableName.Table
+	}
+	if m.subjectSuffix == "-key" {
+		return tableName.Schema + "." + tableName.Table, "Key"
+	}
+	return tableName.Schema + "." + tableName.Table, "Value"
+}
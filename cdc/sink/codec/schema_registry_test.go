@@ -25,7 +25,9 @@ import (
 	"github.com/jarcoal/httpmock"
 	"github.com/linkedin/goavro/v2"
 	"github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/httputil"
 	"github.com/pingcap/ticdc/pkg/security"
 	"github.com/pingcap/ticdc/pkg/util/testleak"
 )
@@ -123,6 +125,28 @@ func startHTTPInterceptForTestingRegistry(c *check.C) {
 			return httpmock.NewJsonResponse(200, &respData)
 		})
 
+	httpmock.RegisterResponder("GET", `=~^http://127.0.0.1:8081/schemas/ids/(\d+)(/versions)?$`,
+		func(req *http.Request) (*http.Response, error) {
+			id, err := httpmock.GetSubmatchAsInt(req, 1)
+			if err != nil {
+				return httpmock.NewStringResponse(500, "Internal Server Error"), err
+			}
+			versions, _ := httpmock.GetSubmatch(req, 2)
+
+			registry.mu.Lock()
+			defer registry.mu.Unlock()
+			for subject, item := range registry.subjects {
+				if int64(item.ID) != id {
+					continue
+				}
+				if versions != "" {
+					return httpmock.NewJsonResponse(200, []subjectVersion{{Subject: subject, Version: item.version}})
+				}
+				return httpmock.NewJsonResponse(200, &lookupByIDResponse{Schema: item.content})
+			}
+			return httpmock.NewStringResponse(404, ""), nil
+		})
+
 	httpmock.RegisterResponder("DELETE", `=~^http://127.0.0.1:8081/subjects/(.+)`,
 		func(req *http.Request) (*http.Response, error) {
 			subject, err := httpmock.GetSubmatch(req, 1)
@@ -149,10 +173,13 @@ func startHTTPInterceptForTestingRegistry(c *check.C) {
 			c.Assert(int64(len(data)), check.Equals, req.ContentLength)
 			if failCounter < 3 {
 				failCounter++
-				return httpmock.NewStringResponse(422, ""), nil
+				return httpmock.NewStringResponse(503, ""), nil
 			}
 			return httpmock.NewStringResponse(200, ""), nil
 		})
+
+	httpmock.RegisterResponder("POST", `=~^http://127.0.0.1:8081/reject`,
+		httpmock.NewStringResponder(409, ""))
 }
 
 func stopHTTPInterceptForTestingRegistry() {
@@ -241,6 +268,51 @@ func (s *AvroSchemaRegistrySuite) TestSchemaRegistry(c *check.C) {
 	c.Assert(codec.CanonicalSchema(), check.Equals, codec2.CanonicalSchema())
 }
 
+func (s *AvroSchemaRegistrySuite) TestSubjectNameStrategy(c *check.C) {
+	defer testleak.AfterTest(c)()
+	table := model.TableName{Schema: "testdb", Table: "test1"}
+
+	testCases := []struct {
+		strategy string
+		key      string
+		value    string
+	}{
+		{"TableName", "testdb_test1-key", "testdb_test1-value"},
+		{"TopicName", "topic-key", "topic-value"},
+		{"RecordName", "testdb.test1.Key", "testdb.test1.Value"},
+		{"io.confluent.kafka.serializers.subject.TopicRecordNameStrategy", "topic-testdb.test1.Key", "topic-testdb.test1.Value"},
+	}
+	for _, tc := range testCases {
+		strategy, err := ParseSubjectNameStrategy(tc.strategy)
+		c.Assert(err, check.IsNil)
+		keyManager := &AvroSchemaManager{subjectSuffix: "-key"}
+		c.Assert(keyManager.SetSubjectNameStrategy(strategy, "topic"), check.IsNil)
+		valueManager := &AvroSchemaManager{subjectSuffix: "-value"}
+		c.Assert(valueManager.SetSubjectNameStrategy(strategy, "topic"), check.IsNil)
+		c.Assert(keyManager.tableNameToSchemaSubject(table), check.Equals, tc.key)
+		c.Assert(valueManager.tableNameToSchemaSubject(table), check.Equals, tc.value)
+	}
+
+	_, err := ParseSubjectNameStrategy("Unknown")
+	c.Assert(err, check.ErrorMatches, ".*unknown subject name strategy.*")
+	manager := &AvroSchemaManager{subjectSuffix: "-value"}
+	c.Assert(manager.SetSubjectNameStrategy(TopicNameStrategy, ""), check.ErrorMatches, ".*requires a topic.*")
+
+	// the tables are found by the namespaces of the records
+	manager, err = NewAvroSchemaManager(getTestingContext(), &security.Credential{}, "http://127.0.0.1:8081", "-value")
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.SetSubjectNameStrategy(TopicNameStrategy, "topic"), check.IsNil)
+	cols := []*model.Column{{Name: "id", Type: mysql.TypeLong, Value: int64(1)}}
+	res, err := avroEncode(&table, manager, 1, cols)
+	c.Assert(err, check.IsNil)
+	_, id, err := manager.Lookup(getTestingContext(), table, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(id, check.Equals, res.registryID)
+	_, tableName, err := manager.LookupByID(getTestingContext(), res.registryID)
+	c.Assert(err, check.IsNil)
+	c.Assert(*tableName, check.Equals, table)
+}
+
 func (s *AvroSchemaRegistrySuite) TestSchemaRegistryBad(c *check.C) {
 	defer testleak.AfterTest(c)()
 	_, err := NewAvroSchemaManager(getTestingContext(), &security.Credential{}, "http://127.0.0.1:808", "-value")
@@ -302,7 +374,45 @@ func (s *AvroSchemaRegistrySuite) TestHTTPRetry(c *check.C) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 
-	resp, err := httpRetry(ctx, nil, req, false)
+	httpCli, err := httputil.NewClient(nil)
+	c.Assert(err, check.IsNil)
+	resp, err := httpRetry(ctx, httpCli, req, false)
 	c.Assert(err, check.IsNil)
 	_ = resp.Body.Close()
 }
+
+func (s *AvroSchemaRegistrySuite) TestHTTPRetryNotRetryable(c *check.C) {
+	defer testleak.AfterTest(c)()
+	req, err := http.NewRequest("POST", "http://127.0.0.1:8081/reject", bytes.NewReader([]byte("test")))
+	c.Assert(err, check.IsNil)
+	httpCli, err := httputil.NewClient(nil)
+	c.Assert(err, check.IsNil)
+
+	// an incompatible schema is not retried
+	count := httpmock.GetTotalCallCount()
+	resp, err := httpRetry(getTestingContext(), httpCli, req, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.StatusCode, check.Equals, 409)
+	_ = resp.Body.Close()
+	c.Assert(httpmock.GetTotalCallCount(), check.Equals, count+1)
+}
+
+func (s *AvroSchemaRegistrySuite) TestRegisteredSchemaCache(c *check.C) {
+	defer testleak.AfterTest(c)()
+	table := model.TableName{Schema: "testdb", Table: "cached"}
+	manager, err := NewAvroSchemaManager(getTestingContext(), &security.Credential{}, "http://127.0.0.1:8081", "-value")
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.ClearRegistry(getTestingContext(), table), check.IsNil)
+
+	schemaGen := func() (string, error) {
+		return `{"type":"record","name":"cached","fields":[{"type":"string","name":"field1"}]}`, nil
+	}
+	_, id, err := manager.GetCachedOrRegister(getTestingContext(), table, 1, schemaGen)
+	c.Assert(err, check.IsNil)
+	// the schema isn't registered again if a DDL doesn't change it
+	count := httpmock.GetTotalCallCount()
+	_, id1, err := manager.GetCachedOrRegister(getTestingContext(), table, 2, schemaGen)
+	c.Assert(err, check.IsNil)
+	c.Assert(id1, check.Equals, id)
+	c.Assert(httpmock.GetTotalCallCount(), check.Equals, count)
+}
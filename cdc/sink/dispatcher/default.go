@@ -37,7 +37,8 @@ func (d *defaultDispatcher) Dispatch(row *model.RowChangedEvent) int32 {
 	if d.enableOldValue {
 		return d.tbd.Dispatch(row)
 	}
-	if len(row.IndexColumns) != 1 {
+	// the values of a unique index without visible columns are not available
+	if len(row.IndexColumns) != 1 || len(row.IndexColumns[0]) == 0 {
 		return d.tbd.Dispatch(row)
 	}
 	return d.ivd.Dispatch(row)
@@ -0,0 +1,350 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	parser_types "github.com/pingcap/parser/types"
+	"github.com/pingcap/ticdc/cdc/model"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/quotes"
+	"go.uber.org/zap"
+)
+
+// downstreamColumn is a column of a downstream table.
+type downstreamColumn struct {
+	name     string
+	dataType string
+	// fillValue is inserted to the column if it's absent in the upstream,
+	// it's nil if the column can be omitted in the INSERT and REPLACE.
+	fillValue interface{}
+}
+
+// downstreamTable is a downstream table, whose columns may differ from the
+// upstream ones, for example, during a migration of the downstream.
+type downstreamTable struct {
+	// columns are ordered by their positions
+	columns []*downstreamColumn
+	// names are the lower case names of the columns
+	names map[string]*downstreamColumn
+	// uniqueKeys are the lower case column names of the primary key and the
+	// unique indexes
+	uniqueKeys [][]string
+}
+
+// adjust removes the columns absent in the downstream table, and appends the
+// NOT NULL columns without default values absent in the upstream if fill is
+// true. The given columns are not modified.
+func (t *downstreamTable) adjust(cols []*model.Column, fill bool) []*model.Column {
+	adjusted := make([]*model.Column, 0, len(cols))
+	present := make(map[string]struct{}, len(cols))
+	for _, col := range cols {
+		if col == nil {
+			continue
+		}
+		name := strings.ToLower(col.Name)
+		if _, ok := t.names[name]; !ok {
+			continue
+		}
+		present[name] = struct{}{}
+		adjusted = append(adjusted, col)
+	}
+	if !fill {
+		return adjusted
+	}
+	for _, col := range t.columns {
+		if col.fillValue == nil {
+			continue
+		}
+		if _, ok := present[strings.ToLower(col.name)]; ok {
+			continue
+		}
+		adjusted = append(adjusted, &model.Column{Name: col.name, Value: col.fillValue})
+	}
+	return adjusted
+}
+
+// check compares the downstream table with the upstream one described by the
+// columns of a row, it returns the divergences which don't fail the DMLs
+// immediately but are risky, such as the different column types and the
+// missing unique keys.
+func (t *downstreamTable) check(quoteTable string, cols []*model.Column) []string {
+	var warnings []string
+	var handleKey []string
+	for _, col := range cols {
+		if col == nil {
+			continue
+		}
+		if col.Flag.IsHandleKey() {
+			handleKey = append(handleKey, strings.ToLower(col.Name))
+		}
+		downstream, ok := t.names[strings.ToLower(col.Name)]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf(
+				"column %s of table %s is absent in the downstream", col.Name, quoteTable))
+			continue
+		}
+		upstreamType := upstreamDataType(col)
+		if upstreamType != "" && upstreamType != strings.ToLower(downstream.dataType) {
+			warnings = append(warnings, fmt.Sprintf(
+				"column %s of table %s is %s in the upstream but %s in the downstream",
+				col.Name, quoteTable, upstreamType, downstream.dataType))
+		}
+	}
+	if len(handleKey) != 0 && !t.hasUniqueKey(handleKey) {
+		warnings = append(warnings, fmt.Sprintf(
+			"the handle key (%s) of table %s is not a unique key in the downstream, the rows may be duplicated",
+			strings.Join(handleKey, ","), quoteTable))
+	}
+	return warnings
+}
+
+func (t *downstreamTable) hasUniqueKey(names []string) bool {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	for _, key := range t.uniqueKeys {
+		if len(key) != len(sorted) {
+			continue
+		}
+		k := append([]string(nil), key...)
+		sort.Strings(k)
+		equal := true
+		for i := range k {
+			if k[i] != sorted[i] {
+				equal = false
+				break
+			}
+		}
+		if equal {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamDataType returns the DATA_TYPE of the information_schema of an
+// upstream column, it returns an empty string if it's unknown.
+func upstreamDataType(col *model.Column) string {
+	tp := parser_types.TypeStr(col.Type)
+	if col.Flag.IsBinary() {
+		if parser_types.IsTypeBlob(col.Type) {
+			tp = strings.Replace(tp, "text", "blob", 1)
+		} else if parser_types.IsTypeChar(col.Type) {
+			tp = strings.Replace(tp, "char", "binary", 1)
+		}
+	}
+	if tp == "var_string" {
+		return "varchar"
+	}
+	return tp
+}
+
+// cachedTable is a downstream table in the cache.
+type cachedTable struct {
+	// table is nil if it's absent in the downstream
+	table    *downstreamTable
+	warnings []string
+	loadTime time.Time
+}
+
+// downstreamSchemas caches the downstream tables introspected by the MySQL
+// sink. The cache is cleared after a DDL is executed, and the tables are
+// introspected again after the refresh interval if it's not 0.
+//
+// It's safe for concurrent use.
+type downstreamSchemas struct {
+	db              *sql.DB
+	refreshInterval time.Duration
+	// schemaSuffix is appended to the schema names of the rows
+	schemaSuffix string
+
+	mu sync.RWMutex
+	// tables are keyed by the quoted table names
+	tables map[string]*cachedTable
+}
+
+func newDownstreamSchemas(db *sql.DB, refreshInterval time.Duration, schemaSuffix string) *downstreamSchemas {
+	return &downstreamSchemas{
+		db:              db,
+		refreshInterval: refreshInterval,
+		schemaSuffix:    schemaSuffix,
+		tables:          make(map[string]*cachedTable),
+	}
+}
+
+// load introspects the tables of the rows absent or expired in the cache.
+func (s *downstreamSchemas) load(ctx context.Context, rows []*model.RowChangedEvent) error {
+	for _, row := range rows {
+		schema := row.Table.Schema + s.schemaSuffix
+		quoteTable := quotes.QuoteSchema(schema, row.Table.Table)
+		s.mu.RLock()
+		cached, ok := s.tables[quoteTable]
+		s.mu.RUnlock()
+		if ok && (s.refreshInterval == 0 || time.Since(cached.loadTime) < s.refreshInterval) {
+			continue
+		}
+		table, err := s.query(ctx, schema, row.Table.Table)
+		if err != nil {
+			return err
+		}
+		cached = &cachedTable{table: table, loadTime: time.Now()}
+		if table != nil {
+			cols := row.Columns
+			if len(cols) == 0 {
+				cols = row.PreColumns
+			}
+			cached.warnings = table.check(quoteTable, cols)
+			for _, warning := range cached.warnings {
+				log.Warn("downstream schema mismatch", zap.String("warning", warning))
+			}
+		}
+		s.mu.Lock()
+		s.tables[quoteTable] = cached
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *downstreamSchemas) query(ctx context.Context, schema, table string) (*downstreamTable, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA
+FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`, schema, table)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	defer rows.Close()
+	t := &downstreamTable{names: make(map[string]*downstreamColumn)}
+	for rows.Next() {
+		var name, dataType, nullable, extra string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&name, &dataType, &nullable, &defaultValue, &extra); err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+		}
+		col := &downstreamColumn{name: name, dataType: dataType}
+		extra = strings.ToLower(extra)
+		if nullable == "NO" && !defaultValue.Valid &&
+			!strings.Contains(extra, "auto_increment") && !strings.Contains(extra, "generated") {
+			col.fillValue = zeroValueOfType(dataType)
+		}
+		t.columns = append(t.columns, col)
+		t.names[strings.ToLower(name)] = col
+	}
+	if err := rows.Err(); err != nil {
+		return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	if len(t.columns) == 0 {
+		// the errors of the absent tables are reported by the DMLs
+		return nil, nil
+	}
+	t.uniqueKeys, err = s.queryUniqueKeys(ctx, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("downstream table introspected",
+		zap.String("schema", schema), zap.String("table", table), zap.Int("columns", len(t.columns)))
+	return t, nil
+}
+
+func (s *downstreamSchemas) queryUniqueKeys(ctx context.Context, schema, table string) ([][]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT INDEX_NAME, COLUMN_NAME
+FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND NON_UNIQUE = 0
+ORDER BY INDEX_NAME, SEQ_IN_INDEX`, schema, table)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	defer rows.Close()
+	var keys [][]string
+	lastIndex := ""
+	for rows.Next() {
+		var index string
+		var column sql.NullString
+		if err := rows.Scan(&index, &column); err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+		}
+		if len(keys) == 0 || index != lastIndex {
+			keys = append(keys, nil)
+			lastIndex = index
+		}
+		// the column is NULL if it's an expression index
+		keys[len(keys)-1] = append(keys[len(keys)-1], strings.ToLower(column.String))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	return keys, nil
+}
+
+// get returns the cached downstream table, it returns nil if the table is not
+// loaded or absent in the downstream.
+func (s *downstreamSchemas) get(quoteTable string) *downstreamTable {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cached, ok := s.tables[quoteTable]
+	if !ok {
+		return nil
+	}
+	return cached.table
+}
+
+// warnings returns the divergences found in the cached tables.
+func (s *downstreamSchemas) warnings() []*model.RunningError {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var warnings []*model.RunningError
+	for _, cached := range s.tables {
+		for _, warning := range cached.warnings {
+			warnings = append(warnings, &model.RunningError{
+				Code:    string(cerror.ErrDownstreamSchemaMismatch.RFCCode()),
+				Message: warning,
+			})
+		}
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[i].Message < warnings[j].Message
+	})
+	return warnings
+}
+
+// reset clears the cache, the tables are introspected again by the next DMLs.
+func (s *downstreamSchemas) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tables = make(map[string]*cachedTable)
+}
+
+// zeroValueOfType returns the zero value of a data type of the
+// information_schema, it returns nil for the types without an obvious zero
+// value, such as the temporal types and ENUM.
+func zeroValueOfType(dataType string) interface{} {
+	switch strings.ToLower(dataType) {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint",
+		"decimal", "numeric", "float", "double", "real", "bit", "year":
+		return 0
+	case "char", "varchar", "binary", "varbinary",
+		"tinytext", "text", "mediumtext", "longtext",
+		"tinyblob", "blob", "mediumblob", "longblob", "set":
+		return ""
+	case "json":
+		return "null"
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type downstreamSchemaSuite struct{}
+
+var _ = check.Suite(&downstreamSchemaSuite{})
+
+func (s *downstreamSchemaSuite) TestAdaptDownstreamSchema(c *check.C) {
+	defer testleak.AfterTest(c)()
+	db, mock, err := sqlmock.New()
+	c.Assert(err, check.IsNil)
+	defer db.Close() //nolint:errcheck
+
+	columns := []string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT", "EXTRA"}
+	mock.ExpectQuery("SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA.*").
+		WithArgs("test", "t").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("id", "int", "NO", nil, "auto_increment").
+			AddRow("a", "varchar", "YES", nil, "").
+			// the columns added in the downstream
+			AddRow("b", "int", "NO", "1", "").
+			AddRow("c", "varchar", "NO", nil, "").
+			AddRow("d", "datetime", "NO", nil, ""))
+	mock.ExpectQuery("SELECT INDEX_NAME, COLUMN_NAME.*").
+		WithArgs("test", "t").
+		WillReturnRows(sqlmock.NewRows([]string{"INDEX_NAME", "COLUMN_NAME"}).AddRow("PRIMARY", "id"))
+	mock.ExpectQuery("SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA.*").
+		WithArgs("test", "absent").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	schemas := newDownstreamSchemas(db, 0, "")
+	newRow := func(table string) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			Table: &model.TableName{Schema: "test", Table: table},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 1},
+				{Name: "A", Type: mysql.TypeVarchar, Value: []byte("a")},
+				// the column dropped in the downstream
+				{Name: "e", Type: mysql.TypeLong, Value: 2},
+			},
+		}
+	}
+	rows := []*model.RowChangedEvent{newRow("t"), newRow("t"), newRow("absent")}
+	err = schemas.load(context.Background(), rows)
+	c.Assert(err, check.IsNil)
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+	c.Assert(schemas.get("`test`.`absent`"), check.IsNil)
+
+	table := schemas.get("`test`.`t`")
+	c.Assert(table, check.NotNil)
+	cols := table.adjust(rows[0].Columns, false)
+	c.Assert(cols, check.DeepEquals, rows[0].Columns[:2])
+	cols = table.adjust(rows[0].Columns, true)
+	c.Assert(cols, check.HasLen, 3)
+	c.Assert(cols[2], check.DeepEquals, &model.Column{Name: "c", Value: ""})
+	// the given columns are not modified
+	c.Assert(rows[0].Columns, check.HasLen, 3)
+
+	// the tables are introspected again after a DDL
+	schemas.reset()
+	c.Assert(schemas.get("`test`.`t`"), check.IsNil)
+}
+
+func (s *downstreamSchemaSuite) TestCheckDownstreamSchema(c *check.C) {
+	defer testleak.AfterTest(c)()
+	table := &downstreamTable{
+		names: map[string]*downstreamColumn{
+			"id": {name: "id", dataType: "bigint"},
+			"a":  {name: "a", dataType: "varchar"},
+			"b":  {name: "b", dataType: "BLOB"},
+		},
+		uniqueKeys: [][]string{{"a", "id"}},
+	}
+	cols := []*model.Column{
+		{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag},
+		{Name: "a", Type: mysql.TypeVarString},
+		{Name: "b", Type: mysql.TypeBlob, Flag: model.BinaryFlag},
+		{Name: "c", Type: mysql.TypeLong},
+	}
+	c.Assert(table.check("`test`.`t`", cols), check.DeepEquals, []string{
+		"column id of table `test`.`t` is int in the upstream but bigint in the downstream",
+		"column c of table `test`.`t` is absent in the downstream",
+		"the handle key (id) of table `test`.`t` is not a unique key in the downstream, the rows may be duplicated",
+	})
+
+	table.uniqueKeys = append(table.uniqueKeys, []string{"id"})
+	table.names["id"].dataType = "int"
+	c.Assert(table.check("`test`.`t`", cols[:3]), check.HasLen, 0)
+
+	db, mock, err := sqlmock.New()
+	c.Assert(err, check.IsNil)
+	defer db.Close() //nolint:errcheck
+	schemas := newDownstreamSchemas(db, 0, "")
+	schemas.tables["`test`.`t`"] = &cachedTable{
+		table:    table,
+		warnings: []string{"b", "a"},
+	}
+	warnings := schemas.warnings()
+	c.Assert(warnings, check.HasLen, 2)
+	c.Assert(warnings[0].Message, check.Equals, "a")
+	c.Assert(warnings[0].Code, check.Equals, "CDC:ErrDownstreamSchemaMismatch")
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+}
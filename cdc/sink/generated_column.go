@@ -0,0 +1,69 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+// checkGeneratedColumnsMode checks the generated-columns option of the sink
+// config, an empty mode means the default behavior of the sink.
+func checkGeneratedColumnsMode(mode string) error {
+	switch mode {
+	case "", config.GeneratedColumnsEmit, config.GeneratedColumnsStrip:
+		return nil
+	}
+	return cerror.ErrSinkInvalidConfig.GenWithStack("unknown generated-columns mode %s", mode)
+}
+
+// isGeneratedColumnsStripped returns whether the MQ sinks strip the generated
+// columns, they are emitted by default.
+func isGeneratedColumnsStripped(cfg *config.SinkConfig) bool {
+	return cfg.GeneratedColumns == config.GeneratedColumnsStrip
+}
+
+// stripGeneratedColumns returns the columns without the generated ones. The
+// given slice is not modified, since the offsets of the index columns of the
+// row refer to it.
+func stripGeneratedColumns(cols []*model.Column) []*model.Column {
+	generated := 0
+	for _, col := range cols {
+		if col != nil && col.Flag.IsGeneratedColumn() {
+			generated++
+		}
+	}
+	if generated == 0 {
+		return cols
+	}
+	stripped := make([]*model.Column, 0, len(cols)-generated)
+	for _, col := range cols {
+		if col != nil && col.Flag.IsGeneratedColumn() {
+			continue
+		}
+		stripped = append(stripped, col)
+	}
+	return stripped
+}
+
+// emitGeneratedColumns makes the generated columns written to the downstream
+// as normal columns.
+func emitGeneratedColumns(cols []*model.Column) {
+	for _, col := range cols {
+		if col != nil && col.Flag.IsGeneratedColumn() {
+			col.Flag.UnsetIsGeneratedColumn()
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type generatedColumnSuite struct{}
+
+var _ = check.Suite(&generatedColumnSuite{})
+
+func (s *generatedColumnSuite) TestGeneratedColumns(c *check.C) {
+	defer testleak.AfterTest(c)()
+	c.Assert(checkGeneratedColumnsMode(""), check.IsNil)
+	c.Assert(checkGeneratedColumnsMode(config.GeneratedColumnsEmit), check.IsNil)
+	c.Assert(checkGeneratedColumnsMode(config.GeneratedColumnsStrip), check.IsNil)
+	c.Assert(checkGeneratedColumnsMode("virtual"), check.ErrorMatches, ".*unknown generated-columns mode.*")
+
+	newCols := func() []*model.Column {
+		return []*model.Column{
+			{Name: "a", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 1},
+			{Name: "b", Type: mysql.TypeLong, Flag: model.GeneratedColumnFlag, Value: 2},
+			nil,
+		}
+	}
+
+	cols := newCols()
+	stripped := stripGeneratedColumns(cols)
+	c.Assert(stripped, check.HasLen, 2)
+	c.Assert(stripped[0].Name, check.Equals, "a")
+	c.Assert(stripped[1], check.IsNil)
+	// the original columns are kept
+	c.Assert(cols, check.HasLen, 3)
+	c.Assert(stripGeneratedColumns(stripped), check.DeepEquals, stripped)
+
+	cols = newCols()
+	emitGeneratedColumns(cols)
+	c.Assert(cols[1].Flag.IsGeneratedColumn(), check.IsFalse)
+	sql, args := prepareReplace("`s`.`t`", cols, false, false)
+	c.Assert(sql, check.Equals, "REPLACE INTO `s`.`t`(`a`,`b`) VALUES ")
+	c.Assert(args, check.DeepEquals, []interface{}{1, 2})
+}
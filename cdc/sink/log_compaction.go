@@ -0,0 +1,80 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"bytes"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/ticdc/pkg/config"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+// messageKeyConfig returns the config of the message keys of the MQ sinks, it's
+// nil if the keys are decided by the protocol. The log compaction mode requires
+// a key per row, which is the handle key by default.
+func messageKeyConfig(cfg *config.ReplicaConfig) (*config.MessageKeyConfig, error) {
+	if !cfg.Sink.LogCompaction {
+		return cfg.Sink.MessageKey, nil
+	}
+	if !cfg.EnableOldValue {
+		return nil, cerror.ErrSinkInvalidConfig.GenWithStack(
+			"the log compaction mode requires the old value to be enabled")
+	}
+	for _, rule := range cfg.Sink.DispatchRules {
+		// the messages of a key must be sent to the same partition to be compacted
+		if rule.Dispatcher == "ts" {
+			return nil, cerror.ErrSinkInvalidConfig.GenWithStack(
+				"the ts dispatcher is not supported by the log compaction mode")
+		}
+	}
+	keyCfg := cfg.Sink.MessageKey
+	if keyCfg == nil {
+		return &config.MessageKeyConfig{Type: config.MessageKeyHandleKey}, nil
+	}
+	if keyCfg.Type == config.MessageKeyTable {
+		return nil, cerror.ErrSinkInvalidConfig.GenWithStack(
+			"the message key type %s is not supported by the log compaction mode", keyCfg.Type)
+	}
+	return keyCfg, nil
+}
+
+// splitKeyChangedUpdate splits an update changing the message key of the row
+// into a delete of the old key and an insert of the new key, so the old key is
+// removed from the log-compacted topic. The returned delete is nil if the key
+// is not changed.
+func splitKeyChangedUpdate(
+	row *model.RowChangedEvent, keyBuilder *codec.MessageKeyBuilder,
+) (*model.RowChangedEvent, *model.RowChangedEvent, error) {
+	if len(row.PreColumns) == 0 || len(row.Columns) == 0 {
+		return nil, row, nil
+	}
+	deleteRow, insertRow := *row, *row
+	deleteRow.Columns = nil
+	insertRow.PreColumns = nil
+	oldKey, err := keyBuilder.Build(&deleteRow)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	newKey, err := keyBuilder.Build(&insertRow)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	if bytes.Equal(oldKey, newKey) {
+		return nil, row, nil
+	}
+	return &deleteRow, &insertRow, nil
+}
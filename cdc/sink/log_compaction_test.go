@@ -0,0 +1,93 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type logCompactionSuite struct{}
+
+var _ = check.Suite(&logCompactionSuite{})
+
+func (s *logCompactionSuite) TestMessageKeyConfig(c *check.C) {
+	defer testleak.AfterTest(c)()
+	cfg := config.GetDefaultReplicaConfig()
+	keyCfg, err := messageKeyConfig(cfg)
+	c.Assert(err, check.IsNil)
+	c.Assert(keyCfg, check.IsNil)
+
+	cfg.EnableOldValue = true
+	cfg.Sink.LogCompaction = true
+	keyCfg, err = messageKeyConfig(cfg)
+	c.Assert(err, check.IsNil)
+	c.Assert(keyCfg.Type, check.Equals, config.MessageKeyHandleKey)
+
+	cfg.Sink.MessageKey = &config.MessageKeyConfig{Type: config.MessageKeyTable}
+	_, err = messageKeyConfig(cfg)
+	c.Assert(err, check.ErrorMatches, ".*message key type table is not supported.*")
+
+	cfg.Sink.MessageKey = &config.MessageKeyConfig{Type: config.MessageKeyUniqueKeys}
+	cfg.Sink.DispatchRules = []*config.DispatchRule{{Matcher: []string{"test.*"}, Dispatcher: "ts"}}
+	_, err = messageKeyConfig(cfg)
+	c.Assert(err, check.ErrorMatches, ".*ts dispatcher is not supported.*")
+
+	cfg.Sink.DispatchRules = nil
+	cfg.EnableOldValue = false
+	_, err = messageKeyConfig(cfg)
+	c.Assert(err, check.ErrorMatches, ".*requires the old value to be enabled.*")
+}
+
+func (s *logCompactionSuite) TestSplitKeyChangedUpdate(c *check.C) {
+	defer testleak.AfterTest(c)()
+	keyBuilder, err := codec.NewMessageKeyBuilder(&config.MessageKeyConfig{Type: config.MessageKeyHandleKey})
+	c.Assert(err, check.IsNil)
+	newCols := func(id int, name string) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: id},
+			{Name: "name", Type: mysql.TypeVarchar, Value: name},
+		}
+	}
+	table := &model.TableName{Schema: "test", Table: "t"}
+
+	// the key is not changed
+	row := &model.RowChangedEvent{Table: table, PreColumns: newCols(1, "a"), Columns: newCols(1, "b")}
+	deleteRow, insertRow, err := splitKeyChangedUpdate(row, keyBuilder)
+	c.Assert(err, check.IsNil)
+	c.Assert(deleteRow, check.IsNil)
+	c.Assert(insertRow, check.Equals, row)
+
+	// an insert is never split
+	row = &model.RowChangedEvent{Table: table, Columns: newCols(1, "a")}
+	deleteRow, insertRow, err = splitKeyChangedUpdate(row, keyBuilder)
+	c.Assert(err, check.IsNil)
+	c.Assert(deleteRow, check.IsNil)
+	c.Assert(insertRow, check.Equals, row)
+
+	row = &model.RowChangedEvent{Table: table, PreColumns: newCols(1, "a"), Columns: newCols(2, "a")}
+	deleteRow, insertRow, err = splitKeyChangedUpdate(row, keyBuilder)
+	c.Assert(err, check.IsNil)
+	c.Assert(deleteRow.IsDelete(), check.IsTrue)
+	c.Assert(deleteRow.PreColumns[0].Value, check.Equals, 1)
+	c.Assert(insertRow.PreColumns, check.IsNil)
+	c.Assert(insertRow.Columns[0].Value, check.Equals, 2)
+	// the original row is not modified
+	c.Assert(row.PreColumns, check.NotNil)
+	c.Assert(row.Columns, check.NotNil)
+}
@@ -27,7 +27,9 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/pingcap/errors"
+	"github.com/pingcap/failpoint"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
 )
 
 const (
@@ -45,6 +47,8 @@ type Manager struct {
 	checkpointTs model.Ts
 	tableSinks   map[model.TableID]*tableSink
 	tableSinksMu sync.Mutex
+	// spill is nil if the table sinks don't spill the pending rows to disk
+	spill *spillOptions
 }
 
 // NewManager creates a new Sink manager
@@ -56,6 +60,18 @@ func NewManager(ctx context.Context, backendSink Sink, errCh chan error, checkpo
 	}
 }
 
+// EnableSpill makes the table sinks created afterwards spill the pending rows
+// to the files in dir, once the rows in memory exceed the limit. The files left
+// in dir are removed.
+func (m *Manager) EnableSpill(ctx context.Context, dir string, cfg *config.SpillConfig) error {
+	opts, err := newSpillOptions(dir, cfg, util.CaptureAddrFromCtx(ctx), util.ChangefeedIDFromCtx(ctx))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.spill = opts
+	return nil
+}
+
 // CreateTableSink creates a table sink
 func (m *Manager) CreateTableSink(tableID model.TableID, checkpointTs model.Ts) Sink {
 	if _, exist := m.tableSinks[tableID]; exist {
@@ -67,6 +83,9 @@ func (m *Manager) CreateTableSink(tableID model.TableID, checkpointTs model.Ts)
 		buffer:    make([]*model.RowChangedEvent, 0, 128),
 		emittedTs: checkpointTs,
 	}
+	if m.spill != nil {
+		sink.spill = newSpillQueue(m.spill, tableID)
+	}
 	m.tableSinksMu.Lock()
 	defer m.tableSinksMu.Unlock()
 	m.tableSinks[tableID] = sink
@@ -78,6 +97,40 @@ func (m *Manager) Close() error {
 	return m.backendSink.Close()
 }
 
+// Warnings returns the warnings reported by the backend Sink.
+func (m *Manager) Warnings() []*model.RunningError {
+	if reporter, ok := m.backendSink.(WarningReporter); ok {
+		return reporter.Warnings()
+	}
+	return nil
+}
+
+// EmitBootstrapMessage sends the bootstrap DDL event of a table by the backend
+// Sink, it's a no-op if the backend Sink doesn't support the bootstrap messages.
+func (m *Manager) EmitBootstrapMessage(ctx context.Context, ddl *model.DDLEvent) error {
+	if sender, ok := m.backendSink.(BootstrapMessageSender); ok {
+		return sender.EmitBootstrapMessage(ctx, ddl)
+	}
+	return nil
+}
+
+// HeartbeatInterval returns the interval of the table heartbeats of the
+// backend Sink, it's 0 if the backend Sink doesn't support the heartbeats.
+func (m *Manager) HeartbeatInterval() time.Duration {
+	if sender, ok := m.backendSink.(HeartbeatSender); ok {
+		return sender.HeartbeatInterval()
+	}
+	return 0
+}
+
+// EmitHeartbeat sends a heartbeat of the table by the backend Sink.
+func (m *Manager) EmitHeartbeat(ctx context.Context, table *model.TableName, ts uint64) error {
+	if sender, ok := m.backendSink.(HeartbeatSender); ok {
+		return sender.EmitHeartbeat(ctx, table, ts)
+	}
+	return nil
+}
+
 func (m *Manager) getMinEmittedTs() model.Ts {
 	m.tableSinksMu.Lock()
 	defer m.tableSinksMu.Unlock()
@@ -118,6 +171,8 @@ type tableSink struct {
 	tableID model.TableID
 	manager *Manager
 	buffer  []*model.RowChangedEvent
+	// spill holds the pending rows after the buffer, it's nil if spilling is disabled
+	spill *spillQueue
 	// emittedTs means all of events which of commitTs less than or equal to emittedTs is sent to backendSink
 	emittedTs model.Ts
 }
@@ -128,8 +183,16 @@ func (t *tableSink) Initialize(ctx context.Context, tableInfo []*model.SimpleTab
 }
 
 func (t *tableSink) EmitRowChangedEvents(ctx context.Context, rows ...*model.RowChangedEvent) error {
-	t.buffer = append(t.buffer, rows...)
-	return nil
+	if len(rows) == 0 {
+		return nil
+	}
+	// the rows are spilled once there are too many rows in memory, and keep
+	// being spilled until the spilled rows are drained to preserve the order
+	if t.spill == nil || (t.spill.empty() && len(t.buffer)+len(rows) <= t.manager.spill.memoryRowsLimit) {
+		t.buffer = append(t.buffer, rows...)
+		return nil
+	}
+	return errors.Trace(t.spill.push(rows))
 }
 
 func (t *tableSink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error {
@@ -138,24 +201,38 @@ func (t *tableSink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error
 }
 
 func (t *tableSink) FlushRowChangedEvents(ctx context.Context, resolvedTs uint64) (uint64, error) {
-	i := sort.Search(len(t.buffer), func(i int) bool {
-		return t.buffer[i].CommitTs > resolvedTs
-	})
-	if i == 0 {
-		atomic.StoreUint64(&t.emittedTs, resolvedTs)
-		return t.manager.flushBackendSink(ctx)
-	}
-	resolvedRows := t.buffer[:i]
-	t.buffer = t.buffer[i:]
-
-	err := t.manager.backendSink.EmitRowChangedEvents(ctx, resolvedRows...)
-	if err != nil {
-		return t.manager.getCheckpointTs(), errors.Trace(err)
+	for {
+		i := sort.Search(len(t.buffer), func(i int) bool {
+			return t.buffer[i].CommitTs > resolvedTs
+		})
+		if i != 0 {
+			resolvedRows := t.buffer[:i]
+			t.buffer = t.buffer[i:]
+			err := t.manager.backendSink.EmitRowChangedEvents(ctx, resolvedRows...)
+			if err != nil {
+				return t.manager.getCheckpointTs(), errors.Trace(err)
+			}
+		}
+		if len(t.buffer) != 0 || t.spill == nil || t.spill.empty() {
+			break
+		}
+		// all the rows in memory are resolved, load the spilled rows
+		rows, err := t.spill.pop()
+		if err != nil {
+			return t.manager.getCheckpointTs(), errors.Trace(err)
+		}
+		t.buffer = rows
 	}
 	atomic.StoreUint64(&t.emittedTs, resolvedTs)
 	return t.manager.flushBackendSink(ctx)
 }
 
+// Full implements the FullReporter interface, the table sink is full if the
+// pending rows can't be spilled anymore.
+func (t *tableSink) Full() bool {
+	return t.spill != nil && t.spill.full()
+}
+
 func (t *tableSink) getEmittedTs() uint64 {
 	return atomic.LoadUint64(&t.emittedTs)
 }
@@ -167,6 +244,9 @@ func (t *tableSink) EmitCheckpointTs(ctx context.Context, ts uint64) error {
 
 func (t *tableSink) Close() error {
 	t.manager.destroyTableSink(t.tableID)
+	if t.spill != nil {
+		return t.spill.close()
+	}
 	return nil
 }
 
@@ -211,6 +291,9 @@ func (b *bufferSink) run(ctx context.Context, errCh chan error) {
 				// A resolved event received
 				start := time.Now()
 				checkpointTs, err := b.Sink.FlushRowChangedEvents(ctx, e.resolvedTs)
+				failpoint.Inject("SinkFlushError", func() {
+					err = errors.New("injected sink flush error")
+				})
 				if err != nil {
 					if errors.Cause(err) != context.Canceled {
 						errCh <- err
@@ -266,3 +349,31 @@ func (b *bufferSink) FlushRowChangedEvents(ctx context.Context, resolvedTs uint6
 	}
 	return atomic.LoadUint64(&b.checkpointTs), nil
 }
+
+func (b *bufferSink) Warnings() []*model.RunningError {
+	if reporter, ok := b.Sink.(WarningReporter); ok {
+		return reporter.Warnings()
+	}
+	return nil
+}
+
+func (b *bufferSink) HeartbeatInterval() time.Duration {
+	if sender, ok := b.Sink.(HeartbeatSender); ok {
+		return sender.HeartbeatInterval()
+	}
+	return 0
+}
+
+func (b *bufferSink) EmitHeartbeat(ctx context.Context, table *model.TableName, ts uint64) error {
+	if sender, ok := b.Sink.(HeartbeatSender); ok {
+		return sender.EmitHeartbeat(ctx, table, ts)
+	}
+	return nil
+}
+
+func (b *bufferSink) EmitBootstrapMessage(ctx context.Context, ddl *model.DDLEvent) error {
+	if sender, ok := b.Sink.(BootstrapMessageSender); ok {
+		return sender.EmitBootstrapMessage(ctx, ddl)
+	}
+	return nil
+}
@@ -85,6 +85,43 @@ var (
 			Name:      "buffer_chan_size",
 			Help:      "size of row changed event buffer channel in sink manager",
 		}, []string{"capture", "changefeed"})
+	spillBytesCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ticdc",
+			Subsystem: "sink",
+			Name:      "spill_bytes",
+			Help:      "Bytes of the pending rows spilled to disk by the table sinks",
+		}, []string{"capture", "changefeed"})
+	spillRowsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ticdc",
+			Subsystem: "sink",
+			Name:      "spill_rows",
+			Help:      "Count of the pending rows spilled to disk by the table sinks",
+		}, []string{"capture", "changefeed"})
+	spillDrainDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ticdc",
+			Subsystem: "sink",
+			Name:      "spill_drain_duration_seconds",
+			Help:      "Bucketed histogram of the time (s) from a table sink starts spilling to all the spilled rows are drained",
+			Buckets:   prometheus.ExponentialBuckets(0.1 /* 100ms */, 2, 18),
+		}, []string{"capture", "changefeed"})
+	oversizedRowCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ticdc",
+			Subsystem: "sink",
+			Name:      "oversized_row_count",
+			Help:      "Count of the rows whose messages exceed max-message-bytes of the MQ sinks",
+		}, []string{"capture", "changefeed", "policy"})
+	e2eLatencyHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ticdc",
+			Subsystem: "sink",
+			Name:      "e2e_latency_seconds",
+			Help:      "Bucketed histogram of the time (s) from a txn is committed in the upstream to the flush of the sink containing it is acknowledged",
+			Buckets:   prometheus.ExponentialBuckets(0.01 /* 10ms */, 2, 18),
+		}, []string{"capture", "changefeed"})
 )
 
 // InitMetrics registers all metrics in this file
@@ -98,4 +135,9 @@ func InitMetrics(registry *prometheus.Registry) {
 	registry.MustRegister(totalFlushedRowsCountGauge)
 	registry.MustRegister(flushRowChangedDuration)
 	registry.MustRegister(bufferChanSizeGauge)
+	registry.MustRegister(spillBytesCounter)
+	registry.MustRegister(spillRowsCounter)
+	registry.MustRegister(spillDrainDuration)
+	registry.MustRegister(e2eLatencyHistogram)
+	registry.MustRegister(oversizedRowCounter)
 }
@@ -18,6 +18,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -34,44 +35,82 @@ import (
 	"github.com/pingcap/ticdc/pkg/filter"
 	"github.com/pingcap/ticdc/pkg/notify"
 	"github.com/pingcap/ticdc/pkg/security"
+	"github.com/pingcap/ticdc/pkg/usage"
+	"github.com/pingcap/ticdc/pkg/util"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 )
 
 type mqSink struct {
 	mqProducer producer.Producer
-	dispatcher dispatcher.Dispatcher
-	newEncoder func() codec.EventBatchEncoder
-	filter     *filter.Filter
-	protocol   codec.Protocol
-
-	partitionNum   int32
-	partitionInput []chan struct {
-		row        *model.RowChangedEvent
-		resolvedTs uint64
-	}
+	// ddlProducer sends the DDL events to the schema change topic, the DDL
+	// events are sent to the data topic if it's nil.
+	ddlProducer producer.Producer
+	dispatcher  dispatcher.Dispatcher
+	newEncoder  func() codec.EventBatchEncoder
+	filter      *filter.Filter
+	protocol    codec.Protocol
+
+	partitionNum        int32
+	partitionInput      []chan mqEvent
 	partitionResolvedTs []uint64
 	checkpointTs        uint64
 	resolvedNotifier    *notify.Notifier
 	resolvedReceiver    *notify.Receiver
 
+	// resolvedTsInterval is the min interval of broadcasting the resolved ts
+	// messages, the checkpoint ts emitted in between are coalesced into the
+	// next message. Coalescing is disabled if it's 0.
+	resolvedTsInterval time.Duration
+	resolvedTsMu       sync.Mutex
+	pendingResolvedTs  uint64
+	lastResolvedTs     uint64
+	lastResolvedTime   time.Time
+	// heartbeatInterval is the interval of sending the heartbeat messages of
+	// the tables, they are disabled if it's 0.
+	heartbeatInterval time.Duration
+
+	// adaptiveBatch tunes the bytes of a batch flushed by each partition
+	// worker within [minBatchBytes, maxBatchBytes] by the flush latency.
+	adaptiveBatch      bool
+	minBatchBytes      int
+	maxBatchBytes      int
+	targetFlushLatency time.Duration
+
+	// stripGeneratedColumns removes the stored generated columns from the
+	// rows before they are encoded.
+	stripGeneratedColumns bool
+	// logCompaction splits the updates changing the message keys, and skips
+	// the DDL events unless they are sent to the schema change topic, since
+	// the log-compacted topics reject the messages without keys.
+	logCompaction bool
+	keyBuilder    *codec.MessageKeyBuilder
+	// oversizedRow handles the rows exceeding max-message-bytes
+	oversizedRow *oversizedRowHandler
+
 	statistics *Statistics
 }
 
+// mqEvent is the event sent to a partition worker, which is a row, a resolved
+// ts or a claim check message referencing an offloaded row.
+type mqEvent struct {
+	row        *model.RowChangedEvent
+	resolvedTs uint64
+	claimCheck *codec.MQMessage
+}
+
+// defaultResolvedTsInterval is the default min interval of broadcasting the
+// resolved ts messages.
+const defaultResolvedTsInterval = time.Second
+
 func newMqSink(
 	ctx context.Context, credential *security.Credential, mqProducer producer.Producer,
 	filter *filter.Filter, config *config.ReplicaConfig, opts map[string]string, errCh chan error,
 ) (*mqSink, error) {
 	partitionNum := mqProducer.GetPartitionNum()
-	partitionInput := make([]chan struct {
-		row        *model.RowChangedEvent
-		resolvedTs uint64
-	}, partitionNum)
+	partitionInput := make([]chan mqEvent, partitionNum)
 	for i := 0; i < int(partitionNum); i++ {
-		partitionInput[i] = make(chan struct {
-			row        *model.RowChangedEvent
-			resolvedTs uint64
-		}, 12800)
+		partitionInput[i] = make(chan mqEvent, 12800)
 	}
 	d, err := dispatcher.NewDispatcher(config, mqProducer.GetPartitionNum())
 	if err != nil {
@@ -87,18 +126,47 @@ func newMqSink(
 		if !ok {
 			return nil, cerror.ErrPrepareAvroFailed.GenWithStack(`Avro protocol requires parameter "registry"`)
 		}
-		keySchemaManager, err := codec.NewAvroSchemaManager(ctx, credential, registryURI, "-key")
+		// the registry may have its own certificates, the ones of the sink are
+		// used by default
+		registryCredential := credential
+		if opts["registry-ca"] != "" || opts["registry-cert"] != "" || opts["registry-key"] != "" {
+			registryCredential = &security.Credential{
+				CAPath:   opts["registry-ca"],
+				CertPath: opts["registry-cert"],
+				KeyPath:  opts["registry-key"],
+			}
+		}
+		keySchemaManager, err := codec.NewAvroSchemaManager(ctx, registryCredential, registryURI, "-key")
 		if err != nil {
 			return nil, errors.Annotate(
 				cerror.WrapError(cerror.ErrPrepareAvroFailed, err),
 				"Could not create Avro schema manager for message keys")
 		}
-		valueSchemaManager, err := codec.NewAvroSchemaManager(ctx, credential, registryURI, "-value")
+		valueSchemaManager, err := codec.NewAvroSchemaManager(ctx, registryCredential, registryURI, "-value")
 		if err != nil {
 			return nil, errors.Annotate(
 				cerror.WrapError(cerror.ErrPrepareAvroFailed, err),
 				"Could not create Avro schema manager for message values")
 		}
+		for _, manager := range []struct {
+			opt     string
+			manager *codec.AvroSchemaManager
+		}{
+			{"key-subject-name-strategy", keySchemaManager},
+			{"value-subject-name-strategy", valueSchemaManager},
+		} {
+			s, ok := opts[manager.opt]
+			if !ok {
+				continue
+			}
+			strategy, err := codec.ParseSubjectNameStrategy(s)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if err := manager.manager.SetSubjectNameStrategy(strategy, opts[OptTopic]); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
 		newEncoder1 := newEncoder
 		newEncoder = func() codec.EventBatchEncoder {
 			avroEncoder := newEncoder1().(*codec.AvroEventBatchEncoder)
@@ -126,6 +194,107 @@ func newMqSink(
 		return ret
 	}
 
+	keyCfg, err := messageKeyConfig(config)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var keyBuilder *codec.MessageKeyBuilder
+	if keyCfg != nil {
+		keyBuilder, err = codec.NewMessageKeyBuilder(keyCfg)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		setter, ok := newEncoder().(codec.MessageKeySetter)
+		if !ok {
+			return nil, cerror.ErrKafkaInvalidConfig.GenWithStack(
+				"the message key can't be configured for the protocol %s", config.Sink.Protocol)
+		}
+		if err := setter.SetMessageKeyBuilder(keyBuilder); err != nil {
+			return nil, errors.Trace(err)
+		}
+		newEncoder2 := newEncoder
+		newEncoder = func() codec.EventBatchEncoder {
+			ret := newEncoder2()
+			// the builder is verified by the encoder above
+			_ = ret.(codec.MessageKeySetter).SetMessageKeyBuilder(keyBuilder)
+			return ret
+		}
+	}
+	if config.Sink.LogCompaction {
+		if _, ok := newEncoder().(codec.LogCompactionSetter); !ok {
+			return nil, cerror.ErrSinkInvalidConfig.GenWithStack(
+				"the log compaction mode is not supported by the protocol %s", config.Sink.Protocol)
+		}
+		newEncoder3 := newEncoder
+		newEncoder = func() codec.EventBatchEncoder {
+			ret := newEncoder3()
+			ret.(codec.LogCompactionSetter).EnableLogCompaction()
+			return ret
+		}
+	}
+
+	resolvedTsInterval := defaultResolvedTsInterval
+	if s, ok := opts["resolved-ts-interval"]; ok {
+		resolvedTsInterval, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrKafkaInvalidConfig, err)
+		}
+		if resolvedTsInterval < 0 {
+			return nil, cerror.ErrKafkaInvalidConfig.GenWithStack("invalid resolved-ts-interval %s", s)
+		}
+	}
+
+	var heartbeatInterval time.Duration
+	if s, ok := opts["heartbeat-interval"]; ok {
+		heartbeatInterval, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrKafkaInvalidConfig, err)
+		}
+		if heartbeatInterval < 0 {
+			return nil, cerror.ErrKafkaInvalidConfig.GenWithStack("invalid heartbeat-interval %s", s)
+		}
+		if _, ok := newEncoder().(codec.HeartbeatEncoder); heartbeatInterval > 0 && !ok {
+			return nil, cerror.ErrKafkaInvalidConfig.GenWithStack(
+				"the heartbeat messages are not supported by the protocol %s", config.Sink.Protocol)
+		}
+	}
+
+	adaptiveBatch := false
+	if s, ok := opts["adaptive-batch"]; ok {
+		adaptiveBatch, err = strconv.ParseBool(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrKafkaInvalidConfig, err)
+		}
+	}
+	minBatchBytes, maxBatchBytes := defaultMinBatchBytes, batchSizeLimit
+	if s, ok := opts["min-batch-bytes"]; ok {
+		minBatchBytes, err = strconv.Atoi(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrKafkaInvalidConfig, err)
+		}
+	}
+	if s, ok := opts["max-batch-bytes"]; ok {
+		maxBatchBytes, err = strconv.Atoi(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrKafkaInvalidConfig, err)
+		}
+	}
+	if minBatchBytes <= 0 || maxBatchBytes < minBatchBytes {
+		return nil, cerror.ErrKafkaInvalidConfig.GenWithStack(
+			"invalid batch bytes bounds [%d, %d]", minBatchBytes, maxBatchBytes)
+	}
+	targetFlushLatency := defaultTargetFlushLatency
+	if s, ok := opts["target-flush-latency"]; ok {
+		targetFlushLatency, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrKafkaInvalidConfig, err)
+		}
+	}
+	oversizedRow, err := newOversizedRowHandler(ctx, config.Sink, opts, newEncoder)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
 	resolvedReceiver, err := notifier.NewReceiver(50 * time.Millisecond)
 	if err != nil {
 		return nil, err
@@ -142,6 +311,17 @@ func newMqSink(
 		partitionResolvedTs: make([]uint64, partitionNum),
 		resolvedNotifier:    notifier,
 		resolvedReceiver:    resolvedReceiver,
+		resolvedTsInterval:  resolvedTsInterval,
+		heartbeatInterval:   heartbeatInterval,
+		adaptiveBatch:       adaptiveBatch,
+		minBatchBytes:       minBatchBytes,
+		maxBatchBytes:       maxBatchBytes,
+		targetFlushLatency:  targetFlushLatency,
+
+		stripGeneratedColumns: isGeneratedColumnsStripped(config.Sink),
+		logCompaction:         config.Sink.LogCompaction,
+		keyBuilder:            keyBuilder,
+		oversizedRow:          oversizedRow,
 
 		statistics: NewStatistics(ctx, "MQ", opts),
 	}
@@ -167,18 +347,46 @@ func (k *mqSink) EmitRowChangedEvents(ctx context.Context, rows ...*model.RowCha
 			log.Info("Row changed event ignored", zap.Uint64("start-ts", row.StartTs))
 			continue
 		}
-		partition := k.dispatcher.Dispatch(row)
+		if k.logCompaction {
+			deleteRow, insertRow, err := splitKeyChangedUpdate(row, k.keyBuilder)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if deleteRow != nil {
+				if err := k.dispatchRow(ctx, deleteRow); err != nil {
+					return err
+				}
+			}
+			row = insertRow
+		}
+		if err := k.dispatchRow(ctx, row); err != nil {
+			return err
+		}
+		rowsCount++
+	}
+	k.statistics.AddRowsCount(rowsCount)
+	k.statistics.AddCommitTs(rows)
+	return nil
+}
+
+func (k *mqSink) dispatchRow(ctx context.Context, row *model.RowChangedEvent) error {
+	partition := k.dispatcher.Dispatch(row)
+	if k.stripGeneratedColumns {
+		row.PreColumns = stripGeneratedColumns(row.PreColumns)
+		row.Columns = stripGeneratedColumns(row.Columns)
+	}
+	// the events replacing an oversized row are sent to the partition of the row
+	events, err := k.oversizedRow.handle(ctx, row)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, e := range events {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case k.partitionInput[partition] <- struct {
-			row        *model.RowChangedEvent
-			resolvedTs uint64
-		}{row: row}:
+		case k.partitionInput[partition] <- e:
 		}
-		rowsCount++
 	}
-	k.statistics.AddRowsCount(rowsCount)
 	return nil
 }
 
@@ -191,10 +399,7 @@ func (k *mqSink) FlushRowChangedEvents(ctx context.Context, resolvedTs uint64) (
 		select {
 		case <-ctx.Done():
 			return 0, ctx.Err()
-		case k.partitionInput[i] <- struct {
-			row        *model.RowChangedEvent
-			resolvedTs uint64
-		}{resolvedTs: resolvedTs}:
+		case k.partitionInput[i] <- mqEvent{resolvedTs: resolvedTs}:
 		}
 	}
 
@@ -218,11 +423,48 @@ flushLoop:
 		return 0, errors.Trace(err)
 	}
 	k.checkpointTs = resolvedTs
+	k.statistics.ObserveFlushed(resolvedTs)
 	k.statistics.PrintStatus(ctx)
 	return k.checkpointTs, nil
 }
 
 func (k *mqSink) EmitCheckpointTs(ctx context.Context, ts uint64) error {
+	if k.resolvedTsInterval <= 0 {
+		return k.broadcastCheckpointTs(ctx, ts)
+	}
+	k.resolvedTsMu.Lock()
+	defer k.resolvedTsMu.Unlock()
+	if ts <= k.lastResolvedTs {
+		return nil
+	}
+	if time.Since(k.lastResolvedTime) < k.resolvedTsInterval {
+		// coalesce it into the next resolved ts message
+		k.pendingResolvedTs = ts
+		return nil
+	}
+	return k.emitResolvedTsLocked(ctx, ts)
+}
+
+// flushPendingResolvedTs broadcasts the coalesced checkpoint ts if any.
+func (k *mqSink) flushPendingResolvedTs(ctx context.Context) error {
+	k.resolvedTsMu.Lock()
+	defer k.resolvedTsMu.Unlock()
+	if k.pendingResolvedTs <= k.lastResolvedTs {
+		return nil
+	}
+	return k.emitResolvedTsLocked(ctx, k.pendingResolvedTs)
+}
+
+func (k *mqSink) emitResolvedTsLocked(ctx context.Context, ts uint64) error {
+	if err := k.broadcastCheckpointTs(ctx, ts); err != nil {
+		return errors.Trace(err)
+	}
+	k.lastResolvedTs = ts
+	k.lastResolvedTime = time.Now()
+	return nil
+}
+
+func (k *mqSink) broadcastCheckpointTs(ctx context.Context, ts uint64) error {
 	encoder := k.newEncoder()
 	msg, err := encoder.EncodeCheckpointEvent(ts)
 	if err != nil {
@@ -245,6 +487,10 @@ func (k *mqSink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error {
 		)
 		return cerror.ErrDDLEventIgnored.GenWithStackByArgs()
 	}
+	// the consumers should see the checkpoint ts before the DDL
+	if err := k.flushPendingResolvedTs(ctx); err != nil {
+		return errors.Trace(err)
+	}
 	encoder := k.newEncoder()
 	msg, err := encoder.EncodeDDLEvent(ddl)
 	if err != nil {
@@ -254,11 +500,66 @@ func (k *mqSink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error {
 	if msg == nil {
 		return nil
 	}
+	if k.logCompaction && k.ddlProducer == nil {
+		log.Info("DDL event is not sent to the log-compacted topic",
+			zap.String("query", ddl.Query), zap.Uint64("commit-ts", ddl.CommitTs))
+		return nil
+	}
 	log.Debug("emit ddl event", zap.String("query", ddl.Query), zap.Uint64("commit-ts", ddl.CommitTs))
+	if k.ddlProducer != nil {
+		err = k.ddlProducer.SyncBroadcastMessage(ctx, msg.Key, msg.Value)
+		return errors.Trace(err)
+	}
 	err = k.writeToProducer(ctx, msg.Key, msg.Value, codec.EncoderNeedSyncWrite, -1)
 	return errors.Trace(err)
 }
 
+// HeartbeatInterval implements HeartbeatSender.
+func (k *mqSink) HeartbeatInterval() time.Duration {
+	return k.heartbeatInterval
+}
+
+// EmitHeartbeat implements HeartbeatSender, the heartbeat is broadcast to all
+// the partitions since the rows of a table may be sent to any of them.
+func (k *mqSink) EmitHeartbeat(ctx context.Context, table *model.TableName, ts uint64) error {
+	encoder, ok := k.newEncoder().(codec.HeartbeatEncoder)
+	if !ok {
+		return nil
+	}
+	msg, err := encoder.EncodeHeartbeatEvent(table, ts)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if msg == nil {
+		return nil
+	}
+	return errors.Trace(k.writeToProducer(ctx, msg.Key, msg.Value, codec.EncoderNeedSyncWrite, -1))
+}
+
+// EmitBootstrapMessage implements BootstrapMessageSender, the bootstrap DDL is
+// sent like the other DDLs, except the pending checkpoint ts is not flushed
+// since it's called by the processors concurrently with the row events.
+func (k *mqSink) EmitBootstrapMessage(ctx context.Context, ddl *model.DDLEvent) error {
+	if k.filter.ShouldIgnoreTable(ddl.TableInfo.Schema, ddl.TableInfo.Table) {
+		return nil
+	}
+	msg, err := k.newEncoder().EncodeDDLEvent(ddl)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if msg == nil {
+		return nil
+	}
+	log.Info("emit bootstrap message", zap.String("query", ddl.Query), zap.Uint64("start-ts", ddl.StartTs))
+	if k.ddlProducer != nil {
+		return errors.Trace(k.ddlProducer.SyncBroadcastMessage(ctx, msg.Key, msg.Value))
+	}
+	if k.logCompaction {
+		return nil
+	}
+	return errors.Trace(k.writeToProducer(ctx, msg.Key, msg.Value, codec.EncoderNeedSyncWrite, -1))
+}
+
 // Initialize registers Avro schemas for all tables
 func (k *mqSink) Initialize(ctx context.Context, tableInfo []*model.SimpleTableInfo) error {
 	// No longer need it for now
@@ -267,6 +568,16 @@ func (k *mqSink) Initialize(ctx context.Context, tableInfo []*model.SimpleTableI
 
 func (k *mqSink) Close() error {
 	err := k.mqProducer.Close()
+	if k.ddlProducer != nil {
+		if err1 := k.ddlProducer.Close(); err == nil {
+			err = err1
+		}
+	}
+	if k.oversizedRow != nil && k.oversizedRow.dlqProducer != nil {
+		if err1 := k.oversizedRow.dlqProducer.Close(); err == nil {
+			err = err1
+		}
+	}
 	return errors.Trace(err)
 }
 
@@ -275,24 +586,68 @@ func (k *mqSink) run(ctx context.Context) error {
 	wg, ctx := errgroup.WithContext(ctx)
 	for i := int32(0); i < k.partitionNum; i++ {
 		partition := i
-		wg.Go(func() error {
+		wg.Go(util.GuardPanic(ctx, "mq-sink-worker", func() error {
 			return k.runWorker(ctx, partition)
+		}))
+	}
+	if k.resolvedTsInterval > 0 {
+		wg.Go(func() error {
+			return k.runResolvedTsWorker(ctx)
 		})
 	}
 	return wg.Wait()
 }
 
-const batchSizeLimit = 4 * 1024 * 1024 // 4MB
+// runResolvedTsWorker broadcasts the coalesced checkpoint ts periodically, so
+// the last checkpoint ts is sent even if the checkpoint stops advancing.
+func (k *mqSink) runResolvedTsWorker(ctx context.Context) error {
+	tick := time.NewTicker(k.resolvedTsInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+			if err := k.flushPendingResolvedTs(ctx); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+}
+
+const (
+	batchSizeLimit       = 4 * 1024 * 1024 // 4MB
+	defaultMinBatchBytes = 64 * 1024       // 64KB
+)
 
 func (k *mqSink) runWorker(ctx context.Context, partition int32) error {
 	input := k.partitionInput[partition]
 	encoder := k.newEncoder()
+	// the time of encoding the rows is accounted to the changefeed when the
+	// batch is flushed
+	cfUsage := usage.Of(util.ChangefeedIDFromCtx(ctx))
+	var encodeTime time.Duration
 	tick := time.NewTicker(500 * time.Millisecond)
 	defer tick.Stop()
 
+	var batchBytes *adaptiveBatchSize
+	if k.adaptiveBatch {
+		batchBytes = newAdaptiveBatchSize(k.minBatchBytes, k.maxBatchBytes, k.targetFlushLatency)
+	}
+	maxBatchBytes := func() int {
+		if batchBytes != nil {
+			return batchBytes.get()
+		}
+		return batchSizeLimit
+	}
+
 	flushToProducer := func(op codec.EncoderResult) error {
 		return k.statistics.RecordBatchExecution(func() (int, error) {
+			startTime := time.Now()
+			size := encoder.Size()
 			messages := encoder.Build()
+			cfUsage.AddCPUTime(encodeTime + time.Since(startTime))
+			encodeTime = 0
 			thisBatchSize := len(messages)
 			if thisBatchSize == 0 {
 				return 0, nil
@@ -311,15 +666,15 @@ func (k *mqSink) runWorker(ctx context.Context, partition int32) error {
 					return 0, err
 				}
 			}
+			if batchBytes != nil {
+				batchBytes.observe(size, time.Since(startTime))
+			}
 			log.Debug("MQSink flushed", zap.Int("thisBatchSize", thisBatchSize))
 			return thisBatchSize, nil
 		})
 	}
 	for {
-		var e struct {
-			row        *model.RowChangedEvent
-			resolvedTs uint64
-		}
+		var e mqEvent
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -330,6 +685,18 @@ func (k *mqSink) runWorker(ctx context.Context, partition int32) error {
 			continue
 		case e = <-input:
 		}
+		if e.claimCheck != nil {
+			// the rows before the claim check message are sent first to keep
+			// the order in the partition
+			if err := flushToProducer(codec.EncoderNeedAsyncWrite); err != nil {
+				return errors.Trace(err)
+			}
+			err := k.writeToProducer(ctx, e.claimCheck.Key, e.claimCheck.Value, codec.EncoderNeedAsyncWrite, partition)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
 		if e.row == nil {
 			if e.resolvedTs != 0 {
 				op, err := encoder.AppendResolvedEvent(e.resolvedTs)
@@ -346,16 +713,22 @@ func (k *mqSink) runWorker(ctx context.Context, partition int32) error {
 			}
 			continue
 		}
+		if err := cfUsage.WaitCPU(ctx); err != nil {
+			return errors.Trace(err)
+		}
+		encodeStart := time.Now()
 		op, err := encoder.AppendRowChangedEvent(e.row)
 		if err != nil {
 			return errors.Trace(err)
 		}
+		encodeTime += time.Since(encodeStart)
 
-		if encoder.Size() >= batchSizeLimit {
+		full := encoder.Size() >= maxBatchBytes()
+		if full {
 			op = codec.EncoderNeedAsyncWrite
 		}
 
-		if encoder.Size() >= batchSizeLimit || op != codec.EncoderNoOperation {
+		if full || op != codec.EncoderNoOperation {
 			if err := flushToProducer(op); err != nil {
 				return errors.Trace(err)
 			}
@@ -433,6 +806,18 @@ func newKafkaSaramaSink(ctx context.Context, sinkURI *url.URL, filter *filter.Fi
 		opts["max-batch-size"] = s
 	}
 
+	s = sinkURI.Query().Get("resolved-ts-interval")
+	if s != "" {
+		opts["resolved-ts-interval"] = s
+	}
+
+	for _, key := range []string{"adaptive-batch", "min-batch-bytes", "max-batch-bytes", "target-flush-latency", "heartbeat-interval"} {
+		s = sinkURI.Query().Get(key)
+		if s != "" {
+			opts[key] = s
+		}
+	}
+
 	s = sinkURI.Query().Get("compression")
 	if s != "" {
 		config.Compression = s
@@ -460,6 +845,11 @@ func newKafkaSaramaSink(ctx context.Context, sinkURI *url.URL, filter *filter.Fi
 		config.Credential.KeyPath = s
 	}
 
+	s = sinkURI.Query().Get("schema-change-topic")
+	if s != "" {
+		replicaConfig.Sink.SchemaChangeTopic = s
+	}
+
 	s = sinkURI.Query().Get("auto-create-topic")
 	if s != "" {
 		autoCreate, err := strconv.ParseBool(s)
@@ -469,9 +859,41 @@ func newKafkaSaramaSink(ctx context.Context, sinkURI *url.URL, filter *filter.Fi
 		config.TopicPreProcess = autoCreate
 	}
 
+	s = sinkURI.Query().Get("consumer-groups")
+	if s != "" {
+		for _, group := range strings.Split(s, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				config.ConsumerGroups = append(config.ConsumerGroups, group)
+			}
+		}
+	}
+
+	s = sinkURI.Query().Get("consumer-lag-interval")
+	if s != "" {
+		interval, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrKafkaInvalidConfig, err)
+		}
+		if interval <= 0 {
+			return nil, cerror.ErrKafkaInvalidConfig.GenWithStack("invalid consumer-lag-interval %s", s)
+		}
+		config.ConsumerLagInterval = interval
+	}
+
 	topic := strings.TrimFunc(sinkURI.Path, func(r rune) bool {
 		return r == '/'
 	})
+	schemaChangeTopic := replicaConfig.Sink.SchemaChangeTopic
+	if schemaChangeTopic == topic {
+		return nil, cerror.ErrKafkaInvalidConfig.GenWithStack(
+			"the schema change topic %s must be different from the data topic", schemaChangeTopic)
+	}
+	dlqTopic, useDLQ := oversizedRowDLQTopic(replicaConfig.Sink)
+	if useDLQ && (dlqTopic == "" || dlqTopic == topic || dlqTopic == schemaChangeTopic) {
+		return nil, cerror.ErrKafkaInvalidConfig.GenWithStack(
+			"the oversized row DLQ topic %s must be set and different from the data topic and the schema change topic", dlqTopic)
+	}
+	opts[OptTopic] = topic
 	producer, err := kafka.NewKafkaSaramaProducer(ctx, sinkURI.Host, topic, config, errCh)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -480,10 +902,45 @@ func newKafkaSaramaSink(ctx context.Context, sinkURI *url.URL, filter *filter.Fi
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if schemaChangeTopic != "" {
+		// the DDL events are totally ordered, so the topic has only one partition
+		ddlConfig := config
+		ddlConfig.PartitionNum = 1
+		// the lags are only monitored on the data topic
+		ddlConfig.ConsumerGroups = nil
+		sink.ddlProducer, err = kafka.NewKafkaSaramaProducer(ctx, sinkURI.Host, schemaChangeTopic, ddlConfig, errCh)
+		if err != nil {
+			if err1 := producer.Close(); err1 != nil {
+				log.Warn("close kafka producer failed", zap.Error(err1))
+			}
+			return nil, errors.Trace(err)
+		}
+	}
+	if useDLQ {
+		// the oversized rows exceed max-message-bytes of the data topic, the
+		// size of the DLQ messages is limited by the DLQ topic only
+		dlqConfig := config
+		dlqConfig.PartitionNum = 1
+		dlqConfig.ConsumerGroups = nil
+		dlqConfig.MaxMessageBytes = kafka.NewKafkaConfig().MaxMessageBytes
+		sink.oversizedRow.dlqProducer, err = kafka.NewKafkaSaramaProducer(ctx, sinkURI.Host, dlqTopic, dlqConfig, errCh)
+		if err != nil {
+			if err1 := sink.Close(); err1 != nil {
+				log.Warn("close kafka producer failed", zap.Error(err1))
+			}
+			return nil, errors.Trace(err)
+		}
+	}
 	return sink, nil
 }
 
 func newPulsarSink(ctx context.Context, sinkURI *url.URL, filter *filter.Filter, replicaConfig *config.ReplicaConfig, opts map[string]string, errCh chan error) (*mqSink, error) {
+	if replicaConfig.Sink.SchemaChangeTopic != "" || sinkURI.Query().Get("schema-change-topic") != "" {
+		return nil, cerror.ErrSinkURIInvalid.GenWithStack("the schema change topic is not supported by the pulsar sink")
+	}
+	if _, useDLQ := oversizedRowDLQTopic(replicaConfig.Sink); useDLQ {
+		return nil, cerror.ErrSinkURIInvalid.GenWithStack("the oversized row DLQ is not supported by the pulsar sink")
+	}
 	producer, err := pulsar.NewProducer(sinkURI, errCh)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -502,6 +959,18 @@ func newPulsarSink(ctx context.Context, sinkURI *url.URL, filter *filter.Filter,
 	if s != "" {
 		opts["max-batch-size"] = s
 	}
+
+	s = sinkURI.Query().Get("resolved-ts-interval")
+	if s != "" {
+		opts["resolved-ts-interval"] = s
+	}
+
+	for _, key := range []string{"adaptive-batch", "min-batch-bytes", "max-batch-bytes", "target-flush-latency", "heartbeat-interval"} {
+		s = sinkURI.Query().Get(key)
+		if s != "" {
+			opts[key] = s
+		}
+	}
 	// For now, it's a place holder. Avro format have to make connection to Schema Registery,
 	// and it may needs credential.
 	credential := &security.Credential{}
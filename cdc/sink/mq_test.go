@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/ticdc/cdc/sink/codec"
@@ -217,3 +218,95 @@ func (s mqSinkSuite) TestPulsarSinkEncoderConfig(c *check.C) {
 	c.Assert(encoder.(*codec.JSONEventBatchEncoder).GetMaxBatchSize(), check.Equals, 1)
 	c.Assert(encoder.(*codec.JSONEventBatchEncoder).GetMaxKafkaMessageSize(), check.Equals, 4194304)
 }
+
+// broadcastCountProducer counts the broadcast messages.
+type broadcastCountProducer struct {
+	broadcasts int
+}
+
+func (p *broadcastCountProducer) SendMessage(ctx context.Context, key []byte, value []byte, partition int32) error {
+	return nil
+}
+
+func (p *broadcastCountProducer) SyncBroadcastMessage(ctx context.Context, key []byte, value []byte) error {
+	p.broadcasts++
+	return nil
+}
+
+func (p *broadcastCountProducer) Flush(ctx context.Context) error { return nil }
+
+func (p *broadcastCountProducer) GetPartitionNum() int32 { return 1 }
+
+func (p *broadcastCountProducer) Close() error { return nil }
+
+func (s mqSinkSuite) TestCoalesceResolvedTs(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	producer := &broadcastCountProducer{}
+	sink := &mqSink{
+		mqProducer:         producer,
+		newEncoder:         codec.NewJSONEventBatchEncoder,
+		resolvedTsInterval: time.Hour,
+	}
+
+	// the first checkpoint ts is sent immediately
+	c.Assert(sink.EmitCheckpointTs(ctx, 100), check.IsNil)
+	c.Assert(producer.broadcasts, check.Equals, 1)
+	for ts := uint64(101); ts <= 200; ts++ {
+		c.Assert(sink.EmitCheckpointTs(ctx, ts), check.IsNil)
+	}
+	c.Assert(producer.broadcasts, check.Equals, 1)
+	c.Assert(sink.pendingResolvedTs, check.Equals, uint64(200))
+
+	c.Assert(sink.flushPendingResolvedTs(ctx), check.IsNil)
+	c.Assert(producer.broadcasts, check.Equals, 2)
+	c.Assert(sink.lastResolvedTs, check.Equals, uint64(200))
+	// nothing is pending
+	c.Assert(sink.flushPendingResolvedTs(ctx), check.IsNil)
+	c.Assert(producer.broadcasts, check.Equals, 2)
+
+	// the older checkpoint ts is dropped
+	sink.lastResolvedTime = time.Time{}
+	c.Assert(sink.EmitCheckpointTs(ctx, 150), check.IsNil)
+	c.Assert(producer.broadcasts, check.Equals, 2)
+
+	// every checkpoint ts is sent if coalescing is disabled
+	sink.resolvedTsInterval = 0
+	for ts := uint64(201); ts <= 210; ts++ {
+		c.Assert(sink.EmitCheckpointTs(ctx, ts), check.IsNil)
+	}
+	c.Assert(producer.broadcasts, check.Equals, 12)
+}
+
+func (s mqSinkSuite) TestSchemaChangeTopic(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	fr, err := filter.NewFilter(config.GetDefaultReplicaConfig())
+	c.Assert(err, check.IsNil)
+	dataProducer := &broadcastCountProducer{}
+	ddlProducer := &broadcastCountProducer{}
+	sink := &mqSink{
+		mqProducer:  dataProducer,
+		ddlProducer: ddlProducer,
+		newEncoder:  codec.NewJSONEventBatchEncoder,
+		filter:      fr,
+	}
+
+	ddl := &model.DDLEvent{
+		StartTs:  130,
+		CommitTs: 140,
+		TableInfo: &model.SimpleTableInfo{
+			Schema: "a", Table: "b",
+		},
+		Query: "create table a",
+		Type:  1,
+	}
+	c.Assert(sink.EmitDDLEvent(ctx, ddl), check.IsNil)
+	c.Assert(ddlProducer.broadcasts, check.Equals, 1)
+	c.Assert(dataProducer.broadcasts, check.Equals, 0)
+
+	// the checkpoint ts is still sent to the data topic
+	c.Assert(sink.EmitCheckpointTs(ctx, 150), check.IsNil)
+	c.Assert(ddlProducer.broadcasts, check.Equals, 1)
+	c.Assert(dataProducer.broadcasts, check.Equals, 1)
+}
@@ -25,7 +25,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/cenkalti/backoff"
 	dmysql "github.com/go-sql-driver/mysql"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
@@ -37,6 +36,7 @@ import (
 	"github.com/pingcap/ticdc/pkg/config"
 	"github.com/pingcap/ticdc/pkg/cyclic"
 	"github.com/pingcap/ticdc/pkg/cyclic/mark"
+	"github.com/pingcap/ticdc/pkg/dbbalancer"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/ticdc/pkg/filter"
 	tifilter "github.com/pingcap/ticdc/pkg/filter"
@@ -54,16 +54,45 @@ import (
 const (
 	defaultWorkerCount         = 16
 	defaultMaxTxnRow           = 256
+	defaultMinTxnRow           = 16
 	defaultDMLMaxRetryTime     = 8
 	defaultDDLMaxRetryTime     = 20
 	defaultTiDBTxnMode         = "optimistic"
 	defaultFlushInterval       = time.Millisecond * 50
 	defaultBatchReplaceEnabled = true
 	defaultBatchReplaceSize    = 20
+	defaultBatchDeleteEnabled  = true
+	defaultBatchDeleteSize     = 128
+	defaultUpsertStrategy      = upsertReplace
 	defaultReadTimeout         = "2m"
 	defaultWriteTimeout        = "2m"
 	defaultDialTimeout         = "2m"
 	defaultSafeMode            = true
+	// defaultConnMaxLifetime is shorter than the idle timeouts of the common
+	// proxies, such as 15 minutes of LVS, so the connections are recycled
+	// before they are killed silently.
+	defaultConnMaxLifetime = 10 * time.Minute
+	// the DDLs are executed again after the changefeed fails over, so the
+	// errors that the objects exist or not are ignored by default.
+	defaultDDLSkipExistenceErrors = true
+	// the delays between the retries of the DMLs and DDLs grow from 500ms
+	// to backoffMaxDelay. The DDLs are retried far more times, so their delays
+	// grow to ddlBackoffMaxDelay and the retries stop after ddlRetryDuration.
+	backoffBaseDelay   = 500 * time.Millisecond
+	backoffMaxDelay    = 10 * time.Second
+	ddlBackoffMaxDelay = time.Minute
+	ddlRetryDuration   = 15 * time.Minute
+)
+
+// The strategies of writing the rows in safe mode.
+const (
+	// upsertReplace writes the rows by REPLACE, which deletes the conflicting
+	// rows and inserts the new ones.
+	upsertReplace = "replace"
+	// upsertOnDuplicateKeyUpdate writes the rows by INSERT ... ON DUPLICATE
+	// KEY UPDATE, which updates the conflicting rows in place, so the indexes
+	// not changed are not rewritten.
+	upsertOnDuplicateKeyUpdate = "on-duplicate-key-update"
 )
 
 // SyncpointTableName is the name of table where all syncpoint maps sit
@@ -102,11 +131,18 @@ type mysqlSink struct {
 	metricBucketSizeCounters        []prometheus.Counter
 
 	forceReplicate bool
+	// emitGeneratedColumns writes the stored generated columns to the
+	// downstream, where they are normal columns.
+	emitGeneratedColumns bool
+	// downstreamSchemas is set if the DMLs are adapted to the downstream
+	// tables or the downstream tables are checked.
+	downstreamSchemas *downstreamSchemas
 }
 
 func (s *mysqlSink) EmitRowChangedEvents(ctx context.Context, rows ...*model.RowChangedEvent) error {
 	count := s.txnCache.Append(s.filter, rows...)
 	s.statistics.AddRowsCount(count)
+	s.statistics.AddCommitTs(rows)
 	return nil
 }
 
@@ -128,6 +164,7 @@ func (s *mysqlSink) FlushRowChangedEvents(ctx context.Context, resolvedTs uint64
 			checkpointTs = workerCheckpointTs
 		}
 	}
+	s.statistics.ObserveFlushed(checkpointTs)
 	s.statistics.PrintStatus(ctx)
 	return checkpointTs, nil
 }
@@ -183,31 +220,75 @@ func (s *mysqlSink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error
 		)
 		return cerror.ErrDDLEventIgnored.GenWithStackByArgs()
 	}
-	err := s.execDDLWithMaxRetries(ctx, ddl, defaultDDLMaxRetryTime)
+	if s.params.schemaSuffix != "" {
+		shadow, err := shadowDDL(ddl, s.params.schemaSuffix)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		ddl = shadow
+	}
+	err := s.execDDLWithMaxRetries(ctx, ddl, s.params.ddlMaxRetries)
+	if s.downstreamSchemas != nil {
+		// the downstream tables may be changed by the DDL even if it fails
+		s.downstreamSchemas.reset()
+	}
 	return errors.Trace(err)
 }
 
+// Warnings implements WarningReporter, it returns the divergences between the
+// upstream and downstream tables.
+func (s *mysqlSink) Warnings() []*model.RunningError {
+	if s.downstreamSchemas == nil {
+		return nil
+	}
+	return s.downstreamSchemas.warnings()
+}
+
 // Initialize is no-op for Mysql sink
 func (s *mysqlSink) Initialize(ctx context.Context, tableInfo []*model.SimpleTableInfo) error {
 	return nil
 }
 
 func (s *mysqlSink) execDDLWithMaxRetries(ctx context.Context, ddl *model.DDLEvent, maxRetries uint64) error {
-	return retry.Run(500*time.Millisecond, maxRetries,
-		func() error {
-			err := s.execDDL(ctx, ddl)
-			if isIgnorableDDLError(err) {
-				log.Info("execute DDL failed, but error can be ignored", zap.String("query", ddl.Query), zap.Error(err))
-				return nil
-			}
-			if errors.Cause(err) == context.Canceled {
-				return backoff.Permanent(err)
-			}
-			if err != nil {
-				log.Warn("execute DDL with error, retry later", zap.String("query", ddl.Query), zap.Error(err))
-			}
+	return retry.Do(ctx, func() error {
+		err := s.execDDLWithTimeout(ctx, ddl)
+		if err == nil {
+			return nil
+		}
+		// the DDL may have been executed before the failover of the
+		// changefeed or the timeout of the last attempt
+		if s.params.ddlSkipExistenceErrors && isIgnorableDDLError(err) {
+			log.Info("execute DDL failed, but error can be ignored", zap.String("query", ddl.Query), zap.Error(err))
+			return nil
+		}
+		if errors.Cause(err) == context.Canceled || !isRetryableDDLError(err) {
+			log.Warn("execute DDL failed", zap.String("query", ddl.Query), zap.Error(err))
 			return err
-		})
+		}
+		log.Warn("execute DDL with error, retry later", zap.String("query", ddl.Query), zap.Error(err))
+		return err
+	}, retry.WithBackoffBaseDelay(backoffBaseDelay),
+		retry.WithBackoffMaxDelay(ddlBackoffMaxDelay),
+		retry.WithMaxTries(maxRetries+1),
+		retry.WithTotalRetryDuration(ddlRetryDuration),
+		retry.WithIsRetryableErr(isRetryableDDLError))
+}
+
+// execDDLWithTimeout executes the DDL within the timeout of the sink. TiDB
+// keeps running the DDL job after the timeout, so the error of the retry that
+// the object exists or not is ignored.
+func (s *mysqlSink) execDDLWithTimeout(ctx context.Context, ddl *model.DDLEvent) error {
+	if s.params.ddlTimeout == 0 {
+		return s.execDDL(ctx, ddl)
+	}
+	execCtx, cancel := context.WithTimeout(ctx, s.params.ddlTimeout)
+	defer cancel()
+	err := s.execDDL(execCtx, ddl)
+	if err != nil && ctx.Err() == nil && execCtx.Err() == context.DeadlineExceeded {
+		// retry.Do stops retrying on context.DeadlineExceeded
+		return cerror.ErrMySQLDDLTimeout.GenWithStackByArgs(s.params.ddlTimeout)
+	}
+	return err
 }
 
 func (s *mysqlSink) execDDL(ctx context.Context, ddl *model.DDLEvent) error {
@@ -274,11 +355,17 @@ func (s *mysqlSink) adjustSQLMode(ctx context.Context) error {
 	return nil
 }
 
-var _ Sink = &mysqlSink{}
+var (
+	_ Sink        = &mysqlSink{}
+	_ TxnExecutor = &mysqlSink{}
+)
 
 type sinkParams struct {
 	workerCount         int
 	maxTxnRow           int
+	adaptiveBatch       bool
+	minTxnRow           int
+	targetFlushLatency  time.Duration
 	tidbTxnMode         string
 	changefeedID        string
 	captureAddr         string
@@ -291,6 +378,33 @@ type sinkParams struct {
 	safeMode            bool
 	timezone            string
 	tls                 string
+	// adaptDownstreamSchema omits the columns absent in the downstream
+	// tables, and fills the NOT NULL columns absent in the upstream.
+	adaptDownstreamSchema bool
+	// schemaCheckInterval is the interval of introspecting the downstream
+	// tables and comparing them with the upstream ones, 0 means disabled.
+	schemaCheckInterval time.Duration
+	// maxOpenConns and maxIdleConns are the limits of the connection pool, 0
+	// means the worker count.
+	maxOpenConns int
+	maxIdleConns int
+	// connMaxLifetime is the max lifetime of a connection, 0 means unlimited.
+	connMaxLifetime time.Duration
+	// batchDeleteEnabled merges the deletes of a table by a single-column key
+	// into `DELETE ... WHERE key IN (...)`.
+	batchDeleteEnabled bool
+	batchDeleteSize    int
+	// upsertStrategy is the statement writing the rows in safe mode.
+	upsertStrategy string
+	// ddlTimeout is the timeout of executing a DDL, 0 means unlimited.
+	ddlTimeout    time.Duration
+	ddlMaxRetries uint64
+	// ddlSkipExistenceErrors ignores the errors that the object of the DDL
+	// exists or not, which are returned when the DDL is executed again.
+	ddlSkipExistenceErrors bool
+	// schemaSuffix is appended to the names of the schemas written, such as
+	// the shadow schemas of a shadow changefeed.
+	schemaSuffix string
 }
 
 func (s *sinkParams) Clone() *sinkParams {
@@ -298,16 +412,40 @@ func (s *sinkParams) Clone() *sinkParams {
 	return &clone
 }
 
+// configureConnPool sets the limits of the connection pool of db.
+func (s *sinkParams) configureConnPool(db *sql.DB) {
+	maxOpenConns, maxIdleConns := s.maxOpenConns, s.maxIdleConns
+	if maxOpenConns == 0 {
+		maxOpenConns = s.workerCount
+	}
+	if maxIdleConns == 0 {
+		maxIdleConns = s.workerCount
+	}
+	db.SetMaxOpenConns(maxOpenConns)
+	// the idle connections more than the open ones are closed by sql.DB
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(s.connMaxLifetime)
+}
+
 var defaultParams = &sinkParams{
 	workerCount:         defaultWorkerCount,
 	maxTxnRow:           defaultMaxTxnRow,
+	minTxnRow:           defaultMinTxnRow,
+	targetFlushLatency:  defaultTargetFlushLatency,
 	tidbTxnMode:         defaultTiDBTxnMode,
 	batchReplaceEnabled: defaultBatchReplaceEnabled,
 	batchReplaceSize:    defaultBatchReplaceSize,
+	batchDeleteEnabled:  defaultBatchDeleteEnabled,
+	batchDeleteSize:     defaultBatchDeleteSize,
+	upsertStrategy:      defaultUpsertStrategy,
+	ddlMaxRetries:       defaultDDLMaxRetryTime,
 	readTimeout:         defaultReadTimeout,
 	writeTimeout:        defaultWriteTimeout,
 	dialTimeout:         defaultDialTimeout,
 	safeMode:            defaultSafeMode,
+	connMaxLifetime:     defaultConnMaxLifetime,
+
+	ddlSkipExistenceErrors: defaultDDLSkipExistenceErrors,
 }
 
 func checkTiDBVariable(ctx context.Context, db *sql.DB, variableName, defaultValue string) (string, error) {
@@ -405,6 +543,30 @@ func parseSinkURI(ctx context.Context, sinkURI *url.URL, opts map[string]string)
 		}
 		params.maxTxnRow = c
 	}
+	s = sinkURI.Query().Get("adaptive-batch")
+	if s != "" {
+		enable, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLInvalidConfig, err)
+		}
+		params.adaptiveBatch = enable
+	}
+	s = sinkURI.Query().Get("min-txn-row")
+	if s != "" {
+		c, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLInvalidConfig, err)
+		}
+		params.minTxnRow = c
+	}
+	s = sinkURI.Query().Get("target-flush-latency")
+	if s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLInvalidConfig, err)
+		}
+		params.targetFlushLatency = d
+	}
 	s = sinkURI.Query().Get("tidb-txn-mode")
 	if s != "" {
 		if s == "pessimistic" || s == "optimistic" {
@@ -448,6 +610,40 @@ func parseSinkURI(ctx context.Context, sinkURI *url.URL, opts map[string]string)
 		params.batchReplaceSize = size
 	}
 
+	s = sinkURI.Query().Get("batch-delete-enable")
+	if s != "" {
+		enable, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLInvalidConfig, err)
+		}
+		params.batchDeleteEnabled = enable
+	}
+	if params.batchDeleteEnabled && sinkURI.Query().Get("batch-delete-size") != "" {
+		size, err := strconv.Atoi(sinkURI.Query().Get("batch-delete-size"))
+		if err != nil || size <= 0 {
+			return nil, cerror.ErrMySQLInvalidConfig.GenWithStack(
+				"invalid batch-delete-size %s", sinkURI.Query().Get("batch-delete-size"))
+		}
+		params.batchDeleteSize = size
+	}
+
+	s = sinkURI.Query().Get("adapt-downstream-schema")
+	if s != "" {
+		enable, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLInvalidConfig, err)
+		}
+		params.adaptDownstreamSchema = enable
+	}
+	s = sinkURI.Query().Get("schema-check-interval")
+	if s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLInvalidConfig, err)
+		}
+		params.schemaCheckInterval = d
+	}
+
 	// TODO: force safe mode in startup phase
 	s = sinkURI.Query().Get("safe-mode")
 	if s != "" {
@@ -487,6 +683,74 @@ func parseSinkURI(ctx context.Context, sinkURI *url.URL, opts map[string]string)
 		params.dialTimeout = s
 	}
 
+	// the limits of the connection pool, ref: https://golang.org/pkg/database/sql/#DB.SetMaxOpenConns
+	s = sinkURI.Query().Get("max-open-conns")
+	if s != "" {
+		c, err := strconv.Atoi(s)
+		if err != nil || c < 0 {
+			return nil, cerror.ErrMySQLInvalidConfig.GenWithStack("invalid max-open-conns %s", s)
+		}
+		if c > 0 && c < params.workerCount {
+			log.Warn("max-open-conns is less than worker-count, the workers may wait for the connections",
+				zap.Int("max-open-conns", c), zap.Int("worker-count", params.workerCount))
+		}
+		params.maxOpenConns = c
+	}
+	s = sinkURI.Query().Get("max-idle-conns")
+	if s != "" {
+		c, err := strconv.Atoi(s)
+		if err != nil || c < 0 {
+			return nil, cerror.ErrMySQLInvalidConfig.GenWithStack("invalid max-idle-conns %s", s)
+		}
+		params.maxIdleConns = c
+	}
+	s = sinkURI.Query().Get("conn-max-lifetime")
+	if s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return nil, cerror.ErrMySQLInvalidConfig.GenWithStack("invalid conn-max-lifetime %s", s)
+		}
+		params.connMaxLifetime = d
+	}
+
+	s = sinkURI.Query().Get("upsert-strategy")
+	if s != "" {
+		switch s {
+		case upsertReplace, upsertOnDuplicateKeyUpdate:
+			params.upsertStrategy = s
+		default:
+			return nil, cerror.ErrMySQLInvalidConfig.GenWithStack(
+				"invalid upsert-strategy %s, should be %s or %s", s, upsertReplace, upsertOnDuplicateKeyUpdate)
+		}
+	}
+
+	s = sinkURI.Query().Get("ddl-timeout")
+	if s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return nil, cerror.ErrMySQLInvalidConfig.GenWithStack("invalid ddl-timeout %s", s)
+		}
+		params.ddlTimeout = d
+	}
+	s = sinkURI.Query().Get("ddl-max-retries")
+	if s != "" {
+		retries, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, cerror.ErrMySQLInvalidConfig.GenWithStack("invalid ddl-max-retries %s", s)
+		}
+		params.ddlMaxRetries = retries
+	}
+	params.schemaSuffix = sinkURI.Query().Get("schema-suffix")
+
+	s = sinkURI.Query().Get("ddl-skip-existence-errors")
+	if s != "" {
+		skip, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLInvalidConfig, err)
+		}
+		params.ddlSkipExistenceErrors = skip
+	}
+
 	return params, nil
 }
 
@@ -531,15 +795,15 @@ func newMySQLSink(
 	// [username[:password]@][protocol[(address)]]/dbname[?param1=value1&...&paramN=valueN]
 	username := sinkURI.User.Username()
 	password, _ := sinkURI.User.Password()
-	port := sinkURI.Port()
 	if username == "" {
 		username = "root"
 	}
-	if port == "" {
-		port = "4000"
+	network, addr, err := dbbalancer.Resolve(sinkURI, "mysql-"+changefeedID)
+	if err != nil {
+		return nil, errors.Trace(err)
 	}
 
-	dsnStr := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", username, password, sinkURI.Hostname(), port, params.tls)
+	dsnStr := fmt.Sprintf("%s:%s@%s(%s)/%s", username, password, network, addr, params.tls)
 	dsn, err := dmysql.ParseDSN(dsnStr)
 	if err != nil {
 		return nil, cerror.WrapError(cerror.ErrMySQLInvalidConfig, err)
@@ -572,8 +836,7 @@ func newMySQLSink(
 
 	log.Info("Start mysql sink")
 
-	db.SetMaxIdleConns(params.workerCount)
-	db.SetMaxOpenConns(params.workerCount)
+	params.configureConnPool(db)
 
 	metricConflictDetectDurationHis := conflictDetectDurationHis.WithLabelValues(
 		params.captureAddr, params.changefeedID)
@@ -593,6 +856,10 @@ func newMySQLSink(
 		metricBucketSizeCounters:        metricBucketSizeCounters,
 		errCh:                           make(chan error, 1),
 		forceReplicate:                  replicaConfig.ForceReplicate,
+		emitGeneratedColumns:            replicaConfig.Sink.GeneratedColumns == config.GeneratedColumnsEmit,
+	}
+	if params.adaptDownstreamSchema || params.schemaCheckInterval > 0 {
+		sink.downstreamSchemas = newDownstreamSchemas(db, params.schemaCheckInterval, params.schemaSuffix)
 	}
 
 	if val, ok := opts[mark.OptCyclicConfig]; ok {
@@ -630,6 +897,10 @@ func (s *mysqlSink) createSinkWorkers(ctx context.Context) error {
 		}
 		worker := newMySQLSinkWorker(
 			s.params.maxTxnRow, i, s.metricBucketSizeCounters[i], receiver, s.execDMLs)
+		if s.params.adaptiveBatch {
+			worker.batchSize = newAdaptiveBatchSize(
+				s.params.minTxnRow, s.params.maxTxnRow, s.params.targetFlushLatency)
+		}
 		s.workers[i] = worker
 		go func() {
 			err := worker.run(ctx)
@@ -704,6 +975,9 @@ type mysqlSinkWorker struct {
 	metricBucketSize prometheus.Counter
 	receiver         *notify.Receiver
 	checkpointTs     uint64
+	// batchSize tunes the max rows of a batch if the adaptive batching is
+	// enabled, otherwise maxTxnRow is used.
+	batchSize *adaptiveBatchSize
 }
 
 func newMySQLSinkWorker(
@@ -723,6 +997,13 @@ func newMySQLSinkWorker(
 	}
 }
 
+func (w *mysqlSinkWorker) maxBatchRows() int {
+	if w.batchSize != nil {
+		return w.batchSize.get()
+	}
+	return w.maxTxnRow
+}
+
 func (w *mysqlSinkWorker) waitAllTxnsExecuted() {
 	w.txnWg.Wait()
 }
@@ -764,12 +1045,16 @@ func (w *mysqlSinkWorker) run(ctx context.Context) (err error) {
 		}
 		rows := make([]*model.RowChangedEvent, len(toExecRows))
 		copy(rows, toExecRows)
+		startTime := time.Now()
 		err := w.execDMLs(ctx, rows, replicaID, w.bucket)
 		if err != nil {
 			w.txnWg.Add(-1 * txnNum)
 			txnNum = 0
 			return err
 		}
+		if w.batchSize != nil {
+			w.batchSize.observe(len(rows), time.Since(startTime))
+		}
 		atomic.StoreUint64(&w.checkpointTs, lastCommitTs)
 		toExecRows = toExecRows[:0]
 		w.metricBucketSize.Add(float64(txnNum))
@@ -786,7 +1071,7 @@ func (w *mysqlSinkWorker) run(ctx context.Context) (err error) {
 			if txn == nil {
 				return errors.Trace(flushRows())
 			}
-			if txn.ReplicaID != replicaID || len(toExecRows)+len(txn.Rows) > w.maxTxnRow {
+			if txn.ReplicaID != replicaID || len(toExecRows)+len(txn.Rows) > w.maxBatchRows() {
 				if err := flushRows(); err != nil {
 					return errors.Trace(err)
 				}
@@ -820,12 +1105,16 @@ func (s *mysqlSink) execDMLWithMaxRetries(
 	}
 	checkTxnErr := func(err error) error {
 		if errors.Cause(err) == context.Canceled {
-			return backoff.Permanent(err)
+			return err
 		}
 		log.Warn("execute DMLs with error, retry later", zap.Error(err))
+		// the execution is called by the worker of the bucket
+		if bucket < len(s.workers) && s.workers[bucket].batchSize != nil {
+			s.workers[bucket].batchSize.observeError()
+		}
 		return err
 	}
-	return retry.Run(500*time.Millisecond, maxRetries,
+	return retry.Do(ctx,
 		func() error {
 			failpoint.Inject("MySQLSinkTxnRandomError", func() {
 				failpoint.Return(checkTxnErr(errors.Trace(dmysql.ErrInvalidConn)))
@@ -871,6 +1160,9 @@ func (s *mysqlSink) execDMLWithMaxRetries(
 				zap.Int("bucket", bucket))
 			return nil
 		},
+		retry.WithBackoffBaseDelay(backoffBaseDelay),
+		retry.WithBackoffMaxDelay(backoffMaxDelay),
+		retry.WithMaxTries(maxRetries+1),
 	)
 }
 
@@ -886,28 +1178,68 @@ func (s *mysqlSink) prepareDMLs(rows []*model.RowChangedEvent, replicaID uint64,
 	sqls := make([]string, 0, len(rows))
 	values := make([][]interface{}, 0, len(rows))
 	replaces := make(map[string][][]interface{})
+	// the ON DUPLICATE KEY UPDATE clauses of the cached upserts
+	upsertClauses := make(map[string]string)
+	// the cached deletes in the order of the tables first deleted from
+	var deletes []*batchDelete
+	deleteIdx := make(map[string]int)
 	rowCount := 0
 	translateToInsert := s.params.enableOldValue && !s.params.safeMode
 
-	// flush cached batch replace or insert, to keep the sequence of DMLs
-	flushCacheDMLs := func() {
+	flushReplaces := func() {
 		if s.params.batchReplaceEnabled && len(replaces) > 0 {
-			replaceSqls, replaceValues := reduceReplace(replaces, s.params.batchReplaceSize)
+			replaceSqls, replaceValues := reduceReplace(replaces, s.params.batchReplaceSize, upsertClauses)
 			sqls = append(sqls, replaceSqls...)
 			values = append(values, replaceValues...)
 			replaces = make(map[string][][]interface{})
+			upsertClauses = make(map[string]string)
+		}
+	}
+	flushDeletes := func() {
+		for _, d := range deletes {
+			deleteSqls, deleteValues := d.reduce(s.params.batchDeleteSize)
+			sqls = append(sqls, deleteSqls...)
+			values = append(values, deleteValues...)
 		}
+		if len(deletes) > 0 {
+			deletes = nil
+			deleteIdx = make(map[string]int)
+		}
+	}
+	// flush cached batch replace or insert and batch delete, to keep the sequence of DMLs.
+	// Only one of them is cached at the same time.
+	flushCacheDMLs := func() {
+		flushReplaces()
+		flushDeletes()
 	}
 
 	for _, row := range rows {
 		var query string
 		var args []interface{}
-		quoteTable := quotes.QuoteSchema(row.Table.Schema, row.Table.Table)
+		quoteTable := quotes.QuoteSchema(row.Table.Schema+s.params.schemaSuffix, row.Table.Table)
+		if s.emitGeneratedColumns {
+			emitGeneratedColumns(row.PreColumns)
+			emitGeneratedColumns(row.Columns)
+		}
+		preCols, cols, replaceCols := row.PreColumns, row.Columns, row.Columns
+		if s.params.adaptDownstreamSchema {
+			if table := s.downstreamSchemas.get(quoteTable); table != nil {
+				if len(preCols) != 0 {
+					preCols = table.adjust(preCols, false)
+				}
+				if len(cols) != 0 {
+					cols = table.adjust(cols, false)
+					// the absent columns are only filled in the new rows,
+					// the existing values are kept by the UPDATE
+					replaceCols = table.adjust(cols, true)
+				}
+			}
+		}
 
 		// Translate to UPDATE if old value is enabled, not in safe mode and is update event
-		if translateToInsert && len(row.PreColumns) != 0 && len(row.Columns) != 0 {
+		if translateToInsert && len(preCols) != 0 && len(cols) != 0 {
 			flushCacheDMLs()
-			query, args = prepareUpdate(quoteTable, row.PreColumns, row.Columns, s.forceReplicate)
+			query, args = prepareUpdate(quoteTable, preCols, cols, s.forceReplicate)
 			if query != "" {
 				sqls = append(sqls, query)
 				values = append(values, args)
@@ -916,12 +1248,33 @@ func (s *mysqlSink) prepareDMLs(rows []*model.RowChangedEvent, replicaID uint64,
 			continue
 		}
 
+		// Case for delete event by a single-column key, which is cached
+		if s.params.batchDeleteEnabled && len(preCols) != 0 && len(replaceCols) == 0 {
+			if column, key, ok := singleKeyOfDelete(preCols, s.forceReplicate); ok {
+				flushReplaces()
+				idx, ok := deleteIdx[quoteTable]
+				if !ok || deletes[idx].column != column {
+					if ok {
+						// the key of the table is changed, the cached deletes
+						// must be executed first
+						flushDeletes()
+					}
+					deleteIdx[quoteTable] = len(deletes)
+					deletes = append(deletes, &batchDelete{quoteTable: quoteTable, column: column})
+					idx = deleteIdx[quoteTable]
+				}
+				deletes[idx].keys = append(deletes[idx].keys, key)
+				rowCount++
+				continue
+			}
+		}
+
 		// Case for delete event or update event
 		// If old value is enabled and not in safe mode,
 		// update will be translated to DELETE + INSERT(or REPLACE) SQL.
-		if len(row.PreColumns) != 0 {
+		if len(preCols) != 0 {
 			flushCacheDMLs()
-			query, args = prepareDelete(quoteTable, row.PreColumns, s.forceReplicate)
+			query, args = prepareDelete(quoteTable, preCols, s.forceReplicate)
 			if query != "" {
 				sqls = append(sqls, query)
 				values = append(values, args)
@@ -930,9 +1283,28 @@ func (s *mysqlSink) prepareDMLs(rows []*model.RowChangedEvent, replicaID uint64,
 		}
 
 		// Case for insert event or update event
-		if len(row.Columns) != 0 {
+		if len(replaceCols) != 0 {
+			flushDeletes()
+			if !translateToInsert && s.params.upsertStrategy == upsertOnDuplicateKeyUpdate {
+				query, args = prepareReplace(quoteTable, replaceCols, false /* appendPlaceHolder */, true /* translateToInsert */)
+				if query == "" {
+					continue
+				}
+				// the columns filled for the downstream schema are only
+				// inserted, the existing values are kept
+				clause := upsertClause(cols)
+				if s.params.batchReplaceEnabled {
+					replaces[query] = append(replaces[query], args)
+					upsertClauses[query] = clause
+				} else {
+					sqls = append(sqls, query+"("+model.HolderString(len(args))+")"+clause+";")
+					values = append(values, args)
+				}
+				rowCount++
+				continue
+			}
 			if s.params.batchReplaceEnabled {
-				query, args = prepareReplace(quoteTable, row.Columns, false /* appendPlaceHolder */, translateToInsert)
+				query, args = prepareReplace(quoteTable, replaceCols, false /* appendPlaceHolder */, translateToInsert)
 				if query != "" {
 					if _, ok := replaces[query]; !ok {
 						replaces[query] = make([][]interface{}, 0)
@@ -941,7 +1313,7 @@ func (s *mysqlSink) prepareDMLs(rows []*model.RowChangedEvent, replicaID uint64,
 					rowCount++
 				}
 			} else {
-				query, args = prepareReplace(quoteTable, row.Columns, true /* appendPlaceHolder */, translateToInsert)
+				query, args = prepareReplace(quoteTable, replaceCols, true /* appendPlaceHolder */, translateToInsert)
 				sqls = append(sqls, query)
 				values = append(values, args)
 				if query != "" {
@@ -982,6 +1354,11 @@ func (s *mysqlSink) execDMLs(ctx context.Context, rows []*model.RowChangedEvent,
 		time.Sleep(time.Second * 2)
 		failpoint.Return(errors.Trace(dmysql.ErrInvalidConn))
 	})
+	if s.downstreamSchemas != nil {
+		if err := s.downstreamSchemas.load(ctx, rows); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	dmls := s.prepareDMLs(rows, replicaID, bucket)
 	log.Debug("prepare DMLs", zap.Any("rows", rows), zap.Strings("sqls", dmls.sqls), zap.Any("values", dmls.values))
 	if err := s.execDMLWithMaxRetries(ctx, dmls, defaultDMLMaxRetryTime, bucket); err != nil {
@@ -997,6 +1374,21 @@ func (s *mysqlSink) execDMLs(ctx context.Context, rows []*model.RowChangedEvent,
 	return nil
 }
 
+// ExecRowsInTxn implements TxnExecutor, the query is executed after the rows.
+func (s *mysqlSink) ExecRowsInTxn(
+	ctx context.Context, rows []*model.RowChangedEvent, query string, args ...interface{},
+) error {
+	if s.downstreamSchemas != nil {
+		if err := s.downstreamSchemas.load(ctx, rows); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	dmls := s.prepareDMLs(rows, 0 /* replicaID */, 0 /* bucket */)
+	dmls.sqls = append(dmls.sqls, query)
+	dmls.values = append(dmls.values, args)
+	return errors.Trace(s.execDMLWithMaxRetries(ctx, dmls, defaultDMLMaxRetryTime, 0 /* bucket */))
+}
+
 func prepareReplace(
 	quoteTable string,
 	cols []*model.Column,
@@ -1033,7 +1425,11 @@ func prepareReplace(
 // reduceReplace groups SQLs with the same replace statement format, as following
 // sql: `REPLACE INTO `test`.`t` (`a`,`b`) VALUES (?,?,?,?,?,?)`
 // args: (1,"",2,"2",3,"")
-func reduceReplace(replaces map[string][][]interface{}, batchSize int) ([]string, [][]interface{}) {
+// The clause in clauses of a statement, such as ON DUPLICATE KEY UPDATE, is
+// appended to the grouped SQLs.
+func reduceReplace(
+	replaces map[string][][]interface{}, batchSize int, clauses map[string]string,
+) ([]string, [][]interface{}) {
 	nextHolderString := func(query string, valueNum int, last bool) string {
 		query += "(" + model.HolderString(valueNum) + ")"
 		if !last {
@@ -1056,7 +1452,7 @@ func reduceReplace(replaces map[string][][]interface{}, batchSize int) ([]string
 			query = nextHolderString(query, len(val), last)
 			cacheArgs = append(cacheArgs, val...)
 			if last {
-				sqls = append(sqls, query)
+				sqls = append(sqls, query+clauses[replace])
 				args = append(args, cacheArgs)
 				query = replace
 				cacheCount = 0
@@ -1068,6 +1464,26 @@ func reduceReplace(replaces map[string][][]interface{}, batchSize int) ([]string
 	return sqls, args
 }
 
+// upsertClause returns the ON DUPLICATE KEY UPDATE clause updating the columns
+// by the inserted values.
+func upsertClause(cols []*model.Column) string {
+	var builder strings.Builder
+	builder.WriteString(" ON DUPLICATE KEY UPDATE ")
+	first := true
+	for _, col := range cols {
+		if col == nil || col.Flag.IsGeneratedColumn() {
+			continue
+		}
+		if !first {
+			builder.WriteString(",")
+		}
+		first = false
+		name := quotes.QuoteName(col.Name)
+		builder.WriteString(name + "=VALUES(" + name + ")")
+	}
+	return builder.String()
+}
+
 func prepareUpdate(quoteTable string, preCols, cols []*model.Column, forceReplicate bool) (string, []interface{}) {
 	var builder strings.Builder
 	builder.WriteString("UPDATE " + quoteTable + " SET ")
@@ -1138,6 +1554,45 @@ func prepareDelete(quoteTable string, cols []*model.Column, forceReplicate bool)
 	return sql, args
 }
 
+// batchDelete is the keys of the rows deleted from a table by a single-column key.
+type batchDelete struct {
+	quoteTable string
+	column     string
+	keys       []interface{}
+}
+
+// reduce merges the deletes into the statements of at most batchSize keys,
+// a single delete is executed by the same statement as prepareDelete.
+func (d *batchDelete) reduce(batchSize int) ([]string, [][]interface{}) {
+	sqls := make([]string, 0, (len(d.keys)+batchSize-1)/batchSize)
+	args := make([][]interface{}, 0, cap(sqls))
+	for start := 0; start < len(d.keys); start += batchSize {
+		end := start + batchSize
+		if end > len(d.keys) {
+			end = len(d.keys)
+		}
+		keys := d.keys[start:end]
+		if len(keys) == 1 {
+			sqls = append(sqls, "DELETE FROM "+d.quoteTable+" WHERE "+quotes.QuoteName(d.column)+" = ? LIMIT 1;")
+		} else {
+			sqls = append(sqls, "DELETE FROM "+d.quoteTable+" WHERE "+quotes.QuoteName(d.column)+
+				" IN ("+model.HolderString(len(keys))+");")
+		}
+		args = append(args, keys)
+	}
+	return sqls, args
+}
+
+// singleKeyOfDelete returns the column and the value of the key the row is
+// deleted by, ok is false if the key has multiple columns or the value is NULL.
+func singleKeyOfDelete(cols []*model.Column, forceReplicate bool) (column string, key interface{}, ok bool) {
+	colNames, wargs := whereSlice(cols, forceReplicate)
+	if len(colNames) != 1 || wargs[0] == nil {
+		return "", nil, false
+	}
+	return colNames[0], wargs[0], true
+}
+
 func whereSlice(cols []*model.Column, forceReplicate bool) (colNames []string, args []interface{}) {
 	// Try to use unique key values when available
 	for _, col := range cols {
@@ -1180,6 +1635,23 @@ func isIgnorableDDLError(err error) bool {
 	}
 }
 
+// isRetryableDDLError returns whether the DDL may succeed if it's executed
+// again, the errors of the DDL itself or the privileges fail every time.
+func isRetryableDDLError(err error) bool {
+	errCode, ok := getSQLErrCode(err)
+	if !ok {
+		// the errors of the connection or the timeout
+		return true
+	}
+	switch errCode {
+	case mysql.ErrParse, mysql.ErrSyntax, mysql.ErrUnsupportedDDLOperation, mysql.ErrNotSupportedYet,
+		mysql.ErrAccessDenied, mysql.ErrDBaccessDenied, mysql.ErrTableaccessDenied, mysql.ErrSpecificAccessDenied:
+		return false
+	default:
+		return true
+	}
+}
+
 func getSQLErrCode(err error) (errors.ErrCode, bool) {
 	mysqlErr, ok := errors.Cause(err).(*dmysql.MySQLError)
 	if !ok {
@@ -1254,15 +1726,15 @@ func newMySQLSyncpointStore(ctx context.Context, id string, sinkURI *url.URL) (S
 	// [username[:password]@][protocol[(address)]]/dbname[?param1=value1&...&paramN=valueN]
 	username := sinkURI.User.Username()
 	password, _ := sinkURI.User.Password()
-	port := sinkURI.Port()
 	if username == "" {
 		username = "root"
 	}
-	if port == "" {
-		port = "4000"
+	network, addr, err := dbbalancer.Resolve(sinkURI, "syncpoint-"+id)
+	if err != nil {
+		return nil, errors.Trace(err)
 	}
 
-	dsnStr := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", username, password, sinkURI.Hostname(), port, tlsParam)
+	dsnStr := fmt.Sprintf("%s:%s@%s(%s)/%s", username, password, network, addr, tlsParam)
 	dsn, err := dmysql.ParseDSN(dsnStr)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -1290,6 +1762,7 @@ func newMySQLSyncpointStore(ctx context.Context, id string, sinkURI *url.URL) (S
 	if err != nil {
 		return nil, errors.Annotate(err, "fail to open MySQL connection")
 	}
+	syncDB.SetConnMaxLifetime(params.connMaxLifetime)
 
 	log.Info("Start mysql syncpoint sink")
 	syncpointStore := &mysqlSyncpointStore{
@@ -261,6 +261,113 @@ func (s MySQLSinkSuite) TestPrepareDML(c *check.C) {
 	}
 }
 
+func (s MySQLSinkSuite) TestPrepareBatchDelete(c *check.C) {
+	defer testleak.AfterTest(c)()
+	deleteRow := func(table string, id int) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			Table: &model.TableName{Schema: "test", Table: table},
+			PreColumns: []*model.Column{{
+				Name:  "id",
+				Type:  mysql.TypeLong,
+				Flag:  model.HandleKeyFlag | model.PrimaryKeyFlag,
+				Value: id,
+			}, {
+				Name:  "v",
+				Type:  mysql.TypeLong,
+				Value: id,
+			}},
+		}
+	}
+	insertRow := deleteRow("t1", 4)
+	insertRow.Columns, insertRow.PreColumns = insertRow.PreColumns, nil
+	rows := []*model.RowChangedEvent{
+		deleteRow("t1", 1), deleteRow("t1", 2), deleteRow("t2", 1), deleteRow("t1", 3),
+		// the cached deletes are executed before the insert
+		insertRow,
+		deleteRow("t1", 4),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ms := newMySQLSink4Test(ctx, c)
+	ms.params.batchReplaceEnabled = true
+	ms.params.batchDeleteSize = 2
+	dmls := ms.prepareDMLs(rows, 0, 0)
+	c.Assert(dmls, check.DeepEquals, &preparedDMLs{
+		sqls: []string{
+			"DELETE FROM `test`.`t1` WHERE `id` IN (?,?);",
+			"DELETE FROM `test`.`t1` WHERE `id` = ? LIMIT 1;",
+			"DELETE FROM `test`.`t2` WHERE `id` = ? LIMIT 1;",
+			"REPLACE INTO `test`.`t1`(`id`,`v`) VALUES (?,?)",
+			"DELETE FROM `test`.`t1` WHERE `id` = ? LIMIT 1;",
+		},
+		values:   [][]interface{}{{1, 2}, {3}, {1}, {4, 4}, {4}},
+		rowCount: 6,
+	})
+
+	ms.params.batchDeleteEnabled = false
+	dmls = ms.prepareDMLs(rows[:2], 0, 0)
+	c.Assert(dmls.sqls, check.DeepEquals, []string{
+		"DELETE FROM `test`.`t1` WHERE `id` = ? LIMIT 1;",
+		"DELETE FROM `test`.`t1` WHERE `id` = ? LIMIT 1;",
+	})
+}
+
+func (s MySQLSinkSuite) TestPrepareUpsert(c *check.C) {
+	defer testleak.AfterTest(c)()
+	insertRow := func(id int) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			Table: &model.TableName{Schema: "test", Table: "t1"},
+			Columns: []*model.Column{{
+				Name:  "id",
+				Type:  mysql.TypeLong,
+				Flag:  model.HandleKeyFlag | model.PrimaryKeyFlag,
+				Value: id,
+			}, {
+				Name:  "v",
+				Type:  mysql.TypeLong,
+				Value: id,
+			}, {
+				Name:  "g",
+				Type:  mysql.TypeLong,
+				Flag:  model.GeneratedColumnFlag,
+				Value: id,
+			}},
+		}
+	}
+	rows := []*model.RowChangedEvent{insertRow(1), insertRow(2), insertRow(3)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ms := newMySQLSink4Test(ctx, c)
+	ms.params.upsertStrategy = upsertOnDuplicateKeyUpdate
+	ms.params.batchReplaceEnabled = true
+	ms.params.batchReplaceSize = 2
+	dmls := ms.prepareDMLs(rows, 0, 0)
+	c.Assert(dmls, check.DeepEquals, &preparedDMLs{
+		sqls: []string{
+			"INSERT INTO `test`.`t1`(`id`,`v`) VALUES (?,?),(?,?) ON DUPLICATE KEY UPDATE `id`=VALUES(`id`),`v`=VALUES(`v`)",
+			"INSERT INTO `test`.`t1`(`id`,`v`) VALUES (?,?) ON DUPLICATE KEY UPDATE `id`=VALUES(`id`),`v`=VALUES(`v`)",
+		},
+		values:   [][]interface{}{{1, 1, 2, 2}, {3, 3}},
+		rowCount: 3,
+	})
+
+	ms.params.batchReplaceEnabled = false
+	dmls = ms.prepareDMLs(rows[:1], 0, 0)
+	c.Assert(dmls.sqls, check.DeepEquals, []string{
+		"INSERT INTO `test`.`t1`(`id`,`v`) VALUES (?,?) ON DUPLICATE KEY UPDATE `id`=VALUES(`id`),`v`=VALUES(`v`);",
+	})
+
+	uri, err := url.Parse("mysql://127.0.0.1:3306/?upsert-strategy=on-duplicate-key-update")
+	c.Assert(err, check.IsNil)
+	params, err := parseSinkURI(context.TODO(), uri, map[string]string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(params.upsertStrategy, check.Equals, upsertOnDuplicateKeyUpdate)
+	uri, err = url.Parse("mysql://127.0.0.1:3306/?upsert-strategy=upsert")
+	c.Assert(err, check.IsNil)
+	_, err = parseSinkURI(context.TODO(), uri, map[string]string{})
+	c.Assert(err, check.ErrorMatches, ".*invalid upsert-strategy.*")
+}
+
 func (s MySQLSinkSuite) TestPrepareUpdate(c *check.C) {
 	defer testleak.AfterTest(c)()
 	testCases := []struct {
@@ -587,7 +694,7 @@ func (s MySQLSinkSuite) TestReduceReplace(c *check.C) {
 		},
 	}
 	for _, tc := range testCases {
-		sqls, args := reduceReplace(tc.replaces, tc.batchSize)
+		sqls, args := reduceReplace(tc.replaces, tc.batchSize, nil)
 		if tc.sort {
 			sort.Strings(sqls)
 			sort.Sort(sqlArgs(args))
@@ -708,9 +815,13 @@ func (s MySQLSinkSuite) TestParseSinkURI(c *check.C) {
 	expected.changefeedID = "cf-id"
 	expected.captureAddr = "127.0.0.1:8300"
 	expected.tidbTxnMode = "pessimistic"
+	expected.adaptiveBatch = true
+	expected.minTxnRow = 4
+	expected.targetFlushLatency = 50 * time.Millisecond
 	uriStr := "mysql://127.0.0.1:3306/?worker-count=64&max-txn-row=20" +
 		"&batch-replace-enable=true&batch-replace-size=50&safe-mode=true" +
-		"&tidb-txn-mode=pessimistic"
+		"&tidb-txn-mode=pessimistic&adaptive-batch=true&min-txn-row=4" +
+		"&target-flush-latency=50ms"
 	opts := map[string]string{
 		OptChangefeedID: expected.changefeedID,
 		OptCaptureAddr:  expected.captureAddr,
@@ -722,6 +833,31 @@ func (s MySQLSinkSuite) TestParseSinkURI(c *check.C) {
 	c.Assert(params, check.DeepEquals, expected)
 }
 
+func (s MySQLSinkSuite) TestParseSinkURIConnPool(c *check.C) {
+	defer testleak.AfterTest(c)()
+	uri, err := url.Parse("mysql://127.0.0.1:3306/?worker-count=8&max-open-conns=10&max-idle-conns=2&conn-max-lifetime=1m")
+	c.Assert(err, check.IsNil)
+	params, err := parseSinkURI(context.TODO(), uri, map[string]string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(params.maxOpenConns, check.Equals, 10)
+	c.Assert(params.maxIdleConns, check.Equals, 2)
+	c.Assert(params.connMaxLifetime, check.Equals, time.Minute)
+
+	uri, err = url.Parse("mysql://127.0.0.1:3306/")
+	c.Assert(err, check.IsNil)
+	params, err = parseSinkURI(context.TODO(), uri, map[string]string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(params.maxOpenConns, check.Equals, 0)
+	c.Assert(params.connMaxLifetime, check.Equals, defaultConnMaxLifetime)
+
+	for _, query := range []string{"max-open-conns=-1", "max-idle-conns=abc", "conn-max-lifetime=1"} {
+		uri, err = url.Parse("mysql://127.0.0.1:3306/?" + query)
+		c.Assert(err, check.IsNil)
+		_, err = parseSinkURI(context.TODO(), uri, map[string]string{})
+		c.Assert(err, check.ErrorMatches, ".*invalid.*", check.Commentf("%s", query))
+	}
+}
+
 func (s MySQLSinkSuite) TestParseSinkURITimezone(c *check.C) {
 	defer testleak.AfterTest(c)()
 	uris := []string{
@@ -756,6 +892,9 @@ func (s MySQLSinkSuite) TestParseSinkURIBadQueryString(c *check.C) {
 		"mysql://127.0.0.1:3306/?batch-replace-enable=not-bool",
 		"mysql://127.0.0.1:3306/?batch-replace-enable=true&batch-replace-size=not-number",
 		"mysql://127.0.0.1:3306/?safe-mode=not-bool",
+		"mysql://127.0.0.1:3306/?adaptive-batch=not-bool",
+		"mysql://127.0.0.1:3306/?min-txn-row=not-number",
+		"mysql://127.0.0.1:3306/?target-flush-latency=not-duration",
 	}
 	ctx := context.TODO()
 	opts := map[string]string{OptChangefeedID: "changefeed-01"}
@@ -1035,24 +1174,24 @@ func (s MySQLSinkSuite) TestNewMySQLSinkExecDML(c *check.C) {
 	err = sink.EmitRowChangedEvents(ctx, rows...)
 	c.Assert(err, check.IsNil)
 
-	err = retry.Run(time.Millisecond*20, 10, func() error {
+	err = retry.Do(ctx, func() error {
 		ts, err := sink.FlushRowChangedEvents(ctx, uint64(2))
 		c.Assert(err, check.IsNil)
 		if ts < uint64(2) {
 			return errors.Errorf("checkpoint ts %d less than resolved ts %d", ts, 2)
 		}
 		return nil
-	})
+	}, retry.WithBackoffBaseDelay(time.Millisecond*20), retry.WithMaxTries(11))
 	c.Assert(err, check.IsNil)
 
-	err = retry.Run(time.Millisecond*20, 10, func() error {
+	err = retry.Do(ctx, func() error {
 		ts, err := sink.FlushRowChangedEvents(ctx, uint64(4))
 		c.Assert(err, check.IsNil)
 		if ts < uint64(4) {
 			return errors.Errorf("checkpoint ts %d less than resolved ts %d", ts, 4)
 		}
 		return nil
-	})
+	}, retry.WithBackoffBaseDelay(time.Millisecond*20), retry.WithMaxTries(11))
 	c.Assert(err, check.IsNil)
 
 	err = sink.Close()
@@ -1128,6 +1267,74 @@ func (s MySQLSinkSuite) TestExecDMLRollback(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s MySQLSinkSuite) TestExecRowsInTxn(c *check.C) {
+	defer testleak.AfterTest(c)()
+
+	rows := []*model.RowChangedEvent{
+		{
+			Table: &model.TableName{Schema: "s1", Table: "t1", TableID: 1},
+			Columns: []*model.Column{
+				{Name: "a", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 1},
+			},
+		},
+		{
+			Table: &model.TableName{Schema: "s1", Table: "t1", TableID: 1},
+			Columns: []*model.Column{
+				{Name: "a", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 2},
+			},
+		},
+	}
+
+	dbIndex := 0
+	mockGetDBConn := func(ctx context.Context, dsnStr string) (*sql.DB, error) {
+		defer func() {
+			dbIndex++
+		}()
+		if dbIndex == 0 {
+			// test db
+			db, err := mockTestDB()
+			c.Assert(err, check.IsNil)
+			return db, nil
+		}
+		// normal db
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		c.Assert(err, check.IsNil)
+		// the rows and the query are executed in one transaction
+		mock.ExpectBegin()
+		mock.ExpectExec("REPLACE INTO `s1`.`t1`(`a`) VALUES (?),(?)").
+			WithArgs(1, 2).
+			WillReturnResult(sqlmock.NewResult(2, 2))
+		mock.ExpectExec("REPLACE INTO `tidb_cdc`.`progress` VALUES (?)").
+			WithArgs(100).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectClose()
+		return db, nil
+	}
+	backupGetDBConn := getDBConnImpl
+	getDBConnImpl = mockGetDBConn
+	defer func() {
+		getDBConnImpl = backupGetDBConn
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changefeed := "test-changefeed"
+	sinkURI, err := url.Parse("mysql://127.0.0.1:4000/?time-zone=UTC&worker-count=1")
+	c.Assert(err, check.IsNil)
+	rc := config.GetDefaultReplicaConfig()
+	f, err := filter.NewFilter(rc)
+	c.Assert(err, check.IsNil)
+	sink, err := newMySQLSink(ctx, changefeed, sinkURI, f, rc, map[string]string{})
+	c.Assert(err, check.IsNil)
+
+	err = sink.(TxnExecutor).ExecRowsInTxn(ctx, rows, "REPLACE INTO `tidb_cdc`.`progress` VALUES (?)", 100)
+	c.Assert(err, check.IsNil)
+
+	err = sink.Close()
+	c.Assert(err, check.IsNil)
+}
+
 func (s MySQLSinkSuite) TestNewMySQLSinkExecDDL(c *check.C) {
 	defer testleak.AfterTest(c)()
 
@@ -1211,3 +1418,60 @@ func (s MySQLSinkSuite) TestNewMySQLSinkExecDDL(c *check.C) {
 	err = sink.Close()
 	c.Assert(err, check.IsNil)
 }
+
+func (s MySQLSinkSuite) TestExecDDLRetry(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	c.Assert(err, check.IsNil)
+	ms := newMySQLSink4Test(ctx, c)
+	ms.db = db
+	ms.params.ddlTimeout = 100 * time.Millisecond
+	ddl := &model.DDLEvent{
+		TableInfo: &model.SimpleTableInfo{Schema: "test", Table: "t1"},
+		Type:      timodel.ActionAddColumn,
+		Query:     "ALTER TABLE test.t1 ADD COLUMN a int",
+	}
+
+	// the DDL is still executed by TiDB after the timeout
+	mock.ExpectBegin()
+	mock.ExpectExec("USE `test`;").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(ddl.Query).WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("USE `test`;").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(ddl.Query).WillReturnError(&dmysql.MySQLError{Number: uint16(infoschema.ErrColumnExists.Code())})
+	mock.ExpectRollback()
+	err = ms.execDDLWithMaxRetries(ctx, ddl, 3)
+	c.Assert(err, check.IsNil)
+
+	// the genuine failures are not retried
+	ms.params.ddlSkipExistenceErrors = false
+	mock.ExpectBegin()
+	mock.ExpectExec("USE `test`;").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(ddl.Query).WillReturnError(&dmysql.MySQLError{Number: uint16(infoschema.ErrColumnExists.Code())})
+	mock.ExpectRollback()
+	err = ms.execDDLWithMaxRetries(ctx, ddl, 3)
+	c.Assert(err, check.ErrorMatches, ".*Error 1060.*")
+	mock.ExpectBegin()
+	mock.ExpectExec("USE `test`;").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(ddl.Query).WillReturnError(&dmysql.MySQLError{Number: mysql.ErrParse})
+	mock.ExpectRollback()
+	err = ms.execDDLWithMaxRetries(ctx, ddl, 3)
+	c.Assert(err, check.ErrorMatches, ".*Error 1064.*")
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+
+	c.Assert(isRetryableDDLError(&dmysql.MySQLError{Number: mysql.ErrLockWaitTimeout}), check.IsTrue)
+	c.Assert(isRetryableDDLError(dmysql.ErrInvalidConn), check.IsTrue)
+	c.Assert(isRetryableDDLError(&dmysql.MySQLError{Number: mysql.ErrAccessDenied}), check.IsFalse)
+
+	uri, err := url.Parse("mysql://127.0.0.1:3306/?ddl-timeout=10m&ddl-max-retries=5&ddl-skip-existence-errors=false")
+	c.Assert(err, check.IsNil)
+	params, err := parseSinkURI(context.TODO(), uri, map[string]string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(params.ddlTimeout, check.Equals, 10*time.Minute)
+	c.Assert(params.ddlMaxRetries, check.Equals, uint64(5))
+	c.Assert(params.ddlSkipExistenceErrors, check.IsFalse)
+	c.Assert(db.Close(), check.IsNil)
+}
@@ -0,0 +1,358 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink/cdclog"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/ticdc/cdc/sink/producer"
+	"github.com/pingcap/ticdc/pkg/config"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// truncatedMarker is appended to the values truncated by OversizedRowTruncate
+const truncatedMarker = "...(truncated)"
+
+// newClaimCheckStorage opens the storage the oversized rows are offloaded to,
+// it's replaced in the tests.
+var newClaimCheckStorage = cdclog.NewObjectStorage
+
+// oversizedRowHandler handles the rows whose messages exceed the max message
+// bytes of the MQ sinks by the oversized row policy of the changefeed.
+type oversizedRowHandler struct {
+	policy          string
+	maxMessageBytes int
+	newEncoder      func() codec.EventBatchEncoder
+	// dlqProducer sends the oversized rows to the DLQ topic
+	dlqProducer producer.Producer
+	// claimCheck is the storage the oversized rows are offloaded to, the
+	// objects are under the directory of the changefeed.
+	claimCheck   cdclog.ObjectStorage
+	changefeedID string
+
+	metricOversizedRows prometheus.Counter
+}
+
+func newOversizedRowHandler(
+	ctx context.Context, cfg *config.SinkConfig, opts map[string]string, newEncoder func() codec.EventBatchEncoder,
+) (*oversizedRowHandler, error) {
+	policy := cfg.OversizedRow
+	switch policy {
+	case "":
+		policy = config.OversizedRowFail
+	case config.OversizedRowFail, config.OversizedRowSplitColumns,
+		config.OversizedRowTruncate, config.OversizedRowDLQ, config.OversizedRowClaimCheck:
+	default:
+		return nil, cerror.ErrSinkInvalidConfig.GenWithStack("invalid oversized row policy %s", policy)
+	}
+	maxMessageBytes := codec.DefaultMaxMessageBytes
+	if s, ok := opts["max-message-bytes"]; ok {
+		c, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrSinkInvalidConfig, err)
+		}
+		maxMessageBytes = c
+	}
+	h := &oversizedRowHandler{
+		policy:              policy,
+		maxMessageBytes:     maxMessageBytes,
+		newEncoder:          newEncoder,
+		changefeedID:        opts[OptChangefeedID],
+		metricOversizedRows: oversizedRowCounter.WithLabelValues(opts[OptCaptureAddr], opts[OptChangefeedID], policy),
+	}
+	if policy == config.OversizedRowClaimCheck {
+		if cfg.ClaimCheckStorageURI == "" {
+			return nil, cerror.ErrSinkInvalidConfig.GenWithStack("the claim check storage uri must be set")
+		}
+		var err error
+		h.claimCheck, err = newClaimCheckStorage(ctx, cfg.ClaimCheckStorageURI)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return h, nil
+}
+
+// oversizedRowDLQTopic returns the DLQ topic of the oversized rows, and whether
+// the oversized rows are sent to the DLQ topic.
+func oversizedRowDLQTopic(cfg *config.SinkConfig) (string, bool) {
+	return cfg.OversizedRowDLQTopic, cfg.OversizedRow == config.OversizedRowDLQ
+}
+
+// handle returns the events sent instead of the row, which are the parts of
+// the row split by columns, the row with the long values truncated or the claim
+// check messages. Nothing is returned if the row is sent to the DLQ topic.
+func (h *oversizedRowHandler) handle(ctx context.Context, row *model.RowChangedEvent) ([]mqEvent, error) {
+	if h == nil {
+		return []mqEvent{{row: row}}, nil
+	}
+	// most rows are far smaller than the limit, only the large ones are
+	// encoded to get the sizes of their messages
+	if estimateRowSize(row) < h.maxMessageBytes/4 {
+		return []mqEvent{{row: row}}, nil
+	}
+	size, err := h.messageSize(row)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if size <= h.maxMessageBytes {
+		return []mqEvent{{row: row}}, nil
+	}
+	h.metricOversizedRows.Inc()
+	log.Warn("the message of the row exceeds max-message-bytes",
+		zap.Stringer("table", row.Table),
+		zap.Uint64("commit-ts", row.CommitTs),
+		zap.Int("size", size),
+		zap.Int("max-message-bytes", h.maxMessageBytes),
+		zap.String("policy", h.policy))
+
+	var rows []*model.RowChangedEvent
+	switch h.policy {
+	case config.OversizedRowSplitColumns:
+		rows = splitRowColumns(row, h.maxMessageBytes/4)
+	case config.OversizedRowTruncate:
+		rows = []*model.RowChangedEvent{truncateRowValues(row, h.maxMessageBytes/4)}
+	case config.OversizedRowDLQ:
+		return nil, h.sendToDLQ(ctx, row)
+	case config.OversizedRowClaimCheck:
+		return h.offload(ctx, row)
+	default:
+		return nil, cerror.ErrMQRowTooLarge.GenWithStackByArgs(row.Table, row.CommitTs, size, h.maxMessageBytes)
+	}
+	events := make([]mqEvent, 0, len(rows))
+	for _, r := range rows {
+		size, err := h.messageSize(r)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if size > h.maxMessageBytes {
+			return nil, cerror.ErrMQRowTooLarge.GenWithStackByArgs(row.Table, row.CommitTs, size, h.maxMessageBytes)
+		}
+		events = append(events, mqEvent{row: r})
+	}
+	return events, nil
+}
+
+// offload writes the messages of the row to the claim check storage, and
+// returns the claim check messages referencing them. The objects are written
+// before the claim check messages are sent, so the consumers can always read
+// them.
+func (h *oversizedRowHandler) offload(ctx context.Context, row *model.RowChangedEvent) ([]mqEvent, error) {
+	encoder := h.newEncoder()
+	if _, err := encoder.AppendRowChangedEvent(row); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var events []mqEvent
+	for _, msg := range encoder.Build() {
+		name := path.Join(h.changefeedID, row.Table.String(), fmt.Sprintf("%d-%s", row.CommitTs, uuid.New()))
+		if err := h.claimCheck.Write(ctx, name, codec.EncodeClaimCheckObject(msg)); err != nil {
+			return nil, errors.Trace(err)
+		}
+		events = append(events, mqEvent{claimCheck: codec.NewClaimCheckMessage(msg.Key, name, row.CommitTs)})
+	}
+	return events, nil
+}
+
+// messageSize returns the size of the largest message of the row.
+func (h *oversizedRowHandler) messageSize(row *model.RowChangedEvent) (int, error) {
+	encoder := h.newEncoder()
+	if _, err := encoder.AppendRowChangedEvent(row); err != nil {
+		return 0, errors.Trace(err)
+	}
+	size := 0
+	for _, msg := range encoder.Build() {
+		if msg.Length() > size {
+			size = msg.Length()
+		}
+	}
+	return size, nil
+}
+
+// sendToDLQ sends the messages of the row to the DLQ topic synchronously, the
+// oversized rows are rare so they are not batched.
+func (h *oversizedRowHandler) sendToDLQ(ctx context.Context, row *model.RowChangedEvent) error {
+	if h.dlqProducer == nil {
+		return cerror.ErrSinkInvalidConfig.GenWithStack("the oversized row DLQ is not supported by the sink")
+	}
+	encoder := h.newEncoder()
+	if _, err := encoder.AppendRowChangedEvent(row); err != nil {
+		return errors.Trace(err)
+	}
+	for _, msg := range encoder.Build() {
+		if err := h.dlqProducer.SyncBroadcastMessage(ctx, msg.Key, msg.Value); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// estimateRowSize returns the approximate size of the column names and values
+// of the row.
+func estimateRowSize(row *model.RowChangedEvent) int {
+	size := 0
+	for _, cols := range [][]*model.Column{row.Columns, row.PreColumns} {
+		for _, col := range cols {
+			size += columnSize(col)
+		}
+	}
+	return size
+}
+
+func columnSize(col *model.Column) int {
+	if col == nil {
+		return 0
+	}
+	switch v := col.Value.(type) {
+	case []byte:
+		return len(col.Name) + len(v)
+	case string:
+		return len(col.Name) + len(v)
+	default:
+		return len(col.Name) + 8
+	}
+}
+
+// splitRowColumns splits the columns of the row into the rows of about at most
+// limit bytes, each of which has the handle key columns and a part of the other
+// columns, so the consumers can merge them by the keys and the commit ts. A
+// column larger than the limit is in a row by itself.
+func splitRowColumns(row *model.RowChangedEvent, limit int) []*model.RowChangedEvent {
+	num := len(row.Columns)
+	if len(row.PreColumns) > num {
+		num = len(row.PreColumns)
+	}
+	at := func(cols []*model.Column, i int) *model.Column {
+		if i < len(cols) {
+			return cols[i]
+		}
+		return nil
+	}
+	var keys, others []int
+	keySize := 0
+	for i := 0; i < num; i++ {
+		col, preCol := at(row.Columns, i), at(row.PreColumns, i)
+		if col == nil && preCol == nil {
+			continue
+		}
+		if (col != nil && col.Flag.IsHandleKey()) || (preCol != nil && preCol.Flag.IsHandleKey()) {
+			keys = append(keys, i)
+			keySize += columnSize(col) + columnSize(preCol)
+		} else {
+			others = append(others, i)
+		}
+	}
+
+	var parts [][]int
+	var part []int
+	partSize := keySize
+	for _, i := range others {
+		size := columnSize(at(row.Columns, i)) + columnSize(at(row.PreColumns, i))
+		if len(part) > 0 && partSize+size > limit {
+			parts = append(parts, part)
+			part, partSize = nil, keySize
+		}
+		part = append(part, i)
+		partSize += size
+	}
+	if len(part) > 0 || len(parts) == 0 {
+		parts = append(parts, part)
+	}
+
+	rows := make([]*model.RowChangedEvent, 0, len(parts))
+	for _, part := range parts {
+		indexes := append(append(make([]int, 0, len(keys)+len(part)), keys...), part...)
+		r := *row
+		r.Columns = pickColumns(row.Columns, indexes)
+		r.PreColumns = pickColumns(row.PreColumns, indexes)
+		rows = append(rows, &r)
+	}
+	return rows
+}
+
+// pickColumns returns the non-nil columns at the indexes, it returns nil if
+// cols is nil, e.g. the columns of a deleted row.
+func pickColumns(cols []*model.Column, indexes []int) []*model.Column {
+	if cols == nil {
+		return nil
+	}
+	picked := make([]*model.Column, 0, len(indexes))
+	for _, i := range indexes {
+		if i < len(cols) && cols[i] != nil {
+			picked = append(picked, cols[i])
+		}
+	}
+	return picked
+}
+
+// truncateRowValues returns a copy of the row whose string and binary values
+// are truncated to share the limit bytes, a truncated value ends with the
+// truncatedMarker.
+func truncateRowValues(row *model.RowChangedEvent, limit int) *model.RowChangedEvent {
+	num := 0
+	for _, cols := range [][]*model.Column{row.Columns, row.PreColumns} {
+		for _, col := range cols {
+			if col != nil {
+				num++
+			}
+		}
+	}
+	if num == 0 {
+		return row
+	}
+	valueLimit := limit / num
+	truncate := func(cols []*model.Column) []*model.Column {
+		if cols == nil {
+			return nil
+		}
+		truncated := make([]*model.Column, len(cols))
+		for i, col := range cols {
+			truncated[i] = col
+			// the keys are never truncated to identify the row
+			if col == nil || col.Flag.IsHandleKey() {
+				continue
+			}
+			var value []byte
+			switch v := col.Value.(type) {
+			case []byte:
+				value = v
+			case string:
+				value = []byte(v)
+			default:
+				continue
+			}
+			if len(value) <= valueLimit {
+				continue
+			}
+			c := *col
+			c.Value = append(append(make([]byte, 0, valueLimit+len(truncatedMarker)), value[:valueLimit]...), truncatedMarker...)
+			truncated[i] = &c
+		}
+		return truncated
+	}
+	r := *row
+	r.Columns = truncate(row.Columns)
+	r.PreColumns = truncate(row.PreColumns)
+	return &r
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink/cdclog"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/ticdc/pkg/config"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type oversizedRowSuite struct{}
+
+var _ = check.Suite(&oversizedRowSuite{})
+
+func (s *oversizedRowSuite) TestHandleOversizedRow(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	newRow := func(valueLen int) *model.RowChangedEvent {
+		value := bytes.Repeat([]byte{'a'}, valueLen)
+		return &model.RowChangedEvent{
+			CommitTs: 100,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Value: int64(1), Flag: model.HandleKeyFlag | model.PrimaryKeyFlag},
+				{Name: "a", Type: mysql.TypeVarchar, Value: value},
+				nil,
+				{Name: "b", Type: mysql.TypeVarchar, Value: value},
+				{Name: "c", Type: mysql.TypeVarchar, Value: value},
+			},
+		}
+	}
+	newHandler := func(policy string) *oversizedRowHandler {
+		h, err := newOversizedRowHandler(ctx, &config.SinkConfig{OversizedRow: policy},
+			map[string]string{"max-message-bytes": "1024"}, codec.NewJSONEventBatchEncoder)
+		c.Assert(err, check.IsNil)
+		return h
+	}
+
+	// the small rows are sent as they are
+	small := newRow(10)
+	events, err := newHandler(config.OversizedRowFail).handle(ctx, small)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.DeepEquals, []mqEvent{{row: small}})
+
+	_, err = newHandler("").handle(ctx, newRow(500))
+	c.Assert(cerror.ErrMQRowTooLarge.Equal(err), check.IsTrue)
+
+	// each part has the handle key column and a column larger than the limit
+	events, err = newHandler(config.OversizedRowSplitColumns).handle(ctx, newRow(500))
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 3)
+	for i, name := range []string{"a", "b", "c"} {
+		c.Assert(events[i].row.PreColumns, check.IsNil)
+		c.Assert(events[i].row.Columns, check.HasLen, 2)
+		c.Assert(events[i].row.Columns[0].Name, check.Equals, "id")
+		c.Assert(events[i].row.Columns[1].Name, check.Equals, name)
+	}
+
+	row := newRow(500)
+	events, err = newHandler(config.OversizedRowTruncate).handle(ctx, row)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].row.Columns[0].Value, check.Equals, int64(1))
+	value := string(events[0].row.Columns[1].Value.([]byte))
+	c.Assert(strings.HasSuffix(value, truncatedMarker), check.IsTrue)
+	c.Assert(len(value), check.Equals, 256/4+len(truncatedMarker))
+	// the original row is not changed
+	c.Assert(row.Columns[1].Value, check.HasLen, 500)
+
+	h := newHandler(config.OversizedRowDLQ)
+	_, err = h.handle(ctx, newRow(500))
+	c.Assert(cerror.ErrSinkInvalidConfig.Equal(err), check.IsTrue)
+	dlqProducer := &broadcastCountProducer{}
+	h.dlqProducer = dlqProducer
+	events, err = h.handle(ctx, newRow(500))
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 0)
+	c.Assert(dlqProducer.broadcasts, check.Equals, 1)
+
+	// the claim check message references the offloaded message of the row
+	storage := &memObjectStorage{objects: make(map[string][]byte)}
+	defer func(fn func(context.Context, string) (cdclog.ObjectStorage, error)) {
+		newClaimCheckStorage = fn
+	}(newClaimCheckStorage)
+	newClaimCheckStorage = func(ctx context.Context, uri string) (cdclog.ObjectStorage, error) {
+		c.Assert(uri, check.Equals, "s3://bucket/prefix")
+		return storage, nil
+	}
+	h, err = newOversizedRowHandler(ctx, &config.SinkConfig{
+		OversizedRow:         config.OversizedRowClaimCheck,
+		ClaimCheckStorageURI: "s3://bucket/prefix",
+	}, map[string]string{"max-message-bytes": "1024", OptChangefeedID: "test-cf"}, codec.NewJSONEventBatchEncoder)
+	c.Assert(err, check.IsNil)
+	events, err = h.handle(ctx, newRow(500))
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].row, check.IsNil)
+	name, ok := codec.ParseClaimCheck(events[0].claimCheck.Value)
+	c.Assert(ok, check.IsTrue)
+	c.Assert(strings.HasPrefix(name, "test-cf/test.t/100-"), check.IsTrue)
+	key, value2, err := codec.DecodeClaimCheckObject(storage.objects[name])
+	c.Assert(err, check.IsNil)
+	c.Assert(events[0].claimCheck.Key, check.DeepEquals, key)
+	c.Assert(len(value2) > 1024, check.IsTrue)
+
+	for _, cfg := range []*config.SinkConfig{
+		{OversizedRow: "drop"},
+		{OversizedRow: config.OversizedRowClaimCheck},
+	} {
+		_, err = newOversizedRowHandler(ctx, cfg, map[string]string{}, codec.NewJSONEventBatchEncoder)
+		c.Assert(cerror.ErrSinkInvalidConfig.Equal(err), check.IsTrue)
+	}
+}
+
+type memObjectStorage struct {
+	objects map[string][]byte
+}
+
+func (s *memObjectStorage) Write(ctx context.Context, name string, data []byte) error {
+	s.objects[name] = data
+	return nil
+}
+
+func (s *memObjectStorage) Read(ctx context.Context, name string) ([]byte, error) {
+	return s.objects[name], nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pingcap/log"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// defaultConsumerLagInterval is the default interval of fetching the lags of
+// the consumer groups.
+const defaultConsumerLagInterval = 30 * time.Second
+
+// consumerLagMonitor fetches the offsets committed by the consumer groups of
+// the topic periodically, and exposes the lags behind the newest offsets of
+// the partitions in the metrics.
+type consumerLagMonitor struct {
+	client       sarama.Client
+	admin        sarama.ClusterAdmin
+	topic        string
+	groups       []string
+	changefeedID string
+}
+
+func newConsumerLagMonitor(
+	address string, topic string, groups []string, changefeedID string, cfg *sarama.Config,
+) (*consumerLagMonitor, error) {
+	client, err := sarama.NewClient(strings.Split(address, ","), cfg)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrKafkaNewSaramaProducer, err)
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, cerror.WrapError(cerror.ErrKafkaNewSaramaProducer, err)
+	}
+	return &consumerLagMonitor{
+		client:       client,
+		admin:        admin,
+		topic:        topic,
+		groups:       groups,
+		changefeedID: changefeedID,
+	}, nil
+}
+
+// run updates the lags every interval until the context is done or the
+// producer is closed, the failures are logged and retried in the next round
+// since they don't affect the sink.
+func (m *consumerLagMonitor) run(ctx context.Context, closeCh <-chan struct{}, interval time.Duration) {
+	defer m.close()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, group := range m.groups {
+			lag, err := m.fetchLag(group)
+			if err != nil {
+				log.Warn("fetch the lag of the kafka consumer group failed",
+					zap.String("changefeed", m.changefeedID), zap.String("topic", m.topic),
+					zap.String("group", group), zap.Error(err))
+				continue
+			}
+			consumerLagGauge.WithLabelValues(m.changefeedID, m.topic, group).Set(float64(lag))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-closeCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchLag returns the total lag of the consumer group on the partitions of
+// the topic, the partitions without committed offsets are lagging from their
+// oldest offsets.
+func (m *consumerLagMonitor) fetchLag(group string) (int64, error) {
+	if err := m.client.RefreshMetadata(m.topic); err != nil {
+		return 0, cerror.WrapError(cerror.ErrKafkaConsumerLag, err)
+	}
+	partitions, err := m.client.Partitions(m.topic)
+	if err != nil {
+		return 0, cerror.WrapError(cerror.ErrKafkaConsumerLag, err)
+	}
+	resp, err := m.admin.ListConsumerGroupOffsets(group, map[string][]int32{m.topic: partitions})
+	if err != nil {
+		return 0, cerror.WrapError(cerror.ErrKafkaConsumerLag, err)
+	}
+	var total int64
+	for _, partition := range partitions {
+		newest, err := m.client.GetOffset(m.topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return 0, cerror.WrapError(cerror.ErrKafkaConsumerLag, err)
+		}
+		committed := int64(-1)
+		if block := resp.GetBlock(m.topic, partition); block != nil {
+			if block.Err != sarama.ErrNoError {
+				return 0, cerror.WrapError(cerror.ErrKafkaConsumerLag, block.Err)
+			}
+			committed = block.Offset
+		}
+		if committed < 0 {
+			committed, err = m.client.GetOffset(m.topic, partition, sarama.OffsetOldest)
+			if err != nil {
+				return 0, cerror.WrapError(cerror.ErrKafkaConsumerLag, err)
+			}
+		}
+		total += partitionLag(newest, committed)
+	}
+	return total, nil
+}
+
+// partitionLag returns the number of the messages between the committed offset
+// and the newest offset, the committed offset may be ahead of the newest one
+// fetched earlier.
+func partitionLag(newest, committed int64) int64 {
+	if committed >= newest {
+		return 0
+	}
+	return newest - committed
+}
+
+func (m *consumerLagMonitor) close() {
+	for _, group := range m.groups {
+		consumerLagGauge.DeleteLabelValues(m.changefeedID, m.topic, group)
+	}
+	// the client is closed by the admin
+	if err := m.admin.Close(); err != nil {
+		log.Warn("close kafka admin client failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+func (s *kafkaSuite) TestConsumerLag(c *check.C) {
+	defer testleak.AfterTest(c)()
+	topic := "unit_test_lag"
+	group := "consumer"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := sarama.NewMockBroker(c, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(topic, 0, broker.BrokerID()).
+			SetLeader(topic, 1, broker.BrokerID()).
+			SetController(broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(c).
+			SetOffset(topic, 0, sarama.OffsetNewest, 100).
+			SetOffset(topic, 0, sarama.OffsetOldest, 0).
+			SetOffset(topic, 1, sarama.OffsetNewest, 50).
+			SetOffset(topic, 1, sarama.OffsetOldest, 10),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(c).
+			SetCoordinator(sarama.CoordinatorGroup, group, broker),
+		// the partition 1 has no committed offset
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(c).
+			SetOffset(group, topic, 0, 40, "", sarama.ErrNoError),
+	})
+
+	cfg, err := newSaramaConfigImpl(ctx, NewKafkaConfig())
+	c.Assert(err, check.IsNil)
+	monitor, err := newConsumerLagMonitor(broker.Addr(), topic, []string{group}, "test-cf", cfg)
+	c.Assert(err, check.IsNil)
+	defer monitor.close()
+	lag, err := monitor.fetchLag(group)
+	c.Assert(err, check.IsNil)
+	c.Assert(lag, check.Equals, int64(60+40))
+
+	c.Assert(partitionLag(100, 40), check.Equals, int64(60))
+	c.Assert(partitionLag(100, 120), check.Equals, int64(0))
+}
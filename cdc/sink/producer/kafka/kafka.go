@@ -47,6 +47,12 @@ type Config struct {
 
 	// control whether to create topic and verify partition number
 	TopicPreProcess bool
+
+	// ConsumerGroups are the consumer groups of the topic whose lags are
+	// exposed in the metrics by the owner, the lags are not monitored if
+	// it's empty.
+	ConsumerGroups      []string
+	ConsumerLagInterval time.Duration
 }
 
 // NewKafkaConfig returns a default Kafka configuration
@@ -58,6 +64,8 @@ func NewKafkaConfig() Config {
 		Compression:       "none",
 		Credential:        &security.Credential{},
 		TopicPreProcess:   true,
+
+		ConsumerLagInterval: defaultConsumerLagInterval,
 	}
 }
 
@@ -360,6 +368,16 @@ func NewKafkaSaramaProducer(ctx context.Context, address string, topic string, c
 		closeCh:         make(chan struct{}),
 		failpointCh:     make(chan error, 1),
 	}
+	if len(config.ConsumerGroups) > 0 && util.IsOwnerFromCtx(ctx) {
+		// the lags are monitored once per changefeed
+		monitor, err := newConsumerLagMonitor(address, topic, config.ConsumerGroups, util.ChangefeedIDFromCtx(ctx), cfg)
+		if err != nil {
+			// the replication doesn't depend on the monitor
+			log.Warn("create the kafka consumer lag monitor failed", zap.String("topic", topic), zap.Error(err))
+		} else {
+			go monitor.run(ctx, k.closeCh, config.ConsumerLagInterval)
+		}
+	}
 	go func() {
 		if err := k.run(ctx); err != nil && errors.Cause(err) != context.Canceled {
 			select {
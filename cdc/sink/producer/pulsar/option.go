@@ -59,6 +59,12 @@ func parseSinkOptions(u *url.URL) (opt *Option, err error) {
 	return
 }
 
+// NewClientOptions parses the client options from the sink uri, it is used by the
+// consumers reading the messages sent by the pulsar producer.
+func NewClientOptions(u *url.URL) (*pulsar.ClientOptions, error) {
+	return parseClientOption(u)
+}
+
 func parseClientOption(u *url.URL) (opt *pulsar.ClientOptions, err error) {
 	vs := values(u.Query())
 	opt = &pulsar.ClientOptions{
@@ -0,0 +1,89 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"strings"
+
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/format"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/cdc/model"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	// the values in the DDLs are parsed by the driver of TiDB
+	_ "github.com/pingcap/tidb/types/parser_driver"
+)
+
+// schemaRenamer appends the suffix to the schema names in a DDL, the table
+// names without schema are resolved by the current database.
+type schemaRenamer struct {
+	suffix string
+}
+
+func (r *schemaRenamer) Enter(in ast.Node) (ast.Node, bool) {
+	switch node := in.(type) {
+	case *ast.TableName:
+		if node.Schema.O != "" {
+			node.Schema = timodel.NewCIStr(node.Schema.O + r.suffix)
+		}
+	case *ast.CreateDatabaseStmt:
+		node.Name += r.suffix
+	case *ast.DropDatabaseStmt:
+		node.Name += r.suffix
+	case *ast.AlterDatabaseStmt:
+		if node.Name != "" {
+			node.Name += r.suffix
+		}
+	}
+	return in, false
+}
+
+func (r *schemaRenamer) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+// renameDDLSchemas returns the DDL writing to the schemas with the suffix
+// appended to their names, such as the shadow schemas of a shadow changefeed.
+func renameDDLSchemas(query, suffix string) (string, error) {
+	stmt, err := parser.New().ParseOneStmt(query, "", "")
+	if err != nil {
+		return "", cerror.WrapError(cerror.ErrRewriteDDLFailed, err)
+	}
+	stmt.Accept(&schemaRenamer{suffix: suffix})
+	var builder strings.Builder
+	if err := stmt.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, &builder)); err != nil {
+		return "", cerror.WrapError(cerror.ErrRewriteDDLFailed, err)
+	}
+	return builder.String(), nil
+}
+
+// shadowDDL returns a copy of the DDL event writing to the schemas with the
+// suffix appended to their names.
+func shadowDDL(ddl *model.DDLEvent, suffix string) (*model.DDLEvent, error) {
+	query, err := renameDDLSchemas(ddl.Query, suffix)
+	if err != nil {
+		return nil, err
+	}
+	shadow := *ddl
+	shadow.Query = query
+	if ddl.TableInfo != nil {
+		tableInfo := *ddl.TableInfo
+		if tableInfo.Schema != "" {
+			tableInfo.Schema += suffix
+		}
+		shadow.TableInfo = &tableInfo
+	}
+	return &shadow, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"github.com/pingcap/check"
+	timodel "github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type shadowSuite struct{}
+
+var _ = check.Suite(&shadowSuite{})
+
+func (s *shadowSuite) TestRenameDDLSchemas(c *check.C) {
+	defer testleak.AfterTest(c)()
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{"create database test", "CREATE DATABASE `test_shadow`"},
+		{"drop database if exists test", "DROP DATABASE IF EXISTS `test_shadow`"},
+		{"drop table test.t", "DROP TABLE `test_shadow`.`t`"},
+		{"rename table a.t to b.t", "RENAME TABLE `a_shadow`.`t` TO `b_shadow`.`t`"},
+		// the tables without schema are resolved by the current database
+		{"truncate table t", "TRUNCATE TABLE `t`"},
+	}
+	for _, tc := range testCases {
+		query, err := renameDDLSchemas(tc.query, "_shadow")
+		c.Assert(err, check.IsNil)
+		c.Assert(query, check.Equals, tc.expected)
+	}
+
+	_, err := renameDDLSchemas("create tabl t", "_shadow")
+	c.Assert(err, check.ErrorMatches, ".*rewrite DDL failed.*")
+
+	ddl := &model.DDLEvent{
+		Query:     "create table t (id int primary key)",
+		Type:      timodel.ActionCreateTable,
+		TableInfo: &model.SimpleTableInfo{Schema: "test", Table: "t"},
+	}
+	shadow, err := shadowDDL(ddl, "_shadow")
+	c.Assert(err, check.IsNil)
+	c.Assert(shadow.TableInfo.Schema, check.Equals, "test_shadow")
+	c.Assert(shadow.Query, check.Matches, "CREATE TABLE `t` .*")
+	// the original event is unchanged
+	c.Assert(ddl.TableInfo.Schema, check.Equals, "test")
+}
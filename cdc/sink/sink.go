@@ -17,18 +17,22 @@ import (
 	"context"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/cdc/sink/cdclog"
 	"github.com/pingcap/ticdc/pkg/config"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/secret"
 )
 
 // Sink options keys
 const (
 	OptChangefeedID = "_changefeed_id"
 	OptCaptureAddr  = "_capture_addr"
+	// OptTopic is the data topic of the Kafka sink
+	OptTopic = "_topic"
 )
 
 // Sink is an abstraction for anything that a changefeed may emit into.
@@ -55,6 +59,50 @@ type Sink interface {
 	Close() error
 }
 
+// WarningReporter is implemented by the sinks which report the risks found in
+// the downstream, they don't fail the changefeed but may cause failures later.
+type WarningReporter interface {
+	// Warnings returns the current warnings, the addresses are not set.
+	Warnings() []*model.RunningError
+}
+
+// FullReporter is implemented by the sinks which limit their pending rows, the
+// caller should stop emitting the rows once the sink is full, until the pending
+// rows are flushed.
+type FullReporter interface {
+	// Full returns true if the sink can't take more rows.
+	Full() bool
+}
+
+// BootstrapMessageSender is implemented by the sinks which can send the
+// bootstrap messages of the tables.
+type BootstrapMessageSender interface {
+	// EmitBootstrapMessage sends the bootstrap DDL event of a table, it's
+	// called before any rows of the table are emitted.
+	EmitBootstrapMessage(ctx context.Context, ddl *model.DDLEvent) error
+}
+
+// HeartbeatSender is implemented by the sinks which can send the heartbeat
+// messages of the tables, so the consumers can tell the idle tables from a
+// stuck changefeed.
+type HeartbeatSender interface {
+	// HeartbeatInterval returns the interval of the heartbeats, the heartbeats
+	// are disabled if it's 0.
+	HeartbeatInterval() time.Duration
+	// EmitHeartbeat sends a heartbeat of the table, all the rows of the table
+	// committed before or at ts must have been flushed.
+	EmitHeartbeat(ctx context.Context, table *model.TableName, ts uint64) error
+}
+
+// TxnExecutor is implemented by the sinks which can apply the rows together
+// with an extra statement in one downstream transaction, so the consumers can
+// save their progress atomically with the data.
+type TxnExecutor interface {
+	// ExecRowsInTxn applies the rows and executes the query in one
+	// transaction, the rows are not buffered by the sink.
+	ExecRowsInTxn(ctx context.Context, rows []*model.RowChangedEvent, query string, args ...interface{}) error
+}
+
 var sinkIniterMap = make(map[string]sinkInitFunc)
 
 type sinkInitFunc func(context.Context, model.ChangeFeedID, *url.URL, *filter.Filter, *config.ReplicaConfig, map[string]string, chan error) (Sink, error)
@@ -100,15 +148,42 @@ func init() {
 		filter *filter.Filter, config *config.ReplicaConfig, opts map[string]string, errCh chan error) (Sink, error) {
 		return cdclog.NewS3Sink(ctx, sinkURI, errCh)
 	}
+	// gcs and azure blob storage share the implementation of s3 sink
+	sinkIniterMap["gs"] = sinkIniterMap["s3"]
+	sinkIniterMap["gcs"] = sinkIniterMap["s3"]
+	sinkIniterMap["azblob"] = sinkIniterMap["s3"]
+	sinkIniterMap["azure"] = sinkIniterMap["s3"]
+}
+
+// ValidateSinkURI checks the sink uri can be parsed and its scheme is
+// supported, the secrets referenced by the uri are not resolved.
+func ValidateSinkURI(sinkURIStr string) error {
+	sinkURI, err := secret.ParseURI(sinkURIStr)
+	if err != nil {
+		return err
+	}
+	if _, ok := sinkIniterMap[strings.ToLower(sinkURI.Scheme)]; !ok {
+		return cerror.ErrSinkURIInvalid.GenWithStack("the sink scheme (%s) is not supported", sinkURI.Scheme)
+	}
+	return nil
 }
 
 // NewSink creates a new sink with the sink-uri
 func NewSink(ctx context.Context, changefeedID model.ChangeFeedID, sinkURIStr string, filter *filter.Filter, config *config.ReplicaConfig, opts map[string]string, errCh chan error) (Sink, error) {
+	// the secrets referenced by the sink uri are resolved when the sink is created,
+	// so they are not stored in etcd
+	sinkURIStr, err := secret.ResolveURI(ctx, sinkURIStr)
+	if err != nil {
+		return nil, err
+	}
 	// parse sinkURI as a URI
 	sinkURI, err := url.Parse(sinkURIStr)
 	if err != nil {
 		return nil, cerror.WrapError(cerror.ErrSinkURIInvalid, err)
 	}
+	if err := checkGeneratedColumnsMode(config.Sink.GeneratedColumns); err != nil {
+		return nil, err
+	}
 	if newSink, ok := sinkIniterMap[strings.ToLower(sinkURI.Scheme)]; ok {
 		return newSink(ctx, changefeedID, sinkURI, filter, config, opts, errCh)
 	}
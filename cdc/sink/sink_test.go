@@ -0,0 +1,42 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"github.com/pingcap/check"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type sinkSuite struct{}
+
+var _ = check.Suite(&sinkSuite{})
+
+func (s *sinkSuite) TestValidateSinkURI(c *check.C) {
+	defer testleak.AfterTest(c)()
+	for _, uri := range []string{
+		"mysql://root@127.0.0.1:3306/",
+		"KAFKA://127.0.0.1:9092/topic?protocol=canal-json",
+		"mysql://root:${secret:env:PASSWORD}@127.0.0.1:3306/",
+	} {
+		c.Assert(ValidateSinkURI(uri), check.IsNil, check.Commentf("%s", uri))
+	}
+	for _, uri := range []string{
+		"",
+		"redis://127.0.0.1:6379",
+		"mysql://root@127.0.0.1:3306/%zz",
+	} {
+		c.Assert(cerror.ErrSinkURIInvalid.Equal(ValidateSinkURI(uri)), check.IsTrue, check.Commentf("%s", uri))
+	}
+}
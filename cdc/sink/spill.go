@@ -0,0 +1,338 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/diskmanager"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSpillMemoryRowsLimit = 100000
+	defaultSpillMaxDiskSize     = 1024 * 1024 * 1024 // 1G
+)
+
+// spillOptions is shared by the table sinks of a Manager.
+type spillOptions struct {
+	dir             string
+	memoryRowsLimit int
+	maxDiskSize     int64
+	// disk accounts the spill files if the data dir of the capture is used
+	disk *diskmanager.Manager
+
+	metricBytes prometheus.Counter
+	metricRows  prometheus.Counter
+	metricDrain prometheus.Observer
+}
+
+func newSpillOptions(dir string, cfg *config.SpillConfig, captureAddr, changefeedID string) (*spillOptions, error) {
+	if dir == "" {
+		return nil, cerror.ErrSinkSpill.GenWithStack("the spill dir is empty")
+	}
+	// the files left by the previous processor are useless, the rows are
+	// pulled again from the checkpoint
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, cerror.WrapError(cerror.ErrSinkSpill, err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, cerror.WrapError(cerror.ErrSinkSpill, err)
+	}
+	opts := &spillOptions{
+		dir:             dir,
+		memoryRowsLimit: cfg.MemoryRowsLimit,
+		maxDiskSize:     cfg.MaxDiskSize,
+		disk:            diskmanager.GetGlobal(),
+		metricBytes:     spillBytesCounter.WithLabelValues(captureAddr, changefeedID),
+		metricRows:      spillRowsCounter.WithLabelValues(captureAddr, changefeedID),
+		metricDrain:     spillDrainDuration.WithLabelValues(captureAddr, changefeedID),
+	}
+	if opts.memoryRowsLimit <= 0 {
+		opts.memoryRowsLimit = defaultSpillMemoryRowsLimit
+	}
+	if opts.maxDiskSize <= 0 {
+		opts.maxDiskSize = defaultSpillMaxDiskSize
+	}
+	return opts, nil
+}
+
+// spillQueue is a FIFO queue of the pending rows of a table sink, which are
+// stored in a file until the file reaches the max disk size. The rows pushed
+// after that are kept in memory to preserve the order, the queue is full then
+// and the caller should stop pushing until it's drained. The rows in memory are
+// limited by the memory rows limit, the push fails beyond it unless the rows
+// belong to the transaction reaching the limit, since a transaction can't be
+// split and is only drained after it's resolved as a whole.
+//
+// The file consists of records, each record is a batch of rows encoded by gob
+// and prefixed with its length in 4 bytes.
+type spillQueue struct {
+	opts *spillOptions
+	path string
+	file *os.File
+
+	readOffset  int64
+	writeOffset int64
+	// overflow holds the rows pushed after the file is full, it's limited by
+	// the memory rows limit except for a single oversized transaction
+	overflow []*model.RowChangedEvent
+	// spillStart is the time the queue becomes non-empty
+	spillStart time.Time
+}
+
+func newSpillQueue(opts *spillOptions, tableID model.TableID) *spillQueue {
+	return &spillQueue{
+		opts: opts,
+		path: filepath.Join(opts.dir, fmt.Sprintf("table-%d.spill", tableID)),
+	}
+}
+
+func (q *spillQueue) empty() bool {
+	return q.readOffset == q.writeOffset && len(q.overflow) == 0
+}
+
+// full returns true if the rows can't be spilled to the file, the rows pushed
+// are kept in memory until the queue is drained.
+func (q *spillQueue) full() bool {
+	return len(q.overflow) != 0
+}
+
+// push appends the rows to the end of the queue.
+func (q *spillQueue) push(rows []*model.RowChangedEvent) error {
+	if q.empty() {
+		q.spillStart = time.Now()
+	}
+	if len(q.overflow) == 0 && q.writeOffset < q.opts.maxDiskSize {
+		data, err := encodeSpillRecord(rows)
+		if err != nil {
+			return err
+		}
+		if q.writeOffset+int64(len(data)) <= q.opts.maxDiskSize {
+			if q.opts.disk == nil || q.opts.disk.Allocate(diskmanager.ComponentSinkSpill, int64(len(data))) {
+				return q.write(data, len(rows))
+			}
+			log.Warn("the disk quota of the sink spill is used up, keep the rows in memory",
+				zap.String("file", q.path))
+		} else {
+			log.Warn("the spill file of the table sink is full, keep the rows in memory",
+				zap.String("file", q.path), zap.Int64("max-disk-size", q.opts.maxDiskSize))
+		}
+	}
+	if q.exceedLimit(rows) {
+		return cerror.ErrSinkSpillFull.GenWithStackByArgs(q.opts.maxDiskSize, q.opts.memoryRowsLimit)
+	}
+	q.overflow = append(q.overflow, rows...)
+	return nil
+}
+
+// exceedLimit returns true if the rows can't be kept in memory. The rows beyond
+// the memory rows limit are only kept if they belong to the transaction of the
+// last row within the limit.
+func (q *spillQueue) exceedLimit(rows []*model.RowChangedEvent) bool {
+	limit := q.opts.memoryRowsLimit
+	if len(q.overflow)+len(rows) <= limit {
+		return false
+	}
+	rowAt := func(i int) *model.RowChangedEvent {
+		if i < len(q.overflow) {
+			return q.overflow[i]
+		}
+		return rows[i-len(q.overflow)]
+	}
+	txn := rowAt(limit - 1)
+	// the rows kept beyond the limit before have been checked
+	start := limit
+	if start < len(q.overflow) {
+		start = len(q.overflow)
+	}
+	for i := start; i < len(q.overflow)+len(rows); i++ {
+		row := rowAt(i)
+		if row.StartTs != txn.StartTs || row.CommitTs != txn.CommitTs {
+			return true
+		}
+	}
+	return false
+}
+
+// write appends the data to the file, the data must have been allocated from
+// the disk manager.
+func (q *spillQueue) write(data []byte, rows int) error {
+	if q.file == nil {
+		file, err := os.OpenFile(q.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			q.free(int64(len(data)))
+			return cerror.WrapError(cerror.ErrSinkSpill, err)
+		}
+		q.file = file
+	}
+	if _, err := q.file.WriteAt(data, q.writeOffset); err != nil {
+		q.free(int64(len(data)))
+		return cerror.WrapError(cerror.ErrSinkSpill, err)
+	}
+	q.writeOffset += int64(len(data))
+	q.opts.metricBytes.Add(float64(len(data)))
+	q.opts.metricRows.Add(float64(rows))
+	return nil
+}
+
+// pop removes a batch of rows from the front of the queue.
+func (q *spillQueue) pop() ([]*model.RowChangedEvent, error) {
+	var rows []*model.RowChangedEvent
+	if q.readOffset < q.writeOffset {
+		var header [4]byte
+		if _, err := q.file.ReadAt(header[:], q.readOffset); err != nil {
+			return nil, cerror.WrapError(cerror.ErrSinkSpill, err)
+		}
+		data := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err := q.file.ReadAt(data, q.readOffset+int64(len(header))); err != nil {
+			return nil, cerror.WrapError(cerror.ErrSinkSpill, err)
+		}
+		var err error
+		rows, err = decodeSpillRecord(data)
+		if err != nil {
+			return nil, err
+		}
+		q.readOffset += int64(len(header) + len(data))
+		if q.readOffset == q.writeOffset {
+			// reuse the file from the beginning once it's drained
+			if err := q.file.Truncate(0); err != nil {
+				return nil, cerror.WrapError(cerror.ErrSinkSpill, err)
+			}
+			q.free(q.writeOffset)
+			q.readOffset, q.writeOffset = 0, 0
+		}
+	} else {
+		rows, q.overflow = q.overflow, nil
+	}
+	if q.empty() {
+		q.opts.metricDrain.Observe(time.Since(q.spillStart).Seconds())
+	}
+	return rows, nil
+}
+
+// close removes the spill file.
+func (q *spillQueue) close() error {
+	if q.file == nil {
+		return nil
+	}
+	err := q.file.Close()
+	q.file = nil
+	q.free(q.writeOffset)
+	q.readOffset, q.writeOffset = 0, 0
+	if err != nil {
+		return cerror.WrapError(cerror.ErrSinkSpill, err)
+	}
+	if err := os.Remove(q.path); err != nil && !os.IsNotExist(err) {
+		return cerror.WrapError(cerror.ErrSinkSpill, err)
+	}
+	return nil
+}
+
+func (q *spillQueue) free(size int64) {
+	if q.opts.disk != nil && size > 0 {
+		q.opts.disk.Free(diskmanager.ComponentSinkSpill, size)
+	}
+}
+
+// spillRow is the gob representation of a row, the columns are wrapped since
+// gob can't encode the nil elements of a slice.
+type spillRow struct {
+	Row        *model.RowChangedEvent
+	Columns    []spillColumn
+	PreColumns []spillColumn
+}
+
+type spillColumn struct {
+	Column *model.Column
+	// EmptyBytes is set if the value is an empty []byte, which is decoded
+	// as nil by gob but means an empty string rather than NULL
+	EmptyBytes bool
+}
+
+func wrapSpillColumns(cols []*model.Column) []spillColumn {
+	if cols == nil {
+		return nil
+	}
+	wrapped := make([]spillColumn, len(cols))
+	for i, col := range cols {
+		wrapped[i].Column = col
+		if col != nil {
+			if v, ok := col.Value.([]byte); ok && v != nil && len(v) == 0 {
+				wrapped[i].EmptyBytes = true
+			}
+		}
+	}
+	return wrapped
+}
+
+func unwrapSpillColumns(wrapped []spillColumn) []*model.Column {
+	if wrapped == nil {
+		return nil
+	}
+	cols := make([]*model.Column, len(wrapped))
+	for i := range wrapped {
+		cols[i] = wrapped[i].Column
+		if wrapped[i].EmptyBytes {
+			cols[i].Value = []byte{}
+		}
+	}
+	return cols
+}
+
+func encodeSpillRecord(rows []*model.RowChangedEvent) ([]byte, error) {
+	record := make([]spillRow, len(rows))
+	for i, row := range rows {
+		r := *row
+		r.Columns, r.PreColumns = nil, nil
+		record[i] = spillRow{
+			Row:        &r,
+			Columns:    wrapSpillColumns(row.Columns),
+			PreColumns: wrapSpillColumns(row.PreColumns),
+		}
+	}
+	buf := bytes.NewBuffer(make([]byte, 4))
+	if err := gob.NewEncoder(buf).Encode(record); err != nil {
+		return nil, cerror.WrapError(cerror.ErrSinkSpill, err)
+	}
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data, uint32(len(data)-4))
+	return data, nil
+}
+
+func decodeSpillRecord(data []byte) ([]*model.RowChangedEvent, error) {
+	var record []spillRow
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&record); err != nil {
+		return nil, cerror.WrapError(cerror.ErrSinkSpill, err)
+	}
+	rows := make([]*model.RowChangedEvent, len(record))
+	for i := range record {
+		row := record[i].Row
+		row.Columns = unwrapSpillColumns(record[i].Columns)
+		row.PreColumns = unwrapSpillColumns(record[i].PreColumns)
+		rows[i] = row
+	}
+	return rows, nil
+}
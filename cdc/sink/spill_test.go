@@ -0,0 +1,164 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type spillSuite struct{}
+
+var _ = check.Suite(&spillSuite{})
+
+// recordSink records the rows emitted to it.
+type recordSink struct {
+	blackHoleSink
+	rows []*model.RowChangedEvent
+}
+
+func (s *recordSink) EmitRowChangedEvents(ctx context.Context, rows ...*model.RowChangedEvent) error {
+	s.rows = append(s.rows, rows...)
+	return nil
+}
+
+func (s *recordSink) FlushRowChangedEvents(ctx context.Context, resolvedTs uint64) (uint64, error) {
+	return resolvedTs, nil
+}
+
+func newSpillTestRow(commitTs uint64) *model.RowChangedEvent {
+	return &model.RowChangedEvent{
+		CommitTs: commitTs,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{
+			{Name: "id", Type: 3, Flag: model.HandleKeyFlag, Value: int64(commitTs)},
+			nil,
+			{Name: "name", Type: 15, Value: []byte{}},
+			{Name: "null", Type: 15, Value: nil},
+		},
+		IndexColumns: [][]int{{0}},
+	}
+}
+
+func (s *spillSuite) TestSpillQueue(c *check.C) {
+	defer testleak.AfterTest(c)()
+	opts, err := newSpillOptions(filepath.Join(c.MkDir(), "spill"), &config.SpillConfig{MaxDiskSize: 4096}, "capture", "changefeed")
+	c.Assert(err, check.IsNil)
+	c.Assert(opts.memoryRowsLimit, check.Equals, defaultSpillMemoryRowsLimit)
+	q := newSpillQueue(opts, 1)
+	c.Assert(q.empty(), check.IsTrue)
+
+	var expected []*model.RowChangedEvent
+	for i := uint64(1); i <= 100; i += 2 {
+		rows := []*model.RowChangedEvent{newSpillTestRow(i), newSpillTestRow(i + 1)}
+		c.Assert(q.push(rows), check.IsNil)
+		expected = append(expected, rows...)
+	}
+	// the rows are kept in memory once the file is full
+	c.Assert(q.writeOffset, check.LessEqual, int64(4096))
+	c.Assert(len(q.overflow), check.Greater, 0)
+	c.Assert(q.full(), check.IsTrue)
+	_, err = os.Stat(q.path)
+	c.Assert(err, check.IsNil)
+
+	var popped []*model.RowChangedEvent
+	for !q.empty() {
+		rows, err := q.pop()
+		c.Assert(err, check.IsNil)
+		popped = append(popped, rows...)
+	}
+	c.Assert(popped, check.DeepEquals, expected)
+	c.Assert(q.writeOffset, check.Equals, int64(0))
+
+	c.Assert(q.close(), check.IsNil)
+	_, err = os.Stat(q.path)
+	c.Assert(os.IsNotExist(err), check.IsTrue)
+}
+
+func (s *spillSuite) TestSpillQueueFull(c *check.C) {
+	defer testleak.AfterTest(c)()
+	opts, err := newSpillOptions(filepath.Join(c.MkDir(), "spill"),
+		&config.SpillConfig{MaxDiskSize: 1, MemoryRowsLimit: 3}, "capture", "changefeed")
+	c.Assert(err, check.IsNil)
+	q := newSpillQueue(opts, 1)
+	defer q.close() //nolint:errcheck
+
+	// the rows kept in memory are limited once the file is full
+	c.Assert(q.push([]*model.RowChangedEvent{newSpillTestRow(1), newSpillTestRow(2)}), check.IsNil)
+	c.Assert(q.full(), check.IsTrue)
+	err = q.push([]*model.RowChangedEvent{newSpillTestRow(3), newSpillTestRow(4)})
+	c.Assert(err, check.ErrorMatches, ".*exceed the spill limits.*")
+	c.Assert(q.push([]*model.RowChangedEvent{newSpillTestRow(3)}), check.IsNil)
+
+	rows, err := q.pop()
+	c.Assert(err, check.IsNil)
+	c.Assert(rows, check.HasLen, 3)
+	c.Assert(q.full(), check.IsFalse)
+	c.Assert(q.empty(), check.IsTrue)
+
+	// a transaction reaching the limit is kept in memory as a whole
+	c.Assert(q.push([]*model.RowChangedEvent{newSpillTestRow(5)}), check.IsNil)
+	txn := []*model.RowChangedEvent{newSpillTestRow(6), newSpillTestRow(6), newSpillTestRow(6), newSpillTestRow(6)}
+	c.Assert(q.push(txn), check.IsNil)
+	c.Assert(q.push([]*model.RowChangedEvent{newSpillTestRow(6)}), check.IsNil)
+	err = q.push([]*model.RowChangedEvent{newSpillTestRow(6), newSpillTestRow(7)})
+	c.Assert(err, check.ErrorMatches, ".*exceed the spill limits.*")
+	rows, err = q.pop()
+	c.Assert(err, check.IsNil)
+	c.Assert(rows, check.HasLen, 6)
+}
+
+func (s *spillSuite) TestTableSinkSpill(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := context.Background()
+	backendSink := &recordSink{}
+	manager := &Manager{
+		backendSink: backendSink,
+		tableSinks:  make(map[model.TableID]*tableSink),
+	}
+	err := manager.EnableSpill(ctx, filepath.Join(c.MkDir(), "spill"), &config.SpillConfig{Enable: true, MemoryRowsLimit: 4})
+	c.Assert(err, check.IsNil)
+	sink := manager.CreateTableSink(1, 0).(*tableSink)
+
+	var expected []*model.RowChangedEvent
+	for i := uint64(1); i <= 20; i++ {
+		row := newSpillTestRow(i)
+		c.Assert(sink.EmitRowChangedEvents(ctx, row), check.IsNil)
+		expected = append(expected, row)
+	}
+	c.Assert(sink.buffer, check.HasLen, 4)
+	c.Assert(sink.spill.empty(), check.IsFalse)
+
+	_, err = sink.FlushRowChangedEvents(ctx, 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(backendSink.rows, check.DeepEquals, expected[:10])
+	// the new rows are spilled until the spilled rows are drained
+	row := newSpillTestRow(21)
+	c.Assert(sink.EmitRowChangedEvents(ctx, row), check.IsNil)
+	expected = append(expected, row)
+	c.Assert(sink.spill.empty(), check.IsFalse)
+
+	_, err = sink.FlushRowChangedEvents(ctx, 21)
+	c.Assert(err, check.IsNil)
+	c.Assert(backendSink.rows, check.DeepEquals, expected)
+	c.Assert(sink.spill.empty(), check.IsTrue)
+	c.Assert(sink.buffer, check.HasLen, 0)
+	c.Assert(sink.Close(), check.IsNil)
+}
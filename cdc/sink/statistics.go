@@ -15,11 +15,14 @@ package sink
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/pkg/util"
+	"github.com/pingcap/tidb/store/tikv/oracle"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
@@ -31,7 +34,11 @@ const (
 
 // NewStatistics creates a statistics
 func NewStatistics(ctx context.Context, name string, opts map[string]string) *Statistics {
-	statistics := &Statistics{name: name, lastPrintStatusTime: time.Now()}
+	statistics := &Statistics{
+		name:                name,
+		lastPrintStatusTime: time.Now(),
+		pendingCommitTs:     make(map[uint64]struct{}),
+	}
 	if cid, ok := opts[OptChangefeedID]; ok {
 		statistics.changefeedID = cid
 	}
@@ -41,6 +48,7 @@ func NewStatistics(ctx context.Context, name string, opts map[string]string) *St
 	statistics.metricExecTxnHis = execTxnHistogram.WithLabelValues(statistics.captureAddr, statistics.changefeedID)
 	statistics.metricExecBatchHis = execBatchHistogram.WithLabelValues(statistics.captureAddr, statistics.changefeedID)
 	statistics.metricExecErrCnt = executionErrorCounter.WithLabelValues(statistics.captureAddr, statistics.changefeedID)
+	statistics.metricE2ELatencyHis = e2eLatencyHistogram.WithLabelValues(statistics.captureAddr, statistics.changefeedID)
 
 	// Flush metrics in background for better accuracy and efficiency.
 	ticker := time.NewTicker(flushMetricsInterval)
@@ -73,9 +81,14 @@ type Statistics struct {
 	lastPrintStatusTotalRows uint64
 	lastPrintStatusTime      time.Time
 
-	metricExecTxnHis   prometheus.Observer
-	metricExecBatchHis prometheus.Observer
-	metricExecErrCnt   prometheus.Counter
+	// pendingCommitTs is the commit ts of the emitted txns not flushed yet
+	pendingMu       sync.Mutex
+	pendingCommitTs map[uint64]struct{}
+
+	metricExecTxnHis    prometheus.Observer
+	metricExecBatchHis  prometheus.Observer
+	metricExecErrCnt    prometheus.Counter
+	metricE2ELatencyHis prometheus.Observer
 }
 
 // AddRowsCount records total number of rows needs to flush
@@ -93,6 +106,33 @@ func (b *Statistics) TotalRowsCount() uint64 {
 	return atomic.LoadUint64(&b.totalRows)
 }
 
+// AddCommitTs records the commit ts of the rows emitted to the Sink, the
+// latency from their upstream commit to the flush acknowledging them is
+// observed by ObserveFlushed.
+func (b *Statistics) AddCommitTs(rows []*model.RowChangedEvent) {
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+	for _, row := range rows {
+		b.pendingCommitTs[row.CommitTs] = struct{}{}
+	}
+}
+
+// ObserveFlushed observes the end-to-end latency of the txns acknowledged by
+// the flush of the Sink, which are the ones committed before the checkpoint ts.
+// The latency is observed once for each txn rather than each row.
+func (b *Statistics) ObserveFlushed(checkpointTs uint64) {
+	now := time.Now()
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+	for commitTs := range b.pendingCommitTs {
+		if commitTs > checkpointTs {
+			continue
+		}
+		b.metricE2ELatencyHis.Observe(now.Sub(oracle.GetTimeFromTS(commitTs)).Seconds())
+		delete(b.pendingCommitTs, commitTs)
+	}
+}
+
 // RecordBatchExecution records the cost time of batch execution and batch size
 func (b *Statistics) RecordBatchExecution(executer func() (int, error)) error {
 	startTime := time.Now()
@@ -0,0 +1,43 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type statisticsSuite struct{}
+
+var _ = check.Suite(&statisticsSuite{})
+
+func (s *statisticsSuite) TestObserveFlushed(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	statistics := NewStatistics(ctx, "test", map[string]string{OptChangefeedID: "test-cf"})
+
+	statistics.AddCommitTs([]*model.RowChangedEvent{{CommitTs: 100}, {CommitTs: 100}, {CommitTs: 200}})
+	statistics.AddCommitTs([]*model.RowChangedEvent{{CommitTs: 300}})
+	c.Assert(statistics.pendingCommitTs, check.HasLen, 3)
+
+	// only the txns before the checkpoint are acknowledged
+	statistics.ObserveFlushed(200)
+	c.Assert(statistics.pendingCommitTs, check.DeepEquals, map[uint64]struct{}{300: {}})
+	statistics.ObserveFlushed(300)
+	c.Assert(statistics.pendingCommitTs, check.HasLen, 0)
+}
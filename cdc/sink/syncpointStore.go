@@ -20,6 +20,7 @@ import (
 
 	"github.com/pingcap/ticdc/cdc/model"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/secret"
 )
 
 // SyncpointStore is an abstraction for anything that a changefeed may emit into.
@@ -36,6 +37,10 @@ type SyncpointStore interface {
 
 // NewSyncpointStore creates a new Spyncpoint sink with the sink-uri
 func NewSyncpointStore(ctx context.Context, changefeedID model.ChangeFeedID, sinkURIStr string) (SyncpointStore, error) {
+	sinkURIStr, err := secret.ResolveURI(ctx, sinkURIStr)
+	if err != nil {
+		return nil, err
+	}
 	// parse sinkURI as a URI
 	sinkURI, err := url.Parse(sinkURIStr)
 	if err != nil {
@@ -0,0 +1,133 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/dbbalancer"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/secret"
+	"go.etcd.io/etcd/mvcc/mvccpb"
+)
+
+// sinkDestination returns the downstream the sink uri writes to, the uris of
+// the same downstream have the same destination. It's empty if the sink does
+// not write to a shared downstream, such as the blackhole sink.
+func sinkDestination(sinkURI string) (string, error) {
+	u, err := secret.ParseURI(sinkURI)
+	if err != nil {
+		return "", err
+	}
+	scheme := strings.ToLower(u.Scheme)
+	switch scheme {
+	case "blackhole":
+		return "", nil
+	case "mysql", "mysql+ssl", "tidb", "tidb+ssl":
+		addrs, err := dbbalancer.ParseEndpoints(u)
+		if err != nil {
+			return "", err
+		}
+		// the rows are written to the tables of the same names as upstream,
+		// or the shadow tables in the schemas with the suffix
+		sort.Strings(addrs)
+		dest := "mysql://" + strings.ToLower(strings.Join(addrs, ","))
+		if suffix := u.Query().Get("schema-suffix"); suffix != "" {
+			dest += "?schema-suffix=" + suffix
+		}
+		return dest, nil
+	case "kafka", "kafka+ssl", "pulsar", "pulsar+ssl":
+		hosts := strings.Split(strings.ToLower(u.Host), ",")
+		sort.Strings(hosts)
+		topic := strings.Trim(u.Path, "/")
+		return strings.TrimSuffix(scheme, "+ssl") + "://" + strings.Join(hosts, ",") + "/" + topic, nil
+	default:
+		return scheme + "://" + strings.ToLower(u.Host) + path.Clean("/"+u.Path), nil
+	}
+}
+
+// findSinkConflicts returns the tables of the changefeeds writing to the same
+// downstream as the sink uri, which are also in the tables.
+func findSinkConflicts(
+	infos map[model.ChangeFeedID]*model.ChangeFeedInfo, sinkURI string, tables []model.TableName,
+) (map[model.ChangeFeedID][]model.TableName, error) {
+	dest, err := sinkDestination(sinkURI)
+	if err != nil || dest == "" {
+		return nil, err
+	}
+	conflicts := make(map[model.ChangeFeedID][]model.TableName)
+	for id, info := range infos {
+		if info.State == model.StateRemoved || info.State == model.StateFinished {
+			continue
+		}
+		otherDest, err := sinkDestination(info.SinkURI)
+		if err != nil || otherDest != dest {
+			// the changefeed with an invalid sink uri can't write anything
+			continue
+		}
+		f, err := filter.NewFilter(info.Config)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, table := range tables {
+			if !f.ShouldIgnoreTable(table.Schema, table.Table) {
+				conflicts[id] = append(conflicts[id], table)
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// FindSinkConflicts returns the tables of the other changefeeds than id
+// writing to the same downstream as the sink uri, which are also in the
+// tables. The changefeeds are the raw changefeed infos read from etcd.
+func FindSinkConflicts(
+	raw map[model.ChangeFeedID]*mvccpb.KeyValue, id model.ChangeFeedID, sinkURI string, tables []model.TableName,
+) (map[model.ChangeFeedID][]model.TableName, error) {
+	infos := make(map[model.ChangeFeedID]*model.ChangeFeedInfo, len(raw))
+	for otherID, rawKv := range raw {
+		if otherID == id {
+			continue
+		}
+		info := &model.ChangeFeedInfo{}
+		if err := info.Unmarshal(rawKv.Value); err != nil {
+			return nil, errors.Trace(err)
+		}
+		// fill the configs of the changefeeds created by the old versions
+		if err := info.VerifyAndFix(); err != nil {
+			return nil, errors.Trace(err)
+		}
+		infos[otherID] = info
+	}
+	return findSinkConflicts(infos, sinkURI, tables)
+}
+
+// SinkConflictError returns the error of the conflicts found by
+// FindSinkConflicts, it's nil if there is no conflict.
+func SinkConflictError(conflicts map[model.ChangeFeedID][]model.TableName) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(conflicts))
+	for id := range conflicts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return cerror.ErrSinkConflict.GenWithStackByArgs(strings.Join(ids, ","))
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type sinkConflictSuite struct{}
+
+var _ = check.Suite(&sinkConflictSuite{})
+
+func (s *sinkConflictSuite) TestFindSinkConflicts(c *check.C) {
+	defer testleak.AfterTest(c)()
+	newInfo := func(sinkURI string, rules ...string) *model.ChangeFeedInfo {
+		cfg := config.GetDefaultReplicaConfig()
+		if len(rules) != 0 {
+			cfg.Filter.Rules = rules
+		}
+		return &model.ChangeFeedInfo{SinkURI: sinkURI, Config: cfg, State: model.StateNormal}
+	}
+	removed := newInfo("mysql://root@127.0.0.1:4000/")
+	removed.State = model.StateRemoved
+	infos := map[model.ChangeFeedID]*model.ChangeFeedInfo{
+		"same-host":     newInfo("mysql://root:${secret:env:PASSWORD}@127.0.0.1/?worker-count=4"),
+		"other-tables":  newInfo("tidb://root@127.0.0.1:4000/", "test.t2"),
+		"other-host":    newInfo("mysql://root@127.0.0.2:4000/"),
+		"removed":       removed,
+		"same-topic":    newInfo("kafka://127.0.0.1:9092,127.0.0.2:9092/topic?protocol=canal"),
+		"other-topic":   newInfo("kafka://127.0.0.1:9092/topic2"),
+		"invalid-sink":  newInfo("mysql://root@127.0.0.1:abc/"),
+		"blackhole-one": newInfo("blackhole://"),
+		"shadow":        newInfo("mysql://root@127.0.0.1:4000/?schema-suffix=_shadow"),
+	}
+	tables := []model.TableName{{Schema: "test", Table: "t1"}}
+
+	conflicts, err := findSinkConflicts(infos, "mysql+ssl://root@127.0.0.1:4000/", tables)
+	c.Assert(err, check.IsNil)
+	c.Assert(conflicts, check.DeepEquals, map[model.ChangeFeedID][]model.TableName{
+		"same-host": tables,
+	})
+	conflicts, err = findSinkConflicts(infos, "kafka://127.0.0.2:9092,127.0.0.1:9092/topic/", tables)
+	c.Assert(err, check.IsNil)
+	c.Assert(conflicts, check.DeepEquals, map[model.ChangeFeedID][]model.TableName{
+		"same-topic": tables,
+	})
+	// the shadow tables are in the schemas with the suffix
+	conflicts, err = findSinkConflicts(infos, "mysql://root@127.0.0.1:4000/?schema-suffix=_shadow", tables)
+	c.Assert(err, check.IsNil)
+	c.Assert(conflicts, check.DeepEquals, map[model.ChangeFeedID][]model.TableName{
+		"shadow": tables,
+	})
+	conflicts, err = findSinkConflicts(infos, "blackhole://", tables)
+	c.Assert(err, check.IsNil)
+	c.Assert(conflicts, check.HasLen, 0)
+
+	c.Assert(SinkConflictError(nil), check.IsNil)
+	err = SinkConflictError(map[model.ChangeFeedID][]model.TableName{"b": tables, "a": tables})
+	c.Assert(cerror.ErrSinkConflict.Equal(err), check.IsTrue)
+	c.Assert(err, check.ErrorMatches, ".*changefeeds a,b.*")
+}
@@ -28,7 +28,10 @@ import (
 	"github.com/pingcap/ticdc/cdc"
 	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/audit"
+	"github.com/pingcap/ticdc/pkg/diskmanager"
 	"github.com/pingcap/ticdc/pkg/logutil"
+	"github.com/pingcap/ticdc/pkg/security"
 	"github.com/pingcap/ticdc/pkg/version"
 	"github.com/spf13/cobra"
 	pd "github.com/tikv/pd/client"
@@ -44,8 +47,13 @@ func init() {
 	cliCmd := newCliCommand()
 	cliCmd.PersistentFlags().StringVar(&cliPdAddr, "pd", "http://127.0.0.1:2379", "PD address, use ',' to separate multiple PDs")
 	cliCmd.PersistentFlags().BoolVarP(&interact, "interact", "i", false, "Run cdc cli with readline")
+	cliCmd.PersistentFlags().StringVar(&cliNamespace, "namespace", "", "Namespace of the changefeeds, "+
+		"the changefeed ids are qualified by it and only the changefeeds in it are listed")
 	cliCmd.PersistentFlags().StringVar(&cliLogLevel, "log-level", "warn", "log level (etc: debug|info|warn|error)")
 	addSecurityFlags(cliCmd.PersistentFlags(), false /* isServer */)
+	addAuditFlags(cliCmd.PersistentFlags())
+	cliCmd.PersistentFlags().BoolVar(&cliIgnoreIncompatible, "ignore-incompatible-versions", false,
+		"Only warn instead of refusing the upstream TiKV, PD and TiDB versions not supported by TiCDC")
 	rootCmd.AddCommand(cliCmd)
 }
 
@@ -72,8 +80,10 @@ var (
 	simplified        bool
 	cliLogLevel       string
 	changefeedListAll bool
+	listOptions       cdc.ListOptions
 
 	changefeedID            string
+	cliNamespace            string
 	captureID               string
 	interval                uint
 	disableGCSafePointCheck bool
@@ -81,22 +91,32 @@ var (
 	syncPointEnabled  bool
 	syncPointInterval time.Duration
 
+	changefeedTTL time.Duration
+
 	optForceRemove bool
+	optForceCreate bool
+
+	shadowOf string
+
+	tableStartTs []string
+
+	moveTableID int64
+	optPinTable bool
+
+	switchTs uint64
+
+	// cliIgnoreIncompatible only warns the incompatible upstream versions
+	cliIgnoreIncompatible bool
 
 	defaultContext context.Context
 )
 
-// changefeedCommonInfo holds some common used information of a changefeed
-type changefeedCommonInfo struct {
-	ID      string              `json:"id"`
-	Summary *cdc.ChangefeedResp `json:"summary"`
-}
-
 // capture holds capture information
 type capture struct {
-	ID            string `json:"id"`
-	IsOwner       bool   `json:"is-owner"`
-	AdvertiseAddr string `json:"address"`
+	ID            string                 `json:"id"`
+	IsOwner       bool                   `json:"is-owner"`
+	AdvertiseAddr string                 `json:"address"`
+	DataDir       *diskmanager.DirHealth `json:"data-dir,omitempty"`
 }
 
 // cfMeta holds changefeed info and changefeed status
@@ -105,6 +125,7 @@ type cfMeta struct {
 	Status     *model.ChangeFeedStatus `json:"status"`
 	Count      uint64                  `json:"count"`
 	TaskStatus []captureTaskStatus     `json:"task-status"`
+	Warnings   []*model.RunningError   `json:"warnings,omitempty"`
 }
 
 type captureTaskStatus struct {
@@ -122,6 +143,8 @@ type profileStatus struct {
 type processorMeta struct {
 	Status   *model.TaskStatus   `json:"status"`
 	Position *model.TaskPosition `json:"position"`
+	// Stats is served by the capture, it's absent if the capture is unreachable
+	Stats *model.ProcessorStats `json:"stats,omitempty"`
 }
 
 func newCliCommand() *cobra.Command {
@@ -130,8 +153,17 @@ func newCliCommand() *cobra.Command {
 		Short: "Manage replication task and TiCDC cluster",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			initCmd(cmd, &logutil.Config{Level: cliLogLevel})
+			if err := audit.Init(auditLog); err != nil {
+				return errors.Annotate(err, "fail to open audit log")
+			}
+			if err := applyNamespace(cliNamespace); err != nil {
+				return err
+			}
 
 			credential := getCredential()
+			if err := security.SetDefaultTLSOptions(credential.TLSOptions); err != nil {
+				return errors.Annotate(err, "fail to validate TLS settings")
+			}
 			tlsConfig, err := credential.ToTLSConfig()
 			if err != nil {
 				return errors.Annotate(err, "fail to validate TLS settings")
@@ -196,8 +228,11 @@ func newCliCommand() *cobra.Command {
 				return errors.Annotatef(err, "fail to open PD client, pd-addr=\"%s\"", cliPdAddr)
 			}
 			ctx := defaultContext
-			errorTiKVIncompatible := true // Error if TiKV is incompatible.
-			err = version.CheckClusterVersion(ctx, pdCli, pdEndpoints[0], credential, errorTiKVIncompatible)
+			err = version.CheckClusterVersion(ctx, pdCli, pdEndpoints[0], credential, cliIgnoreIncompatible)
+			if err != nil {
+				return err
+			}
+			err = version.CheckTiDBVersion(ctx, etcdCli, cliIgnoreIncompatible)
 			if err != nil {
 				return err
 			}
@@ -213,9 +248,12 @@ func newCliCommand() *cobra.Command {
 	command.AddCommand(
 		newCaptureCommand(),
 		newChangefeedCommand(),
+		newClusterCommand(),
 		newProcessorCommand(),
 		newUnsafeCommand(),
 		newTsoCommand(),
+		newVerifyCommand(),
+		newBenchCommand(),
 	)
 
 	return command
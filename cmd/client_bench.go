@@ -0,0 +1,149 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/pkg/bench"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/verify"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+func getChangefeedProgress(ctx context.Context, changefeedID string) (bench.ChangefeedProgress, error) {
+	var progress bench.ChangefeedProgress
+	status, _, err := cdcEtcdCli.GetChangeFeedStatus(ctx, changefeedID)
+	if err != nil {
+		return progress, err
+	}
+	taskPositions, err := cdcEtcdCli.GetAllTaskPositions(ctx, changefeedID)
+	if err != nil {
+		return progress, err
+	}
+	for _, pinfo := range taskPositions {
+		progress.Count += pinfo.Count
+	}
+	ts, _, err := pdCli.GetTS(ctx)
+	if err != nil {
+		return progress, errors.Trace(err)
+	}
+	progress.Lag = time.Duration(ts-oracle.ExtractPhysical(status.CheckpointTs)) * time.Millisecond
+	return progress, nil
+}
+
+func newBenchCommand() *cobra.Command {
+	var (
+		upstreamURI string
+		skipPrepare bool
+		duration    time.Duration
+	)
+	cfg := &bench.Config{}
+	command := &cobra.Command{
+		Use:   "bench",
+		Short: "Write a synthetic workload to the upstream and measure the throughput and the lag of a changefeed",
+		Long: `Write a synthetic workload to the upstream and measure the throughput and the lag of a changefeed.
+The changefeed must replicate the database of the workload, which is dropped and created again
+unless --skip-prepare is set.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := defaultContext
+			if interval == 0 {
+				return cerror.ErrBenchInvalidConfig.GenWithStack("the interval must be positive")
+			}
+			if _, err := cdcEtcdCli.GetChangeFeedInfo(ctx, changefeedID); err != nil {
+				return err
+			}
+			db, err := verify.OpenDB(ctx, upstreamURI)
+			if err != nil {
+				return errors.Annotate(err, "fail to connect the upstream")
+			}
+			defer db.Close()
+			db.SetMaxOpenConns(cfg.Workers + 1)
+			db.SetMaxIdleConns(cfg.Workers + 1)
+
+			workload, err := bench.NewWorkload(db, cfg)
+			if err != nil {
+				return err
+			}
+			if !skipPrepare {
+				if err := workload.Prepare(ctx); err != nil {
+					return err
+				}
+			}
+			initial, err := getChangefeedProgress(ctx, changefeedID)
+			if err != nil {
+				return err
+			}
+			recorder := bench.NewRecorder(time.Now(), initial)
+
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			if duration > 0 {
+				runCtx, cancel = context.WithTimeout(ctx, duration)
+				defer cancel()
+			}
+			errg, runCtx := errgroup.WithContext(runCtx)
+			errg.Go(func() error {
+				return workload.Run(runCtx)
+			})
+			errg.Go(func() error {
+				tick := time.NewTicker(time.Duration(interval) * time.Second)
+				defer tick.Stop()
+				for {
+					select {
+					case <-runCtx.Done():
+						return nil
+					case <-tick.C:
+					}
+					progress, err := getChangefeedProgress(runCtx, changefeedID)
+					if err != nil {
+						if runCtx.Err() != nil {
+							return nil
+						}
+						return err
+					}
+					if err := jsonPrint(cmd, recorder.Record(time.Now(), workload.Stats(), progress)); err != nil {
+						return err
+					}
+				}
+			})
+			if err := errg.Wait(); err != nil {
+				return err
+			}
+			return jsonPrint(cmd, recorder.Summary())
+		},
+	}
+	command.SetOutput(os.Stdout)
+	command.PersistentFlags().StringVarP(&changefeedID, "changefeed-id", "c", "", "Replication task (changefeed) ID")
+	command.PersistentFlags().StringVar(&upstreamURI, "upstream-uri", "", "URI of the upstream TiDB, such as mysql://root@127.0.0.1:4000/")
+	command.PersistentFlags().StringVar(&cfg.Database, "database", "cdc_bench", "Database of the tables of the workload")
+	command.PersistentFlags().IntVar(&cfg.Tables, "tables", 8, "Number of the tables")
+	command.PersistentFlags().IntVar(&cfg.RowWidth, "row-width", 128, "Bytes of the padding column of a row")
+	command.PersistentFlags().IntVar(&cfg.QPS, "qps", 0, "Max rows written per second, 0 means no limit")
+	command.PersistentFlags().IntVar(&cfg.TxnSize, "txn-size", 10, "Number of the rows written in a transaction")
+	command.PersistentFlags().Float64Var(&cfg.UpdateRatio, "update-ratio", 0, "Ratio of the updates in the written rows")
+	command.PersistentFlags().DurationVar(&cfg.DDLInterval, "ddl-interval", 0, "Interval of the DDLs, 0 means no DDL")
+	command.PersistentFlags().IntVar(&cfg.Workers, "workers", 16, "Number of the concurrent connections writing rows")
+	command.PersistentFlags().DurationVar(&duration, "duration", 5*time.Minute, "Duration of the workload, 0 means running until interrupted")
+	command.PersistentFlags().BoolVar(&skipPrepare, "skip-prepare", false, "Write to the existing tables of the workload")
+	command.PersistentFlags().UintVarP(&interval, "interval", "I", 10, "Interval in seconds for outputing the latest statistics")
+	_ = command.MarkPersistentFlagRequired("changefeed-id")
+	_ = command.MarkPersistentFlagRequired("upstream-uri")
+	return command
+}
@@ -15,6 +15,7 @@ package cmd
 
 import (
 	_ "github.com/go-sql-driver/mysql" // mysql driver
+	"github.com/pingcap/ticdc/cdc"
 	"github.com/spf13/cobra"
 )
 
@@ -36,12 +37,26 @@ func newListCaptureCommand() *cobra.Command {
 		Short: "List all captures in TiCDC cluster",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := defaultContext
-			captures, err := getAllCaptures(ctx)
+			opts, err := parseListOptions(cdc.CaptureSortKeys...)
 			if err != nil {
 				return err
 			}
+			// the captures are shared by all namespaces
+			opts.Namespace = ""
+			_, raw, err := cdcEtcdCli.GetCaptures(ctx)
+			if err != nil {
+				return err
+			}
+			raw = cdc.FilterCaptures(raw, opts)
+			start, end := opts.Page(len(raw))
+			captures, err := newCaptures(ctx, raw[start:end])
+			if err != nil {
+				return err
+			}
+			printListTotal(cmd, opts, len(captures), len(raw))
 			return jsonPrint(cmd, captures)
 		},
 	}
+	addListFlags(command.PersistentFlags(), false /* withState */, cdc.CaptureSortKeys...)
 	return command
 }
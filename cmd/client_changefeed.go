@@ -15,9 +15,9 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,6 +30,8 @@ import (
 	"github.com/pingcap/ticdc/pkg/cyclic"
 	"github.com/pingcap/ticdc/pkg/cyclic/mark"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/secret"
 	"github.com/pingcap/ticdc/pkg/security"
 	"github.com/pingcap/ticdc/pkg/util"
 	"github.com/pingcap/tidb/store/tikv/oracle"
@@ -39,14 +41,9 @@ import (
 )
 
 const (
-	defaultSortDir = "/tmp/cdc_sort"
+	defaultSortDir = model.DefaultSortDir
 )
 
-var forceEnableOldValueProtocols = []string{
-	"canal",
-	"maxwell",
-}
-
 func newChangefeedCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "changefeed",
@@ -58,7 +55,13 @@ func newChangefeedCommand() *cobra.Command {
 		newCreateChangefeedCommand(),
 		newUpdateChangefeedCommand(),
 		newStatisticsChangefeedCommand(),
+		newStatsChangefeedCommand(),
 		newCreateChangefeedCyclicCommand(),
+		newCompareShadowCommand(),
+		newRebaseChangefeedCommand(),
+		newMoveTableCommand(),
+		newSwitchSinkCommand(),
+		newSimulateScheduleCommand(),
 	)
 	// Add pause, resume, remove changefeed
 	for _, cmd := range newAdminChangefeedCommand() {
@@ -126,47 +129,65 @@ func newListChangefeedCommand() *cobra.Command {
 		Short: "List all replication tasks (changefeeds) in TiCDC cluster",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := defaultContext
-			_, raw, err := cdcEtcdCli.GetChangeFeeds(ctx)
+			opts, err := parseListOptions(cdc.ChangefeedSortKeys...)
 			if err != nil {
 				return err
 			}
-			changefeedIDs := make(map[string]struct{}, len(raw))
-			for id := range raw {
-				changefeedIDs[id] = struct{}{}
-			}
-			if changefeedListAll {
-				statuses, err := cdcEtcdCli.GetAllChangeFeedStatus(ctx)
-				if err != nil {
+			resp, err := applyOwnerChangefeedList(ctx, opts, changefeedListAll, getCredential())
+			if err != nil {
+				if errors.Cause(err) != errOwnerNotFound {
 					return err
 				}
-				for cid := range statuses {
-					changefeedIDs[cid] = struct{}{}
-				}
-			}
-			cfs := make([]*changefeedCommonInfo, 0, len(changefeedIDs))
-			for id := range changefeedIDs {
-				cfci := &changefeedCommonInfo{ID: id}
-				resp, err := applyOwnerChangefeedQuery(ctx, id, getCredential())
+				// if no capture is available, only the ids are listed
+				log.Warn("query changefeed info failed", zap.String("error", err.Error()))
+				resp, err = listChangefeedIDs(ctx, opts)
 				if err != nil {
-					// if no capture is available, the query will fail, just add a warning here
-					log.Warn("query changefeed info failed", zap.String("error", err.Error()))
-				} else {
-					info := &cdc.ChangefeedResp{}
-					err = json.Unmarshal([]byte(resp), info)
-					if err != nil {
-						return err
-					}
-					cfci.Summary = info
+					return err
 				}
-				cfs = append(cfs, cfci)
 			}
-			return jsonPrint(cmd, cfs)
+			printListTotal(cmd, opts, len(resp.Changefeeds), resp.Total)
+			return jsonPrint(cmd, resp.Changefeeds)
 		},
 	}
 	command.PersistentFlags().BoolVarP(&changefeedListAll, "all", "a", false, "List all replication tasks(including removed and finished)")
+	addListFlags(command.PersistentFlags(), true /* withState */, cdc.ChangefeedSortKeys...)
 	return command
 }
 
+// listChangefeedIDs lists the ids of the changefeeds from etcd without the
+// owner, so the changefeeds are only filtered and sorted by the ids.
+func listChangefeedIDs(ctx context.Context, opts *cdc.ListOptions) (*cdc.ChangefeedListResp, error) {
+	if len(opts.States) > 0 || opts.SortBy != cdc.ChangefeedSortKeys[0] {
+		return nil, errors.Annotate(errOwnerNotFound, "the states of the changefeeds are unknown")
+	}
+	_, raw, err := cdcEtcdCli.GetChangeFeeds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changefeedIDs := make(map[string]struct{}, len(raw))
+	for id := range raw {
+		changefeedIDs[id] = struct{}{}
+	}
+	if changefeedListAll {
+		statuses, err := cdcEtcdCli.GetAllChangeFeedStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for cid := range statuses {
+			changefeedIDs[cid] = struct{}{}
+		}
+	}
+	cfs := make([]*cdc.ChangefeedListItem, 0, len(changefeedIDs))
+	for id := range changefeedIDs {
+		if opts.Match(id, "") {
+			cfs = append(cfs, &cdc.ChangefeedListItem{ID: id})
+		}
+	}
+	sort.SliceStable(cfs, opts.Less(func(i, j int) bool { return cfs[i].ID < cfs[j].ID }))
+	start, end := opts.Page(len(cfs))
+	return &cdc.ChangefeedListResp{Total: len(cfs), Changefeeds: cfs[start:end]}, nil
+}
+
 func newQueryChangefeedCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "query",
@@ -207,7 +228,13 @@ func newQueryChangefeedCommand() *cobra.Command {
 			for captureID, status := range processorInfos {
 				taskStatus = append(taskStatus, captureTaskStatus{CaptureID: captureID, TaskStatus: status})
 			}
+			if info != nil {
+				info.SinkURI = secret.RedactURI(info.SinkURI)
+			}
 			meta := &cfMeta{Info: info, Status: status, Count: count, TaskStatus: taskStatus}
+			for _, pinfo := range taskPositions {
+				meta.Warnings = append(meta.Warnings, pinfo.Warnings...)
+			}
 			if info == nil {
 				log.Warn("this changefeed has been deleted, the residual meta data will be completely deleted within 24 hours.")
 			}
@@ -239,16 +266,34 @@ func verifyChangefeedParamers(ctx context.Context, cmd *cobra.Command, isCreate
 			return nil, err
 		}
 	}
+	if changefeedTTL < 0 {
+		return nil, errors.Errorf("invalid ttl %s", changefeedTTL)
+	}
+
+	var primary *model.ChangeFeedInfo
+	if isCreate && shadowOf != "" {
+		var err error
+		primary, err = verifyShadowOf(ctx, shadowOf, sinkURI)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	cfg := config.GetDefaultReplicaConfig()
 	if len(configFile) > 0 {
 		if err := strictDecodeFile(configFile, "cdc", cfg); err != nil {
 			return nil, err
 		}
+	} else if primary != nil {
+		// the shadow replicates the same tables as the primary by default
+		cfg = primary.Config.Clone()
 	}
 	if disableGCSafePointCheck {
 		cfg.CheckGCSafePoint = false
 	}
+	if err := cfg.Mounter.SkipEvent.Validate(); err != nil {
+		return nil, err
+	}
 	if cyclicReplicaID != 0 || len(cyclicFilterReplicaIDs) != 0 {
 		if !(cyclicReplicaID != 0 && len(cyclicFilterReplicaIDs) != 0) {
 			return nil, errors.New("invaild cyclic config, please make sure using " +
@@ -268,18 +313,14 @@ func verifyChangefeedParamers(ctx context.Context, cmd *cobra.Command, isCreate
 	}
 
 	if !cfg.EnableOldValue {
-		sinkURIParsed, err := url.Parse(sinkURI)
+		needOldValue, err := model.SinkNeedsOldValue(sinkURI, cfg)
 		if err != nil {
-			return nil, cerror.WrapError(cerror.ErrSinkURIInvalid, err)
+			return nil, err
 		}
-
-		protocol := sinkURIParsed.Query().Get("protocol")
-		for _, fp := range forceEnableOldValueProtocols {
-			if protocol == fp {
-				log.Warn("Attemping to replicate without old value enabled. CDC will enable old value and continue.", zap.String("protocol", protocol))
-				cfg.EnableOldValue = true
-				break
-			}
+		if needOldValue {
+			log.Warn("Attemping to replicate without old value enabled. CDC will enable old value and continue.",
+				zap.String("sink-uri", secret.RedactURI(sinkURI)))
+			cfg.EnableOldValue = true
 		}
 
 		if cfg.ForceReplicate {
@@ -310,6 +351,13 @@ func verifyChangefeedParamers(ctx context.Context, cmd *cobra.Command, isCreate
 		State:             model.StateNormal,
 		SyncPointEnabled:  syncPointEnabled,
 		SyncPointInterval: syncPointInterval,
+		TTL:               changefeedTTL,
+	}
+	if primary != nil {
+		// the syncpoints of the shadow are at the same ts as the primary
+		info.ShadowOf = shadowOf
+		info.SyncPointEnabled = true
+		info.SyncPointInterval = primary.SyncPointInterval
 	}
 
 	if info.Engine != model.SortInMemory && (info.SortDir == ".") {
@@ -358,6 +406,14 @@ func verifyChangefeedParamers(ctx context.Context, cmd *cobra.Command, isCreate
 			return nil, errors.New("normal tables and mark tables are not paired, " +
 				"please run `cdc cli changefeed cyclic create-marktables`")
 		}
+		tables := eligibleTables
+		if cfg.ForceReplicate {
+			tables = append(tables, ineligibleTables...)
+		}
+		if err := verifySinkConflicts(ctx, cmd, info.SinkURI, tables); err != nil {
+			return nil, err
+		}
+		info.IgnoreSinkConflicts = optForceCreate
 	}
 
 	for _, opt := range opts {
@@ -398,6 +454,7 @@ func changefeedConfigVariables(command *cobra.Command) {
 	command.PersistentFlags().BoolVar(&cyclicSyncDDL, "cyclic-sync-ddl", true, "(Expremental) Cyclic replication sync DDL of changefeed")
 	command.PersistentFlags().BoolVar(&syncPointEnabled, "sync-point", false, "(Expremental) Set and Record syncpoint in replication(default off)")
 	command.PersistentFlags().DurationVar(&syncPointInterval, "sync-interval", 10*time.Minute, "(Expremental) Set the interval for syncpoint in replication(default 10min)")
+	command.PersistentFlags().DurationVar(&changefeedTTL, "ttl", 0, "Remove the changefeed automatically after the duration since it's created, 0 means never")
 }
 
 func newCreateChangefeedCommand() *cobra.Command {
@@ -409,7 +466,11 @@ func newCreateChangefeedCommand() *cobra.Command {
 			ctx := defaultContext
 			id := changefeedID
 			if id == "" {
-				id = uuid.New().String()
+				var err error
+				id, err = model.QualifyChangefeedID(cliNamespace, uuid.New().String())
+				if err != nil {
+					return err
+				}
 			}
 
 			info, err := verifyChangefeedParamers(ctx, cmd, true /* isCreate */, getCredential())
@@ -419,16 +480,16 @@ func newCreateChangefeedCommand() *cobra.Command {
 			if info == nil {
 				return nil
 			}
+			info.Creator = cliIdentity()
+			info.ClusterID = pdCli.GetClusterID(ctx)
 
-			infoStr, err := info.Marshal()
-			if err != nil {
-				return err
-			}
 			err = cdcEtcdCli.CreateChangefeedInfo(ctx, info, id)
+			auditCLI("create changefeed", id, map[string]string{"sink-uri": secret.RedactURI(info.SinkURI)}, err)
 			if err != nil {
 				return err
 			}
-			cmd.Printf("Create changefeed successfully!\nID: %s\nInfo: %s\n", id, infoStr)
+			// the sink uri is redacted by String
+			cmd.Printf("Create changefeed successfully!\nID: %s\nInfo: %s\n", id, info.String())
 			return nil
 		},
 	}
@@ -436,6 +497,9 @@ func newCreateChangefeedCommand() *cobra.Command {
 	command.PersistentFlags().BoolVar(&noConfirm, "no-confirm", false, "Don't ask user whether to ignore ineligible table")
 	command.PersistentFlags().StringVarP(&changefeedID, "changefeed-id", "c", "", "Replication task (changefeed) ID")
 	command.PersistentFlags().BoolVarP(&disableGCSafePointCheck, "disable-gc-check", "", false, "Disable GC safe point check")
+	command.PersistentFlags().BoolVar(&optForceCreate, "force", false, "Create the changefeed even if other changefeeds write the same tables to the same downstream")
+	command.PersistentFlags().StringVar(&shadowOf, "shadow-of", "", "Create a shadow of the changefeed, which replicates the same tables "+
+		"to the schemas with the schema-suffix of the sink uri for comparison")
 
 	return command
 }
@@ -463,6 +527,26 @@ func newUpdateChangefeedCommand() *cobra.Command {
 			info.StartTs = old.StartTs
 			info.ErrorHis = old.ErrorHis
 			info.Error = old.Error
+			info.Creator = old.Creator
+			info.ClusterID = old.ClusterID
+			info.IgnoreSinkConflicts = old.IgnoreSinkConflicts
+
+			status, _, err := cdcEtcdCli.GetChangeFeedStatus(ctx, changefeedID)
+			if err != nil && cerror.ErrChangeFeedNotExists.NotEqual(err) {
+				return err
+			}
+			var checkpointTs uint64
+			if status != nil {
+				checkpointTs = status.CheckpointTs
+			}
+			ts, logical, err := pdCli.GetTS(ctx)
+			if err != nil {
+				return err
+			}
+			info.TableStartTs, err = mergeTableStartTs(old, info, tableStartTs, checkpointTs, oracle.ComposeTS(ts, logical))
+			if err != nil {
+				return err
+			}
 
 			resp, err := applyOwnerChangefeedQuery(ctx, changefeedID, getCredential())
 			// if no cdc owner exists, allow user to update changefeed config
@@ -485,6 +569,10 @@ func newUpdateChangefeedCommand() *cobra.Command {
 			}
 			cmd.Printf("Diff of changefeed config:\n")
 			for _, change := range changelog {
+				if len(change.Path) > 0 && change.Path[0] == "SinkURI" {
+					change.From = secret.RedactURI(fmt.Sprint(change.From))
+					change.To = secret.RedactURI(fmt.Sprint(change.To))
+				}
 				cmd.Printf("%+v\n", change)
 			}
 
@@ -502,27 +590,85 @@ func newUpdateChangefeedCommand() *cobra.Command {
 			}
 
 			err = cdcEtcdCli.SaveChangeFeedInfo(ctx, info, changefeedID)
-			if err != nil {
-				return err
-			}
-			infoStr, err := info.Marshal()
+			auditCLI("update changefeed", changefeedID, map[string]string{"sink-uri": secret.RedactURI(info.SinkURI)}, err)
 			if err != nil {
 				return err
 			}
 			cmd.Printf("Update changefeed config successfully! "+
 				"Will take effect only if the changefeed has been paused before this command"+
-				"\nID: %s\nInfo: %s\n", changefeedID, infoStr)
+				"\nID: %s\nInfo: %s\n", changefeedID, info.String())
 			return nil
 		},
 	}
 	changefeedConfigVariables(command)
 	command.PersistentFlags().StringVarP(&changefeedID, "changefeed-id", "c", "", "Replication task (changefeed) ID")
 	command.PersistentFlags().BoolVar(&noConfirm, "no-confirm", false, "Don't ask user whether to confirm update changefeed config")
+	command.PersistentFlags().StringSliceVar(&tableStartTs, "table-start-ts", nil, "Start the table added by the new filter from the ts "+
+		"instead of the checkpoint of the changefeed, in the format of 'schema.table=ts' and 'now' is the current ts, can be specified multiple times")
 	_ = command.MarkPersistentFlagRequired("changefeed-id")
 
 	return command
 }
 
+// mergeTableStartTs merges the start ts of the tables specified in the format
+// of "schema.table=ts" into the ones of the old changefeed info. A table must
+// be ignored by the old filter and replicated by the new one, and its start ts
+// must be in [checkpointTs, currentTs]. The start ts reached by the checkpoint
+// of the changefeed are dropped since they take no effect.
+func mergeTableStartTs(
+	old, info *model.ChangeFeedInfo, specs []string, checkpointTs, currentTs uint64,
+) (map[string]uint64, error) {
+	merged := make(map[string]uint64, len(old.TableStartTs)+len(specs))
+	for name, ts := range old.TableStartTs {
+		if ts > checkpointTs {
+			merged[name] = ts
+		}
+	}
+	if len(specs) > 0 {
+		oldFilter, err := filter.NewFilter(old.Config)
+		if err != nil {
+			return nil, err
+		}
+		newFilter, err := filter.NewFilter(info.Config)
+		if err != nil {
+			return nil, err
+		}
+		for _, spec := range specs {
+			idx := strings.LastIndex(spec, "=")
+			if idx < 0 {
+				return nil, errors.Errorf("invalid table start ts %s, the format is 'schema.table=ts'", spec)
+			}
+			name, tsStr := strings.TrimSpace(spec[:idx]), strings.TrimSpace(spec[idx+1:])
+			parts := strings.SplitN(name, ".", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return nil, errors.Errorf("invalid table %s, the format is 'schema.table'", name)
+			}
+			if !oldFilter.ShouldIgnoreTable(parts[0], parts[1]) {
+				return nil, errors.Errorf("the table %s is already replicated by the changefeed", name)
+			}
+			if newFilter.ShouldIgnoreTable(parts[0], parts[1]) {
+				return nil, errors.Errorf("the table %s is not replicated by the new filter", name)
+			}
+			ts := currentTs
+			if tsStr != "now" {
+				ts, err = strconv.ParseUint(tsStr, 10, 64)
+				if err != nil {
+					return nil, errors.Errorf("invalid start ts %s of the table %s", tsStr, name)
+				}
+			}
+			if ts < checkpointTs || ts > currentTs {
+				return nil, errors.Errorf("the start ts %d of the table %s must be in [%d, %d], "+
+					"between the checkpoint of the changefeed and the current ts", ts, name, checkpointTs, currentTs)
+			}
+			merged[name] = ts
+		}
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return merged, nil
+}
+
 func newStatisticsChangefeedCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "statistics",
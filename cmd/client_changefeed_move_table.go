@@ -0,0 +1,79 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/spf13/cobra"
+)
+
+// newMoveTableCommand moves a table of the changefeed to the given capture
+// manually. The pinned tables stay on their captures and are not moved by the
+// rebalance, they are moved again or unpinned by this command. A pinned table
+// is unpinned if its capture is gone or drained.
+func newMoveTableCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "move-table",
+		Short: "Move a table of the replication task (changefeed) to the given capture",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := defaultContext
+
+			captures, err := getAllCaptures(ctx)
+			if err != nil {
+				return err
+			}
+			found := false
+			for _, c := range captures {
+				if c.ID == captureID {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return errors.Errorf("capture %s is not found", captureID)
+			}
+			statuses, err := cdcEtcdCli.GetAllTaskStatus(ctx, changefeedID)
+			if err != nil {
+				return err
+			}
+			found = false
+			for _, status := range statuses {
+				if _, ok := status.Tables[moveTableID]; ok {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return errors.Errorf("table %d is not replicated by changefeed %s", moveTableID, changefeedID)
+			}
+
+			err = applyOwnerMoveTable(ctx, changefeedID, captureID, moveTableID, optPinTable, getCredential())
+			if err != nil {
+				return err
+			}
+			// the table is moved by the owner asynchronously
+			cmd.Printf("Move table %d of changefeed %s to capture %s, pin: %t\n",
+				moveTableID, changefeedID, captureID, optPinTable)
+			return nil
+		},
+	}
+	command.PersistentFlags().StringVarP(&changefeedID, "changefeed-id", "c", "", "Replication task (changefeed) ID")
+	command.PersistentFlags().StringVarP(&captureID, "capture-id", "p", "", "ID of the capture the table is moved to")
+	command.PersistentFlags().Int64Var(&moveTableID, "table-id", 0, "ID of the table, or the partition of a partitioned table")
+	command.PersistentFlags().BoolVar(&optPinTable, "pin", false, "Pin the table to the capture, it's not moved by the rebalance until it's moved again")
+	_ = command.MarkPersistentFlagRequired("changefeed-id")
+	_ = command.MarkPersistentFlagRequired("capture-id")
+	_ = command.MarkPersistentFlagRequired("table-id")
+	return command
+}
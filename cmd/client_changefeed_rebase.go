@@ -0,0 +1,139 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+	"github.com/spf13/cobra"
+)
+
+// newRebaseChangefeedCommand re-points a stopped or failed changefeed at the
+// current upstream cluster, such as PD is rebuilt or the data is restored into
+// a new cluster. The changefeed replicates from the new start-ts after it's
+// resumed, the changes between its checkpoint and the start-ts are skipped.
+func newRebaseChangefeedCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "rebase",
+		Short: "Re-point a stopped or failed replication task (changefeed) at the current upstream cluster with a new start-ts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := defaultContext
+
+			info, err := cdcEtcdCli.GetChangeFeedInfo(ctx, changefeedID)
+			if err != nil {
+				return err
+			}
+			resp, err := applyOwnerChangefeedQuery(ctx, changefeedID, getCredential())
+			// if no cdc owner exists, allow user to rebase the changefeed
+			if err != nil && errors.Cause(err) != errOwnerNotFound {
+				return err
+			}
+			if err == nil {
+				feed := new(cdc.ChangefeedResp)
+				if err := json.Unmarshal([]byte(resp), feed); err != nil {
+					return errors.Trace(err)
+				}
+				if feed.FeedState != string(model.StateStopped) && feed.FeedState != string(model.StateFailed) {
+					return errors.Errorf("can only rebase the changefeed when it is stopped or failed\nstatus: %s", resp)
+				}
+			}
+
+			if startTs == 0 {
+				ts, logical, err := pdCli.GetTS(ctx)
+				if err != nil {
+					return err
+				}
+				startTs = oracle.ComposeTS(ts, logical)
+			}
+			if err := verifyStartTs(ctx, startTs); err != nil {
+				return err
+			}
+			if err := verifyTargetTs(ctx, startTs, info.TargetTs); err != nil {
+				return err
+			}
+			clusterID := pdCli.GetClusterID(ctx)
+
+			cmd.Printf("Rebase changefeed %s from cluster %d to cluster %d at start-ts %d\n",
+				changefeedID, info.ClusterID, clusterID, startTs)
+			if !noConfirm {
+				cmd.Printf("The changes before the start-ts are not replicated, could you agree to rebase the changefeed [Y/N]\n")
+				var yOrN string
+				_, err = fmt.Scan(&yOrN)
+				if err != nil {
+					return err
+				}
+				if strings.ToLower(strings.TrimSpace(yOrN)) != "y" {
+					cmd.Printf("No rebase to changefeed.\n")
+					return nil
+				}
+			}
+
+			err = rebaseChangefeed(ctx, changefeedID, info, clusterID, startTs)
+			auditCLI("rebase changefeed", changefeedID, map[string]string{
+				"cluster-id": fmt.Sprint(clusterID),
+				"start-ts":   fmt.Sprint(startTs),
+			}, err)
+			if err != nil {
+				return err
+			}
+			cmd.Printf("Rebase changefeed successfully! Resume it to replicate from the new start-ts\nID: %s\nInfo: %s\n",
+				changefeedID, info.String())
+			return nil
+		},
+	}
+	command.PersistentFlags().StringVarP(&changefeedID, "changefeed-id", "c", "", "Replication task (changefeed) ID")
+	command.PersistentFlags().Uint64Var(&startTs, "start-ts", 0, "Start ts of the changefeed in the current upstream cluster, the current ts by default")
+	command.PersistentFlags().BoolVarP(&disableGCSafePointCheck, "disable-gc-check", "", false, "Disable GC safe point check")
+	command.PersistentFlags().BoolVar(&noConfirm, "no-confirm", false, "Don't ask user whether to confirm the rebase")
+	_ = command.MarkPersistentFlagRequired("changefeed-id")
+	return command
+}
+
+// rebaseChangefeed resets the changefeed to start from startTs in the cluster
+// clusterID, the changefeed is left stopped.
+func rebaseChangefeed(
+	ctx context.Context, id model.ChangeFeedID, info *model.ChangeFeedInfo, clusterID, startTs uint64,
+) error {
+	if err := info.Transit(model.StateStopped, fmt.Sprintf("rebased to %d", startTs)); err != nil {
+		return err
+	}
+	// the processors are stopped, their states are stale
+	if err := cdcEtcdCli.RemoveAllTaskStatus(ctx, id); err != nil {
+		return err
+	}
+	if err := cdcEtcdCli.RemoveAllTaskPositions(ctx, id); err != nil {
+		return err
+	}
+	err := cdcEtcdCli.PutChangeFeedStatus(ctx, id, &model.ChangeFeedStatus{
+		ResolvedTs:   startTs,
+		CheckpointTs: startTs,
+		AdminJobType: model.AdminStop,
+	})
+	if err != nil {
+		return err
+	}
+	info.ClusterID = clusterID
+	info.StartTs = startTs
+	info.AdminJobType = model.AdminStop
+	info.Error = nil
+	info.ErrorHis = nil
+	return cdcEtcdCli.SaveChangeFeedInfo(ctx, info, id)
+}
@@ -0,0 +1,198 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/secret"
+	"github.com/pingcap/ticdc/pkg/verify"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// verifyShadowOf returns the primary of the shadow changefeed to create, the
+// shadow writes to the shadow schemas of a MySQL compatible downstream and
+// is compared with the primary at their common syncpoints.
+func verifyShadowOf(ctx context.Context, primaryID model.ChangeFeedID, sinkURI string) (*model.ChangeFeedInfo, error) {
+	u, err := secret.ParseURI(sinkURI)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "mysql", "mysql+ssl", "tidb", "tidb+ssl":
+	default:
+		return nil, errors.Errorf("the sink of a shadow changefeed must be MySQL or TiDB, got %s", u.Scheme)
+	}
+	if u.Query().Get("schema-suffix") == "" {
+		return nil, errors.New("the sink uri of a shadow changefeed must have the schema-suffix parameter")
+	}
+	primary, err := cdcEtcdCli.GetChangeFeedInfo(ctx, primaryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := primary.VerifyAndFix(); err != nil {
+		return nil, err
+	}
+	if primary.ShadowOf != "" {
+		return nil, errors.Errorf("changefeed %s is a shadow of changefeed %s", primaryID, primary.ShadowOf)
+	}
+	if !primary.SyncPointEnabled {
+		return nil, errors.Errorf("the syncpoint of changefeed %s is not enabled", primaryID)
+	}
+	return primary, nil
+}
+
+type compareShadowResult struct {
+	Time       time.Time          `json:"time"`
+	Primary    *verify.Syncpoint  `json:"primary"`
+	Shadow     *verify.Syncpoint  `json:"shadow"`
+	Mismatches []*verify.Mismatch `json:"mismatches"`
+}
+
+// compareShadow compares the shadow tables with the tables of the primary at
+// their latest common syncpoint.
+func compareShadow(ctx context.Context, shadowID model.ChangeFeedID, shadow *model.ChangeFeedInfo, chunkSize int) (*compareShadowResult, error) {
+	primary, err := cdcEtcdCli.GetChangeFeedInfo(ctx, shadow.ShadowOf)
+	if err != nil {
+		return nil, err
+	}
+	f, err := filter.NewFilter(shadow.Config)
+	if err != nil {
+		return nil, err
+	}
+	shadowURI, err := secret.ResolveURI(ctx, shadow.SinkURI)
+	if err != nil {
+		return nil, err
+	}
+	primaryURI, err := secret.ResolveURI(ctx, primary.SinkURI)
+	if err != nil {
+		return nil, err
+	}
+	u, err := secret.ParseURI(shadowURI)
+	if err != nil {
+		return nil, err
+	}
+	shadowDB, err := verify.OpenDB(ctx, shadowURI)
+	if err != nil {
+		return nil, errors.Annotate(err, "fail to connect the downstream of the shadow")
+	}
+	defer shadowDB.Close()
+	primaryDB, err := verify.OpenDB(ctx, primaryURI)
+	if err != nil {
+		return nil, errors.Annotate(err, "fail to connect the downstream of the primary")
+	}
+	defer primaryDB.Close()
+
+	primarySp, shadowSp, err := verify.LatestCommonSyncpoint(ctx, primaryDB, shadow.ShadowOf, shadowDB, shadowID)
+	if err != nil {
+		return nil, err
+	}
+	// the snapshots are set in the sessions of the connections
+	primaryConn, err := primaryDB.Conn(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer primaryConn.Close()
+	shadowConn, err := shadowDB.Conn(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer shadowConn.Close()
+	// the downstream of the primary is compared as the upstream
+	sp := &verify.Syncpoint{PrimaryTs: primarySp.SecondaryTs, SecondaryTs: shadowSp.SecondaryTs}
+	checker, err := verify.NewChecker(ctx, primaryConn, shadowConn, f, chunkSize, sp)
+	if err != nil {
+		return nil, err
+	}
+	checker.SetDownstreamSchemaSuffix(u.Query().Get("schema-suffix"))
+	mismatches, err := checker.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &compareShadowResult{
+		Time:       time.Now(),
+		Primary:    primarySp,
+		Shadow:     shadowSp,
+		Mismatches: mismatches,
+	}, nil
+}
+
+func newCompareShadowCommand() *cobra.Command {
+	var (
+		compareInterval time.Duration
+		chunkSize       int
+	)
+	command := &cobra.Command{
+		Use:   "compare-shadow",
+		Short: "Compare the checksums of the tables of a shadow changefeed and its primary at their latest common syncpoint",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := defaultContext
+			shadow, err := cdcEtcdCli.GetChangeFeedInfo(ctx, changefeedID)
+			if err != nil {
+				return err
+			}
+			if shadow.ShadowOf == "" {
+				return errors.Errorf("changefeed %s is not a shadow changefeed", changefeedID)
+			}
+			if err := shadow.VerifyAndFix(); err != nil {
+				return err
+			}
+			if compareInterval <= 0 {
+				result, err := compareShadow(ctx, changefeedID, shadow, chunkSize)
+				if err != nil {
+					return err
+				}
+				if err := jsonPrint(cmd, result); err != nil {
+					return err
+				}
+				if len(result.Mismatches) != 0 {
+					return errors.Errorf("%d chunks of changefeed %s and %s are mismatched",
+						len(result.Mismatches), shadow.ShadowOf, changefeedID)
+				}
+				return nil
+			}
+
+			ticker := time.NewTicker(compareInterval)
+			defer ticker.Stop()
+			for {
+				result, err := compareShadow(ctx, changefeedID, shadow, chunkSize)
+				if err != nil {
+					// the changefeeds may not have a common syncpoint yet
+					log.Warn("compare the shadow changefeed failed", zap.String("changefeed", changefeedID), zap.Error(err))
+				} else if err := jsonPrint(cmd, result); err != nil {
+					return err
+				}
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+	command.SetOutput(os.Stdout)
+	command.PersistentFlags().StringVarP(&changefeedID, "changefeed-id", "c", "", "ID of the shadow changefeed")
+	command.PersistentFlags().DurationVar(&compareInterval, "interval", 0, "Compare periodically at the interval, 0 means compare once")
+	command.PersistentFlags().IntVar(&chunkSize, "chunk-size", verify.DefaultChunkSize, "Number of rows in a chunk")
+	_ = command.MarkPersistentFlagRequired("changefeed-id")
+	return command
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/scheduler"
+	"github.com/spf13/cobra"
+)
+
+// newSimulateScheduleCommand simulates the scheduling of the tables of a
+// changefeed offline, nothing in the cluster is changed. The tables are
+// weighted by their traffic if the sample interval is given, or each table
+// counts one otherwise.
+func newSimulateScheduleCommand() *cobra.Command {
+	var (
+		schedulerType  string
+		removeCaptures []string
+		addCaptures    int
+		rebalance      bool
+		sampleInterval time.Duration
+	)
+	command := &cobra.Command{
+		Use:   "simulate-schedule",
+		Short: "Simulate the scheduling of the tables of a replication task (changefeed) if the captures or the scheduler are changed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := defaultContext
+
+			info, err := cdcEtcdCli.GetChangeFeedInfo(ctx, changefeedID)
+			if err != nil {
+				return err
+			}
+			if schedulerType == "" && info.Config != nil && info.Config.Scheduler != nil {
+				schedulerType = info.Config.Scheduler.Tp
+			}
+			captures, err := getAllCaptures(ctx)
+			if err != nil {
+				return err
+			}
+			sim, err := loadSimulation(ctx, captures, sampleInterval)
+			if err != nil {
+				return err
+			}
+			sim.Scheduler = schedulerType
+			sim.RemoveCaptures = removeCaptures
+			for i := 1; i <= addCaptures; i++ {
+				sim.AddCaptures = append(sim.AddCaptures, fmt.Sprintf("new-capture-%d", i))
+			}
+			sim.Rebalance = rebalance
+			result, err := scheduler.Simulate(sim)
+			if err != nil {
+				return err
+			}
+			return jsonPrint(cmd, result)
+		},
+	}
+	command.PersistentFlags().StringVarP(&changefeedID, "changefeed-id", "c", "", "Replication task (changefeed) ID")
+	command.PersistentFlags().StringVar(&schedulerType, "scheduler", "", "Type of the scheduler, default to the scheduler of the changefeed")
+	command.PersistentFlags().StringSliceVar(&removeCaptures, "remove-captures", nil, "IDs of the captures removed")
+	command.PersistentFlags().IntVar(&addCaptures, "add-captures", 0, "Number of the captures added")
+	command.PersistentFlags().BoolVar(&rebalance, "rebalance", true, "Rebalance the tables after the captures are changed")
+	command.PersistentFlags().DurationVar(&sampleInterval, "interval", 0,
+		"Interval between the samples the traffic of the tables are calculated by, the tables are weighted by their traffic if it's set")
+	_ = command.MarkPersistentFlagRequired("changefeed-id")
+	return command
+}
+
+// loadSimulation loads the tables of the changefeed on the captures, the
+// draining captures are taken as removed.
+func loadSimulation(ctx context.Context, captures []*capture, sampleInterval time.Duration) (*scheduler.Simulation, error) {
+	statuses, err := cdcEtcdCli.GetAllTaskStatus(ctx, changefeedID)
+	if err != nil {
+		return nil, err
+	}
+	var traffic map[model.TableID]uint64
+	if sampleInterval > 0 {
+		traffic, err = sampleTableTraffic(ctx, captures, sampleInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sim := &scheduler.Simulation{
+		Workloads: make(map[model.CaptureID]model.TaskWorkload, len(captures)),
+		Pinned:    make(map[model.TableID]struct{}),
+	}
+	for _, c := range captures {
+		sim.Workloads[c.ID] = make(model.TaskWorkload)
+	}
+	_, raw, err := cdcEtcdCli.GetCaptures(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range raw {
+		if c.Draining {
+			sim.RemoveCaptures = append(sim.RemoveCaptures, c.ID)
+		}
+	}
+	for captureID, status := range statuses {
+		captureWorkloads, ok := sim.Workloads[captureID]
+		if !ok {
+			// the capture is gone, its tables are rescheduled by the owner
+			continue
+		}
+		for tableID, replicaInfo := range status.Tables {
+			workload := uint64(1)
+			if traffic != nil {
+				workload = traffic[tableID]
+			}
+			captureWorkloads[tableID] = model.WorkloadInfo{Workload: workload}
+			if replicaInfo != nil && replicaInfo.Pinned {
+				sim.Pinned[tableID] = struct{}{}
+			}
+		}
+	}
+	return sim, nil
+}
+
+// sampleTableTraffic returns the bytes per second of the tables of the
+// changefeed sampled in the interval.
+func sampleTableTraffic(ctx context.Context, captures []*capture, interval time.Duration) (map[model.TableID]uint64, error) {
+	prev, err := fetchChangefeedStats(ctx, captures, changefeedID)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+		return nil, errors.Trace(ctx.Err())
+	case <-time.After(interval):
+	}
+	curr, err := fetchChangefeedStats(ctx, captures, changefeedID)
+	if err != nil {
+		return nil, err
+	}
+	stats := aggregateChangefeedStats(changefeedID, prev, curr, time.Since(start), -1)
+	traffic := make(map[model.TableID]uint64, len(stats.TopTables))
+	for _, t := range stats.TopTables {
+		traffic[t.TableID] = uint64(t.BytesPerSecond)
+	}
+	return traffic, nil
+}
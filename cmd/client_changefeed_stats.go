@@ -0,0 +1,253 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/httputil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+// tableStats is the traffic of a table in a sampling interval.
+type tableStats struct {
+	TableID        model.TableID   `json:"table-id"`
+	Table          string          `json:"table"`
+	CaptureID      model.CaptureID `json:"capture-id"`
+	RowsPerSecond  float64         `json:"rows-per-second"`
+	BytesPerSecond float64         `json:"bytes-per-second"`
+}
+
+// flushLatency is the percentiles of the latencies of flushing the table sinks.
+type flushLatency struct {
+	P50 string `json:"p50"`
+	P90 string `json:"p90"`
+	P99 string `json:"p99"`
+	Max string `json:"max"`
+}
+
+// changefeedStats is the statistics of a changefeed aggregated from all the
+// processors.
+type changefeedStats struct {
+	ChangefeedID   model.ChangeFeedID `json:"changefeed-id"`
+	Processors     int                `json:"processors"`
+	Tables         int                `json:"tables"`
+	RowsPerSecond  float64            `json:"rows-per-second"`
+	BytesPerSecond float64            `json:"bytes-per-second"`
+	TopTables      []tableStats       `json:"top-tables"`
+	FlushLatency   flushLatency       `json:"flush-latency"`
+	// BarrierTs is the min barrier ts of the processors.
+	BarrierTs    uint64 `json:"barrier-ts"`
+	CheckpointTs uint64 `json:"checkpoint-ts"`
+	ResolvedTs   uint64 `json:"resolved-ts"`
+}
+
+func newStatsChangefeedCommand() *cobra.Command {
+	var (
+		sampleInterval time.Duration
+		topN           int
+	)
+	command := &cobra.Command{
+		Use:   "stats",
+		Short: "Output the statistics of a replication task (changefeed) aggregated from all the processors",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := defaultContext
+			if sampleInterval <= 0 {
+				return errors.Errorf("invalid sample interval %s", sampleInterval)
+			}
+			captures, err := getAllCaptures(ctx)
+			if err != nil {
+				return err
+			}
+			prev, err := fetchChangefeedStats(ctx, captures, changefeedID)
+			if err != nil {
+				return err
+			}
+			start := time.Now()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(sampleInterval):
+			}
+			curr, err := fetchChangefeedStats(ctx, captures, changefeedID)
+			if err != nil {
+				return err
+			}
+			if len(curr) == 0 {
+				return errors.Errorf("changefeed %s has no processor", changefeedID)
+			}
+			return jsonPrint(cmd, aggregateChangefeedStats(changefeedID, prev, curr, time.Since(start), topN))
+		},
+	}
+	command.PersistentFlags().StringVarP(&changefeedID, "changefeed-id", "c", "", "Replication task (changefeed) ID")
+	command.PersistentFlags().DurationVar(&sampleInterval, "interval", time.Second, "Interval between the samples the rates are calculated by")
+	command.PersistentFlags().IntVar(&topN, "top", 10, "Number of the tables with the most traffic to output")
+	_ = command.MarkPersistentFlagRequired("changefeed-id")
+	// accept --id as an alias of --changefeed-id
+	command.PersistentFlags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "id" {
+			name = "changefeed-id"
+		}
+		return pflag.NormalizedName(name)
+	})
+	return command
+}
+
+// fetchChangefeedStats returns the statistics of the processors of the
+// changefeed on the captures, the captures without the processor are skipped.
+func fetchChangefeedStats(
+	ctx context.Context, captures []*capture, id model.ChangeFeedID,
+) (map[model.CaptureID]*model.ProcessorStats, error) {
+	credential := getCredential()
+	cli, err := httputil.NewClient(credential)
+	if err != nil {
+		return nil, err
+	}
+	cli.SetBearerToken(getAuthToken())
+	scheme := "http"
+	if credential.IsTLSEnabled() {
+		scheme = "https"
+	}
+	ret := make(map[model.CaptureID]*model.ProcessorStats, len(captures))
+	for _, c := range captures {
+		addr := fmt.Sprintf("%s://%s%s?%s=%s", scheme, c.AdvertiseAddr, cdc.ChangefeedStatsAPI,
+			cdc.APIOpVarChangefeedID, url.QueryEscape(id))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		resp, err := cli.Do(req)
+		if err != nil {
+			return nil, errors.Annotatef(err, "fetch the statistics from capture %s", c.ID)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			log.Debug("changefeed has no processor on the capture", zap.String("capture", c.ID))
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return nil, errors.Errorf("fetch the statistics from capture %s failed: %s", c.ID, body)
+		}
+		stats := new(model.ProcessorStats)
+		if err := json.Unmarshal(body, stats); err != nil {
+			return nil, errors.Trace(err)
+		}
+		ret[c.ID] = stats
+	}
+	return ret, nil
+}
+
+// aggregateChangefeedStats calculates the rates of the changefeed by the
+// samples of the processors taken elapsed apart. The rates of a table are only
+// calculated if it's on the same capture in both samples.
+func aggregateChangefeedStats(
+	id model.ChangeFeedID, prev, curr map[model.CaptureID]*model.ProcessorStats, elapsed time.Duration, topN int,
+) *changefeedStats {
+	ret := &changefeedStats{ChangefeedID: id, Processors: len(curr)}
+	seconds := elapsed.Seconds()
+	var tables []tableStats
+	var latencies []float64
+	first := true
+	for captureID, stats := range curr {
+		ret.Tables += len(stats.Tables)
+		latencies = append(latencies, stats.FlushLatencies...)
+		if first || stats.BarrierTs < ret.BarrierTs {
+			ret.BarrierTs = stats.BarrierTs
+		}
+		if first || stats.CheckpointTs < ret.CheckpointTs {
+			ret.CheckpointTs = stats.CheckpointTs
+		}
+		if first || stats.ResolvedTs < ret.ResolvedTs {
+			ret.ResolvedTs = stats.ResolvedTs
+		}
+		first = false
+
+		prevTables := make(map[model.TableID]model.TableTraffic)
+		if prevStats, ok := prev[captureID]; ok {
+			for _, t := range prevStats.Tables {
+				prevTables[t.TableID] = t
+			}
+		}
+		for _, t := range stats.Tables {
+			p, ok := prevTables[t.TableID]
+			if !ok || t.Rows < p.Rows || t.Bytes < p.Bytes || seconds <= 0 {
+				// the table is added or re-added in between
+				continue
+			}
+			ts := tableStats{
+				TableID:        t.TableID,
+				Table:          t.Table,
+				CaptureID:      captureID,
+				RowsPerSecond:  float64(t.Rows-p.Rows) / seconds,
+				BytesPerSecond: float64(t.Bytes-p.Bytes) / seconds,
+			}
+			ret.RowsPerSecond += ts.RowsPerSecond
+			ret.BytesPerSecond += ts.BytesPerSecond
+			tables = append(tables, ts)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].BytesPerSecond != tables[j].BytesPerSecond {
+			return tables[i].BytesPerSecond > tables[j].BytesPerSecond
+		}
+		return tables[i].TableID < tables[j].TableID
+	})
+	if topN >= 0 && len(tables) > topN {
+		tables = tables[:topN]
+	}
+	ret.TopTables = tables
+	sort.Float64s(latencies)
+	ret.FlushLatency = flushLatency{
+		P50: formatLatency(percentile(latencies, 0.5)),
+		P90: formatLatency(percentile(latencies, 0.9)),
+		P99: formatLatency(percentile(latencies, 0.99)),
+		Max: formatLatency(percentile(latencies, 1)),
+	}
+	return ret
+}
+
+// percentile returns the p-th percentile of the sorted values by the nearest
+// rank, it returns 0 if there are no values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+func formatLatency(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).String()
+}
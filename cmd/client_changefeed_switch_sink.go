@@ -0,0 +1,85 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc"
+	"github.com/pingcap/ticdc/pkg/secret"
+	"github.com/spf13/cobra"
+)
+
+// newSwitchSinkCommand switches the sink of a running changefeed at a resolved
+// ts boundary. The owner stops the resolved ts of the changefeed at the switch
+// ts, and restarts the changefeed with the new sink after the old one flushes
+// the events before the switch ts and is closed.
+func newSwitchSinkCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "switch-sink",
+		Short: "Switch the sink of a running replication task (changefeed) at a resolved ts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := defaultContext
+
+			info, err := cdcEtcdCli.GetChangeFeedInfo(ctx, changefeedID)
+			if err != nil {
+				return err
+			}
+			if !info.GetState().IsRunning() {
+				return errors.Errorf("can only switch the sink of a running changefeed, state: %s", info.GetState())
+			}
+			if info.SinkSwitch != nil {
+				return errors.Errorf("the sink of changefeed %s is being switched at %d", changefeedID, info.SinkSwitch.Ts)
+			}
+			if info.SinkURI == sinkURI {
+				return errors.Errorf("changefeed %s already replicates to the sink", changefeedID)
+			}
+			if err := verifySink(ctx, sinkURI, info.Config, info.Opts); err != nil {
+				return err
+			}
+
+			err = applyOwnerSwitchSink(ctx, changefeedID, sinkURI, switchTs, getCredential())
+			if err != nil {
+				return err
+			}
+			// the switch is accepted by the owner asynchronously
+			for i := 0; i < 20; i++ {
+				time.Sleep(500 * time.Millisecond)
+				info, err := cdcEtcdCli.GetChangeFeedInfo(ctx, changefeedID)
+				if err != nil {
+					return err
+				}
+				if info.SinkSwitch != nil && info.SinkSwitch.SinkURI == sinkURI {
+					cmd.Printf("The sink of changefeed %s will be switched to %s at %d\n",
+						changefeedID, secret.RedactURI(sinkURI), info.SinkSwitch.Ts)
+					return nil
+				}
+				if info.SinkURI == sinkURI {
+					cmd.Printf("The sink of changefeed %s is switched to %s\n", changefeedID, secret.RedactURI(sinkURI))
+					return nil
+				}
+			}
+			return errors.Errorf("the switch is not accepted yet, it may be rejected, "+
+				"check the owner log or the decisions of the owner by %s", cdc.OwnerDecisionsAPI)
+		},
+	}
+	command.PersistentFlags().StringVarP(&changefeedID, "changefeed-id", "c", "", "Replication task (changefeed) ID")
+	command.PersistentFlags().StringVar(&sinkURI, "sink-uri", "", "The sink uri to switch to")
+	command.PersistentFlags().Uint64Var(&switchTs, "switch-ts", 0,
+		"The ts to switch the sink at, it must be not less than the resolved ts of the changefeed, 0 means the current resolved ts")
+	_ = command.MarkPersistentFlagRequired("changefeed-id")
+	_ = command.MarkPersistentFlagRequired("sink-uri")
+	return command
+}
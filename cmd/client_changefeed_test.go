@@ -17,8 +17,11 @@ import (
 	"context"
 	"io/ioutil"
 	"path/filepath"
+	"time"
 
 	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/config"
 	"github.com/pingcap/ticdc/pkg/util/testleak"
 	"github.com/spf13/cobra"
 )
@@ -51,3 +54,92 @@ enable-old-value = false
 	_, err = verifyChangefeedParamers(ctx, cmd, true /* isCreate */, nil)
 	c.Assert(err, check.NotNil)
 }
+
+func (s *clientChangefeedSuite) TestAggregateChangefeedStats(c *check.C) {
+	defer testleak.AfterTest(c)()
+	prev := map[model.CaptureID]*model.ProcessorStats{
+		"capture-1": {Tables: []model.TableTraffic{
+			{TableID: 1, Table: "test.t1", Rows: 100, Bytes: 1000},
+			{TableID: 2, Table: "test.t2", Rows: 100, Bytes: 1000},
+		}},
+		"capture-2": {Tables: []model.TableTraffic{
+			{TableID: 3, Table: "test.t3", Rows: 100, Bytes: 1000},
+		}},
+	}
+	curr := map[model.CaptureID]*model.ProcessorStats{
+		"capture-1": {
+			Tables: []model.TableTraffic{
+				{TableID: 1, Table: "test.t1", Rows: 300, Bytes: 5000},
+				{TableID: 2, Table: "test.t2", Rows: 120, Bytes: 1200},
+			},
+			FlushLatencies: []float64{0.1, 0.2, 0.3},
+			BarrierTs:      20,
+			CheckpointTs:   10,
+			ResolvedTs:     15,
+		},
+		"capture-2": {
+			Tables: []model.TableTraffic{
+				// the table is re-added to the capture
+				{TableID: 3, Table: "test.t3", Rows: 10, Bytes: 100},
+				// the table is moved to the capture
+				{TableID: 4, Table: "test.t4", Rows: 10, Bytes: 100},
+			},
+			FlushLatencies: []float64{0.4},
+			BarrierTs:      18,
+			CheckpointTs:   12,
+			ResolvedTs:     13,
+		},
+	}
+	stats := aggregateChangefeedStats("test", prev, curr, 2*time.Second, 1)
+	c.Assert(stats.Processors, check.Equals, 2)
+	c.Assert(stats.Tables, check.Equals, 4)
+	c.Assert(stats.RowsPerSecond, check.Equals, float64(110))
+	c.Assert(stats.BytesPerSecond, check.Equals, float64(2100))
+	c.Assert(stats.TopTables, check.DeepEquals, []tableStats{{
+		TableID:        1,
+		Table:          "test.t1",
+		CaptureID:      "capture-1",
+		RowsPerSecond:  100,
+		BytesPerSecond: 2000,
+	}})
+	c.Assert(stats.FlushLatency, check.DeepEquals, flushLatency{
+		P50: "200ms", P90: "400ms", P99: "400ms", Max: "400ms",
+	})
+	c.Assert(stats.BarrierTs, check.Equals, uint64(18))
+	c.Assert(stats.CheckpointTs, check.Equals, uint64(10))
+	c.Assert(stats.ResolvedTs, check.Equals, uint64(13))
+}
+
+func (s *clientChangefeedSuite) TestMergeTableStartTs(c *check.C) {
+	defer testleak.AfterTest(c)()
+	newInfo := func(rules ...string) *model.ChangeFeedInfo {
+		cfg := config.GetDefaultReplicaConfig()
+		cfg.Filter.Rules = rules
+		return &model.ChangeFeedInfo{Config: cfg}
+	}
+	old := newInfo("test.t1", "test.t2")
+	old.TableStartTs = map[string]uint64{"test.t2": 100, "test.t3": 300}
+	info := newInfo("test.*")
+
+	merged, err := mergeTableStartTs(old, info, []string{"test.t4=250", "test.t5=now"}, 200, 400)
+	c.Assert(err, check.IsNil)
+	// the start ts reached by the checkpoint is dropped
+	c.Assert(merged, check.DeepEquals, map[string]uint64{"test.t3": 300, "test.t4": 250, "test.t5": 400})
+
+	merged, err = mergeTableStartTs(old, info, nil, 300, 400)
+	c.Assert(err, check.IsNil)
+	c.Assert(merged, check.IsNil)
+
+	for _, specs := range [][]string{
+		{"test.t4"},
+		{"t4=250"},
+		{"test.t4=abc"},
+		{"test.t1=250"},  // replicated by the old filter
+		{"other.t1=250"}, // ignored by the new filter
+		{"test.t4=150"},  // before the checkpoint
+		{"test.t4=500"},  // after the current ts
+	} {
+		_, err = mergeTableStartTs(old, info, specs, 200, 400)
+		c.Assert(err, check.NotNil, check.Commentf("%v", specs))
+	}
+}
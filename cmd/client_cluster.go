@@ -0,0 +1,218 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type maintenanceResult struct {
+	StartTime time.Time            `json:"start-time"`
+	Paused    []model.ChangeFeedID `json:"paused,omitempty"`
+	Resumed   []model.ChangeFeedID `json:"resumed,omitempty"`
+	// Untouched are the changefeeds not running when the maintenance mode is
+	// entered, created or removed during the maintenance, with their states.
+	Untouched map[model.ChangeFeedID]model.FeedState `json:"untouched,omitempty"`
+}
+
+func newClusterCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "cluster",
+		Short: "Manage the maintenance mode and the quota of TiCDC cluster",
+	}
+	command.AddCommand(
+		newPauseAllCommand(),
+		newResumeAllCommand(),
+		newQuotaCommand(),
+	)
+	return command
+}
+
+func newPauseAllCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pause-all",
+		Short: "Enter the maintenance mode by pausing all the running replication tasks (changefeeds)",
+		Long: `Enter the maintenance mode by pausing all the running replication tasks (changefeeds).
+The paused changefeeds are recorded and only they are resumed by resume-all.
+It's safe to run pause-all again if it's interrupted, the recorded changefeeds are paused again.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := defaultContext
+			running, untouched, err := classifyChangefeeds(ctx)
+			if err != nil {
+				return err
+			}
+			info := &model.MaintenanceInfo{
+				StartTime:         time.Now(),
+				PausedChangefeeds: running,
+			}
+			err = cdcEtcdCli.CreateMaintenanceInfo(ctx, info)
+			if cerror.ErrClusterInMaintenance.Equal(err) {
+				// continue the interrupted pause-all by the recorded changefeeds
+				info, err = cdcEtcdCli.GetMaintenanceInfo(ctx)
+				if err != nil {
+					return err
+				}
+				log.Info("the cluster is already in maintenance mode, pause the recorded changefeeds again",
+					zap.Time("startTime", info.StartTime))
+				recorded := make(map[model.ChangeFeedID]struct{}, len(info.PausedChangefeeds))
+				for _, id := range info.PausedChangefeeds {
+					recorded[id] = struct{}{}
+					delete(untouched, id)
+				}
+				// the changefeeds created during the maintenance are left running
+				for _, id := range running {
+					if _, ok := recorded[id]; !ok {
+						untouched[id] = model.StateNormal
+					}
+				}
+			} else if err != nil {
+				return err
+			}
+			for _, id := range info.PausedChangefeeds {
+				job := model.AdminJob{
+					CfID: id,
+					Type: model.AdminStop,
+				}
+				if err := applyAdminChangefeed(ctx, job, getCredential()); err != nil {
+					return errors.Annotatef(err, "pause changefeed %s failed, run pause-all again to retry", id)
+				}
+			}
+			return jsonPrint(cmd, &maintenanceResult{
+				StartTime: info.StartTime,
+				Paused:    info.PausedChangefeeds,
+				Untouched: untouched,
+			})
+		},
+	}
+}
+
+func newResumeAllCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "resume-all",
+		Short: "Leave the maintenance mode by resuming the replication tasks (changefeeds) paused by pause-all",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := defaultContext
+			info, err := cdcEtcdCli.GetMaintenanceInfo(ctx)
+			if err != nil {
+				return err
+			}
+			result := &maintenanceResult{
+				StartTime: info.StartTime,
+				Untouched: make(map[model.ChangeFeedID]model.FeedState),
+			}
+			for _, id := range info.PausedChangefeeds {
+				cfInfo, err := cdcEtcdCli.GetChangeFeedInfo(ctx, id)
+				if cerror.ErrChangeFeedNotExists.Equal(err) {
+					result.Untouched[id] = model.StateRemoved
+					continue
+				} else if err != nil {
+					return err
+				}
+				if cfInfo.State == model.StateRemoved || cfInfo.State == model.StateFinished {
+					result.Untouched[id] = cfInfo.State
+					continue
+				}
+				job := model.AdminJob{
+					CfID: id,
+					Type: model.AdminResume,
+				}
+				if err := applyAdminChangefeed(ctx, job, getCredential()); err != nil {
+					return errors.Annotatef(err, "resume changefeed %s failed, run resume-all again to retry", id)
+				}
+				result.Resumed = append(result.Resumed, id)
+			}
+			// the record is kept until all the changefeeds are resumed, so
+			// resume-all can be retried
+			if err := cdcEtcdCli.DeleteMaintenanceInfo(ctx); err != nil {
+				return err
+			}
+			return jsonPrint(cmd, result)
+		},
+	}
+}
+
+func newQuotaCommand() *cobra.Command {
+	var maxChangefeeds int
+	command := &cobra.Command{
+		Use:   "quota",
+		Short: "Query or update the quota of TiCDC cluster",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := defaultContext
+			quota, err := cdcEtcdCli.GetClusterQuota(ctx)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("max-changefeeds") {
+				if maxChangefeeds < 0 {
+					return errors.Errorf("invalid max changefeeds %d", maxChangefeeds)
+				}
+				quota.MaxChangefeeds = maxChangefeeds
+				err = cdcEtcdCli.PutClusterQuota(ctx, quota)
+				auditCLI("update quota", "cluster", map[string]string{"max-changefeeds": strconv.Itoa(maxChangefeeds)}, err)
+				if err != nil {
+					return err
+				}
+			}
+			return jsonPrint(cmd, quota)
+		},
+	}
+	command.PersistentFlags().IntVar(&maxChangefeeds, "max-changefeeds", 0, "Max number of changefeeds in the cluster, 0 means no limit")
+	return command
+}
+
+// classifyChangefeeds returns the IDs of the running changefeeds in order, and
+// the states of the others.
+func classifyChangefeeds(
+	ctx context.Context,
+) ([]model.ChangeFeedID, map[model.ChangeFeedID]model.FeedState, error) {
+	_, raw, err := cdcEtcdCli.GetChangeFeeds(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var running []model.ChangeFeedID
+	others := make(map[model.ChangeFeedID]model.FeedState)
+	for id, kv := range raw {
+		info := &model.ChangeFeedInfo{}
+		if err := info.Unmarshal(kv.Value); err != nil {
+			return nil, nil, err
+		}
+		if isChangefeedRunning(info) {
+			running = append(running, id)
+		} else {
+			others[id] = info.State
+		}
+	}
+	sort.Strings(running)
+	return running, others, nil
+}
+
+// isChangefeedRunning returns whether the changefeed is neither paused nor
+// stopped by errors, the state of the changefeeds created by the old versions
+// may be empty.
+func isChangefeedRunning(info *model.ChangeFeedInfo) bool {
+	if info.AdminJobType == model.AdminStop || info.AdminJobType == model.AdminRemove {
+		return false
+	}
+	return info.GetState().IsRunning()
+}
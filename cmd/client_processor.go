@@ -14,9 +14,15 @@
 package cmd
 
 import (
+	"context"
+
 	_ "github.com/go-sql-driver/mysql" // mysql driver
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc"
+	"github.com/pingcap/ticdc/cdc/model"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 func newProcessorCommand() *cobra.Command {
@@ -37,13 +43,21 @@ func newListProcessorCommand() *cobra.Command {
 		Short: "List all processors in TiCDC cluster",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := defaultContext
+			opts, err := parseListOptions(cdc.ProcessorSortKeys...)
+			if err != nil {
+				return err
+			}
 			info, err := cdcEtcdCli.GetProcessors(ctx)
 			if err != nil {
 				return err
 			}
-			return jsonPrint(cmd, info)
+			info = cdc.FilterProcessors(info, opts)
+			start, end := opts.Page(len(info))
+			printListTotal(cmd, opts, end-start, len(info))
+			return jsonPrint(cmd, info[start:end])
 		},
 	}
+	addListFlags(command.PersistentFlags(), false /* withState */, cdc.ProcessorSortKeys...)
 	return command
 }
 
@@ -62,6 +76,10 @@ func newQueryProcessorCommand() *cobra.Command {
 				return err
 			}
 			meta := &processorMeta{Status: status, Position: position}
+			meta.Stats, err = fetchProcessorStats(ctx, changefeedID, captureID)
+			if err != nil {
+				log.Warn("failed to fetch the statistics of the processor", zap.Error(err))
+			}
 			return jsonPrint(cmd, meta)
 		},
 	}
@@ -71,3 +89,24 @@ func newQueryProcessorCommand() *cobra.Command {
 	_ = command.MarkPersistentFlagRequired("capture-id")
 	return command
 }
+
+// fetchProcessorStats returns the statistics of the processor served by its
+// capture, including the pipeline progress of the tables and the last sink
+// error, nil is returned if the processor or the capture is not running.
+func fetchProcessorStats(
+	ctx context.Context, id model.ChangeFeedID, captureID model.CaptureID,
+) (*model.ProcessorStats, error) {
+	info, err := cdcEtcdCli.GetCaptureInfo(ctx, captureID)
+	if err != nil {
+		if cerror.ErrCaptureNotExist.Equal(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	c := &capture{ID: info.ID, AdvertiseAddr: info.AdvertiseAddr}
+	stats, err := fetchChangefeedStats(ctx, []*capture{c}, id)
+	if err != nil {
+		return nil, err
+	}
+	return stats[captureID], nil
+}
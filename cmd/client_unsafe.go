@@ -52,6 +52,7 @@ func newResetCommand() *cobra.Command {
 			}
 
 			err = cdcEtcdCli.ClearAllCDCInfo(ctx)
+			auditCLI("reset cluster", "", nil, err)
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -104,6 +105,7 @@ func newDeleteServiceGcSafepointCommand() *cobra.Command {
 			}
 			ctx := defaultContext
 			_, err := pdCli.UpdateServiceGCSafePoint(ctx, cdc.CDCServiceSafePointID, 0, 0)
+			auditCLI("delete service gc safepoint", cdc.CDCServiceSafePointID, nil, err)
 			if err == nil {
 				cmd.Println("CDC service GC safepoint truncated in PD!")
 			}
@@ -0,0 +1,106 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/secret"
+	"github.com/pingcap/ticdc/pkg/verify"
+	"github.com/spf13/cobra"
+)
+
+type verifyResult struct {
+	Syncpoint  *verify.Syncpoint  `json:"syncpoint"`
+	Mismatches []*verify.Mismatch `json:"mismatches"`
+}
+
+func newVerifyCommand() *cobra.Command {
+	var (
+		upstreamURI string
+		chunkSize   int
+	)
+	command := &cobra.Command{
+		Use:   "verify",
+		Short: "Compare the checksums of the upstream and downstream tables of a changefeed at the latest syncpoint",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := defaultContext
+			info, err := cdcEtcdCli.GetChangeFeedInfo(ctx, changefeedID)
+			if err != nil {
+				return err
+			}
+			if !info.SyncPointEnabled {
+				return errors.Errorf("the syncpoint of changefeed %s is not enabled", changefeedID)
+			}
+			f, err := filter.NewFilter(info.Config)
+			if err != nil {
+				return err
+			}
+			sinkURI, err := secret.ResolveURI(ctx, info.SinkURI)
+			if err != nil {
+				return err
+			}
+			downstream, err := verify.OpenDB(ctx, sinkURI)
+			if err != nil {
+				return errors.Annotate(err, "fail to connect the downstream")
+			}
+			defer downstream.Close()
+			upstream, err := verify.OpenDB(ctx, upstreamURI)
+			if err != nil {
+				return errors.Annotate(err, "fail to connect the upstream")
+			}
+			defer upstream.Close()
+
+			sp, err := verify.LatestSyncpoint(ctx, downstream, changefeedID)
+			if err != nil {
+				return err
+			}
+			// the snapshots are set in the sessions of the connections
+			upConn, err := upstream.Conn(ctx)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			defer upConn.Close()
+			downConn, err := downstream.Conn(ctx)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			defer downConn.Close()
+			checker, err := verify.NewChecker(ctx, upConn, downConn, f, chunkSize, sp)
+			if err != nil {
+				return err
+			}
+			mismatches, err := checker.Run(ctx)
+			if err != nil {
+				return err
+			}
+			if err := jsonPrint(cmd, &verifyResult{Syncpoint: sp, Mismatches: mismatches}); err != nil {
+				return err
+			}
+			if len(mismatches) != 0 {
+				return errors.Errorf("%d chunks of changefeed %s are mismatched", len(mismatches), changefeedID)
+			}
+			return nil
+		},
+	}
+	command.SetOutput(os.Stdout)
+	command.PersistentFlags().StringVarP(&changefeedID, "changefeed-id", "c", "", "Replication task (changefeed) ID")
+	command.PersistentFlags().StringVar(&upstreamURI, "upstream-uri", "", "URI of the upstream TiDB, such as mysql://root@127.0.0.1:4000/")
+	command.PersistentFlags().IntVar(&chunkSize, "chunk-size", verify.DefaultChunkSize, "Number of rows in a chunk")
+	_ = command.MarkPersistentFlagRequired("changefeed-id")
+	_ = command.MarkPersistentFlagRequired("upstream-uri")
+	return command
+}
@@ -63,6 +63,14 @@ sync-ddl = true
 [scheduler]
 type = "manual"
 polling-time = 5
+
+[rate-limit]
+rows-per-second = 10000
+bytes-per-second = 1048576
+
+[worker-pool]
+isolated = true
+sorter-worker-num = 4
 `
 	err := ioutil.WriteFile(path, []byte(content), 0o644)
 	c.Assert(err, check.IsNil)
@@ -98,6 +106,14 @@ polling-time = 5
 		Tp:          "manual",
 		PollingTime: 5,
 	})
+	c.Assert(cfg.RateLimit, check.DeepEquals, &config.RateLimitConfig{
+		RowsPerSecond:  10000,
+		BytesPerSecond: 1048576,
+	})
+	c.Assert(cfg.WorkerPool, check.DeepEquals, &config.WorkerPoolConfig{
+		Isolated:        true,
+		SorterWorkerNum: 4,
+	})
 }
 
 func (s *decodeFileSuite) TestAndWriteExampleTOML(c *check.C) {
@@ -141,6 +157,17 @@ dispatchers = [
 # Currently the protocol support default, canal, avro and maxwell. Default is ticdc-open-protocol
 protocol = "default"
 
+# 下游阻塞时，将每张表待写入的行溢出到磁盘的队列中
+# Spill the pending rows of each table to a queue on disk when the downstream stalls
+[sink.spill]
+enable = false
+# 每张表在内存中保留的最大行数
+# The max number of pending rows kept in memory per table
+memory-rows-limit = 100000
+# 每张表溢出到磁盘的最大字节数
+# The max bytes spilled to disk per table
+max-disk-size = 1073741824
+
 [cyclic-replication]
 # 是否开启环形复制
 # Whether to enable cyclic replication
@@ -154,6 +181,37 @@ filter-replica-ids = [2,3]
 # 是否同步 DDL
 # Whether to replicate DDL
 sync-ddl = true
+
+[rate-limit]
+# 该 changefeed 每秒最多同步的行数和字节数，由同步该 changefeed 的各个 capture 平分，0 表示不限制
+# The maximum rows and bytes per second replicated by the changefeed, which are shared evenly by the captures
+# replicating the changefeed, 0 means unlimited
+rows-per-second = 0
+bytes-per-second = 0
+
+[worker-pool]
+# 是否为该 changefeed 创建独立的 sorter 线程池，mounter 和 sink 的线程总是属于该 changefeed
+# Whether the changefeed has its own sorter worker pools, the mounter and sink workers always belong to the changefeed
+isolated = false
+# 独立的 sorter 线程池的线程数，0 表示与共享的线程池相同
+# The number of the Goroutines of the isolated sorter worker pools, 0 means the same as the shared ones
+sorter-worker-num = 0
+
+[table-skew]
+# 表的 checkpoint 落后于同一 processor 上推进最快的表的最大分钟数，超过时报告警告，0 表示不检查
+# The max minutes the checkpoint of a table lags behind the most advanced table of the processor before it's warned, 0 means disabled
+max-lag = 0
+# 表落后过多时是否以错误停止该 changefeed
+# Whether to stop the changefeed with an error if a table lags behind too much
+pause = false
+
+[flow-control]
+# 表的 puller 领先于该表 checkpoint 的最大分钟数，按 commit ts 的物理时间计算，0 表示不限制
+# The max minutes the puller of a table runs ahead of the checkpoint of the table, in the physical time of the commit ts, 0 means unlimited
+window = 10
+# 每个 capture 上该 changefeed 已拉取但未被 sink 写入的事件的最大字节数，0 表示不限制
+# The max bytes of the events pulled but not flushed by the sink of the changefeed on each capture, 0 means unlimited
+memory-quota = 10737418240
 `
 	err := ioutil.WriteFile("changefeed.toml", []byte(content), 0o644)
 	c.Assert(err, check.IsNil)
@@ -176,6 +234,11 @@ sync-ddl = true
 			{Dispatcher: "rowid", Matcher: []string{"test3.*", "test4.*"}},
 		},
 		Protocol: "default",
+		Spill: &config.SpillConfig{
+			Enable:          false,
+			MemoryRowsLimit: 100000,
+			MaxDiskSize:     1073741824,
+		},
 	})
 	c.Assert(cfg.Cyclic, check.DeepEquals, &config.CyclicConfig{
 		Enable:          false,
@@ -183,6 +246,13 @@ sync-ddl = true
 		FilterReplicaID: []uint64{2, 3},
 		SyncDDL:         true,
 	})
+	c.Assert(cfg.RateLimit, check.DeepEquals, &config.RateLimitConfig{})
+	c.Assert(cfg.WorkerPool, check.DeepEquals, &config.WorkerPoolConfig{})
+	c.Assert(cfg.TableSkew, check.DeepEquals, &config.TableSkewConfig{})
+	c.Assert(cfg.FlowControl, check.DeepEquals, &config.FlowControlConfig{
+		Window:      10,
+		MemoryQuota: 10737418240,
+	})
 }
 
 func (s *decodeFileSuite) TestShouldReturnErrForUnknownCfgs(c *check.C) {
@@ -0,0 +1,99 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/sink/cdclog"
+	"github.com/pingcap/ticdc/pkg/logutil"
+	"github.com/pingcap/ticdc/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	replayUpstreamURI   string
+	replayDownstreamURI string
+	replayStartTs       uint64
+	replayEndTs         uint64
+	replayTimezone      string
+	replayLogFile       string
+	replayLogLevel      string
+
+	replayCmd = &cobra.Command{
+		Use:   "replay",
+		Short: "Replay the events of a storage sink output between two timestamps to a new downstream",
+		Long: "Replay the events whose commit ts is in (start-ts, end-ts] from the output of a storage sink " +
+			"(s3, gcs, azure blob or local file) to a new downstream, such as Kafka or MySQL, " +
+			"without touching the upstream cluster.",
+		RunE: runEReplay,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().StringVar(&replayUpstreamURI, "upstream-uri", "", "The sink uri of the storage sink, e.g. s3://bucket/prefix")
+	replayCmd.Flags().StringVar(&replayDownstreamURI, "downstream-uri", "", "The sink uri of the downstream, e.g. kafka://127.0.0.1:9092/topic")
+	replayCmd.Flags().Uint64Var(&replayStartTs, "start-ts", 0, "Replay the events whose commit ts is larger than start-ts")
+	replayCmd.Flags().Uint64Var(&replayEndTs, "end-ts", 0, "Replay the events whose commit ts is less than or equal to end-ts")
+	replayCmd.Flags().StringVar(&replayTimezone, "tz", "System", "Specify time zone of the replay")
+	replayCmd.Flags().StringVar(&replayLogFile, "log-file", "", "log file path")
+	replayCmd.Flags().StringVar(&replayLogLevel, "log-level", "info", "log level (etc: debug|info|warn|error)")
+	_ = replayCmd.MarkFlagRequired("upstream-uri")
+	_ = replayCmd.MarkFlagRequired("downstream-uri")
+	_ = replayCmd.MarkFlagRequired("end-ts")
+}
+
+func runEReplay(cmd *cobra.Command, args []string) error {
+	cancel := initCmd(cmd, &logutil.Config{
+		File:  replayLogFile,
+		Level: replayLogLevel,
+	})
+	defer cancel()
+	tz, err := util.GetTimezone(replayTimezone)
+	if err != nil {
+		return errors.Annotate(err, "can not load timezone")
+	}
+	ctx, cancel := context.WithCancel(util.PutTimezoneInCtx(defaultContext, tz))
+	defer cancel()
+
+	upstreamURI, err := url.Parse(replayUpstreamURI)
+	if err != nil {
+		return errors.Annotate(err, "invalid upstream uri")
+	}
+	downstream, err := newConsumerDownstream(ctx, cancel, "replay", replayDownstreamURI)
+	if err != nil {
+		return err
+	}
+	defer downstream.Close()
+
+	// the manifests are read once, the poll interval is not used
+	consumer, err := cdclog.NewConsumer(ctx, upstreamURI, downstream, replayStartTs, 0)
+	if err != nil {
+		return errors.Annotate(err, "create storage consumer")
+	}
+	err = consumer.Replay(ctx, replayEndTs)
+	log.Info("replay exited", zap.Uint64("start ts", replayStartTs), zap.Uint64("end ts", replayEndTs),
+		zap.Uint64("applied ts", consumer.AppliedTs()))
+	if err != nil {
+		return errors.Annotate(err, "replay")
+	}
+	cmd.Printf("Replay the events in (%d, %d] successfully\n", replayStartTs, replayEndTs)
+	return nil
+}
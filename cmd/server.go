@@ -15,6 +15,9 @@ package cmd
 
 import (
 	"context"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/pingcap/ticdc/cdc/puller/sorter"
@@ -22,8 +25,13 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/audit"
+	"github.com/pingcap/ticdc/pkg/auth"
 	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/diskmanager"
 	"github.com/pingcap/ticdc/pkg/logutil"
+	"github.com/pingcap/ticdc/pkg/security"
 	"github.com/pingcap/ticdc/pkg/util"
 	"github.com/pingcap/ticdc/pkg/version"
 	ticonfig "github.com/pingcap/tidb/config"
@@ -39,15 +47,44 @@ var (
 	gcTTL         int64
 	logFile       string
 	logLevel      string
+	// serverConfigFile is the file of the reloadable server config
+	serverConfigFile string
+	// variables for the authentication of the HTTP APIs
+	authTokenFile string
+	authCertRoles string
 	// variables for unified sorter
 	numConcurrentWorker    int
 	chunkSizeLimit         uint64
 	maxMemoryPressure      int
 	maxMemoryConsumption   uint64
 	numWorkerPoolGoroutine int
+	sorterEnableResume     bool
+	// variables for the disk manager
+	dataDir            string
+	dataDirQuota       int64
+	sorterDiskQuota    int64
+	sinkSpillDiskQuota int64
+	dataDirMinFree     int64
+	dataDirFallback    bool
 
 	ownerFlushInterval     time.Duration
 	processorFlushInterval time.Duration
+	// gracefulShutdownTimeout is the max duration to drain the capture on SIGTERM
+	gracefulShutdownTimeout time.Duration
+	// variables for exporting the checkpoints of the changefeeds
+	checkpointExportURI      string
+	checkpointExportInterval time.Duration
+	// variables for the grpc connections of the kv client
+	kvClientGRPCCompression    string
+	kvClientGRPCWindowSize     int32
+	kvClientGRPCConnWindowSize int32
+	kvClientZone               string
+	kvClientZoneLabel          string
+	kvClientDedupMaxKeys       int
+	// ignoreIncompatibleVersions only warns the incompatible upstream versions
+	ignoreIncompatibleVersions bool
+	// compressTaskStatus compresses the large task statuses in etcd
+	compressTaskStatus bool
 
 	serverCmd = &cobra.Command{
 		Use:   "server",
@@ -77,6 +114,12 @@ func init() {
 	serverCmd.Flags().DurationVar(&ownerFlushInterval, "owner-flush-interval", time.Millisecond*200, "owner flushes changefeed status interval")
 	serverCmd.Flags().DurationVar(&processorFlushInterval, "processor-flush-interval", time.Millisecond*100, "processor flushes task status interval")
 
+	serverCmd.Flags().DurationVar(&gracefulShutdownTimeout, "graceful-shutdown-timeout", 20*time.Second,
+		"max duration to move the tables to the other captures on SIGTERM before exiting, 0 means exiting at once. "+
+			"It should be shorter than the terminationGracePeriodSeconds of the pod on Kubernetes")
+	serverCmd.Flags().StringVar(&serverConfigFile, "config", "", "Path of the server config file, "+
+		"the settings in it override the flags and are reloaded on SIGHUP")
+
 	serverCmd.Flags().IntVar(&numWorkerPoolGoroutine, "sorter-num-workerpool-goroutine", 16, "sorter workerpool size")
 	serverCmd.Flags().IntVar(&numConcurrentWorker, "sorter-num-concurrent-worker", 4, "sorter concurrency level")
 	serverCmd.Flags().Uint64Var(&chunkSizeLimit, "sorter-chunk-size-limit", 1024*1024*1024, "size of heaps for sorting")
@@ -84,8 +127,50 @@ func init() {
 	serverCmd.Flags().IntVar(&maxMemoryPressure, "sorter-max-memory-percentage", 80, "system memory usage threshold for forcing in-disk sort")
 	// We use 8GB as a safe default before we support local configuration file.
 	serverCmd.Flags().Uint64Var(&maxMemoryConsumption, "sorter-max-memory-consumption", 8*1024*1024*1024, "maximum memory consumption of in-memory sort")
+	serverCmd.Flags().BoolVar(&sorterEnableResume, "sorter-enable-resume", false, "keep the sorted events of the tables in the data dir, "+
+		"so the tables are resumed from them instead of pulled again from the checkpoint after the capture restarts. It requires --data-dir")
+
+	serverCmd.Flags().StringVar(&dataDir, "data-dir", "", "Directory of the sorter and sink spill files of the capture, "+
+		"the files are stored in the sort-dir of the changefeeds if it's empty. It must not be shared by multiple captures")
+	serverCmd.Flags().Int64Var(&dataDirQuota, "data-dir-quota", 0, "max bytes used in the data dir, 0 means no limit")
+	serverCmd.Flags().Int64Var(&sorterDiskQuota, "sorter-disk-quota", 0, "max bytes used by the sorter in the data dir, 0 means no limit")
+	serverCmd.Flags().Int64Var(&sinkSpillDiskQuota, "sink-spill-disk-quota", 0, "max bytes used by the sink spill queues in the data dir, 0 means no limit")
+	serverCmd.Flags().Int64Var(&dataDirMinFree, "data-dir-min-free-bytes", diskmanager.DefaultMinFreeBytes,
+		"min free space of the data dir checked at startup, 0 means no check")
+	serverCmd.Flags().BoolVar(&dataDirFallback, "data-dir-memory-fallback", false,
+		"use the memory sorter instead of refusing to start if the data dir fails the startup checks")
+
+	serverCmd.Flags().StringVar(&checkpointExportURI, "checkpoint-export-uri", "", "URI of a TiDB, such as mysql://root@127.0.0.1:4000/, "+
+		"the owner writes the checkpoint ts and the resolved ts of the changefeeds into the table tidb_cdc.changefeed_checkpoint of it")
+	serverCmd.Flags().DurationVar(&checkpointExportInterval, "checkpoint-export-interval", 10*time.Second,
+		"interval of exporting the checkpoints of the changefeeds")
+
+	serverCmd.Flags().StringVar(&kvClientGRPCCompression, "kv-client-grpc-compression", config.GRPCCompressionNone,
+		"compression algorithm of the event streams from TiKV (none|gzip|snappy|zstd), TiKV compresses the events only if it supports the algorithm")
+	serverCmd.Flags().Int32Var(&kvClientGRPCWindowSize, "kv-client-grpc-window-size", 1<<30,
+		"initial window size of a grpc stream from TiKV, the smaller it is the less memory is used by the slow streams")
+	serverCmd.Flags().Int32Var(&kvClientGRPCConnWindowSize, "kv-client-grpc-conn-window-size", 1<<30,
+		"initial window size of a grpc connection to TiKV")
+	serverCmd.Flags().StringVar(&kvClientZone, "kv-client-zone", "", "Zone of the capture, the events of the regions "+
+		"whose leaders are in the other zones are pulled from the followers in the zone if TiKV supports it, empty means always pulling from the leaders")
+	serverCmd.Flags().StringVar(&kvClientZoneLabel, "kv-client-zone-label", "zone", "key of the TiKV store label of the zones")
+	serverCmd.Flags().IntVar(&kvClientDedupMaxKeys, "kv-client-dedup-max-keys", 16*1024,
+		"max number of the keys recorded per table to drop the events delivered again after the regions reconnect, 0 means no deduplication")
+
+	serverCmd.Flags().StringVar(&authTokenFile, "auth-token-file", "", "File of the tokens to call the HTTP APIs, "+
+		"each line is a role (viewer|admin) followed by a token, and optionally the namespace the token is scoped to, "+
+		"the captures report the runtime states to the owner with the token of the role internal")
+	serverCmd.Flags().StringVar(&authCertRoles, "auth-cert-roles", "", "Roles of the callers identified by "+
+		"the cert Common Name, e.g. `dashboard:viewer,ctl:admin,team-a-ctl:admin@team-a`")
+
+	serverCmd.Flags().BoolVar(&ignoreIncompatibleVersions, "ignore-incompatible-versions", false,
+		"Only warn instead of refusing the upstream TiKV, PD and TiDB versions not supported by TiCDC")
+	serverCmd.Flags().BoolVar(&compressTaskStatus, "compress-task-status", false,
+		"Compress the task statuses larger than 256KB in etcd. The older captures and cli can't read them, "+
+			"so enable it only after all the captures and the cli are upgraded, and disable it before downgrading any of them")
 
 	addSecurityFlags(serverCmd.Flags(), true /* isServer */)
+	addAuditFlags(serverCmd.Flags())
 }
 
 func runEServer(cmd *cobra.Command, args []string) error {
@@ -105,7 +190,40 @@ func runEServer(cmd *cobra.Command, args []string) error {
 		MaxMemoryPressure:      maxMemoryPressure,
 		MaxMemoryConsumption:   maxMemoryConsumption,
 		NumWorkerPoolGoroutine: numWorkerPoolGoroutine,
+		EnableResume:           sorterEnableResume,
 	})
+	kvClientCfg := &config.KVClientConfig{
+		GRPCCompression:    kvClientGRPCCompression,
+		GRPCWindowSize:     kvClientGRPCWindowSize,
+		GRPCConnWindowSize: kvClientGRPCConnWindowSize,
+		Zone:               kvClientZone,
+		ZoneLabel:          kvClientZoneLabel,
+		DedupMaxKeys:       kvClientDedupMaxKeys,
+	}
+	if err := kvClientCfg.Validate(); err != nil {
+		return errors.Annotate(err, "invalid kv client config")
+	}
+	config.SetKVClientConfig(kvClientCfg)
+	model.EnableTaskStatusCompression(compressTaskStatus)
+
+	authCfg := &auth.Config{}
+	if authTokenFile != "" {
+		if err := authCfg.LoadTokenFile(authTokenFile); err != nil {
+			return errors.Annotate(err, "load auth token file")
+		}
+	}
+	if err := authCfg.ParseCertRoles(authCertRoles); err != nil {
+		return errors.Annotate(err, "parse auth cert roles")
+	}
+
+	// the TLS options apply to the connections to the sinks as well
+	if err := security.SetDefaultTLSOptions(getCredential().TLSOptions); err != nil {
+		return errors.Annotate(err, "invalid tls options")
+	}
+	if err := audit.Init(auditLog); err != nil {
+		return errors.Annotate(err, "open audit log")
+	}
+	defer audit.Close()
 
 	version.LogVersionInfo()
 	opts := []cdc.ServerOption{
@@ -117,11 +235,35 @@ func runEServer(cmd *cobra.Command, args []string) error {
 		cdc.Credential(getCredential()),
 		cdc.OwnerFlushInterval(ownerFlushInterval),
 		cdc.ProcessorFlushInterval(processorFlushInterval),
+		cdc.Auth(authCfg),
+		cdc.CheckpointExport(checkpointExportURI, checkpointExportInterval),
+		cdc.IgnoreIncompatibleVersions(ignoreIncompatibleVersions),
+	}
+	if dataDir != "" {
+		opts = append(opts, cdc.DiskManager(&diskmanager.Config{
+			DataDir: dataDir,
+			Quota:   dataDirQuota,
+			ComponentQuotas: map[string]int64{
+				diskmanager.ComponentSorter:    sorterDiskQuota,
+				diskmanager.ComponentSinkSpill: sinkSpillDiskQuota,
+			},
+			MinFreeBytes:   dataDirMinFree,
+			MemoryFallback: dataDirFallback,
+		}))
 	}
 	server, err := cdc.NewServer(opts...)
 	if err != nil {
 		return errors.Annotate(err, "new server")
 	}
+	if serverConfigFile != "" {
+		if err := reloadServerConfig(server); err != nil {
+			return errors.Annotate(err, "load server config")
+		}
+		watchServerConfig(defaultContext, server)
+	}
+	if gracefulShutdownTimeout > 0 {
+		shutdownOnSIGTERM(defaultContext, server, cancel)
+	}
 	err = server.Run(defaultContext)
 	if err != nil && errors.Cause(err) != context.Canceled {
 		log.Error("run server", zap.String("error", errors.ErrorStack(err)))
@@ -133,3 +275,58 @@ func runEServer(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// shutdownOnSIGTERM drains the server on SIGTERM before it exits, the server
+// exits anyway once gracefulShutdownTimeout elapses.
+func shutdownOnSIGTERM(ctx context.Context, server *cdc.Server, exit context.CancelFunc) {
+	sc := make(chan os.Signal, 1)
+	// SIGTERM is handled as an exit signal in initCmd
+	signal.Reset(syscall.SIGTERM)
+	signal.Notify(sc, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sc)
+		select {
+		case <-ctx.Done():
+			return
+		case <-sc:
+		}
+		log.Info("got signal to shut down gracefully", zap.Duration("timeout", gracefulShutdownTimeout))
+		drainCtx, cancel := context.WithTimeout(ctx, gracefulShutdownTimeout)
+		if err := server.Drain(drainCtx); err != nil {
+			log.Warn("drain server failed, exit anyway", zap.Error(err))
+		}
+		cancel()
+		exit()
+	}()
+}
+
+func reloadServerConfig(server *cdc.Server) error {
+	cfg := &config.ServerConfig{}
+	if err := strictDecodeFile(serverConfigFile, "cdc server", cfg); err != nil {
+		return err
+	}
+	return server.ReloadConfig(cfg)
+}
+
+// watchServerConfig reloads the server config file on SIGHUP, the capture
+// keeps running if the reload fails.
+func watchServerConfig(ctx context.Context, server *cdc.Server) {
+	sc := make(chan os.Signal, 1)
+	// SIGHUP is handled as an exit signal in initCmd
+	signal.Reset(syscall.SIGHUP)
+	signal.Notify(sc, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(sc)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sc:
+			}
+			log.Info("got signal to reload server config", zap.String("file", serverConfigFile))
+			if err := reloadServerConfig(server); err != nil {
+				log.Warn("reload server config failed", zap.Error(err))
+			}
+		}
+	}()
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/sink"
+	"github.com/pingcap/ticdc/cdc/sink/cdclog"
+	"github.com/pingcap/ticdc/pkg/config"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/logutil"
+	"github.com/pingcap/ticdc/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	storageConsumerUpstreamURI   string
+	storageConsumerDownstreamURI string
+	storageConsumerStartTs       uint64
+	storageConsumerPollInterval  time.Duration
+	storageConsumerTimezone      string
+	storageConsumerLogFile       string
+	storageConsumerLogLevel      string
+
+	storageConsumerCmd = &cobra.Command{
+		Use:   "storage-consumer",
+		Short: "Replicate the output of a storage sink (s3, gcs, azure blob or local file) to a downstream",
+		RunE:  runEStorageConsumer,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(storageConsumerCmd)
+
+	storageConsumerCmd.Flags().StringVar(&storageConsumerUpstreamURI, "upstream-uri", "", "The sink uri of the storage sink, e.g. s3://bucket/prefix")
+	storageConsumerCmd.Flags().StringVar(&storageConsumerDownstreamURI, "downstream-uri", "", "The sink uri of the downstream, e.g. mysql://root@127.0.0.1:3306/")
+	storageConsumerCmd.Flags().Uint64Var(&storageConsumerStartTs, "start-ts", 0, "Skip the events whose commit ts is less than or equal to start-ts, used to resume the consumer")
+	storageConsumerCmd.Flags().DurationVar(&storageConsumerPollInterval, "poll-interval", 5*time.Second, "The interval to check new manifests of the storage sink")
+	storageConsumerCmd.Flags().StringVar(&storageConsumerTimezone, "tz", "System", "Specify time zone of storage consumer")
+	storageConsumerCmd.Flags().StringVar(&storageConsumerLogFile, "log-file", "", "log file path")
+	storageConsumerCmd.Flags().StringVar(&storageConsumerLogLevel, "log-level", "info", "log level (etc: debug|info|warn|error)")
+	_ = storageConsumerCmd.MarkFlagRequired("upstream-uri")
+	_ = storageConsumerCmd.MarkFlagRequired("downstream-uri")
+}
+
+func runEStorageConsumer(cmd *cobra.Command, args []string) error {
+	cancel := initCmd(cmd, &logutil.Config{
+		File:  storageConsumerLogFile,
+		Level: storageConsumerLogLevel,
+	})
+	defer cancel()
+	tz, err := util.GetTimezone(storageConsumerTimezone)
+	if err != nil {
+		return errors.Annotate(err, "can not load timezone")
+	}
+	ctx, cancel := context.WithCancel(util.PutTimezoneInCtx(defaultContext, tz))
+	defer cancel()
+
+	upstreamURI, err := url.Parse(storageConsumerUpstreamURI)
+	if err != nil {
+		return errors.Annotate(err, "invalid upstream uri")
+	}
+	downstream, err := newConsumerDownstream(ctx, cancel, "storage-consumer", storageConsumerDownstreamURI)
+	if err != nil {
+		return err
+	}
+	defer downstream.Close()
+
+	consumer, err := cdclog.NewConsumer(ctx, upstreamURI, downstream, storageConsumerStartTs, storageConsumerPollInterval)
+	if err != nil {
+		return errors.Annotate(err, "create storage consumer")
+	}
+	err = consumer.Run(ctx)
+	log.Info("storage consumer exited", zap.Uint64("applied ts", consumer.AppliedTs()))
+	if err != nil && errors.Cause(err) != context.Canceled {
+		return errors.Annotate(err, "run storage consumer")
+	}
+	return nil
+}
+
+// newConsumerDownstream creates the downstream sink of a storage consumer, the
+// consumer is canceled if the sink fails.
+func newConsumerDownstream(ctx context.Context, cancel context.CancelFunc, id, sinkURI string) (sink.Sink, error) {
+	replicaConfig := config.GetDefaultReplicaConfig()
+	f, err := filter.NewFilter(replicaConfig)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	errCh := make(chan error, 1)
+	downstream, err := sink.NewSink(ctx, id, sinkURI, f, replicaConfig, map[string]string{}, errCh)
+	if err != nil {
+		return nil, errors.Annotate(err, "create downstream sink")
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+		case err := <-errCh:
+			log.Error("downstream sink failed", zap.Error(err))
+			cancel()
+		}
+	}()
+	return downstream, nil
+}
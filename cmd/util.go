@@ -15,13 +15,19 @@ package cmd
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	liberrors "errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"os/user"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -33,10 +39,13 @@ import (
 	"github.com/pingcap/ticdc/cdc/kv"
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/cdc/sink"
+	"github.com/pingcap/ticdc/pkg/audit"
+	"github.com/pingcap/ticdc/pkg/auth"
 	"github.com/pingcap/ticdc/pkg/config"
 	"github.com/pingcap/ticdc/pkg/filter"
 	"github.com/pingcap/ticdc/pkg/httputil"
 	"github.com/pingcap/ticdc/pkg/logutil"
+	"github.com/pingcap/ticdc/pkg/secret"
 	"github.com/pingcap/ticdc/pkg/security"
 	"github.com/pingcap/ticdc/pkg/util"
 	"github.com/spf13/cobra"
@@ -50,6 +59,10 @@ var (
 	certPath      string
 	keyPath       string
 	allowedCertCN string
+	tlsMinVersion string
+	tlsCiphers    string
+	authToken     string
+	auditLog      string
 )
 
 var errOwnerNotFound = liberrors.New("owner not found")
@@ -58,22 +71,150 @@ func addSecurityFlags(flags *pflag.FlagSet, isServer bool) {
 	flags.StringVar(&caPath, "ca", "", "CA certificate path for TLS connection")
 	flags.StringVar(&certPath, "cert", "", "Certificate path for TLS connection")
 	flags.StringVar(&keyPath, "key", "", "Private key path for TLS connection")
+	flags.StringVar(&tlsMinVersion, "tls-min-version", "", "Minimal TLS version of all TLS connections (1.0|1.1|1.2|1.3)")
+	flags.StringVar(&tlsCiphers, "tls-cipher-suites", "", "Cipher suites of all TLS connections, "+
+		"e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Use `,` to separate multiple cipher suites")
 	if isServer {
 		flags.StringVar(&allowedCertCN, "cert-allowed-cn", "", "Verify caller's identity "+
 			"(cert Common Name). Use `,` to separate multiple CN")
+	} else {
+		flags.StringVar(&authToken, "auth-token", "", "Token to call the APIs of TiCDC server, "+
+			"read from the environment variable TICDC_AUTH_TOKEN if not set")
 	}
 }
 
+func addAuditFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&auditLog, "audit-log", "", "Audit log of the admin operations, "+
+		"a file path or a syslog address like syslog://, syslog+udp://127.0.0.1:514")
+}
+
+// applyNamespace qualifies the changefeed ids in the flags by the namespace,
+// and limits the list commands to the namespace.
+func applyNamespace(namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	if err := model.ValidateNamespace(namespace); err != nil {
+		return err
+	}
+	var err error
+	if changefeedID != "" {
+		if changefeedID, err = model.QualifyChangefeedID(namespace, changefeedID); err != nil {
+			return err
+		}
+	}
+	if shadowOf != "" {
+		if shadowOf, err = model.QualifyChangefeedID(namespace, shadowOf); err != nil {
+			return err
+		}
+	}
+	listOptions.Namespace = namespace
+	return nil
+}
+
+// addListFlags adds the filters, the order and the page of the list commands,
+// sortKeys are the supported sort keys and the first one is the default.
+func addListFlags(flags *pflag.FlagSet, withState bool, sortKeys ...string) {
+	if withState {
+		flags.StringSliceVar(&listOptions.States, "state", nil, "List the items in the states only")
+	}
+	flags.StringVar(&listOptions.Prefix, "prefix", "", "List the items whose ids have the prefix only")
+	flags.StringVar(&listOptions.Keyword, "keyword", "", "List the items containing the keyword case-insensitively only")
+	flags.StringVar(&listOptions.SortBy, "sort-by", sortKeys[0], "Sort the items by one of "+strings.Join(sortKeys, ", "))
+	flags.BoolVar(&listOptions.Desc, "desc", false, "Sort the items in descending order")
+	flags.IntVar(&listOptions.Offset, "offset", 0, "Skip the first items")
+	flags.IntVar(&listOptions.Limit, "limit", cdc.DefaultListLimit, "Max number of the listed items")
+}
+
+// parseListOptions validates the list flags the same way as the list APIs.
+func parseListOptions(sortKeys ...string) (*cdc.ListOptions, error) {
+	return cdc.ParseListOptions(listOptions.Values(), sortKeys...)
+}
+
+// printListTotal notes on stderr if only a page of the total items is listed,
+// so the listed items on stdout are still a valid json.
+func printListTotal(cmd *cobra.Command, opts *cdc.ListOptions, listed, total int) {
+	if listed < total {
+		cmd.PrintErrf("listed %d of the %d matched items from offset %d, use --offset and --limit to list the others\n",
+			listed, total, opts.Offset)
+	}
+}
+
+// auditCLI records an admin operation called by the CLI in the audit log.
+func auditCLI(operation, target string, params map[string]string, err error) {
+	ev := audit.Event{
+		Source:    audit.SourceCLI,
+		Caller:    osCaller(),
+		Operation: operation,
+		Target:    target,
+		Params:    params,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	audit.Log(ev)
+}
+
+// osCaller returns the OS user running the CLI in the form of user@host.
+func osCaller() string {
+	caller := "unknown"
+	if u, err := user.Current(); err == nil {
+		caller = u.Username
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		caller += "@" + hostname
+	}
+	return caller
+}
+
+// cliIdentity returns the identity of the CLI user, which is the identity the
+// TiCDC server authenticates the user by if the token or the client certificate
+// is given, or the OS user otherwise.
+func cliIdentity() string {
+	if token := getAuthToken(); token != "" {
+		return auth.TokenIdentity(token)
+	}
+	if certPath != "" {
+		data, err := ioutil.ReadFile(certPath)
+		if err == nil {
+			if block, _ := pem.Decode(data); block != nil {
+				if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+					return auth.CertIdentity(cert.Subject.CommonName)
+				}
+			}
+		}
+		log.Warn("parse the client certificate failed, use the OS user as the identity",
+			zap.String("cert", certPath))
+	}
+	return osCaller()
+}
+
+// getAuthToken returns the token to call the APIs of TiCDC server.
+func getAuthToken() string {
+	if authToken != "" {
+		return authToken
+	}
+	return os.Getenv("TICDC_AUTH_TOKEN")
+}
+
 func getCredential() *security.Credential {
 	var certAllowedCN []string
 	if len(allowedCertCN) != 0 {
 		certAllowedCN = strings.Split(allowedCertCN, ",")
 	}
+	var cipherSuites []string
+	if len(tlsCiphers) != 0 {
+		cipherSuites = strings.Split(tlsCiphers, ",")
+	}
 	return &security.Credential{
 		CAPath:        caPath,
 		CertPath:      certPath,
 		KeyPath:       keyPath,
 		CertAllowedCN: certAllowedCN,
+		TLSOptions: security.TLSOptions{
+			MinVersion:   tlsMinVersion,
+			CipherSuites: cipherSuites,
+		},
 	}
 }
 
@@ -109,6 +250,11 @@ func getAllCaptures(ctx context.Context) ([]*capture, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newCaptures(ctx, raw)
+}
+
+// newCaptures marks the owner in the captures.
+func newCaptures(ctx context.Context, raw []*model.CaptureInfo) ([]*capture, error) {
 	ownerID, err := cdcEtcdCli.GetOwnerID(ctx, kv.CaptureOwnerKey)
 	if err != nil && errors.Cause(err) != concurrency.ErrElectionNoLeader {
 		return nil, err
@@ -117,7 +263,7 @@ func getAllCaptures(ctx context.Context) ([]*capture, error) {
 	for _, c := range raw {
 		isOwner := c.ID == ownerID
 		captures = append(captures,
-			&capture{ID: c.ID, IsOwner: isOwner, AdvertiseAddr: c.AdvertiseAddr})
+			&capture{ID: c.ID, IsOwner: isOwner, AdvertiseAddr: c.AdvertiseAddr, DataDir: c.DataDir})
 	}
 	return captures, nil
 }
@@ -135,7 +281,14 @@ func getOwnerCapture(ctx context.Context) (*capture, error) {
 	return nil, errors.Trace(errOwnerNotFound)
 }
 
-func applyAdminChangefeed(ctx context.Context, job model.AdminJob, credential *security.Credential) error {
+func applyAdminChangefeed(ctx context.Context, job model.AdminJob, credential *security.Credential) (err error) {
+	defer func() {
+		var params map[string]string
+		if job.Opts != nil {
+			params = map[string]string{cdc.APIOpForceRemoveChangefeed: strconv.FormatBool(job.Opts.ForceRemove)}
+		}
+		auditCLI(job.Type.String(), job.CfID, params, err)
+	}()
 	owner, err := getOwnerCapture(ctx)
 	if err != nil {
 		return err
@@ -149,6 +302,7 @@ func applyAdminChangefeed(ctx context.Context, job model.AdminJob, credential *s
 	if err != nil {
 		return err
 	}
+	cli.SetBearerToken(getAuthToken())
 	forceRemoveOpt := "false"
 	if job.Opts != nil && job.Opts.ForceRemove {
 		forceRemoveOpt = "true"
@@ -187,6 +341,7 @@ func applyOwnerChangefeedQuery(
 	if err != nil {
 		return "", err
 	}
+	cli.SetBearerToken(getAuthToken())
 	resp, err := cli.PostForm(addr, url.Values(map[string][]string{
 		cdc.APIOpVarChangefeedID: {cid},
 	}))
@@ -203,6 +358,143 @@ func applyOwnerChangefeedQuery(
 	return string(body), nil
 }
 
+// applyOwnerChangefeedList lists a page of the changefeeds matching the
+// options by the owner, the removed and finished changefeeds are listed only
+// if all is set.
+func applyOwnerChangefeedList(
+	ctx context.Context, opts *cdc.ListOptions, all bool, credential *security.Credential,
+) (*cdc.ChangefeedListResp, error) {
+	owner, err := getOwnerCapture(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	if credential.IsTLSEnabled() {
+		scheme = "https"
+	}
+	query := opts.Values()
+	if all {
+		query.Set(cdc.APIOpVarListAll, "true")
+	}
+	addr := fmt.Sprintf("%s://%s%s?%s", scheme, owner.AdvertiseAddr, cdc.ChangefeedListAPI, query.Encode())
+	cli, err := httputil.NewClient(credential)
+	if err != nil {
+		return nil, err
+	}
+	cli.SetBearerToken(getAuthToken())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, errors.BadRequestf("%s", string(body))
+	}
+	list := &cdc.ChangefeedListResp{}
+	if err := json.Unmarshal(body, list); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return list, nil
+}
+
+// applyOwnerMoveTable asks the owner to move the table of the changefeed to
+// the capture, and pins or unpins the table.
+func applyOwnerMoveTable(
+	ctx context.Context, cid model.ChangeFeedID, target model.CaptureID, tableID model.TableID, pin bool,
+	credential *security.Credential,
+) (err error) {
+	defer func() {
+		auditCLI("move table", cid, map[string]string{
+			cdc.APIOpVarTableID:         strconv.FormatInt(tableID, 10),
+			cdc.APIOpVarTargetCaptureID: target,
+			cdc.APIOpVarPinTable:        strconv.FormatBool(pin),
+		}, err)
+	}()
+	owner, err := getOwnerCapture(ctx)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if credential.IsTLSEnabled() {
+		scheme = "https"
+	}
+	addr := fmt.Sprintf("%s://%s/capture/owner/move_table", scheme, owner.AdvertiseAddr)
+	cli, err := httputil.NewClient(credential)
+	if err != nil {
+		return err
+	}
+	cli.SetBearerToken(getAuthToken())
+	resp, err := cli.PostForm(addr, url.Values(map[string][]string{
+		cdc.APIOpVarChangefeedID:    {cid},
+		cdc.APIOpVarTargetCaptureID: {target},
+		cdc.APIOpVarTableID:         {strconv.FormatInt(tableID, 10)},
+		cdc.APIOpVarPinTable:        {strconv.FormatBool(pin)},
+	}))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return errors.BadRequestf("move table failed")
+		}
+		return errors.BadRequestf("%s", string(body))
+	}
+	return nil
+}
+
+// applyOwnerSwitchSink asks the owner to switch the sink of the changefeed at
+// the ts, the zero ts means the current resolved ts of the changefeed.
+func applyOwnerSwitchSink(
+	ctx context.Context, cid model.ChangeFeedID, sinkURI string, ts uint64, credential *security.Credential,
+) (err error) {
+	defer func() {
+		auditCLI("switch sink", cid, map[string]string{
+			cdc.APIOpVarSinkURI:  secret.RedactURI(sinkURI),
+			cdc.APIOpVarSwitchTs: strconv.FormatUint(ts, 10),
+		}, err)
+	}()
+	owner, err := getOwnerCapture(ctx)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if credential.IsTLSEnabled() {
+		scheme = "https"
+	}
+	addr := fmt.Sprintf("%s://%s%s", scheme, owner.AdvertiseAddr, cdc.SinkSwitchAPI)
+	cli, err := httputil.NewClient(credential)
+	if err != nil {
+		return err
+	}
+	cli.SetBearerToken(getAuthToken())
+	resp, err := cli.PostForm(addr, url.Values(map[string][]string{
+		cdc.APIOpVarChangefeedID: {cid},
+		cdc.APIOpVarSinkURI:      {sinkURI},
+		cdc.APIOpVarSwitchTs:     {strconv.FormatUint(ts, 10)},
+	}))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return errors.BadRequestf("switch sink failed")
+		}
+		return errors.BadRequestf("%s", string(body))
+	}
+	return nil
+}
+
 func jsonPrint(cmd *cobra.Command, v interface{}) error {
 	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
@@ -263,6 +555,36 @@ func verifyTables(ctx context.Context, credential *security.Credential, cfg *con
 	return
 }
 
+// verifySinkConflicts fails the creation of the changefeed if the other
+// changefeeds write the same tables to the same downstream, which corrupts the
+// downstream silently. It only warns if the creation is forced, the owner
+// checks the changefeeds not forced again before they are started.
+func verifySinkConflicts(ctx context.Context, cmd *cobra.Command, sinkURI string, tables []model.TableName) error {
+	_, raw, err := cdcEtcdCli.GetChangeFeeds(ctx)
+	if err != nil {
+		return err
+	}
+	conflicts, err := cdc.FindSinkConflicts(raw, changefeedID, sinkURI, tables)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(conflicts))
+	for id := range conflicts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		cmd.Printf("[WARN] changefeed %s writes the same tables to the same downstream, %v\n", id, conflicts[id])
+	}
+	if optForceCreate {
+		return nil
+	}
+	if err := cdc.SinkConflictError(conflicts); err != nil {
+		return errors.Annotate(err, "use --force to create the changefeed anyway")
+	}
+	return nil
+}
+
 func verifySink(
 	ctx context.Context, sinkURI string, cfg *config.ReplicaConfig, opts map[string]string,
 ) error {
@@ -270,6 +592,14 @@ func verifySink(
 	if err != nil {
 		return err
 	}
+	if secret.ContainsReference(sinkURI) {
+		// the secrets are resolved by the captures, they may be unavailable in cli
+		if _, err := secret.ResolveURI(ctx, sinkURI); err != nil {
+			log.Warn("skip verifying the sink, the secrets referenced by the sink uri can not be resolved locally",
+				zap.Error(err))
+			return nil
+		}
+	}
 	errCh := make(chan error)
 	s, err := sink.NewSink(ctx, "cli-verify", sinkURI, filter, cfg, opts, errCh)
 	if err != nil {
@@ -14,6 +14,7 @@
 package framework
 
 import (
+	"context"
 	"database/sql"
 	"os"
 	"os/exec"
@@ -53,7 +54,9 @@ func (d *DockerComposeOperator) Setup() {
 // WaitClusterStarted waits the cluster is started and ready
 func (d *DockerComposeOperator) WaitClusterStarted() {
 	if d.HealthChecker != nil {
-		err := retry.Run(time.Second, 120, d.HealthChecker)
+		err := retry.Do(context.Background(), d.HealthChecker,
+			retry.WithBackoffBaseDelay(time.Second), retry.WithBackoffMaxDelay(time.Minute),
+			retry.WithMaxTries(121), retry.WithTotalRetryDuration(15*time.Minute))
 		if err != nil {
 			log.Fatal("Docker service health check failed after max retries", zap.Error(err))
 		}
@@ -75,7 +78,7 @@ func (d *DockerComposeOperator) RestartComponents(names ...string) {
 }
 
 func waitTiDBStarted(dsn string) error {
-	return retry.Run(time.Second, 60, func() error {
+	return retry.Do(context.Background(), func() error {
 		upstream, err := sql.Open("mysql", dsn)
 		if err != nil {
 			return errors.Trace(err)
@@ -86,7 +89,8 @@ func waitTiDBStarted(dsn string) error {
 			return errors.Trace(err)
 		}
 		return nil
-	})
+	}, retry.WithBackoffBaseDelay(time.Second), retry.WithBackoffMaxDelay(time.Minute),
+		retry.WithMaxTries(61), retry.WithTotalRetryDuration(15*time.Minute))
 }
 
 func runCmdHandleError(cmd *exec.Cmd) []byte {
@@ -90,7 +90,9 @@ func (e *DockerEnv) RunTest(task Task) {
 		Downstream: downstream,
 		Env:        e,
 		WaitForReady: func() error {
-			return retry.Run(time.Second, 120, e.HealthChecker)
+			return retry.Do(context.Background(), e.HealthChecker,
+				retry.WithBackoffBaseDelay(time.Second), retry.WithBackoffMaxDelay(time.Minute),
+				retry.WithMaxTries(121), retry.WithTotalRetryDuration(15*time.Minute))
 		},
 		Ctx: context.Background(),
 	}
@@ -17,16 +17,20 @@ import (
 	"context"
 	"time"
 
-	backoff2 "github.com/cenkalti/backoff"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/pkg/retry"
 	"go.uber.org/zap"
 )
 
 const (
-	waitMaxPollInterval = time.Second * 5
+	waitBasePollInterval = time.Millisecond * 500
+	waitMaxPollInterval  = time.Second * 5
+	waitMaxElapsedTime   = time.Minute * 15
 )
 
+var errPollNotFinished = errors.New("pollable not finished")
+
 // Awaitable represents the handle of an SQL operation that can be waited on
 type Awaitable interface {
 	SetTimeOut(duration time.Duration) Awaitable
@@ -90,36 +94,28 @@ func (b *basicAwaitable) Wait() Checkable {
 	}
 	defer cancel()
 
-	backoff := backoff2.NewExponentialBackOff()
-	backoff.MaxInterval = waitMaxPollInterval
-	for {
-		select {
-		case <-ctx.Done():
-			return &errorCheckableAndAwaitable{ctx.Err()}
-		default:
-		}
-
+	err := retry.Do(ctx, func() error {
 		ok, err := b.poll(ctx)
 		if err != nil {
-			return &errorCheckableAndAwaitable{errors.Annotate(err, "Wait() failed with error")}
-		}
-
-		if ok {
-			log.Debug("Wait(): pollable finished")
-			return b
+			return errors.Annotate(err, "Wait() failed with error")
 		}
-
-		interval := backoff.NextBackOff()
-		if interval == backoff2.Stop {
-			return &errorCheckableAndAwaitable{errors.New("Maximum retry interval reached")}
+		if !ok {
+			log.Debug("Wait(): pollable returned false, backing off")
+			return errPollNotFinished
 		}
-		log.Debug("Wait(): pollable returned false, backing off", zap.Duration("interval", interval))
-
-		ch := time.After(interval)
-		select {
-		case <-ctx.Done():
+		return nil
+	}, retry.WithBackoffBaseDelay(waitBasePollInterval), retry.WithBackoffMaxDelay(waitMaxPollInterval),
+		retry.WithInfiniteTries(), retry.WithTotalRetryDuration(waitMaxElapsedTime),
+		retry.WithIsRetryableErr(func(err error) bool { return err == errPollNotFinished }))
+	if err == errPollNotFinished {
+		return &errorCheckableAndAwaitable{errors.New("Maximum retry interval reached")}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
 			return &errorCheckableAndAwaitable{ctx.Err()}
-		case <-ch:
 		}
+		return &errorCheckableAndAwaitable{err}
 	}
+	log.Debug("Wait(): pollable finished")
+	return b
 }
@@ -0,0 +1,208 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	dmysql "github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/pkg/cyclic/mark"
+	"github.com/pingcap/ticdc/pkg/security"
+	"go.uber.org/zap"
+)
+
+const (
+	checkpointTableName       = "kafka_consumer_checkpoint"
+	workerCheckpointTableName = "kafka_consumer_worker_checkpoint"
+)
+
+// checkpointStore saves the consumed offsets of the partitions to the downstream
+// after the row changed events before them are applied. After the consumer is
+// restarted, it resumes from the saved offsets.
+//
+// Each worker also saves the commit ts of the rows it has applied, in the same
+// transaction as the rows. The rows consumed again after the restart are
+// skipped if their worker has applied them, so every row is applied exactly
+// once.
+type checkpointStore struct {
+	db      *sql.DB
+	groupID string
+	topic   string
+}
+
+func newCheckpointStore(ctx context.Context, downstreamURI *url.URL, groupID, topic string) (*checkpointStore, error) {
+	var tlsParam string
+	if downstreamURI.Query().Get("ssl-ca") != "" {
+		credential := security.Credential{
+			CAPath:   downstreamURI.Query().Get("ssl-ca"),
+			CertPath: downstreamURI.Query().Get("ssl-cert"),
+			KeyPath:  downstreamURI.Query().Get("ssl-key"),
+		}
+		tlsCfg, err := credential.ToTLSConfig()
+		if err != nil {
+			return nil, errors.Annotate(err, "fail to open MySQL connection")
+		}
+		name := "cdc_mysql_tls_kafka_consumer_checkpoint"
+		err = dmysql.RegisterTLSConfig(name, tlsCfg)
+		if err != nil {
+			return nil, errors.Annotate(err, "fail to open MySQL connection")
+		}
+		tlsParam = "?tls=" + name
+	}
+	username := downstreamURI.User.Username()
+	password, _ := downstreamURI.User.Password()
+	port := downstreamURI.Port()
+	if username == "" {
+		username = "root"
+	}
+	if port == "" {
+		port = "4000"
+	}
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", username, password, downstreamURI.Hostname(), port, tlsParam)
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, errors.Annotate(err, "fail to open MySQL connection")
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, errors.Annotate(err, "fail to open MySQL connection")
+	}
+	s := &checkpointStore{db: db, groupID: groupID, topic: topic}
+	if err := s.createTable(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *checkpointStore) createTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "CREATE DATABASE IF NOT EXISTS "+mark.SchemaName)
+	if err != nil {
+		return errors.Annotate(err, "create checkpoint database")
+	}
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.%s (
+		group_id VARCHAR(255) NOT NULL,
+		topic VARCHAR(255) NOT NULL,
+		`+"`partition`"+` INT NOT NULL,
+		`+"`offset`"+` BIGINT NOT NULL,
+		resolved_ts BIGINT UNSIGNED NOT NULL,
+		PRIMARY KEY (group_id, topic, `+"`partition`"+`)
+	)`, mark.SchemaName, checkpointTableName))
+	if err != nil {
+		return errors.Annotate(err, "create checkpoint table")
+	}
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.%s (
+		group_id VARCHAR(255) NOT NULL,
+		topic VARCHAR(255) NOT NULL,
+		worker INT NOT NULL,
+		worker_count INT NOT NULL,
+		applied_ts BIGINT UNSIGNED NOT NULL,
+		PRIMARY KEY (group_id, topic, worker)
+	)`, mark.SchemaName, workerCheckpointTableName))
+	return errors.Annotate(err, "create checkpoint table")
+}
+
+// load returns the saved offsets of the partitions and the resolved ts of the
+// events applied before them.
+func (s *checkpointStore) load(ctx context.Context) (offsets map[int32]int64, resolvedTs uint64, err error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT `partition`, `offset`, resolved_ts FROM %s.%s WHERE group_id = ? AND topic = ?",
+		mark.SchemaName, checkpointTableName), s.groupID, s.topic)
+	if err != nil {
+		return nil, 0, errors.Annotate(err, "load checkpoint")
+	}
+	defer rows.Close()
+	offsets = make(map[int32]int64)
+	for rows.Next() {
+		var (
+			partition int32
+			offset    int64
+			ts        uint64
+		)
+		if err := rows.Scan(&partition, &offset, &ts); err != nil {
+			return nil, 0, errors.Annotate(err, "load checkpoint")
+		}
+		offsets[partition] = offset
+		// the resolved ts is saved with all partitions in one transaction
+		resolvedTs = ts
+	}
+	return offsets, resolvedTs, errors.Annotate(rows.Err(), "load checkpoint")
+}
+
+// save saves the offsets of the next messages to consume and the resolved ts
+// in one transaction.
+func (s *checkpointStore) save(ctx context.Context, offsets map[int32]int64, resolvedTs uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Annotate(err, "save checkpoint")
+	}
+	query := fmt.Sprintf("REPLACE INTO %s.%s (group_id, topic, `partition`, `offset`, resolved_ts) VALUES (?, ?, ?, ?, ?)",
+		mark.SchemaName, checkpointTableName)
+	for partition, offset := range offsets {
+		if _, err := tx.ExecContext(ctx, query, s.groupID, s.topic, partition, offset, resolvedTs); err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Warn("rollback checkpoint transaction failed", zap.Error(rbErr))
+			}
+			return errors.Annotate(err, "save checkpoint")
+		}
+	}
+	return errors.Annotate(tx.Commit(), "save checkpoint")
+}
+
+// loadWorkers returns the commit ts of the rows applied by the workers. The rows
+// are dispatched to the workers by their keys, so the saved ts are only used
+// if the worker count isn't changed.
+func (s *checkpointStore) loadWorkers(ctx context.Context, workerCount int) ([]uint64, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT worker, worker_count, applied_ts FROM %s.%s WHERE group_id = ? AND topic = ?",
+		mark.SchemaName, workerCheckpointTableName), s.groupID, s.topic)
+	if err != nil {
+		return nil, errors.Annotate(err, "load worker checkpoint")
+	}
+	defer rows.Close()
+	appliedTs := make([]uint64, workerCount)
+	for rows.Next() {
+		var (
+			worker, count int
+			ts            uint64
+		)
+		if err := rows.Scan(&worker, &count, &ts); err != nil {
+			return nil, errors.Annotate(err, "load worker checkpoint")
+		}
+		if count != workerCount || worker >= workerCount {
+			log.Warn("the worker count is changed, the rows after the checkpoint may be applied again",
+				zap.Int("old", count), zap.Int("new", workerCount))
+			return nil, nil
+		}
+		appliedTs[worker] = ts
+	}
+	return appliedTs, errors.Annotate(rows.Err(), "load worker checkpoint")
+}
+
+// workerQuery returns the statement saving the commit ts of the rows applied by
+// the worker, which is executed in the transaction of the rows.
+func (s *checkpointStore) workerQuery(worker, workerCount int, appliedTs uint64) (string, []interface{}) {
+	query := fmt.Sprintf("REPLACE INTO %s.%s (group_id, topic, worker, worker_count, applied_ts) VALUES (?, ?, ?, ?, ?)",
+		mark.SchemaName, workerCheckpointTableName)
+	return query, []interface{}{s.groupID, s.topic, worker, workerCount, appliedTs}
+}
+
+func (s *checkpointStore) close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/hash"
+	"github.com/pingcap/ticdc/pkg/quotes"
+)
+
+// conflictDetector dispatches the row changed events to the workers. A row is
+// dispatched by its handle key, so the rows changing the same row are applied
+// by the same worker in the order they are received. The rows sharing a handle
+// key or a unique key with the rows dispatched to another worker conflict with
+// them, the rows before must be flushed by all workers before they are applied.
+type conflictDetector struct {
+	workerCount int
+	hasher      *hash.PositionInertia
+	// keys are the workers of the keys dispatched since the last flush
+	keys map[string]int
+}
+
+func newConflictDetector(workerCount int) *conflictDetector {
+	return &conflictDetector{
+		workerCount: workerCount,
+		hasher:      hash.NewPositionInertia(),
+		keys:        make(map[string]int),
+	}
+}
+
+// dispatch returns the workers the rows of a transaction should be applied by.
+// The rows conflicting with each other are applied by one worker, so a worker
+// is chosen only by the rows of the transaction. conflict is true if the rows
+// conflict with the rows dispatched before, which must be flushed before the
+// rows are applied.
+func (d *conflictDetector) dispatch(rows []*model.RowChangedEvent) (workers []int, conflict bool) {
+	workers = make([]int, len(rows))
+	keys := make([][]string, len(rows))
+	txnKeys := make(map[string]int)
+	sameWorker := false
+	for i, row := range rows {
+		cols := row.Columns
+		if len(cols) == 0 {
+			cols = row.PreColumns
+		}
+		workers[i] = d.hashKey(row.Table, cols)
+		keys[i] = rowKeys(row)
+		for _, key := range keys[i] {
+			if worker, ok := txnKeys[key]; ok && worker != workers[i] {
+				sameWorker = true
+			}
+			txnKeys[key] = workers[i]
+		}
+	}
+	if sameWorker {
+		for i := range workers {
+			workers[i] = workers[0]
+		}
+	}
+	for i := range rows {
+		for _, key := range keys[i] {
+			if worker, ok := d.keys[key]; ok && worker != workers[i] {
+				conflict = true
+			}
+		}
+	}
+	if conflict {
+		d.reset()
+	}
+	for i := range rows {
+		for _, key := range keys[i] {
+			d.keys[key] = workers[i]
+		}
+	}
+	return workers, conflict
+}
+
+// reset forgets the keys dispatched before, it's called after all workers
+// are flushed.
+func (d *conflictDetector) reset() {
+	if len(d.keys) != 0 {
+		d.keys = make(map[string]int)
+	}
+}
+
+// hashKey hashes the table and the handle key of the row. All rows of a table
+// without a handle key are dispatched to the same worker.
+func (d *conflictDetector) hashKey(table *model.TableName, cols []*model.Column) int {
+	d.hasher.Reset()
+	d.hasher.Write([]byte(table.Schema), []byte(table.Table))
+	for _, col := range cols {
+		if col == nil || !col.Flag.IsHandleKey() {
+			continue
+		}
+		d.hasher.Write([]byte(col.Name), []byte(model.ColumnValueString(col.Value)))
+	}
+	return int(d.hasher.Sum32() % uint32(d.workerCount))
+}
+
+// rowKeys returns the keys of the old and new values of the row, which are the
+// handle key and each column of the unique keys. The columns of a multi-column
+// unique key are not grouped by the messages, so each of them is a key by
+// itself, which detects more conflicts than the unique key but never less.
+func rowKeys(row *model.RowChangedEvent) []string {
+	prefix := quotes.QuoteSchema(row.Table.Schema, row.Table.Table)
+	var keys []string
+	for _, cols := range [][]*model.Column{row.PreColumns, row.Columns} {
+		var handle strings.Builder
+		for _, col := range cols {
+			if col == nil || col.Value == nil {
+				continue
+			}
+			value := model.ColumnValueString(col.Value)
+			if col.Flag.IsHandleKey() {
+				handle.WriteString(col.Name)
+				handle.WriteByte(0)
+				handle.WriteString(value)
+				handle.WriteByte(0)
+			}
+			if col.Flag.IsUniqueKey() {
+				keys = append(keys, prefix+".uk."+col.Name+"\x00"+value)
+			}
+		}
+		if handle.Len() != 0 {
+			keys = append(keys, prefix+".handle."+handle.String())
+		}
+	}
+	if len(keys) == 0 {
+		// the rows of a table without keys conflict with each other
+		keys = append(keys, prefix)
+	}
+	return keys
+}
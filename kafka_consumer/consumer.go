@@ -0,0 +1,542 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/ticdc/pkg/mqconsumer"
+	"github.com/pingcap/ticdc/pkg/util"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// resolvedOffset is a position of a partition, all events before offset are
+// received and their commit ts are less than or equal to ts.
+type resolvedOffset struct {
+	ts     uint64
+	offset int64
+}
+
+// partitionBuffer buffers the row changed events of a partition until they are resolved.
+type partitionBuffer struct {
+	mu              sync.Mutex
+	rows            []*model.RowChangedEvent
+	resolvedTs      uint64
+	resolvedOffsets []resolvedOffset
+}
+
+func (b *partitionBuffer) appendRow(row *model.RowChangedEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rows = append(b.rows, row)
+}
+
+func (b *partitionBuffer) resolve(ts uint64, offset int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ts > b.resolvedTs {
+		b.resolvedTs = ts
+	}
+	b.resolvedOffsets = append(b.resolvedOffsets, resolvedOffset{ts: ts, offset: offset})
+}
+
+func (b *partitionBuffer) getResolvedTs() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.resolvedTs
+}
+
+// takeRows removes and returns the buffered rows whose commit ts is less than or equal to ts.
+func (b *partitionBuffer) takeRows(ts uint64) []*model.RowChangedEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var taken []*model.RowChangedEvent
+	remain := b.rows[:0]
+	for _, row := range b.rows {
+		if row.CommitTs <= ts {
+			taken = append(taken, row)
+		} else {
+			remain = append(remain, row)
+		}
+	}
+	b.rows = remain
+	return taken
+}
+
+// takeOffset removes the resolved offsets whose ts is less than or equal to ts,
+// and returns the greatest one of them.
+func (b *partitionBuffer) takeOffset(ts uint64) (int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i := 0
+	for i < len(b.resolvedOffsets) && b.resolvedOffsets[i].ts <= ts {
+		i++
+	}
+	if i == 0 {
+		return 0, false
+	}
+	offset := b.resolvedOffsets[i-1].offset
+	b.resolvedOffsets = b.resolvedOffsets[i:]
+	return offset, true
+}
+
+// reset drops the buffered events, they will be consumed again from the committed offset.
+func (b *partitionBuffer) reset(resolvedTs uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rows = nil
+	b.resolvedTs = resolvedTs
+	b.resolvedOffsets = nil
+}
+
+// Consumer represents a Sarama consumer group consumer
+type Consumer struct {
+	ready chan bool
+
+	ddls mqconsumer.DDLQueue
+
+	partitions     []*partitionBuffer
+	decoderFactory *mqconsumer.DecoderFactory
+	// seq is used as the commit ts of the events of the protocols without commit ts
+	seq   uint64
+	seqMu sync.Mutex
+
+	detector *conflictDetector
+	sinks    []sink.Sink
+	ddlSink  sink.Sink
+
+	checkpoint *checkpointStore
+	// txnSinks apply the rows of the workers together with their checkpoints
+	txnSinks []sink.TxnExecutor
+	// appliedTs are the commit ts of the rows applied by the workers
+	appliedTs []uint64
+	// committedOffsets are the offsets of the next messages to consume of the partitions
+	committedOffsets map[int32]int64
+	session          sarama.ConsumerGroupSession
+	sessionMu        sync.Mutex
+
+	fakeTableIDGenerator *mqconsumer.FakeTableIDGenerator
+
+	// globalResolvedTs is the ts all events before which are applied to the downstream
+	globalResolvedTs uint64
+}
+
+// NewConsumer creates a new cdc kafka consumer
+func NewConsumer(ctx context.Context) (*Consumer, error) {
+	tz, err := util.GetTimezone(timezone)
+	if err != nil {
+		return nil, errors.Annotate(err, "can not load timezone")
+	}
+	ctx = util.PutTimezoneInCtx(ctx, tz)
+	c := new(Consumer)
+	c.fakeTableIDGenerator = mqconsumer.NewFakeTableIDGenerator()
+	c.decoderFactory, err = mqconsumer.NewDecoderFactory(ctx, protocol, registryURL, claimCheckStorageURI)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	c.partitions = make([]*partitionBuffer, kafkaPartitionNum)
+	for i := range c.partitions {
+		c.partitions[i] = new(partitionBuffer)
+	}
+	c.committedOffsets = make(map[int32]int64)
+	if enableCheckpoint {
+		downstreamURI, err := url.Parse(downstreamURIStr)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		c.checkpoint, err = newCheckpointStore(ctx, downstreamURI, kafkaGroupID, kafkaTopic)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		c.committedOffsets, c.globalResolvedTs, err = c.checkpoint.load(ctx)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		c.seq = c.globalResolvedTs
+		for _, buffer := range c.partitions {
+			buffer.resolvedTs = c.globalResolvedTs
+		}
+		log.Info("load checkpoint", zap.Uint64("resolvedTs", c.globalResolvedTs), zap.Reflect("offsets", c.committedOffsets))
+	}
+
+	c.detector = newConflictDetector(workerCount)
+	c.sinks, c.ddlSink, err = mqconsumer.NewSinks(ctx, "kafka-consumer", downstreamURIStr, workerCount)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if c.checkpoint != nil {
+		if err := c.initWorkerCheckpoints(ctx); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	c.ready = make(chan bool)
+	return c, nil
+}
+
+// initWorkerCheckpoints loads the commit ts of the rows applied by the workers,
+// the workers save them in the transactions of the rows afterwards.
+func (c *Consumer) initWorkerCheckpoints(ctx context.Context) error {
+	c.txnSinks = make([]sink.TxnExecutor, len(c.sinks))
+	for i, s := range c.sinks {
+		txnSink, ok := s.(sink.TxnExecutor)
+		if !ok {
+			log.Warn("the sink can't apply the rows with the checkpoints, the rows may be applied again after restarted")
+			c.txnSinks = nil
+			return nil
+		}
+		c.txnSinks[i] = txnSink
+	}
+	if !c.decoderFactory.WithResolvedEvent() {
+		// the sequence numbers used as the commit ts of the rows can't identify
+		// the rows consumed again
+		log.Warn("the protocol has no resolved events, the rows may be applied again after restarted")
+		return nil
+	}
+	var err error
+	c.appliedTs, err = c.checkpoint.loadWorkers(ctx, len(c.sinks))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("load worker checkpoints", zap.Uint64s("appliedTs", c.appliedTs))
+	return nil
+}
+
+// Close closes the sinks and the checkpoint store of the consumer
+func (c *Consumer) Close() {
+	for _, s := range c.sinks {
+		if err := s.Close(); err != nil {
+			log.Warn("close sink failed", zap.Error(err))
+		}
+	}
+	if err := c.ddlSink.Close(); err != nil {
+		log.Warn("close ddl sink failed", zap.Error(err))
+	}
+	if c.checkpoint != nil {
+		if err := c.checkpoint.close(); err != nil {
+			log.Warn("close checkpoint store failed", zap.Error(err))
+		}
+	}
+}
+
+// Setup is run at the beginning of a new session, before ConsumeClaim
+func (c *Consumer) Setup(session sarama.ConsumerGroupSession) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	c.session = session
+	// the events not applied are consumed again from the committed offsets
+	globalResolvedTs := atomic.LoadUint64(&c.globalResolvedTs)
+	for _, partition := range session.Claims()[kafkaTopic] {
+		c.partitions[partition].reset(globalResolvedTs)
+		if offset, ok := c.committedOffsets[partition]; ok {
+			session.ResetOffset(kafkaTopic, partition, offset, "")
+		}
+	}
+	if !c.decoderFactory.WithResolvedEvent() {
+		// the DDLs of the protocols without commit ts can not be deduplicated
+		c.ddls.Reset()
+	}
+	// Mark the c as ready
+	close(c.ready)
+	return nil
+}
+
+// Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
+func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	c.session = nil
+	return nil
+}
+
+// ConsumeClaim must start a consumer loop of ConsumerGroupClaim's Messages().
+func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ctx := context.TODO()
+	buffer := c.partitions[claim.Partition()]
+	for message := range claim.Messages() {
+		log.Debug("Message claimed", zap.Int32("partition", message.Partition), zap.ByteString("key", message.Key), zap.ByteString("value", message.Value))
+		if err := c.handleMessage(ctx, buffer, message); err != nil {
+			log.Fatal("handle message failed", zap.Int32("partition", message.Partition), zap.Int64("offset", message.Offset), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (c *Consumer) handleMessage(ctx context.Context, buffer *partitionBuffer, message *sarama.ConsumerMessage) error {
+	var seq uint64
+	if !c.decoderFactory.WithResolvedEvent() {
+		// the events are applied in the order they are received, the events of a
+		// message share a sequence number as their commit ts
+		c.seqMu.Lock()
+		defer c.seqMu.Unlock()
+		c.seq++
+		seq = c.seq
+	}
+	batchDecoder, err := c.decoderFactory.NewDecoder(ctx, message.Key, message.Value)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	counter := 0
+	for {
+		tp, hasNext, err := batchDecoder.HasNext()
+		if err != nil {
+			return errors.Annotate(err, "decode message key failed")
+		}
+		if !hasNext {
+			break
+		}
+
+		counter++
+		// If the message containing only one event exceeds the length limit, CDC will allow it and issue a warning.
+		if len(message.Key)+len(message.Value) > kafkaMaxMessageBytes && counter > 1 {
+			log.Fatal("kafka max-messages-bytes exceeded", zap.Int("max-message-bytes", kafkaMaxMessageBytes),
+				zap.Int("recevied-bytes", len(message.Key)+len(message.Value)))
+		}
+
+		switch tp {
+		case model.MqMessageTypeDDL:
+			ddl, err := batchDecoder.NextDDLEvent()
+			if err != nil {
+				return errors.Annotate(err, "decode message value failed")
+			}
+			if seq != 0 {
+				ddl.CommitTs = seq
+			}
+			c.ddls.Append(ddl, atomic.LoadUint64(&c.globalResolvedTs))
+		case model.MqMessageTypeRow:
+			row, err := batchDecoder.NextRowChangedEvent()
+			if err != nil {
+				return errors.Annotate(err, "decode message value failed")
+			}
+			if seq != 0 {
+				row.CommitTs = seq
+			}
+			globalResolvedTs := atomic.LoadUint64(&c.globalResolvedTs)
+			if row.CommitTs <= globalResolvedTs {
+				log.Debug("filter applied row", zap.ByteString("row", message.Key),
+					zap.Uint64("globalResolvedTs", globalResolvedTs),
+					zap.Int32("partition", message.Partition))
+				continue
+			}
+			mqconsumer.PrepareRow(row, c.fakeTableIDGenerator)
+			buffer.appendRow(row)
+		case model.MqMessageTypeResolved:
+			ts, err := batchDecoder.NextResolvedEvent()
+			if err != nil {
+				return errors.Annotate(err, "decode message value failed")
+			}
+			buffer.resolve(ts, message.Offset+1)
+		case model.MqMessageTypeHeartbeat:
+			// the heartbeats of the idle tables are only logged
+			table, ts, err := batchDecoder.(codec.HeartbeatDecoder).NextHeartbeatEvent()
+			if err != nil {
+				return errors.Annotate(err, "decode message value failed")
+			}
+			log.Debug("receive table heartbeat", zap.Stringer("table", table), zap.Uint64("ts", ts),
+				zap.Int32("partition", message.Partition))
+		}
+	}
+
+	if counter > kafkaMaxBatchSize {
+		log.Fatal("Open Protocol max-batch-size exceeded", zap.Int("max-batch-size", kafkaMaxBatchSize),
+			zap.Int("actual-batch-size", counter))
+	}
+	if seq != 0 {
+		buffer.resolve(seq, message.Offset+1)
+	}
+	return nil
+}
+
+// getResolvedTs returns the ts all events before which are received.
+func (c *Consumer) getResolvedTs() uint64 {
+	if !c.decoderFactory.WithResolvedEvent() {
+		c.seqMu.Lock()
+		defer c.seqMu.Unlock()
+		return c.seq
+	}
+	resolvedTs := uint64(math.MaxUint64)
+	for _, buffer := range c.partitions {
+		if ts := buffer.getResolvedTs(); ts < resolvedTs {
+			resolvedTs = ts
+		}
+	}
+	return resolvedTs
+}
+
+// Run runs the Consumer
+func (c *Consumer) Run(ctx context.Context) error {
+	lastGlobalResolvedTs := atomic.LoadUint64(&c.globalResolvedTs)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		time.Sleep(100 * time.Millisecond)
+		globalResolvedTs := c.getResolvedTs()
+		// handle ddl
+		todoDDL := c.ddls.Front()
+		if todoDDL != nil && globalResolvedTs >= todoDDL.CommitTs {
+			// flush DMLs
+			if err := c.apply(ctx, todoDDL.CommitTs); err != nil {
+				return errors.Trace(err)
+			}
+			// execute ddl
+			if err := c.ddlSink.EmitDDLEvent(ctx, todoDDL); err != nil {
+				return errors.Trace(err)
+			}
+			c.ddls.Pop()
+			if err := c.saveCheckpoint(ctx, todoDDL.CommitTs); err != nil {
+				return errors.Trace(err)
+			}
+			lastGlobalResolvedTs = todoDDL.CommitTs
+			continue
+		}
+
+		if lastGlobalResolvedTs >= globalResolvedTs {
+			continue
+		}
+		lastGlobalResolvedTs = globalResolvedTs
+		log.Info("update globalResolvedTs", zap.Uint64("ts", globalResolvedTs))
+		if err := c.apply(ctx, globalResolvedTs); err != nil {
+			return errors.Trace(err)
+		}
+		if err := c.saveCheckpoint(ctx, globalResolvedTs); err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
+
+// apply applies the buffered rows whose commit ts is less than or equal to resolvedTs
+// to the downstream by the workers concurrently.
+func (c *Consumer) apply(ctx context.Context, resolvedTs uint64) error {
+	var rows []*model.RowChangedEvent
+	for _, buffer := range c.partitions {
+		rows = append(rows, buffer.takeRows(resolvedTs)...)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].CommitTs < rows[j].CommitTs
+	})
+
+	batches := make([][]*model.RowChangedEvent, len(c.sinks))
+	for i := 0; i < len(rows); {
+		// the rows of a transaction are dispatched together
+		j := i + 1
+		for j < len(rows) && rows[j].CommitTs == rows[i].CommitTs {
+			j++
+		}
+		workers, conflict := c.detector.dispatch(rows[i:j])
+		if conflict {
+			// the rows changing the same keys before must be applied first
+			if err := c.emit(ctx, batches, rows[i].CommitTs-1); err != nil {
+				return err
+			}
+		}
+		for k, row := range rows[i:j] {
+			worker := workers[k]
+			if c.appliedTs != nil && row.CommitTs <= c.appliedTs[worker] {
+				log.Debug("filter row applied by the worker", zap.Int("worker", worker),
+					zap.Uint64("commitTs", row.CommitTs), zap.Uint64("appliedTs", c.appliedTs[worker]))
+				continue
+			}
+			batches[worker] = append(batches[worker], row)
+		}
+		i = j
+	}
+	if err := c.emit(ctx, batches, resolvedTs); err != nil {
+		return err
+	}
+	c.detector.reset()
+	atomic.StoreUint64(&c.globalResolvedTs, resolvedTs)
+	return nil
+}
+
+// emit applies the batches of the workers, all the rows before or at ts must
+// be in the batches. The batches are cleared after they are applied.
+func (c *Consumer) emit(ctx context.Context, batches [][]*model.RowChangedEvent, ts uint64) error {
+	if c.txnSinks != nil {
+		errg, ctx := errgroup.WithContext(ctx)
+		for i, batch := range batches {
+			if len(batch) == 0 {
+				continue
+			}
+			i, batch := i, batch
+			errg.Go(func() error {
+				query, args := c.checkpoint.workerQuery(i, len(batches), ts)
+				return errors.Trace(c.txnSinks[i].ExecRowsInTxn(ctx, batch, query, args...))
+			})
+		}
+		if err := errg.Wait(); err != nil {
+			return err
+		}
+		for i := range batches {
+			batches[i] = nil
+		}
+		return nil
+	}
+	for i, batch := range batches {
+		if len(batch) == 0 {
+			continue
+		}
+		if err := c.sinks[i].EmitRowChangedEvents(ctx, batch...); err != nil {
+			return errors.Trace(err)
+		}
+		batches[i] = nil
+	}
+	return c.flushAll(ctx, ts)
+}
+
+func (c *Consumer) flushAll(ctx context.Context, resolvedTs uint64) error {
+	errg, ctx := errgroup.WithContext(ctx)
+	for _, s := range c.sinks {
+		s := s
+		errg.Go(func() error {
+			return mqconsumer.SyncFlushRowChangedEvents(ctx, s, resolvedTs)
+		})
+	}
+	return errg.Wait()
+}
+
+// saveCheckpoint commits the offsets of the messages whose events are applied.
+func (c *Consumer) saveCheckpoint(ctx context.Context, resolvedTs uint64) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	for partition, buffer := range c.partitions {
+		offset, ok := buffer.takeOffset(resolvedTs)
+		if !ok {
+			continue
+		}
+		c.committedOffsets[int32(partition)] = offset
+		if c.session != nil {
+			c.session.MarkOffset(kafkaTopic, int32(partition), offset, "")
+		}
+	}
+	if c.checkpoint == nil {
+		return nil
+	}
+	return c.checkpoint.save(ctx, c.committedOffsets, resolvedTs)
+}
@@ -24,7 +24,6 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -32,15 +31,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
-	"github.com/pingcap/ticdc/cdc/model"
-	"github.com/pingcap/ticdc/cdc/sink"
 	"github.com/pingcap/ticdc/cdc/sink/codec"
-	"github.com/pingcap/ticdc/pkg/config"
-	cdcfilter "github.com/pingcap/ticdc/pkg/filter"
 	"github.com/pingcap/ticdc/pkg/logutil"
-	"github.com/pingcap/ticdc/pkg/quotes"
+	"github.com/pingcap/ticdc/pkg/mqconsumer"
 	"github.com/pingcap/ticdc/pkg/security"
-	"github.com/pingcap/ticdc/pkg/util"
 	"go.uber.org/zap"
 )
 
@@ -54,7 +48,13 @@ var (
 	kafkaMaxMessageBytes = math.MaxInt64
 	kafkaMaxBatchSize    = math.MaxInt64
 
+	protocol             codec.Protocol
+	registryURL          string
+	claimCheckStorageURI string
+
 	downstreamURIStr string
+	workerCount      int
+	enableCheckpoint bool
 
 	logPath       string
 	logLevel      string
@@ -67,12 +67,15 @@ func init() {
 
 	flag.StringVar(&upstreamURIStr, "upstream-uri", "", "Kafka uri")
 	flag.StringVar(&downstreamURIStr, "downstream-uri", "", "downstream sink uri")
+	flag.IntVar(&workerCount, "worker-count", 0, "The number of sinks applying the row changed events concurrently, default to the partition number")
+	flag.BoolVar(&enableCheckpoint, "enable-checkpoint", true, "Save the consumed offsets to the downstream together with the applied data, only works for MySQL compatible downstream")
 	flag.StringVar(&logPath, "log-file", "cdc_kafka_consumer.log", "log file path")
 	flag.StringVar(&logLevel, "log-level", "info", "log file path")
 	flag.StringVar(&timezone, "tz", "System", "Specify time zone of Kafka consumer")
 	flag.StringVar(&ca, "ca", "", "CA certificate path for Kafka SSL connection")
 	flag.StringVar(&cert, "cert", "", "Certificate path for Kafka SSL connection")
 	flag.StringVar(&key, "key", "", "Private key path for Kafka SSL connection")
+	flag.StringVar(&claimCheckStorageURI, "claim-check-storage-uri", "", "Storage uri of the messages offloaded by the claim-check oversized row policy")
 	flag.Parse()
 
 	err := logutil.InitLogger(&logutil.Config{
@@ -104,6 +107,11 @@ func init() {
 	})
 	kafkaAddrs = strings.Split(upstreamURI.Host, ",")
 
+	protocol, registryURL, err = mqconsumer.ParseProtocol(upstreamURI.Query())
+	if err != nil {
+		log.Fatal("invalid upstream-uri", zap.Error(err))
+	}
+
 	config, err := newSaramaConfig()
 	if err != nil {
 		log.Fatal("Error creating sarama config", zap.Error(err))
@@ -143,6 +151,21 @@ func init() {
 		log.Info("Setting max-batch-size", zap.Int("max-batch-size", c))
 		kafkaMaxBatchSize = c
 	}
+
+	if workerCount <= 0 {
+		workerCount = int(kafkaPartitionNum)
+	}
+	if enableCheckpoint {
+		downstreamURI, err := url.Parse(downstreamURIStr)
+		if err != nil {
+			log.Fatal("invalid downstream-uri", zap.Error(err))
+		}
+		scheme := strings.ToLower(downstreamURI.Scheme)
+		if scheme != "mysql" && scheme != "tidb" && scheme != "mysql+ssl" && scheme != "tidb+ssl" {
+			log.Warn("checkpoint is only supported by MySQL compatible downstream, disable it", zap.String("scheme", scheme))
+			enableCheckpoint = false
+		}
+	}
 }
 
 func getPartitionNum(address []string, topic string, cfg *sarama.Config) (int32, error) {
@@ -219,7 +242,7 @@ func newSaramaConfig() (*sarama.Config, error) {
 }
 
 func main() {
-	log.Info("Starting a new TiCDC open protocol consumer")
+	log.Info("Starting a new TiCDC kafka consumer", zap.Int("protocol", int(protocol)), zap.Int("worker-count", workerCount))
 
 	/**
 	 * Construct a new Sarama configuration.
@@ -236,12 +259,11 @@ func main() {
 	/**
 	 * Setup a new Sarama consumer group
 	 */
-	consumer, err := NewConsumer(context.TODO())
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer, err := NewConsumer(ctx)
 	if err != nil {
 		log.Fatal("Error creating consumer", zap.Error(err))
 	}
-
-	ctx, cancel := context.WithCancel(context.Background())
 	client, err := sarama.NewConsumerGroup(kafkaAddrs, kafkaGroupID, config)
 	if err != nil {
 		log.Fatal("Error creating consumer group client", zap.Error(err))
@@ -273,7 +295,7 @@ func main() {
 	}()
 
 	<-consumer.ready // Await till the consumer has been set up
-	log.Info("TiCDC open protocol consumer up and running!...")
+	log.Info("TiCDC kafka consumer up and running!...")
 
 	sigterm := make(chan os.Signal, 1)
 	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
@@ -288,338 +310,5 @@ func main() {
 	if err = client.Close(); err != nil {
 		log.Fatal("Error closing client", zap.Error(err))
 	}
-}
-
-// Consumer represents a Sarama consumer group consumer
-type Consumer struct {
-	ready chan bool
-
-	ddlList          []*model.DDLEvent
-	maxDDLReceivedTs uint64
-	ddlListMu        sync.Mutex
-
-	sinks []*struct {
-		sink.Sink
-		resolvedTs uint64
-	}
-	sinksMu sync.Mutex
-
-	ddlSink              sink.Sink
-	fakeTableIDGenerator *fakeTableIDGenerator
-
-	globalResolvedTs uint64
-}
-
-// NewConsumer creates a new cdc kafka consumer
-func NewConsumer(ctx context.Context) (*Consumer, error) {
-	// TODO support filter in downstream sink
-	tz, err := util.GetTimezone(timezone)
-	if err != nil {
-		return nil, errors.Annotate(err, "can not load timezone")
-	}
-	ctx = util.PutTimezoneInCtx(ctx, tz)
-	filter, err := cdcfilter.NewFilter(config.GetDefaultReplicaConfig())
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	c := new(Consumer)
-	c.fakeTableIDGenerator = &fakeTableIDGenerator{
-		tableIDs: make(map[string]int64),
-	}
-	c.sinks = make([]*struct {
-		sink.Sink
-		resolvedTs uint64
-	}, kafkaPartitionNum)
-	ctx, cancel := context.WithCancel(ctx)
-	errCh := make(chan error, 1)
-	opts := map[string]string{}
-	for i := 0; i < int(kafkaPartitionNum); i++ {
-		s, err := sink.NewSink(ctx, "kafka-consumer", downstreamURIStr, filter, config.GetDefaultReplicaConfig(), opts, errCh)
-		if err != nil {
-			cancel()
-			return nil, errors.Trace(err)
-		}
-		c.sinks[i] = &struct {
-			sink.Sink
-			resolvedTs uint64
-		}{Sink: s}
-	}
-	sink, err := sink.NewSink(ctx, "kafka-consumer", downstreamURIStr, filter, config.GetDefaultReplicaConfig(), opts, errCh)
-	if err != nil {
-		cancel()
-		return nil, errors.Trace(err)
-	}
-	go func() {
-		err := <-errCh
-		if errors.Cause(err) != context.Canceled {
-			log.Error("error on running consumer", zap.Error(err))
-		} else {
-			log.Info("consumer exited")
-		}
-		cancel()
-	}()
-	c.ddlSink = sink
-	c.ready = make(chan bool)
-	return c, nil
-}
-
-// Setup is run at the beginning of a new session, before ConsumeClaim
-func (c *Consumer) Setup(sarama.ConsumerGroupSession) error {
-	// Mark the c as ready
-	close(c.ready)
-	return nil
-}
-
-// Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
-func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
-	return nil
-}
-
-// ConsumeClaim must start a consumer loop of ConsumerGroupClaim's Messages().
-func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	ctx := context.TODO()
-	partition := claim.Partition()
-	c.sinksMu.Lock()
-	sink := c.sinks[partition]
-	c.sinksMu.Unlock()
-	if sink == nil {
-		panic("sink should initialized")
-	}
-ClaimMessages:
-	for message := range claim.Messages() {
-		log.Info("Message claimed", zap.Int32("partition", message.Partition), zap.ByteString("key", message.Key), zap.ByteString("value", message.Value))
-		batchDecoder, err := codec.NewJSONEventBatchDecoder(message.Key, message.Value)
-		if err != nil {
-			return errors.Trace(err)
-		}
-
-		counter := 0
-		for {
-			tp, hasNext, err := batchDecoder.HasNext()
-			if err != nil {
-				log.Fatal("decode message key failed", zap.Error(err))
-			}
-			if !hasNext {
-				break
-			}
-
-			counter++
-			// If the message containing only one event exceeds the length limit, CDC will allow it and issue a warning.
-			if len(message.Key)+len(message.Value) > kafkaMaxMessageBytes && counter > 1 {
-				log.Fatal("kafka max-messages-bytes exceeded", zap.Int("max-message-bytes", kafkaMaxMessageBytes),
-					zap.Int("recevied-bytes", len(message.Key)+len(message.Value)))
-			}
-
-			switch tp {
-			case model.MqMessageTypeDDL:
-				ddl, err := batchDecoder.NextDDLEvent()
-				if err != nil {
-					log.Fatal("decode message value failed", zap.ByteString("value", message.Value))
-				}
-				c.appendDDL(ddl)
-			case model.MqMessageTypeRow:
-				row, err := batchDecoder.NextRowChangedEvent()
-				if err != nil {
-					log.Fatal("decode message value failed", zap.ByteString("value", message.Value))
-				}
-				globalResolvedTs := atomic.LoadUint64(&c.globalResolvedTs)
-				if row.CommitTs <= globalResolvedTs || row.CommitTs <= sink.resolvedTs {
-					log.Debug("filter fallback row", zap.ByteString("row", message.Key),
-						zap.Uint64("globalResolvedTs", globalResolvedTs),
-						zap.Uint64("sinkResolvedTs", sink.resolvedTs),
-						zap.Int32("partition", partition))
-					break ClaimMessages
-				}
-				// FIXME: hack to set start-ts in row changed event, as start-ts
-				// is not contained in TiCDC open protocol
-				row.StartTs = row.CommitTs
-				var partitionID int64
-				if row.Table.IsPartition {
-					partitionID = row.Table.TableID
-				}
-				row.Table.TableID =
-					c.fakeTableIDGenerator.generateFakeTableID(row.Table.Schema, row.Table.Table, partitionID)
-				err = sink.EmitRowChangedEvents(ctx, row)
-				if err != nil {
-					log.Fatal("emit row changed event failed", zap.Error(err))
-				}
-			case model.MqMessageTypeResolved:
-				ts, err := batchDecoder.NextResolvedEvent()
-				if err != nil {
-					log.Fatal("decode message value failed", zap.ByteString("value", message.Value))
-				}
-				resolvedTs := atomic.LoadUint64(&sink.resolvedTs)
-				if resolvedTs < ts {
-					log.Debug("update sink resolved ts",
-						zap.Uint64("ts", ts),
-						zap.Int32("partition", partition))
-					atomic.StoreUint64(&sink.resolvedTs, ts)
-				}
-			}
-			session.MarkMessage(message, "")
-		}
-
-		if counter > kafkaMaxBatchSize {
-			log.Fatal("Open Protocol max-batch-size exceeded", zap.Int("max-batch-size", kafkaMaxBatchSize),
-				zap.Int("actual-batch-size", counter))
-		}
-	}
-
-	return nil
-}
-
-func (c *Consumer) appendDDL(ddl *model.DDLEvent) {
-	c.ddlListMu.Lock()
-	defer c.ddlListMu.Unlock()
-	if ddl.CommitTs <= c.maxDDLReceivedTs {
-		return
-	}
-	globalResolvedTs := atomic.LoadUint64(&c.globalResolvedTs)
-	if ddl.CommitTs <= globalResolvedTs {
-		log.Error("unexpected ddl job", zap.Uint64("ddlts", ddl.CommitTs), zap.Uint64("globalResolvedTs", globalResolvedTs))
-		return
-	}
-	c.ddlList = append(c.ddlList, ddl)
-	c.maxDDLReceivedTs = ddl.CommitTs
-}
-
-func (c *Consumer) getFrontDDL() *model.DDLEvent {
-	c.ddlListMu.Lock()
-	defer c.ddlListMu.Unlock()
-	if len(c.ddlList) > 0 {
-		return c.ddlList[0]
-	}
-	return nil
-}
-
-func (c *Consumer) popDDL() *model.DDLEvent {
-	c.ddlListMu.Lock()
-	defer c.ddlListMu.Unlock()
-	if len(c.ddlList) > 0 {
-		ddl := c.ddlList[0]
-		c.ddlList = c.ddlList[1:]
-		return ddl
-	}
-	return nil
-}
-
-func (c *Consumer) forEachSink(fn func(sink *struct {
-	sink.Sink
-	resolvedTs uint64
-}) error) error {
-	c.sinksMu.Lock()
-	defer c.sinksMu.Unlock()
-	for _, sink := range c.sinks {
-		if err := fn(sink); err != nil {
-			return errors.Trace(err)
-		}
-	}
-	return nil
-}
-
-// Run runs the Consumer
-func (c *Consumer) Run(ctx context.Context) error {
-	var lastGlobalResolvedTs uint64
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-		time.Sleep(100 * time.Millisecond)
-		// handle ddl
-		globalResolvedTs := uint64(math.MaxUint64)
-		err := c.forEachSink(func(sink *struct {
-			sink.Sink
-			resolvedTs uint64
-		}) error {
-			resolvedTs := atomic.LoadUint64(&sink.resolvedTs)
-			if resolvedTs < globalResolvedTs {
-				globalResolvedTs = resolvedTs
-			}
-			return nil
-		})
-		if err != nil {
-			return errors.Trace(err)
-		}
-		todoDDL := c.getFrontDDL()
-		if todoDDL != nil && globalResolvedTs >= todoDDL.CommitTs {
-			// flush DMLs
-			err := c.forEachSink(func(sink *struct {
-				sink.Sink
-				resolvedTs uint64
-			}) error {
-				return syncFlushRowChangedEvents(ctx, sink, todoDDL.CommitTs)
-			})
-			if err != nil {
-				return errors.Trace(err)
-			}
-
-			// execute ddl
-			err = c.ddlSink.EmitDDLEvent(ctx, todoDDL)
-			if err != nil {
-				return errors.Trace(err)
-			}
-			c.popDDL()
-			continue
-		}
-
-		if todoDDL != nil && todoDDL.CommitTs < globalResolvedTs {
-			globalResolvedTs = todoDDL.CommitTs
-		}
-		if lastGlobalResolvedTs == globalResolvedTs {
-			continue
-		}
-		lastGlobalResolvedTs = globalResolvedTs
-		atomic.StoreUint64(&c.globalResolvedTs, globalResolvedTs)
-		log.Info("update globalResolvedTs", zap.Uint64("ts", globalResolvedTs))
-
-		err = c.forEachSink(func(sink *struct {
-			sink.Sink
-			resolvedTs uint64
-		}) error {
-			return syncFlushRowChangedEvents(ctx, sink, globalResolvedTs)
-		})
-		if err != nil {
-			return errors.Trace(err)
-		}
-	}
-}
-
-func syncFlushRowChangedEvents(ctx context.Context, sink sink.Sink, resolvedTs uint64) error {
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-		checkpointTs, err := sink.FlushRowChangedEvents(ctx, resolvedTs)
-		if err != nil {
-			return err
-		}
-		if checkpointTs >= resolvedTs {
-			return nil
-		}
-	}
-}
-
-type fakeTableIDGenerator struct {
-	tableIDs       map[string]int64
-	currentTableID int64
-	mu             sync.Mutex
-}
-
-func (g *fakeTableIDGenerator) generateFakeTableID(schema, table string, partition int64) int64 {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	key := quotes.QuoteSchema(schema, table)
-	if partition != 0 {
-		key = fmt.Sprintf("%s.`%d`", key, partition)
-	}
-	if tableID, ok := g.tableIDs[key]; ok {
-		return tableID
-	}
-	g.currentTableID++
-	g.tableIDs[key] = g.currentTableID
-	return g.currentTableID
+	consumer.Close()
 }
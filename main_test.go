@@ -19,6 +19,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/pingcap/ticdc/cdc"
 )
 
 func TestRunMain(t *testing.T) {
@@ -32,5 +34,7 @@ func TestRunMain(t *testing.T) {
 	}
 
 	os.Args = args
+	// the test binary is only built for the integration tests
+	cdc.EnableFailpointAPI()
 	main()
 }
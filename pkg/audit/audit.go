@@ -0,0 +1,255 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records the admin operations of the APIs and the CLI.
+//
+// Each operation is written as a line of JSON. The records are chained by
+// hashes, a record contains the hash of the previous one and its own hash,
+// so a modified, inserted or deleted record breaks the chain and can be
+// found by Verify.
+//
+// The audit log is written to a file or sent to syslog:
+//
+//	/var/log/cdc-audit.log           a file, the chain continues after restart
+//	syslog://                        the local syslog daemon
+//	syslog+udp://127.0.0.1:514       a remote syslog server by udp
+//	syslog+tcp://127.0.0.1:514       a remote syslog server by tcp
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// The sources of the operations.
+const (
+	SourceAPI = "api"
+	SourceCLI = "cli"
+)
+
+// Event is an admin operation.
+type Event struct {
+	Time      time.Time         `json:"time"`
+	Source    string            `json:"source"`
+	Caller    string            `json:"caller"`
+	Remote    string            `json:"remote,omitempty"`
+	Operation string            `json:"operation"`
+	Target    string            `json:"target,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	PrevHash  string            `json:"prev-hash"`
+	Hash      string            `json:"hash"`
+}
+
+// computeHash returns the hash of the event, which covers all fields but Hash.
+func (e *Event) computeHash() (string, error) {
+	ev := *e
+	ev.Hash = ""
+	data, err := json.Marshal(&ev)
+	if err != nil {
+		return "", cerror.WrapError(cerror.ErrAuditLog, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Logger writes the events to the audit log.
+type Logger struct {
+	mu       sync.Mutex
+	w        io.WriteCloser
+	lastHash string
+}
+
+// NewLogger creates a Logger writing to the target, see the package document
+// for the supported targets.
+func NewLogger(target string) (*Logger, error) {
+	if strings.HasPrefix(target, "syslog") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrAuditLog, err)
+		}
+		w, err := newSyslogWriter(u)
+		if err != nil {
+			return nil, err
+		}
+		return &Logger{w: w}, nil
+	}
+	path := strings.TrimPrefix(target, "file://")
+	lastHash, err := readLastHash(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrAuditLog, err)
+	}
+	return &Logger{w: f, lastHash: lastHash}, nil
+}
+
+// readLastHash returns the hash of the last record in the file, so the chain
+// continues after the file is reopened.
+func readLastHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", cerror.WrapError(cerror.ErrAuditLog, err)
+	}
+	defer f.Close()
+	var lastLine string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lastLine = line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", cerror.WrapError(cerror.ErrAuditLog, err)
+	}
+	if lastLine == "" {
+		return "", nil
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(lastLine), &ev); err != nil {
+		return "", cerror.WrapError(cerror.ErrAuditLog, err)
+	}
+	return ev.Hash, nil
+}
+
+// Log writes an event, the time and the hashes of the event are filled.
+func (l *Logger) Log(ev Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	ev.PrevHash = l.lastHash
+	hash, err := ev.computeHash()
+	if err != nil {
+		return err
+	}
+	ev.Hash = hash
+	data, err := json.Marshal(&ev)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrAuditLog, err)
+	}
+	if _, err := l.w.Write(append(data, '\n')); err != nil {
+		return cerror.WrapError(cerror.ErrAuditLog, err)
+	}
+	l.lastHash = hash
+	return nil
+}
+
+// Close closes the audit log.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return cerror.WrapError(cerror.ErrAuditLog, l.w.Close())
+}
+
+// Verify reads the records from r and checks whether the chain is intact.
+func Verify(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	lastHash := ""
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			return cerror.ErrAuditLog.GenWithStack("invalid record at line %d: %s", lineNo, err)
+		}
+		hash, err := ev.computeHash()
+		if err != nil {
+			return err
+		}
+		if hash != ev.Hash {
+			return cerror.ErrAuditLog.GenWithStack("record at line %d is modified", lineNo)
+		}
+		if ev.PrevHash != lastHash {
+			return cerror.ErrAuditLog.GenWithStack("chain is broken before line %d", lineNo)
+		}
+		lastHash = ev.Hash
+	}
+	return cerror.WrapError(cerror.ErrAuditLog, scanner.Err())
+}
+
+var (
+	globalMu     sync.RWMutex
+	globalLogger *Logger
+)
+
+// Init initializes the global audit logger, the audit log is disabled if the
+// target is empty.
+func Init(target string) error {
+	if target == "" {
+		return nil
+	}
+	logger, err := NewLogger(target)
+	if err != nil {
+		return err
+	}
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	if globalLogger != nil {
+		_ = globalLogger.Close()
+	}
+	globalLogger = logger
+	return nil
+}
+
+// Log writes an event to the global audit logger, it is a no-op if the audit
+// log is disabled. The failure is logged but not returned, since an operation
+// is never rejected because of the audit log.
+func Log(ev Event) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	if globalLogger == nil {
+		return
+	}
+	if err := globalLogger.Log(ev); err != nil {
+		log.Warn("write audit log failed",
+			zap.String("operation", ev.Operation),
+			zap.String("target", ev.Target),
+			zap.Error(err))
+	}
+}
+
+// Close closes the global audit logger.
+func Close() {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	if globalLogger == nil {
+		return
+	}
+	if err := globalLogger.Close(); err != nil {
+		log.Warn("close audit log failed", zap.Error(err))
+	}
+	globalLogger = nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type auditSuite struct{}
+
+var _ = check.Suite(&auditSuite{})
+
+func (s *auditSuite) TestFileLogger(c *check.C) {
+	defer testleak.AfterTest(c)()
+	path := filepath.Join(c.MkDir(), "audit.log")
+
+	logger, err := NewLogger(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(logger.Log(Event{Source: SourceCLI, Caller: "root@host", Operation: "create changefeed", Target: "cf-1"}), check.IsNil)
+	c.Assert(logger.Log(Event{Source: SourceAPI, Caller: "cert:ctl", Operation: "stop changefeed", Target: "cf-1"}), check.IsNil)
+	c.Assert(logger.Close(), check.IsNil)
+
+	// the chain continues after the file is reopened
+	logger, err = NewLogger("file://" + path)
+	c.Assert(err, check.IsNil)
+	c.Assert(logger.Log(Event{Source: SourceAPI, Caller: "token:1a2b3c4d", Operation: "resign owner", Error: "not owner"}), check.IsNil)
+	c.Assert(logger.Close(), check.IsNil)
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(Verify(bytes.NewReader(data)), check.IsNil)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	c.Assert(lines, check.HasLen, 3)
+	var ev Event
+	c.Assert(json.Unmarshal([]byte(lines[2]), &ev), check.IsNil)
+	c.Assert(ev.Operation, check.Equals, "resign owner")
+	c.Assert(ev.Error, check.Equals, "not owner")
+	c.Assert(ev.Time.IsZero(), check.IsFalse)
+
+	// modify a record
+	modified := strings.Replace(string(data), "cf-1", "cf-2", 1)
+	c.Assert(Verify(strings.NewReader(modified)), check.ErrorMatches, ".*record at line 1 is modified.*")
+	// delete a record
+	deleted := lines[0] + "\n" + lines[2] + "\n"
+	c.Assert(Verify(strings.NewReader(deleted)), check.ErrorMatches, ".*chain is broken before line 2.*")
+}
+
+func (s *auditSuite) TestGlobalLogger(c *check.C) {
+	defer testleak.AfterTest(c)()
+	// it is a no-op before initialized
+	Log(Event{Operation: "noop"})
+	c.Assert(Init(""), check.IsNil)
+	Log(Event{Operation: "noop"})
+
+	path := filepath.Join(c.MkDir(), "audit.log")
+	c.Assert(Init(path), check.IsNil)
+	Log(Event{Source: SourceCLI, Operation: "reset cluster"})
+	Close()
+	Log(Event{Operation: "noop"})
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.Count(string(data), "\n"), check.Equals, 1)
+	c.Assert(string(data), check.Matches, `.*"operation":"reset cluster".*\n`)
+
+	_, err = NewLogger("syslog+http://127.0.0.1:514")
+	c.Assert(err, check.ErrorMatches, ".*unsupported audit log target syslog\\+http.*")
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !windows
+
+package audit
+
+import (
+	"io"
+	"log/syslog"
+	"net/url"
+
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+const syslogTag = "ticdc-audit"
+
+func newSyslogWriter(u *url.URL) (io.WriteCloser, error) {
+	var network string
+	switch u.Scheme {
+	case "syslog":
+	case "syslog+udp":
+		network = "udp"
+	case "syslog+tcp":
+		network = "tcp"
+	default:
+		return nil, cerror.ErrAuditLog.GenWithStack("unsupported audit log target %s", u.Scheme)
+	}
+	w, err := syslog.Dial(network, u.Host, syslog.LOG_NOTICE|syslog.LOG_AUTH, syslogTag)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrAuditLog, err)
+	}
+	return w, nil
+}
@@ -0,0 +1,300 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth authenticates the callers of the HTTP APIs and checks whether
+// they have the permission to call an API.
+//
+// A caller is authenticated by a static token in the `Authorization: Bearer <token>`
+// header, or by the common name of its TLS client certificate. Each token or
+// common name is bound to a role:
+//
+//	viewer: only reads the status of the cluster and the changefeeds
+//	admin:  also pauses, resumes and removes changefeeds, moves tables and so on
+//
+// A token or common name can also be scoped to a namespace of changefeeds, so
+// the caller only reads or manages the changefeeds in the namespace.
+//
+// The captures call the internal APIs of each other with an internal token,
+// which is not bound to a role, so it can't call the other APIs, and the user
+// tokens can't call the internal APIs.
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/ticdc/cdc/model"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+// Role is the role of a caller.
+type Role int
+
+// The roles, a role with a larger value has all permissions of the smaller ones.
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleAdmin
+)
+
+// String implements fmt.Stringer.
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+// internalRole is the role of the internal tokens in the token file.
+const internalRole = "internal"
+
+// ParseRole parses a role from its name.
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "viewer":
+		return RoleViewer, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, cerror.ErrInvalidAuthConfig.GenWithStack("unknown role %s", s)
+}
+
+// Config binds the tokens and the common names of the client certificates to roles.
+type Config struct {
+	Tokens    map[string]Role
+	CertRoles map[string]Role
+	// TokenNamespaces and CertNamespaces are the namespaces the tokens and
+	// the common names are scoped to, the ones not in them aren't scoped.
+	TokenNamespaces map[string]string
+	CertNamespaces  map[string]string
+	// InternalTokens are the tokens the captures call the internal APIs of
+	// each other with.
+	InternalTokens map[string]struct{}
+}
+
+// IsEnabled returns whether any caller is required to be authenticated.
+func (c *Config) IsEnabled() bool {
+	return c != nil && (len(c.Tokens) != 0 || len(c.CertRoles) != 0)
+}
+
+// LoadTokenFile loads the tokens from a file, each line of the file is a role
+// followed by a token, and optionally the namespace the token is scoped to.
+// The role `internal` marks an internal token, which can't be scoped. For
+// example:
+//
+//	# the token of the dashboard
+//	viewer   0c6a5d3c2f4b
+//	admin    9e1f8b7a6d5c
+//	admin    3b2a1f0e9d8c team-a
+//	internal 7d6c5b4a3f2e
+func (c *Config) LoadTokenFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrInvalidAuthConfig, err)
+	}
+	defer f.Close()
+	if c.Tokens == nil {
+		c.Tokens = make(map[string]Role)
+	}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 3 {
+			return cerror.ErrInvalidAuthConfig.GenWithStack("invalid token at %s:%d", path, lineNo)
+		}
+		if strings.EqualFold(fields[0], internalRole) {
+			if len(fields) != 2 {
+				return cerror.ErrInvalidAuthConfig.GenWithStack(
+					"internal token can't be scoped at %s:%d", path, lineNo)
+			}
+			if c.InternalTokens == nil {
+				c.InternalTokens = make(map[string]struct{})
+			}
+			c.InternalTokens[fields[1]] = struct{}{}
+			continue
+		}
+		role, err := ParseRole(fields[0])
+		if err != nil {
+			return err
+		}
+		if len(fields) == 3 {
+			if err := model.ValidateNamespace(fields[2]); err != nil {
+				return cerror.WrapError(cerror.ErrInvalidAuthConfig, err)
+			}
+			if c.TokenNamespaces == nil {
+				c.TokenNamespaces = make(map[string]string)
+			}
+			c.TokenNamespaces[fields[1]] = fields[2]
+		}
+		c.Tokens[fields[1]] = role
+	}
+	return cerror.WrapError(cerror.ErrInvalidAuthConfig, scanner.Err())
+}
+
+// ParseCertRoles parses the roles of the client certificates from
+// `<common name>:<role>` pairs separated by `,`, for example `dashboard:viewer,ctl:admin`.
+// A role followed by `@<namespace>` scopes the common name to the namespace,
+// for example `team-a-ctl:admin@team-a`.
+func (c *Config) ParseCertRoles(s string) error {
+	if c.CertRoles == nil {
+		c.CertRoles = make(map[string]Role)
+	}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		idx := strings.LastIndex(pair, ":")
+		if idx <= 0 {
+			return cerror.ErrInvalidAuthConfig.GenWithStack("invalid certificate role %s", pair)
+		}
+		roleStr, namespace := pair[idx+1:], ""
+		if at := strings.Index(roleStr, "@"); at >= 0 {
+			roleStr, namespace = roleStr[:at], strings.TrimSpace(roleStr[at+1:])
+			if err := model.ValidateNamespace(namespace); err != nil {
+				return cerror.WrapError(cerror.ErrInvalidAuthConfig, err)
+			}
+		}
+		role, err := ParseRole(roleStr)
+		if err != nil {
+			return err
+		}
+		commonName := strings.TrimSpace(pair[:idx])
+		c.CertRoles[commonName] = role
+		if namespace != "" {
+			if c.CertNamespaces == nil {
+				c.CertNamespaces = make(map[string]string)
+			}
+			c.CertNamespaces[commonName] = namespace
+		}
+	}
+	return nil
+}
+
+// InternalToken returns the internal token a capture calls the internal APIs
+// of the other captures with, the first one is used if there are several.
+// The captures of a cluster are expected to load the same token file, it
+// returns an empty string if there is no internal token.
+func (c *Config) InternalToken() string {
+	if c == nil || len(c.InternalTokens) == 0 {
+		return ""
+	}
+	tokens := make([]string, 0, len(c.InternalTokens))
+	for token := range c.InternalTokens {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens[0]
+}
+
+// AuthenticateInternal checks whether the caller of req is a capture, which
+// carries an internal token.
+func (c *Config) AuthenticateInternal(req *http.Request) error {
+	token, ok := bearerToken(req)
+	if !ok || token == "" {
+		return cerror.ErrAPIUnauthenticated.GenWithStack("no internal token")
+	}
+	for t := range c.InternalTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return nil
+		}
+	}
+	return cerror.ErrAPIUnauthenticated.GenWithStack("invalid internal token")
+}
+
+// Authenticate returns the role of the caller of req. The bearer token takes
+// precedence over the client certificate.
+func (c *Config) Authenticate(req *http.Request) (Role, error) {
+	role, _, err := c.AuthenticateScope(req)
+	return role, err
+}
+
+// AuthenticateScope returns the role of the caller of req like Authenticate,
+// and the namespace the caller is scoped to, which is empty if the caller
+// isn't scoped.
+func (c *Config) AuthenticateScope(req *http.Request) (Role, string, error) {
+	if token, ok := bearerToken(req); ok {
+		if token == "" {
+			return RoleNone, "", cerror.ErrAPIUnauthenticated.GenWithStack("unsupported authorization scheme")
+		}
+		for t, role := range c.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return role, c.TokenNamespaces[t], nil
+			}
+		}
+		return RoleNone, "", cerror.ErrAPIUnauthenticated.GenWithStack("invalid token")
+	}
+	if req.TLS != nil {
+		for _, chain := range req.TLS.VerifiedChains {
+			if len(chain) == 0 {
+				continue
+			}
+			commonName := chain[0].Subject.CommonName
+			if role, ok := c.CertRoles[commonName]; ok {
+				return role, c.CertNamespaces[commonName], nil
+			}
+		}
+	}
+	return RoleNone, "", cerror.ErrAPIUnauthenticated.GenWithStack("no valid token or client certificate")
+}
+
+// Identity returns the identity of the caller of req, which is the common name
+// of the client certificate or the fingerprint of the token, so the tokens are
+// never leaked to the logs.
+func Identity(req *http.Request) string {
+	if token, ok := bearerToken(req); ok && token != "" {
+		return TokenIdentity(token)
+	}
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 && len(req.TLS.VerifiedChains[0]) > 0 {
+		return CertIdentity(req.TLS.VerifiedChains[0][0].Subject.CommonName)
+	}
+	return "anonymous"
+}
+
+// TokenIdentity returns the identity of the caller with the token.
+func TokenIdentity(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:4])
+}
+
+// CertIdentity returns the identity of the caller with the client certificate
+// of the common name.
+func CertIdentity(commonName string) string {
+	return "cert:" + commonName
+}
+
+// bearerToken returns the token in the Authorization header, ok is false if
+// the header is absent, and the token is empty if the scheme is not Bearer.
+func bearerToken(req *http.Request) (token string, ok bool) {
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		return "", false
+	}
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", true
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
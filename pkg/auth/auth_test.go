@@ -0,0 +1,165 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type authSuite struct{}
+
+var _ = check.Suite(&authSuite{})
+
+func (s *authSuite) TestLoadConfig(c *check.C) {
+	defer testleak.AfterTest(c)()
+	file := filepath.Join(c.MkDir(), "tokens")
+	content := "# dashboard\nviewer view-token\n\nadmin  admin-token\n"
+	c.Assert(ioutil.WriteFile(file, []byte(content), 0o600), check.IsNil)
+
+	cfg := &Config{}
+	c.Assert(cfg.IsEnabled(), check.IsFalse)
+	c.Assert(cfg.LoadTokenFile(file), check.IsNil)
+	c.Assert(cfg.Tokens, check.DeepEquals, map[string]Role{
+		"view-token":  RoleViewer,
+		"admin-token": RoleAdmin,
+	})
+	c.Assert(cfg.ParseCertRoles("dashboard:viewer, ctl:admin"), check.IsNil)
+	c.Assert(cfg.CertRoles, check.DeepEquals, map[string]Role{
+		"dashboard": RoleViewer,
+		"ctl":       RoleAdmin,
+	})
+	c.Assert(cfg.IsEnabled(), check.IsTrue)
+
+	c.Assert(ioutil.WriteFile(file, []byte("root admin-token\n"), 0o600), check.IsNil)
+	c.Assert(cfg.LoadTokenFile(file), check.ErrorMatches, ".*unknown role root.*")
+	c.Assert(ioutil.WriteFile(file, []byte("admin\n"), 0o600), check.IsNil)
+	c.Assert(cfg.LoadTokenFile(file), check.ErrorMatches, ".*invalid token at .*tokens:1.*")
+	c.Assert(cfg.ParseCertRoles("ctl"), check.ErrorMatches, ".*invalid certificate role ctl.*")
+
+	// the tokens and the common names scoped to namespaces
+	cfg = &Config{}
+	content = "viewer view-token\nadmin team-a-token team-a\n"
+	c.Assert(ioutil.WriteFile(file, []byte(content), 0o600), check.IsNil)
+	c.Assert(cfg.LoadTokenFile(file), check.IsNil)
+	c.Assert(cfg.Tokens, check.DeepEquals, map[string]Role{
+		"view-token":   RoleViewer,
+		"team-a-token": RoleAdmin,
+	})
+	c.Assert(cfg.TokenNamespaces, check.DeepEquals, map[string]string{"team-a-token": "team-a"})
+	c.Assert(cfg.ParseCertRoles("dashboard:viewer,team-a-ctl:admin@team-a"), check.IsNil)
+	c.Assert(cfg.CertRoles, check.DeepEquals, map[string]Role{
+		"dashboard":  RoleViewer,
+		"team-a-ctl": RoleAdmin,
+	})
+	c.Assert(cfg.CertNamespaces, check.DeepEquals, map[string]string{"team-a-ctl": "team-a"})
+	c.Assert(ioutil.WriteFile(file, []byte("admin token team_a\n"), 0o600), check.IsNil)
+	c.Assert(cfg.LoadTokenFile(file), check.ErrorMatches, ".*bad namespace team_a.*")
+	c.Assert(cfg.ParseCertRoles("ctl:admin@"), check.ErrorMatches, ".*bad namespace.*")
+}
+
+func (s *authSuite) TestAuthenticate(c *check.C) {
+	defer testleak.AfterTest(c)()
+	cfg := &Config{
+		Tokens:    map[string]Role{"view-token": RoleViewer, "admin-token": RoleAdmin},
+		CertRoles: map[string]Role{"ctl": RoleAdmin},
+	}
+
+	req := httptest.NewRequest("GET", "/status", nil)
+	_, err := cfg.Authenticate(req)
+	c.Assert(err, check.ErrorMatches, ".*no valid token or client certificate.*")
+
+	req.Header.Set("Authorization", "Bearer view-token")
+	role, err := cfg.Authenticate(req)
+	c.Assert(err, check.IsNil)
+	c.Assert(role, check.Equals, RoleViewer)
+
+	req.Header.Set("Authorization", "bearer admin-token")
+	role, err = cfg.Authenticate(req)
+	c.Assert(err, check.IsNil)
+	c.Assert(role, check.Equals, RoleAdmin)
+
+	req.Header.Set("Authorization", "Bearer unknown")
+	_, err = cfg.Authenticate(req)
+	c.Assert(err, check.ErrorMatches, ".*invalid token.*")
+	req.Header.Set("Authorization", "Basic cm9vdDo=")
+	_, err = cfg.Authenticate(req)
+	c.Assert(err, check.ErrorMatches, ".*unsupported authorization scheme.*")
+
+	req = httptest.NewRequest("GET", "/status", nil)
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "ctl"}}
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	role, err = cfg.Authenticate(req)
+	c.Assert(err, check.IsNil)
+	c.Assert(role, check.Equals, RoleAdmin)
+
+	cert.Subject.CommonName = "other"
+	_, err = cfg.Authenticate(req)
+	c.Assert(err, check.NotNil)
+
+	cfg.TokenNamespaces = map[string]string{"admin-token": "team-a"}
+	cfg.CertNamespaces = map[string]string{"ctl": "team-b"}
+	req.Header.Set("Authorization", "Bearer admin-token")
+	role, namespace, err := cfg.AuthenticateScope(req)
+	c.Assert(err, check.IsNil)
+	c.Assert(role, check.Equals, RoleAdmin)
+	c.Assert(namespace, check.Equals, "team-a")
+	req.Header.Set("Authorization", "Bearer view-token")
+	_, namespace, err = cfg.AuthenticateScope(req)
+	c.Assert(err, check.IsNil)
+	c.Assert(namespace, check.Equals, "")
+	req.Header.Del("Authorization")
+	cert.Subject.CommonName = "ctl"
+	_, namespace, err = cfg.AuthenticateScope(req)
+	c.Assert(err, check.IsNil)
+	c.Assert(namespace, check.Equals, "team-b")
+}
+
+func (s *authSuite) TestInternalToken(c *check.C) {
+	defer testleak.AfterTest(c)()
+	var cfg *Config
+	c.Assert(cfg.InternalToken(), check.Equals, "")
+
+	file := filepath.Join(c.MkDir(), "tokens")
+	content := "admin admin-token\ninternal b-internal\nINTERNAL a-internal\n"
+	c.Assert(ioutil.WriteFile(file, []byte(content), 0o600), check.IsNil)
+	cfg = &Config{}
+	c.Assert(cfg.LoadTokenFile(file), check.IsNil)
+	// the internal tokens are not bound to roles
+	c.Assert(cfg.Tokens, check.DeepEquals, map[string]Role{"admin-token": RoleAdmin})
+	c.Assert(cfg.InternalToken(), check.Equals, "a-internal")
+
+	req := httptest.NewRequest("POST", "/capture/owner/runtime_state", nil)
+	c.Assert(cfg.AuthenticateInternal(req), check.ErrorMatches, ".*no internal token.*")
+	req.Header.Set("Authorization", "Bearer admin-token")
+	c.Assert(cfg.AuthenticateInternal(req), check.ErrorMatches, ".*invalid internal token.*")
+	req.Header.Set("Authorization", "Bearer b-internal")
+	c.Assert(cfg.AuthenticateInternal(req), check.IsNil)
+	// the internal tokens can't call the other APIs
+	_, err := cfg.Authenticate(req)
+	c.Assert(err, check.ErrorMatches, ".*invalid token.*")
+
+	c.Assert(ioutil.WriteFile(file, []byte("internal token team-a\n"), 0o600), check.IsNil)
+	c.Assert(cfg.LoadTokenFile(file), check.ErrorMatches, ".*internal token can't be scoped.*")
+}
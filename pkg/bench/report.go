@@ -0,0 +1,118 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"time"
+)
+
+// ChangefeedProgress is the progress of the changefeed replicating the
+// workload.
+type ChangefeedProgress struct {
+	// Count is the number of the rows replicated by the changefeed.
+	Count uint64
+	// Lag is the lag of the checkpoint of the changefeed.
+	Lag time.Duration
+}
+
+// Sample is the throughput and the lag measured in an interval.
+type Sample struct {
+	Stats
+	WrittenQPS    float64 `json:"written_qps"`
+	ReplicatedQPS float64 `json:"replicated_qps"`
+	Lag           string  `json:"lag"`
+}
+
+// Summary is the throughput and the lag measured in the whole run.
+type Summary struct {
+	Stats
+	Duration      string  `json:"duration"`
+	WrittenQPS    float64 `json:"written_qps"`
+	ReplicatedQPS float64 `json:"replicated_qps"`
+	AvgLag        string  `json:"avg_lag"`
+	MaxLag        string  `json:"max_lag"`
+}
+
+// Recorder measures the throughput of the workload and the changefeed by the
+// samples taken periodically.
+type Recorder struct {
+	start      time.Time
+	startCount uint64
+
+	lastTime  time.Time
+	lastStats Stats
+	lastCount uint64
+
+	samples  int
+	totalLag time.Duration
+	maxLag   time.Duration
+}
+
+// NewRecorder creates a Recorder, the rows replicated before the workload
+// starts are excluded by the initial progress.
+func NewRecorder(start time.Time, initial ChangefeedProgress) *Recorder {
+	return &Recorder{
+		start:      start,
+		startCount: initial.Count,
+		lastTime:   start,
+		lastCount:  initial.Count,
+	}
+}
+
+// Record takes a sample.
+func (r *Recorder) Record(now time.Time, stats Stats, progress ChangefeedProgress) *Sample {
+	seconds := now.Sub(r.lastTime).Seconds()
+	sample := &Sample{
+		Stats: stats,
+		Lag:   progress.Lag.String(),
+	}
+	if seconds > 0 {
+		sample.WrittenQPS = float64(stats.Rows-r.lastStats.Rows) / seconds
+		// the count is reset if a processor is moved to another capture
+		if progress.Count >= r.lastCount {
+			sample.ReplicatedQPS = float64(progress.Count-r.lastCount) / seconds
+		}
+	}
+	r.lastTime = now
+	r.lastStats = stats
+	r.lastCount = progress.Count
+
+	r.samples++
+	r.totalLag += progress.Lag
+	if progress.Lag > r.maxLag {
+		r.maxLag = progress.Lag
+	}
+	return sample
+}
+
+// Summary summarizes the samples.
+func (r *Recorder) Summary() *Summary {
+	duration := r.lastTime.Sub(r.start)
+	summary := &Summary{
+		Stats:    r.lastStats,
+		Duration: duration.String(),
+		MaxLag:   r.maxLag.String(),
+		AvgLag:   time.Duration(0).String(),
+	}
+	if seconds := duration.Seconds(); seconds > 0 {
+		summary.WrittenQPS = float64(r.lastStats.Rows) / seconds
+		if r.lastCount >= r.startCount {
+			summary.ReplicatedQPS = float64(r.lastCount-r.startCount) / seconds
+		}
+	}
+	if r.samples > 0 {
+		summary.AvgLag = (r.totalLag / time.Duration(r.samples)).String()
+	}
+	return summary
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type reportSuite struct{}
+
+var _ = check.Suite(&reportSuite{})
+
+func (s *reportSuite) TestRecorder(c *check.C) {
+	defer testleak.AfterTest(c)()
+	start := time.Now()
+	r := NewRecorder(start, ChangefeedProgress{Count: 100})
+
+	sample := r.Record(start.Add(10*time.Second), Stats{Rows: 1000, Txns: 100},
+		ChangefeedProgress{Count: 600, Lag: 2 * time.Second})
+	c.Assert(sample, check.DeepEquals, &Sample{
+		Stats:         Stats{Rows: 1000, Txns: 100},
+		WrittenQPS:    100,
+		ReplicatedQPS: 50,
+		Lag:           "2s",
+	})
+	sample = r.Record(start.Add(20*time.Second), Stats{Rows: 2000, Txns: 200, DDLs: 1},
+		ChangefeedProgress{Count: 2100, Lag: 4 * time.Second})
+	c.Assert(sample.WrittenQPS, check.Equals, float64(100))
+	c.Assert(sample.ReplicatedQPS, check.Equals, float64(150))
+
+	c.Assert(r.Summary(), check.DeepEquals, &Summary{
+		Stats:         Stats{Rows: 2000, Txns: 200, DDLs: 1},
+		Duration:      "20s",
+		WrittenQPS:    100,
+		ReplicatedQPS: 100,
+		AvgLag:        "3s",
+		MaxLag:        "4s",
+	})
+}
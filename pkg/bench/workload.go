@@ -0,0 +1,267 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/quotes"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+)
+
+// maxRowWidth is the max width of the padding column, it's the max length of
+// a VARCHAR column in utf8mb4.
+const maxRowWidth = 16383
+
+// Config is the config of a synthetic workload.
+type Config struct {
+	// Database is the database of the tables of the workload, it's dropped
+	// and created again in Prepare.
+	Database string
+	// Tables is the number of the tables.
+	Tables int
+	// RowWidth is the bytes of the padding column of a row.
+	RowWidth int
+	// QPS is the max rows written per second, 0 means no limit.
+	QPS int
+	// TxnSize is the number of the rows written in a transaction.
+	TxnSize int
+	// UpdateRatio is the ratio of the updates in the written rows, the rest
+	// are the inserts.
+	UpdateRatio float64
+	// DDLInterval is the interval of the DDLs, 0 means no DDL. The DDLs add
+	// and drop a column of the tables in turn.
+	DDLInterval time.Duration
+	// Workers is the number of the concurrent connections writing rows.
+	Workers int
+}
+
+// Validate checks the config.
+func (c *Config) Validate() error {
+	switch {
+	case c.Database == "":
+		return cerror.ErrBenchInvalidConfig.GenWithStack("the database is empty")
+	case c.Tables <= 0:
+		return cerror.ErrBenchInvalidConfig.GenWithStack("the number of tables %d must be positive", c.Tables)
+	case c.RowWidth <= 0 || c.RowWidth > maxRowWidth:
+		return cerror.ErrBenchInvalidConfig.GenWithStack("the row width %d must be in [1, %d]", c.RowWidth, maxRowWidth)
+	case c.QPS < 0:
+		return cerror.ErrBenchInvalidConfig.GenWithStack("the qps %d must not be negative", c.QPS)
+	case c.TxnSize <= 0:
+		return cerror.ErrBenchInvalidConfig.GenWithStack("the transaction size %d must be positive", c.TxnSize)
+	case c.UpdateRatio < 0 || c.UpdateRatio > 1:
+		return cerror.ErrBenchInvalidConfig.GenWithStack("the update ratio %v must be in [0, 1]", c.UpdateRatio)
+	case c.DDLInterval < 0:
+		return cerror.ErrBenchInvalidConfig.GenWithStack("the ddl interval %s must not be negative", c.DDLInterval)
+	case c.Workers <= 0:
+		return cerror.ErrBenchInvalidConfig.GenWithStack("the number of workers %d must be positive", c.Workers)
+	}
+	return nil
+}
+
+// Stats are the counts of the statements executed by a workload.
+type Stats struct {
+	Rows int64 `json:"rows"`
+	Txns int64 `json:"txns"`
+	DDLs int64 `json:"ddls"`
+}
+
+// Workload writes the synthetic rows and DDLs to the upstream.
+type Workload struct {
+	cfg     *Config
+	db      *sql.DB
+	limiter *rate.Limiter
+
+	rows int64
+	txns int64
+	ddls int64
+	// maxIDs are the max ids inserted to the tables, the updates pick the rows
+	// by them.
+	maxIDs []int64
+}
+
+// NewWorkload creates a Workload.
+func NewWorkload(db *sql.DB, cfg *Config) (*Workload, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if cfg.QPS > 0 {
+		burst := cfg.QPS
+		if burst < cfg.TxnSize {
+			// a transaction waits for all of its rows at once
+			burst = cfg.TxnSize
+		}
+		limiter = rate.NewLimiter(rate.Limit(cfg.QPS), burst)
+	}
+	return &Workload{
+		cfg:     cfg,
+		db:      db,
+		limiter: limiter,
+		maxIDs:  make([]int64, cfg.Tables),
+	}, nil
+}
+
+func (w *Workload) quoteTable(i int) string {
+	return quotes.QuoteSchema(w.cfg.Database, fmt.Sprintf("t%d", i))
+}
+
+// Prepare creates the database and the tables of the workload.
+func (w *Workload) Prepare(ctx context.Context) error {
+	stmts := []string{
+		"DROP DATABASE IF EXISTS " + quotes.QuoteName(w.cfg.Database),
+		"CREATE DATABASE " + quotes.QuoteName(w.cfg.Database),
+	}
+	for i := 0; i < w.cfg.Tables; i++ {
+		stmts = append(stmts, fmt.Sprintf(
+			"CREATE TABLE %s (id BIGINT PRIMARY KEY AUTO_INCREMENT, k BIGINT NOT NULL, pad VARCHAR(%d) NOT NULL, KEY (k))",
+			w.quoteTable(i), w.cfg.RowWidth))
+	}
+	for _, stmt := range stmts {
+		if _, err := w.db.ExecContext(ctx, stmt); err != nil {
+			return cerror.WrapError(cerror.ErrMySQLQueryError, errors.Annotate(err, stmt))
+		}
+	}
+	log.Info("bench tables prepared", zap.String("database", w.cfg.Database), zap.Int("tables", w.cfg.Tables))
+	return nil
+}
+
+// Run writes the workload until the context is done or an error occurs.
+func (w *Workload) Run(ctx context.Context) error {
+	errg, ctx := errgroup.WithContext(ctx)
+	for i := 0; i < w.cfg.Workers; i++ {
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)))
+		errg.Go(func() error {
+			for {
+				if err := w.limiter.WaitN(ctx, w.cfg.TxnSize); err != nil {
+					return errors.Trace(err)
+				}
+				if err := w.writeTxn(ctx, rnd); err != nil {
+					return errors.Trace(err)
+				}
+			}
+		})
+	}
+	if w.cfg.DDLInterval > 0 {
+		errg.Go(func() error {
+			return w.runDDLs(ctx)
+		})
+	}
+	err := errg.Wait()
+	if errors.Cause(err) == context.Canceled || errors.Cause(err) == context.DeadlineExceeded {
+		return nil
+	}
+	return err
+}
+
+func (w *Workload) writeTxn(ctx context.Context, rnd *rand.Rand) error {
+	tableIdx := rnd.Intn(w.cfg.Tables)
+	table := w.quoteTable(tableIdx)
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrMySQLTxnError, err)
+	}
+	var maxID int64
+	for i := 0; i < w.cfg.TxnSize; i++ {
+		pad := randomPad(rnd, w.cfg.RowWidth)
+		k := rnd.Int63()
+		if existed := atomic.LoadInt64(&w.maxIDs[tableIdx]); existed > 0 && rnd.Float64() < w.cfg.UpdateRatio {
+			_, err = tx.ExecContext(ctx, "UPDATE "+table+" SET k = ?, pad = ? WHERE id = ?", k, pad, rnd.Int63n(existed)+1)
+		} else {
+			var res sql.Result
+			res, err = tx.ExecContext(ctx, "INSERT INTO "+table+" (k, pad) VALUES (?, ?)", k, pad)
+			if err == nil {
+				var id int64
+				if id, err = res.LastInsertId(); err == nil && id > maxID {
+					maxID = id
+				}
+			}
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Warn("failed to rollback txn", zap.Error(rbErr))
+			}
+			return cerror.WrapError(cerror.ErrMySQLTxnError, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return cerror.WrapError(cerror.ErrMySQLTxnError, err)
+	}
+	for {
+		existed := atomic.LoadInt64(&w.maxIDs[tableIdx])
+		if maxID <= existed || atomic.CompareAndSwapInt64(&w.maxIDs[tableIdx], existed, maxID) {
+			break
+		}
+	}
+	atomic.AddInt64(&w.rows, int64(w.cfg.TxnSize))
+	atomic.AddInt64(&w.txns, 1)
+	return nil
+}
+
+func (w *Workload) runDDLs(ctx context.Context) error {
+	ticker := time.NewTicker(w.cfg.DDLInterval)
+	defer ticker.Stop()
+	// whether the tables have the extra column added by the DDLs
+	added := make([]bool, w.cfg.Tables)
+	next := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-ticker.C:
+		}
+		table := w.quoteTable(next)
+		stmt := "ALTER TABLE " + table + " ADD COLUMN extra INT NOT NULL DEFAULT 0"
+		if added[next] {
+			stmt = "ALTER TABLE " + table + " DROP COLUMN extra"
+		}
+		if _, err := w.db.ExecContext(ctx, stmt); err != nil {
+			return cerror.WrapError(cerror.ErrMySQLQueryError, errors.Annotate(err, stmt))
+		}
+		added[next] = !added[next]
+		next = (next + 1) % w.cfg.Tables
+		atomic.AddInt64(&w.ddls, 1)
+	}
+}
+
+// Stats returns the counts of the statements executed so far.
+func (w *Workload) Stats() Stats {
+	return Stats{
+		Rows: atomic.LoadInt64(&w.rows),
+		Txns: atomic.LoadInt64(&w.txns),
+		DDLs: atomic.LoadInt64(&w.ddls),
+	}
+}
+
+const padLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randomPad(rnd *rand.Rand, width int) string {
+	var b strings.Builder
+	b.Grow(width)
+	for i := 0; i < width; i++ {
+		b.WriteByte(padLetters[rnd.Intn(len(padLetters))])
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type workloadSuite struct{}
+
+var _ = check.Suite(&workloadSuite{})
+
+func newTestConfig() *Config {
+	return &Config{
+		Database: "bench",
+		Tables:   2,
+		RowWidth: 8,
+		TxnSize:  2,
+		Workers:  1,
+	}
+}
+
+func (s *workloadSuite) TestValidate(c *check.C) {
+	defer testleak.AfterTest(c)()
+	c.Assert(newTestConfig().Validate(), check.IsNil)
+
+	testCases := []struct {
+		update func(cfg *Config)
+		err    string
+	}{
+		{func(cfg *Config) { cfg.Database = "" }, ".*the database is empty.*"},
+		{func(cfg *Config) { cfg.Tables = 0 }, ".*the number of tables 0 must be positive.*"},
+		{func(cfg *Config) { cfg.RowWidth = maxRowWidth + 1 }, ".*the row width 16384 must be in.*"},
+		{func(cfg *Config) { cfg.QPS = -1 }, ".*the qps -1 must not be negative.*"},
+		{func(cfg *Config) { cfg.TxnSize = 0 }, ".*the transaction size 0 must be positive.*"},
+		{func(cfg *Config) { cfg.UpdateRatio = 1.5 }, ".*the update ratio 1.5 must be in.*"},
+		{func(cfg *Config) { cfg.DDLInterval = -1 }, ".*the ddl interval -1ns must not be negative.*"},
+		{func(cfg *Config) { cfg.Workers = 0 }, ".*the number of workers 0 must be positive.*"},
+	}
+	for _, tc := range testCases {
+		cfg := newTestConfig()
+		tc.update(cfg)
+		c.Assert(cfg.Validate(), check.ErrorMatches, tc.err)
+	}
+}
+
+func (s *workloadSuite) TestPrepareAndWrite(c *check.C) {
+	defer testleak.AfterTest(c)()
+	db, mock, err := sqlmock.New()
+	c.Assert(err, check.IsNil)
+	defer db.Close()
+	cfg := newTestConfig()
+	cfg.UpdateRatio = 1
+	cfg.Tables = 1
+	w, err := NewWorkload(db, cfg)
+	c.Assert(err, check.IsNil)
+
+	ctx := context.Background()
+	mock.ExpectExec("DROP DATABASE IF EXISTS `bench`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE DATABASE `bench`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE `bench`.`t0` .*VARCHAR\\(8\\).*").WillReturnResult(sqlmock.NewResult(0, 0))
+	c.Assert(w.Prepare(ctx), check.IsNil)
+
+	// the rows are inserted before any row exists, even all the rows are updates
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `bench`.`t0` \\(k, pad\\) VALUES").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO `bench`.`t0` \\(k, pad\\) VALUES").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	rnd := rand.New(rand.NewSource(0))
+	c.Assert(w.writeTxn(ctx, rnd), check.IsNil)
+	c.Assert(w.maxIDs[0], check.Equals, int64(2))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `bench`.`t0` SET k = \\?, pad = \\? WHERE id = \\?").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `bench`.`t0` SET k = \\?, pad = \\? WHERE id = \\?").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	c.Assert(w.writeTxn(ctx, rnd), check.IsNil)
+	c.Assert(w.Stats(), check.DeepEquals, Stats{Rows: 4, Txns: 2})
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+}
@@ -44,21 +44,32 @@ var defaultReplicaConfig = &ReplicaConfig{
 		Tp:          "table-number",
 		PollingTime: -1,
 	},
+	RateLimit:  &RateLimitConfig{},
+	WorkerPool: &WorkerPoolConfig{},
+	TableSkew:  &TableSkewConfig{},
+	FlowControl: &FlowControlConfig{
+		Window:      10,
+		MemoryQuota: 10 * 1024 * 1024 * 1024, // 10G
+	},
 }
 
 // ReplicaConfig represents some addition replication config for a changefeed
 type ReplicaConfig replicaConfig
 
 type replicaConfig struct {
-	CaseSensitive    bool             `toml:"case-sensitive" json:"case-sensitive"`
-	EnableOldValue   bool             `toml:"enable-old-value" json:"enable-old-value"`
-	ForceReplicate   bool             `toml:"force-replicate" json:"force-replicate"`
-	CheckGCSafePoint bool             `toml:"check-gc-safe-point" json:"check-gc-safe-point"`
-	Filter           *FilterConfig    `toml:"filter" json:"filter"`
-	Mounter          *MounterConfig   `toml:"mounter" json:"mounter"`
-	Sink             *SinkConfig      `toml:"sink" json:"sink"`
-	Cyclic           *CyclicConfig    `toml:"cyclic-replication" json:"cyclic-replication"`
-	Scheduler        *SchedulerConfig `toml:"scheduler" json:"scheduler"`
+	CaseSensitive    bool               `toml:"case-sensitive" json:"case-sensitive"`
+	EnableOldValue   bool               `toml:"enable-old-value" json:"enable-old-value"`
+	ForceReplicate   bool               `toml:"force-replicate" json:"force-replicate"`
+	CheckGCSafePoint bool               `toml:"check-gc-safe-point" json:"check-gc-safe-point"`
+	Filter           *FilterConfig      `toml:"filter" json:"filter"`
+	Mounter          *MounterConfig     `toml:"mounter" json:"mounter"`
+	Sink             *SinkConfig        `toml:"sink" json:"sink"`
+	Cyclic           *CyclicConfig      `toml:"cyclic-replication" json:"cyclic-replication"`
+	Scheduler        *SchedulerConfig   `toml:"scheduler" json:"scheduler"`
+	RateLimit        *RateLimitConfig   `toml:"rate-limit" json:"rate-limit"`
+	WorkerPool       *WorkerPoolConfig  `toml:"worker-pool" json:"worker-pool"`
+	TableSkew        *TableSkewConfig   `toml:"table-skew" json:"table-skew"`
+	FlowControl      *FlowControlConfig `toml:"flow-control" json:"flow-control"`
 }
 
 // Marshal returns the json marshal format of a ReplicationConfig
@@ -0,0 +1,27 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// FlowControlConfig represents the bound of the events between the pullers
+// and the sink of a changefeed on each capture, the pullers are paused once
+// either limit is reached.
+type FlowControlConfig struct {
+	// Window is the max minutes the pullers of a table run ahead of the
+	// checkpoint of the table, in the physical time of the commit ts, 0 means
+	// unlimited.
+	Window int `toml:"window" json:"window"`
+	// MemoryQuota is the max bytes of the events pulled but not flushed by the
+	// sink on a capture, 0 means unlimited.
+	MemoryQuota uint64 `toml:"memory-quota" json:"memory-quota"`
+}
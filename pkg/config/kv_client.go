@@ -0,0 +1,87 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"github.com/pingcap/errors"
+)
+
+// The gRPC compression algorithms of the kv client.
+const (
+	GRPCCompressionNone   = "none"
+	GRPCCompressionGzip   = "gzip"
+	GRPCCompressionSnappy = "snappy"
+	GRPCCompressionZstd   = "zstd"
+)
+
+// KVClientConfig represents the settings of the gRPC connections between the
+// kv client of a capture and the TiKV stores.
+type KVClientConfig struct {
+	// GRPCCompression is the compression algorithm of the event streams. The
+	// requests are compressed by it, and TiKV compresses the events by it if
+	// TiKV supports it.
+	GRPCCompression string `toml:"grpc-compression" json:"grpc-compression"`
+	// GRPCWindowSize is the initial window size of a stream
+	GRPCWindowSize int32 `toml:"grpc-window-size" json:"grpc-window-size"`
+	// GRPCConnWindowSize is the initial window size of a connection
+	GRPCConnWindowSize int32 `toml:"grpc-conn-window-size" json:"grpc-conn-window-size"`
+	// Zone is the zone of the capture. If it's set, the event feeds of the
+	// regions whose leaders are out of the zone are requested from the
+	// followers in the zone, if the CDC components of TiKV support it.
+	Zone string `toml:"zone" json:"zone"`
+	// ZoneLabel is the key of the store label of the zones
+	ZoneLabel string `toml:"zone-label" json:"zone-label"`
+	// DedupMaxKeys is the max number of the keys recorded by the puller of a
+	// table to drop the events delivered again after the regions reconnect,
+	// 0 disables the deduplication.
+	DedupMaxKeys int `toml:"dedup-max-keys" json:"dedup-max-keys"`
+}
+
+// Validate checks the settings of the kv client.
+func (c *KVClientConfig) Validate() error {
+	switch c.GRPCCompression {
+	case GRPCCompressionNone, GRPCCompressionGzip, GRPCCompressionSnappy, GRPCCompressionZstd:
+	default:
+		return errors.Errorf("unknown grpc compression %q, it must be one of none, gzip, snappy and zstd", c.GRPCCompression)
+	}
+	// gRPC ignores the window sizes less than 64KB
+	if c.GRPCWindowSize < 64*1024 || c.GRPCConnWindowSize < 64*1024 {
+		return errors.Errorf("the grpc window sizes %d and %d must not be less than 64KB",
+			c.GRPCWindowSize, c.GRPCConnWindowSize)
+	}
+	if c.DedupMaxKeys < 0 {
+		return errors.Errorf("the dedup max keys %d is negative", c.DedupMaxKeys)
+	}
+	if c.Zone != "" && c.ZoneLabel == "" {
+		return errors.New("the zone label is empty")
+	}
+	return nil
+}
+
+var kvClientConfig *KVClientConfig
+
+// GetKVClientConfig returns the process-local kv client config, it's nil if
+// the defaults are used.
+func GetKVClientConfig() *KVClientConfig {
+	mu.Lock()
+	defer mu.Unlock()
+	return kvClientConfig
+}
+
+// SetKVClientConfig sets the process-local kv client config
+func SetKVClientConfig(config *KVClientConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	kvClientConfig = config
+}
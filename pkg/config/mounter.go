@@ -13,7 +13,63 @@
 
 package config
 
+import (
+	"github.com/pingcap/errors"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
 // MounterConfig represents mounter config for a changefeed
 type MounterConfig struct {
 	WorkerNum int `toml:"worker-num" json:"worker-num"`
+	// LargeRowThreshold is the size in bytes of the rows reported as large rows
+	// with their tables, 0 means the default threshold.
+	LargeRowThreshold int64 `toml:"large-row-threshold" json:"large-row-threshold,omitempty"`
+	// SkipEvent is the opt-in policy to skip the row events which can't be
+	// mounted, nil means the changefeed fails on them.
+	SkipEvent *SkipEventConfig `toml:"skip-event" json:"skip-event,omitempty"`
+}
+
+// SkippableErrorCodes are the RFC codes of the errors of mounting a row event
+// which can be skipped. They are caused by the corrupted upstream data, so
+// retrying the event or restarting the changefeed doesn't help.
+var SkippableErrorCodes = []string{
+	string(cerror.ErrCodecDecode.RFCCode()),
+	string(cerror.ErrDecodeRowToDatum.RFCCode()),
+	string(cerror.ErrDatumUnflatten.RFCCode()),
+	string(cerror.ErrInvalidRecordKey.RFCCode()),
+}
+
+// SkipEventConfig represents the policy to skip the row events failed to be
+// mounted by the listed errors, so one bad row doesn't halt the replication.
+// The skipped events are dumped to the quarantine files in the data dir of
+// the captures and reported as the warnings of the changefeed.
+type SkipEventConfig struct {
+	// ErrorCodes are the RFC codes of the errors to skip, each one must be
+	// in SkippableErrorCodes, such as "CDC:ErrDecodeRowToDatum".
+	ErrorCodes []string `toml:"error-codes" json:"error-codes"`
+	// MaxRetries is the number of retries before an event is skipped.
+	MaxRetries int `toml:"max-retries" json:"max-retries"`
+}
+
+// Validate checks the skip policy, it's valid to be nil.
+func (c *SkipEventConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.MaxRetries < 0 {
+		return errors.Errorf("invalid max retries %d of the skip policy", c.MaxRetries)
+	}
+	for _, code := range c.ErrorCodes {
+		skippable := false
+		for _, s := range SkippableErrorCodes {
+			if code == s {
+				skippable = true
+				break
+			}
+		}
+		if !skippable {
+			return errors.Errorf("error %s can't be skipped, it must be one of %v", code, SkippableErrorCodes)
+		}
+	}
+	return nil
 }
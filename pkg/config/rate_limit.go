@@ -0,0 +1,27 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// RateLimitConfig represents the throughput limit of a changefeed, which is
+// shared evenly by the captures replicating the changefeed. Zero means
+// unlimited.
+type RateLimitConfig struct {
+	RowsPerSecond  uint64 `toml:"rows-per-second" json:"rows-per-second"`
+	BytesPerSecond uint64 `toml:"bytes-per-second" json:"bytes-per-second"`
+	// CPUShares is the relative weight of the changefeed when the changefeeds
+	// on a capture compete for the CPU, the mounter and encoder workers of the
+	// changefeed are paused once it uses more than its share. Zero means the
+	// default 1024.
+	CPUShares uint64 `toml:"cpu-shares" json:"cpu-shares"`
+}
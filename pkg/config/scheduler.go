@@ -13,9 +13,29 @@
 
 package config
 
+import "time"
+
+// defaultCatchUpLag is the default lag of the tables catching up.
+const defaultCatchUpLag = 10 * time.Minute
+
 // SchedulerConfig represents scheduler config for a changefeed
 type SchedulerConfig struct {
 	Tp string `toml:"type" json:"type"`
 	// PollingTime represents the polling cycle of checking the skewness of workload and try to do schedule if needed
 	PollingTime int `toml:"polling-time" json:"polling-time"`
+	// MaxCatchUpTables is the max number of the tables catching up on each
+	// capture at the same time, the other tables catching up are dispatched
+	// after some of them catch up. 0 means no limit.
+	MaxCatchUpTables int `toml:"max-catch-up-tables" json:"max-catch-up-tables"`
+	// CatchUpLag is the minutes the resolved ts of a table lags behind the
+	// current time when it's catching up, 0 means the default 10 minutes.
+	CatchUpLag int `toml:"catch-up-lag" json:"catch-up-lag"`
+}
+
+// GetCatchUpLag returns the lag of the tables catching up.
+func (c *SchedulerConfig) GetCatchUpLag() time.Duration {
+	if c.CatchUpLag <= 0 {
+		return defaultCatchUpLag
+	}
+	return time.Duration(c.CatchUpLag) * time.Minute
 }
@@ -0,0 +1,27 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// ServerConfig represents the capture server settings which can be reloaded
+// at runtime without restarting the capture. The zero values mean the settings
+// are left unchanged.
+type ServerConfig struct {
+	LogLevel string `toml:"log-level" json:"log-level,omitempty"`
+	// GcTTL is the TTL of the cdc service gc safepoint, specified in seconds
+	GcTTL int64 `toml:"gc-ttl" json:"gc-ttl,omitempty"`
+	// Sorter limits the memory used by the unified sorter of the capture. The
+	// memory limits take effect immediately, the other settings take effect
+	// for the tables sorted afterwards. NumWorkerPoolGoroutine can't be reloaded.
+	Sorter *SorterConfig `toml:"sorter" json:"sorter,omitempty"`
+}
@@ -17,6 +17,106 @@ package config
 type SinkConfig struct {
 	DispatchRules []*DispatchRule `toml:"dispatchers" json:"dispatchers"`
 	Protocol      string          `toml:"protocol" json:"protocol"`
+	// SchemaChangeTopic is the topic the DDL events are sent to instead of the
+	// data topic, it's only supported by the Kafka sink.
+	SchemaChangeTopic string `toml:"schema-change-topic" json:"schema-change-topic,omitempty"`
+	// GeneratedColumns is how the stored generated columns are replicated,
+	// see GeneratedColumnsEmit and GeneratedColumnsStrip. By default the MQ
+	// sinks emit them and the MySQL sink strips them. The virtual generated
+	// columns are never replicated, since their values are not stored.
+	GeneratedColumns string       `toml:"generated-columns" json:"generated-columns,omitempty"`
+	Spill            *SpillConfig `toml:"spill" json:"spill,omitempty"`
+	// MessageKey is what goes into the keys of the MQ messages, it's
+	// independent of the partition dispatcher. The keys are decided by the
+	// protocol if it's nil.
+	MessageKey *MessageKeyConfig `toml:"message-key" json:"message-key,omitempty"`
+	// LogCompaction makes the data topic usable as a log-compacted changelog,
+	// the deleted rows are sent as tombstones, i.e. the messages with null
+	// values, and the key of a row is never changed by an update, which is
+	// split into a delete of the old key and an insert of the new key. The
+	// message keys are the handle keys if MessageKey is nil.
+	LogCompaction bool `toml:"log-compaction" json:"log-compaction,omitempty"`
+	// BootstrapMessage makes the MQ sinks send a bootstrap DDL message of each
+	// table, i.e. a CREATE TABLE IF NOT EXISTS of the schema at the start ts,
+	// before the rows of the table when the changefeed starts or the table is
+	// added, so the consumers can create the schemas without querying TiDB.
+	BootstrapMessage bool `toml:"bootstrap-message" json:"bootstrap-message,omitempty"`
+	// OversizedRow is how the MQ sinks handle the rows whose messages exceed
+	// max-message-bytes, see OversizedRowFail, OversizedRowSplitColumns,
+	// OversizedRowTruncate, OversizedRowDLQ and OversizedRowClaimCheck. The
+	// changefeed fails by default.
+	OversizedRow string `toml:"oversized-row" json:"oversized-row,omitempty"`
+	// OversizedRowDLQTopic is the topic the oversized rows are sent to by
+	// OversizedRowDLQ, it's only supported by the Kafka sink.
+	OversizedRowDLQTopic string `toml:"oversized-row-dlq-topic" json:"oversized-row-dlq-topic,omitempty"`
+	// ClaimCheckStorageURI is the external storage the oversized rows are
+	// offloaded to by OversizedRowClaimCheck, e.g. s3://bucket/prefix. The
+	// objects are never deleted by TiCDC, a lifecycle rule of the bucket should
+	// expire them after the consumers have read them.
+	ClaimCheckStorageURI string `toml:"claim-check-storage-uri" json:"claim-check-storage-uri,omitempty"`
+}
+
+const (
+	// GeneratedColumnsEmit sends the stored generated columns in the events of
+	// the MQ sinks, and writes them in the SQL of the MySQL sink, which is used
+	// if they are normal columns in the downstream.
+	GeneratedColumnsEmit = "emit"
+	// GeneratedColumnsStrip removes the stored generated columns from the
+	// events of the MQ sinks and the SQL of the MySQL sink.
+	GeneratedColumnsStrip = "strip"
+)
+
+const (
+	// OversizedRowFail fails the changefeed.
+	OversizedRowFail = "fail"
+	// OversizedRowSplitColumns splits the columns of the row into the messages
+	// within the limit, each of which has the handle key columns.
+	OversizedRowSplitColumns = "split-columns"
+	// OversizedRowTruncate truncates the long string and binary values of the
+	// row, the truncated values end with a marker.
+	OversizedRowTruncate = "truncate"
+	// OversizedRowDLQ sends the row to the DLQ topic instead of the data topic.
+	OversizedRowDLQ = "dlq"
+	// OversizedRowClaimCheck offloads the message of the row to the external
+	// storage, and sends a claim check message referencing it instead.
+	OversizedRowClaimCheck = "claim-check"
+)
+
+// MessageKeyConfig represents the keys of the MQ messages of the rows, it's
+// only supported by the protocols sending a message per row.
+type MessageKeyConfig struct {
+	// Type is one of MessageKeyHandleKey, MessageKeyUniqueKeys,
+	// MessageKeyTable and MessageKeyTemplate.
+	Type string `toml:"type" json:"type"`
+	// Template is the template of the keys of MessageKeyTemplate, the
+	// placeholders {schema}, {table}, {handle-key} and {column:<name>} are
+	// replaced by the values of the row.
+	Template string `toml:"template" json:"template,omitempty"`
+}
+
+const (
+	// MessageKeyHandleKey puts the values of the handle key columns in the keys.
+	MessageKeyHandleKey = "handle-key"
+	// MessageKeyUniqueKeys puts the values of all the primary key and unique
+	// key columns in the keys.
+	MessageKeyUniqueKeys = "unique-keys"
+	// MessageKeyTable puts the schema and the table name in the keys.
+	MessageKeyTable = "table"
+	// MessageKeyTemplate builds the keys by a template.
+	MessageKeyTemplate = "template"
+)
+
+// SpillConfig represents how the table sinks spill the pending rows to disk
+// when the downstream stalls.
+type SpillConfig struct {
+	Enable bool `toml:"enable" json:"enable"`
+	// MemoryRowsLimit is the max number of the pending rows kept in memory by a table sink
+	MemoryRowsLimit int `toml:"memory-rows-limit" json:"memory-rows-limit"`
+	// MaxDiskSize is the max bytes spilled to disk by a table sink, once it's
+	// reached the table stops receiving rows until the spilled rows are drained,
+	// and at most MemoryRowsLimit rows more are kept in memory, or more for a
+	// single transaction exceeding it
+	MaxDiskSize int64 `toml:"max-disk-size" json:"max-disk-size"`
 }
 
 // DispatchRule represents partition rule for a table
@@ -27,6 +27,10 @@ type SorterConfig struct {
 	MaxMemoryConsumption uint64 `toml:"max-memory-consumption" json:"max-memory-consumption"`
 	// the size of workerpool
 	NumWorkerPoolGoroutine int `toml:"num-workerpool-goroutine" json:"num-workerpool-goroutine"`
+	// EnableResume keeps the sorted events of the tables in the data dir, so
+	// the tables are resumed from them after the capture restarts. It can't
+	// be reloaded.
+	EnableResume bool `toml:"enable-resume" json:"enable-resume"`
 }
 
 var (
@@ -0,0 +1,25 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// TableSkewConfig represents the guard against the tables whose checkpoints
+// are stuck while the other tables advance.
+type TableSkewConfig struct {
+	// MaxLag is the max minutes the checkpoint of a table lags behind the
+	// most advanced table of the processor, 0 means the guard is disabled.
+	MaxLag int `toml:"max-lag" json:"max-lag"`
+	// Pause stops the changefeed with an error instead of warning if a table
+	// lags behind too much.
+	Pause bool `toml:"pause" json:"pause"`
+}
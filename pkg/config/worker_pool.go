@@ -0,0 +1,27 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// WorkerPoolConfig represents the worker pools of a changefeed. The mounter
+// and the sink workers always belong to the changefeed, and their numbers are
+// set by mounter.worker-num and the sink uri. The sorter workers are shared by
+// the changefeeds on a capture unless Isolated is set.
+type WorkerPoolConfig struct {
+	// Isolated gives the changefeed its own sorter worker pools, so the slow
+	// sorting of its events can't delay the other changefeeds.
+	Isolated bool `toml:"isolated" json:"isolated"`
+	// SorterWorkerNum is the number of the Goroutines of the isolated sorter
+	// worker pools, 0 means the same as the shared ones.
+	SorterWorkerNum int `toml:"sorter-worker-num" json:"sorter-worker-num"`
+}
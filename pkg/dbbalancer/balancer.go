@@ -0,0 +1,242 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dbbalancer balances the connections to the downstream databases
+// among the endpoints listed in the URI, for example
+// `mysql://root@tidb-1:4000,tidb-2:4000/`, or resolved from the DNS SRV
+// record of the host if the URI has `dns-srv=true`. A connection is dialed to
+// the endpoints in turn, and the endpoints failed to be dialed are skipped for
+// a while, so the connections fail over to the healthy endpoints without an
+// external proxy.
+package dbbalancer
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dmysql "github.com/go-sql-driver/mysql"
+	"github.com/pingcap/log"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPort = "4000"
+	// downDuration is the duration an endpoint failed to be dialed is skipped.
+	downDuration = 10 * time.Second
+	// srvRefreshInterval is the interval of resolving the SRV record again.
+	srvRefreshInterval = time.Minute
+	// defaultDialTimeout is the timeout of dialing an endpoint, it's shorter
+	// than the timeout of the driver so the other endpoints can be tried.
+	defaultDialTimeout = 5 * time.Second
+)
+
+var lookupSRV = net.LookupSRV
+
+// ParseEndpoints returns the addresses of the endpoints listed in the host of
+// the URI separated by `,`, the port is 4000 if it's omitted.
+func ParseEndpoints(u *url.URL) ([]string, error) {
+	if u.Host == "" {
+		return nil, cerror.ErrSinkURIInvalid.GenWithStack("no endpoint in the URI")
+	}
+	hosts := strings.Split(u.Host, ",")
+	addrs := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			return nil, cerror.ErrSinkURIInvalid.GenWithStack("empty endpoint in %s", u.Host)
+		}
+		addr, err := withDefaultPort(host)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+func withDefaultPort(host string) (string, error) {
+	if _, port, err := net.SplitHostPort(host); err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return "", cerror.ErrSinkURIInvalid.GenWithStack("invalid port of endpoint %s", host)
+		}
+		return host, nil
+	}
+	// the host without port, an IPv6 address must be bracketed
+	return net.JoinHostPort(strings.Trim(host, "[]"), defaultPort), nil
+}
+
+// Balancer dials the connections to the endpoints in turn.
+type Balancer struct {
+	srvName     string
+	dialTimeout time.Duration
+
+	mu        sync.Mutex
+	addrs     []string
+	resolved  time.Time
+	next      int
+	downUntil map[string]time.Time
+}
+
+// New creates a balancer of the endpoints of the URI.
+func New(u *url.URL) (*Balancer, error) {
+	b := &Balancer{
+		dialTimeout: defaultDialTimeout,
+		downUntil:   make(map[string]time.Time),
+	}
+	if s := u.Query().Get("timeout"); s != "" {
+		timeout, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrSinkURIInvalid, err)
+		}
+		if timeout < b.dialTimeout {
+			b.dialTimeout = timeout
+		}
+	}
+	if strings.EqualFold(u.Query().Get("dns-srv"), "true") {
+		if strings.ContainsAny(u.Host, ",:") {
+			return nil, cerror.ErrSinkURIInvalid.GenWithStack(
+				"the host of the URI with dns-srv must be a domain name without port")
+		}
+		b.srvName = u.Host
+		if err := b.resolve(); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	addrs, err := ParseEndpoints(u)
+	if err != nil {
+		return nil, err
+	}
+	b.addrs = addrs
+	return b, nil
+}
+
+// resolve resolves the endpoints from the SRV record, it must be called with
+// the lock held except in New.
+func (b *Balancer) resolve() error {
+	_, records, err := lookupSRV("", "", b.srvName)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrMySQLConnectionError, err)
+	}
+	if len(records) == 0 {
+		return cerror.ErrMySQLConnectionError.GenWithStack("no SRV record of %s", b.srvName)
+	}
+	addrs := make([]string, 0, len(records))
+	for _, r := range records {
+		addrs = append(addrs, net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port))))
+	}
+	b.addrs = addrs
+	b.resolved = time.Now()
+	return nil
+}
+
+// candidates returns the endpoints to be dialed in order, the healthy ones
+// first starting from the next one in turn.
+func (b *Balancer) candidates() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.srvName != "" && time.Since(b.resolved) > srvRefreshInterval {
+		if err := b.resolve(); err != nil {
+			// keep the endpoints resolved before
+			log.Warn("resolve the SRV record of the downstream failed",
+				zap.String("name", b.srvName), zap.Error(err))
+		}
+	}
+	now := time.Now()
+	healthy := make([]string, 0, len(b.addrs))
+	var down []string
+	for i := range b.addrs {
+		addr := b.addrs[(b.next+i)%len(b.addrs)]
+		if now.Before(b.downUntil[addr]) {
+			down = append(down, addr)
+		} else {
+			healthy = append(healthy, addr)
+		}
+	}
+	b.next = (b.next + 1) % len(b.addrs)
+	// the down endpoints are still tried if all the healthy ones fail
+	return append(healthy, down...)
+}
+
+func (b *Balancer) markDown(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.downUntil[addr] = time.Now().Add(downDuration)
+}
+
+func (b *Balancer) markUp(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.downUntil, addr)
+}
+
+// DialContext dials a connection to one of the endpoints, the address is
+// ignored.
+func (b *Balancer) DialContext(ctx context.Context, _ string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: b.dialTimeout}
+	var lastErr error
+	for _, addr := range b.candidates() {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			b.markUp(addr)
+			return conn, nil
+		}
+		log.Warn("dial the downstream endpoint failed, try the next one",
+			zap.String("addr", addr), zap.Error(err))
+		b.markDown(addr)
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// Addrs returns the endpoints of the balancer.
+func (b *Balancer) Addrs() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.addrs...)
+}
+
+// Resolve returns the network and the address of the DSN of the driver
+// connecting to the endpoints of the URI. The URI of a single endpoint is
+// connected by tcp directly, otherwise the dial function of a balancer is
+// registered to the driver as the network named by name.
+func Resolve(u *url.URL, name string) (network string, addr string, err error) {
+	if !strings.EqualFold(u.Query().Get("dns-srv"), "true") {
+		addrs, err := ParseEndpoints(u)
+		if err != nil {
+			return "", "", err
+		}
+		if len(addrs) == 1 {
+			return "tcp", addrs[0], nil
+		}
+	}
+	b, err := New(u)
+	if err != nil {
+		return "", "", err
+	}
+	network = "cdc-balancer-" + name
+	dmysql.RegisterDialContext(network, b.DialContext)
+	log.Info("balance the connections to the downstream endpoints",
+		zap.String("network", network), zap.Strings("endpoints", b.Addrs()))
+	// the address is only used as the server name of TLS and in the logs of the driver
+	return network, b.Addrs()[0], nil
+}
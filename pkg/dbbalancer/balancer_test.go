@@ -0,0 +1,116 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbbalancer
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type balancerSuite struct{}
+
+var _ = check.Suite(&balancerSuite{})
+
+func (s *balancerSuite) TestParseEndpoints(c *check.C) {
+	defer testleak.AfterTest(c)()
+	testCases := []struct {
+		uri   string
+		addrs []string
+		err   string
+	}{
+		{uri: "mysql://root@127.0.0.1/", addrs: []string{"127.0.0.1:4000"}},
+		{uri: "mysql://root@tidb-1:3306,tidb-2:4000/", addrs: []string{"tidb-1:3306", "tidb-2:4000"}},
+		{uri: "mysql://root@tidb-1,tidb-2/", addrs: []string{"tidb-1:4000", "tidb-2:4000"}},
+		{uri: "mysql://root@tidb-1,,tidb-2/", err: ".*empty endpoint.*"},
+		{uri: "mysql:///", err: ".*no endpoint.*"},
+	}
+	for _, tc := range testCases {
+		u, err := url.Parse(tc.uri)
+		c.Assert(err, check.IsNil)
+		addrs, err := ParseEndpoints(u)
+		if tc.err != "" {
+			c.Assert(err, check.ErrorMatches, tc.err)
+			continue
+		}
+		c.Assert(err, check.IsNil)
+		c.Assert(addrs, check.DeepEquals, tc.addrs, check.Commentf("%s", tc.uri))
+	}
+}
+
+func (s *balancerSuite) TestDialFailover(c *check.C) {
+	defer testleak.AfterTest(c)()
+	l1, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer l1.Close()
+	l2, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	addr2 := l2.Addr().String()
+	c.Assert(l2.Close(), check.IsNil)
+
+	u, err := url.Parse("mysql://root@" + l1.Addr().String() + "," + addr2 + "/")
+	c.Assert(err, check.IsNil)
+	b, err := New(u)
+	c.Assert(err, check.IsNil)
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		conn, err := b.DialContext(ctx, "")
+		c.Assert(err, check.IsNil)
+		c.Assert(conn.RemoteAddr().String(), check.Equals, l1.Addr().String())
+		c.Assert(conn.Close(), check.IsNil)
+	}
+	// the closed endpoint is skipped after it fails once
+	c.Assert(b.candidates(), check.DeepEquals, []string{l1.Addr().String(), addr2})
+
+	c.Assert(l1.Close(), check.IsNil)
+	_, err = b.DialContext(ctx, "")
+	c.Assert(err, check.NotNil)
+}
+
+func (s *balancerSuite) TestResolveSRV(c *check.C) {
+	defer testleak.AfterTest(c)()
+	defer func() { lookupSRV = net.LookupSRV }()
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		c.Assert(name, check.Equals, "tidb.example.com")
+		return name, []*net.SRV{
+			{Target: "tidb-1.example.com.", Port: 4000},
+			{Target: "tidb-2.example.com.", Port: 4001},
+		}, nil
+	}
+	u, err := url.Parse("mysql://root@tidb.example.com/?dns-srv=true")
+	c.Assert(err, check.IsNil)
+	network, addr, err := Resolve(u, "test")
+	c.Assert(err, check.IsNil)
+	c.Assert(network, check.Equals, "cdc-balancer-test")
+	c.Assert(addr, check.Equals, "tidb-1.example.com:4000")
+
+	u, err = url.Parse("mysql://root@tidb.example.com:4000/?dns-srv=true")
+	c.Assert(err, check.IsNil)
+	_, err = New(u)
+	c.Assert(err, check.ErrorMatches, ".*must be a domain name without port.*")
+
+	// a single endpoint is connected directly
+	u, err = url.Parse("mysql://root@127.0.0.1:3306/")
+	c.Assert(err, check.IsNil)
+	network, addr, err = Resolve(u, "test")
+	c.Assert(err, check.IsNil)
+	c.Assert(network, check.Equals, "tcp")
+	c.Assert(addr, check.Equals, "127.0.0.1:3306")
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/pingcap/ticdc/pkg/util"
+)
+
+// DefaultMinFreeBytes is the default min free space of the data dir.
+const DefaultMinFreeBytes = 1 << 30
+
+// riskyFSTypes are the file systems which work but are not recommended for
+// the data dir.
+var riskyFSTypes = map[string]string{
+	"tmpfs": "the files are stored in memory",
+	"nfs":   "the network file system is slow and unreliable",
+	"cifs":  "the network file system is slow and unreliable",
+	"smb2":  "the network file system is slow and unreliable",
+	"fuse":  "the user space file system may be slow",
+}
+
+// DirHealth is the result of the health checks of the data dir.
+type DirHealth struct {
+	Dir       string `json:"dir"`
+	FSType    string `json:"fs-type,omitempty"`
+	FreeBytes uint64 `json:"free-bytes"`
+	// Warnings are the risks which don't fail the checks.
+	Warnings []string `json:"warnings,omitempty"`
+	// Error is the reason why the checks fail, it's empty if the data dir is
+	// healthy.
+	Error string `json:"error,omitempty"`
+	// MemoryFallback is set if the unified sorter falls back to the memory
+	// sorter because the data dir is unhealthy.
+	MemoryFallback bool `json:"memory-fallback,omitempty"`
+}
+
+// Healthy returns whether the checks pass.
+func (h *DirHealth) Healthy() bool {
+	return h.Error == ""
+}
+
+// CheckDir creates the data dir if it doesn't exist, and checks that it's
+// writable and has at least minFreeBytes free space.
+func CheckDir(dir string, minFreeBytes int64) *DirHealth {
+	h := &DirHealth{Dir: dir}
+	dirs := []string{dir}
+	for _, component := range components {
+		dirs = append(dirs, filepath.Join(dir, component))
+	}
+	for _, d := range dirs {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			h.Error = fmt.Sprintf("create dir %s failed: %s", d, err)
+			return h
+		}
+		if err := util.IsDirAndWritable(d); err != nil {
+			h.Error = fmt.Sprintf("dir %s is not writable: %s", d, err)
+			return h
+		}
+	}
+	free, fsType, err := statfs(dir)
+	if err != nil {
+		h.Warnings = append(h.Warnings, fmt.Sprintf("get the free space failed: %s", err))
+		return h
+	}
+	h.FreeBytes = free
+	h.FSType = fsType
+	if reason, ok := riskyFSTypes[fsType]; ok {
+		h.Warnings = append(h.Warnings, fmt.Sprintf("%s is not recommended, %s", fsType, reason))
+	}
+	if minFreeBytes > 0 && free < uint64(minFreeBytes) {
+		h.Error = fmt.Sprintf("the free space %d bytes is less than %d bytes", free, minFreeBytes)
+	}
+	return h
+}
+
+var memoryFallback int32
+
+// SetMemoryFallback sets whether the unified sorter falls back to the memory
+// sorter, it's set if the data dir is unhealthy.
+func SetMemoryFallback(fallback bool) {
+	var v int32
+	if fallback {
+		v = 1
+	}
+	atomic.StoreInt32(&memoryFallback, v)
+}
+
+// MemoryFallback returns whether the unified sorter falls back to the memory
+// sorter.
+func MemoryFallback() bool {
+	return atomic.LoadInt32(&memoryFallback) == 1
+}
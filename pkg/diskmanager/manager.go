@@ -0,0 +1,388 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// The components storing files in the data dir, each one has a sub directory.
+const (
+	// ComponentSorter stores the spill files of the unified sorter
+	ComponentSorter = "sorter"
+	// ComponentSorterResume stores the resume logs of the unified sorter,
+	// which are kept across the restarts of the capture
+	ComponentSorterResume = "sorter-resume"
+	// ComponentRedo stores the redo logs
+	ComponentRedo = "redo"
+	// ComponentSinkSpill stores the spill queues of the table sinks
+	ComponentSinkSpill = "sink-spill"
+	// ComponentQuarantine stores the row events skipped by the changefeeds,
+	// which are the audit records and never removed by the capture
+	ComponentQuarantine = "quarantine"
+)
+
+var components = []string{
+	ComponentSorter, ComponentSorterResume, ComponentRedo, ComponentSinkSpill, ComponentQuarantine,
+}
+
+// PersistentFileTTL is how long the files of the persistent components are
+// kept after the files in the same directory are last modified. The files are
+// reused by the capture restarted in time, the older ones are removed at
+// startup.
+const PersistentFileTTL = time.Hour
+
+var persistentComponents = map[string]struct{}{ComponentSorterResume: {}}
+
+// retainedComponents are never limited by the quotas, and their files are
+// left to the operators.
+var retainedComponents = map[string]struct{}{ComponentQuarantine: {}}
+
+// Config is the config of a Manager.
+type Config struct {
+	// DataDir is the directory of the files of all the components, it must
+	// not be shared by multiple captures.
+	DataDir string
+	// Quota is the max bytes used by all the components, 0 means no limit.
+	Quota int64
+	// ComponentQuotas are the max bytes used by each component, 0 or absent
+	// means no limit.
+	ComponentQuotas map[string]int64
+	// MinFreeBytes is the min free space of the data dir checked at startup,
+	// 0 means no check.
+	MinFreeBytes int64
+	// MemoryFallback makes the unified sorter fall back to the memory sorter
+	// instead of refusing to start if the data dir is unhealthy.
+	MemoryFallback bool
+}
+
+type componentUsage struct {
+	quota int64
+	used  int64
+
+	metricUsed prometheus.Gauge
+}
+
+// Manager tracks the disk space used by the components of a capture under a
+// single data dir, and limits it by the quotas. The quotas are soft limits:
+// the components check them before writing and fall back to memory when they
+// are exceeded, and the files written regardless of the quotas are still
+// accounted.
+//
+// It's safe for concurrent use.
+type Manager struct {
+	dataDir string
+	quota   int64
+
+	mu         sync.Mutex
+	used       int64
+	components map[string]*componentUsage
+
+	metricUsed prometheus.Gauge
+}
+
+// NewManager creates a Manager. The files left in the data dir are removed,
+// they are left by the previous runs of the capture and useless, since the
+// data is pulled again from the checkpoint after a restart. The files of the
+// persistent components are only removed if they are expired.
+func NewManager(cfg *Config, captureAddr string) (*Manager, error) {
+	if cfg.DataDir == "" {
+		return nil, cerror.ErrDiskManager.GenWithStack("the data dir is empty")
+	}
+	if cfg.Quota < 0 {
+		return nil, cerror.ErrDiskManager.GenWithStack("invalid quota %d", cfg.Quota)
+	}
+	m := &Manager{
+		dataDir:    cfg.DataDir,
+		quota:      cfg.Quota,
+		components: make(map[string]*componentUsage, len(components)),
+		metricUsed: usedBytesGauge.WithLabelValues(captureAddr, "total"),
+	}
+	for name, quota := range cfg.ComponentQuotas {
+		if !isComponent(name) {
+			return nil, cerror.ErrDiskManager.GenWithStack("unknown component %s", name)
+		}
+		if quota < 0 {
+			return nil, cerror.ErrDiskManager.GenWithStack("invalid quota %d of component %s", quota, name)
+		}
+		if _, ok := retainedComponents[name]; ok && quota > 0 {
+			return nil, cerror.ErrDiskManager.GenWithStack("component %s can't be limited", name)
+		}
+	}
+	quotaBytesGauge.WithLabelValues(captureAddr, "total").Set(float64(m.quota))
+	for _, name := range components {
+		dir := m.Dir(name)
+		removeFiles := removeOrphanFiles
+		if _, ok := persistentComponents[name]; ok {
+			removeFiles = removeExpiredFiles
+		}
+		if _, ok := retainedComponents[name]; ok {
+			removeFiles = func(string) error { return nil }
+		}
+		if err := removeFiles(dir); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, cerror.WrapError(cerror.ErrDiskManager, err)
+		}
+		quota := cfg.ComponentQuotas[name]
+		m.components[name] = &componentUsage{
+			quota:      quota,
+			metricUsed: usedBytesGauge.WithLabelValues(captureAddr, name),
+		}
+		m.components[name].metricUsed.Set(0)
+		quotaBytesGauge.WithLabelValues(captureAddr, name).Set(float64(quota))
+	}
+	m.metricUsed.Set(0)
+	log.Info("disk manager created", zap.String("data-dir", m.dataDir),
+		zap.Int64("quota", m.quota), zap.Reflect("component-quotas", cfg.ComponentQuotas))
+	return m, nil
+}
+
+func isComponent(name string) bool {
+	for _, c := range components {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func removeOrphanFiles(dir string) error {
+	var files int
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return cerror.WrapError(cerror.ErrDiskManager, err)
+	}
+	if files == 0 {
+		return nil
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return cerror.WrapError(cerror.ErrDiskManager, err)
+	}
+	log.Info("orphan files removed", zap.String("dir", dir),
+		zap.Int("files", files), zap.Int64("bytes", size))
+	return nil
+}
+
+// removeExpiredFiles removes the directories whose files are all not modified
+// within PersistentFileTTL, and the empty directories. The files of a
+// directory, such as the resume log of a table, depend on each other, so
+// they are removed together, a file is never removed while the others in the
+// same directory are still in use.
+func removeExpiredFiles(dir string) error {
+	var dirs []string
+	lastModified := make(map[string]time.Time)
+	files := make(map[string]int)
+	sizes := make(map[string]int64)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if path != dir {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		parent := filepath.Dir(path)
+		if info.ModTime().After(lastModified[parent]) {
+			lastModified[parent] = info.ModTime()
+		}
+		files[parent]++
+		sizes[parent] += info.Size()
+		return nil
+	})
+	if err != nil {
+		return cerror.WrapError(cerror.ErrDiskManager, err)
+	}
+	expire := time.Now().Add(-PersistentFileTTL)
+	for parent, modified := range lastModified {
+		if !modified.Before(expire) {
+			continue
+		}
+		// the directory is removed below once it is empty
+		if err := removeFilesIn(parent); err != nil {
+			return cerror.WrapError(cerror.ErrDiskManager, err)
+		}
+		log.Info("expired files removed", zap.String("dir", parent),
+			zap.Int("files", files[parent]), zap.Int64("bytes", sizes[parent]))
+	}
+	// the sub directories are walked after their parents
+	for i := len(dirs) - 1; i >= 0; i-- {
+		// only the empty directories can be removed
+		_ = os.Remove(dirs[i])
+	}
+	return nil
+}
+
+// removeFilesIn removes the files directly in dir, the sub directories are
+// kept.
+func removeFilesIn(dir string) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, info.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dir returns the directory of the component.
+func (m *Manager) Dir(component string) string {
+	return filepath.Join(m.dataDir, component)
+}
+
+// Allocate reserves size bytes for the component, it returns false and
+// reserves nothing if a quota would be exceeded.
+func (m *Manager) Allocate(component string, size int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.component(component)
+	if (m.quota > 0 && m.used+size > m.quota) || (c.quota > 0 && c.used+size > c.quota) {
+		return false
+	}
+	m.addLocked(c, size)
+	return true
+}
+
+// ForceAllocate accounts size bytes written by the component regardless of
+// the quotas.
+func (m *Manager) ForceAllocate(component string, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addLocked(m.component(component), size)
+}
+
+// Free releases size bytes of the component.
+func (m *Manager) Free(component string, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addLocked(m.component(component), -size)
+}
+
+// Full returns whether the component or the data dir has used up its quota.
+func (m *Manager) Full(component string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.component(component)
+	return (m.quota > 0 && m.used >= m.quota) || (c.quota > 0 && c.used >= c.quota)
+}
+
+// Used returns the bytes used by the component.
+func (m *Manager) Used(component string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.component(component).used
+}
+
+// TotalUsed returns the bytes used by all the components.
+func (m *Manager) TotalUsed() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.used
+}
+
+// Available returns the bytes the component can still write, limited by the
+// quotas and the free space of the data dir. It's -1 if neither is known.
+func (m *Manager) Available(component string) int64 {
+	available := int64(-1)
+	if free, _, err := statfs(m.dataDir); err == nil {
+		available = int64(free)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	limit := func(quota, used int64) {
+		if quota <= 0 {
+			return
+		}
+		left := quota - used
+		if left < 0 {
+			left = 0
+		}
+		if available < 0 || left < available {
+			available = left
+		}
+	}
+	limit(m.quota, m.used)
+	c := m.component(component)
+	limit(c.quota, c.used)
+	return available
+}
+
+func (m *Manager) component(name string) *componentUsage {
+	c, ok := m.components[name]
+	if !ok {
+		log.Panic("unknown disk manager component", zap.String("component", name))
+	}
+	return c
+}
+
+func (m *Manager) addLocked(c *componentUsage, size int64) {
+	c.used += size
+	m.used += size
+	c.metricUsed.Set(float64(c.used))
+	m.metricUsed.Set(float64(m.used))
+}
+
+var (
+	globalManager *Manager
+	globalMu      sync.Mutex
+)
+
+// GetGlobal returns the process-local disk manager, it returns nil if the
+// data dir is not configured.
+func GetGlobal() *Manager {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	return globalManager
+}
+
+// SetGlobal sets the process-local disk manager.
+func SetGlobal(m *Manager) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalManager = m
+}
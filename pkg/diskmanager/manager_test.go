@@ -0,0 +1,158 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskmanager
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type managerSuite struct{}
+
+var _ = check.Suite(&managerSuite{})
+
+func (s *managerSuite) TestRemoveOrphanFiles(c *check.C) {
+	defer testleak.AfterTest(c)()
+	dataDir := c.MkDir()
+	orphan := filepath.Join(dataDir, ComponentSorter, "sort-1234-1.tmp")
+	c.Assert(os.MkdirAll(filepath.Dir(orphan), 0o755), check.IsNil)
+	c.Assert(ioutil.WriteFile(orphan, []byte("data"), 0o644), check.IsNil)
+	// the files not owned by any component are kept
+	other := filepath.Join(dataDir, "other")
+	c.Assert(ioutil.WriteFile(other, []byte("data"), 0o644), check.IsNil)
+	// the files of the persistent components are kept until they expire
+	resume := filepath.Join(dataDir, ComponentSorterResume, "cf", "1", "manifest.json")
+	c.Assert(os.MkdirAll(filepath.Dir(resume), 0o755), check.IsNil)
+	c.Assert(ioutil.WriteFile(resume, []byte("[]"), 0o644), check.IsNil)
+	expired := filepath.Join(dataDir, ComponentSorterResume, "cf", "2", "manifest.json")
+	c.Assert(os.MkdirAll(filepath.Dir(expired), 0o755), check.IsNil)
+	c.Assert(ioutil.WriteFile(expired, []byte("[]"), 0o644), check.IsNil)
+	expireTime := time.Now().Add(-2 * PersistentFileTTL)
+	c.Assert(os.Chtimes(expired, expireTime, expireTime), check.IsNil)
+	// an old segment is kept while the manifest listing it is still in use
+	segment := filepath.Join(filepath.Dir(resume), "00000000000000000001.seg")
+	c.Assert(ioutil.WriteFile(segment, []byte("data"), 0o644), check.IsNil)
+	c.Assert(os.Chtimes(segment, expireTime, expireTime), check.IsNil)
+	// the files of the retained components are always kept
+	quarantine := filepath.Join(dataDir, ComponentQuarantine, "cf.log")
+	c.Assert(os.MkdirAll(filepath.Dir(quarantine), 0o755), check.IsNil)
+	c.Assert(ioutil.WriteFile(quarantine, []byte("{}"), 0o644), check.IsNil)
+	c.Assert(os.Chtimes(quarantine, expireTime, expireTime), check.IsNil)
+
+	m, err := NewManager(&Config{DataDir: dataDir}, "")
+	c.Assert(err, check.IsNil)
+	_, err = os.Stat(orphan)
+	c.Assert(os.IsNotExist(err), check.IsTrue)
+	_, err = os.Stat(other)
+	c.Assert(err, check.IsNil)
+	_, err = os.Stat(resume)
+	c.Assert(err, check.IsNil)
+	_, err = os.Stat(segment)
+	c.Assert(err, check.IsNil)
+	_, err = os.Stat(filepath.Dir(expired))
+	c.Assert(os.IsNotExist(err), check.IsTrue)
+	_, err = os.Stat(quarantine)
+	c.Assert(err, check.IsNil)
+	for _, component := range components {
+		info, err := os.Stat(m.Dir(component))
+		c.Assert(err, check.IsNil)
+		c.Assert(info.IsDir(), check.IsTrue)
+	}
+}
+
+func (s *managerSuite) TestQuota(c *check.C) {
+	defer testleak.AfterTest(c)()
+	m, err := NewManager(&Config{
+		DataDir:         c.MkDir(),
+		Quota:           100,
+		ComponentQuotas: map[string]int64{ComponentSinkSpill: 50},
+	}, "")
+	c.Assert(err, check.IsNil)
+
+	c.Assert(m.Allocate(ComponentSinkSpill, 40), check.IsTrue)
+	c.Assert(m.Allocate(ComponentSinkSpill, 20), check.IsFalse)
+	c.Assert(m.Full(ComponentSinkSpill), check.IsFalse)
+	c.Assert(m.Allocate(ComponentSinkSpill, 10), check.IsTrue)
+	c.Assert(m.Full(ComponentSinkSpill), check.IsTrue)
+	c.Assert(m.Full(ComponentSorter), check.IsFalse)
+
+	// the total quota is shared by the components
+	c.Assert(m.Allocate(ComponentSorter, 60), check.IsFalse)
+	m.ForceAllocate(ComponentSorter, 60)
+	c.Assert(m.Full(ComponentSorter), check.IsTrue)
+	c.Assert(m.Used(ComponentSorter), check.Equals, int64(60))
+	c.Assert(m.TotalUsed(), check.Equals, int64(110))
+
+	c.Assert(m.Available(ComponentSorter), check.Equals, int64(0))
+
+	m.Free(ComponentSorter, 60)
+	m.Free(ComponentSinkSpill, 50)
+	c.Assert(m.TotalUsed(), check.Equals, int64(0))
+	c.Assert(m.Available(ComponentSinkSpill), check.Equals, int64(50))
+	c.Assert(m.Allocate(ComponentSorter, 100), check.IsTrue)
+}
+
+func (s *managerSuite) TestInvalidConfig(c *check.C) {
+	defer testleak.AfterTest(c)()
+	_, err := NewManager(&Config{}, "")
+	c.Assert(err, check.ErrorMatches, ".*the data dir is empty.*")
+	_, err = NewManager(&Config{DataDir: c.MkDir(), Quota: -1}, "")
+	c.Assert(err, check.ErrorMatches, ".*invalid quota.*")
+	_, err = NewManager(&Config{
+		DataDir:         c.MkDir(),
+		ComponentQuotas: map[string]int64{"unknown": 1},
+	}, "")
+	c.Assert(err, check.ErrorMatches, ".*unknown component.*")
+	_, err = NewManager(&Config{
+		DataDir:         c.MkDir(),
+		ComponentQuotas: map[string]int64{ComponentQuarantine: 1},
+	}, "")
+	c.Assert(err, check.ErrorMatches, ".*can't be limited.*")
+}
+
+func (s *managerSuite) TestCheckDir(c *check.C) {
+	defer testleak.AfterTest(c)()
+	dataDir := filepath.Join(c.MkDir(), "data")
+	h := CheckDir(dataDir, 0)
+	c.Assert(h.Healthy(), check.IsTrue, check.Commentf("%+v", h))
+	for _, component := range components {
+		info, err := os.Stat(filepath.Join(dataDir, component))
+		c.Assert(err, check.IsNil)
+		c.Assert(info.IsDir(), check.IsTrue)
+	}
+
+	// no disk has so much free space
+	h = CheckDir(dataDir, math.MaxInt64)
+	if h.FSType != "" {
+		c.Assert(h.Error, check.Matches, "the free space .* is less than .*")
+	}
+
+	// the data dir can't be created under a file
+	file := filepath.Join(c.MkDir(), "file")
+	c.Assert(ioutil.WriteFile(file, []byte("data"), 0o644), check.IsNil)
+	h = CheckDir(filepath.Join(file, "data"), 0)
+	c.Assert(h.Healthy(), check.IsFalse)
+	c.Assert(h.Error, check.Matches, "create dir .* failed.*")
+}
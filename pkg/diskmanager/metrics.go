@@ -0,0 +1,41 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskmanager
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	usedBytesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "ticdc",
+			Subsystem: "disk_manager",
+			Name:      "used_bytes",
+			Help:      "Bytes used by the components in the data dir",
+		}, []string{"capture", "component"})
+	quotaBytesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "ticdc",
+			Subsystem: "disk_manager",
+			Name:      "quota_bytes",
+			Help:      "Disk quotas of the components in the data dir, 0 means no limit",
+		}, []string{"capture", "component"})
+)
+
+// InitMetrics registers all metrics in this file
+func InitMetrics(registry *prometheus.Registry) {
+	registry.MustRegister(usedBytesGauge)
+	registry.MustRegister(quotaBytesGauge)
+}
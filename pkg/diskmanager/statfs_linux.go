@@ -0,0 +1,48 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskmanager
+
+import (
+	"fmt"
+	"syscall"
+
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+// fsTypeNames are the names of the file systems by the magic numbers, see
+// statfs(2).
+var fsTypeNames = map[int64]string{
+	0xEF53:     "ext4",
+	0x58465342: "xfs",
+	0x9123683E: "btrfs",
+	0x2FC12FC1: "zfs",
+	0x01021994: "tmpfs",
+	0x794C7630: "overlay",
+	0x6969:     "nfs",
+	0xFF534D42: "cifs",
+	0xFE534D42: "smb2",
+	0x65735546: "fuse",
+}
+
+func statfs(dir string) (free uint64, fsType string, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, "", cerror.WrapError(cerror.ErrDiskManager, err)
+	}
+	fsType, ok := fsTypeNames[int64(st.Type)]
+	if !ok {
+		fsType = fmt.Sprintf("0x%x", st.Type)
+	}
+	return st.Bavail * uint64(st.Bsize), fsType, nil
+}
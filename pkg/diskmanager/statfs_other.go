@@ -0,0 +1,24 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !linux
+
+package diskmanager
+
+import (
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+func statfs(dir string) (free uint64, fsType string, err error) {
+	return 0, "", cerror.ErrDiskManager.GenWithStack("statfs is not supported on this platform")
+}
@@ -66,48 +66,82 @@ var (
 	ErrCreateMarkTableFailed = errors.Normalize("create mark table failed", errors.RFCCodeText("CDC:ErrCreateMarkTableFailed"))
 
 	// sink related errors
-	ErrExecDDLFailed             = errors.Normalize("exec DDL failed", errors.RFCCodeText("CDC:ErrExecDDLFailed"))
-	ErrDDLEventIgnored           = errors.Normalize("ddl event is ignored", errors.RFCCodeText("CDC:ErrDDLEventIgnored"))
-	ErrKafkaSendMessage          = errors.Normalize("kafka send message failed", errors.RFCCodeText("CDC:ErrKafkaSendMessage"))
-	ErrKafkaAsyncSendMessage     = errors.Normalize("kafka async send message failed", errors.RFCCodeText("CDC:ErrKafkaAsyncSendMessage"))
-	ErrKafkaFlushUnfished        = errors.Normalize("flush not finished before producer close", errors.RFCCodeText("CDC:ErrKafkaFlushUnfished"))
-	ErrKafkaInvalidPartitionNum  = errors.Normalize("invalid partition num %d", errors.RFCCodeText("CDC:ErrKafkaInvalidPartitionNum"))
-	ErrKafkaNewSaramaProducer    = errors.Normalize("new sarama producer", errors.RFCCodeText("CDC:ErrKafkaNewSaramaProducer"))
-	ErrKafkaInvalidClientID      = errors.Normalize("invalid kafka client ID '%s'", errors.RFCCodeText("CDC:ErrKafkaInvalidClientID"))
-	ErrKafkaInvalidVersion       = errors.Normalize("invalid kafka version", errors.RFCCodeText("CDC:ErrKafkaInvalidVersion"))
-	ErrPulsarNewProducer         = errors.Normalize("new pulsar producer", errors.RFCCodeText("CDC:ErrPulsarNewProducer"))
-	ErrPulsarSendMessage         = errors.Normalize("pulsar send message failed", errors.RFCCodeText("CDC:ErrPulsarSendMessage"))
-	ErrFileSinkCreateDir         = errors.Normalize("file sink create dir", errors.RFCCodeText("CDC:ErrFileSinkCreateDir"))
-	ErrFileSinkFileOp            = errors.Normalize("file sink file operation", errors.RFCCodeText("CDC:ErrFileSinkFileOp"))
-	ErrFileSinkMetaAlreadyExists = errors.Normalize("file sink meta file already exists", errors.RFCCodeText("CDC:ErrFileSinkMetaAlreadyExists"))
-	ErrS3SinkWriteStorage        = errors.Normalize("write to storage", errors.RFCCodeText("CDC:ErrS3SinkWriteStorage"))
-	ErrS3SinkInitialzie          = errors.Normalize("new s3 sink", errors.RFCCodeText("CDC:ErrS3SinkInitialzie"))
-	ErrS3SinkStorageAPI          = errors.Normalize("s3 sink storage api", errors.RFCCodeText("CDC:ErrS3SinkStorageAPI"))
-	ErrPrepareAvroFailed         = errors.Normalize("prepare avro failed", errors.RFCCodeText("CDC:ErrPrepareAvroFailed"))
-	ErrAsyncBroadcaseNotSupport  = errors.Normalize("Async broadcasts not supported", errors.RFCCodeText("CDC:ErrAsyncBroadcaseNotSupport"))
-	ErrKafkaInvalidConfig        = errors.Normalize("kafka config invalid", errors.RFCCodeText("CDC:ErrKafkaInvalidConfig"))
-	ErrSinkURIInvalid            = errors.Normalize("sink uri invalid", errors.RFCCodeText("CDC:ErrSinkURIInvalid"))
-	ErrMySQLTxnError             = errors.Normalize("MySQL txn error", errors.RFCCodeText("CDC:ErrMySQLTxnError"))
-	ErrMySQLQueryError           = errors.Normalize("MySQL query error", errors.RFCCodeText("CDC:ErrMySQLQueryError"))
-	ErrMySQLConnectionError      = errors.Normalize("MySQL connection error", errors.RFCCodeText("CDC:ErrMySQLConnectionError"))
-	ErrMySQLInvalidConfig        = errors.Normalize("MySQL config invaldi", errors.RFCCodeText("CDC:ErrMySQLInvalidConfig"))
-	ErrMySQLWorkerPanic          = errors.Normalize("MySQL worker panic", errors.RFCCodeText("CDC:ErrMySQLWorkerPanic"))
-	ErrAvroToEnvelopeError       = errors.Normalize("to envelope failed", errors.RFCCodeText("CDC:ErrAvroToEnvelopeError"))
-	ErrAvroUnknownType           = errors.Normalize("unknown type for Avro: %v", errors.RFCCodeText("CDC:ErrAvroUnknownType"))
-	ErrAvroMarshalFailed         = errors.Normalize("json marshal failed", errors.RFCCodeText("CDC:ErrAvroMarshalFailed"))
-	ErrAvroEncodeFailed          = errors.Normalize("encode to avro native data", errors.RFCCodeText("CDC:ErrAvroEncodeFailed"))
-	ErrAvroEncodeToBinary        = errors.Normalize("encode to binray from native", errors.RFCCodeText("CDC:ErrAvroEncodeToBinary"))
-	ErrAvroSchemaAPIError        = errors.Normalize("schema manager API error", errors.RFCCodeText("CDC:ErrAvroSchemaAPIError"))
-	ErrMaxwellEncodeFailed       = errors.Normalize("maxwell encode failed", errors.RFCCodeText("CDC:ErrMaxwellEncodeFailed"))
-	ErrMaxwellDecodeFailed       = errors.Normalize("maxwell decode failed", errors.RFCCodeText("CDC:ErrMaxwellDecodeFailed"))
-	ErrMaxwellInvalidData        = errors.Normalize("maxwell invalid data", errors.RFCCodeText("CDC:ErrMaxwellInvalidData"))
-	ErrJSONCodecInvalidData      = errors.Normalize("json codec invalid data", errors.RFCCodeText("CDC:ErrJSONCodecInvalidData"))
-	ErrCanalDecodeFailed         = errors.Normalize("canal decode failed", errors.RFCCodeText("CDC:ErrCanalDecodeFailed"))
-	ErrCanalEncodeFailed         = errors.Normalize("canal encode failed", errors.RFCCodeText("CDC:ErrCanalEncodeFailed"))
-	ErrOldValueNotEnabled        = errors.Normalize("old value is not enabled", errors.RFCCodeText("CDC:ErrOldValueNotEnabled"))
+	ErrExecDDLFailed               = errors.Normalize("exec DDL failed", errors.RFCCodeText("CDC:ErrExecDDLFailed"))
+	ErrDDLEventIgnored             = errors.Normalize("ddl event is ignored", errors.RFCCodeText("CDC:ErrDDLEventIgnored"))
+	ErrKafkaSendMessage            = errors.Normalize("kafka send message failed", errors.RFCCodeText("CDC:ErrKafkaSendMessage"))
+	ErrKafkaAsyncSendMessage       = errors.Normalize("kafka async send message failed", errors.RFCCodeText("CDC:ErrKafkaAsyncSendMessage"))
+	ErrKafkaFlushUnfished          = errors.Normalize("flush not finished before producer close", errors.RFCCodeText("CDC:ErrKafkaFlushUnfished"))
+	ErrKafkaInvalidPartitionNum    = errors.Normalize("invalid partition num %d", errors.RFCCodeText("CDC:ErrKafkaInvalidPartitionNum"))
+	ErrKafkaNewSaramaProducer      = errors.Normalize("new sarama producer", errors.RFCCodeText("CDC:ErrKafkaNewSaramaProducer"))
+	ErrKafkaInvalidClientID        = errors.Normalize("invalid kafka client ID '%s'", errors.RFCCodeText("CDC:ErrKafkaInvalidClientID"))
+	ErrKafkaInvalidVersion         = errors.Normalize("invalid kafka version", errors.RFCCodeText("CDC:ErrKafkaInvalidVersion"))
+	ErrPulsarNewProducer           = errors.Normalize("new pulsar producer", errors.RFCCodeText("CDC:ErrPulsarNewProducer"))
+	ErrPulsarSendMessage           = errors.Normalize("pulsar send message failed", errors.RFCCodeText("CDC:ErrPulsarSendMessage"))
+	ErrFileSinkCreateDir           = errors.Normalize("file sink create dir", errors.RFCCodeText("CDC:ErrFileSinkCreateDir"))
+	ErrFileSinkFileOp              = errors.Normalize("file sink file operation", errors.RFCCodeText("CDC:ErrFileSinkFileOp"))
+	ErrLogSinkEncryption           = errors.Normalize("log sink encryption error", errors.RFCCodeText("CDC:ErrLogSinkEncryption"))
+	ErrFileSinkMetaAlreadyExists   = errors.Normalize("file sink meta file already exists", errors.RFCCodeText("CDC:ErrFileSinkMetaAlreadyExists"))
+	ErrS3SinkWriteStorage          = errors.Normalize("write to storage", errors.RFCCodeText("CDC:ErrS3SinkWriteStorage"))
+	ErrS3SinkInitialzie            = errors.Normalize("new s3 sink", errors.RFCCodeText("CDC:ErrS3SinkInitialzie"))
+	ErrS3SinkStorageAPI            = errors.Normalize("s3 sink storage api", errors.RFCCodeText("CDC:ErrS3SinkStorageAPI"))
+	ErrGCSSinkInitialize           = errors.Normalize("new gcs sink", errors.RFCCodeText("CDC:ErrGCSSinkInitialize"))
+	ErrAzblobSinkInitialize        = errors.Normalize("new azure blob sink", errors.RFCCodeText("CDC:ErrAzblobSinkInitialize"))
+	ErrPrepareAvroFailed           = errors.Normalize("prepare avro failed", errors.RFCCodeText("CDC:ErrPrepareAvroFailed"))
+	ErrAsyncBroadcaseNotSupport    = errors.Normalize("Async broadcasts not supported", errors.RFCCodeText("CDC:ErrAsyncBroadcaseNotSupport"))
+	ErrKafkaInvalidConfig          = errors.Normalize("kafka config invalid", errors.RFCCodeText("CDC:ErrKafkaInvalidConfig"))
+	ErrSinkURIInvalid              = errors.Normalize("sink uri invalid", errors.RFCCodeText("CDC:ErrSinkURIInvalid"))
+	ErrSinkInvalidConfig           = errors.Normalize("sink config invalid", errors.RFCCodeText("CDC:ErrSinkInvalidConfig"))
+	ErrDownstreamSchemaMismatch    = errors.Normalize("downstream schema mismatch", errors.RFCCodeText("CDC:ErrDownstreamSchemaMismatch"))
+	ErrVerifyFailed                = errors.Normalize("verify the data consistency failed", errors.RFCCodeText("CDC:ErrVerifyFailed"))
+	ErrBenchInvalidConfig          = errors.Normalize("invalid bench config", errors.RFCCodeText("CDC:ErrBenchInvalidConfig"))
+	ErrMessageKeyBuildFailed       = errors.Normalize("build the message key failed", errors.RFCCodeText("CDC:ErrMessageKeyBuildFailed"))
+	ErrKafkaConsumerLag            = errors.Normalize("fetch the lag of kafka consumer group failed", errors.RFCCodeText("CDC:ErrKafkaConsumerLag"))
+	ErrClusterInMaintenance        = errors.Normalize("the cluster is in maintenance mode since %s", errors.RFCCodeText("CDC:ErrClusterInMaintenance"))
+	ErrClusterNotInMaintenance     = errors.Normalize("the cluster is not in maintenance mode", errors.RFCCodeText("CDC:ErrClusterNotInMaintenance"))
+	ErrChangefeedQuotaExceeded     = errors.Normalize("the number of changefeeds reaches the limit %d", errors.RFCCodeText("CDC:ErrChangefeedQuotaExceeded"))
+	ErrMySQLDDLTimeout             = errors.Normalize("execute DDL timeout after %s", errors.RFCCodeText("CDC:ErrMySQLDDLTimeout"))
+	ErrTableCheckpointSkewed       = errors.Normalize("the checkpoint of table %s lags behind the other tables by %s", errors.RFCCodeText("CDC:ErrTableCheckpointSkewed"))
+	ErrRewriteDDLFailed            = errors.Normalize("rewrite DDL failed", errors.RFCCodeText("CDC:ErrRewriteDDLFailed"))
+	ErrUpstreamClusterIDChanged    = errors.Normalize("the upstream cluster id is changed from %d to %d, rebase the changefeed to replicate from the new cluster", errors.RFCCodeText("CDC:ErrUpstreamClusterIDChanged"))
+	ErrReplayInvalidRange          = errors.Normalize("the end ts of the replay must be larger than the start ts %d, got %d", errors.RFCCodeText("CDC:ErrReplayInvalidRange"))
+	ErrReplayIncomplete            = errors.Normalize("the storage sink output is only replayed to %d, less than the end ts %d", errors.RFCCodeText("CDC:ErrReplayIncomplete"))
+	ErrChangefeedPanic             = errors.Normalize("%s of the changefeed panics: %v", errors.RFCCodeText("CDC:ErrChangefeedPanic"))
+	ErrMQRowTooLarge               = errors.Normalize("the message of the row of %s at commit ts %d is %d bytes, larger than max-message-bytes %d", errors.RFCCodeText("CDC:ErrMQRowTooLarge"))
+	ErrClaimCheckInvalidObject     = errors.Normalize("invalid claim check object of %d bytes", errors.RFCCodeText("CDC:ErrClaimCheckInvalidObject"))
+	ErrChangefeedIllegalTransition = errors.Normalize("changefeed can't transit from %s to %s", errors.RFCCodeText("CDC:ErrChangefeedIllegalTransition"))
+	ErrInvalidNamespace            = errors.Normalize(`bad namespace %s, please match the pattern "^[a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*$", eg, "team-a"`, errors.RFCCodeText("CDC:ErrInvalidNamespace"))
+	ErrAPINamespaceDenied          = errors.Normalize("the caller is scoped to namespace %s, can't call %s %s", errors.RFCCodeText("CDC:ErrAPINamespaceDenied"))
+	ErrEventSkipped                = errors.Normalize("%d row events failed to be mounted are skipped by the skip policy and dumped to %s, the last one is skipped by: %s", errors.RFCCodeText("CDC:ErrEventSkipped"))
+	ErrQuarantineEvent             = errors.Normalize("dump the skipped event to the quarantine file failed", errors.RFCCodeText("CDC:ErrQuarantineEvent"))
+	ErrSinkConflict                = errors.Normalize("the tables are replicated to the same downstream by changefeeds %s", errors.RFCCodeText("CDC:ErrSinkConflict"))
+	ErrSinkSpillFull               = errors.Normalize("the pending rows of the table sink exceed the spill limits, max-disk-size: %d, memory-rows-limit: %d", errors.RFCCodeText("CDC:ErrSinkSpillFull"))
+	ErrSinkSpill                   = errors.Normalize("sink spill to disk failed", errors.RFCCodeText("CDC:ErrSinkSpill"))
+	ErrMySQLTxnError               = errors.Normalize("MySQL txn error", errors.RFCCodeText("CDC:ErrMySQLTxnError"))
+	ErrMySQLQueryError             = errors.Normalize("MySQL query error", errors.RFCCodeText("CDC:ErrMySQLQueryError"))
+	ErrMySQLConnectionError        = errors.Normalize("MySQL connection error", errors.RFCCodeText("CDC:ErrMySQLConnectionError"))
+	ErrMySQLInvalidConfig          = errors.Normalize("MySQL config invaldi", errors.RFCCodeText("CDC:ErrMySQLInvalidConfig"))
+	ErrMySQLWorkerPanic            = errors.Normalize("MySQL worker panic", errors.RFCCodeText("CDC:ErrMySQLWorkerPanic"))
+	ErrAvroToEnvelopeError         = errors.Normalize("to envelope failed", errors.RFCCodeText("CDC:ErrAvroToEnvelopeError"))
+	ErrAvroUnknownType             = errors.Normalize("unknown type for Avro: %v", errors.RFCCodeText("CDC:ErrAvroUnknownType"))
+	ErrAvroMarshalFailed           = errors.Normalize("json marshal failed", errors.RFCCodeText("CDC:ErrAvroMarshalFailed"))
+	ErrAvroEncodeFailed            = errors.Normalize("encode to avro native data", errors.RFCCodeText("CDC:ErrAvroEncodeFailed"))
+	ErrAvroEncodeToBinary          = errors.Normalize("encode to binray from native", errors.RFCCodeText("CDC:ErrAvroEncodeToBinary"))
+	ErrAvroDecodeFailed            = errors.Normalize("decode avro message failed", errors.RFCCodeText("CDC:ErrAvroDecodeFailed"))
+	ErrAvroSchemaAPIError          = errors.Normalize("schema manager API error", errors.RFCCodeText("CDC:ErrAvroSchemaAPIError"))
+	ErrMaxwellEncodeFailed         = errors.Normalize("maxwell encode failed", errors.RFCCodeText("CDC:ErrMaxwellEncodeFailed"))
+	ErrMaxwellDecodeFailed         = errors.Normalize("maxwell decode failed", errors.RFCCodeText("CDC:ErrMaxwellDecodeFailed"))
+	ErrMaxwellInvalidData          = errors.Normalize("maxwell invalid data", errors.RFCCodeText("CDC:ErrMaxwellInvalidData"))
+	ErrJSONCodecInvalidData        = errors.Normalize("json codec invalid data", errors.RFCCodeText("CDC:ErrJSONCodecInvalidData"))
+	ErrCanalDecodeFailed           = errors.Normalize("canal decode failed", errors.RFCCodeText("CDC:ErrCanalDecodeFailed"))
+	ErrCanalEncodeFailed           = errors.Normalize("canal encode failed", errors.RFCCodeText("CDC:ErrCanalEncodeFailed"))
+	ErrOldValueNotEnabled          = errors.Normalize("old value is not enabled", errors.RFCCodeText("CDC:ErrOldValueNotEnabled"))
 
 	// utilities related errors
 	ErrToTLSConfigFailed         = errors.Normalize("generate tls config failed", errors.RFCCodeText("CDC:ErrToTLSConfigFailed"))
+	ErrInvalidTLSOptions         = errors.Normalize("invalid tls options", errors.RFCCodeText("CDC:ErrInvalidTLSOptions"))
+	ErrCertCNNotAllowed          = errors.Normalize("the common name of the certificate %s is not allowed", errors.RFCCodeText("CDC:ErrCertCNNotAllowed"))
+	ErrVerifyCertificateFailed   = errors.Normalize("verify the certificate of the peer failed", errors.RFCCodeText("CDC:ErrVerifyCertificateFailed"))
+	ErrResolveSecretFailed       = errors.Normalize("resolve secret failed", errors.RFCCodeText("CDC:ErrResolveSecretFailed"))
 	ErrCheckClusterVersionFromPD = errors.Normalize("failed to request PD", errors.RFCCodeText("CDC:ErrCheckClusterVersionFromPD"))
 	ErrNewSemVersion             = errors.Normalize("create sem version", errors.RFCCodeText("CDC:ErrNewSemVersion"))
 	ErrCheckDirWritable          = errors.Normalize("check dir writable failed", errors.RFCCodeText("CDC:ErrCheckDirWritable"))
@@ -127,7 +161,7 @@ var (
 	ErrDecodeRowToDatum      = errors.Normalize("decode row data to datum failed", errors.RFCCodeText("CDC:ErrDecodeRowToDatum"))
 	ErrMarshalFailed         = errors.Normalize("marshal failed", errors.RFCCodeText("CDC:ErrMarshalFailed"))
 	ErrUnmarshalFailed       = errors.Normalize("unmarshal failed", errors.RFCCodeText("CDC:ErrUnmarshalFailed"))
-	ErrInvalidChangefeedID   = errors.Normalize(`bad changefeed id, please match the pattern "^[a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*$", eg, "simple-changefeed-task"`, errors.RFCCodeText("CDC:ErrInvalidChangefeedID"))
+	ErrInvalidChangefeedID   = errors.Normalize(`bad changefeed id, please match the pattern "^([a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*\.)?[a-zA-Z0-9]+(\-[a-zA-Z0-9]+)*$", eg, "simple-changefeed-task" or "team-a.simple-changefeed-task"`, errors.RFCCodeText("CDC:ErrInvalidChangefeedID"))
 	ErrInvalidEtcdKey        = errors.Normalize("invalid key: %s", errors.RFCCodeText("CDC:ErrInvalidEtcdKey"))
 
 	// schema storage errors
@@ -141,7 +175,6 @@ var (
 	ErrSnapshotTableExists     = errors.Normalize("table %s.%s already exists", errors.RFCCodeText("CDC:ErrSnapshotTableExists"))
 
 	// puller related errors
-	ErrBufferReachLimit      = errors.Normalize("puller mem buffer reach size limit", errors.RFCCodeText("CDC:ErrBufferReachLimit"))
 	ErrFileSorterOpenFile    = errors.Normalize("open file failed", errors.RFCCodeText("CDC:ErrFileSorterOpenFile"))
 	ErrFileSorterReadFile    = errors.Normalize("read file failed", errors.RFCCodeText("CDC:ErrFileSorterReadFile"))
 	ErrFileSorterWriteFile   = errors.Normalize("write file failed", errors.RFCCodeText("CDC:ErrFileSorterWriteFile"))
@@ -161,6 +194,7 @@ var (
 	ErrUnknownSortEngine            = errors.Normalize("unknown sort engine %s", errors.RFCCodeText("CDC:ErrUnknownSortEngine"))
 	ErrInvalidTaskKey               = errors.Normalize("invalid task key: %s", errors.RFCCodeText("CDC:ErrInvalidTaskKey"))
 	ErrInvalidServerOption          = errors.Normalize("invalid server option", errors.RFCCodeText("CDC:ErrInvalidServerOption"))
+	ErrInvalidServerConfig          = errors.Normalize("invalid server config", errors.RFCCodeText("CDC:ErrInvalidServerConfig"))
 	ErrServerNewPDClient            = errors.Normalize("server creates pd client failed", errors.RFCCodeText("CDC:ErrServerNewPDClient"))
 	ErrServeHTTP                    = errors.Normalize("serve http error", errors.RFCCodeText("CDC:ErrServeHTTP"))
 	ErrCaptureCampaignOwner         = errors.Normalize("campaign owner failed", errors.RFCCodeText("CDC:ErrCaptureCampaignOwner"))
@@ -169,6 +203,11 @@ var (
 	ErrSupportPostOnly              = errors.Normalize("this api supports POST method only", errors.RFCCodeText("CDC:ErrSupportPostOnly"))
 	ErrAPIInvalidParam              = errors.Normalize("invalid api parameter", errors.RFCCodeText("CDC:ErrAPIInvalidParam"))
 	ErrInternalServerError          = errors.Normalize("internal server error", errors.RFCCodeText("CDC:ErrInternalServerError"))
+	ErrAPIUnauthenticated           = errors.Normalize("unauthenticated api caller", errors.RFCCodeText("CDC:ErrAPIUnauthenticated"))
+	ErrAPIPermissionDenied          = errors.Normalize("role %s is not allowed to call %s", errors.RFCCodeText("CDC:ErrAPIPermissionDenied"))
+	ErrInvalidAuthConfig            = errors.Normalize("invalid authentication config", errors.RFCCodeText("CDC:ErrInvalidAuthConfig"))
+	ErrAuditLog                     = errors.Normalize("audit log error", errors.RFCCodeText("CDC:ErrAuditLog"))
+	ErrReportRuntimeState           = errors.Normalize("report runtime state to owner failed", errors.RFCCodeText("CDC:ErrReportRuntimeState"))
 	ErrOwnerSortDir                 = errors.Normalize("owner sort dir", errors.RFCCodeText("CDC:ErrOwnerSortDir"))
 	ErrOwnerChangefeedNotFound      = errors.Normalize("changefeed %s not found in owner cache", errors.RFCCodeText("CDC:ErrOwnerChangefeedNotFound"))
 	ErrChangefeedAbnormalState      = errors.Normalize("changefeed in abnormal state: %s, replication status: %+v", errors.RFCCodeText("CDC:ErrChangefeedAbnormalState"))
@@ -197,4 +236,7 @@ var (
 
 	// unified sorter errors
 	ErrUnifiedSorterBackendTerminating = errors.Normalize("unified sorter backend is terminating", errors.RFCCodeText("CDC:ErrUnifiedSorterBackendTerminating"))
+
+	// disk manager errors
+	ErrDiskManager = errors.Normalize("disk manager error", errors.RFCCodeText("CDC:ErrDiskManager"))
 )
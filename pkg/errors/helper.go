@@ -27,3 +27,24 @@ func WrapError(rfcError *errors.Error, err error) error {
 	}
 	return rfcError.Wrap(err).GenWithStackByCause()
 }
+
+// RFCCodes returns the RFC codes of the errors in the cause chain of err, the
+// outermost one comes first.
+func RFCCodes(err error) []errors.RFCErrorCode {
+	var codes []errors.RFCErrorCode
+	for err != nil {
+		if e, ok := err.(*errors.Error); ok {
+			codes = append(codes, e.RFCCode())
+		}
+		causer, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		cause := causer.Cause()
+		if cause == err {
+			break
+		}
+		err = cause
+	}
+	return codes
+}
@@ -53,3 +53,14 @@ func (s *helperSuite) TestWrapError(c *check.C) {
 		}
 	}
 }
+
+func (s *helperSuite) TestRFCCodes(c *check.C) {
+	defer testleak.AfterTest(c)()
+	c.Assert(RFCCodes(nil), check.HasLen, 0)
+	c.Assert(RFCCodes(errors.New("test")), check.HasLen, 0)
+
+	err := errors.Trace(WrapError(ErrDecodeRowToDatum, errors.New("test")))
+	c.Assert(RFCCodes(err), check.DeepEquals, []errors.RFCErrorCode{"CDC:ErrDecodeRowToDatum"})
+	err = errors.Annotate(ErrQuarantineEvent.GenWithStackByArgs(), "skip event")
+	c.Assert(RFCCodes(err), check.DeepEquals, []errors.RFCErrorCode{"CDC:ErrQuarantineEvent"})
+}
@@ -39,11 +39,22 @@ const (
 type Client struct {
 	cli     *clientv3.Client
 	metrics map[string]prometheus.Counter
+	// latencies observe the latencies of the RPCs in seconds, the latency of
+	// each try is observed if the RPC is retried.
+	latencies map[string]prometheus.Observer
 }
 
 // Wrap warps a clientv3.Client that provides etcd APIs required by TiCDC.
 func Wrap(cli *clientv3.Client, metrics map[string]prometheus.Counter) *Client {
-	return &Client{cli: cli, metrics: metrics}
+	return WrapWithLatencies(cli, metrics, nil)
+}
+
+// WrapWithLatencies is the same as Wrap, and observes the latencies of the
+// RPCs keyed by the operation names.
+func WrapWithLatencies(
+	cli *clientv3.Client, metrics map[string]prometheus.Counter, latencies map[string]prometheus.Observer,
+) *Client {
+	return &Client{cli: cli, metrics: metrics, latencies: latencies}
 }
 
 // Unwrap returns a clientv3.Client
@@ -51,28 +62,34 @@ func (c *Client) Unwrap() *clientv3.Client {
 	return c.cli
 }
 
-func retryRPC(rpcName string, metric prometheus.Counter, etcdRPC func() error) error {
+func retryRPC(
+	ctx context.Context, rpcName string, metric prometheus.Counter, latency prometheus.Observer, etcdRPC func() error,
+) error {
 	// By default, PD etcd sets [3s, 6s) for election timeout.
 	// Some rpc could fail due to etcd errors, like "proposal dropped".
 	// Retry at least two election timeout to handle the case that two PDs restarted
 	// (the first election maybe failed).
-	// 16s = \sum_{n=0}^{6} 0.5*1.5^n
-	return retry.Run(500*time.Millisecond, 7+1, // +1 for the inital request.
-		func() error {
-			err := etcdRPC()
-			if err != nil && errors.Cause(err) != context.Canceled {
-				log.Warn("etcd RPC failed", zap.String("RPC", rpcName), zap.Error(err))
-			}
-			if metric != nil {
-				metric.Inc()
-			}
-			return err
-		})
+	// The delays are [0.5s, 1s, 2s, 3s, 3s, 3s, 3s] without the jitter, 15.5s in total.
+	return retry.Do(ctx, func() error {
+		start := time.Now()
+		err := etcdRPC()
+		if latency != nil {
+			latency.Observe(time.Since(start).Seconds())
+		}
+		if err != nil && errors.Cause(err) != context.Canceled {
+			log.Warn("etcd RPC failed", zap.String("RPC", rpcName), zap.Error(err))
+		}
+		if metric != nil {
+			metric.Inc()
+		}
+		return err
+	}, retry.WithBackoffBaseDelay(500*time.Millisecond), retry.WithBackoffMaxDelay(3*time.Second),
+		retry.WithMaxTries(7+1)) // +1 for the inital request.
 }
 
 // Put delegates request to clientv3.KV.Put
 func (c *Client) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (resp *clientv3.PutResponse, err error) {
-	err = retryRPC(EtcdPut, c.metrics[EtcdPut], func() error {
+	err = retryRPC(ctx, EtcdPut, c.metrics[EtcdPut], c.latencies[EtcdPut], func() error {
 		var inErr error
 		resp, inErr = c.cli.Put(ctx, key, val, opts...)
 		return inErr
@@ -82,7 +99,7 @@ func (c *Client) Put(ctx context.Context, key, val string, opts ...clientv3.OpOp
 
 // Get delegates request to clientv3.KV.Get
 func (c *Client) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (resp *clientv3.GetResponse, err error) {
-	err = retryRPC(EtcdGet, c.metrics[EtcdGet], func() error {
+	err = retryRPC(ctx, EtcdGet, c.metrics[EtcdGet], c.latencies[EtcdGet], func() error {
 		var inErr error
 		resp, inErr = c.cli.Get(ctx, key, opts...)
 		return inErr
@@ -96,6 +113,11 @@ func (c *Client) Delete(ctx context.Context, key string, opts ...clientv3.OpOpti
 		metric.Inc()
 	}
 	// We don't retry on delete operatoin. It's dangerous.
+	if latency, ok := c.latencies[EtcdDel]; ok {
+		defer func(start time.Time) {
+			latency.Observe(time.Since(start).Seconds())
+		}(time.Now())
+	}
 	return c.cli.Delete(ctx, key, opts...)
 }
 
@@ -109,7 +131,7 @@ func (c *Client) Txn(ctx context.Context) clientv3.Txn {
 
 // Grant delegates request to clientv3.Lease.Grant
 func (c *Client) Grant(ctx context.Context, ttl int64) (resp *clientv3.LeaseGrantResponse, err error) {
-	err = retryRPC(EtcdGrant, c.metrics[EtcdGrant], func() error {
+	err = retryRPC(ctx, EtcdGrant, c.metrics[EtcdGrant], c.latencies[EtcdGrant], func() error {
 		var inErr error
 		resp, inErr = c.cli.Grant(ctx, ttl)
 		return inErr
@@ -119,7 +141,7 @@ func (c *Client) Grant(ctx context.Context, ttl int64) (resp *clientv3.LeaseGran
 
 // Revoke delegates request to clientv3.Lease.Revoke
 func (c *Client) Revoke(ctx context.Context, id clientv3.LeaseID) (resp *clientv3.LeaseRevokeResponse, err error) {
-	err = retryRPC(EtcdRevoke, c.metrics[EtcdRevoke], func() error {
+	err = retryRPC(ctx, EtcdRevoke, c.metrics[EtcdRevoke], c.latencies[EtcdRevoke], func() error {
 		var inErr error
 		resp, inErr = c.cli.Revoke(ctx, id)
 		return inErr
@@ -129,7 +151,7 @@ func (c *Client) Revoke(ctx context.Context, id clientv3.LeaseID) (resp *clientv
 
 // TimeToLive delegates request to clientv3.Lease.TimeToLive
 func (c *Client) TimeToLive(ctx context.Context, lease clientv3.LeaseID, opts ...clientv3.LeaseOption) (resp *clientv3.LeaseTimeToLiveResponse, err error) {
-	err = retryRPC(EtcdRevoke, c.metrics[EtcdRevoke], func() error {
+	err = retryRPC(ctx, EtcdRevoke, c.metrics[EtcdRevoke], c.latencies[EtcdRevoke], func() error {
 		var inErr error
 		resp, inErr = c.cli.TimeToLive(ctx, lease, opts...)
 		return inErr
@@ -54,7 +54,7 @@ func (s *clientSuite) TestRetry(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(get, check.NotNil)
 
-	// TODO: speed test, it take about 6.59s
+	// TODO: speed test, it take about 12s
 	_, err = retrycli.Put(context.TODO(), "", "")
 	c.Assert(err, check.NotNil)
 	c.Assert(err, check.ErrorMatches, "mock error")
@@ -0,0 +1,224 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+)
+
+const (
+	// endpointStatusTimeout is the timeout of checking the status of an endpoint.
+	endpointStatusTimeout = 3 * time.Second
+	// minEndpointBackoff and maxEndpointBackoff bound how long an unhealthy
+	// endpoint is excluded, the backoff doubles every time the check fails.
+	minEndpointBackoff = 5 * time.Second
+	maxEndpointBackoff = 2 * time.Minute
+)
+
+// EndpointRefresher keeps the endpoints of an etcd client up to date during
+// the rolling upgrades of PD. It refreshes the member list periodically, and
+// excludes the endpoints failing the status checks for an exponential backoff.
+// If the leader is avoided, the client connects to the followers only as long
+// as one of them is healthy, so the connections, and the sessions kept alive
+// through them, aren't broken when the leader is transferred or restarted.
+type EndpointRefresher struct {
+	cli         *clientv3.Client
+	interval    time.Duration
+	avoidLeader bool
+	// latency observes the latencies of the status checks in seconds, it's
+	// labeled by the endpoints.
+	latency prometheus.ObserverVec
+
+	backoffs map[string]*endpointBackoff
+	now      func() time.Time
+}
+
+type endpointBackoff struct {
+	until time.Time
+	delay time.Duration
+}
+
+type endpointStatus struct {
+	endpoint string
+	healthy  bool
+	leader   bool
+}
+
+// NewEndpointRefresher creates an EndpointRefresher of the client, latency
+// can be nil.
+func NewEndpointRefresher(
+	cli *clientv3.Client, interval time.Duration, avoidLeader bool, latency prometheus.ObserverVec,
+) *EndpointRefresher {
+	return &EndpointRefresher{
+		cli:         cli,
+		interval:    interval,
+		avoidLeader: avoidLeader,
+		latency:     latency,
+		backoffs:    make(map[string]*endpointBackoff),
+		now:         time.Now,
+	}
+}
+
+// Run refreshes the endpoints until the context is done.
+func (r *EndpointRefresher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-ticker.C:
+		}
+		if err := r.refresh(ctx); err != nil && errors.Cause(err) != context.Canceled {
+			log.Warn("refresh etcd endpoints failed", zap.Error(err))
+		}
+	}
+}
+
+// refresh checks the endpoints of the members and sets the selected ones to
+// the client, the endpoints are left unchanged if none of them is healthy.
+func (r *EndpointRefresher) refresh(ctx context.Context) error {
+	endpoints, err := r.memberEndpoints(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	statuses := make([]endpointStatus, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if b, ok := r.backoffs[endpoint]; ok && r.now().Before(b.until) {
+			continue
+		}
+		statuses = append(statuses, r.checkEndpoint(ctx, endpoint))
+	}
+	// forget the endpoints of the removed members
+	for endpoint := range r.backoffs {
+		if !containsEndpoint(endpoints, endpoint) {
+			delete(r.backoffs, endpoint)
+		}
+	}
+
+	selected := selectEndpoints(statuses, r.avoidLeader)
+	if len(selected) == 0 {
+		log.Warn("no healthy etcd endpoint, keep the current ones",
+			zap.Strings("endpoints", r.cli.Endpoints()))
+		return nil
+	}
+	current := append([]string(nil), r.cli.Endpoints()...)
+	sort.Strings(current)
+	if !equalEndpoints(current, selected) {
+		log.Info("etcd endpoints changed",
+			zap.Strings("from", current), zap.Strings("to", selected))
+		r.cli.SetEndpoints(selected...)
+	}
+	return nil
+}
+
+func (r *EndpointRefresher) memberEndpoints(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, endpointStatusTimeout)
+	defer cancel()
+	resp, err := r.cli.MemberList(ctx)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrPDEtcdAPIError, err)
+	}
+	var endpoints []string
+	for _, member := range resp.Members {
+		// the members not started yet have no client urls
+		endpoints = append(endpoints, member.ClientURLs...)
+	}
+	return endpoints, nil
+}
+
+// checkEndpoint checks the status of the endpoint, it backs off from the
+// endpoint if the check fails.
+func (r *EndpointRefresher) checkEndpoint(ctx context.Context, endpoint string) endpointStatus {
+	ctx, cancel := context.WithTimeout(ctx, endpointStatusTimeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := r.cli.Status(ctx, endpoint)
+	if r.latency != nil {
+		r.latency.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	}
+	if err != nil {
+		b, ok := r.backoffs[endpoint]
+		if !ok {
+			b = &endpointBackoff{delay: minEndpointBackoff}
+			r.backoffs[endpoint] = b
+		} else {
+			b.delay *= 2
+			if b.delay > maxEndpointBackoff {
+				b.delay = maxEndpointBackoff
+			}
+		}
+		b.until = r.now().Add(b.delay)
+		log.Warn("etcd endpoint is unhealthy, back off from it",
+			zap.String("endpoint", endpoint), zap.Duration("backoff", b.delay), zap.Error(err))
+		return endpointStatus{endpoint: endpoint}
+	}
+	delete(r.backoffs, endpoint)
+	return endpointStatus{
+		endpoint: endpoint,
+		healthy:  true,
+		leader:   resp.Header != nil && resp.Header.MemberId == resp.Leader,
+	}
+}
+
+// selectEndpoints returns the sorted healthy endpoints, the leader is
+// excluded if the leader is avoided and a follower is healthy.
+func selectEndpoints(statuses []endpointStatus, avoidLeader bool) []string {
+	var healthy, followers []string
+	for _, status := range statuses {
+		if !status.healthy {
+			continue
+		}
+		healthy = append(healthy, status.endpoint)
+		if !status.leader {
+			followers = append(followers, status.endpoint)
+		}
+	}
+	selected := healthy
+	if avoidLeader && len(followers) > 0 {
+		selected = followers
+	}
+	sort.Strings(selected)
+	return selected
+}
+
+func containsEndpoint(endpoints []string, endpoint string) bool {
+	for _, ep := range endpoints {
+		if ep == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+func equalEndpoints(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+	"go.etcd.io/etcd/clientv3"
+)
+
+func (s *clientSuite) TestSelectEndpoints(c *check.C) {
+	defer testleak.AfterTest(c)()
+	statuses := []endpointStatus{
+		{endpoint: "http://pd-3:2379", healthy: true},
+		{endpoint: "http://pd-1:2379", healthy: true, leader: true},
+		{endpoint: "http://pd-2:2379"},
+	}
+	c.Assert(selectEndpoints(statuses, false), check.DeepEquals, []string{"http://pd-1:2379", "http://pd-3:2379"})
+	c.Assert(selectEndpoints(statuses, true), check.DeepEquals, []string{"http://pd-3:2379"})
+
+	// the leader is used if no follower is healthy
+	statuses[0].healthy = false
+	c.Assert(selectEndpoints(statuses, true), check.DeepEquals, []string{"http://pd-1:2379"})
+	statuses[1].healthy = false
+	c.Assert(selectEndpoints(statuses, true), check.HasLen, 0)
+}
+
+func (s *etcdSuite) TestEndpointRefresher(c *check.C) {
+	defer testleak.AfterTest(c)()
+	defer s.TearDownTest(c)
+	ctx := context.Background()
+	curl := s.clientURL.String()
+	unreachable := "http://127.0.0.1:1"
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{unreachable, curl},
+		DialTimeout: 3 * time.Second,
+	})
+	c.Assert(err, check.IsNil)
+	defer cli.Close()
+
+	// the endpoints are replaced by the ones of the members, the only member
+	// is the leader, so it's used even if the leader is avoided
+	r := NewEndpointRefresher(cli, time.Minute, true, nil)
+	c.Assert(r.refresh(ctx), check.IsNil)
+	c.Assert(cli.Endpoints(), check.DeepEquals, []string{curl})
+
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	status := r.checkEndpoint(ctx, curl)
+	c.Assert(status.healthy, check.IsTrue)
+	c.Assert(status.leader, check.IsTrue)
+
+	// the backoff of the unhealthy endpoint doubles until it's healthy
+	c.Assert(r.checkEndpoint(ctx, unreachable).healthy, check.IsFalse)
+	c.Assert(r.backoffs[unreachable].delay, check.Equals, minEndpointBackoff)
+	c.Assert(r.backoffs[unreachable].until, check.Equals, now.Add(minEndpointBackoff))
+	c.Assert(r.checkEndpoint(ctx, unreachable).healthy, check.IsFalse)
+	c.Assert(r.backoffs[unreachable].delay, check.Equals, 2*minEndpointBackoff)
+
+	// the backoffs of the removed members are forgotten
+	c.Assert(r.refresh(ctx), check.IsNil)
+	c.Assert(r.backoffs, check.HasLen, 0)
+}
@@ -21,5 +21,5 @@ import (
 // ChangefeedFastFailError checks the error, returns true if it is meaningless
 // to retry on this error
 func ChangefeedFastFailError(err error) bool {
-	return cerror.ErrStartTsBeforeGC.Equal(errors.Cause(err))
+	return cerror.ErrStartTsBeforeGC.Equal(errors.Cause(err)) || cerror.ErrSinkConflict.Equal(errors.Cause(err))
 }
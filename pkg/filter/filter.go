@@ -128,12 +128,9 @@ func (f *Filter) shouldDiscardByBuiltInDDLAllowlist(ddlType model.ActionType) bo
 	ActionDropSequence                  ActionType = 36
 	ActionModifyTableAutoIdCache        ActionType = 39
 	ActionRebaseAutoRandomBase          ActionType = 40
-	ActionAlterIndexVisibility          ActionType = 41
-	ActionExchangeTablePartition        ActionType = 42
 	ActionAddCheckConstraint            ActionType = 43
 	ActionDropCheckConstraint           ActionType = 44
 	ActionAlterCheckConstraint          ActionType = 45
-	ActionAlterTableAlterPartition      ActionType = 46
 
 	... Any Action which of value is greater than 46 ...
 	*/
@@ -163,7 +160,10 @@ func (f *Filter) shouldDiscardByBuiltInDDLAllowlist(ddlType model.ActionType) bo
 		model.ActionAddPrimaryKey,
 		model.ActionDropPrimaryKey,
 		model.ActionAddColumns,
-		model.ActionDropColumns:
+		model.ActionDropColumns,
+		model.ActionAlterIndexVisibility,
+		model.ActionExchangeTablePartition,
+		model.ActionAlterTableAlterPartition:
 		return false
 	}
 	return true
@@ -138,6 +138,9 @@ func (s *filterSuite) TestShouldDiscardDDL(c *check.C) {
 	c.Assert(filter.ShouldDiscardDDL(model.ActionDropSchema), check.IsFalse)
 	c.Assert(filter.ShouldDiscardDDL(model.ActionAddForeignKey), check.IsFalse)
 	c.Assert(filter.ShouldDiscardDDL(model.ActionCreateSequence), check.IsTrue)
+	c.Assert(filter.ShouldDiscardDDL(model.ActionExchangeTablePartition), check.IsFalse)
+	c.Assert(filter.ShouldDiscardDDL(model.ActionAlterIndexVisibility), check.IsFalse)
+	c.Assert(filter.ShouldDiscardDDL(model.ActionAlterTableAlterPartition), check.IsFalse)
 }
 
 func (s *filterSuite) TestShouldIgnoreDDL(c *check.C) {
@@ -14,32 +14,155 @@
 package httputil
 
 import (
+	"net"
 	"net/http"
+	"net/url"
+	"time"
 
+	"github.com/pingcap/errors"
 	"github.com/pingcap/ticdc/pkg/security"
 )
 
+const (
+	// DefaultTimeout is the default timeout of a request, including reading
+	// the response body.
+	DefaultTimeout = time.Minute
+
+	defaultDialTimeout         = 10 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+)
+
 // Client wraps an HTTP client and support TLS requests.
 type Client struct {
 	http.Client
 }
 
-// NewClient creates an HTTP client with the given Credential.
-func NewClient(credential *security.Credential) (*Client, error) {
-	transport := http.DefaultTransport
+type clientOptions struct {
+	timeout time.Duration
+	proxy   string
+}
+
+// Option configures the HTTP client created by NewClient.
+type Option func(*clientOptions)
+
+// WithTimeout sets the timeout of a request, 0 means no timeout, such as the
+// requests streaming the responses.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithProxyURL sends the requests by the proxy instead of the proxy set by
+// the environment variables, it's a no-op if the proxy is empty.
+func WithProxyURL(proxy string) Option {
+	return func(o *clientOptions) {
+		o.proxy = proxy
+	}
+}
+
+// NewClient creates an HTTP client with the given Credential. The requests
+// are sent by the proxy set by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables, and time out after DefaultTimeout by default.
+func NewClient(credential *security.Credential, opts ...Option) (*Client, error) {
+	options := &clientOptions{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(options)
+	}
+	defaultTransport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		// the default transport is replaced, such as by the mocks in tests
+		return &Client{
+			Client: http.Client{Transport: http.DefaultTransport, Timeout: options.timeout},
+		}, nil
+	}
+	transport := defaultTransport.Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.DialContext = (&net.Dialer{
+		Timeout:   defaultDialTimeout,
+		KeepAlive: defaultKeepAlive,
+	}).DialContext
+	transport.TLSHandshakeTimeout = defaultTLSHandshakeTimeout
+	if options.proxy != "" {
+		u, err := url.Parse(options.proxy)
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid proxy %s", options.proxy)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
 	if credential != nil {
 		tlsConf, err := credential.ToTLSConfig()
 		if err != nil {
 			return nil, err
 		}
-		if tlsConf != nil {
-			httpTrans := http.DefaultTransport.(*http.Transport).Clone()
-			httpTrans.TLSClientConfig = tlsConf
-			transport = httpTrans
-		}
+		transport.TLSClientConfig = tlsConf
 	}
-	// TODO: specific timeout in http client
 	return &Client{
-		Client: http.Client{Transport: transport},
+		Client: http.Client{Transport: transport, Timeout: options.timeout},
 	}, nil
 }
+
+// SetBearerToken makes the client send the token in the Authorization header
+// of each request, it is a no-op if the token is empty.
+func (c *Client) SetBearerToken(token string) {
+	if token == "" {
+		return
+	}
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.Transport = &bearerTokenTransport{base: base, token: token}
+}
+
+// SetBasicAuth makes the client send the username and the password by the
+// basic authentication in each request, it is a no-op if the username is empty.
+func (c *Client) SetBasicAuth(username, password string) {
+	if username == "" {
+		return
+	}
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.Transport = &basicAuthTransport{base: base, username: username, password: password}
+}
+
+type basicAuthTransport struct {
+	base     http.RoundTripper
+	username string
+	password string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.base.RoundTrip(req)
+}
+
+type bearerTokenTransport struct {
+	base  http.RoundTripper
+	token string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *bearerTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the base transport, so
+// http.Client.CloseIdleConnections works with the wrapped transport.
+func (t *bearerTokenTransport) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	if base, ok := t.base.(closeIdler); ok {
+		base.CloseIdleConnections()
+	}
+}
@@ -20,9 +20,11 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/pingcap/check"
 	"github.com/pingcap/ticdc/pkg/security"
@@ -97,3 +99,62 @@ func runServer(ctx context.Context, tlsCfg *tls.Config, port int, c *check.C) *h
 	}()
 	return server
 }
+
+func (s *httputilSuite) TestBasicAuth(c *check.C) {
+	defer testleak.AfterTest(c)()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		username, password, ok := req.BasicAuth()
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	cli, err := NewClient(nil)
+	c.Assert(err, check.IsNil)
+	resp, err := cli.Get(server.URL)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.StatusCode, check.Equals, http.StatusUnauthorized)
+	resp.Body.Close()
+
+	cli.SetBasicAuth("user", "pass")
+	resp, err = cli.Get(server.URL)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.StatusCode, check.Equals, http.StatusOK)
+	resp.Body.Close()
+}
+
+func (s *httputilSuite) TestProxyAndTimeout(c *check.C) {
+	defer testleak.AfterTest(c)()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// the proxy receives the absolute uri of the target
+		if req.URL.Host != "cdc.example.invalid" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer proxy.Close()
+
+	cli, err := NewClient(nil, WithProxyURL(proxy.URL))
+	c.Assert(err, check.IsNil)
+	c.Assert(cli.Timeout, check.Equals, DefaultTimeout)
+	resp, err := cli.Get("http://cdc.example.invalid/")
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.StatusCode, check.Equals, http.StatusOK)
+	resp.Body.Close()
+	cli.CloseIdleConnections()
+
+	_, err = NewClient(nil, WithProxyURL("://invalid"))
+	c.Assert(err, check.ErrorMatches, ".*invalid proxy.*")
+
+	done := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-done
+	}))
+	defer slow.Close()
+	defer close(done)
+	cli, err = NewClient(nil, WithTimeout(50*time.Millisecond))
+	c.Assert(err, check.IsNil)
+	_, err = cli.Get(slow.URL)
+	c.Assert(err, check.ErrorMatches, ".*Client.Timeout exceeded.*")
+	cli.CloseIdleConnections()
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logutil
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// MaxChangefeedLogLevelDuration is the max duration of a changefeed log level,
+// so a forgotten debug level can't flood the log forever.
+const MaxChangefeedLogLevelDuration = 24 * time.Hour
+
+// changefeedLevel is the log level of a changefeed, it's shared by all the
+// loggers of the changefeed, and it takes effect until it expires.
+type changefeedLevel struct {
+	level  int32 // zapcore.Level
+	expire int64 // unix nano, 0 means no level is set
+}
+
+func (l *changefeedLevel) enabled(lvl zapcore.Level) bool {
+	expire := atomic.LoadInt64(&l.expire)
+	if expire == 0 || time.Now().UnixNano() >= expire {
+		return false
+	}
+	return lvl >= zapcore.Level(atomic.LoadInt32(&l.level))
+}
+
+var changefeedLevels = struct {
+	sync.Mutex
+	m map[string]*changefeedLevel
+}{m: make(map[string]*changefeedLevel)}
+
+func getChangefeedLevel(changefeedID string) *changefeedLevel {
+	changefeedLevels.Lock()
+	defer changefeedLevels.Unlock()
+	l, ok := changefeedLevels.m[changefeedID]
+	if !ok {
+		l = &changefeedLevel{}
+		changefeedLevels.m[changefeedID] = l
+	}
+	return l
+}
+
+// SetChangefeedLogLevel lowers the log level of a changefeed for the given
+// duration, the logs of the changefeed are written if their levels are
+// enabled by either the global log level or the changefeed log level.
+func SetChangefeedLogLevel(changefeedID string, level string, duration time.Duration) error {
+	if changefeedID == "" {
+		return errors.New("the changefeed id is empty")
+	}
+	var lv zapcore.Level
+	if err := lv.UnmarshalText([]byte(level)); err != nil {
+		return errors.Trace(err)
+	}
+	if duration <= 0 || duration > MaxChangefeedLogLevelDuration {
+		return errors.Errorf("the duration %s must be in (0, %s]", duration, MaxChangefeedLogLevelDuration)
+	}
+	l := getChangefeedLevel(changefeedID)
+	atomic.StoreInt32(&l.level, int32(lv))
+	atomic.StoreInt64(&l.expire, time.Now().Add(duration).UnixNano())
+	return nil
+}
+
+// ResetChangefeedLogLevel makes the changefeed use the global log level.
+func ResetChangefeedLogLevel(changefeedID string) {
+	changefeedLevels.Lock()
+	defer changefeedLevels.Unlock()
+	if l, ok := changefeedLevels.m[changefeedID]; ok {
+		atomic.StoreInt64(&l.expire, 0)
+	}
+}
+
+// ChangefeedLogLevel is the log level set for a changefeed.
+type ChangefeedLogLevel struct {
+	ChangefeedID string    `json:"changefeed-id"`
+	Level        string    `json:"level"`
+	Expire       time.Time `json:"expire"`
+}
+
+// ChangefeedLogLevels returns the unexpired log levels of the changefeeds.
+func ChangefeedLogLevels() []ChangefeedLogLevel {
+	changefeedLevels.Lock()
+	defer changefeedLevels.Unlock()
+	now := time.Now().UnixNano()
+	levels := make([]ChangefeedLogLevel, 0)
+	for id, l := range changefeedLevels.m {
+		expire := atomic.LoadInt64(&l.expire)
+		if expire == 0 || now >= expire {
+			continue
+		}
+		levels = append(levels, ChangefeedLogLevel{
+			ChangefeedID: id,
+			Level:        zapcore.Level(atomic.LoadInt32(&l.level)).String(),
+			Expire:       time.Unix(0, expire),
+		})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].ChangefeedID < levels[j].ChangefeedID
+	})
+	return levels
+}
+
+// changefeedCore enables the levels enabled by the changefeed log level in
+// addition to the ones enabled by the wrapped core.
+type changefeedCore struct {
+	zapcore.Core
+	level *changefeedLevel
+}
+
+func (c *changefeedCore) Enabled(lvl zapcore.Level) bool {
+	return c.Core.Enabled(lvl) || c.level.enabled(lvl)
+}
+
+func (c *changefeedCore) With(fields []zapcore.Field) zapcore.Core {
+	return &changefeedCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *changefeedCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		// the wrapped core writes the entry without checking the level again
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// ChangefeedLogger returns a logger tagging the logs with the capture and the
+// changefeed, its level can be changed by SetChangefeedLogLevel.
+func ChangefeedLogger(captureAddr string, changefeedID string) *zap.Logger {
+	level := getChangefeedLevel(changefeedID)
+	return log.L().WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &changefeedCore{Core: core, level: level}
+	})).With(zap.String("capture", captureAddr), zap.String("changefeed", changefeedID))
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logutil
+
+import (
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func (s *logSuite) TestChangefeedLogLevel(c *check.C) {
+	defer testleak.AfterTest(c)()
+	core, logs := observer.New(zapcore.InfoLevel)
+	newLogger := func(changefeedID string) *zap.Logger {
+		level := getChangefeedLevel(changefeedID)
+		return zap.New(&changefeedCore{Core: core, level: level}).With(zap.String("changefeed", changefeedID))
+	}
+	logger1 := newLogger("test-1")
+	logger2 := newLogger("test-2")
+
+	logger1.Debug("debug")
+	c.Assert(logs.Len(), check.Equals, 0)
+
+	c.Assert(SetChangefeedLogLevel("test-1", "debug", time.Minute), check.IsNil)
+	logger1.Debug("debug")
+	logger2.Debug("debug")
+	entries := logs.TakeAll()
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].ContextMap()["changefeed"], check.Equals, "test-1")
+	// the loggers derived from the changefeed logger share the level
+	logger1.With(zap.Int64("table-id", 1)).Debug("debug")
+	c.Assert(logs.TakeAll(), check.HasLen, 1)
+
+	levels := ChangefeedLogLevels()
+	c.Assert(levels, check.HasLen, 1)
+	c.Assert(levels[0].ChangefeedID, check.Equals, "test-1")
+	c.Assert(levels[0].Level, check.Equals, "debug")
+
+	ResetChangefeedLogLevel("test-1")
+	logger1.Debug("debug")
+	c.Assert(logs.Len(), check.Equals, 0)
+	c.Assert(ChangefeedLogLevels(), check.HasLen, 0)
+
+	// the level expires
+	c.Assert(SetChangefeedLogLevel("test-2", "debug", time.Millisecond), check.IsNil)
+	time.Sleep(10 * time.Millisecond)
+	logger2.Debug("debug")
+	c.Assert(logs.Len(), check.Equals, 0)
+	c.Assert(ChangefeedLogLevels(), check.HasLen, 0)
+
+	c.Assert(SetChangefeedLogLevel("", "debug", time.Minute), check.ErrorMatches, ".*changefeed id is empty.*")
+	c.Assert(SetChangefeedLogLevel("test-1", "badlevel", time.Minute), check.NotNil)
+	c.Assert(SetChangefeedLogLevel("test-1", "debug", 0), check.ErrorMatches, ".*must be in.*")
+	c.Assert(SetChangefeedLogLevel("test-1", "debug", 2*MaxChangefeedLogLevelDuration), check.ErrorMatches, ".*must be in.*")
+}
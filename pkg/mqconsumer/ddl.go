@@ -0,0 +1,76 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mqconsumer
+
+import (
+	"sync"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"go.uber.org/zap"
+)
+
+// DDLQueue keeps the received DDL events in the order of their commit ts, the
+// DDL events received more than once are dropped.
+type DDLQueue struct {
+	mu               sync.Mutex
+	ddls             []*model.DDLEvent
+	maxDDLReceivedTs uint64
+}
+
+// Append appends the DDL event, it's dropped if it's received before or it's
+// committed at or before appliedTs, which is the ts all events before which
+// are applied to the downstream.
+func (q *DDLQueue) Append(ddl *model.DDLEvent, appliedTs uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if ddl.CommitTs <= q.maxDDLReceivedTs {
+		return
+	}
+	if ddl.CommitTs <= appliedTs {
+		log.Info("skip applied ddl job", zap.Uint64("ddlts", ddl.CommitTs), zap.Uint64("appliedTs", appliedTs))
+		return
+	}
+	q.ddls = append(q.ddls, ddl)
+	q.maxDDLReceivedTs = ddl.CommitTs
+}
+
+// Front returns the first DDL event in the queue, it returns nil if the queue is empty.
+func (q *DDLQueue) Front() *model.DDLEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.ddls) > 0 {
+		return q.ddls[0]
+	}
+	return nil
+}
+
+// Pop removes and returns the first DDL event in the queue.
+func (q *DDLQueue) Pop() *model.DDLEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.ddls) > 0 {
+		ddl := q.ddls[0]
+		q.ddls = q.ddls[1:]
+		return ddl
+	}
+	return nil
+}
+
+// Reset drops the DDL events in the queue, they will be received again.
+func (q *DDLQueue) Reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ddls = nil
+}
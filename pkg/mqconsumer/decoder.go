@@ -0,0 +1,123 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mqconsumer implements the logic shared by the consumers replicating
+// the messages of the MQ sinks to the downstream.
+package mqconsumer
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc/sink/cdclog"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/ticdc/pkg/security"
+)
+
+// ParseProtocol returns the protocol of the messages in the query of the
+// upstream uri, which is the same as the protocol parameter of the sink uri,
+// and the schema registry url of the avro protocol.
+func ParseProtocol(query url.Values) (protocol codec.Protocol, registryURL string, err error) {
+	protocol.FromString(query.Get("protocol"))
+	switch protocol {
+	case codec.ProtocolDefault, codec.ProtocolCanalJSON:
+	case codec.ProtocolAvro:
+		registryURL = query.Get("registry")
+		if registryURL == "" {
+			return protocol, "", errors.New(`Avro protocol requires parameter "registry" of upstream-uri`)
+		}
+	default:
+		return protocol, "", errors.Errorf(
+			"unsupported protocol %s of upstream-uri, only default, canal-json and avro are supported", query.Get("protocol"))
+	}
+	return protocol, registryURL, nil
+}
+
+// DecoderFactory creates the decoders of the messages encoded by the given protocol.
+type DecoderFactory struct {
+	protocol           codec.Protocol
+	keySchemaManager   *codec.AvroSchemaManager
+	valueSchemaManager *codec.AvroSchemaManager
+	// claimCheck stores the messages offloaded by the claim-check policy.
+	claimCheck cdclog.ObjectStorage
+}
+
+// NewDecoderFactory creates a DecoderFactory, the claim checks in the messages
+// are read from the storage of claimCheckStorageURI if it's not empty.
+func NewDecoderFactory(
+	ctx context.Context, protocol codec.Protocol, registryURL string, claimCheckStorageURI string,
+) (*DecoderFactory, error) {
+	f := &DecoderFactory{protocol: protocol}
+	if claimCheckStorageURI != "" {
+		var err error
+		f.claimCheck, err = cdclog.NewObjectStorage(ctx, claimCheckStorageURI)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if protocol != codec.ProtocolAvro {
+		return f, nil
+	}
+	credential := &security.Credential{}
+	var err error
+	f.keySchemaManager, err = codec.NewAvroSchemaManager(ctx, credential, registryURL, "-key")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	f.valueSchemaManager, err = codec.NewAvroSchemaManager(ctx, credential, registryURL, "-value")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return f, nil
+}
+
+// WithResolvedEvent returns whether the protocol carries the resolved ts and
+// the commit ts of the row changed events. For the protocols without them, the
+// events are applied in the order they are received.
+func (f *DecoderFactory) WithResolvedEvent() bool {
+	return f.protocol == codec.ProtocolDefault
+}
+
+// NewDecoder creates a decoder of the message with the key and the value.
+func (f *DecoderFactory) NewDecoder(ctx context.Context, key, value []byte) (codec.EventBatchDecoder, error) {
+	key, value, err := f.resolveClaimCheck(ctx, key, value)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	switch f.protocol {
+	case codec.ProtocolCanalJSON:
+		return codec.NewCanalFlatEventBatchDecoder(value)
+	case codec.ProtocolAvro:
+		return codec.NewAvroEventBatchDecoder(ctx, key, value, f.keySchemaManager, f.valueSchemaManager), nil
+	default:
+		return codec.NewJSONEventBatchDecoder(key, value)
+	}
+}
+
+// resolveClaimCheck returns the key and the value of the message, which are
+// read from the claim check storage if the message is a claim check.
+func (f *DecoderFactory) resolveClaimCheck(ctx context.Context, key, value []byte) ([]byte, []byte, error) {
+	name, ok := codec.ParseClaimCheck(value)
+	if !ok {
+		return key, value, nil
+	}
+	if f.claimCheck == nil {
+		return nil, nil, errors.Errorf("receive the claim check of %s without the claim check storage configured", name)
+	}
+	data, err := f.claimCheck.Read(ctx, name)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return codec.DecodeClaimCheckObject(data)
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mqconsumer
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type mqConsumerSuite struct{}
+
+var _ = check.Suite(&mqConsumerSuite{})
+
+func (s *mqConsumerSuite) TestParseProtocol(c *check.C) {
+	defer testleak.AfterTest(c)()
+	protocol, registryURL, err := ParseProtocol(url.Values{})
+	c.Assert(err, check.IsNil)
+	c.Assert(protocol, check.Equals, codec.ProtocolDefault)
+	c.Assert(registryURL, check.Equals, "")
+
+	protocol, _, err = ParseProtocol(url.Values{"protocol": {"canal-json"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(protocol, check.Equals, codec.ProtocolCanalJSON)
+
+	_, _, err = ParseProtocol(url.Values{"protocol": {"avro"}})
+	c.Assert(err, check.ErrorMatches, ".*requires parameter \"registry\".*")
+	protocol, registryURL, err = ParseProtocol(url.Values{"protocol": {"avro"}, "registry": {"http://127.0.0.1:8081"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(protocol, check.Equals, codec.ProtocolAvro)
+	c.Assert(registryURL, check.Equals, "http://127.0.0.1:8081")
+
+	_, _, err = ParseProtocol(url.Values{"protocol": {"canal"}})
+	c.Assert(err, check.ErrorMatches, ".*unsupported protocol canal.*")
+}
+
+func (s *mqConsumerSuite) TestDDLQueue(c *check.C) {
+	defer testleak.AfterTest(c)()
+	var q DDLQueue
+	c.Assert(q.Front(), check.IsNil)
+	q.Append(&model.DDLEvent{CommitTs: 10}, 5)
+	// the duplicated and the applied DDLs are dropped
+	q.Append(&model.DDLEvent{CommitTs: 10}, 5)
+	q.Append(&model.DDLEvent{CommitTs: 20}, 20)
+	q.Append(&model.DDLEvent{CommitTs: 30}, 20)
+	c.Assert(q.Front().CommitTs, check.Equals, uint64(10))
+	c.Assert(q.Pop().CommitTs, check.Equals, uint64(10))
+	c.Assert(q.Pop().CommitTs, check.Equals, uint64(30))
+	c.Assert(q.Pop(), check.IsNil)
+
+	q.Append(&model.DDLEvent{CommitTs: 40}, 30)
+	q.Reset()
+	c.Assert(q.Front(), check.IsNil)
+}
+
+func (s *mqConsumerSuite) TestFakeTableIDGenerator(c *check.C) {
+	defer testleak.AfterTest(c)()
+	g := NewFakeTableIDGenerator()
+	c.Assert(g.GenerateFakeTableID("test", "t1", 0), check.Equals, int64(1))
+	c.Assert(g.GenerateFakeTableID("test", "t2", 0), check.Equals, int64(2))
+	c.Assert(g.GenerateFakeTableID("test", "t1", 100), check.Equals, int64(3))
+	c.Assert(g.GenerateFakeTableID("test", "t1", 0), check.Equals, int64(1))
+
+	row := &model.RowChangedEvent{
+		CommitTs: 10,
+		Table:    &model.TableName{Schema: "test", Table: "t1", TableID: 100, IsPartition: true},
+	}
+	PrepareRow(row, g)
+	c.Assert(row.StartTs, check.Equals, uint64(10))
+	c.Assert(row.Table.TableID, check.Equals, int64(3))
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mqconsumer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink"
+	"github.com/pingcap/ticdc/pkg/config"
+	cdcfilter "github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/quotes"
+	"go.uber.org/zap"
+)
+
+// NewSinks creates num sinks of the row changed events and a sink of the DDL
+// events to the downstream, the sinks are canceled once any of them fails.
+func NewSinks(ctx context.Context, id string, downstreamURI string, num int) ([]sink.Sink, sink.Sink, error) {
+	// TODO support filter in downstream sink
+	filter, err := cdcfilter.NewFilter(config.GetDefaultReplicaConfig())
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+	opts := map[string]string{}
+	sinks := make([]sink.Sink, num)
+	for i := range sinks {
+		sinks[i], err = sink.NewSink(ctx, id, downstreamURI, filter, config.GetDefaultReplicaConfig(), opts, errCh)
+		if err != nil {
+			cancel()
+			return nil, nil, errors.Trace(err)
+		}
+	}
+	ddlSink, err := sink.NewSink(ctx, id, downstreamURI, filter, config.GetDefaultReplicaConfig(), opts, errCh)
+	if err != nil {
+		cancel()
+		return nil, nil, errors.Trace(err)
+	}
+	go func() {
+		err := <-errCh
+		if errors.Cause(err) != context.Canceled {
+			log.Error("error on running consumer", zap.Error(err))
+		} else {
+			log.Info("consumer exited")
+		}
+		cancel()
+	}()
+	return sinks, ddlSink, nil
+}
+
+// SyncFlushRowChangedEvents flushes the sink until its checkpoint reaches resolvedTs.
+func SyncFlushRowChangedEvents(ctx context.Context, sink sink.Sink, resolvedTs uint64) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		checkpointTs, err := sink.FlushRowChangedEvents(ctx, resolvedTs)
+		if err != nil {
+			return err
+		}
+		if checkpointTs >= resolvedTs {
+			return nil
+		}
+	}
+}
+
+// FakeTableIDGenerator generates the table ids of the received rows, the
+// messages don't carry the table ids of the upstream, and the sinks group the
+// rows by their table ids.
+type FakeTableIDGenerator struct {
+	tableIDs       map[string]int64
+	currentTableID int64
+	mu             sync.Mutex
+}
+
+// NewFakeTableIDGenerator creates a FakeTableIDGenerator.
+func NewFakeTableIDGenerator() *FakeTableIDGenerator {
+	return &FakeTableIDGenerator{tableIDs: make(map[string]int64)}
+}
+
+// GenerateFakeTableID returns the table id of the table, or the partition of
+// the table if partition is not zero.
+func (g *FakeTableIDGenerator) GenerateFakeTableID(schema, table string, partition int64) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := quotes.QuoteSchema(schema, table)
+	if partition != 0 {
+		key = fmt.Sprintf("%s.`%d`", key, partition)
+	}
+	if tableID, ok := g.tableIDs[key]; ok {
+		return tableID
+	}
+	g.currentTableID++
+	g.tableIDs[key] = g.currentTableID
+	return g.currentTableID
+}
+
+// PrepareRow sets the fields of the row not carried by the messages, it sets
+// the table id by the generator.
+func PrepareRow(row *model.RowChangedEvent, g *FakeTableIDGenerator) {
+	// FIXME: hack to set start-ts in row changed event, as start-ts
+	// is not contained in TiCDC open protocol
+	row.StartTs = row.CommitTs
+	var partitionID int64
+	if row.Table.IsPartition {
+		partitionID = row.Table.TableID
+	}
+	row.Table.TableID = g.GenerateFakeTableID(row.Table.Schema, row.Table.Table, partitionID)
+}
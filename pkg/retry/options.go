@@ -0,0 +1,111 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import "time"
+
+// The default max delay is far shorter than the 60s max interval of the
+// backoff package used before, and there is no default total retry duration
+// like its 15 minutes max elapsed time, the callers retrying for long pass
+// WithBackoffMaxDelay and WithTotalRetryDuration explicitly.
+const (
+	defaultBackoffBaseDelay = 10 * time.Millisecond
+	defaultBackoffMaxDelay  = 2 * time.Second
+	defaultMaxTries         = 3
+)
+
+// IsRetryable returns whether the error of the operation should be retried.
+type IsRetryable func(error) bool
+
+// Option configures the retries of Do.
+type Option func(*retryOptions)
+
+type retryOptions struct {
+	backoffBaseDelay   time.Duration
+	backoffMaxDelay    time.Duration
+	maxTries           uint64
+	totalRetryDuration time.Duration
+	isRetryable        IsRetryable
+}
+
+func newRetryOptions(opts ...Option) *retryOptions {
+	options := &retryOptions{
+		backoffBaseDelay: defaultBackoffBaseDelay,
+		backoffMaxDelay:  defaultBackoffMaxDelay,
+		maxTries:         defaultMaxTries,
+		isRetryable:      func(error) bool { return true },
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.backoffMaxDelay < options.backoffBaseDelay {
+		options.backoffMaxDelay = options.backoffBaseDelay
+	}
+	return options
+}
+
+// WithBackoffBaseDelay sets the delay before the first retry, the delay is
+// doubled after every retry.
+func WithBackoffBaseDelay(delay time.Duration) Option {
+	return func(o *retryOptions) {
+		if delay > 0 {
+			o.backoffBaseDelay = delay
+		}
+	}
+}
+
+// WithBackoffMaxDelay sets the upper limit of the delay between the retries.
+func WithBackoffMaxDelay(delay time.Duration) Option {
+	return func(o *retryOptions) {
+		if delay > 0 {
+			o.backoffMaxDelay = delay
+		}
+	}
+}
+
+// WithMaxTries sets the max number of the calls of the operation, including
+// the first one.
+func WithMaxTries(tries uint64) Option {
+	return func(o *retryOptions) {
+		if tries > 0 {
+			o.maxTries = tries
+		}
+	}
+}
+
+// WithInfiniteTries retries the operation until it succeeds, the error is not
+// retryable, the total retry duration is exceeded or the context is done.
+func WithInfiniteTries() Option {
+	return func(o *retryOptions) {
+		o.maxTries = 0
+	}
+}
+
+// WithTotalRetryDuration sets the max elapsed time of the retries, the
+// operation is not retried if the next try starts after it.
+func WithTotalRetryDuration(duration time.Duration) Option {
+	return func(o *retryOptions) {
+		o.totalRetryDuration = duration
+	}
+}
+
+// WithIsRetryableErr sets the classification of the errors, the operation
+// is not retried if the error is not retryable.
+func WithIsRetryableErr(f IsRetryable) Option {
+	return func(o *retryOptions) {
+		if f != nil {
+			o.isRetryable = f
+		}
+	}
+}
@@ -15,49 +15,70 @@ package retry
 
 import (
 	"context"
-	"math"
+	"math/rand"
 	"time"
 
-	"github.com/cenkalti/backoff"
 	"github.com/pingcap/errors"
 )
 
-// Run retries the specified function on error for at most maxRetries times.
-// It stops retry if the returned error is context.Canceled or context.DeadlineExceeded.
-func Run(initialInterval time.Duration, maxRetries uint64, f func() error) error {
-	cfg := backoff.NewExponentialBackOff()
-	cfg.InitialInterval = initialInterval
-	retryCfg := backoff.WithMaxRetries(cfg, maxRetries)
-	return backoff.Retry(func() error {
-		err := f()
-		switch errors.Cause(err) {
-		case context.Canceled, context.DeadlineExceeded:
-			err = backoff.Permanent(err)
-		}
-		return err
-	}, retryCfg)
-}
-
-// RunWithInfiniteRetry retries the specified function indefinitely, until a backoff.PermanentError is encountered.
-// notifyFunc will be called each time before sleeping with the total elapsed time.
-func RunWithInfiniteRetry(initialInterval time.Duration, f func() error, notifyFunc func(elapsed time.Duration)) error {
-	cfg := backoff.NewExponentialBackOff()
-	cfg.InitialInterval = initialInterval
-	cfg.MaxElapsedTime = math.MaxInt64
+// Operation is the action to retry.
+type Operation func() error
 
-	startTime := time.Now()
-	err := backoff.Retry(func() error {
-		err := f()
-		switch errors.Cause(err) {
-		case context.Canceled, context.DeadlineExceeded:
-			err = backoff.Permanent(err)
+// Do calls the operation until it succeeds, the returned error is not
+// retryable, the tries are exhausted, the total retry duration is exceeded or
+// the context is done. The delays between the tries grow exponentially from
+// the base delay to the max delay, with a random jitter of up to half of the
+// delay. The errors of the canceled or expired contexts are never retried.
+//
+// It returns the last error of the operation, or the error of the context if
+// the context is done while waiting for the next try.
+func Do(ctx context.Context, operation Operation, opts ...Option) error {
+	options := newRetryOptions(opts...)
+	start := time.Now()
+	for try := uint64(1); ; try++ {
+		err := operation()
+		if err == nil {
+			return nil
 		}
-
-		if _, ok := err.(*backoff.PermanentError); !ok {
-			notifyFunc(time.Since(startTime))
+		if !isRetryable(err, options.isRetryable) {
+			return err
+		}
+		if options.maxTries > 0 && try >= options.maxTries {
+			return err
 		}
-		return err
-	}, cfg)
+		delay := backoffDelay(try, options.backoffBaseDelay, options.backoffMaxDelay)
+		if options.totalRetryDuration > 0 && time.Since(start)+delay > options.totalRetryDuration {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Trace(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func isRetryable(err error, f IsRetryable) bool {
+	switch errors.Cause(err) {
+	case context.Canceled, context.DeadlineExceeded:
+		return false
+	}
+	return f(err)
+}
 
-	return err
+// backoffDelay returns the delay after the try, the delay is in the range of
+// [d/2, d], where d is the base delay doubled for every try before, and
+// limited by the max delay.
+func backoffDelay(try uint64, base, max time.Duration) time.Duration {
+	delay := base
+	for i := uint64(1); i < try && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
 }
@@ -37,14 +37,9 @@ func (s *runSuite) TestShouldRetryAtMostSpecifiedTimes(c *check.C) {
 		return errors.New("test")
 	}
 
-	err := Run(500*time.Millisecond, 3, f)
+	err := Do(context.Background(), f, WithBackoffBaseDelay(time.Millisecond), WithMaxTries(3))
 	c.Assert(err, check.ErrorMatches, "test")
-	// It's weird that backoff may retry one more time than maxTries.
-	// Because the steps in backoff.Retry is:
-	// 1. Call function
-	// 2. Compare numTries and maxTries
-	// 3. Increment numTries
-	c.Assert(callCount, check.Equals, 3+1)
+	c.Assert(callCount, check.Equals, 3)
 }
 
 func (s *runSuite) TestShouldStopOnSuccess(c *check.C) {
@@ -58,7 +53,7 @@ func (s *runSuite) TestShouldStopOnSuccess(c *check.C) {
 		return errors.New("test")
 	}
 
-	err := Run(500*time.Millisecond, 3, f)
+	err := Do(context.Background(), f, WithBackoffBaseDelay(time.Millisecond), WithMaxTries(3))
 	c.Assert(err, check.IsNil)
 	c.Assert(callCount, check.Equals, 2)
 }
@@ -71,7 +66,7 @@ func (s *runSuite) TestShouldBeCtxAware(c *check.C) {
 		return context.Canceled
 	}
 
-	err := Run(500*time.Millisecond, 3, f)
+	err := Do(context.Background(), f, WithMaxTries(3))
 	c.Assert(err, check.Equals, context.Canceled)
 	c.Assert(callCount, check.Equals, 1)
 
@@ -80,44 +75,83 @@ func (s *runSuite) TestShouldBeCtxAware(c *check.C) {
 		callCount++
 		return errors.Annotate(context.Canceled, "test")
 	}
-	err = Run(500*time.Millisecond, 3, f)
+	err = Do(context.Background(), f, WithMaxTries(3))
 	c.Assert(errors.Cause(err), check.Equals, context.Canceled)
 	c.Assert(callCount, check.Equals, 1)
+
+	// the context is done while waiting for the next try
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	callCount = 0
+	f = func() error {
+		callCount++
+		return errors.New("test")
+	}
+	err = Do(ctx, f, WithBackoffBaseDelay(time.Hour), WithInfiniteTries())
+	c.Assert(errors.Cause(err), check.Equals, context.DeadlineExceeded)
+	c.Assert(callCount, check.Equals, 1)
 }
 
-func (s *runSuite) TestInfiniteRetry(c *check.C) {
+func (s *runSuite) TestIsRetryableErr(c *check.C) {
 	defer testleak.AfterTest(c)()
+	permanent := errors.New("permanent")
 	var callCount int
 	f := func() error {
 		callCount++
-		return context.Canceled
+		if callCount == 2 {
+			return permanent
+		}
+		return errors.New("test")
 	}
 
-	var reportedElapsed time.Duration
-	notify := func(elapsed time.Duration) {
-		reportedElapsed = elapsed
+	err := Do(context.Background(), f, WithBackoffBaseDelay(time.Millisecond), WithMaxTries(10),
+		WithIsRetryableErr(func(err error) bool { return errors.Cause(err) != permanent }))
+	c.Assert(err, check.Equals, permanent)
+	c.Assert(callCount, check.Equals, 2)
+}
+
+func (s *runSuite) TestInfiniteTries(c *check.C) {
+	defer testleak.AfterTest(c)()
+	var callCount int
+	f := func() error {
+		callCount++
+		if callCount == 50 {
+			return nil
+		}
+		return errors.New("test")
 	}
 
-	err := RunWithInfiniteRetry(10*time.Millisecond, f, notify)
-	c.Assert(err, check.Equals, context.Canceled)
-	c.Assert(callCount, check.Equals, 1)
-	c.Assert(reportedElapsed, check.Equals, 0*time.Second)
+	err := Do(context.Background(), f,
+		WithBackoffBaseDelay(time.Microsecond), WithBackoffMaxDelay(time.Microsecond), WithInfiniteTries())
+	c.Assert(err, check.IsNil)
+	c.Assert(callCount, check.Equals, 50)
+}
 
-	callCount = 0
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
-	defer cancel()
-	f = func() error {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
+func (s *runSuite) TestTotalRetryDuration(c *check.C) {
+	defer testleak.AfterTest(c)()
+	var callCount int
+	f := func() error {
 		callCount++
 		return errors.New("test")
 	}
 
-	err = RunWithInfiniteRetry(10*time.Millisecond, f, notify)
-	c.Assert(err, check.Equals, context.DeadlineExceeded)
-	c.Assert(reportedElapsed, check.Greater, time.Second)
-	c.Assert(reportedElapsed, check.LessEqual, 3*time.Second)
+	start := time.Now()
+	err := Do(context.Background(), f, WithBackoffBaseDelay(10*time.Millisecond),
+		WithBackoffMaxDelay(10*time.Millisecond), WithInfiniteTries(), WithTotalRetryDuration(100*time.Millisecond))
+	c.Assert(err, check.ErrorMatches, "test")
+	c.Assert(time.Since(start), check.Less, time.Second)
+	c.Assert(callCount, check.Greater, 1)
+}
+
+func (s *runSuite) TestBackoffDelay(c *check.C) {
+	defer testleak.AfterTest(c)()
+	for i := 0; i < 100; i++ {
+		delay := backoffDelay(1, 100*time.Millisecond, time.Second)
+		c.Assert(delay >= 50*time.Millisecond && delay <= 100*time.Millisecond, check.IsTrue)
+		delay = backoffDelay(3, 100*time.Millisecond, time.Second)
+		c.Assert(delay >= 200*time.Millisecond && delay <= 400*time.Millisecond, check.IsTrue)
+		// limited by the max delay
+		delay = backoffDelay(100, 100*time.Millisecond, time.Second)
+		c.Assert(delay >= 500*time.Millisecond && delay <= time.Second, check.IsTrue)
+	}
 }
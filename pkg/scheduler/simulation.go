@@ -0,0 +1,192 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/ticdc/cdc/model"
+)
+
+// Simulation describes a what-if scheduling of the tables of a changefeed, the
+// captures are removed or added and the tables are scheduled by the scheduler
+// of the given type as the owner does.
+type Simulation struct {
+	// Scheduler is the type of the scheduler.
+	Scheduler string
+	// Workloads are the current workloads of the tables on the captures.
+	Workloads map[model.CaptureID]model.TaskWorkload
+	// Pinned are the tables pinned to their captures, they are only moved if
+	// their captures are removed.
+	Pinned map[model.TableID]struct{}
+	// RemoveCaptures are the captures removed, their tables are redistributed.
+	RemoveCaptures []model.CaptureID
+	// AddCaptures are the captures added without any table.
+	AddCaptures []model.CaptureID
+	// Rebalance rebalances the tables after the captures are changed.
+	Rebalance bool
+}
+
+// SimulatedMove is a table move expected by the simulation.
+type SimulatedMove struct {
+	TableID model.TableID   `json:"table-id"`
+	From    model.CaptureID `json:"from"`
+	To      model.CaptureID `json:"to"`
+	Reason  string          `json:"reason"`
+}
+
+// CaptureLoad is the load of the tables on a capture.
+type CaptureLoad struct {
+	Tables   int    `json:"tables"`
+	Workload uint64 `json:"workload"`
+}
+
+// SimulationResult is the expected plan and load distribution of a simulation.
+type SimulationResult struct {
+	Scheduler      string                          `json:"scheduler"`
+	Moves          []SimulatedMove                 `json:"moves"`
+	Before         map[model.CaptureID]CaptureLoad `json:"before"`
+	After          map[model.CaptureID]CaptureLoad `json:"after"`
+	SkewnessBefore float64                         `json:"skewness-before"`
+	SkewnessAfter  float64                         `json:"skewness-after"`
+}
+
+// Simulate runs the simulation, the given workloads are not modified.
+func Simulate(sim *Simulation) (*SimulationResult, error) {
+	placement := make(map[model.TableID]model.CaptureID)
+	weights := make(map[model.TableID]uint64)
+	captures := make(map[model.CaptureID]struct{}, len(sim.Workloads)+len(sim.AddCaptures))
+	for captureID, captureWorkloads := range sim.Workloads {
+		captures[captureID] = struct{}{}
+		for tableID, workload := range captureWorkloads {
+			placement[tableID] = captureID
+			weights[tableID] = workload.Workload
+		}
+	}
+	before := captureLoads(placement, weights, captures)
+	skewnessBefore := skewness(placement, weights, captures)
+
+	for _, captureID := range sim.RemoveCaptures {
+		if _, ok := sim.Workloads[captureID]; !ok {
+			return nil, errors.Errorf("capture %s is not found", captureID)
+		}
+		delete(captures, captureID)
+	}
+	for _, captureID := range sim.AddCaptures {
+		captures[captureID] = struct{}{}
+	}
+	if len(captures) == 0 {
+		return nil, errors.New("no capture is left to replicate the tables")
+	}
+
+	scheduler := NewScheduler(sim.Scheduler)
+	orphanTables := make(map[model.TableID]model.Ts)
+	for captureID, captureWorkloads := range sim.Workloads {
+		if _, ok := captures[captureID]; !ok {
+			for tableID := range captureWorkloads {
+				orphanTables[tableID] = 0
+			}
+			continue
+		}
+		clone := make(model.TaskWorkload, len(captureWorkloads))
+		for tableID, workload := range captureWorkloads {
+			clone[tableID] = workload
+		}
+		scheduler.ResetWorkloads(captureID, clone)
+	}
+	scheduler.AlignCapture(captures)
+
+	moves := make(map[model.TableID]*SimulatedMove)
+	for captureID, operations := range scheduler.DistributeTables(orphanTables) {
+		for tableID := range operations {
+			moves[tableID] = &SimulatedMove{
+				TableID: tableID,
+				From:    placement[tableID],
+				To:      captureID,
+				Reason:  "the capture is removed",
+			}
+		}
+	}
+	if sim.Rebalance {
+		_, jobs := scheduler.CalRebalanceOperates(0)
+		for tableID, job := range jobs {
+			if move, ok := moves[tableID]; ok {
+				move.To = job.To
+				continue
+			}
+			if _, ok := sim.Pinned[tableID]; ok {
+				continue
+			}
+			moves[tableID] = &SimulatedMove{
+				TableID: tableID,
+				From:    job.From,
+				To:      job.To,
+				Reason:  "rebalance the tables",
+			}
+		}
+	}
+
+	result := &SimulationResult{
+		Scheduler:      sim.Scheduler,
+		Moves:          make([]SimulatedMove, 0, len(moves)),
+		Before:         before,
+		SkewnessBefore: skewnessBefore,
+	}
+	for tableID, move := range moves {
+		placement[tableID] = move.To
+		result.Moves = append(result.Moves, *move)
+	}
+	sort.Slice(result.Moves, func(i, j int) bool {
+		return result.Moves[i].TableID < result.Moves[j].TableID
+	})
+	result.After = captureLoads(placement, weights, captures)
+	result.SkewnessAfter = skewness(placement, weights, captures)
+	return result, nil
+}
+
+// captureLoads returns the loads of the captures by the placement of the tables.
+func captureLoads(
+	placement map[model.TableID]model.CaptureID, weights map[model.TableID]uint64, captures map[model.CaptureID]struct{},
+) map[model.CaptureID]CaptureLoad {
+	loads := make(map[model.CaptureID]CaptureLoad, len(captures))
+	for captureID := range captures {
+		loads[captureID] = CaptureLoad{}
+	}
+	for tableID, captureID := range placement {
+		load := loads[captureID]
+		load.Tables++
+		load.Workload += weights[tableID]
+		loads[captureID] = load
+	}
+	return loads
+}
+
+// skewness returns the skewness of the workloads of the captures, it's zero if
+// there is no workload.
+func skewness(
+	placement map[model.TableID]model.CaptureID, weights map[model.TableID]uint64, captures map[model.CaptureID]struct{},
+) float64 {
+	w := make(workloads, len(captures))
+	w.AlignCapture(captures)
+	var total uint64
+	for tableID, captureID := range placement {
+		w.SetTable(captureID, tableID, model.WorkloadInfo{Workload: weights[tableID]})
+		total += weights[tableID]
+	}
+	if total == 0 {
+		return 0
+	}
+	return w.Skewness()
+}
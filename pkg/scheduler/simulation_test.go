@@ -0,0 +1,84 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+type simulationSuite struct{}
+
+var _ = check.Suite(&simulationSuite{})
+
+func (s *simulationSuite) TestSimulate(c *check.C) {
+	defer testleak.AfterTest(c)()
+	workloads := map[model.CaptureID]model.TaskWorkload{
+		"capture1": {1: {Workload: 1}, 2: {Workload: 1}},
+		"capture2": {3: {Workload: 1}, 4: {Workload: 1}},
+		"capture3": {5: {Workload: 1}, 6: {Workload: 1}},
+	}
+
+	// the tables of the removed capture are distributed to the others
+	result, err := Simulate(&Simulation{
+		Scheduler:      "table-number",
+		Workloads:      workloads,
+		RemoveCaptures: []model.CaptureID{"capture3"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(result.SkewnessBefore, check.Equals, float64(0))
+	c.Assert(result.Moves, check.HasLen, 2)
+	for i, move := range result.Moves {
+		c.Assert(move.TableID, check.Equals, model.TableID(i+5))
+		c.Assert(move.From, check.Equals, "capture3")
+	}
+	c.Assert(result.After, check.DeepEquals, map[model.CaptureID]CaptureLoad{
+		"capture1": {Tables: 3, Workload: 3},
+		"capture2": {Tables: 3, Workload: 3},
+	})
+	// the input is not modified
+	c.Assert(workloads["capture1"], check.HasLen, 2)
+
+	// the tables are rebalanced to the added capture except the pinned ones
+	sim := &Simulation{
+		Scheduler: "table-number",
+		Workloads: map[model.CaptureID]model.TaskWorkload{
+			"capture1": {1: {Workload: 1}, 2: {Workload: 1}, 3: {Workload: 1}, 4: {Workload: 1}},
+		},
+		AddCaptures: []model.CaptureID{"capture2"},
+		Rebalance:   true,
+	}
+	result, err = Simulate(sim)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Moves, check.HasLen, 2)
+	for _, move := range result.Moves {
+		c.Assert(move.To, check.Equals, "capture2")
+	}
+	c.Assert(result.After["capture2"], check.Equals, CaptureLoad{Tables: 2, Workload: 2})
+	c.Assert(result.SkewnessAfter, check.Equals, float64(0))
+	sim.Pinned = map[model.TableID]struct{}{1: {}, 2: {}, 3: {}, 4: {}}
+	result, err = Simulate(sim)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Moves, check.HasLen, 0)
+	c.Assert(result.After["capture2"], check.Equals, CaptureLoad{})
+
+	_, err = Simulate(&Simulation{Workloads: workloads, RemoveCaptures: []model.CaptureID{"capture5"}})
+	c.Assert(err, check.ErrorMatches, ".*capture5 is not found.*")
+	_, err = Simulate(&Simulation{
+		Workloads:      workloads,
+		RemoveCaptures: []model.CaptureID{"capture1", "capture2", "capture3"},
+	})
+	c.Assert(err, check.ErrorMatches, ".*no capture is left.*")
+}
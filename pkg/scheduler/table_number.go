@@ -84,8 +84,11 @@ func (t *TableNumberScheduler) CalRebalanceOperates(targetSkewness float64) (
 // DistributeTables implements the Scheduler interface
 func (t *TableNumberScheduler) DistributeTables(tableIDs map[model.TableID]model.Ts) map[model.CaptureID]map[model.TableID]*model.TableOperation {
 	result := make(map[model.CaptureID]map[model.TableID]*model.TableOperation, len(t.workloads))
+	// maintain the total workloads incrementally, summing up the workloads of
+	// all tables for each new table is quadratic in the number of tables
+	totals := t.workloads.TotalWorkloads()
 	for tableID, boundaryTs := range tableIDs {
-		captureID := t.workloads.SelectIdleCapture()
+		captureID := selectIdleCapture(totals)
 		operations := result[captureID]
 		if operations == nil {
 			operations = make(map[model.TableID]*model.TableOperation)
@@ -94,7 +97,11 @@ func (t *TableNumberScheduler) DistributeTables(tableIDs map[model.TableID]model
 		operations[tableID] = &model.TableOperation{
 			BoundaryTs: boundaryTs,
 		}
+		if old, exist := t.workloads[captureID][tableID]; exist {
+			totals[captureID] -= old.Workload
+		}
 		t.workloads.SetTable(captureID, tableID, model.WorkloadInfo{Workload: 1})
+		totals[captureID]++
 	}
 	return result
 }
@@ -15,6 +15,7 @@ package scheduler
 
 import (
 	"fmt"
+	"testing"
 
 	"github.com/pingcap/ticdc/cdc/model"
 	"github.com/pingcap/ticdc/pkg/util/testleak"
@@ -113,3 +114,36 @@ func (s *tableNumberSuite) TestCalRebalanceOperates(c *check.C) {
 	}
 	c.Assert(fmt.Sprintf("%.2f%%", skewness*100), check.Equals, "0.00%")
 }
+
+func (s *tableNumberSuite) TestDistributeManyTables(c *check.C) {
+	defer testleak.AfterTest(c)()
+	scheduler := newTableNumberScheduler()
+	scheduler.AlignCapture(map[model.CaptureID]struct{}{"capture1": {}, "capture2": {}, "capture3": {}})
+	tableToAdd := make(map[model.TableID]model.Ts, 100000)
+	for i := 0; i < 100000; i++ {
+		tableToAdd[model.TableID(i)] = 1
+	}
+	result := scheduler.DistributeTables(tableToAdd)
+	c.Assert(len(result), check.Equals, 3)
+	for _, ops := range result {
+		c.Assert(len(ops) >= 33333 && len(ops) <= 33334, check.IsTrue)
+	}
+}
+
+func BenchmarkDistributeTables(b *testing.B) {
+	tableToAdd := make(map[model.TableID]model.Ts, 100000)
+	for i := 0; i < 100000; i++ {
+		tableToAdd[model.TableID(i)] = 1
+	}
+	captures := make(map[model.CaptureID]struct{})
+	for i := 0; i < 16; i++ {
+		captures[fmt.Sprintf("capture%d", i)] = struct{}{}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scheduler := newTableNumberScheduler()
+		scheduler.AlignCapture(captures)
+		scheduler.DistributeTables(tableToAdd)
+	}
+}
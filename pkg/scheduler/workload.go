@@ -88,13 +88,27 @@ func (w workloads) Skewness() float64 {
 }
 
 func (w workloads) SelectIdleCapture() model.CaptureID {
-	minWorkload := uint64(math.MaxUint64)
-	var minCapture model.CaptureID
+	return selectIdleCapture(w.TotalWorkloads())
+}
+
+// TotalWorkloads returns the total workload of each capture.
+func (w workloads) TotalWorkloads() map[model.CaptureID]uint64 {
+	totals := make(map[model.CaptureID]uint64, len(w))
 	for captureID, captureWorkloads := range w {
 		var totalWorkloadInCapture uint64
 		for _, workload := range captureWorkloads {
 			totalWorkloadInCapture += workload.Workload
 		}
+		totals[captureID] = totalWorkloadInCapture
+	}
+	return totals
+}
+
+// selectIdleCapture returns the capture with the minimum total workload.
+func selectIdleCapture(totals map[model.CaptureID]uint64) model.CaptureID {
+	minWorkload := uint64(math.MaxUint64)
+	var minCapture model.CaptureID
+	for captureID, totalWorkloadInCapture := range totals {
 		if minWorkload > totalWorkloadInCapture {
 			minWorkload = totalWorkloadInCapture
 			minCapture = captureID
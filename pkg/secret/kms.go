@@ -0,0 +1,45 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// kmsBackend decrypts the secrets by AWS KMS, the name of a secret is the base64
+// encoded ciphertext. The region and the credential are loaded in the same way as
+// the AWS CLI, e.g. from AWS_REGION and AWS_ACCESS_KEY_ID.
+type kmsBackend struct{}
+
+func (kmsBackend) Get(ctx context.Context, name string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(name)
+	if err != nil {
+		return "", err
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return "", err
+	}
+	output, err := kms.New(sess).DecryptWithContext(ctx, &kms.DecryptInput{CiphertextBlob: ciphertext})
+	if err != nil {
+		return "", err
+	}
+	return string(output.Plaintext), nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+const (
+	redacted = "******"
+	// redactedPlaceholder is used in the uri before it is formatted, as `*` is escaped
+	redactedPlaceholder = "__redacted__"
+)
+
+// sensitiveParams are the query parameters of sink uris holding credentials.
+var sensitiveParams = map[string]struct{}{
+	"password":          {},
+	"sasl-password":     {},
+	"access-key":        {},
+	"secret-access-key": {},
+	"session-token":     {},
+	"account-key":       {},
+	"sas-token":         {},
+	"credentials-file":  {},
+	"auth.token":        {},
+	"token":             {},
+	"encryption-key":    {},
+}
+
+// RedactURI hides the credentials in a uri, such as the password in the user info
+// and the values of the sensitive query parameters. The secret references are kept,
+// as they do not contain the secrets themselves.
+func RedactURI(uri string) string {
+	u, refs, err := parseURI(uri)
+	if err != nil {
+		// the uri can not be parsed, nothing is safe to be shown
+		return redacted
+	}
+	changed := false
+	if u.User != nil {
+		username := u.User.Username()
+		// the user name of the pulsar sink uri is the token
+		if strings.HasPrefix(strings.ToLower(u.Scheme), "pulsar") && !isReference(username) {
+			username = redactedPlaceholder
+			changed = true
+		}
+		if password, ok := u.User.Password(); ok {
+			if !isReference(password) {
+				password = redactedPlaceholder
+				changed = true
+			}
+			u.User = url.UserPassword(username, password)
+		} else {
+			u.User = url.User(username)
+		}
+	}
+	query := u.Query()
+	queryChanged := false
+	for key, values := range query {
+		if _, ok := sensitiveParams[strings.ToLower(key)]; !ok {
+			continue
+		}
+		for i, v := range values {
+			if !isReference(v) {
+				values[i] = redactedPlaceholder
+				queryChanged = true
+			}
+		}
+	}
+	if queryChanged {
+		u.RawQuery = query.Encode()
+	}
+	if !changed && !queryChanged {
+		return uri
+	}
+	s := strings.ReplaceAll(u.String(), redactedPlaceholder, redacted)
+	for i, ref := range refs {
+		s = strings.Replace(s, placeholder(i), ref, 1)
+	}
+	return s
+}
+
+// ParseURI parses a uri which may contain secret references without resolving
+// them. The references are replaced by placeholders in the result, so only the
+// parts without secrets should be read from it.
+func ParseURI(uri string) (*url.URL, error) {
+	u, _, err := parseURI(uri)
+	return u, cerror.WrapError(cerror.ErrSinkURIInvalid, err)
+}
+
+func parseURI(uri string) (*url.URL, []string, error) {
+	// the secret references are not valid in the user info of a uri, replace them
+	// with placeholders before parsing
+	refs := referencePattern.FindAllString(uri, -1)
+	for i, ref := range refs {
+		uri = strings.Replace(uri, ref, placeholder(i), 1)
+	}
+	u, err := url.Parse(uri)
+	return u, refs, err
+}
+
+func isReference(s string) bool {
+	return strings.Contains(s, placeholderPrefix)
+}
+
+const placeholderPrefix = "__secret_ref_"
+
+func placeholder(i int) string {
+	return placeholderPrefix + strconv.Itoa(i) + "__"
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secret resolves the secrets referenced in sink uris and credentials,
+// so they are not stored in plaintext in etcd.
+//
+// A secret is referenced by `${secret:<backend>:<name>}`, for example:
+//
+//	mysql://root:${secret:env:DOWNSTREAM_PASSWORD}@127.0.0.1:3306/
+//
+// The supported backends are:
+//
+//	env:   the environment variable of the capture, e.g. ${secret:env:MYSQL_PASSWORD}
+//	file:  the content of a file, e.g. ${secret:file:/etc/cdc/mysql-password}
+//	vault: a field of a HashiCorp Vault secret, the address and the token are read
+//	       from VAULT_ADDR and VAULT_TOKEN, e.g. ${secret:vault:secret/data/cdc#password}
+//	kms:   a base64 encoded ciphertext decrypted by AWS KMS, e.g. ${secret:kms:AQICAHh...}
+package secret
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+// Backend gets the secret by its name from an external store.
+type Backend interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+var (
+	referencePattern = regexp.MustCompile(`\$\{secret:([a-z]+):([^}]+)\}`)
+
+	backendsMu sync.RWMutex
+	backends   = map[string]Backend{
+		"env":   envBackend{},
+		"file":  fileBackend{},
+		"vault": vaultBackend{},
+		"kms":   kmsBackend{},
+	}
+)
+
+// RegisterBackend registers a secret backend, the backend with the same type is replaced.
+func RegisterBackend(tp string, backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[tp] = backend
+}
+
+func getBackend(tp string) (Backend, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	backend, ok := backends[tp]
+	return backend, ok
+}
+
+// ContainsReference returns whether s references any secret.
+func ContainsReference(s string) bool {
+	return referencePattern.MatchString(s)
+}
+
+// Resolve replaces the secret references in s with the secrets.
+func Resolve(ctx context.Context, s string) (string, error) {
+	return resolve(ctx, s, func(v string) string { return v })
+}
+
+// ResolveURI replaces the secret references in a uri with the escaped secrets,
+// so the secrets can contain the reserved characters of uri.
+func ResolveURI(ctx context.Context, uri string) (string, error) {
+	return resolve(ctx, uri, func(v string) string {
+		// `+` is decoded to space in the query but not in the user info
+		return strings.ReplaceAll(url.QueryEscape(v), "+", "%20")
+	})
+}
+
+func resolve(ctx context.Context, s string, escape func(string) string) (string, error) {
+	var resolveErr error
+	resolved := referencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if resolveErr != nil {
+			return ref
+		}
+		match := referencePattern.FindStringSubmatch(ref)
+		tp, name := match[1], match[2]
+		backend, ok := getBackend(tp)
+		if !ok {
+			resolveErr = cerror.ErrResolveSecretFailed.GenWithStack("unknown secret backend %s", tp)
+			return ref
+		}
+		v, err := backend.Get(ctx, name)
+		if err != nil {
+			resolveErr = cerror.WrapError(cerror.ErrResolveSecretFailed, err)
+			return ref
+		}
+		return escape(v)
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return resolved, nil
+}
+
+type envBackend struct{}
+
+func (envBackend) Get(ctx context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", cerror.ErrResolveSecretFailed.GenWithStack("environment variable %s is not set", name)
+	}
+	return v, nil
+}
+
+type fileBackend struct{}
+
+func (fileBackend) Get(ctx context.Context, name string) (string, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	// the files are usually ended with a new line
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/httputil"
+	"github.com/pingcap/ticdc/pkg/security"
+)
+
+const defaultVaultField = "value"
+
+// vaultBackend reads the secrets from the KV secrets engine of HashiCorp Vault.
+// The name of a secret is `<path>#<field>`, the field is `value` if omitted.
+type vaultBackend struct{}
+
+type vaultResponse struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+func (vaultBackend) Get(ctx context.Context, name string) (string, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return "", cerror.ErrResolveSecretFailed.GenWithStack("VAULT_ADDR is not set")
+	}
+	path, field := name, defaultVaultField
+	if i := strings.LastIndex(name, "#"); i >= 0 {
+		path, field = name[:i], name[i+1:]
+	}
+	client, err := httputil.NewClient(&security.Credential{CAPath: os.Getenv("VAULT_CACERT")})
+	if err != nil {
+		return "", err
+	}
+	uri := fmt.Sprintf("%s/v1/%s", strings.TrimRight(addr, "/"), strings.TrimLeft(path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", cerror.ErrResolveSecretFailed.GenWithStack(
+			"read vault secret %s failed, status: %d, body: %s", path, resp.StatusCode, body)
+	}
+	var vaultResp vaultResponse
+	if err := json.Unmarshal(body, &vaultResp); err != nil {
+		return "", err
+	}
+	data := vaultResp.Data
+	// the data of KV secrets engine version 2 is nested with metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMeta := data["metadata"]; hasMeta {
+			data = nested
+		}
+	}
+	v, ok := data[field].(string)
+	if !ok {
+		return "", cerror.ErrResolveSecretFailed.GenWithStack("field %s is not found in vault secret %s", field, path)
+	}
+	return v, nil
+}
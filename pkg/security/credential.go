@@ -15,6 +15,7 @@ package security
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/tidb-tools/pkg/utils"
@@ -29,6 +30,8 @@ type Credential struct {
 	CertPath      string   `toml:"cert-path" json:"cert-path"`
 	KeyPath       string   `toml:"key-path" json:"key-path"`
 	CertAllowedCN []string `toml:"cert-allowed-cn" json:"cert-allowed-cn"`
+
+	TLSOptions
 }
 
 // IsTLSEnabled checks whether TLS is enabled or not.
@@ -54,15 +57,124 @@ func (s *Credential) ToGRPCDialOption() (grpc.DialOption, error) {
 	return grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)), nil
 }
 
-// ToTLSConfig generates tls's config from *Security
+// ToTLSConfig generates tls's config from *Security. The client certificate
+// and the CA are reloaded once the files are modified, so the new connections
+// use the rotated certificates without restarting the client.
 func (s *Credential) ToTLSConfig() (*tls.Config, error) {
 	cfg, err := utils.ToTLSConfig(s.CAPath, s.CertPath, s.KeyPath)
-	return cfg, cerror.WrapError(cerror.ErrToTLSConfigFailed, err)
+	if err != nil || cfg == nil {
+		return nil, cerror.WrapError(cerror.ErrToTLSConfigFailed, err)
+	}
+	if err := s.reloadCertificates(cfg); err != nil {
+		return nil, err
+	}
+	opts := s.tlsOptions()
+	if err := opts.apply(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
 // ToTLSConfigWithVerify generates tls's config from *Security and requires
-// verifing remote cert common name.
+// verifing remote cert common name. The certificates are reloaded like the
+// ones of ToTLSConfig.
 func (s *Credential) ToTLSConfigWithVerify() (*tls.Config, error) {
 	cfg, err := utils.ToTLSConfigWithVerify(s.CAPath, s.CertPath, s.KeyPath, s.CertAllowedCN)
-	return cfg, cerror.WrapError(cerror.ErrToTLSConfigFailed, err)
+	if err != nil || cfg == nil {
+		return nil, cerror.WrapError(cerror.ErrToTLSConfigFailed, err)
+	}
+	if err := s.reloadCertificates(cfg); err != nil {
+		return nil, err
+	}
+	opts := s.tlsOptions()
+	if err := opts.apply(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// reloadCertificates makes the client tls config get the certificate and the
+// CA pool from a certReloader in every handshake. The tls config can't change
+// the CA pool of a handshake, so the server certificate is verified against
+// the reloaded CA pool by VerifyPeerCertificate instead. The server name is
+// not verified then, the servers are identified by the CA and the allowed
+// common names like the other components of the cluster.
+func (s *Credential) reloadCertificates(cfg *tls.Config) error {
+	reloader, err := newCertReloader(s)
+	if err != nil {
+		return err
+	}
+	if s.CertPath != "" {
+		cfg.Certificates = nil
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := reloader.get()
+			return cert, nil
+		}
+	}
+	verifyCommonName := cfg.VerifyPeerCertificate
+	cfg.RootCAs = nil
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		_, caPool := reloader.get()
+		chains, err := verifyServerCertificate(rawCerts, caPool)
+		if err != nil {
+			return err
+		}
+		if verifyCommonName != nil {
+			return verifyCommonName(rawCerts, chains)
+		}
+		return nil
+	}
+	return nil
+}
+
+// ToServerTLSConfig generates the tls config of servers. The certificate of the
+// client is verified by the CA, and its common name must be in CertAllowedCN if
+// CertAllowedCN is not empty. The certificates are reloaded once the files are
+// modified, so they can be rotated without restarting the server.
+func (s *Credential) ToServerTLSConfig() (*tls.Config, error) {
+	if !s.IsTLSEnabled() {
+		return nil, nil
+	}
+	opts := s.tlsOptions()
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	reloader, err := newCertReloader(s)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth := tls.VerifyClientCertIfGiven
+	var verifyPeerCertificate func([][]byte, [][]*x509.Certificate) error
+	if len(s.CertAllowedCN) != 0 {
+		clientAuth = tls.RequireAndVerifyClientCert
+		verifyPeerCertificate = verifyCommonName(s.CertAllowedCN)
+	}
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, _ := reloader.get()
+		if cert == nil {
+			return nil, cerror.ErrToTLSConfigFailed.GenWithStack("server certificate is not specified")
+		}
+		return cert, nil
+	}
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			_, caPool := reloader.get()
+			cfg := &tls.Config{
+				MinVersion:            tls.VersionTLS12,
+				GetCertificate:        getCertificate,
+				ClientCAs:             caPool,
+				ClientAuth:            clientAuth,
+				VerifyPeerCertificate: verifyPeerCertificate,
+				NextProtos:            []string{"h2", "http/1.1"},
+			}
+			// the options are validated above
+			_ = opts.apply(cfg)
+			return cfg, nil
+		},
+	}
+	_ = opts.apply(cfg)
+	return cfg, nil
 }
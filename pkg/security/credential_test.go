@@ -0,0 +1,312 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type credentialSuite struct{}
+
+var _ = check.Suite(&credentialSuite{})
+
+func certPath(name string) string {
+	return filepath.Join("..", "..", "tests", "_certificates", name)
+}
+
+func (s *credentialSuite) TestServerTLSConfig(c *check.C) {
+	defer testleak.AfterTest(c)()
+	newServer := func(allowedCN []string) *httptest.Server {
+		serverTLS, err := (&Credential{
+			CAPath:        certPath("ca.pem"),
+			CertPath:      certPath("server.pem"),
+			KeyPath:       certPath("server-key.pem"),
+			CertAllowedCN: allowedCN,
+		}).ToServerTLSConfig()
+		c.Assert(err, check.IsNil)
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		server.TLS = serverTLS
+		server.StartTLS()
+		return server
+	}
+	get := func(server *httptest.Server, credential *Credential) error {
+		clientTLS, err := credential.ToTLSConfig()
+		c.Assert(err, check.IsNil)
+		transport := &http.Transport{TLSClientConfig: clientTLS}
+		defer transport.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	clientCredential := &Credential{
+		CAPath:   certPath("ca.pem"),
+		CertPath: certPath("client.pem"),
+		KeyPath:  certPath("client-key.pem"),
+	}
+	noCertCredential := &Credential{CAPath: certPath("ca.pem")}
+
+	server := newServer([]string{"client"})
+	c.Assert(get(server, clientCredential), check.IsNil)
+	c.Assert(get(server, noCertCredential), check.NotNil)
+	server.Close()
+
+	server = newServer([]string{"other"})
+	c.Assert(get(server, clientCredential), check.NotNil)
+	server.Close()
+
+	// the client certificate is optional without allowed common names
+	server = newServer(nil)
+	c.Assert(get(server, clientCredential), check.IsNil)
+	c.Assert(get(server, noCertCredential), check.IsNil)
+	server.Close()
+
+	tlsCfg, err := (&Credential{}).ToServerTLSConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(tlsCfg, check.IsNil)
+}
+
+func (s *credentialSuite) TestCertReload(c *check.C) {
+	defer testleak.AfterTest(c)()
+	interval := certCheckInterval
+	certCheckInterval = 0
+	defer func() {
+		certCheckInterval = interval
+	}()
+
+	dir := c.MkDir()
+	copyFile := func(src, dst string, modTime time.Time) {
+		data, err := ioutil.ReadFile(certPath(src))
+		c.Assert(err, check.IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, dst), data, 0o600), check.IsNil)
+		c.Assert(os.Chtimes(filepath.Join(dir, dst), modTime, modTime), check.IsNil)
+	}
+	now := time.Now()
+	copyFile("ca.pem", "ca.pem", now)
+	copyFile("server.pem", "cert.pem", now)
+	copyFile("server-key.pem", "key.pem", now)
+	reloader, err := newCertReloader(&Credential{
+		CAPath:   filepath.Join(dir, "ca.pem"),
+		CertPath: filepath.Join(dir, "cert.pem"),
+		KeyPath:  filepath.Join(dir, "key.pem"),
+	})
+	c.Assert(err, check.IsNil)
+	commonName := func() string {
+		cert, caPool := reloader.get()
+		c.Assert(caPool, check.NotNil)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		c.Assert(err, check.IsNil)
+		return leaf.Subject.CommonName
+	}
+	c.Assert(commonName(), check.Equals, "tidb-server")
+
+	// the previous certificate is kept if the key pair is mismatched
+	copyFile("client.pem", "cert.pem", now.Add(time.Second))
+	c.Assert(commonName(), check.Equals, "tidb-server")
+
+	copyFile("client-key.pem", "key.pem", now.Add(time.Second))
+	c.Assert(commonName(), check.Equals, "client")
+
+	_, err = tls.LoadX509KeyPair(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	c.Assert(err, check.IsNil)
+}
+
+func (s *credentialSuite) TestClientCertReload(c *check.C) {
+	defer testleak.AfterTest(c)()
+	interval := certCheckInterval
+	certCheckInterval = 0
+	defer func() {
+		certCheckInterval = interval
+	}()
+
+	dir := c.MkDir()
+	copyFile := func(src, dst string, modTime time.Time) {
+		data, err := ioutil.ReadFile(certPath(src))
+		c.Assert(err, check.IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, dst), data, 0o600), check.IsNil)
+		c.Assert(os.Chtimes(filepath.Join(dir, dst), modTime, modTime), check.IsNil)
+	}
+	now := time.Now()
+	copyFile("ca.pem", "ca.pem", now)
+	copyFile("client.pem", "cert.pem", now)
+	copyFile("client-key.pem", "key.pem", now)
+	tlsCfg, err := (&Credential{
+		CAPath:   filepath.Join(dir, "ca.pem"),
+		CertPath: filepath.Join(dir, "cert.pem"),
+		KeyPath:  filepath.Join(dir, "key.pem"),
+	}).ToTLSConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(tlsCfg.Certificates, check.HasLen, 0)
+	commonName := func() string {
+		cert, err := tlsCfg.GetClientCertificate(&tls.CertificateRequestInfo{})
+		c.Assert(err, check.IsNil)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		c.Assert(err, check.IsNil)
+		return leaf.Subject.CommonName
+	}
+	c.Assert(commonName(), check.Equals, "client")
+
+	copyFile("server.pem", "cert.pem", now.Add(time.Second))
+	copyFile("server-key.pem", "key.pem", now.Add(time.Second))
+	c.Assert(commonName(), check.Equals, "tidb-server")
+
+	// the certificate isn't reloaded without the client certificate
+	tlsCfg, err = (&Credential{CAPath: filepath.Join(dir, "ca.pem")}).ToTLSConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(tlsCfg.GetClientCertificate, check.IsNil)
+}
+
+func (s *credentialSuite) TestCAReload(c *check.C) {
+	defer testleak.AfterTest(c)()
+	interval := certCheckInterval
+	certCheckInterval = 0
+	defer func() {
+		certCheckInterval = interval
+	}()
+
+	serverTLS, err := (&Credential{
+		CAPath:   certPath("ca.pem"),
+		CertPath: certPath("server.pem"),
+		KeyPath:  certPath("server-key.pem"),
+	}).ToServerTLSConfig()
+	c.Assert(err, check.IsNil)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = serverTLS
+	server.StartTLS()
+	defer server.Close()
+
+	dir := c.MkDir()
+	caPath := filepath.Join(dir, "ca.pem")
+	writeCA := func(data []byte, modTime time.Time) {
+		c.Assert(ioutil.WriteFile(caPath, data, 0o600), check.IsNil)
+		c.Assert(os.Chtimes(caPath, modTime, modTime), check.IsNil)
+	}
+	ca, err := ioutil.ReadFile(certPath("ca.pem"))
+	c.Assert(err, check.IsNil)
+	now := time.Now()
+	writeCA(ca, now)
+	clientTLS, err := (&Credential{CAPath: caPath}).ToTLSConfig()
+	c.Assert(err, check.IsNil)
+	get := func() error {
+		transport := &http.Transport{TLSClientConfig: clientTLS}
+		defer transport.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	c.Assert(get(), check.IsNil)
+
+	// the server certificate isn't signed by the new CA
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, check.IsNil)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "other-ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, check.IsNil)
+	writeCA(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), now.Add(time.Second))
+	c.Assert(get(), check.ErrorMatches, "(?s).*verify the certificate of the peer failed.*")
+
+	writeCA(ca, now.Add(2*time.Second))
+	c.Assert(get(), check.IsNil)
+}
+
+func (s *credentialSuite) TestTLSOptions(c *check.C) {
+	defer testleak.AfterTest(c)()
+	credential := &Credential{
+		CAPath:   certPath("ca.pem"),
+		CertPath: certPath("client.pem"),
+		KeyPath:  certPath("client-key.pem"),
+		TLSOptions: TLSOptions{
+			MinVersion:   "1.2",
+			CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "tls_ecdhe_rsa_with_aes_256_gcm_sha384"},
+		},
+	}
+	cfg, err := credential.ToTLSConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.MinVersion, check.Equals, uint16(tls.VersionTLS12))
+	c.Assert(cfg.CipherSuites, check.DeepEquals, []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	})
+
+	// the default options are used by the credentials without options
+	c.Assert(SetDefaultTLSOptions(TLSOptions{MinVersion: "TLS1.3"}), check.IsNil)
+	defer func() {
+		c.Assert(SetDefaultTLSOptions(TLSOptions{}), check.IsNil)
+	}()
+	cfg, err = (&Credential{CAPath: certPath("ca.pem")}).ToTLSConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.MinVersion, check.Equals, uint16(tls.VersionTLS13))
+	cfg, err = credential.ToTLSConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.MinVersion, check.Equals, uint16(tls.VersionTLS12))
+
+	// a client only supporting TLS 1.2 can't connect to the server requiring TLS 1.3
+	serverTLS, err := (&Credential{
+		CAPath:   certPath("ca.pem"),
+		CertPath: certPath("server.pem"),
+		KeyPath:  certPath("server-key.pem"),
+	}).ToServerTLSConfig()
+	c.Assert(err, check.IsNil)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = serverTLS
+	server.StartTLS()
+	defer server.Close()
+	clientTLS, err := credential.ToTLSConfig()
+	c.Assert(err, check.IsNil)
+	clientTLS.MaxVersion = tls.VersionTLS12
+	transport := &http.Transport{TLSClientConfig: clientTLS}
+	defer transport.CloseIdleConnections()
+	_, err = (&http.Client{Transport: transport}).Get(server.URL)
+	c.Assert(err, check.ErrorMatches, ".*protocol version.*")
+
+	c.Assert(SetDefaultTLSOptions(TLSOptions{MinVersion: "1.4"}), check.ErrorMatches, ".*unsupported tls version 1.4.*")
+	_, err = (&Credential{
+		CAPath:     certPath("ca.pem"),
+		TLSOptions: TLSOptions{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+	}).ToTLSConfig()
+	c.Assert(err, check.ErrorMatches, ".*unsupported cipher suite TLS_RSA_WITH_RC4_128_SHA.*")
+}
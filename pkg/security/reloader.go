@@ -0,0 +1,188 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// certCheckInterval is the minimal interval to check whether the certificate files are modified.
+var certCheckInterval = 10 * time.Second
+
+// certReloader holds the CA and the key pair of a Credential, and reloads them
+// once the files are modified, so the certificates can be rotated without restart.
+type certReloader struct {
+	credential *Credential
+
+	mu        sync.Mutex
+	modTimes  []time.Time
+	lastCheck time.Time
+	cert      *tls.Certificate
+	caPool    *x509.CertPool
+}
+
+func newCertReloader(credential *Credential) (*certReloader, error) {
+	r := &certReloader{credential: credential}
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.modTimes = modTimes
+	r.lastCheck = time.Now()
+	return r, nil
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.credential.CAPath}
+	if r.credential.CertPath != "" {
+		files = append(files, r.credential.CertPath, r.credential.KeyPath)
+	}
+	return files
+}
+
+func (r *certReloader) statFiles() ([]time.Time, error) {
+	files := r.files()
+	modTimes := make([]time.Time, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrToTLSConfigFailed, err)
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+func (r *certReloader) load() error {
+	ca, err := ioutil.ReadFile(r.credential.CAPath)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrToTLSConfigFailed, err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(ca) {
+		return cerror.ErrToTLSConfigFailed.GenWithStack("failed to append ca certs from %s", r.credential.CAPath)
+	}
+	var cert *tls.Certificate
+	if r.credential.CertPath != "" {
+		keyPair, err := tls.LoadX509KeyPair(r.credential.CertPath, r.credential.KeyPath)
+		if err != nil {
+			return cerror.WrapError(cerror.ErrToTLSConfigFailed, err)
+		}
+		cert = &keyPair
+	}
+	r.caPool = caPool
+	r.cert = cert
+	return nil
+}
+
+// get returns the current key pair and CA pool, the files are reloaded if they
+// are modified. The previous certificates are kept if the reload fails, for
+// example, the files are in the middle of being replaced.
+func (r *certReloader) get() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.lastCheck) < certCheckInterval {
+		return r.cert, r.caPool
+	}
+	r.lastCheck = time.Now()
+	modTimes, err := r.statFiles()
+	if err != nil {
+		log.Warn("check certificate files failed", zap.Error(err))
+		return r.cert, r.caPool
+	}
+	changed := false
+	for i := range modTimes {
+		if !modTimes[i].Equal(r.modTimes[i]) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return r.cert, r.caPool
+	}
+	if err := r.load(); err != nil {
+		log.Warn("reload certificates failed, keep using the previous ones", zap.Error(err))
+		return r.cert, r.caPool
+	}
+	r.modTimes = modTimes
+	log.Info("certificates reloaded",
+		zap.String("ca", r.credential.CAPath),
+		zap.String("cert", r.credential.CertPath))
+	return r.cert, r.caPool
+}
+
+// verifyCommonName returns a function verifying the common name of the peer
+// certificate is in the allowed list.
+func verifyCommonName(allowedCN []string) func([][]byte, [][]*x509.Certificate) error {
+	checkCN := make(map[string]struct{}, len(allowedCN))
+	for _, cn := range allowedCN {
+		checkCN[cn] = struct{}{}
+	}
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		for _, chain := range verifiedChains {
+			if len(chain) == 0 {
+				continue
+			}
+			if _, ok := checkCN[chain[0].Subject.CommonName]; ok {
+				return nil
+			}
+		}
+		var cn string
+		if len(verifiedChains) > 0 && len(verifiedChains[0]) > 0 {
+			cn = verifiedChains[0][0].Subject.CommonName
+		}
+		return cerror.ErrCertCNNotAllowed.GenWithStackByArgs(cn)
+	}
+}
+
+// verifyServerCertificate verifies the certificate chain of a server against
+// the CA pool, and returns the verified chains.
+func verifyServerCertificate(rawCerts [][]byte, caPool *x509.CertPool) ([][]*x509.Certificate, error) {
+	if len(rawCerts) == 0 {
+		return nil, cerror.ErrVerifyCertificateFailed.GenWithStack("no server certificate")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrVerifyCertificateFailed, err)
+		}
+		certs = append(certs, cert)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         caPool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrVerifyCertificateFailed, err)
+	}
+	return chains, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"crypto/tls"
+	"strings"
+	"sync"
+
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+)
+
+// TLSOptions restricts the TLS versions and the cipher suites, for example, to
+// the ones approved by FIPS 140-2 in regulated environments.
+type TLSOptions struct {
+	// MinVersion is the minimal TLS version, one of 1.0, 1.1, 1.2 and 1.3.
+	// The default is 1.2 for servers and the default of Go for clients.
+	MinVersion string `toml:"min-tls-version" json:"min-tls-version"`
+	// CipherSuites are the names of the cipher suites of TLS 1.0-1.2, such as
+	// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. The cipher suites of TLS 1.3 are
+	// not configurable. The default cipher suites of Go are used if it's empty.
+	CipherSuites []string `toml:"cipher-suites" json:"cipher-suites"`
+}
+
+var (
+	defaultTLSOptionsMu sync.RWMutex
+	defaultTLSOptions   TLSOptions
+)
+
+// SetDefaultTLSOptions sets the TLS options of the credentials without their
+// own options, such as the credentials specified in sink uris, so all TLS
+// clients and servers of the process follow the same policy.
+func SetDefaultTLSOptions(opts TLSOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	defaultTLSOptionsMu.Lock()
+	defer defaultTLSOptionsMu.Unlock()
+	defaultTLSOptions = opts
+	return nil
+}
+
+func getDefaultTLSOptions() TLSOptions {
+	defaultTLSOptionsMu.RLock()
+	defer defaultTLSOptionsMu.RUnlock()
+	return defaultTLSOptions
+}
+
+func (o *TLSOptions) isEmpty() bool {
+	return o.MinVersion == "" && len(o.CipherSuites) == 0
+}
+
+func (o *TLSOptions) validate() error {
+	if _, err := o.minVersion(); err != nil {
+		return err
+	}
+	_, err := o.cipherSuites()
+	return err
+}
+
+func (o *TLSOptions) minVersion() (uint16, error) {
+	switch strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(o.MinVersion)), "TLS") {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, cerror.ErrInvalidTLSOptions.GenWithStack("unsupported tls version %s", o.MinVersion)
+}
+
+func (o *TLSOptions) cipherSuites() ([]uint16, error) {
+	if len(o.CipherSuites) == 0 {
+		return nil, nil
+	}
+	// only the secure cipher suites implemented by Go are allowed
+	supported := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, 0, len(o.CipherSuites))
+	for _, name := range o.CipherSuites {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		id, ok := supported[name]
+		if !ok {
+			return nil, cerror.ErrInvalidTLSOptions.GenWithStack("unsupported cipher suite %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// apply sets the TLS versions and the cipher suites of cfg, the fields of cfg
+// are kept if the options are not specified.
+func (o *TLSOptions) apply(cfg *tls.Config) error {
+	minVersion, err := o.minVersion()
+	if err != nil {
+		return err
+	}
+	cipherSuites, err := o.cipherSuites()
+	if err != nil {
+		return err
+	}
+	if minVersion != 0 {
+		cfg.MinVersion = minVersion
+	}
+	if len(cipherSuites) != 0 {
+		cfg.CipherSuites = cipherSuites
+		cfg.PreferServerCipherSuites = true
+	}
+	return nil
+}
+
+// tlsOptions returns the options of the credential, or the default options if
+// the credential has no options.
+func (s *Credential) tlsOptions() TLSOptions {
+	if !s.TLSOptions.isEmpty() {
+		return s.TLSOptions
+	}
+	return getDefaultTLSOptions()
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usage
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/errors"
+)
+
+// The CPU time of the changefeeds is paced in windows like the CPU shares of
+// cgroups. Once the changefeeds on the capture use more CPU time than the
+// capture has in a window, a changefeed using more than its share of the
+// window is paused until the next window, and its excess is carried to the
+// next window as a debt. A changefeed is never paused if it's the only one
+// using the CPU, the throttling is cooperative and takes effect only when the
+// CPU bound workers call WaitCPU.
+
+// DefaultCPUShares is the CPU shares of a changefeed if it's not configured.
+const DefaultCPUShares = 1024
+
+const cpuWindow = 100 * time.Millisecond
+
+var (
+	// cpuCapacity is the CPU time of the capture in a window
+	cpuCapacity = time.Duration(runtime.GOMAXPROCS(0)) * cpuWindow
+	nowFunc     = time.Now
+
+	// windowStart and windowTotal are protected by mu
+	windowStart time.Time
+	windowTotal time.Duration
+)
+
+// SetCPUShares sets the CPU shares of the changefeed, 0 means
+// DefaultCPUShares.
+func (c *Changefeed) SetCPUShares(shares uint64) {
+	if c == nil {
+		return
+	}
+	atomic.StoreUint64(&c.cpuShares, shares)
+}
+
+// WaitCPU blocks until the CPU bound workers of the changefeed can resume,
+// it should be called before a piece of CPU bound work whose time is
+// accounted by AddCPUTime.
+func (c *Changefeed) WaitCPU(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	until := atomic.LoadInt64(&c.throttledUntil)
+	if until == 0 {
+		return nil
+	}
+	if d := time.Until(time.Unix(0, until)); d > 0 {
+		// the pause never exceeds a window even if the clock jumps
+		if d > cpuWindow {
+			d = cpuWindow
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-timer.C:
+		}
+		atomic.AddInt64(&c.throttledNanos, int64(d))
+	}
+	atomic.CompareAndSwapInt64(&c.throttledUntil, until, 0)
+	return nil
+}
+
+// throttled returns whether the workers of the changefeed should be paused.
+func (c *Changefeed) throttled() bool {
+	return atomic.LoadInt64(&c.throttledUntil) > nowFunc().UnixNano()
+}
+
+func (c *Changefeed) shares() uint64 {
+	if shares := atomic.LoadUint64(&c.cpuShares); shares > 0 {
+		return shares
+	}
+	return DefaultCPUShares
+}
+
+// throttle accounts the CPU time used by the changefeed in the current
+// window, and pauses the changefeed if the CPU is contended and it uses more
+// than its share.
+func throttle(c *Changefeed, d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	rollWindow(nowFunc())
+	c.windowUsed += d
+	windowTotal += d
+	if windowTotal < cpuCapacity || windowTotal == c.windowUsed {
+		// the CPU is not contended, or the changefeed is the only one using it
+		return
+	}
+	if c.windowUsed > allowance(c, activeShares()) {
+		atomic.StoreInt64(&c.throttledUntil, windowStart.Add(cpuWindow).UnixNano())
+	}
+}
+
+// rollWindow starts a new window if the current one is over, the changefeeds
+// start the new window with their debts, which are at most one window of
+// their shares. The debts are cleared if a whole window is skipped.
+func rollWindow(now time.Time) {
+	elapsed := now.Sub(windowStart)
+	if elapsed >= 0 && elapsed < cpuWindow {
+		return
+	}
+	totalShares := activeShares()
+	windowTotal = 0
+	for _, c := range changefeeds {
+		if c.windowUsed == 0 {
+			continue
+		}
+		if elapsed < 0 || elapsed >= 2*cpuWindow {
+			c.windowUsed = 0
+			continue
+		}
+		share := allowance(c, totalShares)
+		debt := c.windowUsed - share
+		if debt < 0 {
+			debt = 0
+		} else if debt > share {
+			debt = share
+		}
+		c.windowUsed = debt
+		windowTotal += debt
+	}
+	windowStart = now
+}
+
+// activeShares returns the sum of the shares of the changefeeds using the CPU
+// in the current window.
+func activeShares() uint64 {
+	var total uint64
+	for _, c := range changefeeds {
+		if c.windowUsed > 0 {
+			total += c.shares()
+		}
+	}
+	return total
+}
+
+// allowance returns the CPU time the changefeed can use in a window when the
+// CPU is contended.
+func allowance(c *Changefeed, totalShares uint64) time.Duration {
+	if totalShares == 0 {
+		return cpuCapacity
+	}
+	return time.Duration(float64(cpuCapacity) * float64(c.shares()) / float64(totalShares))
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usage
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+func (s *usageSuite) TestThrottle(c *check.C) {
+	defer testleak.AfterTest(c)()
+	now := time.Unix(1000, 0)
+	capacity := cpuCapacity
+	nowFunc = func() time.Time { return now }
+	cpuCapacity = 100 * time.Millisecond
+	defer func() {
+		nowFunc = time.Now
+		cpuCapacity = capacity
+		Remove("cf-a")
+		Remove("cf-b")
+	}()
+	mu.Lock()
+	windowStart, windowTotal = time.Time{}, 0
+	mu.Unlock()
+
+	a := Of("cf-a")
+	b := Of("cf-b")
+	b.SetCPUShares(3 * DefaultCPUShares)
+
+	// the changefeed using the CPU alone is never throttled
+	a.AddCPUTime(150 * time.Millisecond)
+	c.Assert(a.throttled(), check.IsFalse)
+
+	// the CPU is contended, a uses more than its quarter of the window
+	now = now.Add(cpuWindow * 2)
+	a.AddCPUTime(60 * time.Millisecond)
+	b.AddCPUTime(30 * time.Millisecond)
+	c.Assert(a.throttled(), check.IsFalse)
+	a.AddCPUTime(20 * time.Millisecond)
+	c.Assert(a.throttled(), check.IsTrue)
+	b.AddCPUTime(10 * time.Millisecond)
+	c.Assert(b.throttled(), check.IsFalse)
+
+	// a starts the next window with a debt of its share
+	now = now.Add(cpuWindow)
+	c.Assert(a.throttled(), check.IsFalse)
+	b.AddCPUTime(80 * time.Millisecond)
+	c.Assert(a.windowUsed, check.Equals, 25*time.Millisecond)
+	c.Assert(b.windowUsed, check.Equals, 80*time.Millisecond)
+	c.Assert(b.throttled(), check.IsTrue)
+}
+
+func (s *usageSuite) TestWaitCPU(c *check.C) {
+	defer testleak.AfterTest(c)()
+	defer Remove("cf")
+	u := Of("cf")
+	c.Assert(u.WaitCPU(context.Background()), check.IsNil)
+
+	atomic.StoreInt64(&u.throttledUntil, time.Now().Add(50*time.Millisecond).UnixNano())
+	c.Assert(u.WaitCPU(context.Background()), check.IsNil)
+	c.Assert(atomic.LoadInt64(&u.throttledUntil), check.Equals, int64(0))
+	c.Assert(u.Snapshot().ThrottledSeconds > 0, check.IsTrue)
+
+	atomic.StoreInt64(&u.throttledUntil, time.Now().Add(time.Hour).UnixNano())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Assert(u.WaitCPU(ctx), check.ErrorMatches, ".*context canceled.*")
+
+	var nilUsage *Changefeed
+	c.Assert(nilUsage.WaitCPU(context.Background()), check.IsNil)
+}
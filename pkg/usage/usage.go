@@ -0,0 +1,123 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package usage accounts the resources used by each changefeed on a capture.
+// The components shared by the changefeeds, such as the unified sorter, report
+// the resources they use on behalf of the changefeeds. The CPU time of the
+// changefeeds is also paced by their CPU shares, see throttle.go.
+package usage
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/ticdc/cdc/model"
+)
+
+// Changefeed is the resources used by a changefeed on the capture, the
+// methods are no-ops on a nil Changefeed. It's safe for concurrent use.
+type Changefeed struct {
+	cpuNanos        int64
+	sorterMemory    int64
+	sorterDiskBytes int64
+	sinkBytes       int64
+
+	// cpuShares is the CPU shares of the changefeed, 0 means DefaultCPUShares
+	cpuShares uint64
+	// throttledUntil is when the CPU bound workers of the changefeed can
+	// resume in unix nanoseconds, 0 means they are not throttled
+	throttledUntil int64
+	throttledNanos int64
+	// windowUsed is the CPU time used in the current window, protected by mu
+	windowUsed time.Duration
+}
+
+var (
+	mu          sync.Mutex
+	changefeeds = make(map[model.ChangeFeedID]*Changefeed)
+)
+
+// Of returns the usage of the changefeed, which is created if it doesn't
+// exist. It returns nil if the changefeed id is empty.
+func Of(changefeedID model.ChangeFeedID) *Changefeed {
+	if changefeedID == "" {
+		return nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	c, ok := changefeeds[changefeedID]
+	if !ok {
+		c = &Changefeed{}
+		changefeeds[changefeedID] = c
+	}
+	return c
+}
+
+// Remove removes the usage of the changefeed once its processor exits, the
+// usage is accumulated from zero if the processor is started again.
+func Remove(changefeedID model.ChangeFeedID) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(changefeeds, changefeedID)
+}
+
+// AddCPUTime accounts the time spent by the CPU bound workers of the
+// changefeed, such as mounting the rows.
+func (c *Changefeed) AddCPUTime(d time.Duration) {
+	if c == nil {
+		return
+	}
+	atomic.AddInt64(&c.cpuNanos, int64(d))
+	throttle(c, d)
+}
+
+// AddSorterMemory accounts the bytes of the sorted data kept in memory, it's
+// negative if the data is released.
+func (c *Changefeed) AddSorterMemory(size int64) {
+	if c == nil {
+		return
+	}
+	atomic.AddInt64(&c.sorterMemory, size)
+}
+
+// AddSorterDisk accounts the bytes of the sorted data spilled to disk, it's
+// negative if the data is removed.
+func (c *Changefeed) AddSorterDisk(size int64) {
+	if c == nil {
+		return
+	}
+	atomic.AddInt64(&c.sorterDiskBytes, size)
+}
+
+// AddSinkBytes accounts the bytes emitted to the sink.
+func (c *Changefeed) AddSinkBytes(size int64) {
+	if c == nil {
+		return
+	}
+	atomic.AddInt64(&c.sinkBytes, size)
+}
+
+// Snapshot returns the current usage.
+func (c *Changefeed) Snapshot() model.ResourceUsage {
+	if c == nil {
+		return model.ResourceUsage{}
+	}
+	return model.ResourceUsage{
+		CPUSeconds:       time.Duration(atomic.LoadInt64(&c.cpuNanos)).Seconds(),
+		MemoryBytes:      atomic.LoadInt64(&c.sorterMemory),
+		SorterDiskBytes:  atomic.LoadInt64(&c.sorterDiskBytes),
+		SinkBytes:        atomic.LoadInt64(&c.sinkBytes),
+		ThrottledSeconds: time.Duration(atomic.LoadInt64(&c.throttledNanos)).Seconds(),
+	}
+}
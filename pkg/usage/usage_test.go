@@ -0,0 +1,62 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type usageSuite struct{}
+
+var _ = check.Suite(&usageSuite{})
+
+func (s *usageSuite) TestChangefeedUsage(c *check.C) {
+	defer testleak.AfterTest(c)()
+	defer Remove("cf")
+	c.Assert(Of(""), check.IsNil)
+
+	u := Of("cf")
+	c.Assert(Of("cf"), check.Equals, u)
+	u.AddCPUTime(1500 * time.Millisecond)
+	u.AddSorterMemory(100)
+	u.AddSorterMemory(-40)
+	u.AddSorterDisk(200)
+	u.AddSinkBytes(300)
+	c.Assert(u.Snapshot(), check.DeepEquals, model.ResourceUsage{
+		CPUSeconds:      1.5,
+		MemoryBytes:     60,
+		SorterDiskBytes: 200,
+		SinkBytes:       300,
+	})
+
+	// the usage is accumulated from zero once it's removed
+	Remove("cf")
+	c.Assert(Of("cf"), check.Not(check.Equals), u)
+	c.Assert(Of("cf").Snapshot(), check.DeepEquals, model.ResourceUsage{})
+
+	// a nil usage is a no-op
+	var nilUsage *Changefeed
+	nilUsage.AddCPUTime(time.Second)
+	nilUsage.AddSinkBytes(100)
+	c.Assert(nilUsage.Snapshot(), check.DeepEquals, model.ResourceUsage{})
+}
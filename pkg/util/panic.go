@@ -0,0 +1,65 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/pingcap/log"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var panicCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ticdc",
+		Subsystem: "processor",
+		Name:      "panic_count",
+		Help:      "counter for the panics recovered in the routines of changefeeds",
+	}, []string{"changefeed", "capture", "component"})
+
+// InitMetrics registers all metrics in this file
+func InitMetrics(registry *prometheus.Registry) {
+	registry.MustRegister(panicCounter)
+}
+
+// PanicError converts a panic recovered in a routine of a changefeed to an
+// error, so the panic only fails the changefeed instead of crashing all the
+// changefeeds on the capture. It must be called in the deferred function
+// recovering the panic to log the stack of the panic.
+func PanicError(ctx context.Context, component string, r interface{}) error {
+	log.Error("recover the panic of the changefeed",
+		ZapFieldChangefeed(ctx),
+		ZapFieldCapture(ctx),
+		zap.String("component", component),
+		zap.Reflect("panic", r),
+		zap.ByteString("stack", debug.Stack()))
+	panicCounter.WithLabelValues(ChangefeedIDFromCtx(ctx), CaptureAddrFromCtx(ctx), component).Inc()
+	return cerror.ErrChangefeedPanic.GenWithStackByArgs(component, r)
+}
+
+// GuardPanic wraps a routine of a changefeed, a panic in the routine is
+// returned as an error by PanicError.
+func GuardPanic(ctx context.Context, component string, fn func() error) func() error {
+	return func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = PanicError(ctx, component, r)
+			}
+		}()
+		return fn()
+	}
+}
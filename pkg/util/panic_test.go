@@ -0,0 +1,49 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+
+	"github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type panicSuite struct{}
+
+var _ = check.Suite(&panicSuite{})
+
+func (s *panicSuite) TestGuardPanic(c *check.C) {
+	defer testleak.AfterTest(c)()
+	ctx := PutChangefeedIDInCtx(PutCaptureAddrInCtx(context.Background(), "127.0.0.1:8300"), "test-cf")
+	counter := panicCounter.WithLabelValues("test-cf", "127.0.0.1:8300", "codec")
+
+	err := GuardPanic(ctx, "codec", func() error {
+		var m map[string]*int
+		return errors.Errorf("unreachable %d", *m["nil"])
+	})()
+	c.Assert(cerror.ErrChangefeedPanic.Equal(err), check.IsTrue)
+	c.Assert(err, check.ErrorMatches, ".*codec of the changefeed panics.*nil pointer.*")
+	c.Assert(testutil.ToFloat64(counter), check.Equals, float64(1))
+
+	// the errors of the routine are returned as they are
+	err = GuardPanic(ctx, "codec", func() error {
+		return errors.New("codec failed")
+	})()
+	c.Assert(err, check.ErrorMatches, "codec failed")
+	c.Assert(testutil.ToFloat64(counter), check.Equals, float64(1))
+}
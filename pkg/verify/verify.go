@@ -0,0 +1,437 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	dmysql "github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/pkg/cyclic/mark"
+	"github.com/pingcap/ticdc/pkg/dbbalancer"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"github.com/pingcap/ticdc/pkg/filter"
+	"github.com/pingcap/ticdc/pkg/quotes"
+	"github.com/pingcap/ticdc/pkg/security"
+	"go.uber.org/zap"
+)
+
+// DefaultChunkSize is the default number of rows of a chunk.
+const DefaultChunkSize = 10000
+
+// Syncpoint is a pair of the timestamps of the upstream and downstream, at
+// which the data of the two clusters are the same.
+type Syncpoint struct {
+	PrimaryTs   uint64
+	SecondaryTs uint64
+}
+
+// LatestSyncpoint returns the latest syncpoint of the changefeed recorded in
+// the downstream.
+func LatestSyncpoint(ctx context.Context, downstream *sql.DB, changefeedID string) (*Syncpoint, error) {
+	var primaryTs, secondaryTs string
+	err := downstream.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT primary_ts, secondary_ts FROM %s WHERE cf = ? ORDER BY CAST(primary_ts AS UNSIGNED) DESC LIMIT 1",
+		quotes.QuoteSchema(mark.SchemaName, syncpointTableName)), changefeedID).Scan(&primaryTs, &secondaryTs)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, cerror.ErrVerifyFailed.GenWithStack("no syncpoint of changefeed %s is found", changefeedID)
+		}
+		return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	sp := &Syncpoint{}
+	if sp.PrimaryTs, err = strconv.ParseUint(primaryTs, 10, 64); err != nil {
+		return nil, cerror.WrapError(cerror.ErrVerifyFailed, err)
+	}
+	if sp.SecondaryTs, err = strconv.ParseUint(secondaryTs, 10, 64); err != nil {
+		return nil, cerror.WrapError(cerror.ErrVerifyFailed, err)
+	}
+	return sp, nil
+}
+
+// commonSyncpointsLimit is the number of the latest syncpoints searched for the
+// common syncpoints of two changefeeds.
+const commonSyncpointsLimit = 100
+
+// LatestCommonSyncpoint returns the syncpoints of two changefeeds replicating
+// the same upstream, such as a shadow changefeed and its primary, at the latest
+// upstream timestamp both of them have reached.
+func LatestCommonSyncpoint(
+	ctx context.Context, primaryDB *sql.DB, primaryID string, shadowDB *sql.DB, shadowID string,
+) (primary *Syncpoint, shadow *Syncpoint, err error) {
+	primaries, err := latestSyncpoints(ctx, primaryDB, primaryID, commonSyncpointsLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	shadows, err := latestSyncpoints(ctx, shadowDB, shadowID, commonSyncpointsLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	shadowByTs := make(map[uint64]*Syncpoint, len(shadows))
+	for _, sp := range shadows {
+		shadowByTs[sp.PrimaryTs] = sp
+	}
+	for _, sp := range primaries {
+		if shadow, ok := shadowByTs[sp.PrimaryTs]; ok {
+			return sp, shadow, nil
+		}
+	}
+	return nil, nil, cerror.ErrVerifyFailed.GenWithStack(
+		"no common syncpoint of changefeed %s and %s is found", primaryID, shadowID)
+}
+
+// latestSyncpoints returns the latest syncpoints of the changefeed recorded in
+// the downstream, in the descending order of the upstream timestamps.
+func latestSyncpoints(ctx context.Context, downstream *sql.DB, changefeedID string, limit int) ([]*Syncpoint, error) {
+	rows, err := downstream.QueryContext(ctx, fmt.Sprintf(
+		"SELECT primary_ts, secondary_ts FROM %s WHERE cf = ? ORDER BY CAST(primary_ts AS UNSIGNED) DESC LIMIT %d",
+		quotes.QuoteSchema(mark.SchemaName, syncpointTableName), limit), changefeedID)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	defer rows.Close()
+	var syncpoints []*Syncpoint
+	for rows.Next() {
+		var primaryTs, secondaryTs string
+		if err := rows.Scan(&primaryTs, &secondaryTs); err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+		}
+		sp := &Syncpoint{}
+		if sp.PrimaryTs, err = strconv.ParseUint(primaryTs, 10, 64); err != nil {
+			return nil, cerror.WrapError(cerror.ErrVerifyFailed, err)
+		}
+		if sp.SecondaryTs, err = strconv.ParseUint(secondaryTs, 10, 64); err != nil {
+			return nil, cerror.WrapError(cerror.ErrVerifyFailed, err)
+		}
+		syncpoints = append(syncpoints, sp)
+	}
+	return syncpoints, cerror.WrapError(cerror.ErrMySQLQueryError, rows.Err())
+}
+
+// syncpointTableName is the table of the syncpoints written by the MySQL sink.
+const syncpointTableName = "syncpoint_v1"
+
+// Checksum is the checksum of a chunk.
+type Checksum struct {
+	Count int64  `json:"count"`
+	Value uint64 `json:"value"`
+}
+
+// Mismatch is a chunk whose checksums differ between the upstream and
+// downstream. The chunk is the rows whose key is in (Lower, Upper], the
+// missing bounds mean no limit.
+type Mismatch struct {
+	Schema     string   `json:"schema"`
+	Table      string   `json:"table"`
+	Key        string   `json:"key,omitempty"`
+	Lower      *string  `json:"lower,omitempty"`
+	Upper      *string  `json:"upper,omitempty"`
+	Upstream   Checksum `json:"upstream"`
+	Downstream Checksum `json:"downstream"`
+}
+
+// Checker compares the checksums of the chunks of the replicated tables in the
+// upstream and downstream at a syncpoint.
+type Checker struct {
+	upstream   *sql.Conn
+	downstream *sql.Conn
+	filter     *filter.Filter
+	chunkSize  int
+
+	// downstreamSuffix is appended to the schema names in the downstream
+	downstreamSuffix string
+}
+
+// NewChecker creates a Checker, the connections are set to read the snapshots
+// of the syncpoint, they should not be used by others.
+func NewChecker(
+	ctx context.Context, upstream, downstream *sql.Conn, f *filter.Filter, chunkSize int, sp *Syncpoint,
+) (*Checker, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if _, err := upstream.ExecContext(ctx, "SET @@tidb_snapshot = ?", strconv.FormatUint(sp.PrimaryTs, 10)); err != nil {
+		return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	if _, err := downstream.ExecContext(ctx, "SET @@tidb_snapshot = ?", strconv.FormatUint(sp.SecondaryTs, 10)); err != nil {
+		return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	return &Checker{
+		upstream:   upstream,
+		downstream: downstream,
+		filter:     f,
+		chunkSize:  chunkSize,
+	}, nil
+}
+
+// SetDownstreamSchemaSuffix sets the suffix appended to the schema names in the
+// downstream, such as the shadow schemas written by a shadow changefeed. The
+// schemas with the suffix in the upstream are not compared.
+func (c *Checker) SetDownstreamSchemaSuffix(suffix string) {
+	c.downstreamSuffix = suffix
+}
+
+// Run compares all the replicated tables, it returns the mismatched chunks.
+func (c *Checker) Run(ctx context.Context) ([]*Mismatch, error) {
+	tables, err := c.tables(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var mismatches []*Mismatch
+	for _, table := range tables {
+		m, err := c.checkTable(ctx, table[0], table[1])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		mismatches = append(mismatches, m...)
+	}
+	return mismatches, nil
+}
+
+func (c *Checker) tables(ctx context.Context) ([][2]string, error) {
+	rows, err := c.upstream.QueryContext(ctx,
+		"SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.TABLES WHERE TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_SCHEMA, TABLE_NAME")
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	defer rows.Close()
+	var tables [][2]string
+	for rows.Next() {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+		}
+		// the mark tables differ in the clusters
+		if mark.IsMarkTable(schema, table) || c.filter.ShouldIgnoreTable(schema, table) {
+			continue
+		}
+		// the upstream is the downstream of another changefeed if the schemas
+		// are suffixed, which has the syncpoints and the suffixed schemas
+		if c.downstreamSuffix != "" &&
+			(schema == mark.SchemaName || strings.HasSuffix(schema, c.downstreamSuffix)) {
+			continue
+		}
+		tables = append(tables, [2]string{schema, table})
+	}
+	return tables, cerror.WrapError(cerror.ErrMySQLQueryError, rows.Err())
+}
+
+func (c *Checker) checkTable(ctx context.Context, schema, table string) ([]*Mismatch, error) {
+	columns, err := queryStrings(ctx, c.upstream,
+		"SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION",
+		schema, table)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := queryStrings(ctx, c.upstream,
+		"SELECT COLUMN_NAME FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND INDEX_NAME = 'PRIMARY' ORDER BY SEQ_IN_INDEX",
+		schema, table)
+	if err != nil {
+		return nil, err
+	}
+	quoteTable := quotes.QuoteSchema(schema, table)
+	checksumQuery := buildChecksumQuery(quoteTable, columns)
+	downChecksumQuery := checksumQuery
+	if c.downstreamSuffix != "" {
+		downChecksumQuery = buildChecksumQuery(quotes.QuoteSchema(schema+c.downstreamSuffix, table), columns)
+	}
+	// the tables without a single column primary key are compared as a whole
+	key := ""
+	if len(keys) == 1 {
+		key = keys[0]
+	}
+
+	var mismatches []*Mismatch
+	lower := ""
+	for first := true; ; first = false {
+		upper, last := "", true
+		if key != "" {
+			upper, last, err = c.nextBound(ctx, quoteTable, key, lower, first)
+			if err != nil {
+				return nil, err
+			}
+		}
+		where, args := buildRange(key, lower, upper, first, last)
+		up, err := queryChecksum(ctx, c.upstream, checksumQuery+where, args...)
+		if err != nil {
+			return nil, err
+		}
+		down, err := queryChecksum(ctx, c.downstream, downChecksumQuery+where, args...)
+		if err != nil {
+			return nil, err
+		}
+		if up != down {
+			log.Warn("chunk mismatched", zap.String("table", quoteTable),
+				zap.String("lower", lower), zap.String("upper", upper),
+				zap.Reflect("upstream", up), zap.Reflect("downstream", down))
+			m := &Mismatch{Schema: schema, Table: table, Key: key, Upstream: up, Downstream: down}
+			if !first {
+				m.Lower = new(string)
+				*m.Lower = lower
+			}
+			if !last {
+				m.Upper = new(string)
+				*m.Upper = upper
+			}
+			mismatches = append(mismatches, m)
+		}
+		if last {
+			break
+		}
+		lower = upper
+	}
+	log.Info("table verified", zap.String("table", quoteTable), zap.Int("mismatches", len(mismatches)))
+	return mismatches, nil
+}
+
+// nextBound returns the upper bound of the chunk starting from lower, last is
+// true if the chunk is the last one, which has no upper bound.
+func (c *Checker) nextBound(
+	ctx context.Context, quoteTable, key, lower string, first bool,
+) (upper string, last bool, err error) {
+	quoteKey := quotes.QuoteName(key)
+	query := fmt.Sprintf("SELECT %s FROM %s", quoteKey, quoteTable)
+	var args []interface{}
+	if !first {
+		query += fmt.Sprintf(" WHERE %s > ?", quoteKey)
+		args = append(args, lower)
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT 1 OFFSET %d", quoteKey, c.chunkSize-1)
+	var bound sql.NullString
+	err = c.upstream.QueryRowContext(ctx, query, args...).Scan(&bound)
+	if err == sql.ErrNoRows {
+		return "", true, nil
+	}
+	if err != nil {
+		return "", false, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	return bound.String, false, nil
+}
+
+// buildChecksumQuery builds the query of the count and the checksum of the
+// rows, the NULL values are told from the empty ones by the ISNULL list.
+func buildChecksumQuery(quoteTable string, columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	isNull := make([]string, 0, len(columns))
+	for _, col := range columns {
+		quoted = append(quoted, quotes.QuoteName(col))
+		isNull = append(isNull, "ISNULL("+quotes.QuoteName(col)+")")
+	}
+	return fmt.Sprintf(
+		"SELECT COUNT(*), IFNULL(BIT_XOR(CAST(CRC32(CONCAT_WS(',', %s, CONCAT(%s))) AS UNSIGNED)), 0) FROM %s",
+		strings.Join(quoted, ", "), strings.Join(isNull, ", "), quoteTable)
+}
+
+func buildRange(key, lower, upper string, first, last bool) (string, []interface{}) {
+	if key == "" {
+		return "", nil
+	}
+	quoteKey := quotes.QuoteName(key)
+	var conds []string
+	var args []interface{}
+	if !first {
+		conds = append(conds, quoteKey+" > ?")
+		args = append(args, lower)
+	}
+	if !last {
+		conds = append(conds, quoteKey+" <= ?")
+		args = append(args, upper)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func queryChecksum(ctx context.Context, conn *sql.Conn, query string, args ...interface{}) (Checksum, error) {
+	var sum Checksum
+	err := conn.QueryRowContext(ctx, query, args...).Scan(&sum.Count, &sum.Value)
+	if err != nil {
+		return sum, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	return sum, nil
+}
+
+func queryStrings(ctx context.Context, conn *sql.Conn, query string, args ...interface{}) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+	}
+	defer rows.Close()
+	var result []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLQueryError, err)
+		}
+		result = append(result, s)
+	}
+	return result, cerror.WrapError(cerror.ErrMySQLQueryError, rows.Err())
+}
+
+// OpenDB opens a connection pool of a MySQL compatible database by a URI in
+// the format of the MySQL sink URI, such as mysql://root@127.0.0.1:4000/,
+// the ssl-ca, ssl-cert and ssl-key parameters are supported.
+func OpenDB(ctx context.Context, uri string) (*sql.DB, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrSinkURIInvalid, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "mysql", "tidb", "mysql+ssl", "tidb+ssl":
+	default:
+		return nil, cerror.ErrSinkURIInvalid.GenWithStack("the scheme (%s) is not supported", u.Scheme)
+	}
+	cfg := dmysql.NewConfig()
+	cfg.User = u.User.Username()
+	if cfg.User == "" {
+		cfg.User = "root"
+	}
+	cfg.Passwd, _ = u.User.Password()
+	cfg.Net, cfg.Addr, err = dbbalancer.Resolve(u, "verify-"+u.Host)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if u.Query().Get("ssl-ca") != "" {
+		credential := security.Credential{
+			CAPath:   u.Query().Get("ssl-ca"),
+			CertPath: u.Query().Get("ssl-cert"),
+			KeyPath:  u.Query().Get("ssl-key"),
+		}
+		tlsCfg, err := credential.ToTLSConfig()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		name := "cdc_verify_tls_" + u.Host
+		if err := dmysql.RegisterTLSConfig(name, tlsCfg); err != nil {
+			return nil, cerror.WrapError(cerror.ErrMySQLConnectionError, err)
+		}
+		cfg.TLSConfig = name
+	}
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrMySQLConnectionError, err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close() //nolint:errcheck
+		return nil, cerror.WrapError(cerror.ErrMySQLConnectionError, err)
+	}
+	return db, nil
+}
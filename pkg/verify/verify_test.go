@@ -0,0 +1,110 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pingcap/check"
+	"github.com/pingcap/ticdc/pkg/util/testleak"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type verifySuite struct{}
+
+var _ = check.Suite(&verifySuite{})
+
+func (s *verifySuite) TestLatestSyncpoint(c *check.C) {
+	defer testleak.AfterTest(c)()
+	db, mock, err := sqlmock.New()
+	c.Assert(err, check.IsNil)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT primary_ts, secondary_ts FROM `tidb_cdc`.`syncpoint_v1`").
+		WithArgs("test-cf").
+		WillReturnRows(sqlmock.NewRows([]string{"primary_ts", "secondary_ts"}).AddRow("100", "200"))
+	sp, err := LatestSyncpoint(context.Background(), db, "test-cf")
+	c.Assert(err, check.IsNil)
+	c.Assert(sp, check.DeepEquals, &Syncpoint{PrimaryTs: 100, SecondaryTs: 200})
+
+	mock.ExpectQuery("SELECT primary_ts, secondary_ts").
+		WithArgs("test-cf").
+		WillReturnRows(sqlmock.NewRows([]string{"primary_ts", "secondary_ts"}))
+	_, err = LatestSyncpoint(context.Background(), db, "test-cf")
+	c.Assert(err, check.ErrorMatches, ".*no syncpoint of changefeed test-cf is found.*")
+	c.Assert(mock.ExpectationsWereMet(), check.IsNil)
+}
+
+func (s *verifySuite) TestLatestCommonSyncpoint(c *check.C) {
+	defer testleak.AfterTest(c)()
+	primaryDB, primaryMock, err := sqlmock.New()
+	c.Assert(err, check.IsNil)
+	defer primaryDB.Close()
+	shadowDB, shadowMock, err := sqlmock.New()
+	c.Assert(err, check.IsNil)
+	defer shadowDB.Close()
+
+	columns := []string{"primary_ts", "secondary_ts"}
+	primaryMock.ExpectQuery("SELECT primary_ts, secondary_ts FROM `tidb_cdc`.`syncpoint_v1`").
+		WithArgs("primary").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("300", "310").AddRow("200", "210").AddRow("100", "110"))
+	// the shadow lags behind the primary
+	shadowMock.ExpectQuery("SELECT primary_ts, secondary_ts FROM `tidb_cdc`.`syncpoint_v1`").
+		WithArgs("shadow").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("200", "220").AddRow("100", "120"))
+	primary, shadow, err := LatestCommonSyncpoint(context.Background(), primaryDB, "primary", shadowDB, "shadow")
+	c.Assert(err, check.IsNil)
+	c.Assert(primary, check.DeepEquals, &Syncpoint{PrimaryTs: 200, SecondaryTs: 210})
+	c.Assert(shadow, check.DeepEquals, &Syncpoint{PrimaryTs: 200, SecondaryTs: 220})
+
+	primaryMock.ExpectQuery("SELECT primary_ts, secondary_ts").
+		WithArgs("primary").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("300", "310"))
+	shadowMock.ExpectQuery("SELECT primary_ts, secondary_ts").
+		WithArgs("shadow").
+		WillReturnRows(sqlmock.NewRows(columns))
+	_, _, err = LatestCommonSyncpoint(context.Background(), primaryDB, "primary", shadowDB, "shadow")
+	c.Assert(err, check.ErrorMatches, ".*no common syncpoint of changefeed primary and shadow is found.*")
+	c.Assert(primaryMock.ExpectationsWereMet(), check.IsNil)
+	c.Assert(shadowMock.ExpectationsWereMet(), check.IsNil)
+}
+
+func (s *verifySuite) TestBuildQuery(c *check.C) {
+	defer testleak.AfterTest(c)()
+	c.Assert(buildChecksumQuery("`test`.`t`", []string{"a", "b"}), check.Equals,
+		"SELECT COUNT(*), IFNULL(BIT_XOR(CAST(CRC32(CONCAT_WS(',', `a`, `b`, CONCAT(ISNULL(`a`), ISNULL(`b`)))) AS UNSIGNED)), 0) FROM `test`.`t`")
+
+	testCases := []struct {
+		key, lower, upper string
+		first, last       bool
+		cond              string
+		args              []interface{}
+	}{
+		{"id", "", "10", true, false, " WHERE `id` <= ?", []interface{}{"10"}},
+		{"id", "10", "20", false, false, " WHERE `id` > ? AND `id` <= ?", []interface{}{"10", "20"}},
+		{"id", "20", "", false, true, " WHERE `id` > ?", []interface{}{"20"}},
+		{"id", "", "", true, true, "", nil},
+		{"", "", "", false, false, "", nil},
+	}
+	for _, tc := range testCases {
+		cond, args := buildRange(tc.key, tc.lower, tc.upper, tc.first, tc.last)
+		c.Assert(cond, check.Equals, tc.cond)
+		c.Assert(args, check.DeepEquals, tc.args)
+	}
+}
@@ -24,12 +24,10 @@ import (
 
 	"github.com/coreos/go-semver/semver"
 	"github.com/pingcap/kvproto/pkg/metapb"
-	"github.com/pingcap/log"
 	cerror "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/ticdc/pkg/httputil"
 	"github.com/pingcap/ticdc/pkg/security"
 	pd "github.com/tikv/pd/client"
-	"go.uber.org/zap"
 )
 
 // minPDVersion is the version of the minimal compatible PD.
@@ -49,16 +47,15 @@ func removeVAndHash(v string) string {
 	return strings.TrimPrefix(v, "v")
 }
 
-// CheckClusterVersion check TiKV and PD version.
+// CheckClusterVersion checks the versions of TiKV and PD against the
+// compatibility matrix. The refused versions are returned as an error unless
+// ignoreIncompat is true, the others are logged as warnings.
 func CheckClusterVersion(
-	ctx context.Context, client pd.Client, pdHTTP string, credential *security.Credential, errorTiKVIncompat bool,
+	ctx context.Context, client pd.Client, pdHTTP string, credential *security.Credential, ignoreIncompat bool,
 ) error {
-	err := CheckStoreVersion(ctx, client, 0 /* check all TiKV */)
+	incompats, err := checkStoresCompatibility(ctx, client, 0 /* check all TiKV */)
 	if err != nil {
-		if errorTiKVIncompat {
-			return err
-		}
-		log.Warn("check TiKV version failed", zap.Error(err))
+		return err
 	}
 	httpCli, err := httputil.NewClient(credential)
 	if err != nil {
@@ -93,22 +90,33 @@ func CheckClusterVersion(
 	if err != nil {
 		return cerror.WrapError(cerror.ErrCheckClusterVersionFromPD, err)
 	}
-	ver, err := semver.NewVersion(removeVAndHash(pdVer.Version))
+	found, err := checkCompatibility(ComponentPD, "", pdVer.Version)
 	if err != nil {
-		return cerror.WrapError(cerror.ErrNewSemVersion, err)
-	}
-	ord := ver.Compare(*minPDVersion)
-	if ord < 0 {
-		arg := fmt.Sprintf("PD %s is not supported, require minimal version %s",
-			removeVAndHash(pdVer.Version), minPDVersion)
-		return cerror.ErrVersionIncompatible.GenWithStackByArgs(arg)
+		return err
 	}
-	return nil
+	incompats = append(incompats, found...)
+	return handleIncompatibilities(incompats, ignoreIncompat)
 }
 
 // CheckStoreVersion checks whether the given TiKV is compatible with this CDC.
-// If storeID is 0, it checks all TiKV.
+// If storeID is 0, it checks all TiKV. Only the refused versions are
+// returned as an error.
 func CheckStoreVersion(ctx context.Context, client pd.Client, storeID uint64) error {
+	incompats, err := checkStoresCompatibility(ctx, client, storeID)
+	if err != nil {
+		return err
+	}
+	for _, incompat := range incompats {
+		if incompat.Refused {
+			return cerror.ErrVersionIncompatible.GenWithStackByArgs(incompat.String())
+		}
+	}
+	return nil
+}
+
+// checkStoresCompatibility checks the versions of the given TiKV against the
+// compatibility matrix, it checks all TiKV if storeID is 0.
+func checkStoresCompatibility(ctx context.Context, client pd.Client, storeID uint64) ([]*Incompatibility, error) {
 	var stores []*metapb.Store
 	var err error
 	if storeID == 0 {
@@ -118,20 +126,16 @@ func CheckStoreVersion(ctx context.Context, client pd.Client, storeID uint64) er
 		stores[0], err = client.GetStore(ctx, storeID)
 	}
 	if err != nil {
-		return cerror.WrapError(cerror.ErrGetAllStoresFailed, err)
+		return nil, cerror.WrapError(cerror.ErrGetAllStoresFailed, err)
 	}
 
+	var incompats []*Incompatibility
 	for _, s := range stores {
-		ver, err := semver.NewVersion(removeVAndHash(s.Version))
+		found, err := checkCompatibility(ComponentTiKV, s.Address, s.Version)
 		if err != nil {
-			return cerror.WrapError(cerror.ErrNewSemVersion, err)
-		}
-		ord := ver.Compare(*MinTiKVVersion)
-		if ord < 0 {
-			arg := fmt.Sprintf("TiKV %s is not supported, require minimal version %s",
-				removeVAndHash(s.Version), MinTiKVVersion)
-			return cerror.ErrVersionIncompatible.GenWithStackByArgs(arg)
+			return nil, err
 		}
+		incompats = append(incompats, found...)
 	}
-	return nil
+	return incompats, nil
 }
@@ -88,7 +88,7 @@ func (s *checkSuite) TestCheckClusterVersion(c *check.C) {
 		mock.getAllStores = func() []*metapb.Store {
 			return []*metapb.Store{{Version: MinTiKVVersion.String()}}
 		}
-		err := CheckClusterVersion(context.Background(), &mock, pdHTTP, nil, true)
+		err := CheckClusterVersion(context.Background(), &mock, pdHTTP, nil, false)
 		c.Assert(err, check.IsNil)
 	}
 
@@ -99,9 +99,11 @@ func (s *checkSuite) TestCheckClusterVersion(c *check.C) {
 		mock.getAllStores = func() []*metapb.Store {
 			return []*metapb.Store{{Version: MinTiKVVersion.String()}}
 		}
-		err := CheckClusterVersion(context.Background(), &mock, pdHTTP, nil, true)
+		err := CheckClusterVersion(context.Background(), &mock, pdHTTP, nil, false)
 		c.Assert(err, check.ErrorMatches,
 			".*PD .* is not supported.*")
+		err = CheckClusterVersion(context.Background(), &mock, pdHTTP, nil, true)
+		c.Assert(err, check.IsNil)
 	}
 
 	{
@@ -112,14 +114,55 @@ func (s *checkSuite) TestCheckClusterVersion(c *check.C) {
 			// TiKV does not include 'v'.
 			return []*metapb.Store{{Version: `1.0.0-alpha-271-g824ae7fd`}}
 		}
-		err := CheckClusterVersion(context.Background(), &mock, pdHTTP, nil, true)
+		err := CheckClusterVersion(context.Background(), &mock, pdHTTP, nil, false)
 		c.Assert(err, check.ErrorMatches,
 			".*TiKV .* is not supported.*")
-		err = CheckClusterVersion(context.Background(), &mock, pdHTTP, nil, false)
+		err = CheckClusterVersion(context.Background(), &mock, pdHTTP, nil, true)
 		c.Assert(err, check.IsNil)
 	}
 }
 
+func (s *checkSuite) TestCheckCompatibility(c *check.C) {
+	defer testleak.AfterTest(c)()
+	defer func(v string) { ReleaseVersion = v }(ReleaseVersion)
+	ReleaseVersion = "v4.0.9"
+
+	cases := []struct {
+		component string
+		version   string
+		reasons   int
+		refused   bool
+	}{
+		{ComponentTiKV, "v3.0.12", 1, true},
+		{ComponentTiKV, "4.0.0-rc.1", 1, false},
+		{ComponentTiKV, "4.0.5-12-g9b8ddd4-dirty", 1, false},
+		{ComponentTiKV, "4.0.8", 0, false},
+		{ComponentTiKV, "5.0.0", 1, false},
+		{ComponentPD, "v4.0.0-beta.2", 1, true},
+		{ComponentPD, "v4.0.8", 0, false},
+		{ComponentTiDB, tidbVersion("5.7.25-TiDB-v3.0.20"), 1, false},
+		{ComponentTiDB, tidbVersion("5.7.25-TiDB-v4.0.8"), 0, false},
+	}
+	for _, cs := range cases {
+		incompats, err := checkCompatibility(cs.component, "127.0.0.1:20160", cs.version)
+		c.Assert(err, check.IsNil)
+		c.Assert(incompats, check.HasLen, cs.reasons, check.Commentf("%v", cs))
+		if cs.reasons > 0 {
+			c.Assert(incompats[0].Refused, check.Equals, cs.refused, check.Commentf("%v", cs))
+		}
+		c.Assert(handleIncompatibilities(incompats, true), check.IsNil)
+		err = handleIncompatibilities(incompats, false)
+		if cs.refused {
+			c.Assert(err, check.ErrorMatches, ".*is not supported.*")
+		} else {
+			c.Assert(err, check.IsNil)
+		}
+	}
+
+	_, err := checkCompatibility(ComponentTiKV, "", "invalid")
+	c.Assert(err, check.NotNil)
+}
+
 func (s *checkSuite) TestCompareVersion(c *check.C) {
 	defer testleak.AfterTest(c)()
 	c.Assert(semver.New("4.0.0-rc").Compare(*semver.New("4.0.0-rc.2")), check.Equals, -1)
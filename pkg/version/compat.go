@@ -0,0 +1,198 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/pingcap/log"
+	cerror "github.com/pingcap/ticdc/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+)
+
+// The upstream components checked by the compatibility matrix.
+const (
+	ComponentTiKV = "TiKV"
+	ComponentPD   = "PD"
+	ComponentTiDB = "TiDB"
+)
+
+// compatRule is an entry of the compatibility matrix, which matches the
+// versions of a component in [from, below), a nil bound is unbounded.
+type compatRule struct {
+	component string
+	from      *semver.Version
+	below     *semver.Version
+	// refuse is true if CDC can't work with the versions, the versions are
+	// only warned otherwise.
+	refuse bool
+	reason string
+}
+
+// compatMatrix lists the versions of the upstream components which are not
+// supported by this CDC.
+var compatMatrix = []compatRule{
+	{
+		component: ComponentTiKV,
+		below:     MinTiKVVersion,
+		refuse:    true,
+		reason:    fmt.Sprintf("the TiKV has no cdc component, require minimal version %s", MinTiKVVersion),
+	},
+	{
+		component: ComponentPD,
+		below:     minPDVersion,
+		refuse:    true,
+		reason:    fmt.Sprintf("require minimal version %s", minPDVersion),
+	},
+	{
+		component: ComponentTiKV,
+		from:      MinTiKVVersion,
+		below:     semver.New("4.0.6"),
+		reason:    "the cdc component of the TiKV is experimental before v4.0.6",
+	},
+	{
+		component: ComponentTiDB,
+		below:     semver.New("4.0.0-rc.1"),
+		reason:    "the TiDB is not tested with TiCDC before v4.0.0",
+	},
+}
+
+// Incompatibility is a version of an upstream component matched by the
+// compatibility matrix.
+type Incompatibility struct {
+	Component string
+	Address   string
+	Version   string
+	Refused   bool
+	Reason    string
+}
+
+// String implements fmt.Stringer.
+func (i *Incompatibility) String() string {
+	addr := ""
+	if i.Address != "" {
+		addr = " at " + i.Address
+	}
+	return fmt.Sprintf("%s %s%s is not supported: %s", i.Component, i.Version, addr, i.Reason)
+}
+
+// checkCompatibility checks the version of a component against the
+// compatibility matrix, and warns if its major version is different from
+// this CDC, which is not tested together.
+func checkCompatibility(component, addr, version string) ([]*Incompatibility, error) {
+	ver, err := semver.NewVersion(removeVAndHash(version))
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrNewSemVersion, err)
+	}
+	var incompats []*Incompatibility
+	for _, rule := range compatMatrix {
+		if rule.component != component {
+			continue
+		}
+		if rule.from != nil && ver.LessThan(*rule.from) {
+			continue
+		}
+		if rule.below != nil && !ver.LessThan(*rule.below) {
+			continue
+		}
+		incompats = append(incompats, &Incompatibility{
+			Component: component,
+			Address:   addr,
+			Version:   removeVAndHash(version),
+			Refused:   rule.refuse,
+			Reason:    rule.reason,
+		})
+	}
+	if len(incompats) == 0 {
+		if release := ReleaseSemver(); release != "" && semver.New(release).Major != ver.Major {
+			incompats = append(incompats, &Incompatibility{
+				Component: component,
+				Address:   addr,
+				Version:   removeVAndHash(version),
+				Reason:    fmt.Sprintf("the major version is different from TiCDC %s", release),
+			})
+		}
+	}
+	return incompats, nil
+}
+
+// handleIncompatibilities logs the incompatibilities as warnings, and returns
+// the first refused one as an error unless ignore is true.
+func handleIncompatibilities(incompats []*Incompatibility, ignore bool) error {
+	for _, incompat := range incompats {
+		if incompat.Refused && !ignore {
+			return cerror.ErrVersionIncompatible.GenWithStackByArgs(incompat.String())
+		}
+	}
+	for _, incompat := range incompats {
+		log.Warn("incompatible upstream version", zap.Stringer("incompatibility", incompat),
+			zap.Bool("refused", incompat.Refused))
+	}
+	return nil
+}
+
+// tidbTopologyPrefix is the etcd key prefix TiDB registers its topology at,
+// see more: https://github.com/pingcap/tidb/blob/v4.0.0/domain/infosync/info.go
+const tidbTopologyPrefix = "/topology/tidb/"
+
+// tidbServerVersionSep separates the MySQL version and the TiDB version in
+// the server version of TiDB, such as "5.7.25-TiDB-v4.0.8".
+const tidbServerVersionSep = "-TiDB-"
+
+// tidbVersion returns the TiDB version in the server version of TiDB.
+func tidbVersion(serverVersion string) string {
+	if idx := strings.Index(serverVersion, tidbServerVersionSep); idx >= 0 {
+		return serverVersion[idx+len(tidbServerVersionSep):]
+	}
+	return serverVersion
+}
+
+// CheckTiDBVersion checks the versions of the TiDB servers registered in the
+// etcd of PD against the compatibility matrix. The refused versions are
+// returned as an error unless ignoreIncompat is true, the others are logged
+// as warnings.
+func CheckTiDBVersion(ctx context.Context, etcdCli *clientv3.Client, ignoreIncompat bool) error {
+	resp, err := etcdCli.Get(ctx, tidbTopologyPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return cerror.WrapError(cerror.ErrCheckClusterVersionFromPD, err)
+	}
+	var incompats []*Incompatibility
+	for _, kv := range resp.Kvs {
+		key := string(kv.Key)
+		if !strings.HasSuffix(key, "/info") {
+			continue
+		}
+		addr := strings.TrimSuffix(strings.TrimPrefix(key, tidbTopologyPrefix), "/info")
+		info := struct {
+			Version string `json:"version"`
+		}{}
+		if err := json.Unmarshal(kv.Value, &info); err != nil {
+			log.Warn("invalid TiDB topology", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		found, err := checkCompatibility(ComponentTiDB, addr, tidbVersion(info.Version))
+		if err != nil {
+			log.Warn("unknown TiDB version", zap.String("address", addr),
+				zap.String("version", info.Version), zap.Error(err))
+			continue
+		}
+		incompats = append(incompats, found...)
+	}
+	return handleIncompatibilities(incompats, ignoreIncompat)
+}
@@ -19,7 +19,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/cenkalti/backoff"
 	"github.com/pingcap/errors"
 	cerrors "github.com/pingcap/ticdc/pkg/errors"
 	"github.com/pingcap/ticdc/pkg/retry"
@@ -50,18 +49,12 @@ func (p *defaultAsyncPoolImpl) Go(ctx context.Context, f func()) error {
 	if p.doGo(ctx, f) == nil {
 		return nil
 	}
-	return errors.Trace(retry.Run(time.Millisecond*1, 25, func() error {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-		err := errors.Trace(p.doGo(ctx, f))
-		if err != nil && cerrors.ErrAsyncPoolExited.NotEqual(errors.Cause(err)) {
-			return backoff.Permanent(err)
-		}
-		return err
-	}))
+	return errors.Trace(retry.Do(ctx, func() error {
+		return errors.Trace(p.doGo(ctx, f))
+	}, retry.WithBackoffBaseDelay(time.Millisecond), retry.WithMaxTries(26),
+		retry.WithIsRetryableErr(func(err error) bool {
+			return cerrors.ErrAsyncPoolExited.Equal(errors.Cause(err))
+		})))
 }
 
 func (p *defaultAsyncPoolImpl) doGo(ctx context.Context, f func()) error {
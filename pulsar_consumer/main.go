@@ -0,0 +1,448 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"math"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/ticdc/cdc/model"
+	"github.com/pingcap/ticdc/cdc/sink"
+	"github.com/pingcap/ticdc/cdc/sink/codec"
+	pulsarproducer "github.com/pingcap/ticdc/cdc/sink/producer/pulsar"
+	"github.com/pingcap/ticdc/pkg/logutil"
+	"github.com/pingcap/ticdc/pkg/mqconsumer"
+	"github.com/pingcap/ticdc/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Pulsar configuration options
+var (
+	pulsarClientOptions   *pulsar.ClientOptions
+	pulsarTopic           string
+	pulsarSubscription    = "ticdc_pulsar_consumer"
+	pulsarReceiveTimeout  = 100 * time.Millisecond
+	pulsarMaxMessageBytes = math.MaxInt64
+
+	protocol             codec.Protocol
+	registryURL          string
+	claimCheckStorageURI string
+
+	downstreamURIStr string
+
+	logPath  string
+	logLevel string
+	timezone string
+)
+
+func init() {
+	var upstreamURIStr string
+
+	flag.StringVar(&upstreamURIStr, "upstream-uri", "", "Pulsar uri")
+	flag.StringVar(&downstreamURIStr, "downstream-uri", "", "downstream sink uri")
+	flag.StringVar(&logPath, "log-file", "cdc_pulsar_consumer.log", "log file path")
+	flag.StringVar(&logLevel, "log-level", "info", "log file path")
+	flag.StringVar(&timezone, "tz", "System", "Specify time zone of Pulsar consumer")
+	flag.StringVar(&claimCheckStorageURI, "claim-check-storage-uri", "", "Storage uri of the messages offloaded by the claim-check oversized row policy")
+	flag.Parse()
+
+	err := logutil.InitLogger(&logutil.Config{
+		Level: logLevel,
+		File:  logPath,
+	})
+	if err != nil {
+		log.Fatal("init logger failed", zap.Error(err))
+	}
+
+	upstreamURI, err := url.Parse(upstreamURIStr)
+	if err != nil {
+		log.Fatal("invalid upstream-uri", zap.Error(err))
+	}
+	scheme := strings.ToLower(upstreamURI.Scheme)
+	if scheme != "pulsar" && scheme != "pulsar+ssl" {
+		log.Fatal("invalid upstream-uri scheme, the scheme of upstream-uri must be `pulsar` or `pulsar+ssl`",
+			zap.String("upstream-uri", upstreamURIStr))
+	}
+	// the upstream uri shares the same format with the sink uri of the pulsar sink
+	pulsarClientOptions, err = pulsarproducer.NewClientOptions(upstreamURI)
+	if err != nil {
+		log.Fatal("invalid upstream-uri", zap.Error(err))
+	}
+	pulsarTopic = strings.Trim(upstreamURI.Path, "/")
+	if pulsarTopic == "" {
+		pulsarTopic = upstreamURI.Query().Get("topic")
+	}
+	if pulsarTopic == "" {
+		log.Fatal("topic is not specified in upstream-uri", zap.String("upstream-uri", upstreamURIStr))
+	}
+	s := upstreamURI.Query().Get("subscription-name")
+	if s != "" {
+		pulsarSubscription = s
+	}
+	protocol, registryURL, err = mqconsumer.ParseProtocol(upstreamURI.Query())
+	if err != nil {
+		log.Fatal("invalid upstream-uri", zap.Error(err))
+	}
+}
+
+func main() {
+	log.Info("Starting a new TiCDC pulsar consumer", zap.Int("protocol", int(protocol)))
+
+	client, err := pulsar.NewClient(*pulsarClientOptions)
+	if err != nil {
+		log.Fatal("Error creating pulsar client", zap.Error(err))
+	}
+	defer client.Close()
+	// messages of a partition are consumed by an exclusive consumer to keep them in order
+	topics, err := client.TopicPartitions(pulsarTopic)
+	if err != nil {
+		log.Fatal("Error getting topic partitions", zap.String("topic", pulsarTopic), zap.Error(err))
+	}
+	log.Info("get partitions of topic", zap.String("topic", pulsarTopic), zap.Strings("partitions", topics))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer, err := NewConsumer(ctx, len(topics))
+	if err != nil {
+		log.Fatal("Error creating consumer", zap.Error(err))
+	}
+
+	wg := &sync.WaitGroup{}
+	for i, topic := range topics {
+		partitionConsumer, err := client.Subscribe(pulsar.ConsumerOptions{
+			Topic:                       topic,
+			SubscriptionName:            pulsarSubscription,
+			Type:                        pulsar.Exclusive,
+			SubscriptionInitialPosition: pulsar.SubscriptionPositionEarliest,
+		})
+		if err != nil {
+			log.Fatal("Error subscribing topic", zap.String("topic", topic), zap.Error(err))
+		}
+		defer partitionConsumer.Close()
+		wg.Add(1)
+		go func(partition int, partitionConsumer pulsar.Consumer) {
+			defer wg.Done()
+			if err := consumer.ConsumePartition(ctx, partition, partitionConsumer); err != nil && errors.Cause(err) != context.Canceled {
+				log.Fatal("Error from consumer", zap.Int("partition", partition), zap.Error(err))
+			}
+		}(i, partitionConsumer)
+	}
+
+	go func() {
+		if err := consumer.Run(ctx); err != nil && errors.Cause(err) != context.Canceled {
+			log.Fatal("Error running consumer", zap.Error(err))
+		}
+	}()
+	log.Info("TiCDC pulsar consumer up and running!...")
+
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-ctx.Done():
+		log.Info("terminating: context cancelled")
+	case <-sigterm:
+		log.Info("terminating: via signal")
+	}
+	cancel()
+	wg.Wait()
+}
+
+type partitionSink struct {
+	sink.Sink
+	resolvedTs uint64
+
+	// the messages are acknowledged after the events in them are flushed to the downstream
+	ackMu       sync.Mutex
+	consumer    pulsar.Consumer
+	unresolved  []pulsar.Message
+	pendingAcks []pendingAck
+}
+
+// pendingAck is the messages before a resolved event, they can be acknowledged
+// after the resolved ts is flushed.
+type pendingAck struct {
+	resolvedTs uint64
+	messages   []pulsar.Message
+}
+
+func (s *partitionSink) addMessage(message pulsar.Message, resolvedTs uint64) {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	s.unresolved = append(s.unresolved, message)
+	if resolvedTs != 0 {
+		s.pendingAcks = append(s.pendingAcks, pendingAck{resolvedTs: resolvedTs, messages: s.unresolved})
+		s.unresolved = nil
+	}
+}
+
+func (s *partitionSink) ack(resolvedTs uint64) {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	i := 0
+	for ; i < len(s.pendingAcks) && s.pendingAcks[i].resolvedTs <= resolvedTs; i++ {
+		for _, message := range s.pendingAcks[i].messages {
+			s.consumer.Ack(message)
+		}
+	}
+	s.pendingAcks = s.pendingAcks[i:]
+}
+
+// Consumer replicates the messages of the pulsar sink to the downstream
+type Consumer struct {
+	ddls mqconsumer.DDLQueue
+
+	sinks          []*partitionSink
+	ddlSink        sink.Sink
+	decoderFactory *mqconsumer.DecoderFactory
+	// seq is used as the commit ts of the events of the protocols without commit ts
+	seq   uint64
+	seqMu sync.Mutex
+
+	fakeTableIDGenerator *mqconsumer.FakeTableIDGenerator
+
+	globalResolvedTs uint64
+}
+
+// NewConsumer creates a new cdc pulsar consumer
+func NewConsumer(ctx context.Context, partitionNum int) (*Consumer, error) {
+	tz, err := util.GetTimezone(timezone)
+	if err != nil {
+		return nil, errors.Annotate(err, "can not load timezone")
+	}
+	ctx = util.PutTimezoneInCtx(ctx, tz)
+	c := new(Consumer)
+	c.fakeTableIDGenerator = mqconsumer.NewFakeTableIDGenerator()
+	c.decoderFactory, err = mqconsumer.NewDecoderFactory(ctx, protocol, registryURL, claimCheckStorageURI)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	sinks, ddlSink, err := mqconsumer.NewSinks(ctx, "pulsar-consumer", downstreamURIStr, partitionNum)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	c.sinks = make([]*partitionSink, partitionNum)
+	for i, s := range sinks {
+		c.sinks[i] = &partitionSink{Sink: s}
+	}
+	c.ddlSink = ddlSink
+	return c, nil
+}
+
+// ConsumePartition receives the messages of a partition until the context is canceled.
+func (c *Consumer) ConsumePartition(ctx context.Context, partition int, consumer pulsar.Consumer) error {
+	sink := c.sinks[partition]
+	sink.ackMu.Lock()
+	sink.consumer = consumer
+	sink.ackMu.Unlock()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		receiveCtx, cancel := context.WithTimeout(ctx, pulsarReceiveTimeout)
+		message, err := consumer.Receive(receiveCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// no message is received before timeout
+			continue
+		}
+		log.Debug("Message received", zap.Int("partition", partition), zap.String("key", message.Key()), zap.ByteString("value", message.Payload()))
+		resolvedTs, err := c.handleMessage(ctx, partition, message)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		sink.addMessage(message, resolvedTs)
+	}
+}
+
+// handleMessage decodes the events in the message, it returns the resolved ts if
+// the message contains a resolved event.
+func (c *Consumer) handleMessage(ctx context.Context, partition int, message pulsar.Message) (uint64, error) {
+	sink := c.sinks[partition]
+	var seq uint64
+	if !c.decoderFactory.WithResolvedEvent() {
+		// the events are applied in the order they are received, the events of a
+		// message share a sequence number as their commit ts
+		c.seqMu.Lock()
+		defer c.seqMu.Unlock()
+		c.seq++
+		seq = c.seq
+	}
+	key, value := []byte(message.Key()), message.Payload()
+	batchDecoder, err := c.decoderFactory.NewDecoder(ctx, key, value)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	var resolvedTs uint64
+	counter := 0
+	for {
+		tp, hasNext, err := batchDecoder.HasNext()
+		if err != nil {
+			return 0, errors.Annotate(err, "decode message key failed")
+		}
+		if !hasNext {
+			break
+		}
+
+		counter++
+		// If the message containing only one event exceeds the length limit, CDC will allow it and issue a warning.
+		if len(key)+len(value) > pulsarMaxMessageBytes && counter > 1 {
+			log.Fatal("pulsar max-messages-bytes exceeded", zap.Int("max-message-bytes", pulsarMaxMessageBytes),
+				zap.Int("recevied-bytes", len(key)+len(value)))
+		}
+
+		switch tp {
+		case model.MqMessageTypeDDL:
+			ddl, err := batchDecoder.NextDDLEvent()
+			if err != nil {
+				return 0, errors.Annotate(err, "decode message value failed")
+			}
+			if seq != 0 {
+				ddl.CommitTs = seq
+			}
+			c.ddls.Append(ddl, atomic.LoadUint64(&c.globalResolvedTs))
+		case model.MqMessageTypeRow:
+			row, err := batchDecoder.NextRowChangedEvent()
+			if err != nil {
+				return 0, errors.Annotate(err, "decode message value failed")
+			}
+			if seq != 0 {
+				row.CommitTs = seq
+			}
+			globalResolvedTs := atomic.LoadUint64(&c.globalResolvedTs)
+			if row.CommitTs <= globalResolvedTs || row.CommitTs <= atomic.LoadUint64(&sink.resolvedTs) {
+				log.Debug("filter fallback row", zap.String("row", message.Key()),
+					zap.Uint64("globalResolvedTs", globalResolvedTs),
+					zap.Int("partition", partition))
+				continue
+			}
+			mqconsumer.PrepareRow(row, c.fakeTableIDGenerator)
+			err = sink.EmitRowChangedEvents(ctx, row)
+			if err != nil {
+				return 0, errors.Annotate(err, "emit row changed event failed")
+			}
+		case model.MqMessageTypeResolved:
+			ts, err := batchDecoder.NextResolvedEvent()
+			if err != nil {
+				return 0, errors.Annotate(err, "decode message value failed")
+			}
+			if atomic.LoadUint64(&sink.resolvedTs) < ts {
+				log.Debug("update sink resolved ts",
+					zap.Uint64("ts", ts),
+					zap.Int("partition", partition))
+				atomic.StoreUint64(&sink.resolvedTs, ts)
+			}
+			resolvedTs = ts
+		case model.MqMessageTypeHeartbeat:
+			// the heartbeats of the idle tables are only logged
+			table, ts, err := batchDecoder.(codec.HeartbeatDecoder).NextHeartbeatEvent()
+			if err != nil {
+				return 0, errors.Annotate(err, "decode message value failed")
+			}
+			log.Debug("receive table heartbeat", zap.Stringer("table", table), zap.Uint64("ts", ts),
+				zap.Int("partition", partition))
+		}
+	}
+	if seq != 0 {
+		resolvedTs = seq
+	}
+	return resolvedTs, nil
+}
+
+// getResolvedTs returns the ts all events before which are received.
+func (c *Consumer) getResolvedTs() uint64 {
+	if !c.decoderFactory.WithResolvedEvent() {
+		c.seqMu.Lock()
+		defer c.seqMu.Unlock()
+		return c.seq
+	}
+	resolvedTs := uint64(math.MaxUint64)
+	for _, sink := range c.sinks {
+		if ts := atomic.LoadUint64(&sink.resolvedTs); ts < resolvedTs {
+			resolvedTs = ts
+		}
+	}
+	return resolvedTs
+}
+
+// flushAll flushes the rows to the downstream and acknowledges the messages before resolvedTs.
+func (c *Consumer) flushAll(ctx context.Context, resolvedTs uint64) error {
+	for _, sink := range c.sinks {
+		if err := mqconsumer.SyncFlushRowChangedEvents(ctx, sink, resolvedTs); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for _, sink := range c.sinks {
+		sink.ack(resolvedTs)
+	}
+	return nil
+}
+
+// Run runs the Consumer
+func (c *Consumer) Run(ctx context.Context) error {
+	var lastGlobalResolvedTs uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		time.Sleep(100 * time.Millisecond)
+		globalResolvedTs := c.getResolvedTs()
+		// handle ddl
+		todoDDL := c.ddls.Front()
+		if todoDDL != nil && globalResolvedTs >= todoDDL.CommitTs {
+			// flush DMLs
+			if err := c.flushAll(ctx, todoDDL.CommitTs); err != nil {
+				return errors.Trace(err)
+			}
+			// execute ddl
+			if err := c.ddlSink.EmitDDLEvent(ctx, todoDDL); err != nil {
+				return errors.Trace(err)
+			}
+			c.ddls.Pop()
+			continue
+		}
+
+		if todoDDL != nil && todoDDL.CommitTs < globalResolvedTs {
+			globalResolvedTs = todoDDL.CommitTs
+		}
+		if lastGlobalResolvedTs == globalResolvedTs {
+			continue
+		}
+		lastGlobalResolvedTs = globalResolvedTs
+		atomic.StoreUint64(&c.globalResolvedTs, globalResolvedTs)
+		log.Info("update globalResolvedTs", zap.Uint64("ts", globalResolvedTs))
+
+		if err := c.flushAll(ctx, globalResolvedTs); err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
@@ -78,7 +78,7 @@ func main() {
 
 	var finishCount int32
 	for i := 0; i < *numSorters; i++ {
-		sorters[i] = pullerSorter.NewUnifiedSorter(*sorterDir, fmt.Sprintf("test-%d", i), "0.0.0.0:0")
+		sorters[i] = pullerSorter.NewUnifiedSorter(*sorterDir, fmt.Sprintf("test-%d", i), "0.0.0.0:0", nil)
 		finalI := i
 
 		// run sorter
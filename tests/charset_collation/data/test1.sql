@@ -0,0 +1,44 @@
+drop database if exists `charset_collation_test`;
+create database `charset_collation_test`;
+use `charset_collation_test`;
+
+CREATE TABLE t_binary (
+    id int primary key,
+    a binary(8),
+    b varbinary(20),
+    c blob,
+    unique key uk_b(b)
+);
+
+CREATE TABLE t_bin_collation (
+    a varchar(20) charset utf8mb4 collate utf8mb4_bin primary key,
+    b char(10) charset utf8 collate utf8_bin,
+    c text charset utf8mb4 collate utf8mb4_bin
+);
+
+CREATE TABLE t_ci_collation (
+    id int primary key,
+    a varchar(20) charset utf8mb4 collate utf8mb4_general_ci,
+    b char(10) charset latin1 collate latin1_bin,
+    c varchar(20) charset ascii collate ascii_bin,
+    unique key uk_a(a)
+);
+
+insert into t_binary values (1, x'00ff', x'00010203', x'fffefd'), (2, 'ab', 'ab ', x'00'), (3, '', '', '');
+insert into t_binary values (4, x'e4b8ad', x'e4b8ade69687', x'e4b8ad'), (5, null, null, null);
+insert into t_bin_collation values ('a', 'a', 'a'), ('b ', 'b ', 'b '), ('中文', '中文', '中文'), ('😉', 'c', '😉');
+insert into t_ci_collation values (1, 'A', 'A', 'A'), (2, 'b ', 'b ', 'b '), (3, 'd', 'd', 'd');
+
+-- the values equal in the collations are replaced in the same transactions,
+-- the conflicts must be detected by the sink
+update t_binary set b = x'0001020304' where id = 1;
+update t_binary set b = x'00010203' where id = 2;
+begin;
+delete from t_bin_collation where a = 'a';
+insert into t_bin_collation values ('a  ', 'a', 'a  ');
+commit;
+begin;
+delete from t_ci_collation where id = 1;
+insert into t_ci_collation values (4, 'a', 'a', 'a');
+update t_ci_collation set a = 'B' where id = 2;
+commit;
@@ -0,0 +1,3 @@
+use `charset_collation_test`;
+
+create table finish_mark (id int primary key);
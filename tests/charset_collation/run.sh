@@ -0,0 +1,65 @@
+#!/bin/bash
+
+set -e
+
+CUR=$( cd "$( dirname "${BASH_SOURCE[0]}" )" && pwd )
+source $CUR/../_utils/test_prepare
+WORK_DIR=$OUT_DIR/$TEST_NAME
+CDC_BINARY=cdc.test
+SINK_TYPE=$1
+
+function check_values() {
+    # sync_diff compares the checksums of the rows, the values are checked
+    # byte by byte as well
+    run_sql "SELECT HEX(a) AS a, HEX(b) AS b, HEX(c) AS c FROM charset_collation_test.t_binary WHERE id = 1;" ${DOWN_TIDB_HOST} ${DOWN_TIDB_PORT}
+    check_contains "a: 00FF000000000000"
+    check_contains "b: 0001020304"
+    check_contains "c: FFFEFD"
+    run_sql "SELECT HEX(b) AS b FROM charset_collation_test.t_binary WHERE id = 2;" ${DOWN_TIDB_HOST} ${DOWN_TIDB_PORT}
+    check_contains "b: 00010203"
+    run_sql "SELECT HEX(a) AS a, HEX(c) AS c FROM charset_collation_test.t_bin_collation WHERE b = 'a';" ${DOWN_TIDB_HOST} ${DOWN_TIDB_PORT}
+    check_contains "a: 612020"
+    check_contains "c: 612020"
+    run_sql "SELECT HEX(a) AS a FROM charset_collation_test.t_bin_collation WHERE b = 'c';" ${DOWN_TIDB_HOST} ${DOWN_TIDB_PORT}
+    check_contains "a: F09F9889"
+    run_sql "SELECT id, a FROM charset_collation_test.t_ci_collation ORDER BY id;" ${DOWN_TIDB_HOST} ${DOWN_TIDB_PORT}
+    check_not_contains "id: 1"
+    check_contains "a: B"
+    check_contains "id: 4"
+}
+
+function run() {
+    rm -rf $WORK_DIR && mkdir -p $WORK_DIR
+
+    start_tidb_cluster --workdir $WORK_DIR --tidb-config $CUR/conf/tidb_config.toml
+
+    cd $WORK_DIR
+
+    # record tso before we create tables to skip the system table DDLs
+    start_ts=$(cdc cli tso query --pd=http://$UP_PD_HOST_1:$UP_PD_PORT_1)
+
+    run_cdc_server --workdir $WORK_DIR --binary $CDC_BINARY
+
+    TOPIC_NAME="ticdc-charset-collation-test-$RANDOM"
+    case $SINK_TYPE in
+        kafka) SINK_URI="kafka://127.0.0.1:9092/$TOPIC_NAME?partition-num=4&kafka-version=${KAFKA_VERSION}";;
+        *) SINK_URI="mysql://root@127.0.0.1:3306/";;
+    esac
+    cdc cli changefeed create --start-ts=$start_ts --sink-uri="$SINK_URI" --config $CUR/conf/changefeed.toml
+    if [ "$SINK_TYPE" == "kafka" ]; then
+      run_kafka_consumer $WORK_DIR "kafka://127.0.0.1:9092/$TOPIC_NAME?partition-num=4&version=${KAFKA_VERSION}"
+    fi
+
+    run_sql_file $CUR/data/test1.sql ${UP_TIDB_HOST} ${UP_TIDB_PORT}
+    run_sql_file $CUR/data/test2.sql ${UP_TIDB_HOST} ${UP_TIDB_PORT}
+    check_table_exists charset_collation_test.finish_mark ${DOWN_TIDB_HOST} ${DOWN_TIDB_PORT}
+    check_sync_diff $WORK_DIR $CUR/conf/diff_config.toml
+    check_values
+
+    cleanup_process $CDC_BINARY
+}
+
+trap stop_tidb_cluster EXIT
+run $*
+check_cdc_state_log $WORK_DIR
+echo "[$(date)] <<<<<< run test case $TEST_NAME success! >>>>>>"
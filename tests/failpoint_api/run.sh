@@ -0,0 +1,76 @@
+#!/bin/bash
+
+set -e
+
+CUR=$( cd "$( dirname "${BASH_SOURCE[0]}" )" && pwd )
+source $CUR/../_utils/test_prepare
+WORK_DIR=$OUT_DIR/$TEST_NAME
+CDC_BINARY=cdc.test
+SINK_TYPE=$1
+
+MAX_RETRIES=20
+FAILPOINT_PREFIX=github.com/pingcap/ticdc/cdc
+
+function check_changefeed_error() {
+    pd_addr=$1
+    changefeed_id=$2
+    expected_state=$3
+    expected_msg=$4
+    info=$(cdc cli --pd=$pd_addr changefeed query -s -c $changefeed_id)
+    echo "$info"
+    state=$(echo $info|jq -r ".state")
+    if [[ "$state" != "$expected_state" ]]; then
+        echo "unexpected state $state, expected $expected_state"
+        exit 1
+    fi
+    message=$(echo $info|jq -r ".error.message")
+    if [[ ! "$message" =~ "$expected_msg" ]]; then
+        echo "error message '$message' is not as expected '$expected_msg'"
+        exit 1
+    fi
+}
+
+export -f check_changefeed_error
+
+function run() {
+    rm -rf $WORK_DIR && mkdir -p $WORK_DIR
+    start_tidb_cluster --workdir $WORK_DIR
+    cd $WORK_DIR
+
+    pd_addr="http://$UP_PD_HOST_1:$UP_PD_PORT_1"
+    TOPIC_NAME="ticdc-failpoint-api-test-$RANDOM"
+    case $SINK_TYPE in
+        kafka) SINK_URI="kafka://127.0.0.1:9092/$TOPIC_NAME?partition-num=4&kafka-version=${KAFKA_VERSION}";;
+        *) SINK_URI="mysql://root@127.0.0.1:3306/";;
+    esac
+    if [ "$SINK_TYPE" == "kafka" ]; then
+      run_kafka_consumer $WORK_DIR "kafka://127.0.0.1:9092/$TOPIC_NAME?partition-num=4&version=${KAFKA_VERSION}"
+    fi
+
+    run_sql "CREATE DATABASE failpoint_api;" ${UP_TIDB_HOST} ${UP_TIDB_PORT}
+    run_sql "CREATE TABLE failpoint_api.t1(id int primary key auto_increment, val int);" ${UP_TIDB_HOST} ${UP_TIDB_PORT}
+
+    run_cdc_server --workdir $WORK_DIR --binary $CDC_BINARY --addr "127.0.0.1:8300" --pd $pd_addr
+    # the task status is written with conflicts twice, and then succeeds by the retries
+    failpoint enable $FAILPOINT_PREFIX/kv/EtcdTxnConflict "2*return(true)"
+    changefeed_id=$(cdc cli changefeed create --pd=$pd_addr --sink-uri="$SINK_URI" 2>&1|tail -n2|head -n1|awk '{print $2}')
+    check_table_exists "failpoint_api.t1" ${DOWN_TIDB_HOST} ${DOWN_TIDB_PORT}
+    failpoint disable $FAILPOINT_PREFIX/kv/EtcdTxnConflict
+
+    # the changefeed is stopped by the flush error injected after the table is replicated
+    failpoint enable $FAILPOINT_PREFIX/sink/SinkFlushError "1*return(true)"
+    run_sql "INSERT INTO failpoint_api.t1 VALUES (),(),();" ${UP_TIDB_HOST} ${UP_TIDB_PORT}
+    ensure $MAX_RETRIES check_changefeed_error $pd_addr $changefeed_id "stopped" "injected sink flush error"
+    failpoint disable $FAILPOINT_PREFIX/sink/SinkFlushError
+
+    cdc cli changefeed resume --changefeed-id=$changefeed_id --pd=$pd_addr
+    run_sql "INSERT INTO failpoint_api.t1 VALUES (),(),();" ${UP_TIDB_HOST} ${UP_TIDB_PORT}
+    check_sync_diff $WORK_DIR $CUR/conf/diff_config.toml
+
+    cleanup_process $CDC_BINARY
+}
+
+trap stop_tidb_cluster EXIT
+run $*
+check_cdc_state_log $WORK_DIR
+echo "[$(date)] <<<<<< run test case $TEST_NAME success! >>>>>>"
@@ -27,6 +27,7 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/ticdc/cdc/kv"
+	"github.com/pingcap/ticdc/pkg/httputil"
 	"github.com/pingcap/ticdc/pkg/retry"
 	"go.etcd.io/etcd/clientv3"
 	"go.etcd.io/etcd/pkg/logutil"
@@ -56,7 +57,7 @@ func main() {
 		log.Fatal("failed to create cluster info", zap.Error(err))
 	}
 
-	err = retry.Run(100*time.Millisecond, 20, func() error {
+	err = retry.Do(ctx, func() error {
 		err := cluster.refreshInfo(ctx)
 		if err != nil {
 			log.Warn("error refreshing cluster info", zap.Error(err))
@@ -68,7 +69,7 @@ func main() {
 			return errors.New("too few captures")
 		}
 		return nil
-	})
+	}, retry.WithBackoffBaseDelay(100*time.Millisecond), retry.WithBackoffMaxDelay(time.Minute), retry.WithMaxTries(21))
 
 	if err != nil {
 		log.Fatal("Fail to get captures", zap.Error(err))
@@ -110,9 +111,9 @@ func main() {
 	log.Info("all tables are moved", zap.String("sourceCapture", sourceCapture), zap.String("targetCapture", targetCapture))
 
 	for counter := 0; counter < 30; counter++ {
-		err := retry.Run(100*time.Millisecond, 5, func() error {
+		err := retry.Do(ctx, func() error {
 			return cluster.refreshInfo(ctx)
-		})
+		}, retry.WithBackoffBaseDelay(100*time.Millisecond), retry.WithMaxTries(6))
 		if err != nil {
 			log.Warn("error refreshing cluster info", zap.Error(err))
 		}
@@ -259,7 +260,11 @@ func moveTable(ctx context.Context, ownerAddr string, changefeed string, target
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := http.DefaultClient.Do(req)
+	cli, err := httputil.NewClient(nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := cli.Do(req)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -23,7 +23,6 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net"
-	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -32,6 +31,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/log"
 	"github.com/pingcap/parser/model"
+	"github.com/pingcap/ticdc/pkg/httputil"
 	"github.com/pingcap/ticdc/tests/util"
 	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/store/tikv"
@@ -106,8 +106,6 @@ func finishMark(sourceDB *sql.DB) error {
 }
 
 func addLock(ctx context.Context, cfg *util.Config) error {
-	http.DefaultClient.Timeout = 10 * time.Second
-
 	tableID, err := getTableID(cfg.SourceDBCfg[0].Host, "test", "t1")
 	if err != nil {
 		return errors.Trace(err)
@@ -141,7 +139,11 @@ func getTableID(dbAddr, dbName, table string) (int64, error) {
 	dbStatusAddr := net.JoinHostPort(dbAddr, "10080")
 	url := fmt.Sprintf("http://%s/schema/%s/%s", dbStatusAddr, dbName, table)
 
-	resp, err := http.Get(url)
+	cli, err := httputil.NewClient(nil, httputil.WithTimeout(10*time.Second))
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	resp, err := cli.Get(url)
 	if err != nil {
 		return 0, errors.Trace(err)
 	}
@@ -26,12 +26,16 @@ function prepare() {
     TOPIC_NAME="ticdc-simple-test-$RANDOM"
     case $SINK_TYPE in
         kafka) SINK_URI="kafka+ssl://127.0.0.1:9092/$TOPIC_NAME?partition-num=4&kafka-client-id=cdc_test_simple&kafka-version=${KAFKA_VERSION}";;
+        pulsar) SINK_URI="pulsar://127.0.0.1:6650/$TOPIC_NAME";;
         *) SINK_URI="mysql+ssl://root@127.0.0.1:3306/";;
     esac
     run_cdc_cli changefeed create --start-ts=$start_ts --sink-uri="$SINK_URI"
     if [ "$SINK_TYPE" == "kafka" ]; then
       run_kafka_consumer $WORK_DIR "kafka://127.0.0.1:9092/$TOPIC_NAME?partition-num=4&version=${KAFKA_VERSION}"
     fi
+    if [ "$SINK_TYPE" == "pulsar" ]; then
+      run_pulsar_consumer $WORK_DIR "pulsar://127.0.0.1:6650/$TOPIC_NAME?subscription-name=cdc_test_simple"
+    fi
 }
 
 function sql_check() {